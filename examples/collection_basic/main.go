@@ -151,7 +151,8 @@ func main() {
 	// Step 6: Update a document
 	fmt.Println("Updating document metadata...")
 	doc, _ = coll.Get("doc-001")
-	doc.Metadata["views"] = doc.Metadata["views"].(int) + 1
+	views, _ := doc.GetInt("views") // int when buffered, float64 once saved
+	doc.SetTyped("views", views+1)
 	doc.Metadata["last_viewed"] = time.Now().Format("2006-01-02")
 
 	if err := coll.Update(doc); err != nil {
@@ -321,6 +321,28 @@ func main() {
 	}
 	fmt.Println()
 
+	// Demo 9: List Filters
+	fmt.Println("═══════════════════════════════════════════════════════════")
+	fmt.Println("Demo 9: List Filters (tags contain 'audio', brand in list)")
+	fmt.Println("═══════════════════════════════════════════════════════════")
+
+	filter9 := &vego.AndFilter{
+		Filters: []vego.Filter{
+			&vego.MetadataFilter{Field: "tags", Operator: "contains", Value: "audio"},
+			&vego.MetadataFilter{Field: "brand", Operator: "in", Value: []string{"TechCo", "GameTech"}},
+		},
+	}
+
+	results, _ = products.SearchWithFilter(query, 10, filter9)
+	fmt.Printf("Found %d audio products from TechCo or GameTech:\n", len(results))
+	for _, r := range results {
+		fmt.Printf("  • %s (%s) %v\n",
+			r.Document.Metadata["name"],
+			r.Document.Metadata["brand"],
+			r.Document.Metadata["tags"])
+	}
+	fmt.Println()
+
 	fmt.Println("=== Metadata Filtering Demo completed! ===")
 	fmt.Println()
 	fmt.Println("Available filter operators:")
@@ -331,12 +353,14 @@ func main() {
 	fmt.Println("  lt    - Less than")
 	fmt.Println("  lte   - Less than or equal")
 	fmt.Println("  in    - In list")
-	fmt.Println("  contains - String contains")
+	fmt.Println("  nin   - Not in list")
+	fmt.Println("  contains - String contains, or slice has element")
 	fmt.Println()
 	fmt.Println("Filter types:")
 	fmt.Println("  MetadataFilter - Single field condition")
 	fmt.Println("  AndFilter      - All conditions must match")
 	fmt.Println("  OrFilter       - Any condition can match")
+	fmt.Println("  NotFilter      - Condition must not match")
 }
 
 // generateVector creates a deterministic random vector
@@ -157,9 +157,7 @@ func main() {
 		for j, r := range results {
 			content := r.Document.Metadata["content"].(string)
 			source := r.Document.Metadata["source"].(string)
-			similarity := 1.0 - float64(r.Distance) // Rough similarity estimate
-
-			fmt.Printf("  [%d] Similarity: %.2f%% (from %s)\n", j+1, similarity*100, source)
+			fmt.Printf("  [%d] Similarity: %.4f (from %s)\n", j+1, r.Similarity, source)
 			fmt.Printf("      %.80s...\n\n", content)
 
 			contexts = append(contexts, content)
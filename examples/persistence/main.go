@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"time"
 
 	hnsw "github.com/wzqhbustb/vego/index"
@@ -18,8 +19,7 @@ func main() {
 	fmt.Println()
 
 	// Create a temporary directory for the demo
-	tmpDir := "/tmp/vego_persistence_demo"
-	os.MkdirAll(tmpDir, 0755)
+	tmpDir, _ := os.MkdirTemp("", "vego_persistence_demo")
 	defer os.RemoveAll(tmpDir)
 	fmt.Printf("Working directory: %s\n", tmpDir)
 	fmt.Println()
@@ -46,7 +46,7 @@ func main() {
 
 	// Step 2: Save index to disk
 	fmt.Println("Step 2: Saving index to disk...")
-	savePath := tmpDir + "/my_index"
+	savePath := filepath.Join(tmpDir, "my_index")
 	
 	start := time.Now()
 	err := index.SaveToLance(savePath)
@@ -0,0 +1,68 @@
+// Quickstart Example
+// This example loads the embedded fixture dataset into a collection and runs
+// its queries, so the results are real text snippets rather than random IDs.
+//
+// Run: go run main.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/wzqhbustb/vego/vego"
+	"github.com/wzqhbustb/vego/vego/fixtures"
+)
+
+func main() {
+	fmt.Println("=== Vego Quickstart ===")
+	fmt.Println()
+
+	tmpDir, _ := os.MkdirTemp("", "vego_quickstart")
+	defer os.RemoveAll(tmpDir)
+
+	// Step 1: Open a database with the fixtures' dimension
+	db, err := vego.Open(tmpDir, vego.WithDimension(fixtures.Dimension))
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	// Step 2: Load the embedded snippets
+	coll, err := fixtures.LoadCollection(db, "snippets")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("✓ Loaded %d snippets\n\n", coll.Count())
+
+	// Step 3: Run a few queries and measure recall against the labels
+	ctx := context.Background()
+	hits, total := 0, 0
+	for i, q := range fixtures.Queries() {
+		results, err := coll.SearchContext(ctx, q.Vector, len(q.Relevant))
+		if err != nil {
+			panic(err)
+		}
+
+		relevant := make(map[string]bool, len(q.Relevant))
+		for _, id := range q.Relevant {
+			relevant[id] = true
+		}
+		for _, r := range results {
+			if relevant[r.Document.ID] {
+				hits++
+			}
+		}
+		total += len(q.Relevant)
+
+		if i%16 == 0 {
+			fmt.Printf("🔍 %q\n", q.Text)
+			for _, r := range results[:3] {
+				fmt.Printf("   %.3f  %s\n", r.Distance, r.Document.Metadata["text"])
+			}
+			fmt.Println()
+		}
+	}
+
+	fmt.Printf("✓ Recall@10 over %d queries: %.3f\n", len(fixtures.Queries()), float64(hits)/float64(total))
+}
@@ -8,6 +8,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/wzqhbustb/vego/storage/arrow"
@@ -20,8 +21,7 @@ func main() {
 	fmt.Println()
 
 	// Create temp directory
-	tmpDir := "/tmp/vego_storage_demo"
-	os.MkdirAll(tmpDir, 0755)
+	tmpDir, _ := os.MkdirTemp("", "vego_storage_demo")
 	defer os.RemoveAll(tmpDir)
 	fmt.Printf("Working directory: %s\n", tmpDir)
 	fmt.Println()
@@ -90,7 +90,7 @@ func main() {
 
 	// Step 4: Write to Lance file
 	fmt.Println("Step 4: Writing to Lance file...")
-	filename := tmpDir + "/vectors.lance"
+	filename := filepath.Join(tmpDir, "vectors.lance")
 	factory := encoding.NewEncoderFactory(3) // Compression level 3
 	
 	writer, err := column.NewWriter(filename, schema, factory)
@@ -66,6 +66,6 @@ func calculateOptimalQueryEf(datasetSize, topK int) int {
 
 // SearchWithAdaptiveEf - Search using adaptive ef
 func (h *HNSWIndex) SearchWithAdaptiveEf(query []float32, k int) ([]SearchResult, error) {
-	ef := calculateOptimalQueryEf(len(h.nodes), k)
+	ef := calculateOptimalQueryEf(h.Len(), k)
 	return h.Search(query, k, ef)
 }
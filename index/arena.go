@@ -0,0 +1,122 @@
+package hnsw
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// arenaChunkBytes is the size the chunks of an arena are rounded down to,
+// in whole slots, at least one
+const arenaChunkBytes = 256 << 10
+
+// arena stores fixed-width slots of T back to back in large chunks, slot id
+// holding the data of node id, so that node data costs no allocation of its
+// own and nodes added together sit together in memory. Chunks never move
+// once allocated, and the chunk table is replaced copy-on-write, so readers
+// index it without locking. Writers must be serialized, and a slot must be
+// written before the node using it is published.
+type arena[T any] struct {
+	width int  // Elements per slot
+	shift uint // log2 of the slots per chunk
+	mask  int  // Slots per chunk - 1
+
+	chunks atomic.Pointer[[][]T]
+}
+
+// vectorArena holds float32 vectors, a slot per node
+type vectorArena = arena[float32]
+
+func newArena[T any](width, elemSize int) *arena[T] {
+	perChunk := max(arenaChunkBytes/(elemSize*max(width, 1)), 1)
+	shift := uint(bits.Len(uint(perChunk)) - 1)
+	return &arena[T]{
+		width: width,
+		shift: shift,
+		mask:  1<<shift - 1,
+	}
+}
+
+func newVectorArena(dimension int) *vectorArena {
+	return newArena[float32](dimension, 4)
+}
+
+// at returns slot id without copying. It must not be modified unless the
+// slot is being written.
+func (a *arena[T]) at(id int) []T {
+	chunk := (*a.chunks.Load())[id>>a.shift]
+	off := (id & a.mask) * a.width
+	return chunk[off : off+a.width : off+a.width]
+}
+
+// has reports whether slot id has been allocated
+func (a *arena[T]) has(id int) bool {
+	p := a.chunks.Load()
+	return p != nil && id >= 0 && id>>a.shift < len(*p) && (*p)[id>>a.shift] != nil
+}
+
+// slot returns slot id for writing, allocating its chunk if needed
+func (a *arena[T]) slot(id int) []T {
+	if !a.has(id) {
+		a.allocate(id, id+1)
+	}
+	return a.at(id)
+}
+
+// set copies data into slot id
+func (a *arena[T]) set(id int, data []T) {
+	copy(a.slot(id), data)
+}
+
+// reserve allocates the chunks holding slots up to n, which then read as
+// zero. Once it returns, distinct slots below n can be written concurrently
+// through at.
+func (a *arena[T]) reserve(n int) {
+	a.allocate(0, n)
+}
+
+// allocate makes sure the chunks holding slots [from, to) exist, leaving
+// the table entries of chunks before them nil if they are not there yet
+func (a *arena[T]) allocate(from, to int) {
+	var table [][]T
+	if p := a.chunks.Load(); p != nil {
+		table = *p
+	}
+	need := (to + a.mask) >> a.shift
+	grown := table
+	if need > len(table) {
+		grown = make([][]T, need)
+		copy(grown, table)
+	}
+	changed := len(grown) != len(table)
+	for i := from >> a.shift; i < need; i++ {
+		if grown[i] == nil {
+			if !changed {
+				// Copy before filling in a gap readers can see
+				grown = append([][]T(nil), table...)
+				changed = true
+			}
+			grown[i] = make([]T, (a.mask+1)*a.width)
+		}
+	}
+	if changed {
+		a.chunks.Store(&grown)
+	}
+}
+
+// mapSlots makes slots [0, n) read data, which holds them back to back, in
+// an empty arena. Whole chunks alias data, so those slots must never be
+// written; the last partial chunk is copied, as later slots share it.
+func (a *arena[T]) mapSlots(data []T, n int) {
+	size := (a.mask + 1) * a.width
+	table := make([][]T, (n+a.mask)>>a.shift)
+	for i := range table {
+		start := i * size
+		if end := start + size; end <= n*a.width {
+			table[i] = data[start:end:end]
+		} else {
+			table[i] = make([]T, size)
+			copy(table[i], data[start:n*a.width])
+		}
+	}
+	a.chunks.Store(&table)
+}
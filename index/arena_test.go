@@ -0,0 +1,46 @@
+package hnsw
+
+import "testing"
+
+func TestVectorArena(t *testing.T) {
+	// 1000 floats per vector leaves 64 vectors per chunk
+	arena := newVectorArena(1000)
+	if perChunk := arena.mask + 1; perChunk != 64 {
+		t.Fatalf("%d vectors per chunk, want 64", perChunk)
+	}
+
+	vector := make([]float32, 1000)
+	for id := 0; id < 200; id++ {
+		vector[0], vector[999] = float32(id), float32(-id)
+		arena.set(id, vector)
+	}
+	if n := len(*arena.chunks.Load()); n != 4 {
+		t.Errorf("%d chunks for 200 vectors, want 4", n)
+	}
+	for id := 0; id < 200; id++ {
+		v := arena.at(id)
+		if len(v) != 1000 || cap(v) != 1000 || v[0] != float32(id) || v[999] != float32(-id) {
+			t.Fatalf("slot %d = [%v ... %v], len %d, cap %d", id, v[0], v[999], len(v), cap(v))
+		}
+	}
+
+	// Reserved slots read as zero, and earlier chunks stay where they were
+	first := &arena.at(0)[0]
+	arena.reserve(1000)
+	if v := arena.at(999); v[0] != 0 || v[999] != 0 {
+		t.Errorf("reserved slot = [%v ... %v], want zeros", v[0], v[999])
+	}
+	if &arena.at(0)[0] != first {
+		t.Error("reserve moved an existing chunk")
+	}
+
+	// Nodes of an index read their vectors from the index's arena
+	index := NewHNSW(Config{Dimension: 3, Seed: 1})
+	id, _ := index.Add([]float32{1, 2, 3})
+	if got := index.nodes[id].Vector(); len(got) != 3 || got[2] != 3 {
+		t.Errorf("node vector = %v", got)
+	}
+	if index.nodes[id].arena != index.vectors {
+		t.Error("node added outside the index arena")
+	}
+}
@@ -1,6 +1,7 @@
 package hnsw
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
@@ -104,6 +105,32 @@ func generateGaussianVectors(n, dim int, seed int64) [][]float32 {
 }
 
 // bruteForceSearch2 performs exhaustive search for ground truth
+// generateClusteredVectors generates vectors from a mixture of clusters
+// Gaussians around uniformly random centers in [-1, 1]; spread is the
+// per-dimension standard deviation within a cluster
+func generateClusteredVectors(n, dim, clusters int, spread float64, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	// Centers depend only on the shape so data and queries share them
+	centerRng := rand.New(rand.NewSource(int64(dim*7919 + clusters)))
+	centers := make([][]float32, clusters)
+	for c := range centers {
+		centers[c] = make([]float32, dim)
+		for j := range centers[c] {
+			centers[c][j] = centerRng.Float32()*2 - 1
+		}
+	}
+
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		center := centers[rng.Intn(clusters)]
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = center[j] + float32(rng.NormFloat64()*spread)
+		}
+	}
+	return vectors
+}
+
 func bruteForceSearch2(index *HNSWIndex, query []float32, k int) []SearchResult {
 	index.globalLock.RLock()
 	defer index.globalLock.RUnlock()
@@ -111,7 +138,7 @@ func bruteForceSearch2(index *HNSWIndex, query []float32, k int) []SearchResult
 	results := make([]SearchResult, 0, len(index.nodes))
 	for id, node := range index.nodes {
 		if node != nil {
-			dist := index.distFunc(query, node.vector)
+			dist := index.distFunc(query, index.vectors.at(id))
 			results = append(results, SearchResult{ID: id, Distance: dist})
 		}
 	}
@@ -202,6 +229,7 @@ func getStorageSize(path string) (int64, error) {
 // runBenchmark executes a complete benchmark with the given configuration
 func runBenchmark(b *testing.B, config BenchmarkConfig) *BenchmarkResult {
 	b.Helper()
+	b.ReportAllocs()
 
 	result := &BenchmarkResult{Config: config}
 	tempDir := b.TempDir()
@@ -416,6 +444,7 @@ func runBenchmark(b *testing.B, config BenchmarkConfig) *BenchmarkResult {
 		result.QueryQPS, config.TopK, result.Recall, result.Recall*100)
 	b.Logf("Latency - Avg: %v, P50: %v, P95: %v, P99: %v",
 		result.AvgQueryLatency, result.P50Latency, result.P95Latency, result.P99Latency)
+	b.ReportMetric(result.QueryQPS, "qps")
 
 	return result
 }
@@ -837,3 +866,225 @@ func printBenchmarkResult(b *testing.B, result *BenchmarkResult) {
 
 	fmt.Println(strings.Repeat("=", 80))
 }
+
+// BenchmarkAdd_D128 measures the cost and allocations of one Add into an
+// index of 10K vectors
+func BenchmarkAdd_D128(b *testing.B) {
+	vectors := generateRandomVectors(10000+b.N, 128, 42)
+	index := NewHNSW(Config{Dimension: 128, M: 16, EfConstruction: 200, Seed: 42})
+	for _, v := range vectors[:10000] {
+		index.Add(v)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for _, v := range vectors[10000:] {
+		index.Add(v)
+	}
+}
+
+// BenchmarkSearch_EfUpperLayers measures the recall/latency trade-off of
+// widening the upper-layer beam on clustered data, where the greedy descent
+// can settle in the wrong cluster
+func BenchmarkSearch_EfUpperLayers(b *testing.B) {
+	const (
+		n      = 20000
+		dim    = 64
+		k      = 10
+		efBase = 20
+	)
+	vectors := generateClusteredVectors(n, dim, 50, 0.1, 42)
+	queries := generateClusteredVectors(200, dim, 50, 0.1, 43)
+
+	index := NewHNSW(Config{Dimension: dim, M: 8, EfConstruction: 100})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	groundTruth := computeGroundTruthParallel(index, queries, k)
+
+	for _, efUpper := range []int{1, 4, 16} {
+		params := SearchParams{EfUpperLayers: efUpper, EfBase: efBase}
+		b.Run(fmt.Sprintf("EfUpperLayers=%d", efUpper), func(b *testing.B) {
+			var recall float64
+			for i, q := range queries {
+				results, _ := index.SearchWithParams(q, k, params)
+				recall += calculateRecall2(groundTruth[i], results)
+			}
+			recall /= float64(len(queries))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index.SearchWithParams(queries[i%len(queries)], k, params)
+			}
+			b.ReportMetric(recall, "recall@10")
+		})
+	}
+}
+
+// BenchmarkSearch_Cosine_D768 measures a search of 10K cosine vectors with
+// the stored vectors normalized, against a custom wrapper of CosineDistance
+// that the index cannot recognize and so compares with the norms
+// recomputed every time
+func BenchmarkSearch_Cosine_D768(b *testing.B) {
+	const (
+		n   = 10000
+		dim = 768
+		k   = 10
+		ef  = 100
+	)
+	vectors := generateRandomVectors(n, dim, 42)
+	queries := generateRandomVectors(200, dim, 43)
+
+	for _, tc := range []struct {
+		name string
+		dist DistanceFunc
+	}{
+		{"Normalized", CosineDistance},
+		{"Recomputed", func(a, b []float32) float32 { return CosineDistance(a, b) }},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			config := Config{Dimension: dim, M: 16, EfConstruction: 100, DistanceFunc: tc.dist, Seed: 42}
+			index, err := BuildBulk(context.Background(), vectors, config, BulkOptions{})
+			if err != nil {
+				b.Fatalf("BuildBulk failed: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				index.Search(queries[i%len(queries)], k, ef)
+			}
+		})
+	}
+}
+
+// BenchmarkBuildBulk_500K_D128 compares BuildBulk with incremental Add on
+// build time and recall. BuildBulk should be several times faster on
+// multi-core machines with recall within 1-2%.
+func BenchmarkBuildBulk_500K_D128(b *testing.B) {
+	if testing.Short() {
+		b.Skip("Skipping large benchmark in short mode")
+	}
+
+	const (
+		n   = 500000
+		dim = 128
+		k   = 10
+	)
+	vectors := generateRandomVectors(n, dim, 42)
+	queries := generateRandomVectors(200, dim, 43)
+	config := Config{Dimension: dim, M: 16, EfConstruction: 200, Seed: 42}
+
+	var groundTruth [][]SearchResult
+	measure := func(b *testing.B, build func() *HNSWIndex) {
+		var index *HNSWIndex
+		for i := 0; i < b.N; i++ {
+			index = build()
+		}
+		b.StopTimer()
+
+		if groundTruth == nil {
+			groundTruth = computeGroundTruthParallel(index, queries, k)
+		}
+		var recall float64
+		for i, q := range queries {
+			results, _ := index.Search(q, k, 100)
+			recall += calculateRecall2(groundTruth[i], results)
+		}
+		b.ReportMetric(recall/float64(len(queries)), "recall@10")
+	}
+
+	b.Run("Incremental", func(b *testing.B) {
+		measure(b, func() *HNSWIndex {
+			index := NewHNSW(config)
+			for _, v := range vectors {
+				index.Add(v)
+			}
+			return index
+		})
+	})
+	b.Run("Bulk", func(b *testing.B) {
+		measure(b, func() *HNSWIndex {
+			index, err := BuildBulk(context.Background(), vectors, config, BulkOptions{})
+			if err != nil {
+				b.Fatalf("BuildBulk failed: %v", err)
+			}
+			return index
+		})
+	})
+}
+
+// syntheticGraph returns an index of n random nodes with random levels and
+// full random neighbor lists, for benchmarks of the storage layer that
+// need a large graph without the cost of building one
+func syntheticGraph(n, dim, m int, seed int64) *HNSWIndex {
+	index := NewHNSW(Config{Dimension: dim, M: m, Seed: seed})
+	rng := rand.New(rand.NewSource(seed))
+	index.nodes = make([]*Node, n)
+	for i := range index.nodes {
+		vector := make([]float32, dim)
+		for j := range vector {
+			vector[j] = rng.Float32()
+		}
+		level := int(-math.Log(1-rng.Float64()) * index.ml)
+		index.vectors.set(i, vector)
+		node := newNode(i, level, index.vectors)
+		for layer := 0; layer <= level; layer++ {
+			neighbors := make([]int, m)
+			if layer == 0 {
+				neighbors = make([]int, 2*m)
+			}
+			for k := range neighbors {
+				neighbors[k] = rng.Intn(n)
+			}
+			node.SetConnections(layer, neighbors)
+		}
+		index.nodes[i] = node
+		if int32(level) > index.maxLevel {
+			index.maxLevel = int32(level)
+			index.entryPoint = int32(i)
+		}
+	}
+	return index
+}
+
+// BenchmarkLoadHNSWFromLance_1M loads a 1M-node index with increasing
+// numbers of workers. Load time should fall near-linearly up to 8 workers
+// on a machine with at least as many cores; every load is checked against
+// the serial one.
+func BenchmarkLoadHNSWFromLance_1M(b *testing.B) {
+	if testing.Short() {
+		b.Skip("Skipping large benchmark in short mode")
+	}
+
+	dir := b.TempDir()
+	if err := syntheticGraph(1000000, 32, 16, 42).SaveToLance(dir); err != nil {
+		b.Fatalf("SaveToLance failed: %v", err)
+	}
+
+	var serialHash string
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			var loaded *HNSWIndex
+			for i := 0; i < b.N; i++ {
+				if loaded != nil {
+					loaded.Close()
+				}
+				var err error
+				loaded, err = LoadHNSWFromLance(dir, WithLoadWorkers(workers))
+				if err != nil {
+					b.Fatalf("LoadHNSWFromLance failed: %v", err)
+				}
+			}
+			b.StopTimer()
+			defer loaded.Close()
+
+			hash := loaded.GraphHash()
+			if serialHash == "" {
+				serialHash = hash
+			} else if hash != serialHash {
+				b.Fatalf("load with %d workers differs from the serial load", workers)
+			}
+		})
+	}
+}
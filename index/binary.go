@@ -0,0 +1,161 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+)
+
+// A binary index (Config.BinaryVectors) stores vectors of Dimension bits,
+// passed as Dimension/64 uint64 words, and compares them by Hamming
+// distance. Internally each word is kept as two float32 slots holding its
+// low and high 32 bits, so graph traversal, storage in the arena and
+// search run unchanged; those floats are bit patterns that are only copied
+// and compared through hammingPacked, never computed with.
+
+const (
+	// binaryFileName stores the vectors of a binary index
+	binaryFileName = "vectors.bin"
+	binaryMagic    = 0x4E494256 // "VBIN"
+	binaryVersion  = 1
+)
+
+// HammingDistance returns the number of bits that differ between a and b.
+func HammingDistance(a, b []uint64) int {
+	if len(a) != len(b) {
+		panic("vector dimensions mismatch")
+	}
+	var n int
+	for i := range a {
+		n += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return n
+}
+
+// hammingPacked is HammingDistance between two packed binary vectors
+func hammingPacked(a, b []float32) float32 {
+	b = b[:len(a)]
+	var n int
+	for i := range a {
+		n += bits.OnesCount32(math.Float32bits(a[i]) ^ math.Float32bits(b[i]))
+	}
+	return float32(n)
+}
+
+// packBinary returns words as the float32 slots a binary index stores them in
+func packBinary(words []uint64) []float32 {
+	packed := make([]float32, 2*len(words))
+	for i, w := range words {
+		packed[2*i] = math.Float32frombits(uint32(w))
+		packed[2*i+1] = math.Float32frombits(uint32(w >> 32))
+	}
+	return packed
+}
+
+// unpackBinary returns the words of a packed binary vector
+func unpackBinary(packed []float32) []uint64 {
+	words := make([]uint64, len(packed)/2)
+	for i := range words {
+		words[i] = uint64(math.Float32bits(packed[2*i])) | uint64(math.Float32bits(packed[2*i+1]))<<32
+	}
+	return words
+}
+
+// checkVector returns the error of passing a float32 vector of length n to
+// the index
+func (h *HNSWIndex) checkVector(n int) error {
+	if h.binary {
+		return ErrVectorType
+	}
+	if n != h.dimension {
+		return ErrDimensionMismatch
+	}
+	return nil
+}
+
+// checkBinary returns the error of passing a binary vector of n words to
+// the index
+func (h *HNSWIndex) checkBinary(n int) error {
+	if !h.binary {
+		return ErrVectorType
+	}
+	if 2*n != h.dimension {
+		return ErrDimensionMismatch
+	}
+	return nil
+}
+
+// AddBinary inserts a vector into a binary index and returns its assigned
+// node ID. vector holds Dimension bits in Dimension/64 words, and is copied.
+func (h *HNSWIndex) AddBinary(vector []uint64) (int, error) {
+	if err := h.checkBinary(len(vector)); err != nil {
+		return -1, err
+	}
+	return h.add(packBinary(vector), nil)
+}
+
+// SearchBinary is Search for a binary index. Distances are the number of
+// differing bits.
+func (h *HNSWIndex) SearchBinary(query []uint64, k int, ef int) ([]SearchResult, error) {
+	if err := h.checkBinary(len(query)); err != nil {
+		return nil, err
+	}
+	return h.searchWithParams(packBinary(query), k, SearchParams{EfBase: ef})
+}
+
+// BinaryVector returns a copy of the vector stored for node id of a binary
+// index.
+func (h *HNSWIndex) BinaryVector(id int) ([]uint64, error) {
+	if !h.binary {
+		return nil, ErrVectorType
+	}
+	view := h.snapshot()
+	if id < 0 || id >= len(view.nodes) || view.nodes[id].deleted.Load() {
+		return nil, ErrNodeNotFound
+	}
+	return unpackBinary(h.vectors.at(id)), nil
+}
+
+// saveBinaryVectors writes the vectors of a binary index to vectors.bin as
+// little-endian words; deleted nodes get zero records
+func (h *HNSWIndex) saveBinaryVectors(path string) error {
+	return writeRecordFile(path, binaryMagic, binaryVersion, len(h.nodes), 32*h.dimension, 4*h.dimension, func(id int, rec []byte) {
+		if h.nodes[id].deleted.Load() {
+			return
+		}
+		for i, x := range h.vectors.at(id) {
+			binary.LittleEndian.PutUint32(rec[4*i:], math.Float32bits(x))
+		}
+	})
+}
+
+// loadBinaryVectors reads the vectors of numNodes nodes from vectors.bin
+func (h *HNSWIndex) loadBinaryVectors(path string, numNodes int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	recordSize := 4 * h.dimension
+	if err := checkRecordFile(f, binaryFileName, binaryMagic, binaryVersion, numNodes, 32*h.dimension, recordSize); err != nil {
+		return err
+	}
+
+	h.vectors.reserve(numNodes)
+	r := bufio.NewReaderSize(io.NewSectionReader(f, recordFileHeaderSize, int64(recordSize*numNodes)), 1<<20)
+	rec := make([]byte, recordSize)
+	for id := 0; id < numNodes; id++ {
+		if _, err := io.ReadFull(r, rec); err != nil {
+			return fmt.Errorf("read vector of node %d: %w", id, err)
+		}
+		vector := h.vectors.at(id)
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(rec[4*i:]))
+		}
+	}
+	return nil
+}
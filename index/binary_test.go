@@ -0,0 +1,215 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// naiveHamming counts differing bits one at a time
+func naiveHamming(a, b []uint64) int {
+	var n int
+	for i := range a {
+		for bit := 0; bit < 64; bit++ {
+			if (a[i]>>bit)&1 != (b[i]>>bit)&1 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// clusteredBinaryVectors returns n vectors of words*64 bits around the given
+// number of random centers, each bit flipped with probability flip
+func clusteredBinaryVectors(n, words, clusters int, flip float64, seed int64) [][]uint64 {
+	rng := rand.New(rand.NewSource(seed))
+	centers := make([][]uint64, clusters)
+	for i := range centers {
+		centers[i] = make([]uint64, words)
+		for j := range centers[i] {
+			centers[i][j] = rng.Uint64()
+		}
+	}
+	vectors := make([][]uint64, n)
+	for i := range vectors {
+		vectors[i] = append([]uint64(nil), centers[rng.Intn(clusters)]...)
+		for bit := 0; bit < 64*words; bit++ {
+			if rng.Float64() < flip {
+				vectors[i][bit/64] ^= 1 << (bit % 64)
+			}
+		}
+	}
+	return vectors
+}
+
+func bruteForceHamming(query []uint64, vectors [][]uint64, k int) []SearchResult {
+	results := make([]SearchResult, len(vectors))
+	for i, v := range vectors {
+		results[i] = SearchResult{ID: i, Distance: float32(naiveHamming(query, v))}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	return results[:k]
+}
+
+func TestHammingDistance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, words := range []int{1, 2, 4, 7} {
+		for trial := 0; trial < 50; trial++ {
+			a, b := make([]uint64, words), make([]uint64, words)
+			for i := range a {
+				a[i], b[i] = rng.Uint64(), rng.Uint64()
+			}
+			if trial == 0 {
+				copy(b, a)
+				b[words-1] ^= 1 << 63
+			}
+			want := naiveHamming(a, b)
+			if got := HammingDistance(a, b); got != want {
+				t.Fatalf("HammingDistance = %d, want %d", got, want)
+			}
+			if got := hammingPacked(packBinary(a), packBinary(b)); got != float32(want) {
+				t.Fatalf("hammingPacked = %v, want %d", got, want)
+			}
+			if got := unpackBinary(packBinary(a)); fmt.Sprint(got) != fmt.Sprint(a) {
+				t.Fatalf("unpackBinary(packBinary(%v)) = %v", a, got)
+			}
+		}
+	}
+}
+
+func TestBinaryVectorsRecall(t *testing.T) {
+	const n, words, k = 5000, 4, 10
+	all := clusteredBinaryVectors(n+100, words, 50, 0.1, 2)
+	vectors, queries := all[:n], all[n:]
+
+	index := NewHNSW(Config{Dimension: 64 * words, M: 16, EfConstruction: 100, Seed: 2, BinaryVectors: true})
+	for _, v := range vectors {
+		if _, err := index.AddBinary(v); err != nil {
+			t.Fatalf("AddBinary failed: %v", err)
+		}
+	}
+	if _, _, _, metric := index.Params(); metric != "hamming" {
+		t.Errorf("metric %q, want hamming", metric)
+	}
+
+	var recall float64
+	for _, q := range queries {
+		results, err := index.SearchBinary(q, k, 100)
+		if err != nil {
+			t.Fatalf("SearchBinary failed: %v", err)
+		}
+		truth := bruteForceHamming(q, vectors, k)
+		for i, r := range results {
+			if want := naiveHamming(q, vectors[r.ID]); r.Distance != float32(want) {
+				t.Fatalf("distance to %d = %v, want %d", r.ID, r.Distance, want)
+			}
+			if i > 0 && r.Distance < results[i-1].Distance {
+				t.Fatalf("results not sorted: %v", results)
+			}
+		}
+		// Ties at the k-th distance make any of them a correct answer
+		hits := 0
+		for _, r := range results {
+			if r.Distance <= truth[k-1].Distance {
+				hits++
+			}
+		}
+		recall += float64(hits) / k
+	}
+	recall /= float64(len(queries))
+	if recall < 0.95 {
+		t.Errorf("recall@%d = %.3f, want >= 0.95", k, recall)
+	}
+
+	got, err := index.BinaryVector(3)
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(vectors[3]) {
+		t.Errorf("BinaryVector(3) = %v, %v, want %v", got, err, vectors[3])
+	}
+}
+
+func TestBinaryVectorsSaveLoad(t *testing.T) {
+	const words = 4
+	vectors := clusteredBinaryVectors(300, words, 10, 0.1, 3)
+	index := NewHNSW(Config{Dimension: 64 * words, M: 8, EfConstruction: 64, Seed: 3, BinaryVectors: true})
+	for _, v := range vectors {
+		index.AddBinary(v)
+	}
+	index.Delete(5)
+
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	loaded, err := LoadHNSWFromLance(dir)
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	if !loaded.binary || loaded.GraphHash() != index.GraphHash() {
+		t.Fatal("loaded index differs")
+	}
+	for id, v := range vectors {
+		got, err := loaded.BinaryVector(id)
+		if id == 5 {
+			if !errors.Is(err, ErrNodeNotFound) {
+				t.Errorf("deleted node: %v", err)
+			}
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(v) {
+			t.Fatalf("vector %d loaded as %v, want %v", id, got, v)
+		}
+	}
+	// Integer distances tie often, and ties come out in traversal order
+	byDistanceAndID := func(results []SearchResult) string {
+		sort.Slice(results, func(i, j int) bool {
+			a, b := results[i], results[j]
+			return a.Distance < b.Distance || a.Distance == b.Distance && a.ID < b.ID
+		})
+		return fmt.Sprint(results)
+	}
+	for _, q := range vectors[:20] {
+		want, _ := index.SearchBinary(q, 10, 50)
+		got, _ := loaded.SearchBinary(q, 10, 50)
+		if byDistanceAndID(got) != byDistanceAndID(want) {
+			t.Fatalf("loaded results %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBinaryVectorsErrors(t *testing.T) {
+	binary := NewHNSW(Config{Dimension: 128, Seed: 1, BinaryVectors: true})
+	float := NewHNSW(Config{Dimension: 4, Seed: 1})
+	float.Add([]float32{1, 2, 3, 4})
+	binary.AddBinary([]uint64{1, 2})
+
+	if _, err := binary.Add(make([]float32, 4)); !errors.Is(err, ErrVectorType) {
+		t.Errorf("Add to a binary index: %v", err)
+	}
+	if _, err := binary.Search(make([]float32, 4), 1, 0); !errors.Is(err, ErrVectorType) {
+		t.Errorf("Search of a binary index: %v", err)
+	}
+	if _, err := binary.Vector(0); !errors.Is(err, ErrVectorType) {
+		t.Errorf("Vector of a binary index: %v", err)
+	}
+	if _, err := float.AddBinary([]uint64{1}); !errors.Is(err, ErrVectorType) {
+		t.Errorf("AddBinary to a float index: %v", err)
+	}
+	if _, err := float.SearchBinary([]uint64{1}, 1, 0); !errors.Is(err, ErrVectorType) {
+		t.Errorf("SearchBinary of a float index: %v", err)
+	}
+	if _, err := binary.AddBinary([]uint64{1}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("short binary vector: %v", err)
+	}
+
+	for _, c := range []Config{
+		{Dimension: 100, BinaryVectors: true},
+		{Dimension: 128, BinaryVectors: true, Quantization: ScalarQuant8},
+		{Dimension: 128, BinaryVectors: true, DistanceFunc: L2Distance},
+	} {
+		if err := c.Validate(); !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("Validate(%+v) = %v", c, err)
+		}
+	}
+}
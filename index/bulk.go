@@ -0,0 +1,479 @@
+package hnsw
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// BulkOptions tunes BuildBulk. The zero value selects the defaults.
+type BulkOptions struct {
+	Workers int // Parallel workers, default runtime.GOMAXPROCS(0)
+
+	// K is the size of the approximate k-NN lists built for every layer
+	// before pruning, default 2*M. Larger values raise graph quality and
+	// build time roughly quadratically.
+	K int
+
+	// Iterations caps the NN-descent rounds per layer, default 12. Descent
+	// stops earlier once fewer than Delta*n*K neighbor lists improve.
+	Iterations int
+	Delta      float64 // Convergence threshold, default 0.001
+
+	// SampleRate is the fraction of each k-NN list joined per round,
+	// default 0.5
+	SampleRate float64
+
+	// ExactThreshold is the layer size up to which neighbors are found by
+	// exhaustive search instead of NN-descent, default 2000
+	ExactThreshold int
+
+	// RefinePasses re-links every node from an efConstruction search of the
+	// built graph, as incremental insertion would, default 1. Negative
+	// disables refinement.
+	RefinePasses int
+}
+
+func (o BulkOptions) withDefaults(m int) BulkOptions {
+	if o.Workers <= 0 {
+		o.Workers = runtime.GOMAXPROCS(0)
+	}
+	if o.K <= 0 {
+		o.K = 2 * m
+	}
+	if o.Iterations <= 0 {
+		o.Iterations = 12
+	}
+	if o.Delta <= 0 {
+		o.Delta = 0.001
+	}
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		o.SampleRate = 0.5
+	}
+	if o.ExactThreshold <= 0 {
+		o.ExactThreshold = 2000
+	}
+	if o.RefinePasses == 0 {
+		o.RefinePasses = 1
+	}
+	return o
+}
+
+// BuildBulk builds an index over a static set of vectors in one pass. Node
+// IDs are the positions in vectors, as if each had been added with Add in
+// order. Levels are assigned up front; every layer's neighborhoods are then
+// found in parallel with NN-descent (exhaustive search for small layers),
+// pruned with the same heuristic as Add, and refined by searching the built
+// graph. Recall is close to incremental construction while most of the work
+// runs on all cores.
+//
+// Cancelling ctx aborts the build and returns ctx.Err().
+func BuildBulk(ctx context.Context, vectors [][]float32, cfg Config, opts BulkOptions) (*HNSWIndex, error) {
+	h, err := NewHNSWChecked(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults(h.M)
+
+	nodes := make([]*Node, len(vectors))
+	for i, v := range vectors {
+		if err := h.checkVector(len(v)); err != nil {
+			return nil, err
+		}
+		h.storeVector(i, v)
+		nodes[i] = newNode(i, h.randomLevel(), h.nodeArena())
+	}
+	if len(nodes) == 0 {
+		return h, nil
+	}
+
+	// The first node of the highest level is the entry point, as it would
+	// be after incremental insertion
+	entryPoint, maxLevel := 0, nodes[0].level
+	for i, n := range nodes {
+		if n.level > maxLevel {
+			entryPoint, maxLevel = i, n.level
+		}
+	}
+
+	// Build layers bottom-up; layer lc holds every node with level >= lc
+	members := make([]int, len(nodes))
+	for i := range members {
+		members[i] = i
+	}
+	for lc := 0; lc <= maxLevel; lc++ {
+		if lc > 0 {
+			kept := members[:0:0]
+			for _, id := range members {
+				if nodes[id].level >= lc {
+					kept = append(kept, id)
+				}
+			}
+			members = kept
+		}
+
+		maxConn := h.Mmax
+		if lc == 0 {
+			maxConn = h.Mmax0
+		}
+		if err := h.buildLayer(ctx, nodes, members, lc, maxConn, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	h.globalLock.Lock()
+	h.nodes = nodes
+	h.entryPoint = int32(entryPoint)
+	h.maxLevel = int32(maxLevel)
+	h.publish()
+	h.globalLock.Unlock()
+
+	for pass := 0; pass < opts.RefinePasses; pass++ {
+		if err := h.refine(ctx, opts.Workers); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// buildLayer links members at level lc from their approximate k-NN lists
+func (h *HNSWIndex) buildLayer(ctx context.Context, nodes []*Node, members []int, lc, maxConn int, opts BulkOptions) error {
+	if len(members) == 1 {
+		nodes[members[0]].SetConnections(lc, []int{})
+		return nil
+	}
+
+	k := min(opts.K, len(members)-1)
+	var knn []*knnList
+	var err error
+	if len(members) <= opts.ExactThreshold {
+		knn, err = h.exactKNN(ctx, nodes, members, k, opts.Workers)
+	} else {
+		knn, err = h.nnDescent(ctx, nodes, members, k, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Candidates of a node are its k-NN list plus the nodes that list it,
+	// the edges incremental insertion would have added in both directions
+	reverse := make([][]SearchResult, len(members))
+	for i, list := range knn {
+		for _, e := range list.entries {
+			reverse[e.idx] = append(reverse[e.idx], SearchResult{ID: i, Distance: e.dist})
+		}
+	}
+
+	return parallelFor(ctx, len(members), opts.Workers, func(i int) {
+		seen := make(map[int]bool, len(knn[i].entries)+len(reverse[i]))
+		candidates := make([]SearchResult, 0, len(knn[i].entries)+len(reverse[i]))
+		for _, e := range knn[i].entries {
+			seen[e.idx] = true
+			candidates = append(candidates, SearchResult{ID: members[e.idx], Distance: e.dist})
+		}
+		for _, r := range reverse[i] {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				candidates = append(candidates, SearchResult{ID: members[r.ID], Distance: r.Distance})
+			}
+		}
+
+		node := nodes[members[i]]
+		selected := h.selectNeighborsHeuristic(nodes, h.vectorOf(node.id), candidates, maxConn)
+		ids := make([]int, len(selected))
+		for j, s := range selected {
+			ids[j] = s.ID
+		}
+		node.SetConnections(lc, ids)
+	})
+}
+
+// refine re-links every node as incremental insertion would: candidates
+// come from an efConstruction search of the current graph at each of the
+// node's levels, merged with its existing neighbors and pruned. New lists are
+// computed from the unmodified graph and installed together.
+func (h *HNSWIndex) refine(ctx context.Context, workers int) error {
+	view := h.snapshot()
+	nodes := view.nodes
+	updated := make([][][]int, len(nodes))
+
+	err := parallelFor(ctx, len(nodes), workers, func(id int) {
+		node := nodes[id]
+		vector := h.vectorOf(id)
+		lists := make([][]int, node.level+1)
+
+		currentNearest := int(view.entryPoint)
+		for lc := int(view.maxLevel); lc > node.level; lc-- {
+			if nearest := h.searchLayer(nodes, vector, currentNearest, 1, lc); len(nearest) > 0 {
+				currentNearest = nearest[0].ID
+			}
+		}
+		for lc := node.level; lc >= 0; lc-- {
+			found := h.searchLayer(nodes, vector, currentNearest, h.efConstruction, lc)
+
+			seen := make(map[int]bool, len(found)+h.Mmax0)
+			candidates := make([]SearchResult, 0, len(found)+h.Mmax0)
+			for _, r := range found {
+				if r.ID != id && !seen[r.ID] {
+					seen[r.ID] = true
+					candidates = append(candidates, r)
+				}
+			}
+			for _, nb := range node.neighbors(lc) {
+				if !seen[nb] {
+					seen[nb] = true
+					candidates = append(candidates, SearchResult{ID: nb, Distance: h.distTo(vector, nb)})
+				}
+			}
+
+			m := h.Mmax
+			if lc == 0 {
+				m = h.Mmax0
+			}
+			selected := h.selectNeighborsHeuristic(nodes, vector, candidates, m)
+			ids := make([]int, len(selected))
+			for j, s := range selected {
+				ids[j] = s.ID
+			}
+			lists[lc] = ids
+
+			if len(found) > 0 {
+				currentNearest = found[0].ID
+			}
+		}
+		updated[id] = lists
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keep the reverse edges refinement dropped, up to each list's capacity,
+	// so nodes stay reachable from the neighbors that chose them
+	for id, lists := range updated {
+		for lc, ids := range lists {
+			for _, nb := range ids {
+				maxConn := h.Mmax
+				if lc == 0 {
+					maxConn = h.Mmax0
+				}
+				if lc < len(updated[nb]) && len(updated[nb][lc]) < maxConn && !containsInt(updated[nb][lc], id) {
+					updated[nb][lc] = append(updated[nb][lc], id)
+				}
+			}
+		}
+	}
+	for id, lists := range updated {
+		for lc, ids := range lists {
+			nodes[id].SetConnections(lc, ids)
+		}
+	}
+	return nil
+}
+
+// knnEntry is a neighbor in a k-NN list; idx is a position in the layer's
+// member list
+type knnEntry struct {
+	idx   int
+	dist  float32
+	isNew bool
+}
+
+// knnList is a bounded list of nearest neighbors sorted by distance
+type knnList struct {
+	mu      sync.Mutex
+	entries []knnEntry
+	k       int
+}
+
+// insert adds idx if it is closer than the current k-th neighbor and not
+// already present, and reports whether the list changed
+func (l *knnList) insert(idx int, dist float32) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) >= l.k && dist >= l.entries[len(l.entries)-1].dist {
+		return false
+	}
+	for _, e := range l.entries {
+		if e.idx == idx {
+			return false
+		}
+	}
+
+	pos := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].dist > dist })
+	if len(l.entries) < l.k {
+		l.entries = append(l.entries, knnEntry{})
+	}
+	copy(l.entries[pos+1:], l.entries[pos:len(l.entries)-1])
+	l.entries[pos] = knnEntry{idx: idx, dist: dist, isNew: true}
+	return true
+}
+
+// exactKNN finds the k nearest members of every member exhaustively
+func (h *HNSWIndex) exactKNN(ctx context.Context, nodes []*Node, members []int, k, workers int) ([]*knnList, error) {
+	knn := make([]*knnList, len(members))
+	err := parallelFor(ctx, len(members), workers, func(i int) {
+		list := &knnList{k: k, entries: make([]knnEntry, 0, k)}
+		v := h.vectorOf(members[i])
+		for j, other := range members {
+			if j != i {
+				list.insert(j, h.distTo(v, other))
+			}
+		}
+		knn[i] = list
+	})
+	return knn, err
+}
+
+// nnDescent approximates the k-NN lists of members by NN-descent: starting
+// from random lists, each round compares the neighbors of every node with
+// each other and keeps any closer pair, since a neighbor of a neighbor is
+// likely a neighbor.
+func (h *HNSWIndex) nnDescent(ctx context.Context, nodes []*Node, members []int, k int, opts BulkOptions) ([]*knnList, error) {
+	n := len(members)
+	knn := make([]*knnList, n)
+	err := parallelFor(ctx, n, opts.Workers, func(i int) {
+		rng := rand.New(rand.NewSource(int64(i)))
+		list := &knnList{k: k, entries: make([]knnEntry, 0, k)}
+		v := h.vectorOf(members[i])
+		for len(list.entries) < k {
+			j := rng.Intn(n)
+			if j != i {
+				list.insert(j, h.distTo(v, members[j]))
+			}
+		}
+		knn[i] = list
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sample := max(1, int(float64(k)*opts.SampleRate))
+	for iter := 0; iter < opts.Iterations; iter++ {
+		// Split each list into sampled new entries (marked old from now on)
+		// and old entries, then add the reverse of both
+		newSets := make([][]int, n)
+		oldSets := make([][]int, n)
+		for i, list := range knn {
+			taken := 0
+			for j := range list.entries {
+				e := &list.entries[j]
+				if e.isNew {
+					if taken < sample {
+						newSets[i] = append(newSets[i], e.idx)
+						e.isNew = false
+						taken++
+					}
+				} else {
+					oldSets[i] = append(oldSets[i], e.idx)
+				}
+			}
+		}
+		newRev := make([][]int, n)
+		oldRev := make([][]int, n)
+		for i := range knn {
+			for _, j := range newSets[i] {
+				if len(newRev[j]) < sample {
+					newRev[j] = append(newRev[j], i)
+				}
+			}
+			for _, j := range oldSets[i] {
+				if len(oldRev[j]) < sample {
+					oldRev[j] = append(oldRev[j], i)
+				}
+			}
+		}
+
+		var updates atomic.Int64
+		err := parallelFor(ctx, n, opts.Workers, func(i int) {
+			newSet := appendUnique(newSets[i], newRev[i])
+			oldSet := appendUnique(oldSets[i], oldRev[i])
+
+			var changed int64
+			join := func(a, b int) {
+				if a == b {
+					return
+				}
+				d := h.distBetween(members[a], members[b])
+				if knn[a].insert(b, d) {
+					changed++
+				}
+				if knn[b].insert(a, d) {
+					changed++
+				}
+			}
+			for x, a := range newSet {
+				for _, b := range newSet[x+1:] {
+					join(a, b)
+				}
+				for _, b := range oldSet {
+					join(a, b)
+				}
+			}
+			updates.Add(changed)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if float64(updates.Load()) < opts.Delta*float64(n)*float64(k) {
+			break
+		}
+	}
+	return knn, nil
+}
+
+// parallelFor calls fn for every i in [0, n) on up to workers goroutines,
+// stopping early if ctx is cancelled
+func parallelFor(ctx context.Context, n, workers int, fn func(i int)) error {
+	if workers > n {
+		workers = n
+	}
+	const chunk = 256
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				start := int(next.Add(chunk)) - chunk
+				if start >= n {
+					return
+				}
+				for i := start; i < min(start+chunk, n); i++ {
+					fn(i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// appendUnique returns a with the elements of b it does not contain yet
+func appendUnique(a, b []int) []int {
+	if len(b) == 0 {
+		return a
+	}
+	out := append([]int(nil), a...)
+	for _, x := range b {
+		if !containsInt(out, x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func containsInt(s []int, x int) bool {
+	for _, v := range s {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBuildBulkRecall(t *testing.T) {
+	const (
+		n   = 4000
+		dim = 32
+		k   = 10
+	)
+	vectors := generateRandomVectors(n, dim, 1)
+	queries := generateRandomVectors(100, dim, 2)
+	config := Config{Dimension: dim, M: 16, EfConstruction: 100, Seed: 1}
+
+	incremental := NewHNSW(config)
+	for _, v := range vectors {
+		incremental.Add(v)
+	}
+
+	// A low exact threshold makes layer 0 go through NN-descent
+	bulk, err := BuildBulk(context.Background(), vectors, config, BulkOptions{ExactThreshold: 500})
+	if err != nil {
+		t.Fatalf("BuildBulk failed: %v", err)
+	}
+	if bulk.Len() != n {
+		t.Fatalf("Len = %d, want %d", bulk.Len(), n)
+	}
+	for _, id := range []int{0, n / 2, n - 1} {
+		v, err := bulk.Vector(id)
+		if err != nil {
+			t.Fatalf("Vector(%d) failed: %v", id, err)
+		}
+		if L2Distance(v, vectors[id]) != 0 {
+			t.Errorf("node %d does not hold vectors[%d]", id, id)
+		}
+	}
+
+	groundTruth := computeGroundTruthParallel(incremental, queries, k)
+	var incRecall, bulkRecall float64
+	for i, q := range queries {
+		a, _ := incremental.Search(q, k, 20)
+		b, _ := bulk.Search(q, k, 20)
+		incRecall += calculateRecall2(groundTruth[i], a)
+		bulkRecall += calculateRecall2(groundTruth[i], b)
+	}
+	incRecall /= float64(len(queries))
+	bulkRecall /= float64(len(queries))
+	t.Logf("Recall@%d: incremental %.4f, bulk %.4f", k, incRecall, bulkRecall)
+
+	if bulkRecall < incRecall-0.02 {
+		t.Errorf("bulk recall %.4f more than 2%% below incremental %.4f", bulkRecall, incRecall)
+	}
+}
+
+func TestBuildBulkSmall(t *testing.T) {
+	config := Config{Dimension: 4, Seed: 1}
+
+	empty, err := BuildBulk(context.Background(), nil, config, BulkOptions{})
+	if err != nil {
+		t.Fatalf("BuildBulk(nil) failed: %v", err)
+	}
+	if empty.Len() != 0 {
+		t.Errorf("Len = %d, want 0", empty.Len())
+	}
+
+	single, err := BuildBulk(context.Background(), [][]float32{{1, 2, 3, 4}}, config, BulkOptions{})
+	if err != nil {
+		t.Fatalf("BuildBulk(single) failed: %v", err)
+	}
+	results, err := single.Search([]float32{1, 2, 3, 4}, 1, 0)
+	if err != nil || len(results) != 1 || results[0].ID != 0 {
+		t.Errorf("Search = %v, %v; want node 0", results, err)
+	}
+}
+
+func TestBuildBulkErrors(t *testing.T) {
+	config := Config{Dimension: 4, Seed: 1}
+
+	_, err := BuildBulk(context.Background(), [][]float32{{1, 2, 3, 4}, {1, 2}}, config, BulkOptions{})
+	if !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("mismatched vector: err = %v, want ErrDimensionMismatch", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = BuildBulk(ctx, generateRandomVectors(100, 4, 1), config, BulkOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled build: err = %v, want context.Canceled", err)
+	}
+}
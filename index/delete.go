@@ -0,0 +1,124 @@
+package hnsw
+
+// Delete removes node id from the index. Its ID is not reused: the node
+// stays in the node table marked as deleted, so the IDs of the other nodes
+// do not change. Searches still traverse it, which keeps the graph
+// connected, but never return it; Vector reports ErrNodeNotFound for it and
+// Len no longer counts it.
+//
+// The neighbors the node linked to are repaired on every layer: each one
+// that linked back loses the link and re-selects its list from its other
+// neighbors and those of the deleted node. Lists that reference the node
+// without a link back are pruned by later inserts and Optimize. If the
+// node was the entry point, the live node of the highest level takes its
+// place, one still being inserted only if there is no other. SaveToLance
+// does not write deleted nodes.
+//
+// It returns ErrNodeNotFound if id is not in the index or already deleted.
+// Searches and inserts may run concurrently.
+func (h *HNSWIndex) Delete(id int) error {
+	h.globalLock.Lock()
+	if id < 0 || id >= len(h.nodes) || h.nodes[id].deleted.Load() {
+		h.globalLock.Unlock()
+		return ErrNodeNotFound
+	}
+	node := h.nodes[id]
+	node.deleted.Store(true)
+	node.changed.Store(true)
+	h.deleted++
+	if int(h.entryPoint) == id {
+		h.replaceEntryPoint()
+	}
+	h.publish()
+	h.globalLock.Unlock()
+
+	for lc := 0; lc <= node.level; lc++ {
+		former := node.neighbors(lc)
+		for _, nb := range former {
+			h.unlink(nb, id, lc, former)
+		}
+	}
+	return nil
+}
+
+// replaceEntryPoint makes the live node of the highest level the entry
+// point, preferring nodes already linked into the graph: one an insert is
+// still linking may have no neighbors yet, and searches starting there would
+// find nothing until the insert finishes. h.globalLock must be held.
+func (h *HNSWIndex) replaceEntryPoint() {
+	h.entryPoint, h.maxLevel = -1, -1
+	for _, linked := range []bool{true, false} {
+		for _, n := range h.nodes {
+			if !n.deleted.Load() && (!linked || !n.linking.Load()) && int32(n.level) > h.maxLevel {
+				h.entryPoint, h.maxLevel = int32(n.id), int32(n.level)
+			}
+		}
+		if h.entryPoint != -1 {
+			return
+		}
+	}
+}
+
+// unlink removes the link from node nb to the deleted node id at level and
+// re-selects nb's list from its remaining neighbors and former, the
+// neighbors of id
+func (h *HNSWIndex) unlink(nb, id, level int, former []int) {
+	nodes := h.snapshot().nodes
+	if nb < 0 || nb >= len(nodes) || nodes[nb].deleted.Load() {
+		return
+	}
+	node := nodes[nb]
+	vector := h.vectorOf(nb)
+	maxConn := h.Mmax
+	if level == 0 {
+		maxConn = h.Mmax0
+	}
+
+	node.updateConnections(level, func(conns []int) []int {
+		if !containsInt(conns, id) {
+			return conns
+		}
+
+		// The list may reference nodes inserted after our view was taken
+		latest := h.snapshot().nodes
+		seen := map[int]bool{nb: true}
+		candidates := make([]SearchResult, 0, len(conns)+len(former))
+		consider := func(c int) {
+			if c < 0 || c >= len(latest) || seen[c] || latest[c].deleted.Load() || latest[c].level < level {
+				return
+			}
+			seen[c] = true
+			candidates = append(candidates, SearchResult{ID: c, Distance: h.distTo(vector, c)})
+		}
+		for _, c := range conns {
+			consider(c)
+		}
+		for _, c := range former {
+			consider(c)
+		}
+
+		selected := h.selectNeighborsHeuristic(latest, vector, candidates, maxConn)
+		ids := make([]int, len(selected))
+		for i, s := range selected {
+			ids[i] = s.ID
+		}
+		return ids
+	})
+}
+
+// live returns the IDs of list that are in nodes and not deleted, list
+// itself if all of them are
+func live(nodes []*Node, list []int) []int {
+	for i, id := range list {
+		if id < 0 || id >= len(nodes) || nodes[id].deleted.Load() {
+			kept := append([]int(nil), list[:i]...)
+			for _, id := range list[i+1:] {
+				if id >= 0 && id < len(nodes) && !nodes[id].deleted.Load() {
+					kept = append(kept, id)
+				}
+			}
+			return kept
+		}
+	}
+	return list
+}
@@ -0,0 +1,200 @@
+package hnsw
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+// liveGroundTruth returns the k nearest of the vectors not deleted
+func liveGroundTruth(vectors [][]float32, deleted map[int]bool, query []float32, k int) []SearchResult {
+	var all []SearchResult
+	for id, v := range vectors {
+		if !deleted[id] {
+			all = append(all, SearchResult{ID: id, Distance: L2Distance(query, v)})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Distance < all[j].Distance })
+	return all[:min(k, len(all))]
+}
+
+func TestDelete(t *testing.T) {
+	const n, k = 2000, 10
+	vectors := generateRandomVectors(n, 16, 7)
+	index := NewHNSW(Config{Dimension: 16, M: 8, EfConstruction: 100, Seed: 7})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+
+	// Delete every third node and the entry point
+	deleted := map[int]bool{int(index.entryPoint): true}
+	for id := 0; id < n; id += 3 {
+		deleted[id] = true
+	}
+	for id := range deleted {
+		if err := index.Delete(id); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", id, err)
+		}
+	}
+	if index.Len() != n-len(deleted) || index.Deleted() != len(deleted) {
+		t.Fatalf("Len %d, Deleted %d after deleting %d of %d", index.Len(), index.Deleted(), len(deleted), n)
+	}
+	if deleted[int(index.entryPoint)] {
+		t.Fatalf("entry point %d is deleted", index.entryPoint)
+	}
+	if err := index.Delete(0); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("second Delete = %v, want ErrNodeNotFound", err)
+	}
+	if err := index.Delete(n); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Delete out of range = %v, want ErrNodeNotFound", err)
+	}
+	if _, err := index.Vector(0); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Vector of deleted node = %v, want ErrNodeNotFound", err)
+	}
+
+	// Former neighbors of a deleted node no longer link back to it
+	for id := range deleted {
+		node := index.nodes[id]
+		for level := 0; level <= node.level; level++ {
+			for _, nb := range node.neighbors(level) {
+				if !deleted[nb] && containsInt(index.nodes[nb].neighbors(level), id) {
+					t.Fatalf("node %d still links to deleted node %d on level %d", nb, id, level)
+				}
+			}
+		}
+	}
+
+	// Searches never return deleted nodes and still find the live ones
+	var recall float64
+	for q := 0; q < 100; q++ {
+		query := vectors[q*17%n]
+		results, err := index.Search(query, k, 50)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, r := range results {
+			if deleted[r.ID] {
+				t.Fatalf("Search returned deleted node %d", r.ID)
+			}
+		}
+		recall += calculateRecall2(liveGroundTruth(vectors, deleted, query, k), results)
+	}
+	if recall /= 100; recall < 0.9 {
+		t.Errorf("recall after deletes = %.3f", recall)
+	}
+
+	// Inserts after deletes link only to live nodes
+	added, _ := index.Add(vectors[0])
+	if results, _ := index.Search(vectors[0], 1, 50); len(results) == 0 || results[0].ID != added {
+		t.Errorf("re-added vector not found: %v", results)
+	}
+
+	// Deleted nodes are not saved; the others keep their IDs
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	loaded, err := LoadHNSWFromLance(dir)
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	defer loaded.Close()
+	if loaded.Len() != index.Len() || loaded.Deleted() != index.Deleted() {
+		t.Errorf("loaded Len %d, Deleted %d, want %d, %d", loaded.Len(), loaded.Deleted(), index.Len(), index.Deleted())
+	}
+	if loaded.GraphHash() != index.GraphHash() {
+		t.Error("graph hash changed across save and load")
+	}
+	if _, err := loaded.Vector(0); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Vector of deleted node after load = %v", err)
+	}
+	for id := 1; id < n; id += 3 {
+		if deleted[id] {
+			continue
+		}
+		v, err := loaded.Vector(id)
+		if err != nil || L2Distance(v, vectors[id]) != 0 {
+			t.Fatalf("node %d after load: %v, %v", id, v, err)
+		}
+	}
+}
+
+// TestDeleteEntryPointWhileLinking deletes the entry point while a node of
+// a higher level is published but not yet linked, as add leaves it between
+// publishing the node and inserting it
+func TestDeleteEntryPointWhileLinking(t *testing.T) {
+	vectors := generateRandomVectors(301, 16, 9)
+	index := NewHNSW(Config{Dimension: 16, M: 8, EfConstruction: 100, Seed: 9})
+	for _, v := range vectors[:300] {
+		index.Add(v)
+	}
+
+	index.globalLock.Lock()
+	id := len(index.nodes)
+	index.storeVector(id, vectors[300])
+	pending := newNode(id, int(index.maxLevel)+2, index.nodeArena())
+	pending.linking.Store(true)
+	index.nodes = append(index.nodes, pending)
+	index.publish()
+	index.globalLock.Unlock()
+
+	if err := index.Delete(int(index.entryPoint)); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if int(index.entryPoint) == id {
+		t.Fatal("the node being linked became the entry point")
+	}
+	results, err := index.Search(vectors[0], 10, 100)
+	if err != nil || len(results) != 10 {
+		t.Fatalf("Search after deleting the entry point = %d results, %v; want 10", len(results), err)
+	}
+
+	// Once linked, the node of the highest level takes over
+	index.insert(pending)
+	pending.linking.Store(false)
+	if int(index.entryPoint) != id || int(index.maxLevel) != pending.level {
+		t.Errorf("entry point %d at level %d, want the linked node %d at level %d", index.entryPoint, index.maxLevel, id, pending.level)
+	}
+	if results, err := index.Search(vectors[300], 1, 100); err != nil || len(results) != 1 || results[0].ID != id {
+		t.Errorf("Search for the linked node = %v, %v", results, err)
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	index := NewHNSW(Config{Dimension: 4, M: 4, Seed: 1})
+	for _, v := range generateRandomVectors(20, 4, 1) {
+		index.Add(v)
+	}
+	for id := 0; id < 20; id++ {
+		if err := index.Delete(id); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", id, err)
+		}
+	}
+	if index.Len() != 0 {
+		t.Fatalf("Len = %d after deleting every node", index.Len())
+	}
+	if _, err := index.Search([]float32{0, 0, 0, 0}, 5, 10); !errors.Is(err, ErrEmptyIndex) {
+		t.Errorf("Search of emptied index = %v, want ErrEmptyIndex", err)
+	}
+
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	loaded, err := LoadHNSWFromLance(dir)
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	defer loaded.Close()
+	if loaded.Len() != 0 || loaded.Deleted() != 20 {
+		t.Errorf("loaded Len %d, Deleted %d", loaded.Len(), loaded.Deleted())
+	}
+
+	// New nodes take fresh IDs
+	if id, _ := loaded.Add([]float32{1, 2, 3, 4}); id != 20 {
+		t.Errorf("Add after load = %d, want 20", id)
+	}
+	if results, _ := loaded.Search([]float32{1, 2, 3, 4}, 1, 10); len(results) != 1 || results[0].ID != 20 {
+		t.Errorf("Search after re-adding = %v", results)
+	}
+}
@@ -1,20 +1,72 @@
 package hnsw
 
-import "math"
+import (
+	"math"
+	"reflect"
+)
 
 type DistanceFunc func(a, b []float32) float32
 
+// distanceKernels is one implementation of the loops behind the built-in
+// distance functions. The built-ins check lengths, so kernels can assume
+// len(a) == len(b).
+type distanceKernels struct {
+	name   string
+	dot    func(a, b []float32) float32                     // Sum of a[i]*b[i]
+	l2     func(a, b []float32) float32                     // Sum of (a[i]-b[i])^2
+	cosine func(a, b []float32) (dot, normA, normB float32) // Dot product and squared norms
+}
+
+var scalarKernels = distanceKernels{
+	name:   "scalar",
+	dot:    dotScalar,
+	l2:     l2Scalar,
+	cosine: cosineScalar,
+}
+
+// kernels is the fastest implementation the CPU supports, chosen at init.
+// The built-in distance functions stay the same functions whichever it is,
+// so they keep comparing equal for distanceName.
+var kernels = scalarKernels
+
+func init() {
+	if supported := simdKernels(); len(supported) > 0 {
+		kernels = supported[0]
+	}
+}
+
+// DistanceKernel returns the implementation behind the built-in distance
+// functions on this CPU: "avx512", "avx2", "neon" or "scalar"
+func DistanceKernel() string {
+	return kernels.name
+}
+
+// distanceName returns the name of a built-in distance function, or "" for
+// a custom one
+func distanceName(fn DistanceFunc) string {
+	if fn == nil {
+		return "l2"
+	}
+	p := reflect.ValueOf(fn).Pointer()
+	for name, builtin := range map[string]DistanceFunc{
+		"l2":     L2Distance,
+		"l2sqrt": L2DistanceSqrt,
+		"ip":     InnerProductDistance,
+		"cosine": CosineDistance,
+	} {
+		if reflect.ValueOf(builtin).Pointer() == p {
+			return name
+		}
+	}
+	return ""
+}
+
 // L2Distance computes the L2 (Euclidean) distance between two vectors.
 func L2Distance(a, b []float32) float32 {
 	if len(a) != len(b) {
 		panic("vector dimensions mismatch")
 	}
-	var sum float32
-	for i := range a {
-		diff := a[i] - b[i]
-		sum += diff * diff
-	}
-	return sum // Note: returning squared distance for efficiency
+	return kernels.l2(a, b) // Note: returning squared distance for efficiency
 }
 
 // L2DistanceSqrt computes the square root of the L2 distance between two vectors.
@@ -30,13 +82,8 @@ func InnerProductDistance(a, b []float32) float32 {
 		panic("vector dimensions mismatch")
 	}
 
-	var sum float32
-	for i := range a {
-		sum += a[i] * b[i]
-	}
-
 	// We negate the inner product to convert it into a distance metric
-	return -sum
+	return -kernels.dot(a, b)
 }
 
 // CosineDistance computes the cosine distance between two vectors.
@@ -46,13 +93,7 @@ func CosineDistance(a, b []float32) float32 {
 		panic("vector dimensions mismatch")
 	}
 
-	var dotProduct, normA, normB float32
-
-	for i := range a {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
+	dotProduct, normA, normB := kernels.cosine(a, b)
 
 	if normA == 0 || normB == 0 {
 		return 1.0
@@ -62,3 +103,60 @@ func CosineDistance(a, b []float32) float32 {
 
 	return 1.0 - cosineSim
 }
+
+// normalizedCosineDistance is CosineDistance for vectors already scaled to
+// unit length. A zero vector stays zero and is at distance 1 from any
+// other, as with CosineDistance.
+func normalizedCosineDistance(a, b []float32) float32 {
+	return 1.0 - kernels.dot(a, b)
+}
+
+// unitTolerance is how far from 1 the norm of a vector normalize already
+// scaled can be, from float32 rounding
+const unitTolerance = 1e-5
+
+// normalize returns a copy of v scaled to unit length, or zero if v is.
+// Vectors of unit length up to rounding are copied unchanged, so that
+// normalizing twice gives the same vector.
+func normalize(v []float32) []float32 {
+	out := make([]float32, len(v))
+	norm := math.Sqrt(float64(kernels.dot(v, v)))
+	if norm == 0 {
+		return out
+	}
+	if math.Abs(norm-1) <= unitTolerance {
+		copy(out, v)
+		return out
+	}
+	inv := float32(1 / norm)
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
+
+func dotScalar(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func l2Scalar(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		diff := a[i] - b[i]
+		sum += diff * diff
+	}
+	return sum
+}
+
+func cosineScalar(a, b []float32) (dot, normA, normB float32) {
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	return dot, normA, normB
+}
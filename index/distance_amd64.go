@@ -0,0 +1,58 @@
+//go:build amd64 && !purego
+
+package hnsw
+
+// Implemented in distance_amd64.s. The AVX2 kernels also use FMA.
+func dotAVX2(a, b []float32) float32
+func l2AVX2(a, b []float32) float32
+func cosineAVX2(a, b []float32) (dot, normA, normB float32)
+func dotAVX512(a, b []float32) float32
+func l2AVX512(a, b []float32) float32
+func cosineAVX512(a, b []float32) (dot, normA, normB float32)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+func xgetbv() (eax, edx uint32)
+
+// simdKernels returns the kernels this CPU and OS support, fastest first
+func simdKernels() []distanceKernels {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return nil
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	_, ebx7, _, _ := cpuid(7, 0)
+	const (
+		fma     = 1 << 12 // CPUID.1:ECX
+		osxsave = 1 << 27 // CPUID.1:ECX
+		avx     = 1 << 28 // CPUID.1:ECX
+		avx2    = 1 << 5  // CPUID.7:EBX
+		avx512f = 1 << 16 // CPUID.7:EBX
+	)
+	if ecx1&osxsave == 0 {
+		return nil
+	}
+	// The OS must save the vector registers on context switches: XMM and
+	// YMM state for AVX, and the opmask and ZMM state for AVX-512
+	xcr0, _ := xgetbv()
+	ymm := xcr0&0x6 == 0x6
+	zmm := ymm && xcr0&0xe0 == 0xe0
+
+	var supported []distanceKernels
+	if zmm && ebx7&avx512f != 0 {
+		supported = append(supported, distanceKernels{
+			name:   "avx512",
+			dot:    dotAVX512,
+			l2:     l2AVX512,
+			cosine: cosineAVX512,
+		})
+	}
+	if ymm && ecx1&(fma|avx) == fma|avx && ebx7&avx2 != 0 {
+		supported = append(supported, distanceKernels{
+			name:   "avx2",
+			dot:    dotAVX2,
+			l2:     l2AVX2,
+			cosine: cosineAVX2,
+		})
+	}
+	return supported
+}
@@ -0,0 +1,418 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// HSUM leaves the sum of the eight lanes of ymm in the low lane of xmm,
+// the low half of ymm, using tmp as scratch
+#define HSUM(ymm, xmm, tmp) \
+	VEXTRACTF128 $1, ymm, tmp; \
+	VADDPS       tmp, xmm, xmm; \
+	VHADDPS      xmm, xmm, xmm; \
+	VHADDPS      xmm, xmm, xmm
+
+// ZSUM folds the sixteen lanes of zmm into the eight lanes of ymm, its low
+// half, using tmp as scratch
+#define ZSUM(zmm, ymm, tmp) \
+	VEXTRACTF64X4 $1, zmm, tmp; \
+	VADDPS        tmp, ymm, ymm
+
+// TAILMASK sets K1 to the low CX bits, selecting the last CX < 16 elements
+#define TAILMASK \
+	MOVL  $1, AX; \
+	SHLL  CX, AX; \
+	DECL  AX; \
+	KMOVW AX, K1
+
+// func dotAVX2(a, b []float32) float32
+TEXT ·dotAVX2(SB), NOSPLIT, $0-52
+	MOVQ   a_base+0(FP), SI
+	MOVQ   b_base+24(FP), DI
+	MOVQ   a_len+8(FP), CX
+	VXORPS Y0, Y0, Y0
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2
+	VXORPS Y3, Y3, Y3
+
+loop32:
+	CMPQ        CX, $32
+	JL          loop8
+	VMOVUPS     (SI), Y4
+	VMOVUPS     32(SI), Y5
+	VMOVUPS     64(SI), Y6
+	VMOVUPS     96(SI), Y7
+	VFMADD231PS (DI), Y4, Y0
+	VFMADD231PS 32(DI), Y5, Y1
+	VFMADD231PS 64(DI), Y6, Y2
+	VFMADD231PS 96(DI), Y7, Y3
+	ADDQ        $128, SI
+	ADDQ        $128, DI
+	SUBQ        $32, CX
+	JMP         loop32
+
+loop8:
+	CMPQ        CX, $8
+	JL          reduce
+	VMOVUPS     (SI), Y4
+	VFMADD231PS (DI), Y4, Y0
+	ADDQ        $32, SI
+	ADDQ        $32, DI
+	SUBQ        $8, CX
+	JMP         loop8
+
+reduce:
+	VADDPS Y1, Y0, Y0
+	VADDPS Y3, Y2, Y2
+	VADDPS Y2, Y0, Y0
+	HSUM(Y0, X0, X1)
+
+tail:
+	TESTQ       CX, CX
+	JZ          done
+	VMOVSS      (SI), X4
+	VFMADD231SS (DI), X4, X0
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSS X0, ret+48(FP)
+	RET
+
+// func l2AVX2(a, b []float32) float32
+TEXT ·l2AVX2(SB), NOSPLIT, $0-52
+	MOVQ   a_base+0(FP), SI
+	MOVQ   b_base+24(FP), DI
+	MOVQ   a_len+8(FP), CX
+	VXORPS Y0, Y0, Y0
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2
+	VXORPS Y3, Y3, Y3
+
+loop32:
+	CMPQ        CX, $32
+	JL          loop8
+	VMOVUPS     (SI), Y4
+	VMOVUPS     32(SI), Y5
+	VMOVUPS     64(SI), Y6
+	VMOVUPS     96(SI), Y7
+	VSUBPS      (DI), Y4, Y4
+	VSUBPS      32(DI), Y5, Y5
+	VSUBPS      64(DI), Y6, Y6
+	VSUBPS      96(DI), Y7, Y7
+	VFMADD231PS Y4, Y4, Y0
+	VFMADD231PS Y5, Y5, Y1
+	VFMADD231PS Y6, Y6, Y2
+	VFMADD231PS Y7, Y7, Y3
+	ADDQ        $128, SI
+	ADDQ        $128, DI
+	SUBQ        $32, CX
+	JMP         loop32
+
+loop8:
+	CMPQ        CX, $8
+	JL          reduce
+	VMOVUPS     (SI), Y4
+	VSUBPS      (DI), Y4, Y4
+	VFMADD231PS Y4, Y4, Y0
+	ADDQ        $32, SI
+	ADDQ        $32, DI
+	SUBQ        $8, CX
+	JMP         loop8
+
+reduce:
+	VADDPS Y1, Y0, Y0
+	VADDPS Y3, Y2, Y2
+	VADDPS Y2, Y0, Y0
+	HSUM(Y0, X0, X1)
+
+tail:
+	TESTQ       CX, CX
+	JZ          done
+	VMOVSS      (SI), X4
+	VSUBSS      (DI), X4, X4
+	VFMADD231SS X4, X4, X0
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSS X0, ret+48(FP)
+	RET
+
+// func cosineAVX2(a, b []float32) (dot, normA, normB float32)
+TEXT ·cosineAVX2(SB), NOSPLIT, $0-60
+	MOVQ   a_base+0(FP), SI
+	MOVQ   b_base+24(FP), DI
+	MOVQ   a_len+8(FP), CX
+	VXORPS Y0, Y0, Y0 // dot
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2 // normA
+	VXORPS Y3, Y3, Y3
+	VXORPS Y4, Y4, Y4 // normB
+	VXORPS Y5, Y5, Y5
+
+loop16:
+	CMPQ        CX, $16
+	JL          loop8
+	VMOVUPS     (SI), Y6
+	VMOVUPS     32(SI), Y7
+	VMOVUPS     (DI), Y8
+	VMOVUPS     32(DI), Y9
+	VFMADD231PS Y8, Y6, Y0
+	VFMADD231PS Y9, Y7, Y1
+	VFMADD231PS Y6, Y6, Y2
+	VFMADD231PS Y7, Y7, Y3
+	VFMADD231PS Y8, Y8, Y4
+	VFMADD231PS Y9, Y9, Y5
+	ADDQ        $64, SI
+	ADDQ        $64, DI
+	SUBQ        $16, CX
+	JMP         loop16
+
+loop8:
+	CMPQ        CX, $8
+	JL          reduce
+	VMOVUPS     (SI), Y6
+	VMOVUPS     (DI), Y8
+	VFMADD231PS Y8, Y6, Y0
+	VFMADD231PS Y6, Y6, Y2
+	VFMADD231PS Y8, Y8, Y4
+	ADDQ        $32, SI
+	ADDQ        $32, DI
+	SUBQ        $8, CX
+	JMP         loop8
+
+reduce:
+	VADDPS Y1, Y0, Y0
+	VADDPS Y3, Y2, Y2
+	VADDPS Y5, Y4, Y4
+	HSUM(Y0, X0, X1)
+	HSUM(Y2, X2, X3)
+	HSUM(Y4, X4, X5)
+
+tail:
+	TESTQ       CX, CX
+	JZ          done
+	VMOVSS      (SI), X6
+	VMOVSS      (DI), X8
+	VFMADD231SS X8, X6, X0
+	VFMADD231SS X6, X6, X2
+	VFMADD231SS X8, X8, X4
+	ADDQ        $4, SI
+	ADDQ        $4, DI
+	DECQ        CX
+	JMP         tail
+
+done:
+	VZEROUPPER
+	MOVSS X0, dot+48(FP)
+	MOVSS X2, normA+52(FP)
+	MOVSS X4, normB+56(FP)
+	RET
+
+// func dotAVX512(a, b []float32) float32
+TEXT ·dotAVX512(SB), NOSPLIT, $0-52
+	MOVQ   a_base+0(FP), SI
+	MOVQ   b_base+24(FP), DI
+	MOVQ   a_len+8(FP), CX
+	VXORPS Z0, Z0, Z0
+	VXORPS Z1, Z1, Z1
+	VXORPS Z2, Z2, Z2
+	VXORPS Z3, Z3, Z3
+
+loop64:
+	CMPQ        CX, $64
+	JL          loop16
+	VMOVUPS     (SI), Z4
+	VMOVUPS     64(SI), Z5
+	VMOVUPS     128(SI), Z6
+	VMOVUPS     192(SI), Z7
+	VFMADD231PS (DI), Z4, Z0
+	VFMADD231PS 64(DI), Z5, Z1
+	VFMADD231PS 128(DI), Z6, Z2
+	VFMADD231PS 192(DI), Z7, Z3
+	ADDQ        $256, SI
+	ADDQ        $256, DI
+	SUBQ        $64, CX
+	JMP         loop64
+
+loop16:
+	CMPQ        CX, $16
+	JL          tail
+	VMOVUPS     (SI), Z4
+	VFMADD231PS (DI), Z4, Z0
+	ADDQ        $64, SI
+	ADDQ        $64, DI
+	SUBQ        $16, CX
+	JMP         loop16
+
+tail:
+	// Masked loads read only the remaining elements, and zero the rest
+	TESTQ       CX, CX
+	JZ          reduce
+	TAILMASK
+	VMOVUPS.Z   (SI), K1, Z4
+	VMOVUPS.Z   (DI), K1, Z5
+	VFMADD231PS Z5, Z4, Z1
+
+reduce:
+	VADDPS Z1, Z0, Z0
+	VADDPS Z3, Z2, Z2
+	VADDPS Z2, Z0, Z0
+	ZSUM(Z0, Y0, Y1)
+	HSUM(Y0, X0, X1)
+	VZEROUPPER
+	MOVSS  X0, ret+48(FP)
+	RET
+
+// func l2AVX512(a, b []float32) float32
+TEXT ·l2AVX512(SB), NOSPLIT, $0-52
+	MOVQ   a_base+0(FP), SI
+	MOVQ   b_base+24(FP), DI
+	MOVQ   a_len+8(FP), CX
+	VXORPS Z0, Z0, Z0
+	VXORPS Z1, Z1, Z1
+	VXORPS Z2, Z2, Z2
+	VXORPS Z3, Z3, Z3
+
+loop64:
+	CMPQ        CX, $64
+	JL          loop16
+	VMOVUPS     (SI), Z4
+	VMOVUPS     64(SI), Z5
+	VMOVUPS     128(SI), Z6
+	VMOVUPS     192(SI), Z7
+	VSUBPS      (DI), Z4, Z4
+	VSUBPS      64(DI), Z5, Z5
+	VSUBPS      128(DI), Z6, Z6
+	VSUBPS      192(DI), Z7, Z7
+	VFMADD231PS Z4, Z4, Z0
+	VFMADD231PS Z5, Z5, Z1
+	VFMADD231PS Z6, Z6, Z2
+	VFMADD231PS Z7, Z7, Z3
+	ADDQ        $256, SI
+	ADDQ        $256, DI
+	SUBQ        $64, CX
+	JMP         loop64
+
+loop16:
+	CMPQ        CX, $16
+	JL          tail
+	VMOVUPS     (SI), Z4
+	VSUBPS      (DI), Z4, Z4
+	VFMADD231PS Z4, Z4, Z0
+	ADDQ        $64, SI
+	ADDQ        $64, DI
+	SUBQ        $16, CX
+	JMP         loop16
+
+tail:
+	TESTQ       CX, CX
+	JZ          reduce
+	TAILMASK
+	VMOVUPS.Z   (SI), K1, Z4
+	VMOVUPS.Z   (DI), K1, Z5
+	VSUBPS      Z5, Z4, Z4
+	VFMADD231PS Z4, Z4, Z1
+
+reduce:
+	VADDPS Z1, Z0, Z0
+	VADDPS Z3, Z2, Z2
+	VADDPS Z2, Z0, Z0
+	ZSUM(Z0, Y0, Y1)
+	HSUM(Y0, X0, X1)
+	VZEROUPPER
+	MOVSS  X0, ret+48(FP)
+	RET
+
+// func cosineAVX512(a, b []float32) (dot, normA, normB float32)
+TEXT ·cosineAVX512(SB), NOSPLIT, $0-60
+	MOVQ   a_base+0(FP), SI
+	MOVQ   b_base+24(FP), DI
+	MOVQ   a_len+8(FP), CX
+	VXORPS Z0, Z0, Z0 // dot
+	VXORPS Z1, Z1, Z1
+	VXORPS Z2, Z2, Z2 // normA
+	VXORPS Z3, Z3, Z3
+	VXORPS Z4, Z4, Z4 // normB
+	VXORPS Z5, Z5, Z5
+
+loop32:
+	CMPQ        CX, $32
+	JL          loop16
+	VMOVUPS     (SI), Z6
+	VMOVUPS     64(SI), Z7
+	VMOVUPS     (DI), Z8
+	VMOVUPS     64(DI), Z9
+	VFMADD231PS Z8, Z6, Z0
+	VFMADD231PS Z9, Z7, Z1
+	VFMADD231PS Z6, Z6, Z2
+	VFMADD231PS Z7, Z7, Z3
+	VFMADD231PS Z8, Z8, Z4
+	VFMADD231PS Z9, Z9, Z5
+	ADDQ        $128, SI
+	ADDQ        $128, DI
+	SUBQ        $32, CX
+	JMP         loop32
+
+loop16:
+	CMPQ        CX, $16
+	JL          tail
+	VMOVUPS     (SI), Z6
+	VMOVUPS     (DI), Z8
+	VFMADD231PS Z8, Z6, Z0
+	VFMADD231PS Z6, Z6, Z2
+	VFMADD231PS Z8, Z8, Z4
+	ADDQ        $64, SI
+	ADDQ        $64, DI
+	SUBQ        $16, CX
+	JMP         loop16
+
+tail:
+	TESTQ       CX, CX
+	JZ          reduce
+	TAILMASK
+	VMOVUPS.Z   (SI), K1, Z6
+	VMOVUPS.Z   (DI), K1, Z8
+	VFMADD231PS Z8, Z6, Z1
+	VFMADD231PS Z6, Z6, Z3
+	VFMADD231PS Z8, Z8, Z5
+
+reduce:
+	VADDPS Z1, Z0, Z0
+	VADDPS Z3, Z2, Z2
+	VADDPS Z5, Z4, Z4
+	ZSUM(Z0, Y0, Y1)
+	ZSUM(Z2, Y2, Y3)
+	ZSUM(Z4, Y4, Y5)
+	HSUM(Y0, X0, X1)
+	HSUM(Y2, X2, X3)
+	HSUM(Y4, X4, X5)
+	VZEROUPPER
+	MOVSS  X0, dot+48(FP)
+	MOVSS  X2, normA+52(FP)
+	MOVSS  X4, normB+56(FP)
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL   $0, CX
+	XGETBV
+	MOVL   AX, eax+0(FP)
+	MOVL   DX, edx+4(FP)
+	RET
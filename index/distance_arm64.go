@@ -0,0 +1,18 @@
+//go:build arm64 && !purego
+
+package hnsw
+
+// Implemented in distance_arm64.s
+func dotNEON(a, b []float32) float32
+func l2NEON(a, b []float32) float32
+func cosineNEON(a, b []float32) (dot, normA, normB float32)
+
+// simdKernels returns the NEON kernels, which every arm64 CPU supports
+func simdKernels() []distanceKernels {
+	return []distanceKernels{{
+		name:   "neon",
+		dot:    dotNEON,
+		l2:     l2NEON,
+		cosine: cosineNEON,
+	}}
+}
@@ -0,0 +1,195 @@
+//go:build arm64 && !purego
+
+#include "textflag.h"
+
+// ONES fills V12 with 1.0 in every lane. NEON has no float vector add the
+// assembler accepts, so sums and differences are fused with a multiply by 1,
+// which rounds the same as the add alone.
+#define ONES \
+	MOVW $0x3f800000, R3; \
+	VDUP R3, V12.S4
+
+// VSUM leaves the sum of the four lanes of v in f, its low lane
+#define VSUM(v, f) \
+	VMOV  v.S[1], R3; \
+	VMOV  v.S[2], R4; \
+	VMOV  v.S[3], R5; \
+	FMOVS R3, F13; \
+	FMOVS R4, F14; \
+	FMOVS R5, F15; \
+	FADDS F13, f; \
+	FADDS F14, f; \
+	FADDS F15, f
+
+// func dotNEON(a, b []float32) float32
+TEXT ·dotNEON(SB), NOSPLIT, $0-52
+	MOVD a_base+0(FP), R0
+	MOVD b_base+24(FP), R1
+	MOVD a_len+8(FP), R2
+	ONES
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+loop16:
+	CMP    $16, R2
+	BLT    loop4
+	VLD1.P 64(R0), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VLD1.P 64(R1), [V8.S4, V9.S4, V10.S4, V11.S4]
+	VFMLA  V8.S4, V4.S4, V0.S4
+	VFMLA  V9.S4, V5.S4, V1.S4
+	VFMLA  V10.S4, V6.S4, V2.S4
+	VFMLA  V11.S4, V7.S4, V3.S4
+	SUB    $16, R2
+	B      loop16
+
+loop4:
+	CMP    $4, R2
+	BLT    reduce
+	VLD1.P 16(R0), [V4.S4]
+	VLD1.P 16(R1), [V8.S4]
+	VFMLA  V8.S4, V4.S4, V0.S4
+	SUB    $4, R2
+	B      loop4
+
+reduce:
+	VFMLA V1.S4, V12.S4, V0.S4
+	VFMLA V3.S4, V12.S4, V2.S4
+	VFMLA V2.S4, V12.S4, V0.S4
+	VSUM(V0, F0)
+
+tail:
+	CBZ     R2, done
+	FMOVS.P 4(R0), F4
+	FMOVS.P 4(R1), F8
+	FMULS   F4, F8, F9
+	FADDS   F9, F0
+	SUB     $1, R2
+	B       tail
+
+done:
+	FMOVS F0, ret+48(FP)
+	RET
+
+// func l2NEON(a, b []float32) float32
+TEXT ·l2NEON(SB), NOSPLIT, $0-52
+	MOVD a_base+0(FP), R0
+	MOVD b_base+24(FP), R1
+	MOVD a_len+8(FP), R2
+	ONES
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+loop16:
+	CMP    $16, R2
+	BLT    loop4
+	VLD1.P 64(R0), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VLD1.P 64(R1), [V8.S4, V9.S4, V10.S4, V11.S4]
+	VFMLS  V8.S4, V12.S4, V4.S4
+	VFMLS  V9.S4, V12.S4, V5.S4
+	VFMLS  V10.S4, V12.S4, V6.S4
+	VFMLS  V11.S4, V12.S4, V7.S4
+	VFMLA  V4.S4, V4.S4, V0.S4
+	VFMLA  V5.S4, V5.S4, V1.S4
+	VFMLA  V6.S4, V6.S4, V2.S4
+	VFMLA  V7.S4, V7.S4, V3.S4
+	SUB    $16, R2
+	B      loop16
+
+loop4:
+	CMP    $4, R2
+	BLT    reduce
+	VLD1.P 16(R0), [V4.S4]
+	VLD1.P 16(R1), [V8.S4]
+	VFMLS  V8.S4, V12.S4, V4.S4
+	VFMLA  V4.S4, V4.S4, V0.S4
+	SUB    $4, R2
+	B      loop4
+
+reduce:
+	VFMLA V1.S4, V12.S4, V0.S4
+	VFMLA V3.S4, V12.S4, V2.S4
+	VFMLA V2.S4, V12.S4, V0.S4
+	VSUM(V0, F0)
+
+tail:
+	CBZ     R2, done
+	FMOVS.P 4(R0), F4
+	FMOVS.P 4(R1), F8
+	FSUBS   F8, F4, F9
+	FMULS   F9, F9, F9
+	FADDS   F9, F0
+	SUB     $1, R2
+	B       tail
+
+done:
+	FMOVS F0, ret+48(FP)
+	RET
+
+// func cosineNEON(a, b []float32) (dot, normA, normB float32)
+TEXT ·cosineNEON(SB), NOSPLIT, $0-60
+	MOVD a_base+0(FP), R0
+	MOVD b_base+24(FP), R1
+	MOVD a_len+8(FP), R2
+	ONES
+	VEOR V0.B16, V0.B16, V0.B16 // dot
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16 // normA
+	VEOR V3.B16, V3.B16, V3.B16
+	VEOR V6.B16, V6.B16, V6.B16 // normB
+	VEOR V7.B16, V7.B16, V7.B16
+
+loop8:
+	CMP    $8, R2
+	BLT    loop4
+	VLD1.P 32(R0), [V4.S4, V5.S4]
+	VLD1.P 32(R1), [V8.S4, V9.S4]
+	VFMLA  V8.S4, V4.S4, V0.S4
+	VFMLA  V9.S4, V5.S4, V1.S4
+	VFMLA  V4.S4, V4.S4, V2.S4
+	VFMLA  V5.S4, V5.S4, V3.S4
+	VFMLA  V8.S4, V8.S4, V6.S4
+	VFMLA  V9.S4, V9.S4, V7.S4
+	SUB    $8, R2
+	B      loop8
+
+loop4:
+	CMP    $4, R2
+	BLT    reduce
+	VLD1.P 16(R0), [V4.S4]
+	VLD1.P 16(R1), [V8.S4]
+	VFMLA  V8.S4, V4.S4, V0.S4
+	VFMLA  V4.S4, V4.S4, V2.S4
+	VFMLA  V8.S4, V8.S4, V6.S4
+	SUB    $4, R2
+	B      loop4
+
+reduce:
+	VFMLA V1.S4, V12.S4, V0.S4
+	VFMLA V3.S4, V12.S4, V2.S4
+	VFMLA V7.S4, V12.S4, V6.S4
+	VSUM(V0, F0)
+	VSUM(V2, F2)
+	VSUM(V6, F6)
+
+tail:
+	CBZ     R2, done
+	FMOVS.P 4(R0), F4
+	FMOVS.P 4(R1), F8
+	FMULS   F4, F8, F9
+	FADDS   F9, F0
+	FMULS   F4, F4, F9
+	FADDS   F9, F2
+	FMULS   F8, F8, F9
+	FADDS   F9, F6
+	SUB     $1, R2
+	B       tail
+
+done:
+	FMOVS F0, dot+48(FP)
+	FMOVS F2, normA+52(FP)
+	FMOVS F6, normB+56(FP)
+	RET
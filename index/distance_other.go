@@ -0,0 +1,8 @@
+//go:build (!amd64 && !arm64) || purego
+
+package hnsw
+
+// simdKernels returns no kernels: the scalar loops are used everywhere else
+func simdKernels() []distanceKernels {
+	return nil
+}
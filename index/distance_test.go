@@ -0,0 +1,123 @@
+package hnsw
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistanceKernels(t *testing.T) {
+	supported := simdKernels()
+	if len(supported) == 0 {
+		t.Skipf("no SIMD kernels on this CPU, distances use %s", DistanceKernel())
+	}
+	if DistanceKernel() != supported[0].name {
+		t.Errorf("DistanceKernel() = %s, want the fastest supported, %s", DistanceKernel(), supported[0].name)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	normal := func() float32 { return float32(rng.NormFloat64()) }
+	large := func() float32 { return float32(rng.NormFloat64() * 1e17) }
+	denormal := func() float32 {
+		// A zero exponent with random sign and mantissa
+		return math.Float32frombits(rng.Uint32() & 0x807fffff)
+	}
+	generators := map[string]func() float32{
+		"random":   normal,
+		"large":    large,
+		"denormal": denormal,
+		"mixed": func() float32 {
+			return []func() float32{normal, large, denormal}[rng.Intn(3)]()
+		},
+	}
+
+	// Sums are compared relative to the sum of the magnitudes of their
+	// terms, since cancelling terms leave a small result whose relative
+	// error means little. Products in the denormal range may round once
+	// with a fused multiply-add rather than twice, so a unit of the smallest
+	// denormal per term is allowed as well.
+	check := func(t *testing.T, what string, got, want float32, scale float64, n int) {
+		t.Helper()
+		diff := math.Abs(float64(got) - float64(want))
+		if diff > 1e-5*scale+float64(n)*math.SmallestNonzeroFloat32 {
+			t.Errorf("%s = %g, scalar %g, relative error %g", what, got, want, diff/scale)
+		}
+	}
+
+	for _, k := range supported {
+		for kind, gen := range generators {
+			t.Run(k.name+"/"+kind, func(t *testing.T) {
+				for _, n := range []int{0, 1, 3, 4, 7, 8, 15, 16, 17, 31, 32, 33, 63, 64, 65, 100, 768, 1000} {
+					a, b := make([]float32, n), make([]float32, n)
+					for i := range a {
+						a[i], b[i] = gen(), gen()
+					}
+					var dotScale, l2Scale, normAScale, normBScale float64
+					for i := range a {
+						x, y := float64(a[i]), float64(b[i])
+						dotScale += math.Abs(x * y)
+						l2Scale += (x - y) * (x - y)
+						normAScale += x * x
+						normBScale += y * y
+					}
+
+					what := fmt.Sprintf("n=%d: ", n)
+					check(t, what+"dot", k.dot(a, b), dotScalar(a, b), dotScale, n)
+					check(t, what+"l2", k.l2(a, b), l2Scalar(a, b), l2Scale, n)
+					dot, normA, normB := k.cosine(a, b)
+					wantDot, wantA, wantB := cosineScalar(a, b)
+					check(t, what+"cosine dot", dot, wantDot, dotScale, n)
+					check(t, what+"normA", normA, wantA, normAScale, n)
+					check(t, what+"normB", normB, wantB, normBScale, n)
+				}
+			})
+		}
+	}
+
+	// Kernels never read past the ends of short slices of a larger array
+	backing := make([]float32, 40)
+	for i := range backing {
+		backing[i] = float32(i)
+	}
+	for _, k := range supported {
+		for n := 0; n <= 17; n++ {
+			a, b := backing[:n], backing[20:20+n]
+			if got, want := k.dot(a, b), dotScalar(a, b); got != want {
+				t.Errorf("%s: dot of %d elements = %v, want %v", k.name, n, got, want)
+			}
+			if got, want := k.l2(a, b), l2Scalar(a, b); got != want {
+				t.Errorf("%s: l2 of %d elements = %v, want %v", k.name, n, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkDistanceD768(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	x, y := make([]float32, 768), make([]float32, 768)
+	for i := range x {
+		x[i], y[i] = rng.Float32(), rng.Float32()
+	}
+
+	var sink float32
+	for _, k := range append([]distanceKernels{scalarKernels}, simdKernels()...) {
+		b.Run("L2/"+k.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sink += k.l2(x, y)
+			}
+		})
+		b.Run("InnerProduct/"+k.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sink += k.dot(x, y)
+			}
+		})
+		b.Run("Cosine/"+k.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dot, _, _ := k.cosine(x, y)
+				sink += dot
+			}
+		})
+	}
+	_ = sink
+}
@@ -0,0 +1,99 @@
+package hnsw
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestEmptyIndex runs the public methods of the index against an index
+// without nodes, before and after a save and load round trip
+func TestEmptyIndex(t *testing.T) {
+	ctx := context.Background()
+	query := []float32{1, 2, 3, 4}
+
+	runAll := func(name string, index *HNSWIndex) {
+		t.Helper()
+		if n := index.Len(); n != 0 {
+			t.Errorf("%s: Len = %d", name, n)
+		}
+		if _, err := index.Search(query, 5, 0); err != ErrEmptyIndex {
+			t.Errorf("%s: Search: expected ErrEmptyIndex, got %v", name, err)
+		}
+		if _, err := index.SearchWithParams(query, 5, SearchParams{EfUpperLayers: 4}); err != ErrEmptyIndex {
+			t.Errorf("%s: SearchWithParams: expected ErrEmptyIndex, got %v", name, err)
+		}
+		if _, err := index.SearchWithAdaptiveEf(query, 5); err != ErrEmptyIndex {
+			t.Errorf("%s: SearchWithAdaptiveEf: expected ErrEmptyIndex, got %v", name, err)
+		}
+		err := index.SearchStream(ctx, query, StreamParams{K: 5}, func(SearchResult) bool {
+			t.Errorf("%s: SearchStream emitted a hit", name)
+			return true
+		})
+		if err != ErrEmptyIndex {
+			t.Errorf("%s: SearchStream: expected ErrEmptyIndex, got %v", name, err)
+		}
+		if _, err := index.SearchRadius(ctx, query, StreamParams{Radius: 1}); err != ErrEmptyIndex {
+			t.Errorf("%s: SearchRadius: expected ErrEmptyIndex, got %v", name, err)
+		}
+		if _, err := index.Vector(0); err == nil {
+			t.Errorf("%s: Vector(0) succeeded", name)
+		}
+		distances, err := index.DistancesTo(query, []int{0})
+		if err != nil || len(distances) != 1 || !math.IsNaN(float64(distances[0])) {
+			t.Errorf("%s: DistancesTo = %v, %v", name, distances, err)
+		}
+		if index.GraphHash() == "" {
+			t.Errorf("%s: GraphHash is empty", name)
+		}
+		report, err := index.Optimize(ctx, OptimizeOptions{})
+		if err != nil || report.Visited != 0 || report.Nodes != 0 {
+			t.Errorf("%s: Optimize = %+v, %v", name, report, err)
+		}
+	}
+
+	index := NewHNSW(Config{Dimension: 4, M: 8, EfConstruction: 50, Seed: 1})
+	runAll("new", index)
+	hash := index.GraphHash()
+
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	for _, mode := range []GraphStorage{InMemory, TieredL0} {
+		loaded, err := LoadHNSWFromLance(dir, WithGraphStorage(mode))
+		if err != nil {
+			t.Fatalf("LoadHNSWFromLance(%v) failed: %v", mode, err)
+		}
+		runAll("loaded", loaded)
+		if loaded.GraphHash() != hash {
+			t.Errorf("GraphHash changed by the round trip")
+		}
+
+		// The loaded index accepts nodes like a new one
+		if _, err := loaded.Add(query); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		results, err := loaded.Search(query, 5, 0)
+		if err != nil || len(results) != 1 || results[0].ID != 0 {
+			t.Errorf("Search after Add = %v, %v", results, err)
+		}
+		loaded.Close()
+	}
+
+	// Saving an empty index over a populated one replaces it
+	index.Add(query)
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	empty := NewHNSW(Config{Dimension: 4, M: 8, EfConstruction: 50})
+	if err := empty.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	loaded, err := LoadHNSWFromLance(dir)
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	runAll("overwritten", loaded)
+	loaded.Close()
+}
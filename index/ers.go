@@ -11,4 +11,26 @@ var (
 
 	// ErrInvalidParameter is returned when a parameter is invalid
 	ErrInvalidParameter = errors.New("invalid parameter")
+
+	// ErrInvalidK is returned when a search asks for k <= 0 results
+	ErrInvalidK = errors.New("k must be positive")
+
+	// ErrNodeNotFound is returned when a node ID is not in the index
+	ErrNodeNotFound = errors.New("node not found")
+
+	// ErrNotSaved is returned by Compact for an index that was neither
+	// saved nor loaded
+	ErrNotSaved = errors.New("index has not been saved")
+
+	// ErrResultsTruncated is returned by SearchRadius, together with the
+	// results, when more hits than StreamParams.MaxResults were found
+	ErrResultsTruncated = errors.New("results truncated at MaxResults")
+
+	// ErrVectorType is returned when float32 vectors are passed to a binary
+	// index, or binary vectors to a float32 one
+	ErrVectorType = errors.New("vector type does not match the index")
+
+	// ErrUnsupportedFormat is returned when an imported index file is of an
+	// unknown type, version or layout
+	ErrUnsupportedFormat = errors.New("unsupported index file format")
 )
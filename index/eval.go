@@ -0,0 +1,212 @@
+package hnsw
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RecallReport describes how closely Search matched exact search over a set
+// of queries, as measured by EvaluateRecall
+type RecallReport struct {
+	Queries int // Queries evaluated
+	K       int // Neighbors requested per query
+	Ef      int // Search ef, 0 = the index default
+
+	// Recall of each query, in query order: the fraction of its exact k
+	// nearest neighbors that Search returned
+	Recalls    []float64
+	MeanRecall float64
+	MinRecall  float64
+
+	// Histogram[i] counts the queries with recall in [i/10, (i+1)/10);
+	// Histogram[10] those with recall 1
+	Histogram [11]int
+
+	// Latency of the Search calls, which run one at a time
+	LatencyMean time.Duration
+	LatencyP50  time.Duration
+	LatencyP95  time.Duration
+	LatencyP99  time.Duration
+
+	GroundTruthTime time.Duration // Time spent on exact search
+}
+
+// BruteForceSearch returns the exact k nearest neighbors of query, closest
+// first, by comparing it with every node. Ties are broken by node ID. It is
+// the reference EvaluateRecall measures Search against, and costs a
+// distance computation per node.
+func (h *HNSWIndex) BruteForceSearch(query []float32, k int) ([]SearchResult, error) {
+	if err := h.checkVector(len(query)); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, ErrInvalidK
+	}
+	view := h.snapshot()
+	if view.entryPoint == -1 {
+		return nil, ErrEmptyIndex
+	}
+	return h.bruteForce(view, query, k), nil
+}
+
+// bruteForce scans the nodes of view for the k nearest to query, keeping
+// them in a sorted list so that memory stays at k results
+func (h *HNSWIndex) bruteForce(view *graphView, query []float32, k int) []SearchResult {
+	closer := func(a, b SearchResult) bool {
+		return a.Distance < b.Distance || a.Distance == b.Distance && a.ID < b.ID
+	}
+	query = h.prepareQuery(query)
+	results := make([]SearchResult, 0, k+1)
+	buf := make([]float32, h.dimension)
+	for id, node := range view.nodes {
+		if node.deleted.Load() {
+			continue
+		}
+		r := SearchResult{ID: id, Distance: h.exactDist(query, id, buf), ExternalID: h.externalID(id)}
+		if len(results) == k && !closer(r, results[k-1]) {
+			continue
+		}
+		i := sort.Search(len(results), func(i int) bool { return closer(r, results[i]) })
+		results = slices.Insert(results, i, r)
+		if len(results) > k {
+			results = results[:k]
+		}
+	}
+	return results
+}
+
+// EvaluateRecall measures the recall of Search(query, k, ef) over queries
+// against BruteForceSearch, to check parameter choices on real data.
+// Exact search runs on a pool of runtime.GOMAXPROCS(0) workers; the Search
+// calls run one at a time so their latencies are not skewed by each other.
+// Both stop when ctx is cancelled, returning ctx.Err().
+//
+// The index should not change during the evaluation, or recall is measured
+// against a mix of graphs.
+func (h *HNSWIndex) EvaluateRecall(ctx context.Context, queries [][]float32, k, ef int) (RecallReport, error) {
+	if k <= 0 {
+		return RecallReport{}, ErrInvalidK
+	}
+	for _, query := range queries {
+		if err := h.checkVector(len(query)); err != nil {
+			return RecallReport{}, err
+		}
+	}
+	view := h.snapshot()
+	if view.entryPoint == -1 {
+		return RecallReport{}, ErrEmptyIndex
+	}
+
+	start := time.Now()
+	truth, err := h.groundTruth(ctx, view, queries, k)
+	if err != nil {
+		return RecallReport{}, err
+	}
+	report := RecallReport{
+		Queries:         len(queries),
+		K:               k,
+		Ef:              ef,
+		Recalls:         make([]float64, len(queries)),
+		GroundTruthTime: time.Since(start),
+	}
+
+	latencies := make([]time.Duration, len(queries))
+	for i, query := range queries {
+		if err := ctx.Err(); err != nil {
+			return RecallReport{}, err
+		}
+		start := time.Now()
+		results, err := h.Search(query, k, ef)
+		latencies[i] = time.Since(start)
+		if err != nil {
+			return RecallReport{}, err
+		}
+		report.Recalls[i] = recall(truth[i], results)
+	}
+
+	report.MinRecall = 1
+	var total float64
+	for _, r := range report.Recalls {
+		total += r
+		if r < report.MinRecall {
+			report.MinRecall = r
+		}
+		report.Histogram[min(int(r*10), 10)]++
+	}
+	var latency time.Duration
+	for _, l := range latencies {
+		latency += l
+	}
+	if n := len(queries); n > 0 {
+		report.MeanRecall = total / float64(n)
+		report.LatencyMean = latency / time.Duration(n)
+	} else {
+		report.MinRecall = 0
+	}
+	slices.Sort(latencies)
+	report.LatencyP50 = percentile(latencies, 50)
+	report.LatencyP95 = percentile(latencies, 95)
+	report.LatencyP99 = percentile(latencies, 99)
+	return report, nil
+}
+
+// groundTruth runs exact search for queries on a pool of workers
+func (h *HNSWIndex) groundTruth(ctx context.Context, view *graphView, queries [][]float32, k int) ([][]SearchResult, error) {
+	truth := make([][]SearchResult, len(queries))
+	jobs := make(chan int, len(queries))
+	for i := range queries {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), len(queries)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				truth[i] = h.bruteForce(view, queries[i], k)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return truth, nil
+}
+
+// recall returns the fraction of truth that results contains
+func recall(truth, results []SearchResult) float64 {
+	if len(truth) == 0 {
+		return 1
+	}
+	want := make(map[int]struct{}, len(truth))
+	for _, r := range truth {
+		want[r.ID] = struct{}{}
+	}
+	hits := 0
+	for _, r := range results {
+		if _, ok := want[r.ID]; ok {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(truth))
+}
+
+// percentile returns the p-th percentile of sorted by nearest rank, 0 if it
+// is empty
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,117 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestBruteForceSearch(t *testing.T) {
+	// Points on a line, so the exact neighbors of each query are known
+	index := NewHNSW(Config{Dimension: 2, M: 8, EfConstruction: 64, Seed: 1})
+	for i := 0; i < 100; i++ {
+		index.Add([]float32{float32(i), 0})
+	}
+	if err := index.Delete(41); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	results, err := index.BruteForceSearch([]float32{40.25, 0}, 4)
+	if err != nil {
+		t.Fatalf("BruteForceSearch failed: %v", err)
+	}
+	var ids []int
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	if fmt.Sprint(ids) != "[40 39 42 38]" {
+		t.Errorf("BruteForceSearch = %v, want [40 39 42 38] without the deleted 41", ids)
+	}
+
+	// Matches a full sort on random data
+	vectors := generateRandomVectors(300, 8, 2)
+	index = NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 64, Seed: 2})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	for _, q := range generateRandomVectors(10, 8, 3) {
+		got, _ := index.BruteForceSearch(q, 10)
+		want := bruteForceSearch(q, vectors, 10)
+		sort.SliceStable(want, func(i, j int) bool { return want[i].Distance < want[j].Distance })
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("BruteForceSearch = %v, want %v", got, want)
+		}
+	}
+
+	if _, err := index.BruteForceSearch([]float32{1}, 10); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("short query: %v", err)
+	}
+	if _, err := index.BruteForceSearch(vectors[0], 0); !errors.Is(err, ErrInvalidK) {
+		t.Errorf("k = 0: %v", err)
+	}
+	if _, err := NewHNSW(Config{Dimension: 8}).BruteForceSearch(vectors[0], 1); !errors.Is(err, ErrEmptyIndex) {
+		t.Errorf("empty index: %v", err)
+	}
+}
+
+func TestEvaluateRecall(t *testing.T) {
+	ctx := context.Background()
+	vectors := generateRandomVectors(500, 16, 7)
+	index := NewHNSW(Config{Dimension: 16, M: 4, EfConstruction: 16, Seed: 7})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	queries := generateRandomVectors(50, 16, 8)
+
+	// An ef covering the whole graph reaches every node: recall is exactly 1
+	report, err := index.EvaluateRecall(ctx, queries, 10, len(vectors))
+	if err != nil {
+		t.Fatalf("EvaluateRecall failed: %v", err)
+	}
+	if report.MeanRecall != 1 || report.MinRecall != 1 || report.Histogram[10] != len(queries) {
+		t.Errorf("full ef: mean %v, min %v, histogram %v", report.MeanRecall, report.MinRecall, report.Histogram)
+	}
+	if report.Queries != 50 || report.K != 10 || report.Ef != len(vectors) || len(report.Recalls) != 50 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.LatencyP50 <= 0 || report.LatencyP50 > report.LatencyP95 || report.LatencyP95 > report.LatencyP99 {
+		t.Errorf("latencies p50 %v, p95 %v, p99 %v", report.LatencyP50, report.LatencyP95, report.LatencyP99)
+	}
+
+	// A narrow search misses some neighbors; the report matches counting them
+	report, err = index.EvaluateRecall(ctx, queries, 10, 10)
+	if err != nil {
+		t.Fatalf("EvaluateRecall failed: %v", err)
+	}
+	var total float64
+	for i, q := range queries {
+		truth, _ := index.BruteForceSearch(q, 10)
+		results, _ := index.Search(q, 10, 10)
+		want := recall(truth, results)
+		if report.Recalls[i] != want {
+			t.Errorf("query %d: recall %v, want %v", i, report.Recalls[i], want)
+		}
+		total += want
+	}
+	if report.MeanRecall != total/float64(len(queries)) || report.MeanRecall >= 1 {
+		t.Errorf("narrow ef: mean recall %v, want %v below 1", report.MeanRecall, total/float64(len(queries)))
+	}
+	sum := 0
+	for _, n := range report.Histogram {
+		sum += n
+	}
+	if sum != len(queries) {
+		t.Errorf("histogram %v counts %d queries", report.Histogram, sum)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := index.EvaluateRecall(cancelled, queries, 10, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled evaluation: %v", err)
+	}
+	if _, err := index.EvaluateRecall(ctx, [][]float32{{1, 2}}, 10, 10); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("short query: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package hnsw
+
+import (
+	"github.com/wzqhbustb/vego/storage/arrow"
+)
+
+// AddWithID is Add for a node known to the caller by externalID, which
+// search results carry back in SearchResult.ExternalID and saves keep.
+// External IDs need not be unique. Nodes added without one have their
+// node ID as external ID.
+func (h *HNSWIndex) AddWithID(externalID uint64, vector []float32) (int, error) {
+	if err := h.checkVector(len(vector)); err != nil {
+		return -1, err
+	}
+	return h.add(vector, &externalID)
+}
+
+// ExternalID returns the external ID of node id, its node ID unless it was
+// added with AddWithID.
+func (h *HNSWIndex) ExternalID(id int) (uint64, error) {
+	view := h.snapshot()
+	if id < 0 || id >= len(view.nodes) || view.nodes[id].deleted.Load() {
+		return 0, ErrNodeNotFound
+	}
+	return h.externalID(id), nil
+}
+
+// externalID returns the external ID of a node of a published view
+func (h *HNSWIndex) externalID(id int) uint64 {
+	if ids := h.externalIDs.Load(); ids != nil && ids.has(id) {
+		return uint64(id) + ids.at(id)[0]
+	}
+	return uint64(id)
+}
+
+// setExternalID records the external ID of node id before it is published.
+// Slots hold the difference from the node ID, so the zero slots of nodes
+// added without one read as their node ID. globalLock must be held for
+// writing, or the index not yet be shared.
+func (h *HNSWIndex) setExternalID(id int, externalID uint64) {
+	ids := h.externalIDs.Load()
+	if ids == nil {
+		ids = newArena[uint64](1, 8)
+		h.externalIDs.Store(ids)
+	}
+	ids.slot(id)[0] = externalID - uint64(id)
+}
+
+// withExternalIDs sets the ExternalID of results, in place
+func (h *HNSWIndex) withExternalIDs(results []SearchResult) []SearchResult {
+	for i := range results {
+		results[i].ExternalID = h.externalID(results[i].ID)
+	}
+	return results
+}
+
+// externalIDColumn drops the external_id field, last in schema, unless the
+// index has external IDs, else appends the external IDs of the nodes of ids
+// to columns. Indexes without them write files as before the column existed.
+func (h *HNSWIndex) externalIDColumn(schema *arrow.Schema, columns []arrow.Array, ids []int) (*arrow.Schema, []arrow.Array) {
+	if h.externalIDs.Load() == nil {
+		fields := schema.Fields()
+		return arrow.NewSchema(fields[:len(fields)-1], schema.Metadata()), columns
+	}
+	values := make([]int64, len(ids))
+	for i, id := range ids {
+		values[i] = int64(h.externalID(id))
+	}
+	return schema, append(columns, arrow.NewInt64Array(values, nil))
+}
+
+// loadExternalIDs sets the external IDs of the nodes of idArray from the
+// external_id column of a nodes file, which files written without external
+// IDs read as all null
+func (h *HNSWIndex) loadExternalIDs(idArray *arrow.Int32Array, column arrow.Array) {
+	external := column.(*arrow.Int64Array)
+	if external.Len() == 0 || external.NullN() == external.Len() {
+		return
+	}
+	for i := 0; i < idArray.Len(); i++ {
+		h.setExternalID(int(idArray.Value(i)), uint64(external.Value(i)))
+	}
+}
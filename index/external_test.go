@@ -0,0 +1,142 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/column"
+)
+
+func TestAddWithID(t *testing.T) {
+	vectors := generateRandomVectors(200, 8, 1)
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 64, Seed: 1})
+	for _, v := range vectors[:50] {
+		index.Add(v)
+	}
+	for i, v := range vectors[50:150] {
+		if _, err := index.AddWithID(uint64(1)<<40+uint64(i), v); err != nil {
+			t.Fatalf("AddWithID failed: %v", err)
+		}
+	}
+	for _, v := range vectors[150:] {
+		index.Add(v)
+	}
+	index.Delete(60)
+
+	want := func(id int) uint64 {
+		if id >= 50 && id < 150 {
+			return uint64(1)<<40 + uint64(id-50)
+		}
+		return uint64(id)
+	}
+	for id := range vectors {
+		got, err := index.ExternalID(id)
+		if id == 60 {
+			if !errors.Is(err, ErrNodeNotFound) {
+				t.Errorf("ExternalID of a deleted node: %d, %v", got, err)
+			}
+			continue
+		}
+		if err != nil || got != want(id) {
+			t.Errorf("ExternalID(%d) = %d, %v; want %d", id, got, err, want(id))
+		}
+	}
+	if _, err := index.ExternalID(len(vectors)); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("ExternalID past the last node: %v", err)
+	}
+	if _, err := index.AddWithID(1, []float32{1}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("AddWithID of a short vector: %v", err)
+	}
+
+	// Every way of searching carries them back
+	check := func(name string, results []SearchResult) {
+		t.Helper()
+		if len(results) == 0 {
+			t.Fatalf("%s found nothing", name)
+		}
+		for _, r := range results {
+			if r.ExternalID != want(r.ID) {
+				t.Errorf("%s: node %d has external ID %d, want %d", name, r.ID, r.ExternalID, want(r.ID))
+			}
+		}
+	}
+	for _, q := range []int{5, 70, 160} {
+		results, _ := index.Search(vectors[q], 10, 50)
+		check("Search", results)
+		results, _ = index.BruteForceSearch(vectors[q], 10)
+		check("BruteForceSearch", results)
+		results, _ = index.SearchRadius(context.Background(), vectors[q], StreamParams{K: 10})
+		check("SearchRadius", results)
+	}
+}
+
+func TestExternalIDsSaved(t *testing.T) {
+	vectors := generateRandomVectors(300, 8, 2)
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 64, Seed: 2})
+	for _, v := range vectors[:200] {
+		index.Add(v)
+	}
+
+	// An index without external IDs writes nodes.lance as before
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if n := nodesFileColumns(t, filepath.Join(dir, "nodes.lance")); n != 3 {
+		t.Errorf("nodes.lance without external IDs has %d columns, want 3", n)
+	}
+	checkLoadedEqual(t, dir, index)
+
+	// Nodes added with IDs after the base go into a segment
+	for i, v := range vectors[200:250] {
+		index.AddWithID(uint64(9000+i), v)
+	}
+	index.Delete(210)
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if got := readTestManifest(t, dir); len(got.Segments) != 1 {
+		t.Fatalf("segments %v, want 1", got.Segments)
+	}
+	loaded := checkLoadedEqual(t, dir, index)
+
+	// and into the base when saved whole, mapped or not
+	for _, v := range vectors[250:] {
+		loaded.Add(v)
+	}
+	if err := loaded.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if n := nodesFileColumns(t, filepath.Join(dir, "nodes.lance")); n != 4 {
+		t.Errorf("nodes.lance with external IDs has %d columns, want 4", n)
+	}
+	checkLoadedEqual(t, dir, loaded)
+	mapped := checkLoadedEqual(t, dir, loaded, WithGraphStorage(Mapped))
+	if mapped.GraphStorage() != Mapped {
+		t.Errorf("Expected Mapped storage, got %v", mapped.GraphStorage())
+	}
+
+	// Quantized nodes files have the column too
+	quantized := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 64, Seed: 2, Quantization: ScalarQuant8})
+	for i, v := range vectors[:100] {
+		quantized.AddWithID(uint64(100-i), v)
+	}
+	other := t.TempDir()
+	if err := quantized.SaveToLance(other); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	checkLoadedEqual(t, other, quantized)
+}
+
+// nodesFileColumns returns the number of columns of a nodes file
+func nodesFileColumns(t *testing.T, filename string) int {
+	t.Helper()
+	reader, err := column.NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	return reader.Schema().NumFields()
+}
@@ -0,0 +1,63 @@
+package hnsw
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sort"
+)
+
+// graphHashVersion prefixes the canonical form hashed by GraphHash; bump it
+// whenever that form changes
+const graphHashVersion = "vego-graph-v1"
+
+// GraphHash returns the hex SHA-256 of the canonical form of the graph: M,
+// Mmax0 and the dimension, the entry point and top level, then for every node
+// in ID order its level and its neighbor list on each layer, sorted. Integers
+// are written as fixed-width big-endian values, so the hash is the same on
+// every platform and independent of how the graph was stored or loaded.
+// Vectors and the distance function are not part of it. A deleted node is
+// written as level -1 without lists, and links to it are left out, as they
+// are when the graph is saved.
+func (h *HNSWIndex) GraphHash() string {
+	view := h.snapshot()
+
+	w := hashWriter{Hash: sha256.New()}
+	w.Write([]byte(graphHashVersion))
+	w.int(h.M)
+	w.int(h.Mmax0)
+	w.int(h.dimension)
+	w.int(int(view.entryPoint))
+	w.int(int(view.maxLevel))
+	w.int(len(view.nodes))
+
+	var sorted []int
+	for _, node := range view.nodes {
+		if node.deleted.Load() {
+			w.int(-1)
+			continue
+		}
+		w.int(node.level)
+		for level := 0; level <= node.level; level++ {
+			sorted = append(sorted[:0], live(view.nodes, node.neighbors(level))...)
+			sort.Ints(sorted)
+			w.int(len(sorted))
+			for _, id := range sorted {
+				w.int(id)
+			}
+		}
+	}
+	return hex.EncodeToString(w.Sum(nil))
+}
+
+// hashWriter writes integers to a hash in a fixed width and byte order
+type hashWriter struct {
+	hash.Hash
+	buf [8]byte
+}
+
+func (w *hashWriter) int(v int) {
+	binary.BigEndian.PutUint64(w.buf[:], uint64(int64(v)))
+	w.Write(w.buf[:])
+}
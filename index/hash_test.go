@@ -0,0 +1,70 @@
+package hnsw
+
+import (
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/encoding"
+)
+
+func newHashTestIndex(vectors [][]float32) *HNSWIndex {
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 50, Seed: 7})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	return index
+}
+
+func TestGraphHash(t *testing.T) {
+	vectors := generateRandomVectors(500, 8, 1)
+	index := newHashTestIndex(vectors)
+	hash := index.GraphHash()
+
+	if rebuilt := newHashTestIndex(vectors).GraphHash(); rebuilt != hash {
+		t.Errorf("identical builds hash differently: %s vs %s", rebuilt, hash)
+	}
+	if empty := NewHNSW(Config{Dimension: 8}).GraphHash(); empty == hash {
+		t.Errorf("empty index hashes like a populated one")
+	}
+
+	// The hash does not depend on how the files were compressed or how the
+	// graph is served after loading
+	for _, tc := range []struct {
+		name    string
+		level   int
+		storage GraphStorage
+	}{
+		{"zstd 1", 1, InMemory},
+		{"zstd 19", 19, InMemory},
+		{"tiered", 3, TieredL0},
+	} {
+		dir := t.TempDir()
+		if err := index.SaveToLanceWithFactory(dir, encoding.NewEncoderFactory(tc.level)); err != nil {
+			t.Fatalf("%s: SaveToLance failed: %v", tc.name, err)
+		}
+		loaded, err := LoadHNSWFromLance(dir, WithGraphStorage(tc.storage))
+		if err != nil {
+			t.Fatalf("%s: LoadHNSWFromLance failed: %v", tc.name, err)
+		}
+		if got := loaded.GraphHash(); got != hash {
+			t.Errorf("%s: loaded index hashes to %s, want %s", tc.name, got, hash)
+		}
+		loaded.Close()
+	}
+
+	// Neighbor order within a list does not matter
+	node := index.nodes[10]
+	reversed := node.GetConnections(0)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	node.SetConnections(0, reversed)
+	if got := index.GraphHash(); got != hash {
+		t.Errorf("reordering a neighbor list changed the hash")
+	}
+
+	// A single edge does
+	node.SetConnections(0, reversed[1:])
+	if got := index.GraphHash(); got == hash {
+		t.Errorf("removing an edge did not change the hash")
+	}
+}
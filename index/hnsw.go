@@ -3,7 +3,9 @@ package hnsw
 import (
 	"math"
 	"math/rand"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,58 +20,122 @@ type HNSWIndex struct {
 
 	dimension int // Dimensionality of the vectors.
 
-	nodes      []*Node // All nodes in the HNSW graph.
-	entryPoint int32   // Entry point node ID.
-	maxLevel   int32   // Maximum level in the HNSW hierarchy.
+	nodes      []*Node      // All nodes in the HNSW graph, deleted ones included.
+	vectors    *vectorArena // Node vectors, indexed by node ID.
+	deleted    int          // Nodes removed by Delete.
+	entryPoint int32        // Entry point node ID.
+	maxLevel   int32        // Maximum level in the HNSW hierarchy.
 
 	distFunc DistanceFunc // Distance function used for measuring similarity.
+	metric   string       // distanceName of distFunc, "" for a custom one.
 
-	globalLock sync.RWMutex // Protects the entire index during insertions.
+	// normalized is set when vectors are stored scaled to unit length, as
+	// they are with CosineDistance; compare is then distFunc reduced to a
+	// dot product, and queries are normalized once per search.
+	normalized bool
+	compare    DistanceFunc
+
+	binary bool // Vectors are packed bits compared by Hamming distance; see AddBinary.
+
+	quantization Quantization // How vectors are kept in memory.
+	fullVectors  FullVectors  // What a quantized index keeps of the float32 vectors.
+	sq8          *sq8Codes    // Node codes in ScalarQuant8 mode, else nil.
+	vectorFile   *vectorFile  // Open vectors.f32 of a loaded FullVectorsOnDisk index, else nil.
+
+	globalLock sync.RWMutex // Serializes writers of nodes, entryPoint and maxLevel.
+
+	// view is the latest published snapshot of nodes/entryPoint/maxLevel.
+	// Searches load it once and traverse without taking globalLock.
+	view atomic.Pointer[graphView]
 
 	rng *rand.Rand // Random number generator for level assignment.
 	mu  sync.Mutex // Protects the RNG.
+
+	graphStorage GraphStorage // Where the graph lives after loading.
+	l0CacheSize  int          // Hot layer-0 lists cached in TieredL0 mode.
+	l0           *l0Store     // Open layer-0 file in TieredL0 and Mapped modes, else nil.
+	upper        *upperStore  // Open upper-layer file in Mapped mode, else nil.
+	vectorMap    *vectorFile  // Mapped vectors.f32 or vectors.bin in Mapped mode, else nil.
+
+	// externalIDs holds the external IDs of nodes, less their node IDs; nil
+	// until a node is added with one. See AddWithID.
+	externalIDs atomic.Pointer[arena[uint64]]
+
+	saveMu sync.Mutex // Serializes SaveToLance and Compact.
+	saved  saveState  // The last save or load, which SaveToLance appends segments to.
+
+	optimizeMu     sync.Mutex // Serializes Optimize.
+	optimizeCursor int        // Node the next Optimize starts from.
+
+	defaultEf atomic.Int32 // Layer-0 ef of searches that set none, 0 = max(200, 2k); see SetDefaultEf.
+}
+
+// graphView is an immutable snapshot of the graph's node table and entry
+// point. Writers publish a new view after every structural change (the
+// "epoch advance"); readers that still hold an older view keep seeing a
+// consistent prefix of the node table, and retired views are reclaimed by the
+// garbage collector once no search references them.
+type graphView struct {
+	nodes      []*Node
+	entryPoint int32
+	maxLevel   int32
+}
+
+// publish installs the current writer state as the view seen by new
+// searches. Must be called with globalLock held for writing.
+func (h *HNSWIndex) publish() {
+	h.view.Store(&graphView{
+		nodes:      h.nodes,
+		entryPoint: h.entryPoint,
+		maxLevel:   h.maxLevel,
+	})
 }
 
-// Config holds the configuration parameters for the HNSW index.
+// snapshot returns the latest published view.
+func (h *HNSWIndex) snapshot() *graphView {
+	if v := h.view.Load(); v != nil {
+		return v
+	}
+	return &graphView{entryPoint: -1, maxLevel: -1}
+}
+
+// Config holds the configuration parameters for the HNSW index. Zero values
+// select the defaults noted below; see Validate for the allowed ranges.
 type Config struct {
-	M              int          // Maximum number of connections per level, default 16.
-	EfConstruction int          // default 200.
-	Dimension      int          // Vector dimensionality.
+	M              int          // Maximum number of connections per level, >= 2, default 16 (Adaptive: by Dimension).
+	EfConstruction int          // Candidate list size during construction, >= M, default 200 (Adaptive: by Dimension and ExpectedSize).
+	Dimension      int          // Vector dimensionality, required.
 	DistanceFunc   DistanceFunc // default L2Distance.
-	Seed           int64        // Seed for random level generation.
+	Seed           int64        // Seed for random level generation, default the current time.
 	Adaptive       bool         // If true, automatically calculate M and EfConstruction based on Dimension and ExpectedSize
 	ExpectedSize   int          // Expected dataset size for adaptive parameter calculation (default: 10000)
+	GraphStorage   GraphStorage // Graph placement when loading from disk, default InMemory.
+	L0CacheSize    int          // LRU size for layer-0 lists in TieredL0 mode, default 4096.
+	LoadWorkers    int          // Goroutines decoding and rebuilding the graph when loading from disk, default GOMAXPROCS.
+	Quantization   Quantization // In-memory vector format, default NoQuantization.
+	BinaryVectors  bool         // Vectors of Dimension bits, added and searched as []uint64 by Hamming distance; see AddBinary.
+	FullVectors    FullVectors  // Full vectors kept by a quantized index, default DropFullVectors.
 }
 
+// NewHNSW creates an empty index and logs the config's Warnings. It panics
+// if config fails Validate; use NewHNSWChecked to get the error instead.
 func NewHNSW(config Config) *HNSWIndex {
-	// ========== Adaptive Configuration Logic ==========
-	if config.Adaptive && config.Dimension > 0 {
-		adaptive := calculateAdaptiveParams(config.Dimension, config.ExpectedSize)
-
-		// Only override values not explicitly set by user (<= 0 means unset)
-		if config.M <= 0 {
-			config.M = adaptive.M
-		}
-		if config.EfConstruction <= 0 {
-			config.EfConstruction = adaptive.EfConstruction
-		}
-		if config.DistanceFunc == nil {
-			config.DistanceFunc = adaptive.DistanceFunc
-		}
+	h, err := NewHNSWChecked(config)
+	if err != nil {
+		panic(err)
 	}
+	return h
+}
 
-	if config.M <= 0 {
-		config.M = 16
-	}
-	if config.Dimension <= 0 {
-		panic("dimension must be positive")
-	}
-	if config.EfConstruction <= 0 {
-		config.EfConstruction = 200
-	}
-	if config.DistanceFunc == nil {
-		config.DistanceFunc = L2Distance
+// NewHNSWChecked is NewHNSW returning the error of Validate instead of
+// panicking on an invalid config.
+func NewHNSWChecked(config Config) (*HNSWIndex, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
+	config = config.withDefaults()
+	config.logWarnings()
+
 	if config.Seed == 0 {
 		config.Seed = time.Now().UnixNano()
 	}
@@ -77,7 +143,13 @@ func NewHNSW(config Config) *HNSWIndex {
 	// normalization factor for level generation
 	ml := 1.0 / math.Log(float64(config.M))
 
-	return &HNSWIndex{
+	// Binary vectors are stored as two float32 slots per 64 bits
+	if config.BinaryVectors {
+		config.Dimension /= 32
+		config.DistanceFunc = hammingPacked
+	}
+
+	h := &HNSWIndex{
 		M:              config.M,
 		Mmax:           config.M,
 		Mmax0:          config.M * 2,
@@ -85,72 +157,338 @@ func NewHNSW(config Config) *HNSWIndex {
 		ml:             ml,
 		dimension:      config.Dimension,
 		nodes:          make([]*Node, 0, 10000),
+		vectors:        newVectorArena(config.Dimension),
 		entryPoint:     -1, // -1 means no nodes yet
 		maxLevel:       -1,
 		distFunc:       config.DistanceFunc,
+		metric:         distanceName(config.DistanceFunc),
+		compare:        config.DistanceFunc,
+		quantization:   config.Quantization,
+		fullVectors:    config.FullVectors,
 		rng:            rand.New(rand.NewSource(config.Seed)),
+		graphStorage:   config.GraphStorage,
+		l0CacheSize:    config.L0CacheSize,
+	}
+	switch {
+	case config.BinaryVectors:
+		h.binary = true
+		h.metric = "hamming"
+	case h.metric == "cosine":
+		h.normalized = true
+		h.compare = normalizedCosineDistance
+	}
+	if config.Quantization == ScalarQuant8 {
+		h.sq8 = newSQ8Codes(config.Dimension)
 	}
+	return h, nil
 }
 
 // Add inserts a new vector into the HNSW index and returns its assigned node ID.
 func (h *HNSWIndex) Add(vector []float32) (int, error) {
-	if len(vector) != h.dimension {
-		return -1, ErrDimensionMismatch
+	if err := h.checkVector(len(vector)); err != nil {
+		return -1, err
 	}
+	return h.add(vector, nil)
+}
 
-	vectorCopy := make([]float32, len(vector))
-	copy(vectorCopy, vector)
-
+// add inserts a vector of the index's dimension, known by externalID if it
+// is not nil
+func (h *HNSWIndex) add(vector []float32, externalID *uint64) (int, error) {
 	// Generate a random level for the new node
 	level := h.randomLevel()
 
-	// Create the new node
+	// Create the new node and publish it before linking, so any neighbor
+	// list that references it is only ever observed alongside a view that
+	// contains it
 	h.globalLock.Lock()
 	nodeID := len(h.nodes)
-	newNode := NewNode(nodeID, vectorCopy, level)
-	h.nodes = append(h.nodes, newNode)
-	h.globalLock.Unlock()
-
-	if nodeID == 0 {
-		h.globalLock.Lock()
+	h.storeVector(nodeID, vector)
+	if externalID != nil {
+		h.setExternalID(nodeID, *externalID)
+	}
+	node := newNode(nodeID, level, h.nodeArena())
+	h.nodes = append(h.nodes, node)
+	first := h.entryPoint == -1
+	if first {
 		h.entryPoint = int32(nodeID)
 		h.maxLevel = int32(level)
-		h.globalLock.Unlock()
+	} else {
+		node.linking.Store(true)
+	}
+	h.publish()
+	h.globalLock.Unlock()
+
+	if first {
 		return nodeID, nil
 	}
 
-	h.insert(newNode)
+	h.insert(node)
+	node.linking.Store(false)
 
 	return nodeID, nil
 }
 
+// AddBatch inserts vectors and returns their node IDs, in order. IDs are
+// assigned as if each vector had been added with Add in turn; the nodes are
+// then linked in parallel on runtime.GOMAXPROCS(0) workers. Nothing is
+// added if a vector has the wrong dimension.
+func (h *HNSWIndex) AddBatch(vectors [][]float32) ([]int, error) {
+	for _, vector := range vectors {
+		if err := h.checkVector(len(vector)); err != nil {
+			return nil, err
+		}
+	}
+	levels := make([]int, len(vectors))
+	for i := range levels {
+		levels[i] = h.randomLevel()
+	}
+
+	// Publish every node first, as add does, then link them
+	ids := make([]int, len(vectors))
+	pending := make([]*Node, 0, len(vectors))
+	h.globalLock.Lock()
+	for i, vector := range vectors {
+		nodeID := len(h.nodes)
+		h.storeVector(nodeID, vector)
+		node := newNode(nodeID, levels[i], h.nodeArena())
+		h.nodes = append(h.nodes, node)
+		ids[i] = nodeID
+		if h.entryPoint == -1 {
+			h.entryPoint = int32(nodeID)
+			h.maxLevel = int32(levels[i])
+			continue
+		}
+		node.linking.Store(true)
+		pending = append(pending, node)
+	}
+	h.publish()
+	h.globalLock.Unlock()
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), len(pending)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(pending); i = int(next.Add(1) - 1) {
+				h.insert(pending[i])
+				pending[i].linking.Store(false)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ids, nil
+}
+
+// SearchParams controls how wide a search is at each level of the graph
+type SearchParams struct {
+	// EfUpperLayers is the beam width of the descent through the layers
+	// above 0. Values <= 1 select the classic greedy descent; wider beams
+	// help when the greedy path lands in a poor region, at a modest cost.
+	EfUpperLayers int
+
+	// EfBase is the ef used at layer 0. Values <= 0 select the default,
+	// see SetDefaultEf, and any value below k is raised to k.
+	EfBase int
+
+	// Allow, if set, restricts the results to the nodes it returns true
+	// for. It is applied during the traversal of layer 0: nodes it rejects
+	// are still expanded as routing nodes but take no result slot, so a
+	// selective filter costs more visits rather than recall. It is called
+	// at most once per visited node, from the searching goroutine.
+	Allow func(id int) bool
+}
+
+// Search returns up to k nearest neighbors of query, closest first. k must
+// be positive; when fewer than k nodes are reachable, all of them are returned
+// without error. ef <= 0 selects the default, set by SetDefaultEf or else
+// max(200, 2k), and any ef below k is raised to k, since the search cannot
+// return more results than it keeps.
+// Search is SearchWithParams with EfBase set to ef.
 func (h *HNSWIndex) Search(query []float32, k int, ef int) ([]SearchResult, error) {
-	if len(query) != h.dimension {
-		return nil, ErrDimensionMismatch
+	return h.SearchWithParams(query, k, SearchParams{EfBase: ef})
+}
+
+// SearchWithFilter is Search returning only nodes allow returns true for;
+// see SearchParams.Allow
+func (h *HNSWIndex) SearchWithFilter(query []float32, k int, ef int, allow func(id int) bool) ([]SearchResult, error) {
+	return h.SearchWithParams(query, k, SearchParams{EfBase: ef, Allow: allow})
+}
+
+// SearchWithParams is Search with per-level control of the search width
+func (h *HNSWIndex) SearchWithParams(query []float32, k int, params SearchParams) ([]SearchResult, error) {
+	if err := h.checkVector(len(query)); err != nil {
+		return nil, err
 	}
+	return h.searchWithParams(query, k, params)
+}
 
-	if ef == 0 {
-		ef = max(200, k*2)
+// searchWithParams searches for a query of the index's dimension
+func (h *HNSWIndex) searchWithParams(query []float32, k int, params SearchParams) ([]SearchResult, error) {
+	if k <= 0 {
+		return nil, ErrInvalidK
 	}
 
-	h.globalLock.RLock()
-	if h.entryPoint == -1 {
-		h.globalLock.RUnlock()
+	if params.EfBase <= 0 {
+		params.EfBase = int(h.defaultEf.Load())
+	}
+	if params.EfBase <= 0 {
+		params.EfBase = max(200, k*2)
+	}
+	if params.EfBase < k {
+		params.EfBase = k
+	}
+	if params.EfUpperLayers < 1 {
+		params.EfUpperLayers = 1
+	}
+
+	// Acquire an immutable view; the traversal itself takes no locks
+	view := h.snapshot()
+	if view.entryPoint == -1 {
 		return nil, ErrEmptyIndex
 	}
-	ep := h.entryPoint
-	maxLvl := h.maxLevel
-	h.globalLock.RUnlock()
 
-	return h.search(query, k, ef, int(ep), int(maxLvl))
+	return h.search(view, query, k, params)
+}
+
+// Vector returns a copy of the vector stored for node id. The caller owns the
+// returned slice. A cosine index returns it normalized to unit length, as it
+// is stored. A quantized index returns the full vector if it keeps them,
+// else the decoded codes.
+func (h *HNSWIndex) Vector(id int) ([]float32, error) {
+	if h.binary {
+		return nil, ErrVectorType
+	}
+	view := h.snapshot()
+	if id < 0 || id >= len(view.nodes) || view.nodes[id].deleted.Load() {
+		return nil, ErrNodeNotFound
+	}
+	if h.sq8 == nil {
+		return view.nodes[id].Vector(), nil
+	}
+	if vector, ok := h.fullVector(id, make([]float32, h.dimension)); ok {
+		return append([]float32(nil), vector...), nil
+	}
+	return h.sq8.decode(id, nil), nil
+}
+
+// StoredVector returns v as Vector would return it after adding it:
+// normalized to unit length in a cosine index, and decoded from its codes
+// in a ScalarQuant8 index that drops the full vectors. Comparing it with
+// Vector tells whether adding v would store the vector a node has.
+func (h *HNSWIndex) StoredVector(v []float32) []float32 {
+	v = h.prepareQuery(v)
+	if h.sq8 != nil && h.fullVectors == DropFullVectors {
+		return sq8RoundTrip(v)
+	}
+	return v
+}
+
+// DistancesTo returns the distance from query to each node of ids, in the
+// same order, using the index's distance function. It reads the vectors in
+// place from one snapshot instead of copying them as Vector does. An ID that
+// is not in the index, or was deleted, gets NaN rather than failing the call, so one stale ID
+// does not cost the whole batch; callers can detect it with math.IsNaN.
+func (h *HNSWIndex) DistancesTo(query []float32, ids []int) ([]float32, error) {
+	if err := h.checkVector(len(query)); err != nil {
+		return nil, err
+	}
 
+	query = h.prepareQuery(query)
+	nodes := h.snapshot().nodes
+	nan := float32(math.NaN())
+	distances := make([]float32, len(ids))
+	for i, id := range ids {
+		if id < 0 || id >= len(nodes) || nodes[id].deleted.Load() {
+			distances[i] = nan
+			continue
+		}
+		distances[i] = h.exactDist(query, id, nil)
+	}
+	return distances, nil
 }
 
-// Len returns the number of nodes in the HNSW index.
+// Len returns the number of nodes in the HNSW index, not counting deleted
+// ones. Node IDs range up to Len plus Deleted.
 func (h *HNSWIndex) Len() int {
 	h.globalLock.RLock()
 	defer h.globalLock.RUnlock()
-	return len(h.nodes)
+	return len(h.nodes) - h.deleted
+}
+
+// Deleted returns the number of nodes removed by Delete
+func (h *HNSWIndex) Deleted() int {
+	h.globalLock.RLock()
+	defer h.globalLock.RUnlock()
+	return h.deleted
+}
+
+// EfConstruction returns the candidate list size used during construction.
+func (h *HNSWIndex) EfConstruction() int {
+	return h.efConstruction
+}
+
+// SetDefaultEf sets the layer-0 ef of searches that pass none, in place of
+// max(200, 2k); ef <= 0 restores that. It takes effect for searches started
+// afterwards, and is saved with the index.
+func (h *HNSWIndex) SetDefaultEf(ef int) {
+	h.defaultEf.Store(int32(max(ef, 0)))
+}
+
+// Params returns the parameters the index was built with, which in
+// Adaptive mode were derived from the dimension and expected size, the
+// default search ef, 0 when it is max(200, 2k), and the name of the
+// distance function: "l2", "l2sqrt", "ip", "cosine", or "" for a custom
+// one.
+func (h *HNSWIndex) Params() (m, efConstruction, defaultEf int, metric string) {
+	return h.M, h.efConstruction, int(h.defaultEf.Load()), h.metric
+}
+
+// GraphStorage reports where the graph is served from. A TieredL0 or
+// Mapped load whose files were missing or invalid reports InMemory.
+func (h *HNSWIndex) GraphStorage() GraphStorage {
+	h.globalLock.RLock()
+	defer h.globalLock.RUnlock()
+	if h.vectorMap != nil {
+		return Mapped
+	}
+	if h.l0 != nil {
+		return TieredL0
+	}
+	return InMemory
+}
+
+// Close releases the memory-mapped files, if any. The index must not be
+// searched or modified afterwards when it was loaded in TieredL0 or Mapped
+// mode.
+func (h *HNSWIndex) Close() error {
+	h.globalLock.Lock()
+	defer h.globalLock.Unlock()
+	var err error
+	if h.l0 != nil {
+		err = h.l0.Close()
+		h.l0 = nil
+	}
+	if h.vectorFile != nil {
+		if cerr := h.vectorFile.Close(); err == nil {
+			err = cerr
+		}
+		h.vectorFile = nil
+	}
+	if h.upper != nil {
+		if cerr := h.upper.Close(); err == nil {
+			err = cerr
+		}
+		h.upper = nil
+	}
+	if h.vectorMap != nil {
+		if cerr := h.vectorMap.Close(); err == nil {
+			err = cerr
+		}
+		h.vectorMap = nil
+	}
+	return err
 }
 
 // randomLevel generates a random level for a new node based on an exponential distribution.
@@ -179,8 +517,9 @@ func (h *HNSWIndex) randomLevel() int {
 
 // SearchResult represents a single search result with its ID and distance.
 type SearchResult struct {
-	ID       int
-	Distance float32
+	ID         int
+	Distance   float32
+	ExternalID uint64 // See AddWithID; ID unless the node was added with one.
 }
 
 // Helper function
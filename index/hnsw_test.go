@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"reflect"
 	"sort"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestHNSWBasic(t *testing.T) {
@@ -150,6 +152,119 @@ func BenchmarkHNSWSearch(b *testing.B) {
 	}
 }
 
+// runInsertSearchStress runs 8 search goroutines against 2 insert
+// goroutines on index for d and returns the search latencies. With
+// writeLock set, each insert holds it and each search waits for it, as
+// with a global write lock.
+func runInsertSearchStress(t *testing.T, index *HNSWIndex, d time.Duration, writeLock *sync.RWMutex) []time.Duration {
+	t.Helper()
+	dim := index.dimension
+	deadline := time.Now().Add(d)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var latencies []time.Duration
+
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for time.Now().Before(deadline) {
+				vector := make([]float32, dim)
+				for i := range vector {
+					vector[i] = rng.Float32()
+				}
+				if writeLock != nil {
+					writeLock.Lock()
+				}
+				_, err := index.Add(vector)
+				if writeLock != nil {
+					writeLock.Unlock()
+				}
+				if err != nil {
+					t.Errorf("Add failed: %v", err)
+					return
+				}
+			}
+		}(int64(w))
+	}
+
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(100 + seed))
+			query := make([]float32, dim)
+			var own []time.Duration
+			for time.Now().Before(deadline) {
+				for i := range query {
+					query[i] = rng.Float32()
+				}
+				start := time.Now()
+				if writeLock != nil {
+					writeLock.RLock()
+				}
+				results, err := index.Search(query, 10, 64)
+				if writeLock != nil {
+					writeLock.RUnlock()
+				}
+				own = append(own, time.Since(start))
+				if err != nil {
+					t.Errorf("Search failed: %v", err)
+					return
+				}
+				// Every result must be a node a reader can see in full
+				for i, result := range results {
+					if _, err := index.Vector(result.ID); err != nil {
+						t.Errorf("Search returned node %d: %v", result.ID, err)
+						return
+					}
+					if i > 0 && result.Distance < results[i-1].Distance {
+						t.Errorf("Results out of order: %v", results)
+						return
+					}
+				}
+			}
+			mu.Lock()
+			latencies = append(latencies, own...)
+			mu.Unlock()
+		}(int64(r))
+	}
+
+	wg.Wait()
+	return latencies
+}
+
+// TestInsertSearchStress checks that searches run alongside inserts, and
+// are not held back by them as they would be by a global write lock. Run
+// it with -race to check the reads for torn lists too.
+func TestInsertSearchStress(t *testing.T) {
+	d := 2 * time.Second
+	if testing.Short() {
+		d = 200 * time.Millisecond
+	}
+	const dim = 256
+	p99 := func(writeLock *sync.RWMutex) time.Duration {
+		index := NewHNSW(Config{M: 16, EfConstruction: 200, Dimension: dim, Seed: 1})
+		for _, v := range generateRandomVectors(500, dim, 1) {
+			index.Add(v)
+		}
+		latencies := runInsertSearchStress(t, index, d, writeLock)
+		if len(latencies) == 0 {
+			t.Fatal("No search completed")
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		return latencies[len(latencies)*99/100]
+	}
+
+	concurrent := p99(nil)
+	locked := p99(&sync.RWMutex{})
+	t.Logf("search P99 during inserts: %v, with a global write lock %v", concurrent, locked)
+	if !testing.Short() && !raceEnabled && concurrent >= locked {
+		t.Errorf("Search P99 %v during inserts is no better than %v with a global write lock", concurrent, locked)
+	}
+}
+
 func TestConcurrentInsert(t *testing.T) {
 	config := Config{
 		M:              16,
@@ -329,6 +444,126 @@ func TestConcurrentInsertAndSearch(t *testing.T) {
 	t.Logf("Successfully performed concurrent inserts and searches. Final index size: %d", index.Len())
 }
 
+// TestSnapshotSearchDuringInsert stresses lock-free searches against
+// concurrent inserts; run with -race. Every returned ID must refer to a node
+// that exists and results must stay sorted by distance.
+func TestSnapshotSearchDuringInsert(t *testing.T) {
+	const dim = 32
+	index := NewHNSW(Config{M: 8, EfConstruction: 64, Dimension: dim, Seed: 7})
+
+	randomVector := func(r *rand.Rand) []float32 {
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = r.Float32()
+		}
+		return v
+	}
+
+	seed := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		index.Add(randomVector(seed))
+	}
+
+	var wg, inserters sync.WaitGroup
+	errs := make(chan error, 16)
+	done := make(chan struct{})
+
+	for w := 0; w < 3; w++ {
+		inserters.Add(1)
+		go func(w int) {
+			defer inserters.Done()
+			r := rand.New(rand.NewSource(int64(100 + w)))
+			for i := 0; i < 300; i++ {
+				if _, err := index.Add(randomVector(r)); err != nil {
+					errs <- fmt.Errorf("insert %d: %v", w, err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	for s := 0; s < 6; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(200 + s)))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				results, err := index.Search(randomVector(r), 10, 50)
+				if err != nil {
+					errs <- fmt.Errorf("search %d: %v", s, err)
+					return
+				}
+				size := index.Len()
+				for i, res := range results {
+					if res.ID < 0 || res.ID >= size {
+						errs <- fmt.Errorf("search %d: result ID %d outside [0, %d)", s, res.ID, size)
+						return
+					}
+					if i > 0 && res.Distance < results[i-1].Distance {
+						errs <- fmt.Errorf("search %d: results not sorted", s)
+						return
+					}
+				}
+			}
+		}(s)
+	}
+
+	// Stop the searchers once all inserts are in
+	inserters.Wait()
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if index.Len() != 200+3*300 {
+		t.Errorf("Expected %d nodes, got %d", 200+3*300, index.Len())
+	}
+}
+
+// TestSearchDuringLinking searches for a node while it is being inserted,
+// each time its neighbors have linked back to it on a layer: a search that
+// reaches it must not stall there for want of its lists on lower layers
+func TestSearchDuringLinking(t *testing.T) {
+	vectors := generateRandomVectors(501, 16, 13)
+	index := NewHNSW(Config{Dimension: 16, M: 8, EfConstruction: 100, Seed: 13})
+	for _, v := range vectors[:500] {
+		index.Add(v)
+	}
+	if index.maxLevel < 1 {
+		t.Fatalf("index has no upper layer")
+	}
+
+	// Publish a node on the upper layers, as add does, then link it
+	index.globalLock.Lock()
+	id := len(index.nodes)
+	index.storeVector(id, vectors[500])
+	node := newNode(id, int(index.maxLevel), index.nodeArena())
+	index.nodes = append(index.nodes, node)
+	index.publish()
+	index.globalLock.Unlock()
+
+	var levels []int
+	afterLinkBack = func(level int) {
+		levels = append(levels, level)
+		results, err := index.Search(vectors[500], 10, 100)
+		if err != nil || len(results) != 10 {
+			t.Errorf("search after linking layer %d = %d results, %v; want 10", level, len(results), err)
+		}
+	}
+	defer func() { afterLinkBack = nil }()
+	index.insert(node)
+	if len(levels) != node.level+1 {
+		t.Errorf("linked back on layers %v, want 0 to %d", levels, node.level)
+	}
+}
+
 // ==================== Data Isolation Tests ====================
 
 func TestVectorIsolation(t *testing.T) {
@@ -369,6 +604,53 @@ func TestVectorIsolation(t *testing.T) {
 	t.Log("Vector isolation test passed")
 }
 
+func TestDistancesTo(t *testing.T) {
+	for name, distFunc := range map[string]DistanceFunc{
+		"L2":     L2Distance,
+		"Cosine": CosineDistance,
+	} {
+		t.Run(name, func(t *testing.T) {
+			index := NewHNSW(Config{M: 8, EfConstruction: 50, Dimension: 16, DistanceFunc: distFunc})
+			vectors := generateRandomVectors(100, 16, 1)
+			for _, v := range vectors {
+				if _, err := index.Add(v); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+
+			query := generateRandomVectors(1, 16, 2)[0]
+			ids := []int{42, 0, 99, -1, 100, 42}
+			distances, err := index.DistancesTo(query, ids)
+			if err != nil {
+				t.Fatalf("DistancesTo failed: %v", err)
+			}
+			if len(distances) != len(ids) {
+				t.Fatalf("Expected %d distances, got %d", len(ids), len(distances))
+			}
+			for i, id := range ids {
+				if id < 0 || id >= len(vectors) {
+					if !math.IsNaN(float64(distances[i])) {
+						t.Errorf("ID %d: expected NaN for a missing node, got %f", id, distances[i])
+					}
+					continue
+				}
+				if want := distFunc(query, vectors[id]); distances[i] != want {
+					t.Errorf("ID %d: expected %f, got %f", id, want, distances[i])
+				}
+			}
+		})
+	}
+
+	index := NewHNSW(Config{Dimension: 4})
+	if _, err := index.DistancesTo(make([]float32, 3), []int{0}); err != ErrDimensionMismatch {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	distances, err := index.DistancesTo(make([]float32, 4), []int{0})
+	if err != nil || len(distances) != 1 || !math.IsNaN(float64(distances[0])) {
+		t.Errorf("Empty index: expected [NaN], got %v, %v", distances, err)
+	}
+}
+
 // ==================== Edge Case Tests ====================
 
 func TestDimensionMismatch(t *testing.T) {
@@ -544,7 +826,7 @@ func bruteForceSearch(query []float32, vectors [][]float32, k int) []SearchResul
 	results := make([]SearchResult, len(vectors))
 	for i, vec := range vectors {
 		dist := L2Distance(query, vec)
-		results[i] = SearchResult{ID: i, Distance: dist}
+		results[i] = SearchResult{ID: i, Distance: dist, ExternalID: uint64(i)}
 	}
 
 	// Sort
@@ -806,3 +1088,320 @@ func TestCosineDistance(t *testing.T) {
 		t.Errorf("Cosine distance of opposite vectors should be ~2, got %f", dist)
 	}
 }
+
+func TestSearchKBounds(t *testing.T) {
+	build := func(n int) *HNSWIndex {
+		index := NewHNSW(Config{Dimension: 4, M: 4, Seed: 1})
+		for i := 0; i < n; i++ {
+			if _, err := index.Add([]float32{float32(i), 0, 0, 0}); err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+		}
+		return index
+	}
+	query := []float32{0, 0, 0, 0}
+
+	searchers := map[string]func(*HNSWIndex, int) ([]SearchResult, error){
+		"Search": func(h *HNSWIndex, k int) ([]SearchResult, error) {
+			return h.Search(query, k, 0)
+		},
+		"SearchSmallEf": func(h *HNSWIndex, k int) ([]SearchResult, error) {
+			return h.Search(query, k, 1)
+		},
+		"SearchWithAdaptiveEf": func(h *HNSWIndex, k int) ([]SearchResult, error) {
+			return h.SearchWithAdaptiveEf(query, k)
+		},
+	}
+
+	tests := []struct {
+		name    string
+		n       int
+		k       int
+		want    int
+		wantErr error
+	}{
+		{"empty index", 0, 5, 0, ErrEmptyIndex},
+		{"k zero", 10, 0, 0, ErrInvalidK},
+		{"k negative", 10, -3, 0, ErrInvalidK},
+		{"single node", 1, 1, 1, nil},
+		{"single node k > n", 1, 10, 1, nil},
+		{"k == n", 50, 50, 50, nil},
+		{"k == n+1", 50, 51, 50, nil},
+		{"k much larger than n", 50, 100000, 50, nil},
+	}
+
+	for name, search := range searchers {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				results, err := search(build(tt.n), tt.k)
+				if err != tt.wantErr {
+					t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+				}
+				if len(results) != tt.want {
+					t.Fatalf("Expected %d results, got %d", tt.want, len(results))
+				}
+				for i := 1; i < len(results); i++ {
+					if results[i].Distance < results[i-1].Distance {
+						t.Fatalf("Results not sorted at %d", i)
+					}
+				}
+			})
+		}
+	}
+}
+
+// greedySearchReference is the search algorithm as it was before
+// SearchParams: a greedy descent with ef=1 above layer 0
+func greedySearchReference(h *HNSWIndex, query []float32, k, ef int) []SearchResult {
+	view := h.snapshot()
+	currentNearest := int(view.entryPoint)
+	for lc := int(view.maxLevel); lc > 0; lc-- {
+		nearest := h.searchLayer(view.nodes, query, currentNearest, 1, lc)
+		if len(nearest) > 0 {
+			currentNearest = nearest[0].ID
+		}
+	}
+	candidates := h.searchLayer(view.nodes, query, currentNearest, ef, 0)
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return h.withExternalIDs(candidates)
+}
+
+func TestSearchParamsDefaultUnchanged(t *testing.T) {
+	vectors := generateClusteredVectors(3000, 32, 20, 0.05, 7)
+	index := NewHNSW(Config{Dimension: 32, M: 16, EfConstruction: 100})
+	for _, v := range vectors {
+		if _, err := index.Add(v); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	queries := generateClusteredVectors(100, 32, 20, 0.05, 8)
+	for _, ef := range []int{10, 50, 200} {
+		for i, q := range queries {
+			want := greedySearchReference(index, q, 10, ef)
+
+			variants := map[string]SearchParams{
+				"Zero":     {EfBase: ef},
+				"Explicit": {EfBase: ef, EfUpperLayers: 1},
+			}
+			got, err := index.Search(q, 10, ef)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("ef=%d query %d: Search differs from greedy reference\ngot  %v\nwant %v", ef, i, got, want)
+			}
+			for name, params := range variants {
+				got, err := index.SearchWithParams(q, 10, params)
+				if err != nil {
+					t.Fatalf("SearchWithParams failed: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("ef=%d query %d %s: SearchWithParams differs from greedy reference", ef, i, name)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchParamsWideUpperLayers(t *testing.T) {
+	vectors := generateClusteredVectors(3000, 32, 20, 0.05, 9)
+	index := NewHNSW(Config{Dimension: 32, M: 8, EfConstruction: 100})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+
+	queries := generateClusteredVectors(200, 32, 20, 0.05, 10)
+	var narrow, wide float64
+	for _, q := range queries {
+		truth := bruteForceSearch(q, vectors, 10)
+
+		results, err := index.SearchWithParams(q, 10, SearchParams{EfBase: 10})
+		if err != nil {
+			t.Fatalf("SearchWithParams failed: %v", err)
+		}
+		narrow += calculateRecall(results, truth)
+
+		results, err = index.SearchWithParams(q, 10, SearchParams{EfBase: 10, EfUpperLayers: 16})
+		if err != nil {
+			t.Fatalf("SearchWithParams failed: %v", err)
+		}
+		if len(results) != 10 {
+			t.Fatalf("Expected 10 results, got %d", len(results))
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].Distance < results[i-1].Distance {
+				t.Fatalf("Results not sorted by distance: %v", results)
+			}
+		}
+		wide += calculateRecall(results, truth)
+	}
+
+	narrow /= float64(len(queries))
+	wide /= float64(len(queries))
+	t.Logf("Recall@10 with EfBase=10: EfUpperLayers=1 %.3f, EfUpperLayers=16 %.3f", narrow, wide)
+	if wide < narrow {
+		t.Errorf("Wider upper-layer beam lowered recall: %.3f < %.3f", wide, narrow)
+	}
+}
+
+func TestSearchWithFilterSelective(t *testing.T) {
+	const n, k = 5000, 10
+	vectors := generateRandomVectors(n, 16, 11)
+	index := NewHNSW(Config{Dimension: 16, M: 16, EfConstruction: 100, Seed: 11})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+
+	// 1% of the nodes match; the baseline is an index of just those
+	allow := func(id int) bool { return id%100 == 7 }
+	var subset [][]float32
+	subsetIndex := NewHNSW(Config{Dimension: 16, M: 16, EfConstruction: 100, Seed: 11})
+	for id, v := range vectors {
+		if allow(id) {
+			subset = append(subset, v)
+			subsetIndex.Add(v)
+		}
+	}
+
+	queries := generateRandomVectors(100, 16, 12)
+	var filtered, baseline float64
+	for _, q := range queries {
+		results, err := index.SearchWithFilter(q, k, 0, allow)
+		if err != nil {
+			t.Fatalf("SearchWithFilter failed: %v", err)
+		}
+		if len(results) != k {
+			t.Fatalf("Expected %d results, got %d", k, len(results))
+		}
+		for i, r := range results {
+			if !allow(r.ID) {
+				t.Fatalf("Result %d is not allowed", r.ID)
+			}
+			if i > 0 && r.Distance < results[i-1].Distance {
+				t.Fatalf("Results not sorted by distance: %v", results)
+			}
+			// Map to the subset's IDs to compare with its ground truth
+			results[i].ID = r.ID / 100
+		}
+		truth := bruteForceSearch(q, subset, k)
+		filtered += calculateRecall(results, truth)
+
+		subsetResults, err := subsetIndex.Search(q, k, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		baseline += calculateRecall(subsetResults, truth)
+	}
+
+	filtered /= float64(len(queries))
+	baseline /= float64(len(queries))
+	t.Logf("Recall@10 of a 1%% filter: filtered %.3f, subset index %.3f", filtered, baseline)
+	if filtered < baseline-0.05 {
+		t.Errorf("Filtered recall %.3f well below the subset index's %.3f", filtered, baseline)
+	}
+
+	// A filter matching nothing returns no results rather than an error
+	results, err := index.SearchWithFilter(queries[0], k, 0, func(int) bool { return false })
+	if err != nil || len(results) != 0 {
+		t.Errorf("SearchWithFilter matching nothing = %v, %v", results, err)
+	}
+}
+
+func TestCosineNormalization(t *testing.T) {
+	vectors := generateRandomVectors(300, 16, 31)
+	for _, v := range vectors[:100] {
+		for j := range v {
+			v[j] *= 50
+		}
+	}
+	zero := make([]float32, 16)
+	index := NewHNSW(Config{Dimension: 16, M: 8, EfConstruction: 64, DistanceFunc: CosineDistance, Seed: 31})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	zeroID, _ := index.Add(zero)
+
+	// Stored vectors have unit length, or stay zero
+	for id := range vectors {
+		v, _ := index.Vector(id)
+		var norm float64
+		for _, x := range v {
+			norm += float64(x) * float64(x)
+		}
+		if math.Abs(norm-1) > 1e-5 {
+			t.Fatalf("vector %d has squared norm %v", id, norm)
+		}
+	}
+	if v, _ := index.Vector(zeroID); fmt.Sprint(v) != fmt.Sprint(zero) {
+		t.Errorf("zero vector stored as %v", v)
+	}
+
+	// Distances are the cosine distances of the original vectors, whatever
+	// the length of the query
+	query := generateRandomVectors(1, 16, 32)[0]
+	for j := range query {
+		query[j] *= 7
+	}
+	ids := []int{0, 150, zeroID}
+	distances, _ := index.DistancesTo(query, ids)
+	for i, id := range ids {
+		want := CosineDistance(query, append(vectors, zero)[id])
+		if math.Abs(float64(distances[i]-want)) > 1e-5 {
+			t.Errorf("distance to %d = %v, want %v", id, distances[i], want)
+		}
+	}
+	results, _ := index.Search(query, 10, 100)
+	truth, _ := index.BruteForceSearch(query, 10)
+	if calculateRecall(results, truth) < 0.9 {
+		t.Errorf("Search = %v, exact %v", results, truth)
+	}
+	if d, _ := index.DistancesTo(zero, []int{0}); d[0] != 1 {
+		t.Errorf("distance from a zero query = %v, want 1", d[0])
+	}
+}
+
+func TestAddBatch(t *testing.T) {
+	const k = 10
+	vectors := generateRandomVectors(2000, 16, 3)
+	index := NewHNSW(Config{Dimension: 16, M: 16, EfConstruction: 100, Seed: 3})
+
+	// From empty, in chunks, then mixed with Add
+	next := 0
+	for _, n := range []int{500, 700, 1} {
+		ids, err := index.AddBatch(vectors[next : next+n])
+		if err != nil {
+			t.Fatalf("AddBatch failed: %v", err)
+		}
+		for i, id := range ids {
+			if id != next+i {
+				t.Fatalf("vector %d got node %d", next+i, id)
+			}
+		}
+		next += n
+	}
+	for ; next < len(vectors); next++ {
+		if _, err := index.Add(vectors[next]); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if index.Len() != len(vectors) {
+		t.Fatalf("expected %d nodes, got %d", len(vectors), index.Len())
+	}
+
+	queries := generateRandomVectors(100, 16, 4)
+	groundTruth := computeGroundTruthParallel(index, queries, k)
+	if recall := recallAt(index, queries, groundTruth, k, 100); recall < 0.9 {
+		t.Errorf("recall %.3f after AddBatch, want >= 0.9", recall)
+	}
+
+	// A vector of the wrong dimension adds nothing
+	if _, err := index.AddBatch([][]float32{vectors[0], make([]float32, 3)}); err == nil {
+		t.Error("expected dimension error")
+	}
+	if index.Len() != len(vectors) {
+		t.Errorf("failed AddBatch added nodes: %d", index.Len())
+	}
+}
@@ -0,0 +1,475 @@
+package hnsw
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// ImportConfig describes the index file being imported and the expectations
+// it is checked against.
+type ImportConfig struct {
+	// Metric is the space the source index was built with: "l2", "ip" or
+	// "cosine". hnswlib files do not record it, so it is required there.
+	// FAISS files do; when set it must agree with the file.
+	Metric string
+
+	Dimension int // Expected dimensionality, 0 accepts the file's
+	M         int // Expected M, 0 accepts the file's
+}
+
+// importDistance translates a source metric to the distance function that
+// ranks results the same way
+func importDistance(metric string) (DistanceFunc, error) {
+	switch metric {
+	case "l2":
+		return L2Distance, nil
+	case "ip":
+		return InnerProductDistance, nil
+	case "cosine":
+		// hnswlib stores cosine vectors normalized and ranks by 1 - dot;
+		// CosineDistance ranks the same and also accepts raw queries
+		return CosineDistance, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown metric %q, want l2, ip or cosine", ErrInvalidParameter, metric)
+	}
+}
+
+// importedNode is a node as read from a foreign index file
+type importedNode struct {
+	vector    []float32
+	neighbors [][]int // Per level, bottom up
+}
+
+// buildImported checks the decoded graph against cfg and assembles an index
+// from it
+func buildImported(nodes []importedNode, m, efConstruction, dimension, entryPoint, maxLevel int, dist DistanceFunc, cfg ImportConfig) (*HNSWIndex, error) {
+	if cfg.Dimension > 0 && cfg.Dimension != dimension {
+		return nil, fmt.Errorf("%w: file has dimension %d, expected %d", ErrDimensionMismatch, dimension, cfg.Dimension)
+	}
+	if cfg.M > 0 && cfg.M != m {
+		return nil, fmt.Errorf("%w: file has M %d, expected %d", ErrInvalidParameter, m, cfg.M)
+	}
+
+	config := Config{
+		M:              m,
+		EfConstruction: max(efConstruction, m),
+		Dimension:      dimension,
+		DistanceFunc:   dist,
+	}
+	h, err := NewHNSWChecked(config)
+	if err != nil {
+		return nil, fmt.Errorf("imported index has unusable parameters: %w", err)
+	}
+
+	if len(nodes) > 0 {
+		if entryPoint < 0 || entryPoint >= len(nodes) || len(nodes[entryPoint].neighbors)-1 != maxLevel {
+			return nil, fmt.Errorf("%w: entry point %d at level %d does not match the graph", ErrUnsupportedFormat, entryPoint, maxLevel)
+		}
+		h.nodes = make([]*Node, len(nodes))
+		for i, n := range nodes {
+			h.storeVector(i, n.vector)
+			node := newNode(i, len(n.neighbors)-1, h.vectors)
+			for level, list := range n.neighbors {
+				for _, id := range list {
+					if id < 0 || id >= len(nodes) || len(nodes[id].neighbors) <= level {
+						return nil, fmt.Errorf("%w: node %d links to %d at level %d, which is not in the graph", ErrUnsupportedFormat, i, id, level)
+					}
+				}
+				node.SetConnections(level, list)
+			}
+			h.nodes[i] = node
+		}
+		h.entryPoint = int32(entryPoint)
+		h.maxLevel = int32(maxLevel)
+	}
+
+	h.globalLock.Lock()
+	h.publish()
+	h.globalLock.Unlock()
+	return h, nil
+}
+
+// ImportHNSWlib loads an index written by hnswlib's saveIndex (save_index
+// in Python), as produced on little-endian 64-bit machines with float32
+// vectors. Node IDs of the returned index are hnswlib's internal IDs; the
+// returned labels map each node ID to the label it was added with, to be
+// kept alongside the index as its external IDs.
+//
+// The file does not record its metric, so cfg.Metric is required. hnswlib
+// files carry no version either: one whose layout does not add up is
+// rejected with ErrUnsupportedFormat rather than guessed at, as are files
+// holding deleted elements.
+func ImportHNSWlib(path string, cfg ImportConfig) (*HNSWIndex, []int64, error) {
+	dist, err := importDistance(cfg.Metric)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read hnswlib index: %w", err)
+	}
+
+	r := &importReader{buf: data}
+	offsetLevel0 := r.u64()
+	maxElements := r.u64()
+	count := r.u64()
+	sizePerElement := r.u64()
+	labelOffset := r.u64()
+	offsetData := r.u64()
+	maxLevel := int32(r.u32())
+	entryPoint := int32(r.u32())
+	maxM := r.u64()
+	maxM0 := r.u64()
+	m := r.u64()
+	r.f64() // mult, the level multiplier
+	efConstruction := r.u64()
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("%w: hnswlib header: %v", ErrUnsupportedFormat, r.err)
+	}
+
+	// Every field below is derived from M and the vector size in all
+	// hnswlib releases; anything else is not a file we understand
+	dataSize := labelOffset - offsetData
+	switch {
+	case offsetLevel0 != 0:
+		return nil, nil, fmt.Errorf("%w: hnswlib level-0 offset %d, want 0", ErrUnsupportedFormat, offsetLevel0)
+	case m < 2 || maxM != m || maxM0 != 2*m:
+		return nil, nil, fmt.Errorf("%w: hnswlib M %d, maxM %d and maxM0 %d are inconsistent", ErrUnsupportedFormat, m, maxM, maxM0)
+	case count > maxElements:
+		return nil, nil, fmt.Errorf("%w: hnswlib element count %d exceeds capacity %d", ErrUnsupportedFormat, count, maxElements)
+	case offsetData != 4+4*maxM0 || labelOffset < offsetData || sizePerElement != labelOffset+8:
+		return nil, nil, fmt.Errorf("%w: hnswlib element layout (data at %d, label at %d, %d bytes) is not recognized",
+			ErrUnsupportedFormat, offsetData, labelOffset, sizePerElement)
+	case dataSize == 0 || dataSize%4 != 0:
+		return nil, nil, fmt.Errorf("%w: hnswlib vectors of %d bytes are not float32", ErrUnsupportedFormat, dataSize)
+	case count > uint64(len(data))/sizePerElement:
+		return nil, nil, fmt.Errorf("%w: hnswlib file is truncated", ErrUnsupportedFormat)
+	}
+	dimension := int(dataSize / 4)
+
+	n := int(count)
+	nodes := make([]importedNode, n)
+	labels := make([]int64, n)
+	level0 := r.bytes(int(count * sizePerElement))
+	for i := 0; i < n; i++ {
+		elem := level0[uint64(i)*sizePerElement : uint64(i+1)*sizePerElement]
+		if elem[2] != 0 {
+			return nil, nil, fmt.Errorf("%w: hnswlib element %d is marked deleted", ErrUnsupportedFormat, i)
+		}
+		links, err := readLinks(elem, int(maxM0))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: hnswlib element %d level 0: %v", ErrUnsupportedFormat, i, err)
+		}
+		nodes[i].neighbors = [][]int{links}
+		nodes[i].vector = decodeFloats(elem[offsetData:labelOffset])
+		labels[i] = int64(binary.LittleEndian.Uint64(elem[labelOffset:]))
+	}
+
+	// Upper levels follow as one block per element, sized by its level
+	sizeLinks := int(4 + 4*maxM)
+	for i := 0; i < n && r.err == nil; i++ {
+		size := int(r.u32())
+		if size%sizeLinks != 0 {
+			return nil, nil, fmt.Errorf("%w: hnswlib element %d has a %d-byte link block", ErrUnsupportedFormat, i, size)
+		}
+		block := r.bytes(size)
+		for level := 0; level < size/sizeLinks && r.err == nil; level++ {
+			links, err := readLinks(block[level*sizeLinks:(level+1)*sizeLinks], int(maxM))
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: hnswlib element %d level %d: %v", ErrUnsupportedFormat, i, level+1, err)
+			}
+			nodes[i].neighbors = append(nodes[i].neighbors, links)
+		}
+	}
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("%w: hnswlib link lists: %v", ErrUnsupportedFormat, r.err)
+	}
+	if r.pos != len(data) {
+		return nil, nil, fmt.Errorf("%w: hnswlib file has %d trailing bytes", ErrUnsupportedFormat, len(data)-r.pos)
+	}
+
+	h, err := buildImported(nodes, int(m), int(efConstruction), dimension, int(entryPoint), int(maxLevel), dist, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, labels, nil
+}
+
+// readLinks decodes an hnswlib link list: a 16-bit count in a 32-bit
+// header, then up to capacity 32-bit IDs
+func readLinks(block []byte, capacity int) ([]int, error) {
+	n := int(binary.LittleEndian.Uint16(block))
+	if n > capacity {
+		return nil, fmt.Errorf("%d links exceed the capacity of %d", n, capacity)
+	}
+	links := make([]int, n)
+	for j := range links {
+		links[j] = int(binary.LittleEndian.Uint32(block[4+4*j:]))
+	}
+	return links, nil
+}
+
+// FAISS index type tags
+const (
+	faissHNSWFlat = "IHNf"
+	faissIDMap    = "IxMp"
+	faissIDMap2   = "IxM2"
+	faissFlatL2   = "IxF2"
+	faissFlatIP   = "IxFI"
+)
+
+// FAISS metric types
+const (
+	faissMetricIP = 0
+	faissMetricL2 = 1
+)
+
+// ImportFAISS loads an IndexHNSWFlat written by faiss.write_index on a
+// little-endian machine, optionally wrapped in an IndexIDMap. Node IDs of
+// the returned index are FAISS's sequential IDs; the returned labels map
+// each to the ID given with add_with_ids, or to itself without an ID map.
+//
+// Support is best effort: other index types, including the quantized HNSW
+// variants, and metrics other than L2 and inner product are rejected with
+// ErrUnsupportedFormat.
+func ImportFAISS(path string, cfg ImportConfig) (*HNSWIndex, []int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read faiss index: %w", err)
+	}
+	r := &importReader{buf: data}
+
+	// An IndexIDMap wraps the HNSW index and appends its IDs after it
+	tag := r.fourcc()
+	idMap := tag == faissIDMap || tag == faissIDMap2
+	if idMap {
+		r.faissHeader()
+		tag = r.fourcc()
+	}
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("%w: faiss header: %v", ErrUnsupportedFormat, r.err)
+	}
+	if tag != faissHNSWFlat {
+		return nil, nil, fmt.Errorf("%w: faiss index type %q, only IndexHNSWFlat (%q) can be imported", ErrUnsupportedFormat, tag, faissHNSWFlat)
+	}
+
+	dimension, ntotal, metric := r.faissHeader()
+
+	// The HNSW structure: level probabilities, cumulative neighbor counts
+	// per level, each node's level count, offsets into the flat neighbor
+	// table, and the table itself
+	r.skipVector(8)
+	cum := r.i32s()
+	levels := r.i32s()
+	offsets := r.u64s()
+	neighbors := r.i32s()
+	entryPoint := int(r.i32())
+	maxLevel := int(r.i32())
+	efConstruction := int(r.i32())
+	r.i32() // efSearch
+	r.i32() // upper_beam
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("%w: faiss HNSW structure: %v", ErrUnsupportedFormat, r.err)
+	}
+
+	storage := r.fourcc()
+	storageDim, storageTotal, storageMetric := r.faissHeader()
+	vectors := r.f32s()
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("%w: faiss storage: %v", ErrUnsupportedFormat, r.err)
+	}
+	if (storage != faissFlatL2 || storageMetric != faissMetricL2) && (storage != faissFlatIP || storageMetric != faissMetricIP) {
+		return nil, nil, fmt.Errorf("%w: faiss storage type %q, want a flat L2 or inner product index", ErrUnsupportedFormat, storage)
+	}
+	var ids []int64
+	if idMap {
+		ids = r.i64s()
+		if r.err != nil || len(ids) != ntotal {
+			return nil, nil, fmt.Errorf("%w: faiss ID map does not cover %d vectors", ErrUnsupportedFormat, ntotal)
+		}
+	}
+	if r.pos != len(data) {
+		return nil, nil, fmt.Errorf("%w: faiss file has %d trailing bytes", ErrUnsupportedFormat, len(data)-r.pos)
+	}
+
+	var metricName string
+	switch metric {
+	case faissMetricL2:
+		metricName = "l2"
+	case faissMetricIP:
+		metricName = "ip"
+	default:
+		return nil, nil, fmt.Errorf("%w: faiss metric type %d, only L2 and inner product can be imported", ErrUnsupportedFormat, metric)
+	}
+	if cfg.Metric != "" && cfg.Metric != metricName {
+		return nil, nil, fmt.Errorf("%w: file uses metric %q, expected %q", ErrInvalidParameter, metricName, cfg.Metric)
+	}
+	dist, _ := importDistance(metricName)
+
+	switch {
+	case storageDim != dimension || storageTotal != ntotal || storageMetric != metric:
+		return nil, nil, fmt.Errorf("%w: faiss storage (d=%d, n=%d) does not match the index (d=%d, n=%d)",
+			ErrUnsupportedFormat, storageDim, storageTotal, dimension, ntotal)
+	case dimension <= 0 || len(vectors) != dimension*ntotal:
+		return nil, nil, fmt.Errorf("%w: faiss storage holds %d floats for %d vectors of dimension %d",
+			ErrUnsupportedFormat, len(vectors), ntotal, dimension)
+	case len(cum) < 3 || cum[0] != 0 || cum[1] != 2*(cum[2]-cum[1]):
+		return nil, nil, fmt.Errorf("%w: faiss neighbor counts per level %v are not those of IndexHNSWFlat", ErrUnsupportedFormat, cum)
+	case len(levels) != ntotal || len(offsets) != ntotal+1 || offsets[ntotal] != uint64(len(neighbors)):
+		return nil, nil, fmt.Errorf("%w: faiss HNSW tables do not cover %d vectors", ErrUnsupportedFormat, ntotal)
+	}
+	m := int(cum[2] - cum[1])
+
+	nodes := make([]importedNode, ntotal)
+	for i := range nodes {
+		nodeLevels := int(levels[i])
+		if nodeLevels < 1 || nodeLevels >= len(cum) || offsets[i+1]-offsets[i] != uint64(cum[nodeLevels]) {
+			return nil, nil, fmt.Errorf("%w: faiss node %d has %d levels and %d neighbor slots",
+				ErrUnsupportedFormat, i, nodeLevels, offsets[i+1]-offsets[i])
+		}
+		nodes[i].vector = vectors[i*dimension : (i+1)*dimension]
+		nodes[i].neighbors = make([][]int, nodeLevels)
+		for level := range nodes[i].neighbors {
+			// Each level's slots are filled from the front; -1 ends the list
+			var links []int
+			for _, id := range neighbors[offsets[i]+uint64(cum[level]) : offsets[i]+uint64(cum[level+1])] {
+				if id < 0 {
+					break
+				}
+				links = append(links, int(id))
+			}
+			nodes[i].neighbors[level] = links
+		}
+	}
+
+	h, err := buildImported(nodes, m, efConstruction, dimension, entryPoint, maxLevel, dist, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ids == nil {
+		ids = make([]int64, ntotal)
+		for i := range ids {
+			ids[i] = int64(i)
+		}
+	}
+	return h, ids, nil
+}
+
+// importReader decodes little-endian values from a file image. The first
+// read past the end sets err; later reads return zero values.
+type importReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+// bytes returns the next n bytes without copying
+func (r *importReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf)-r.pos {
+		r.err = fmt.Errorf("unexpected end of file at offset %d", r.pos)
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *importReader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *importReader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *importReader) i32() int32 { return int32(r.u32()) }
+
+func (r *importReader) f64() float64 { return math.Float64frombits(r.u64()) }
+
+func (r *importReader) fourcc() string { return string(r.bytes(4)) }
+
+// vectorLen reads the element count of a FAISS vector of width-byte
+// elements, checking that the elements fit in the rest of the file
+func (r *importReader) vectorLen(width int) int {
+	n := r.u64()
+	if r.err == nil && n > uint64(len(r.buf)-r.pos)/uint64(width) {
+		r.err = fmt.Errorf("vector of %d elements at offset %d runs past the end of file", n, r.pos)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (r *importReader) skipVector(width int) {
+	r.bytes(r.vectorLen(width) * width)
+}
+
+func (r *importReader) i32s() []int32 {
+	b := r.bytes(r.vectorLen(4) * 4)
+	out := make([]int32, len(b)/4)
+	for i := range out {
+		out[i] = int32(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return out
+}
+
+func (r *importReader) u64s() []uint64 {
+	b := r.bytes(r.vectorLen(8) * 8)
+	out := make([]uint64, len(b)/8)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint64(b[8*i:])
+	}
+	return out
+}
+
+func (r *importReader) i64s() []int64 {
+	u := r.u64s()
+	out := make([]int64, len(u))
+	for i, v := range u {
+		out[i] = int64(v)
+	}
+	return out
+}
+
+func (r *importReader) f32s() []float32 {
+	return decodeFloats(r.bytes(r.vectorLen(4) * 4))
+}
+
+// faissHeader reads the header every FAISS index starts with and returns
+// its dimension, vector count and metric type
+func (r *importReader) faissHeader() (dimension, ntotal, metric int) {
+	dimension = int(r.i32())
+	ntotal = int(int64(r.u64()))
+	r.u64() // Unused fields kept for compatibility
+	r.u64()
+	r.bytes(1) // is_trained
+	metric = int(r.i32())
+	if metric > faissMetricL2 {
+		r.bytes(4) // metric_arg
+	}
+	if r.err == nil && (dimension < 0 || ntotal < 0) {
+		r.err = fmt.Errorf("negative dimension %d or vector count %d", dimension, ntotal)
+	}
+	return dimension, ntotal, metric
+}
+
+// decodeFloats copies little-endian float32 values out of b
+func decodeFloats(b []byte) []float32 {
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return out
+}
@@ -1,19 +1,27 @@
 package hnsw
 
+// afterLinkBack, when set by tests, runs during an insert after neighbors
+// linked back to the new node on level
+var afterLinkBack func(level int)
+
 // insert handles the insertion of a new node into the HNSW index.
+//
+// The new node must already be part of a published view. Neighbor lists are
+// updated copy-on-write under each node's own lock, so searches running
+// concurrently keep traversing the previous lists without blocking.
 func (h *HNSWIndex) insert(newNode *Node) {
-	h.globalLock.RLock()
-	ep := int(h.entryPoint)
-	maxLvl := int(h.maxLevel)
-	h.globalLock.RUnlock()
+	view := h.snapshot()
+	ep := int(view.entryPoint)
+	maxLvl := int(view.maxLevel)
 
 	newNodeLevel := newNode.Level()
 	newNodeID := newNode.ID()
+	vector := h.vectorOf(newNodeID)
 
 	// Phase 1: From top layer to newNodeLevel+1, use greedy search to find entry point
 	currentNearest := ep
 	for lc := maxLvl; lc > newNodeLevel; lc-- {
-		nearest := h.searchLayer(newNode.Vector(), currentNearest, 1, lc)
+		nearest := h.searchLayer(view.nodes, vector, currentNearest, 1, lc)
 		if len(nearest) == 0 {
 			// Theoretically won't happen, but add protection
 			break
@@ -21,10 +29,15 @@ func (h *HNSWIndex) insert(newNode *Node) {
 		currentNearest = nearest[0].ID
 	}
 
-	// Phase 2: From newNodeLevel to layer 0, establish connections
-	for lc := min(newNodeLevel, maxLvl); lc >= 0; lc-- {
+	// Phase 2: From newNodeLevel to layer 0, establish the new node's
+	// connections. Neighbors link back only once it has them on every layer:
+	// a search reaching it on an upper layer continues from it on the layers
+	// below, where it would find no neighbors yet.
+	top := min(newNodeLevel, maxLvl)
+	selected := make([][]SearchResult, top+1)
+	for lc := top; lc >= 0; lc-- {
 		// Search for nearest neighbors at current layer
-		candidates := h.searchLayer(newNode.Vector(), currentNearest, h.efConstruction, lc)
+		candidates := h.searchLayer(view.nodes, vector, currentNearest, h.efConstruction, lc)
 
 		// Select M neighbors (heuristic pruning)
 		m := h.Mmax
@@ -32,41 +45,18 @@ func (h *HNSWIndex) insert(newNode *Node) {
 			m = h.Mmax0
 		}
 
-		neighbors := h.selectNeighborsHeuristic(newNode.Vector(), candidates, m)
-
-		// Add bidirectional connections
-		for _, neighbor := range neighbors {
-			// New node -> neighbor
-			newNode.AddConnection(lc, neighbor.ID)
-
-			// Neighbor -> new node
-			neighborNode := h.nodes[neighbor.ID]
-			neighborNode.AddConnection(lc, newNodeID)
-
-			// If neighbor's connection count exceeds limit, pruning is needed
-			maxConn := h.Mmax
-			if lc == 0 {
-				maxConn = h.Mmax0
-			}
-
-			if neighborNode.ConnectionCount(lc) > maxConn {
-				// Reselect neighbors
-				neighborConnections := neighborNode.GetConnections(lc)
-				candidatesForPrune := make([]SearchResult, len(neighborConnections))
+		neighbors := h.selectNeighborsHeuristic(view.nodes, vector, candidates, m)
 
-				for i, connID := range neighborConnections {
-					dist := h.distFunc(neighborNode.Vector(), h.nodes[connID].Vector())
-					candidatesForPrune[i] = SearchResult{ID: connID, Distance: dist}
-				}
-
-				prunedNeighbors := h.selectNeighborsHeuristic(neighborNode.Vector(), candidatesForPrune, maxConn)
-				prunedIDs := make([]int, len(prunedNeighbors))
-				for i, n := range prunedNeighbors {
-					prunedIDs[i] = n.ID
-				}
-				neighborNode.SetConnections(lc, prunedIDs)
-			}
+		// New node -> neighbors (concurrent inserts may already have linked
+		// to the new node, so append rather than overwrite)
+		neighborIDs := make([]int, len(neighbors))
+		for i, neighbor := range neighbors {
+			neighborIDs[i] = neighbor.ID
 		}
+		newNode.updateConnections(lc, func(conns []int) []int {
+			return append(conns, neighborIDs...)
+		})
+		selected[lc] = neighbors
 
 		// Update entry point for next layer
 		if len(neighbors) > 0 {
@@ -74,11 +64,64 @@ func (h *HNSWIndex) insert(newNode *Node) {
 		}
 	}
 
-	// If new node's level is higher, update global entry point and max level
-	if newNodeLevel > maxLvl {
+	// Phase 3: Neighbors -> new node
+	for lc, neighbors := range selected {
+		maxConn := h.Mmax
+		if lc == 0 {
+			maxConn = h.Mmax0
+		}
+		for _, neighbor := range neighbors {
+			h.linkAndPrune(view.nodes[neighbor.ID], lc, newNodeID, maxConn)
+		}
+		if afterLinkBack != nil {
+			afterLinkBack(lc)
+		}
+	}
+
+	// If new node's level is higher, update global entry point and max level.
+	// Compare with the latest view: a Delete since ours may have lowered it.
+	if newNodeLevel > int(h.snapshot().maxLevel) {
 		h.globalLock.Lock()
-		h.entryPoint = int32(newNodeID)
-		h.maxLevel = int32(newNodeLevel)
+		if int32(newNodeLevel) > h.maxLevel && !newNode.deleted.Load() {
+			h.entryPoint = int32(newNodeID)
+			h.maxLevel = int32(newNodeLevel)
+			h.publish()
+		}
 		h.globalLock.Unlock()
 	}
 }
+
+// linkAndPrune adds newNodeID to node's neighbor list at level, re-selecting
+// neighbors if the list exceeds maxConn. The whole read-modify-write runs
+// under the node's write lock; a list that already holds newNodeID is left
+// alone.
+func (h *HNSWIndex) linkAndPrune(node *Node, level, newNodeID, maxConn int) {
+	node.updateConnections(level, func(conns []int) []int {
+		if containsInt(conns, newNodeID) {
+			return conns
+		}
+
+		// Neighbor lists may reference nodes inserted after our view was
+		// taken, so resolve them against the latest one. Links to deleted
+		// nodes are dropped on the way.
+		nodes := h.snapshot().nodes
+		conns = append(live(nodes, conns), newNodeID)
+		if len(conns) <= maxConn {
+			return conns
+		}
+
+		vector := h.vectorOf(node.id)
+		candidatesForPrune := make([]SearchResult, len(conns))
+		for i, connID := range conns {
+			dist := h.distTo(vector, connID)
+			candidatesForPrune[i] = SearchResult{ID: connID, Distance: dist}
+		}
+
+		prunedNeighbors := h.selectNeighborsHeuristic(nodes, vector, candidatesForPrune, maxConn)
+		prunedIDs := make([]int, len(prunedNeighbors))
+		for i, n := range prunedNeighbors {
+			prunedIDs[i] = n.ID
+		}
+		return prunedIDs
+	})
+}
@@ -0,0 +1,313 @@
+package hnsw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+const (
+	// upperFileName stores the levels of all nodes and the lists of layers
+	// >= 1, for Mapped loading
+	upperFileName = "upper.adj"
+
+	upperMagic      = 0x41505556 // "VUPA"
+	upperVersion    = 1
+	upperHeaderSize = 16 // magic, version, numNodes, layers (uint32 each)
+)
+
+// writeUpperFile writes the level of each node, -1 for deleted ones, and
+// the lists of each layer above 0. After the header come numNodes int32
+// levels, then layers+1 uint64 offsets, where offset i-1 starts the block of
+// layer i and the last one is the file size. The block of a layer holds,
+// for each node not deleted whose level reaches it, in ID order, a uint32
+// count and as many int32 neighbor IDs. Like layer0.adj, the file is
+// written to a temporary name and renamed into place.
+func writeUpperFile(path string, nodes []*Node) error {
+	layers := 0
+	for _, node := range nodes {
+		if !node.deleted.Load() {
+			layers = max(layers, node.level)
+		}
+	}
+
+	buf := make([]byte, upperHeaderSize+4*len(nodes)+8*(layers+1))
+	binary.LittleEndian.PutUint32(buf[0:], upperMagic)
+	binary.LittleEndian.PutUint32(buf[4:], upperVersion)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(nodes)))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(layers))
+	for i, node := range nodes {
+		level := int32(node.level)
+		if node.deleted.Load() {
+			level = -1
+		}
+		binary.LittleEndian.PutUint32(buf[upperHeaderSize+4*i:], uint32(level))
+	}
+
+	offsets := upperHeaderSize + 4*len(nodes)
+	for layer := 1; layer <= layers; layer++ {
+		binary.LittleEndian.PutUint64(buf[offsets+8*(layer-1):], uint64(len(buf)))
+		for _, node := range nodes {
+			if node.deleted.Load() || node.level < layer {
+				continue
+			}
+			// Links to deleted nodes are not saved
+			ids := live(nodes, node.neighbors(layer))
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(ids)))
+			for _, id := range ids {
+				buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(id)))
+			}
+		}
+	}
+	binary.LittleEndian.PutUint64(buf[offsets+8*layers:], uint64(len(buf)))
+
+	return lanceio.WriteFileAtomic(path, buf, 0644)
+}
+
+// upperStore serves the node levels and upper-layer lists of upper.adj.
+type upperStore struct {
+	file    *os.File
+	data    []byte      // mapped file contents
+	offsets []int       // Block bounds, layer i from offsets[i-1] to offsets[i]
+	once    []sync.Once // Decoding of each layer, from 1
+	nodes   []*Node     // Nodes the layers are decoded into, as loaded
+}
+
+// openUpperStore maps path and validates its header against numNodes
+func openUpperStore(path string, numNodes int) (*upperStore, error) {
+	// Opened shareable so a later SaveToLance can replace the file while it
+	// is still mapped, as with layer0.adj
+	f, err := lanceio.OpenShared(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	data, err := mmapFile(f, int(info.Size()))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := &upperStore{file: f, data: data}
+	if err := s.parse(numNodes); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// parse reads the header and block offsets of the mapped file
+func (s *upperStore) parse(numNodes int) error {
+	if len(s.data) < upperHeaderSize {
+		return fmt.Errorf("upper layers file of %d bytes has no header", len(s.data))
+	}
+	magic := binary.LittleEndian.Uint32(s.data[0:])
+	version := binary.LittleEndian.Uint32(s.data[4:])
+	n := int(binary.LittleEndian.Uint32(s.data[8:]))
+	layers := int(binary.LittleEndian.Uint32(s.data[12:]))
+	start := upperHeaderSize + 4*n + 8*(layers+1)
+	switch {
+	case magic != upperMagic || version != upperVersion:
+		return fmt.Errorf("invalid upper layers header (magic 0x%08X, version %d)", magic, version)
+	case n != numNodes:
+		return fmt.Errorf("upper layers file has %d nodes, index has %d", n, numNodes)
+	case len(s.data) < start:
+		return fmt.Errorf("upper layers file size %d is below its %d-byte index", len(s.data), start)
+	}
+
+	s.offsets = make([]int, layers+1)
+	prev := start
+	for i := range s.offsets {
+		s.offsets[i] = int(binary.LittleEndian.Uint64(s.data[upperHeaderSize+4*n+8*i:]))
+		if s.offsets[i] < prev || s.offsets[i] > len(s.data) || i == 0 && s.offsets[i] != start {
+			return fmt.Errorf("upper layers file has offset %d at %d, outside [%d, %d]", i, s.offsets[i], prev, len(s.data))
+		}
+		prev = s.offsets[i]
+	}
+	if s.offsets[layers] != len(s.data) {
+		return fmt.Errorf("upper layers file size %d does not match its blocks ending at %d", len(s.data), s.offsets[layers])
+	}
+	s.once = make([]sync.Once, layers)
+	return nil
+}
+
+// level returns the level saved for node id, -1 if it was deleted
+func (s *upperStore) level(id int) int {
+	return int(int32(binary.LittleEndian.Uint32(s.data[upperHeaderSize+4*id:])))
+}
+
+// materialize decodes layer into the connections of the nodes, once. It
+// must be called before a list of the layer is read or replaced.
+func (s *upperStore) materialize(layer int) {
+	if layer < 1 || layer > len(s.once) {
+		return
+	}
+	s.once[layer-1].Do(func() {
+		if err := s.decode(layer); err != nil {
+			// Searches cannot surface I/O errors per neighbor list; the
+			// nodes left without one are dead ends on this layer
+			log.Printf("Warning: failed to read upper layer %d: %v", layer, err)
+		}
+	})
+}
+
+func (s *upperStore) decode(layer int) error {
+	block := s.data[s.offsets[layer-1]:s.offsets[layer]]
+	pos := 0
+	for id, node := range s.nodes {
+		if s.level(id) < layer {
+			continue
+		}
+		if pos+4 > len(block) {
+			return fmt.Errorf("block ends before the list of node %d", id)
+		}
+		count := int(binary.LittleEndian.Uint32(block[pos:]))
+		pos += 4
+		if count > (len(block)-pos)/4 {
+			return fmt.Errorf("list of node %d with %d neighbors overruns the block", id, count)
+		}
+		ids := make([]int, count)
+		for i := range ids {
+			ids[i] = int(int32(binary.LittleEndian.Uint32(block[pos:])))
+			if ids[i] < 0 || ids[i] >= len(s.nodes) {
+				return fmt.Errorf("node %d links to %d, outside [0, %d)", id, ids[i], len(s.nodes))
+			}
+			pos += 4
+		}
+		node.connections[layer].CompareAndSwap(nil, &ids)
+	}
+	return nil
+}
+
+// Close unmaps and closes the file.
+func (s *upperStore) Close() error {
+	err := munmapFile(s.data)
+	s.data = nil
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// mapRecordFile maps a record file of float32 slots, vectors.f32 or
+// vectors.bin, whose header is validated as by checkRecordFile
+func mapRecordFile(path, name string, magic, version uint32, numNodes, dimension, recordSize int) (*vectorFile, error) {
+	f, err := lanceio.OpenShared(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRecordFile(f, name, magic, version, numNodes, dimension, recordSize); err != nil {
+		f.Close()
+		return nil, err
+	}
+	data, err := mmapFile(f, recordFileHeaderSize+recordSize*numNodes)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &vectorFile{file: f, data: data, numNodes: numNodes, dimension: dimension}, nil
+}
+
+// loadMapped sets up the nodes of a Mapped load of the numNodes nodes
+// saved in baseDir, with vectors and lists served from its files. It
+// returns an error, having set up nothing, if they cannot be used.
+func (h *HNSWIndex) loadMapped(baseDir string, numNodes int, savedNormalized, segments bool, precision VectorPrecision) (err error) {
+	switch {
+	case h.sq8 != nil:
+		return errors.New("quantized vectors are not mapped")
+	case precision == Float16 && !h.binary:
+		return errors.New("vectors saved at float16 are not mapped")
+	case segments:
+		return errors.New("files of incremental saves are not mapped until the index is compacted")
+	case h.normalized && !savedNormalized:
+		return errors.New("saved vectors need normalizing")
+	case binary.NativeEndian.Uint16([]byte{1, 0}) != 1:
+		return errors.New("saved vectors are little-endian")
+	}
+
+	var closers []interface{ Close() error }
+	defer func() {
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+		}
+	}()
+
+	upper, err := openUpperStore(filepath.Join(baseDir, upperFileName), numNodes)
+	if err != nil {
+		return err
+	}
+	closers = append(closers, upper)
+	l0, err := openL0Store(filepath.Join(baseDir, l0FileName), numNodes, h.l0CacheSize)
+	if err != nil {
+		return err
+	}
+	closers = append(closers, l0)
+	if l0.data == nil {
+		return errors.New("layer0 file is not mapped")
+	}
+	var vectors *vectorFile
+	if h.binary {
+		vectors, err = mapRecordFile(filepath.Join(baseDir, binaryFileName), binaryFileName, binaryMagic, binaryVersion, numNodes, 32*h.dimension, 4*h.dimension)
+	} else {
+		vectors, err = mapRecordFile(filepath.Join(baseDir, vectorsFileName), vectorsFileName, vectorsMagic, vectorsVersion, numNodes, h.dimension, 4*h.dimension)
+	}
+	if err != nil {
+		return err
+	}
+	closers = append(closers, vectors)
+
+	// External IDs are the one column of nodes.lance read, in files that
+	// have them
+	nodeSchema := SchemaForNodes(h.dimension)
+	if h.binary {
+		nodeSchema = SchemaForQuantizedNodes(h.dimension)
+	}
+	fields := nodeSchema.Fields()
+	schema := arrow.NewSchema([]arrow.Field{fields[0], fields[len(fields)-1]}, nil)
+	batch, err := readBatchFile(filepath.Join(baseDir, "nodes.lance"), 1, schema)
+	if err != nil {
+		return fmt.Errorf("read external IDs failed: %w", err)
+	}
+	h.loadExternalIDs(batch.Column(0).(*arrow.Int32Array), batch.Column(1))
+
+	// Records are little-endian float32 slots, laid out as the arena's
+	if numNodes > 0 {
+		slots := unsafe.Slice((*float32)(unsafe.Pointer(&vectors.data[recordFileHeaderSize])), numNodes*h.dimension)
+		h.vectors.mapSlots(slots, numNodes)
+	}
+
+	h.nodes = make([]*Node, numNodes)
+	h.deleted = 0
+	for id := range h.nodes {
+		level := upper.level(id)
+		if level < 0 {
+			h.nodes[id] = newNode(id, 0, nil)
+			h.nodes[id].deleted.Store(true)
+			h.deleted++
+			continue
+		}
+		node := newNode(id, level, h.vectors)
+		node.disk = l0
+		node.upper = upper
+		h.nodes[id] = node
+	}
+	upper.nodes = h.nodes
+
+	h.l0 = l0
+	h.upper = upper
+	h.vectorMap = vectors
+	return nil
+}
@@ -0,0 +1,209 @@
+package hnsw
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMappedSearchMatchesInMemory(t *testing.T) {
+	index, _, _ := buildSavedIndex(t, 500, 16)
+	index.Delete(3)
+	index.Delete(250)
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+
+	mapped := checkLoadedEqual(t, dir, index, WithGraphStorage(Mapped))
+	if mapped.GraphStorage() != Mapped {
+		t.Fatalf("Expected Mapped storage, got %v", mapped.GraphStorage())
+	}
+	for q, query := range generateRandomVectors(50, 16, 99) {
+		want, err := index.Search(query, 10, 50)
+		if err != nil {
+			t.Fatalf("In-memory search failed: %v", err)
+		}
+		got, err := mapped.Search(query, 10, 50)
+		if err != nil {
+			t.Fatalf("Mapped search failed: %v", err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("Query %d: expected %v, got %v", q, want, got)
+		}
+	}
+}
+
+func TestMappedBinary(t *testing.T) {
+	index := NewHNSW(Config{Dimension: 128, BinaryVectors: true, M: 8, EfConstruction: 32, Seed: 5})
+	rng := rand.New(rand.NewSource(5))
+	var vectors [][]uint64
+	for i := 0; i < 300; i++ {
+		v := []uint64{rng.Uint64(), rng.Uint64()}
+		vectors = append(vectors, v)
+		if _, err := index.AddBinary(v); err != nil {
+			t.Fatalf("AddBinary failed: %v", err)
+		}
+	}
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+
+	mapped := checkLoadedEqual(t, dir, index, WithGraphStorage(Mapped))
+	if mapped.GraphStorage() != Mapped {
+		t.Fatalf("Expected Mapped storage, got %v", mapped.GraphStorage())
+	}
+	want, _ := index.SearchBinary(vectors[7], 5, 50)
+	got, _ := mapped.SearchBinary(vectors[7], 5, 50)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestMappedAddCopiesOnWrite(t *testing.T) {
+	_, dir, _ := buildSavedIndex(t, 300, 8)
+	files := map[string][]byte{}
+	for _, name := range []string{vectorsFileName, l0FileName, upperFileName} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		files[name] = data
+	}
+
+	index, err := LoadHNSWFromLance(dir, WithGraphStorage(Mapped))
+	if err != nil {
+		t.Fatalf("Failed to load mapped index: %v", err)
+	}
+	extra := generateRandomVectors(100, 8, 123)
+	for i, vec := range extra {
+		if _, err := index.Add(vec); err != nil {
+			t.Fatalf("Failed to add vector %d: %v", i, err)
+		}
+	}
+	index.Delete(10)
+	for i, vec := range extra {
+		results, err := index.Search(vec, 1, 50)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if results[0].ID != 300+i {
+			t.Errorf("Expected node %d for added vector, got %d", 300+i, results[0].ID)
+		}
+	}
+	for name, data := range files {
+		now, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(now) != string(data) {
+			t.Errorf("%s changed by writes to the mapped index: %v", name, err)
+		}
+	}
+
+	// Saving whole replaces the files still mapped
+	if err := index.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	checkLoadedEqual(t, dir, index, WithGraphStorage(Mapped))
+	if err := index.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestMappedFallsBack(t *testing.T) {
+	index, dir, vectors := buildSavedIndex(t, 200, 8)
+	if err := os.Remove(filepath.Join(dir, upperFileName)); err != nil {
+		t.Fatalf("Failed to remove upper layers file: %v", err)
+	}
+	loaded := checkLoadedEqual(t, dir, index, WithGraphStorage(Mapped))
+	if loaded.GraphStorage() != InMemory {
+		t.Errorf("Expected fallback to InMemory, got %v", loaded.GraphStorage())
+	}
+
+	// Segments of incremental saves are not mapped either
+	index.Add(vectors[0])
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	loaded = checkLoadedEqual(t, dir, index, WithGraphStorage(Mapped))
+	if loaded.GraphStorage() != InMemory {
+		t.Errorf("Expected fallback to InMemory with segments, got %v", loaded.GraphStorage())
+	}
+}
+
+// buildLargeIndex saves an index of n random vectors whose graph links each
+// node to the next ones, which is quick to build at any size
+func buildLargeIndex(t *testing.T, n, dim int) string {
+	t.Helper()
+	index := NewHNSW(Config{Dimension: dim, M: 8, Seed: 1})
+	rng := rand.New(rand.NewSource(1))
+	vector := make([]float32, dim)
+	for id := 0; id < n; id++ {
+		for i := range vector {
+			vector[i] = rng.Float32()
+		}
+		index.storeVector(id, vector)
+		level := 0
+		if id%64 == 0 {
+			level = 1
+		}
+		node := newNode(id, level, index.vectors)
+		for layer, step := range []int{1, 64}[:level+1] {
+			var ids []int
+			for j := 1; j <= 8; j++ {
+				ids = append(ids, (id+j*step)%n)
+			}
+			node.connections[layer].Store(&ids)
+		}
+		index.nodes = append(index.nodes, node)
+	}
+	index.entryPoint, index.maxLevel = 0, 1
+	index.publish()
+
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	return dir
+}
+
+// TestMappedTimeToFirstSearch loads a 300 MB index both ways and checks
+// that the mapped one answers its first search far sooner
+func TestMappedTimeToFirstSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a 300 MB index")
+	}
+	const n, dim = 100000, 768
+	dir := buildLargeIndex(t, n, dim)
+	query := generateRandomVectors(1, dim, 3)[0]
+
+	firstSearch := func(mode GraphStorage) (time.Duration, []SearchResult) {
+		start := time.Now()
+		index, err := LoadHNSWFromLance(dir, WithGraphStorage(mode))
+		if err != nil {
+			t.Fatalf("Failed to load %v index: %v", mode, err)
+		}
+		defer index.Close()
+		results, err := index.Search(query, 10, 50)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		elapsed := time.Since(start)
+		if index.GraphStorage() != mode {
+			t.Fatalf("Expected %v storage, got %v", mode, index.GraphStorage())
+		}
+		return elapsed, results
+	}
+
+	full, want := firstSearch(InMemory)
+	mapped, got := firstSearch(Mapped)
+	t.Logf("time to first search: full load %v, mapped %v", full, mapped)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Mapped search %v, want %v", got, want)
+	}
+	if mapped > full/5 {
+		t.Errorf("Mapped first search took %v, full load %v", mapped, full)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package hnsw
+
+import (
+	"errors"
+	"os"
+)
+
+// errMmapUnsupported makes callers fall back to positional reads.
+var errMmapUnsupported = errors.New("mmap not supported on this platform")
+
+// mmapFile is not available on this platform.
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+// munmapFile is a no-op on this platform.
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -1,28 +1,61 @@
 package hnsw
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 // Node represents a single node in the HNSW graph.
 type Node struct {
-	id     int       // Unique identifier for the node.
-	vector []float32 // The vector associated with the node.
-	level  int       // The level of the node in the HNSW hierarchy.
-
-	connections [][]int // Connections to other nodes at different levels.
-
-	mu sync.RWMutex // Mutex for concurrent access to the node's connections.
+	id    int          // Unique identifier for the node, and its slot in arena.
+	arena *vectorArena // Holds the node's vector (immutable after creation), nil for a deleted placeholder.
+	level int          // The level of the node in the HNSW hierarchy.
+
+	// Connections to other nodes at different levels. Each level points at an
+	// immutable neighbor list: writers build a new slice and swap the pointer
+	// (copy-on-write), so searches read neighbor lists without locking.
+	connections []atomic.Pointer[[]int]
+
+	// disk serves the layer-0 list in TieredL0 mode until a writer replaces
+	// it with an in-memory copy. Nil for fully in-memory graphs.
+	disk *l0Store
+
+	// upper serves the lists of layers >= 1 in Mapped mode, decoding a
+	// whole layer into connections when it is first read. Nil otherwise.
+	upper *upperStore
+
+	// Set by HNSWIndex.Delete: the node is still traversed but never
+	// returned or linked to again
+	deleted atomic.Bool
+
+	// Set while add or AddBatch links the published node into the graph.
+	// Its neighbor lists may still be empty, so Delete does not make it the
+	// entry point.
+	linking atomic.Bool
+
+	// Set when the node's connections change or it is deleted. SaveToLance
+	// clears it, and saves the node again in the next segment if it is set
+	// anew; see saveSegment.
+	changed atomic.Bool
+
+	mu sync.Mutex // Serializes writers of the node's connections.
 }
 
+// NewNode returns a node holding a copy of vector in an arena of its own.
+// The nodes of an index share the index's arena instead.
 func NewNode(id int, vector []float32, level int) *Node {
-	connections := make([][]int, level+1)
-	for i := range connections {
-		connections[i] = make([]int, 0)
-	}
+	arena := newVectorArena(len(vector))
+	arena.set(id, vector)
+	return newNode(id, level, arena)
+}
+
+// newNode returns a node whose vector is in slot id of arena
+func newNode(id, level int, arena *vectorArena) *Node {
 	return &Node{
 		id:          id,
-		vector:      vector,
+		arena:       arena,
 		level:       level,
-		connections: connections,
+		connections: make([]atomic.Pointer[[]int], level+1),
 	}
 }
 
@@ -31,8 +64,12 @@ func (n *Node) ID() int {
 }
 
 func (n *Node) Vector() []float32 {
-	result := make([]float32, len(n.vector))
-	copy(result, n.vector)
+	var vector []float32
+	if n.arena != nil {
+		vector = n.arena.at(n.id)
+	}
+	result := make([]float32, len(vector))
+	copy(result, vector)
 	return result
 }
 
@@ -40,51 +77,79 @@ func (n *Node) Level() int {
 	return n.level
 }
 
-// GetConnections returns the connections of the node at the specified level.
-func (n *Node) GetConnections(level int) []int {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-
+// neighbors returns the current neighbor list at the given level without
+// copying. The returned slice is shared and must not be modified.
+func (n *Node) neighbors(level int) []int {
 	if level < 0 || level >= len(n.connections) {
 		return nil
 	}
+	if p := n.connections[level].Load(); p != nil {
+		return *p
+	}
+	if level == 0 && n.disk != nil {
+		return n.disk.neighbors(n.id)
+	}
+	if level > 0 && n.upper != nil {
+		n.upper.materialize(level)
+		if p := n.connections[level].Load(); p != nil {
+			return *p
+		}
+	}
+	return nil
+}
 
-	result := make([]int, len(n.connections[level]))
-	copy(result, n.connections[level])
+// GetConnections returns the connections of the node at the specified level.
+func (n *Node) GetConnections(level int) []int {
+	current := n.neighbors(level)
+	if current == nil {
+		if level < 0 || level >= len(n.connections) {
+			return nil
+		}
+		return []int{}
+	}
+	result := make([]int, len(current))
+	copy(result, current)
 	return result
 }
 
 // AddConnection adds a connection to another node at the specified level.
 func (n *Node) AddConnection(level int, neighborID int) {
-	n.mu.Lock()
-	defer n.mu.Unlock()
-
-	if level < 0 || level >= len(n.connections) {
-		return
-	}
-	n.connections[level] = append(n.connections[level], neighborID)
+	n.updateConnections(level, func(current []int) []int {
+		return append(current, neighborID)
+	})
 }
 
 // SetConnections sets the connections of the node at the specified level.
 func (n *Node) SetConnections(level int, neighbors []int) {
-	n.mu.Lock()
-	defer n.mu.Unlock()
+	n.updateConnections(level, func([]int) []int {
+		return neighbors
+	})
+}
 
+// updateConnections replaces the neighbor list at level with the result of
+// fn. fn receives a private copy of the current list it may modify freely;
+// the node's write lock is held while it runs, so read-modify-write updates
+// from concurrent inserts are not lost.
+func (n *Node) updateConnections(level int, fn func(current []int) []int) {
 	if level < 0 || level >= len(n.connections) {
 		return
 	}
 
-	n.connections[level] = make([]int, len(neighbors))
-	copy(n.connections[level], neighbors)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	current := n.neighbors(level)
+	working := make([]int, len(current), len(current)+1)
+	copy(working, current)
+
+	updated := fn(working)
+	published := make([]int, len(updated))
+	copy(published, updated)
+	n.connections[level].Store(&published)
+	n.changed.Store(true)
 }
 
 // ConnectionCount returns the number of connections at the specified level.
 func (n *Node) ConnectionCount(level int) int {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-
-	if level < 0 || level >= len(n.connections) {
-		return 0
-	}
-	return len(n.connections[level])
+	return len(n.neighbors(level))
 }
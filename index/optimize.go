@@ -0,0 +1,204 @@
+package hnsw
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// OptimizeOptions bounds a call to Optimize. The zero value visits every
+// node once on all cores.
+type OptimizeOptions struct {
+	MaxNodes   int           // Nodes to visit, 0 = the whole graph
+	TimeBudget time.Duration // Stop after about this long, 0 = no limit
+	Workers    int           // Parallel workers, default runtime.GOMAXPROCS(0)
+}
+
+// OptimizeReport describes what a call to Optimize did
+type OptimizeReport struct {
+	Visited      int           // Nodes whose neighbor lists were recomputed
+	Changed      int           // Visited nodes with at least one list changed
+	EdgesAdded   int           // Edges selected that the lists did not have, reverse links included
+	EdgesRemoved int           // Edges the heuristic dropped
+	Next         int           // Node the next call starts from
+	Nodes        int           // Graph size when the call started
+	Duration     time.Duration // Time spent
+}
+
+// EdgesChanged returns the number of edges added or removed, a rough measure
+// of how far the graph was from what the heuristic would choose now
+func (r OptimizeReport) EdgesChanged() int {
+	return r.EdgesAdded + r.EdgesRemoved
+}
+
+// optimizeBatch is how many nodes each worker handles between checks of the
+// time budget and ctx
+const optimizeBatch = 64
+
+// Optimize re-prunes neighbor lists that insertion order left suboptimal.
+// Nodes are visited round-robin, continuing where the previous call stopped:
+// for every level of a node, its current neighbors and their neighbors are
+// the candidates, and the same heuristic as Add selects the new list. Newly
+// selected neighbors are linked back as Add would link them.
+//
+// Work stops after MaxNodes nodes, once TimeBudget is spent, or when ctx is
+// done, so the pass can run incrementally during quiet periods. Searches and
+// inserts proceed concurrently: lists are swapped copy-on-write, and a list
+// an insert changed meanwhile is merged rather than overwritten. Concurrent
+// calls to Optimize are serialized.
+//
+// It returns ctx.Err() with the partial report if ctx stopped it.
+func (h *HNSWIndex) Optimize(ctx context.Context, opts OptimizeOptions) (OptimizeReport, error) {
+	h.optimizeMu.Lock()
+	defer h.optimizeMu.Unlock()
+
+	start := time.Now()
+	n := len(h.snapshot().nodes)
+	report := OptimizeReport{Nodes: n, Next: h.optimizeCursor}
+	if n == 0 {
+		return report, nil
+	}
+	if report.Next >= n {
+		report.Next = 0
+	}
+
+	total := opts.MaxNodes
+	if total <= 0 || total > n {
+		total = n
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var changed, added, removed atomic.Int64
+	var err error
+	for report.Visited < total {
+		if opts.TimeBudget > 0 && time.Since(start) >= opts.TimeBudget {
+			break
+		}
+
+		batch := min(workers*optimizeBatch, total-report.Visited)
+		first := report.Next
+		err = parallelFor(ctx, batch, workers, func(i int) {
+			a, r := h.optimizeNode((first + i) % n)
+			if a+r > 0 {
+				changed.Add(1)
+				added.Add(int64(a))
+				removed.Add(int64(r))
+			}
+		})
+		if err != nil {
+			break
+		}
+		report.Visited += batch
+		report.Next = (first + batch) % n
+	}
+
+	h.optimizeCursor = report.Next
+	report.Changed = int(changed.Load())
+	report.EdgesAdded = int(added.Load())
+	report.EdgesRemoved = int(removed.Load())
+	report.Duration = time.Since(start)
+	return report, err
+}
+
+// SetOptimizeCursor makes the next Optimize call start from node next, so a
+// pass interrupted by a restart can resume where it stopped. Out of range
+// values start from node 0.
+func (h *HNSWIndex) SetOptimizeCursor(next int) {
+	h.optimizeMu.Lock()
+	defer h.optimizeMu.Unlock()
+	h.optimizeCursor = max(next, 0)
+}
+
+// optimizeNode re-selects the neighbor lists of node id from its neighbors
+// and their neighbors, and reports how many edges were added and removed
+func (h *HNSWIndex) optimizeNode(id int) (added, removed int) {
+	nodes := h.snapshot().nodes
+	node := nodes[id]
+	if node.deleted.Load() {
+		return 0, 0
+	}
+	vector := h.vectorOf(id)
+
+	for lc := 0; lc <= node.level; lc++ {
+		maxConn := h.Mmax
+		if lc == 0 {
+			maxConn = h.Mmax0
+		}
+
+		current := node.neighbors(lc)
+		seen := map[int]bool{id: true}
+		candidates := make([]SearchResult, 0, len(current)*(maxConn+1))
+		// Deleted neighbors are looked through but not kept
+		consider := func(nb int) {
+			if nb < 0 || nb >= len(nodes) || seen[nb] || nodes[nb].deleted.Load() {
+				return
+			}
+			seen[nb] = true
+			candidates = append(candidates, SearchResult{ID: nb, Distance: h.distTo(vector, nb)})
+		}
+		for _, nb := range current {
+			consider(nb)
+		}
+		for _, nb := range current {
+			if nb >= 0 && nb < len(nodes) {
+				for _, nn := range nodes[nb].neighbors(lc) {
+					consider(nn)
+				}
+			}
+		}
+
+		selected := h.selectNeighborsHeuristic(nodes, vector, candidates, maxConn)
+		ids := make([]int, len(selected))
+		for i, s := range selected {
+			ids[i] = s.ID
+		}
+
+		var final []int
+		node.updateConnections(lc, func(latest []int) []int {
+			final = ids
+			if extra := missingFrom(latest, current); len(extra) > 0 {
+				// An insert linked to this node since current was read; keep
+				// its edges in the running
+				latestNodes := h.snapshot().nodes
+				merged := append([]SearchResult(nil), selected...)
+				for _, nb := range extra {
+					if !containsInt(ids, nb) && !latestNodes[nb].deleted.Load() {
+						merged = append(merged, SearchResult{ID: nb, Distance: h.distTo(vector, nb)})
+					}
+				}
+				merged = h.selectNeighborsHeuristic(latestNodes, vector, merged, maxConn)
+				final = make([]int, len(merged))
+				for i, s := range merged {
+					final[i] = s.ID
+				}
+			}
+			return final
+		})
+
+		for _, nb := range final {
+			if !containsInt(current, nb) {
+				added++
+				if nb < len(nodes) && !containsInt(nodes[nb].neighbors(lc), id) {
+					h.linkAndPrune(nodes[nb], lc, id, maxConn)
+				}
+			}
+		}
+		removed += len(missingFrom(current, final))
+	}
+	return added, removed
+}
+
+// missingFrom returns the elements of a that b does not contain
+func missingFrom(a, b []int) []int {
+	var out []int
+	for _, x := range a {
+		if !containsInt(b, x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
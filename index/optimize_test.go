@@ -0,0 +1,168 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clusteredVectors returns n vectors around the given number of cluster
+// centers, ordered cluster by cluster: an insert order that leaves each
+// cluster linked to the rest of the graph mostly through its first nodes
+func clusteredVectors(n, dim, clusters int, spread float64, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	centers := generateRandomVectors(clusters, dim, seed+100)
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		center := centers[i*clusters/n]
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = center[j] + float32(rng.NormFloat64()*spread)
+		}
+	}
+	return vectors
+}
+
+func recallAt(index *HNSWIndex, queries [][]float32, groundTruth [][]SearchResult, k, ef int) float64 {
+	var recall float64
+	for i, q := range queries {
+		results, _ := index.Search(q, k, ef)
+		recall += calculateRecall2(groundTruth[i], results)
+	}
+	return recall / float64(len(queries))
+}
+
+func TestOptimizeImprovesSortedInsertRecall(t *testing.T) {
+	const k, ef = 10, 10
+	vectors := clusteredVectors(6000, 16, 40, 0.1, 2)
+	index := NewHNSW(Config{Dimension: 16, M: 8, EfConstruction: 40, Seed: 2})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+
+	// Queries next to stored vectors, across all clusters
+	rng := rand.New(rand.NewSource(4))
+	queries := make([][]float32, 300)
+	for i := range queries {
+		queries[i] = append([]float32(nil), vectors[rng.Intn(len(vectors))]...)
+		queries[i][0] += 0.01
+	}
+	groundTruth := computeGroundTruthParallel(index, queries, k)
+	before := recallAt(index, queries, groundTruth, k, ef)
+
+	// Two rounds over the graph in bounded increments
+	var edges int
+	for i := 0; i < 4; i++ {
+		report, err := index.Optimize(context.Background(), OptimizeOptions{MaxNodes: 3000, Workers: 1})
+		if err != nil {
+			t.Fatalf("Optimize failed: %v", err)
+		}
+		if report.Visited != 3000 || report.Next != (i+1)*3000%6000 {
+			t.Fatalf("pass %d: visited %d, next %d", i, report.Visited, report.Next)
+		}
+		edges += report.EdgesChanged()
+	}
+
+	after := recallAt(index, queries, groundTruth, k, ef)
+	t.Logf("Recall@%d at ef=%d: %.4f before, %.4f after optimizing (%d edges changed)", k, ef, before, after, edges)
+	if after < before+0.01 {
+		t.Errorf("recall %.4f -> %.4f, want an improvement of at least 0.01", before, after)
+	}
+	if edges == 0 {
+		t.Errorf("no edges changed")
+	}
+}
+
+func TestOptimizeBounds(t *testing.T) {
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 40, Seed: 1})
+	if report, err := index.Optimize(context.Background(), OptimizeOptions{}); err != nil || report.Visited != 0 {
+		t.Fatalf("empty index: %+v, %v", report, err)
+	}
+	for _, v := range generateRandomVectors(2000, 8, 1) {
+		index.Add(v)
+	}
+
+	report, err := index.Optimize(context.Background(), OptimizeOptions{TimeBudget: time.Nanosecond})
+	if err != nil || report.Visited >= 2000 {
+		t.Errorf("time budget: visited %d, err %v; want an early stop", report.Visited, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := index.Optimize(ctx, OptimizeOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: err = %v, want context.Canceled", err)
+	}
+
+	// Every node is still reachable from its neighbors' lists
+	if _, err := index.Optimize(context.Background(), OptimizeOptions{Workers: 1}); err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	linked := make([]bool, index.Len())
+	for _, node := range index.nodes {
+		for _, nb := range node.GetConnections(0) {
+			linked[nb] = true
+		}
+	}
+	for id, ok := range linked {
+		if !ok {
+			t.Errorf("node %d has no incoming layer-0 edge", id)
+		}
+	}
+}
+
+func TestOptimizeConcurrent(t *testing.T) {
+	vectors := generateRandomVectors(3000, 8, 1)
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 40, Seed: 1})
+	for _, v := range vectors[:1500] {
+		index.Add(v)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for _, v := range vectors[1500:] {
+			index.Add(v)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for _, q := range vectors[:500] {
+			if _, err := index.Search(q, 5, 20); err != nil {
+				t.Errorf("Search failed: %v", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		if _, err := index.Optimize(context.Background(), OptimizeOptions{}); err != nil {
+			t.Fatalf("Optimize failed: %v", err)
+		}
+	}
+	wg.Wait()
+
+	maxConn := index.Mmax0
+	for _, node := range index.nodes {
+		conns := node.GetConnections(0)
+		if len(conns) > maxConn {
+			t.Errorf("node %d has %d layer-0 neighbors, max %d", node.id, len(conns), maxConn)
+		}
+		seen := make(map[int]bool)
+		for _, nb := range conns {
+			if seen[nb] || nb == node.id {
+				t.Errorf("node %d has a duplicate or self edge to %d", node.id, nb)
+			}
+			seen[nb] = true
+		}
+	}
+	for _, q := range vectors[1500:1600] {
+		results, _ := index.Search(q, 1, 50)
+		if len(results) == 0 || results[0].Distance != 0 {
+			t.Errorf("inserted vector not found after concurrent optimize")
+			break
+		}
+	}
+}
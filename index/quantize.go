@@ -0,0 +1,512 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+// Quantization selects how an index keeps its vectors in memory.
+type Quantization int
+
+const (
+	// NoQuantization keeps float32 vectors (default).
+	NoQuantization Quantization = iota
+
+	// ScalarQuant8 keeps each vector as int8 codes with an offset and scale
+	// of its own, a quarter of the memory of float32 vectors plus 8 bytes.
+	// Graph traversal uses distances to the decoded codes, which are
+	// approximate; see FullVectors for re-ranking the results exactly.
+	ScalarQuant8
+)
+
+// String returns the name of the quantization.
+func (q Quantization) String() string {
+	switch q {
+	case NoQuantization:
+		return "NoQuantization"
+	case ScalarQuant8:
+		return "ScalarQuant8"
+	default:
+		return fmt.Sprintf("Quantization(%d)", int(q))
+	}
+}
+
+// FullVectors selects what a quantized index keeps of the float32 vectors.
+type FullVectors int
+
+const (
+	// DropFullVectors keeps only the codes; results are ranked by their
+	// approximate distances (default).
+	DropFullVectors FullVectors = iota
+
+	// FullVectorsOnDisk keeps the float32 vectors in vectors.f32 next to
+	// the index files, memory-mapped once loaded, and re-ranks the ef
+	// candidates of every search by exact distance. Vectors added since the
+	// index was loaded, or of an index never saved, are kept in memory.
+	FullVectorsOnDisk
+)
+
+// String returns the name of the choice.
+func (f FullVectors) String() string {
+	switch f {
+	case DropFullVectors:
+		return "DropFullVectors"
+	case FullVectorsOnDisk:
+		return "FullVectorsOnDisk"
+	default:
+		return fmt.Sprintf("FullVectors(%d)", int(f))
+	}
+}
+
+const (
+	// codesFileName stores the ScalarQuant8 codes of a quantized index
+	codesFileName = "codes.sq8"
+	codesMagic    = 0x38515356 // "VSQ8"
+	codesVersion  = 1
+
+	// vectorsFileName stores the float32 vectors of a FullVectorsOnDisk index
+	vectorsFileName = "vectors.f32"
+	vectorsMagic    = 0x32334656 // "VF32"
+	vectorsVersion  = 1
+
+	// Both files start with magic, version, numNodes and dimension (uint32
+	// each), followed by a fixed-size record per node ID
+	recordFileHeaderSize = 16
+)
+
+// sq8Codes holds the ScalarQuant8 codes of an index. The vector of node id
+// is stored as codes c and params (offset, scale), and decodes to
+// offset + scale*c[i].
+type sq8Codes struct {
+	codes  *arena[int8]
+	params *arena[float32]
+}
+
+func newSQ8Codes(dimension int) *sq8Codes {
+	return &sq8Codes{
+		codes:  newArena[int8](dimension, 1),
+		params: newArena[float32](2, 4),
+	}
+}
+
+// encode stores vector in slot id
+func (q *sq8Codes) encode(id int, vector []float32) {
+	params := q.params.slot(id)
+	params[0], params[1] = sq8Encode(vector, q.codes.slot(id))
+}
+
+// sq8Encode writes the codes of vector to codes and returns their offset
+// and scale. The offset is the midpoint of its values and the scale spreads
+// them over [-127, 127].
+func sq8Encode(vector []float32, codes []int8) (offset, scale float32) {
+	lo, hi := vector[0], vector[0]
+	for _, x := range vector[1:] {
+		if x < lo {
+			lo = x
+		}
+		if x > hi {
+			hi = x
+		}
+	}
+	offset, scale = lo+(hi-lo)/2, (hi-lo)/254
+
+	for i, x := range vector {
+		if scale == 0 {
+			codes[i] = 0
+			continue
+		}
+		c := math.Round(float64((x - offset) / scale))
+		codes[i] = int8(max(-127, min(127, int(c))))
+	}
+	return offset, scale
+}
+
+// sq8RoundTrip returns vector as it decodes after encoding
+func sq8RoundTrip(vector []float32) []float32 {
+	codes := make([]int8, len(vector))
+	offset, scale := sq8Encode(vector, codes)
+	decoded := make([]float32, len(codes))
+	for i, c := range codes {
+		decoded[i] = offset + scale*float32(c)
+	}
+	return decoded
+}
+
+// decode returns the vector of slot id, in dst if it is large enough
+func (q *sq8Codes) decode(id int, dst []float32) []float32 {
+	codes := q.codes.at(id)
+	params := q.params.at(id)
+	if cap(dst) < len(codes) {
+		dst = make([]float32, len(codes))
+	}
+	dst = dst[:len(codes)]
+	for i, c := range codes {
+		dst[i] = params[0] + params[1]*float32(c)
+	}
+	return dst
+}
+
+// distance returns the distance from query to the vector of slot id by
+// metric, decoding it as it goes. Custom distance functions, metric "",
+// get a decoded copy.
+func (q *sq8Codes) distance(query []float32, id int, metric string, fn DistanceFunc) float32 {
+	codes := q.codes.at(id)
+	params := q.params.at(id)
+	offset, scale := params[0], params[1]
+	query = query[:len(codes)]
+	switch metric {
+	case "l2", "l2sqrt":
+		var sum float32
+		for i, c := range codes {
+			diff := query[i] - (offset + scale*float32(c))
+			sum += diff * diff
+		}
+		if metric == "l2sqrt" {
+			return float32(math.Sqrt(float64(sum)))
+		}
+		return sum
+	case "ip":
+		var dot float32
+		for i, c := range codes {
+			dot += query[i] * (offset + scale*float32(c))
+		}
+		return -dot
+	case "cosine":
+		var dot, normQ, normV float32
+		for i, c := range codes {
+			v := offset + scale*float32(c)
+			dot += query[i] * v
+			normQ += query[i] * query[i]
+			normV += v * v
+		}
+		if normQ == 0 || normV == 0 {
+			return 1.0
+		}
+		return 1.0 - dot/(float32(math.Sqrt(float64(normQ)))*float32(math.Sqrt(float64(normV))))
+	default:
+		return fn(query, q.decode(id, nil))
+	}
+}
+
+// nodeArena returns the arena nodes read their vectors from: the index's
+// float32 vectors, or nil in a quantized index, whose nodes have none
+func (h *HNSWIndex) nodeArena() *vectorArena {
+	if h.sq8 != nil {
+		return nil
+	}
+	return h.vectors
+}
+
+// storeVector stores the vector of node id the way the index keeps them
+func (h *HNSWIndex) storeVector(id int, vector []float32) {
+	if h.normalized {
+		vector = normalize(vector)
+	}
+	if h.sq8 != nil {
+		h.sq8.encode(id, vector)
+		if h.fullVectors == DropFullVectors {
+			return
+		}
+	}
+	h.vectors.set(id, vector)
+}
+
+// distTo returns the distance from query to node id as graph traversal
+// sees it: to the decoded codes in a quantized index
+func (h *HNSWIndex) distTo(query []float32, id int) float32 {
+	if h.sq8 != nil {
+		return h.sq8.distance(query, id, h.metric, h.distFunc)
+	}
+	return h.compare(query, h.vectors.at(id))
+}
+
+// distBetween returns the distance between nodes a and b as graph
+// traversal sees it
+func (h *HNSWIndex) distBetween(a, b int) float32 {
+	return h.distTo(h.vectorOf(a), b)
+}
+
+// vectorOf returns the vector of node id to search from: its full vector
+// when the index has it, else its decoded codes. It must not be modified.
+func (h *HNSWIndex) vectorOf(id int) []float32 {
+	if h.sq8 == nil {
+		return h.vectors.at(id)
+	}
+	if vector, ok := h.fullVector(id, nil); ok {
+		return vector
+	}
+	return h.sq8.decode(id, nil)
+}
+
+// exactDist returns the distance from query to the full vector of node id,
+// or the traversal distance when the index does not keep full vectors
+func (h *HNSWIndex) exactDist(query []float32, id int, buf []float32) float32 {
+	if vector, ok := h.fullVector(id, buf); ok {
+		return h.compare(query, vector)
+	}
+	return h.distTo(query, id)
+}
+
+// fullVector returns the float32 vector of node id of a quantized index
+// keeping them, read into buf when it comes from vectors.f32. ok is false
+// when the index does not keep them. It must not be modified.
+func (h *HNSWIndex) fullVector(id int, buf []float32) (vector []float32, ok bool) {
+	if h.sq8 == nil {
+		return h.vectors.at(id), true
+	}
+	if h.fullVectors != FullVectorsOnDisk {
+		return nil, false
+	}
+	if h.vectorFile != nil && id < h.vectorFile.numNodes {
+		vector, err := h.vectorFile.read(id, buf)
+		if err != nil {
+			// Searches cannot surface I/O errors per candidate; rank it by
+			// its codes rather than failing the whole query
+			log.Printf("Warning: failed to read full vector of node %d: %v", id, err)
+			return nil, false
+		}
+		return vector, true
+	}
+	if h.vectors.has(id) {
+		return h.vectors.at(id), true
+	}
+	return nil, false
+}
+
+// rerank replaces the approximate distances of candidates with exact ones
+// and sorts them again, for a quantized index that keeps its full vectors
+func (h *HNSWIndex) rerank(query []float32, candidates []SearchResult) []SearchResult {
+	buf := make([]float32, h.dimension)
+	for i := range candidates {
+		candidates[i].Distance = h.exactDist(query, candidates[i].ID, buf)
+	}
+	slices.SortStableFunc(candidates, func(a, b SearchResult) int {
+		switch {
+		case a.Distance < b.Distance:
+			return -1
+		case a.Distance > b.Distance:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return candidates
+}
+
+// writeRecordFile atomically writes a file of numNodes fixed-size records
+// after a header of magic, version, numNodes and dimension. record fills
+// in the zeroed record of each node ID. Unlike layer0.adj, the file is
+// streamed through a buffer rather than built in memory, since it holds
+// every vector.
+func writeRecordFile(path string, magic, version uint32, numNodes, dimension, recordSize int, record func(id int, rec []byte)) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	w := bufio.NewWriterSize(tmp, 1<<20)
+	var header [recordFileHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:], magic)
+	binary.LittleEndian.PutUint32(header[4:], version)
+	binary.LittleEndian.PutUint32(header[8:], uint32(numNodes))
+	binary.LittleEndian.PutUint32(header[12:], uint32(dimension))
+	if _, err := w.Write(header[:]); err != nil {
+		return fail(err)
+	}
+	rec := make([]byte, recordSize)
+	for id := 0; id < numNodes; id++ {
+		clear(rec)
+		record(id, rec)
+		if _, err := w.Write(rec); err != nil {
+			return fail(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := lanceio.ReplaceFile(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// checkRecordFile validates the header of a file written by
+// writeRecordFile against the index and its size
+func checkRecordFile(f *os.File, name string, magic, version uint32, numNodes, dimension, recordSize int) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var header [recordFileHeaderSize]byte
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		return fmt.Errorf("read %s header: %w", name, err)
+	}
+	m := binary.LittleEndian.Uint32(header[0:])
+	v := binary.LittleEndian.Uint32(header[4:])
+	n := int(binary.LittleEndian.Uint32(header[8:]))
+	d := int(binary.LittleEndian.Uint32(header[12:]))
+	switch {
+	case m != magic || v != version:
+		return fmt.Errorf("invalid %s header (magic 0x%08X, version %d)", name, m, v)
+	case n != numNodes || d != dimension:
+		return fmt.Errorf("%s has %d nodes of dimension %d, index has %d of %d", name, n, d, numNodes, dimension)
+	case info.Size() != int64(recordFileHeaderSize+recordSize*n):
+		return fmt.Errorf("%s size %d does not match %d records of %d bytes", name, info.Size(), n, recordSize)
+	}
+	return nil
+}
+
+// saveCodes writes the codes of the index's nodes to codes.sq8; deleted
+// nodes get zero records
+func (h *HNSWIndex) saveCodes(path string) error {
+	return writeRecordFile(path, codesMagic, codesVersion, len(h.nodes), h.dimension, 8+h.dimension, func(id int, rec []byte) {
+		if h.nodes[id].deleted.Load() {
+			return
+		}
+		params := h.sq8.params.at(id)
+		binary.LittleEndian.PutUint32(rec[0:], math.Float32bits(params[0]))
+		binary.LittleEndian.PutUint32(rec[4:], math.Float32bits(params[1]))
+		for i, c := range h.sq8.codes.at(id) {
+			rec[8+i] = byte(c)
+		}
+	})
+}
+
+// loadCodes reads the codes of numNodes nodes from codes.sq8
+func (h *HNSWIndex) loadCodes(path string, numNodes int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	recordSize := 8 + h.dimension
+	if err := checkRecordFile(f, codesFileName, codesMagic, codesVersion, numNodes, h.dimension, recordSize); err != nil {
+		return err
+	}
+
+	h.sq8.codes.reserve(numNodes)
+	h.sq8.params.reserve(numNodes)
+	r := bufio.NewReaderSize(io.NewSectionReader(f, recordFileHeaderSize, int64(recordSize*numNodes)), 1<<20)
+	rec := make([]byte, recordSize)
+	for id := 0; id < numNodes; id++ {
+		if _, err := io.ReadFull(r, rec); err != nil {
+			return fmt.Errorf("read codes of node %d: %w", id, err)
+		}
+		params := h.sq8.params.at(id)
+		params[0] = math.Float32frombits(binary.LittleEndian.Uint32(rec[0:]))
+		params[1] = math.Float32frombits(binary.LittleEndian.Uint32(rec[4:]))
+		codes := h.sq8.codes.at(id)
+		for i := range codes {
+			codes[i] = int8(rec[8+i])
+		}
+	}
+	return nil
+}
+
+// saveFullVectors writes the full vectors of the index's nodes to
+// vectors.f32; deleted nodes get zero records
+func (h *HNSWIndex) saveFullVectors(path string) error {
+	buf := make([]float32, h.dimension)
+	var missing int
+	err := writeRecordFile(path, vectorsMagic, vectorsVersion, len(h.nodes), h.dimension, 4*h.dimension, func(id int, rec []byte) {
+		if h.nodes[id].deleted.Load() {
+			return
+		}
+		vector, ok := h.fullVector(id, buf)
+		if !ok {
+			missing++
+			return
+		}
+		for i, x := range vector {
+			binary.LittleEndian.PutUint32(rec[4*i:], math.Float32bits(x))
+		}
+	})
+	if err == nil && missing > 0 {
+		err = fmt.Errorf("full vectors of %d nodes could not be read", missing)
+	}
+	return err
+}
+
+// vectorFile serves full vectors from vectors.f32.
+type vectorFile struct {
+	file      *os.File
+	data      []byte // mapped file contents; nil when using positional reads
+	numNodes  int
+	dimension int
+}
+
+// openVectorFile opens path and validates it against the index
+func openVectorFile(path string, numNodes, dimension int) (*vectorFile, error) {
+	// Opened shareable so a later SaveToLance can replace the file while it
+	// is still mapped, as with layer0.adj
+	f, err := lanceio.OpenShared(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRecordFile(f, vectorsFileName, vectorsMagic, vectorsVersion, numNodes, dimension, 4*dimension); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	v := &vectorFile{file: f, numNodes: numNodes, dimension: dimension}
+	if data, err := mmapFile(f, recordFileHeaderSize+4*dimension*numNodes); err == nil {
+		v.data = data
+	}
+	return v, nil
+}
+
+// read returns the vector of node id, in buf if it is large enough
+func (v *vectorFile) read(id int, buf []float32) ([]float32, error) {
+	size := 4 * v.dimension
+	offset := recordFileHeaderSize + id*size
+	var rec []byte
+	if v.data != nil {
+		rec = v.data[offset : offset+size]
+	} else {
+		rec = make([]byte, size)
+		if _, err := v.file.ReadAt(rec, int64(offset)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cap(buf) < v.dimension {
+		buf = make([]float32, v.dimension)
+	}
+	buf = buf[:v.dimension]
+	for i := range buf {
+		buf[i] = math.Float32frombits(binary.LittleEndian.Uint32(rec[4*i:]))
+	}
+	return buf, nil
+}
+
+// Close unmaps and closes the file.
+func (v *vectorFile) Close() error {
+	err := munmapFile(v.data)
+	v.data = nil
+	if cerr := v.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScalarQuant8Recall(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping 10K-vector recall test in short mode")
+	}
+	const n, dim, k, ef = 10000, 32, 10, 200
+	vectors := generateRandomVectors(n, dim, 11)
+	queries := generateRandomVectors(100, dim, 12)
+	groundTruth := make([][]SearchResult, len(queries))
+	for i, q := range queries {
+		groundTruth[i] = bruteForceSearch(q, vectors, k)
+	}
+
+	for _, tc := range []struct {
+		full      FullVectors
+		minRecall float64
+	}{
+		{DropFullVectors, 0.95},
+		{FullVectorsOnDisk, 0.98},
+	} {
+		t.Run(tc.full.String(), func(t *testing.T) {
+			index := NewHNSW(Config{Dimension: dim, M: 16, EfConstruction: 100, Seed: 11, Quantization: ScalarQuant8, FullVectors: tc.full})
+			for _, v := range vectors {
+				index.Add(v)
+			}
+			if recall := recallAt(index, queries, groundTruth, k, ef); recall < tc.minRecall {
+				t.Errorf("recall@%d at ef=%d = %.3f, want >= %.2f", k, ef, recall, tc.minRecall)
+			}
+
+			// Re-ranked results carry exact distances
+			results, _ := index.Search(queries[0], k, ef)
+			if tc.full == FullVectorsOnDisk && results[0].Distance != L2Distance(queries[0], vectors[results[0].ID]) {
+				t.Errorf("re-ranked distance %v, want exact %v", results[0].Distance, L2Distance(queries[0], vectors[results[0].ID]))
+			}
+		})
+	}
+}
+
+func TestScalarQuant8Codes(t *testing.T) {
+	q := newSQ8Codes(4)
+	for id, v := range [][]float32{{-1, 0, 0.5, 1}, {3, 3, 3, 3}, {0, 0, 0, 0}} {
+		q.encode(id, v)
+		got := q.decode(id, nil)
+		scale := q.params.at(id)[1]
+		for i := range v {
+			if math.Abs(float64(got[i]-v[i])) > float64(scale)/2+1e-6 {
+				t.Errorf("vector %d decodes to %v, want %v within %v", id, got, v, scale/2)
+			}
+		}
+		// The fused distances match those of the decoded vector
+		query := []float32{0.25, -0.5, 1, 2}
+		for _, fn := range []DistanceFunc{L2Distance, L2DistanceSqrt, InnerProductDistance, CosineDistance} {
+			want := fn(query, got)
+			if d := q.distance(query, id, distanceName(fn), fn); math.Abs(float64(d-want)) > 1e-5 {
+				t.Errorf("vector %d: %s distance %v, want %v", id, distanceName(fn), d, want)
+			}
+		}
+	}
+}
+
+func TestScalarQuant8SaveLoad(t *testing.T) {
+	const dim = 16
+	vectors := generateRandomVectors(500, dim, 21)
+	queries := generateRandomVectors(20, dim, 22)
+
+	for _, full := range []FullVectors{DropFullVectors, FullVectorsOnDisk} {
+		t.Run(full.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			index := NewHNSW(Config{Dimension: dim, M: 8, EfConstruction: 64, Seed: 21, Quantization: ScalarQuant8, FullVectors: full})
+			for _, v := range vectors {
+				index.Add(v)
+			}
+			index.Delete(7)
+			if err := index.SaveToLance(dir); err != nil {
+				t.Fatalf("SaveToLance failed: %v", err)
+			}
+			_, err := os.Stat(filepath.Join(dir, vectorsFileName))
+			if hasFile := err == nil; hasFile != (full == FullVectorsOnDisk) {
+				t.Errorf("vectors.f32 exists = %v", hasFile)
+			}
+
+			loaded, err := LoadHNSWFromLance(dir)
+			if err != nil {
+				t.Fatalf("LoadHNSWFromLance failed: %v", err)
+			}
+			defer loaded.Close()
+			if loaded.quantization != ScalarQuant8 || loaded.fullVectors != full {
+				t.Fatalf("loaded %v, %v", loaded.quantization, loaded.fullVectors)
+			}
+			for _, q := range queries {
+				want, _ := index.Search(q, 10, 50)
+				got, _ := loaded.Search(q, 10, 50)
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("loaded results %v, want %v", got, want)
+				}
+			}
+
+			got, _ := loaded.Vector(3)
+			want, _ := index.Vector(3)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("Vector(3) = %v, want %v", got, want)
+			}
+			if full == FullVectorsOnDisk && fmt.Sprint(got) != fmt.Sprint(vectors[3]) {
+				t.Errorf("Vector(3) = %v, want the full vector %v", got, vectors[3])
+			}
+
+			// Nodes added after loading are searchable alongside the saved ones
+			id, _ := loaded.Add(queries[0])
+			if results, _ := loaded.Search(queries[0], 1, 50); results[0].ID != id {
+				t.Errorf("nearest to an added vector = %v, want node %d", results, id)
+			}
+		})
+	}
+
+	// A missing vectors.f32 fails the load
+	dir := t.TempDir()
+	index := NewHNSW(Config{Dimension: dim, Seed: 1, Quantization: ScalarQuant8, FullVectors: FullVectorsOnDisk})
+	index.Add(vectors[0])
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	os.Remove(filepath.Join(dir, vectorsFileName))
+	if _, err := LoadHNSWFromLance(dir); err == nil {
+		t.Error("loaded without vectors.f32")
+	}
+}
+
+func TestScalarQuant8Config(t *testing.T) {
+	for _, c := range []Config{
+		{Dimension: 4, Quantization: 5},
+		{Dimension: 4, Quantization: ScalarQuant8, FullVectors: -1},
+		{Dimension: 4, FullVectors: FullVectorsOnDisk},
+	} {
+		var ce *ConfigError
+		if err := c.Validate(); !errors.As(err, &ce) || !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("Validate(%+v) = %v, want a ConfigError", c, err)
+		}
+	}
+	if err := (Config{Dimension: 4, Quantization: ScalarQuant8, FullVectors: FullVectorsOnDisk}).Validate(); err != nil {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestScalarQuant8StoredVector(t *testing.T) {
+	for _, tc := range []struct {
+		full FullVectors
+		dist DistanceFunc
+	}{
+		{DropFullVectors, L2Distance},
+		{DropFullVectors, CosineDistance},
+		{FullVectorsOnDisk, L2Distance},
+		{FullVectorsOnDisk, CosineDistance},
+	} {
+		name := fmt.Sprintf("%s/%s", tc.full, distanceName(tc.dist))
+		index, err := NewHNSWChecked(Config{Dimension: 8, M: 8, DistanceFunc: tc.dist, Quantization: ScalarQuant8, FullVectors: tc.full})
+		if err != nil {
+			t.Fatalf("%s: NewHNSWChecked failed: %v", name, err)
+		}
+		for i, v := range generateRandomVectors(20, 8, 3) {
+			id, err := index.Add(v)
+			if err != nil {
+				t.Fatalf("%s: Add failed: %v", name, err)
+			}
+			// Adding the vector again would store what the node has
+			stored, err := index.Vector(id)
+			if err != nil {
+				t.Fatalf("%s: Vector failed: %v", name, err)
+			}
+			if want := index.StoredVector(v); !slices.Equal(stored, want) {
+				t.Errorf("%s: vector %d: Vector = %v, StoredVector = %v", name, i, stored, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package hnsw
+
+// raceEnabled is set when tests run under the race detector, whose overhead
+// makes timings meaningless
+const raceEnabled = true
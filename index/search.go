@@ -82,29 +82,55 @@ func (h *MaxHeap) Peek() interface{} {
 	return (*h)[0]
 }
 
-// search finds k nearest neighbors in the index
-func (h *HNSWIndex) search(query []float32, k int, ef int, ep int, topLevel int) ([]SearchResult, error) {
-	// Phase 1: From top layer to layer 1, use greedy search
-	currentNearest := ep
-	for lc := topLevel; lc > 0; lc-- {
-		nearest := h.searchLayer(query, currentNearest, 1, lc)
-		if len(nearest) > 0 {
-			currentNearest = nearest[0].ID
-		}
-	}
+// search finds k nearest neighbors in the given view of the index
+func (h *HNSWIndex) search(view *graphView, query []float32, k int, params SearchParams) ([]SearchResult, error) {
+	query = h.prepareQuery(query)
+
+	// Phase 1: From top layer to layer 1
+	entries := h.descend(view, query, params.EfUpperLayers)
 
-	// Phase 2: Search at layer 0 using ef
-	candidates := h.searchLayer(query, currentNearest, ef, 0)
+	// Phase 2: Search at layer 0 using EfBase, keeping only allowed nodes
+	candidates := h.searchLayerAllowed(view.nodes, query, entries, params.EfBase, 0, params.Allow)
+
+	// Order the candidates by exact distance when approximate ones found them
+	if h.sq8 != nil && h.fullVectors == FullVectorsOnDisk {
+		candidates = h.rerank(query, candidates)
+	}
 
 	// Return top k results
 	if len(candidates) > k {
-		return candidates[:k], nil
+		candidates = candidates[:k]
 	}
 
-	return candidates, nil
+	return h.withExternalIDs(candidates), nil
 }
 
-func (h *HNSWIndex) searchLayerAggressive(query []float32, ep int, ef int, level int) []SearchResult {
+// prepareQuery returns query as stored vectors are compared with it:
+// normalized to unit length in a cosine index, else unchanged
+func (h *HNSWIndex) prepareQuery(query []float32) []float32 {
+	if h.normalized {
+		return normalize(query)
+	}
+	return query
+}
+
+// descend walks from the entry point down to layer 1, keeping the efUpper
+// closest nodes of each layer as the entry points of the next, and returns
+// the entry points for layer 0. With a beam of 1 this is the classic greedy
+// descent.
+func (h *HNSWIndex) descend(view *graphView, query []float32, efUpper int) []SearchResult {
+	ep := int(view.entryPoint)
+	entries := []SearchResult{{ID: ep, Distance: h.distTo(query, ep)}}
+	for lc := int(view.maxLevel); lc > 0; lc-- {
+		nearest := h.searchLayerFrom(view.nodes, query, entries, efUpper, lc)
+		if len(nearest) > 0 {
+			entries = nearest
+		}
+	}
+	return entries
+}
+
+func (h *HNSWIndex) searchLayerAggressive(nodes []*Node, query []float32, ep int, ef int, level int) []SearchResult {
 	visited := make(map[int]bool)
 
 	// Candidate set, min-heap, sorted by distance ascending
@@ -116,7 +142,7 @@ func (h *HNSWIndex) searchLayerAggressive(query []float32, ep int, ef int, level
 	heap.Init(results)
 
 	// Calculate entry point distance
-	epDist := h.distFunc(query, h.nodes[ep].Vector())
+	epDist := h.distTo(query, ep)
 
 	heap.Push(candidates, &Item{value: ep, priority: epDist})
 	heap.Push(results, &Item{value: ep, priority: epDist})
@@ -134,26 +160,26 @@ func (h *HNSWIndex) searchLayerAggressive(query []float32, ep int, ef int, level
 			}
 		}
 
-		if current.value < 0 || current.value >= len(h.nodes) {
+		if current.value < 0 || current.value >= len(nodes) {
 			continue // Skip invalid nodes
 		}
 
 		// Check all neighbors of current node
-		neighbors := h.nodes[current.value].GetConnections(level)
+		neighbors := nodes[current.value].neighbors(level)
 
 		for _, neighborID := range neighbors {
 			if visited[neighborID] {
 				continue
 			}
 
-			if neighborID < 0 || neighborID >= len(h.nodes) {
+			if neighborID < 0 || neighborID >= len(nodes) {
 				continue // Skip invalid neighbors
 			}
 
 			visited[neighborID] = true
 
 			// Calculate distance
-			dist := h.distFunc(query, h.nodes[neighborID].Vector())
+			dist := h.distTo(query, neighborID)
 
 			// If result set not full or current distance is closer, add to candidates
 			if results.Len() < ef {
@@ -184,7 +210,29 @@ func (h *HNSWIndex) searchLayerAggressive(query []float32, ep int, ef int, level
 }
 
 // searchLayerConservative
-func (h *HNSWIndex) searchLayer(query []float32, ep int, ef int, level int) []SearchResult {
+//
+// searchLayer only reads the given node table and the nodes' published
+// neighbor lists, so it is safe to run concurrently with inserts. Neighbors
+// that are newer than the node table (linked after the view was taken) are
+// skipped.
+func (h *HNSWIndex) searchLayer(nodes []*Node, query []float32, ep int, ef int, level int) []SearchResult {
+	epDist := h.distTo(query, ep)
+	return h.searchLayerFrom(nodes, query, []SearchResult{{ID: ep, Distance: epDist}}, ef, level)
+}
+
+// searchLayerFrom is searchLayer seeded with several entry points whose
+// distances to query are already known
+func (h *HNSWIndex) searchLayerFrom(nodes []*Node, query []float32, entries []SearchResult, ef int, level int) []SearchResult {
+	return h.searchLayerAllowed(nodes, query, entries, ef, level, nil)
+}
+
+// searchLayerAllowed is searchLayerFrom returning only the nodes allow
+// accepts; a nil allow accepts every node
+func (h *HNSWIndex) searchLayerAllowed(nodes []*Node, query []float32, entries []SearchResult, ef int, level int, allow func(id int) bool) []SearchResult {
+	accept := func(id int) bool {
+		return !nodes[id].deleted.Load() && (allow == nil || allow(id))
+	}
+
 	estimatedVisits := int(float64(ef) * 2.0 * float64(h.Mmax))
 	visited := make(map[int]bool, estimatedVisits)
 
@@ -193,16 +241,24 @@ func (h *HNSWIndex) searchLayer(query []float32, ep int, ef int, level int) []Se
 	heap.Init(candidates)
 	heap.Init(results)
 
-	epDist := h.distFunc(query, h.nodes[ep].vector)
-	heap.Push(candidates, &Item{value: ep, priority: epDist})
-	heap.Push(results, &Item{value: ep, priority: epDist})
-	visited[ep] = true
+	// Deleted and disallowed nodes are expanded like the others but never
+	// enter the results, so they are neither returned nor linked to
+	for _, e := range entries {
+		heap.Push(candidates, &Item{value: e.ID, priority: e.Distance})
+		if accept(e.ID) {
+			heap.Push(results, &Item{value: e.ID, priority: e.Distance})
+		}
+		visited[e.ID] = true
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
 
 	for candidates.Len() > 0 {
 		current := heap.Pop(candidates).(*Item)
 
 		// Boundary check
-		if current.value < 0 || current.value >= len(h.nodes) {
+		if current.value < 0 || current.value >= len(nodes) {
 			continue
 		}
 
@@ -215,17 +271,17 @@ func (h *HNSWIndex) searchLayer(query []float32, ep int, ef int, level int) []Se
 		}
 
 		// Iterate through neighbors
-		for _, neighborID := range h.nodes[current.value].connections[level] {
+		for _, neighborID := range nodes[current.value].neighbors(level) {
 			if visited[neighborID] {
 				continue
 			}
 
-			if neighborID < 0 || neighborID >= len(h.nodes) {
+			if neighborID < 0 || neighborID >= len(nodes) {
 				continue
 			}
 
 			visited[neighborID] = true
-			dist := h.distFunc(query, h.nodes[neighborID].vector)
+			dist := h.distTo(query, neighborID)
 
 			// More precise floating-point tolerance
 			shouldAdd := false
@@ -248,9 +304,11 @@ func (h *HNSWIndex) searchLayer(query []float32, ep int, ef int, level int) []Se
 				heap.Push(candidates, &Item{value: neighborID, priority: dist})
 
 				// results maintain original logic
-				heap.Push(results, &Item{value: neighborID, priority: dist})
-				if results.Len() > ef {
-					heap.Pop(results)
+				if accept(neighborID) {
+					heap.Push(results, &Item{value: neighborID, priority: dist})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
 				}
 			}
 		}
@@ -265,7 +323,7 @@ func (h *HNSWIndex) searchLayer(query []float32, ep int, ef int, level int) []Se
 	return resultArray
 }
 
-func (h *HNSWIndex) selectNeighborsHeuristic(query []float32, candidates []SearchResult, m int) []SearchResult {
+func (h *HNSWIndex) selectNeighborsHeuristic(nodes []*Node, query []float32, candidates []SearchResult, m int) []SearchResult {
 	if len(candidates) <= m {
 		return candidates
 	}
@@ -284,14 +342,13 @@ func (h *HNSWIndex) selectNeighborsHeuristic(query []float32, candidates []Searc
 		}
 
 		good := true
-		candidateVec := h.nodes[candidate.ID].Vector()
+		candidateVec := h.vectorOf(candidate.ID)
 
 		// Explicitly document heuristic logic
 		// Rejection condition: if candidate is closer to selected neighbor than to query
 		// Purpose: ensure diversity and coverage of neighbors
 		for _, selected := range result {
-			selectedVec := h.nodes[selected.ID].Vector()
-			distToSelected := h.distFunc(candidateVec, selectedVec)
+			distToSelected := h.distTo(candidateVec, selected.ID)
 
 			// candidate.Distance is the distance from candidate to query
 			if distToSelected < candidate.Distance {
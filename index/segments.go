@@ -0,0 +1,385 @@
+package hnsw
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+// The first save of an index to a directory writes all of it, the base:
+// nodes.lance, connections.lance and metadata.lance. Saving again to the
+// directory the index was last saved to or loaded from appends a segment
+// instead, holding only the nodes added, relinked or deleted since:
+//
+//	nodes-NNNNNN.lance        the nodes added, as in nodes.lance
+//	changes-NNNNNN.lance      ID and level of every node changed, -1 if deleted
+//	connections-NNNNNN.lance  the connections of the changed nodes not deleted
+//	metadata-NNNNNN.lance     the metadata of the index at that save
+//
+// Segments are never modified once written. manifest.json lists them in
+// the order they apply and is replaced last, so a save interrupted before
+// it leaves files that loading ignores and the next save overwrites. A node
+// has the connections of the latest segment that changes it, or of the base.
+//
+// Quantized and binary indexes, whose vectors are saved in files holding
+// every node, are always saved whole, as are indexes whose segments number
+// maxSegments or hold more node changes than the base has nodes. Compact
+// saves whole on demand.
+
+const (
+	manifestFileName = "manifest.json"
+	manifestVersion  = 1
+
+	// maxSegments is the number of segments after which a save rewrites the
+	// whole index
+	maxSegments = 16
+)
+
+// segmentKinds are the files of a segment, by prefix
+var segmentKinds = []string{"nodes", "changes", "connections", "metadata"}
+
+// segmentManifest is the content of manifest.json
+type segmentManifest struct {
+	Version   int           `json:"version"`
+	BaseNodes int           `json:"baseNodes"` // Node count of the base.
+	Segments  []segmentInfo `json:"segments"`  // Oldest first.
+}
+
+// segmentInfo describes a segment listed in manifest.json
+type segmentInfo struct {
+	ID      int `json:"id"`
+	Changes int `json:"changes"` // Rows of its changes file.
+}
+
+// saveState records the last save or load of an index, which later saves
+// to the same directory append segments to
+type saveState struct {
+	dir      string      // Cleaned directory, "" if there is none.
+	options  SaveOptions // Options of the save, with a nil Factory after a load.
+	numNodes int         // Node count saved.
+	metadata []int32     // Metadata saved.
+	base     os.FileInfo // metadata.lance of the base.
+	manifest segmentManifest
+}
+
+// baseInfo returns the file info of the base metadata of baseDir, which
+// every save of the base replaces
+func baseInfo(baseDir string) (os.FileInfo, error) {
+	return os.Stat(filepath.Join(baseDir, "metadata.lance"))
+}
+
+// segmentFileName returns the name of the file of a kind of segment id
+func segmentFileName(kind string, id int) string {
+	return fmt.Sprintf("%s-%06d.lance", kind, id)
+}
+
+// readManifest returns the manifest of baseDir, or an empty one if the
+// directory has none
+func readManifest(baseDir string) (segmentManifest, error) {
+	data, err := os.ReadFile(filepath.Join(baseDir, manifestFileName))
+	if os.IsNotExist(err) {
+		return segmentManifest{}, nil
+	}
+	if err != nil {
+		return segmentManifest{}, err
+	}
+
+	var manifest segmentManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return segmentManifest{}, fmt.Errorf("parse %s: %w", manifestFileName, err)
+	}
+	if manifest.Version != manifestVersion {
+		return segmentManifest{}, fmt.Errorf("%w: %s version %d", ErrUnsupportedFormat, manifestFileName, manifest.Version)
+	}
+	return manifest, nil
+}
+
+// writeManifest atomically replaces the manifest of baseDir
+func writeManifest(baseDir string, manifest segmentManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return lanceio.WriteFileAtomic(filepath.Join(baseDir, manifestFileName), data, 0644)
+}
+
+// removeSegmentFiles removes the segment files of baseDir, listed in its
+// manifest or left by an interrupted save
+func removeSegmentFiles(baseDir string) error {
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		for _, kind := range segmentKinds {
+			if ok, _ := filepath.Match(kind+"-*.lance", entry.Name()); ok {
+				if err := os.Remove(filepath.Join(baseDir, entry.Name())); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// appendable reports whether a save to baseDir with opts can append a
+// segment to the last save instead of rewriting the index
+func (h *HNSWIndex) appendable(baseDir string, opts SaveOptions) bool {
+	s := &h.saved
+	if s.dir == "" || s.dir != filepath.Clean(baseDir) || h.sq8 != nil || h.binary {
+		return false
+	}
+	// Segments hold vectors at the precision of the base
+	if s.options.VectorPrecision != opts.VectorPrecision {
+		return false
+	}
+
+	changes := 0
+	for _, segment := range s.manifest.Segments {
+		changes += segment.Changes
+	}
+	if len(s.manifest.Segments) >= maxSegments || changes > s.manifest.BaseNodes {
+		return false
+	}
+
+	// The directory must still hold that save
+	base, err := baseInfo(baseDir)
+	if err != nil || s.base == nil || !os.SameFile(base, s.base) {
+		return false
+	}
+	manifest, err := readManifest(baseDir)
+	return err == nil && slices.Equal(manifest.Segments, s.manifest.Segments)
+}
+
+// saveSegment appends the nodes changed since the last save to baseDir as
+// a new segment. Nothing is written if nothing changed.
+func (h *HNSWIndex) saveSegment(baseDir string, opts SaveOptions) error {
+	s := &h.saved
+	factory := opts.Factory
+	metadata := h.metadataValues(opts.VectorPrecision)
+
+	// Flags are cleared before the nodes are read, so changes made
+	// meanwhile are saved again next time. Nodes added since the last save
+	// are changed whether they have connections or not.
+	var changed, added, linked []int
+	for id, node := range h.nodes {
+		if !node.changed.Swap(false) && id < s.numNodes {
+			continue
+		}
+		changed = append(changed, id)
+		if node.deleted.Load() {
+			continue
+		}
+		linked = append(linked, id)
+		if id >= s.numNodes {
+			added = append(added, id)
+		}
+	}
+	if len(changed) == 0 && slices.Equal(metadata, s.metadata) {
+		return nil
+	}
+
+	id := 1
+	if n := len(s.manifest.Segments); n > 0 {
+		id = s.manifest.Segments[n-1].ID + 1
+	}
+	file := func(kind string) string {
+		return filepath.Join(baseDir, segmentFileName(kind, id))
+	}
+
+	// The files of TieredL0 and Mapped loading hold the base, which segments
+	// supersede
+	for _, name := range []string{l0FileName, upperFileName, vectorsFileName} {
+		if err := os.Remove(filepath.Join(baseDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s failed: %w", name, err)
+		}
+	}
+
+	// Files of an interrupted save with the same ID are replaced, or
+	// removed if this segment has none
+	if err := h.saveNodes(file("nodes"), added, opts.VectorPrecision, factory); err != nil {
+		return fmt.Errorf("save nodes failed: %w", err)
+	}
+	if err := h.saveChanges(file("changes"), changed, factory); err != nil {
+		return fmt.Errorf("save changes failed: %w", err)
+	}
+	if err := h.saveConnections(file("connections"), linked, factory); err != nil {
+		return fmt.Errorf("save connections failed: %w", err)
+	}
+	if err := h.saveMetadata(file("metadata"), metadata, factory); err != nil {
+		return fmt.Errorf("save metadata failed: %w", err)
+	}
+
+	manifest := s.manifest
+	manifest.Version = manifestVersion
+	manifest.Segments = append(slices.Clip(manifest.Segments), segmentInfo{ID: id, Changes: len(changed)})
+	if err := writeManifest(baseDir, manifest); err != nil {
+		return fmt.Errorf("write manifest failed: %w", err)
+	}
+
+	s.options = opts
+	s.numNodes = len(h.nodes)
+	s.metadata = metadata
+	s.manifest = manifest
+	return nil
+}
+
+// saveChanges saves the IDs and levels of the nodes of ids, which ascend,
+// with level -1 for deleted nodes
+func (h *HNSWIndex) saveChanges(filename string, ids []int, factory *encoding.EncoderFactory) error {
+	if len(ids) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove stale changes failed: %w", err)
+		}
+		return nil
+	}
+
+	schema := SchemaForNodeChanges()
+	nodeIDs := make([]int32, len(ids))
+	levels := make([]int32, len(ids))
+	for i, id := range ids {
+		nodeIDs[i] = int32(id)
+		levels[i] = int32(h.nodes[id].Level())
+		if h.nodes[id].deleted.Load() {
+			levels[i] = -1
+		}
+	}
+
+	batch, err := arrow.NewRecordBatch(schema, len(ids), []arrow.Array{
+		arrow.NewInt32Array(nodeIDs, nil),
+		arrow.NewInt32Array(levels, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("create record batch failed: %w", err)
+	}
+
+	if err := writeBatchFile(filename, schema, batch, pageRows(8), factory); err != nil {
+		return fmt.Errorf("write changes failed: %w", err)
+	}
+
+	return nil
+}
+
+// loadSegments applies the nodes and changes of the segments of manifest
+// to the nodes loaded from the base. It returns, for each node, the
+// position in manifest.Segments plus one of the segment whose connections
+// it has, 0 for the base.
+func (h *HNSWIndex) loadSegments(baseDir string, manifest segmentManifest, workers int, precision VectorPrecision) ([]int, error) {
+	if h.sq8 != nil || h.binary {
+		return nil, fmt.Errorf("%w: segments of a quantized or binary index", ErrUnsupportedFormat)
+	}
+
+	owner := make([]int, len(h.nodes))
+	h.vectors.reserve(len(h.nodes))
+	for i, segment := range manifest.Segments {
+		nodesFile := filepath.Join(baseDir, segmentFileName("nodes", segment.ID))
+		if err := h.loadSegmentNodes(nodesFile, workers, precision); err != nil {
+			return nil, fmt.Errorf("segment %d: %w", segment.ID, err)
+		}
+		changesFile := filepath.Join(baseDir, segmentFileName("changes", segment.ID))
+		if err := h.loadSegmentChanges(changesFile, owner, i+1); err != nil {
+			return nil, fmt.Errorf("segment %d: %w", segment.ID, err)
+		}
+	}
+
+	h.deleted = 0
+	for _, node := range h.nodes {
+		if node.deleted.Load() {
+			h.deleted++
+		}
+	}
+	return owner, nil
+}
+
+// loadSegmentNodes adds the nodes of a segment's nodes file, if it has one
+func (h *HNSWIndex) loadSegmentNodes(filename string, workers int, precision VectorPrecision) error {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	batch, err := readBatchFile(filename, workers, schemaForNodes(h.dimension, precision))
+	if err != nil {
+		return fmt.Errorf("read nodes failed: %w", err)
+	}
+
+	idArray := batch.Column(0).(*arrow.Int32Array)
+	vectorValues := nodeVectors(batch.Column(1))
+	levelArray := batch.Column(2).(*arrow.Int32Array)
+	for i := 0; i < idArray.Len(); i++ {
+		id := int(idArray.Value(i))
+		if id < 0 || id >= len(h.nodes) {
+			return fmt.Errorf("node ID %d at index %d out of range [0, %d)", id, i, len(h.nodes))
+		}
+		copy(h.vectors.at(id), vectorValues[i*h.dimension:(i+1)*h.dimension])
+		h.nodes[id] = newNode(id, int(levelArray.Value(i)), h.nodeArena())
+	}
+	h.loadExternalIDs(idArray, batch.Column(3))
+	return nil
+}
+
+// loadSegmentChanges deletes the nodes a segment's changes file marks as
+// deleted, and sets the owner of every node it changes to segment
+func (h *HNSWIndex) loadSegmentChanges(filename string, owner []int, segment int) error {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	batch, err := readBatchFile(filename, 1, nil)
+	if err != nil {
+		return fmt.Errorf("read changes failed: %w", err)
+	}
+	if want := len(SchemaForNodeChanges().Fields()); batch.NumCols() != want {
+		return fmt.Errorf("changes file has %d columns, want %d", batch.NumCols(), want)
+	}
+
+	ids := batch.Column(0).(*arrow.Int32Array).Values()
+	levels := batch.Column(1).(*arrow.Int32Array).Values()
+	for i := range ids {
+		id, level := int(ids[i]), int(levels[i])
+		switch {
+		case id < 0 || id >= len(h.nodes):
+			return fmt.Errorf("changed node ID %d at index %d out of range [0, %d)", id, i, len(h.nodes))
+		case level < 0:
+			node := newNode(id, 0, nil)
+			node.deleted.Store(true)
+			h.nodes[id] = node
+		case h.nodes[id].deleted.Load() || h.nodes[id].Level() != level:
+			return fmt.Errorf("changed node %d at level %d is not in the index", id, level)
+		}
+		owner[id] = segment
+	}
+	return nil
+}
+
+// Compact rewrites the directory the index was last saved to or loaded
+// from as a single base without segments, as the first save to a directory
+// writes it, so loading reads one set of files and TieredL0 can map
+// layer0.adj again. SaveToLance compacts by itself once the segments number
+// 16 or hold more node changes than the base has nodes.
+//
+// It returns ErrNotSaved if the index was neither saved nor loaded, or its
+// last save failed.
+func (h *HNSWIndex) Compact() error {
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+	if h.saved.dir == "" {
+		return ErrNotSaved
+	}
+
+	h.globalLock.RLock()
+	defer h.globalLock.RUnlock()
+
+	opts := h.saved.options
+	if opts.Factory == nil {
+		opts.Factory = defaultEncoderFactory()
+	}
+	if err := h.saveFull(h.saved.dir, opts); err != nil {
+		h.saved = saveState{}
+		return err
+	}
+	return nil
+}
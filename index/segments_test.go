@@ -0,0 +1,265 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkLoadedEqual loads dir and compares it with want: graph, vectors,
+// external IDs and default ef
+func checkLoadedEqual(t *testing.T, dir string, want *HNSWIndex, opts ...LoadOption) *HNSWIndex {
+	t.Helper()
+	loaded, err := LoadHNSWFromLance(dir, opts...)
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	t.Cleanup(func() { loaded.Close() })
+	if loaded.GraphHash() != want.GraphHash() {
+		t.Fatal("loaded graph differs from the index in memory")
+	}
+	if loaded.Len() != want.Len() || loaded.Deleted() != want.Deleted() {
+		t.Fatalf("loaded %d nodes, %d deleted; want %d, %d", loaded.Len(), loaded.Deleted(), want.Len(), want.Deleted())
+	}
+	if _, _, got, _ := loaded.Params(); got != int(want.defaultEf.Load()) {
+		t.Errorf("loaded default ef %d, want %d", got, want.defaultEf.Load())
+	}
+	for id := 0; id < len(want.nodes); id++ {
+		got, gotErr := loaded.Vector(id)
+		vector, err := want.Vector(id)
+		if fmt.Sprint(got, gotErr) != fmt.Sprint(vector, err) {
+			t.Fatalf("node %d loaded as %v, %v; want %v, %v", id, got, gotErr, vector, err)
+		}
+		gotID, gotErr := loaded.ExternalID(id)
+		externalID, err := want.ExternalID(id)
+		if gotID != externalID || gotErr != err {
+			t.Fatalf("node %d loaded with external ID %d, %v; want %d, %v", id, gotID, gotErr, externalID, err)
+		}
+	}
+	return loaded
+}
+
+func readTestManifest(t *testing.T, dir string) segmentManifest {
+	t.Helper()
+	manifest, err := readManifest(dir)
+	if err != nil {
+		t.Fatalf("readManifest failed: %v", err)
+	}
+	return manifest
+}
+
+func TestIncrementalSave(t *testing.T) {
+	vectors := generateRandomVectors(2080, 16, 1)
+	index := NewHNSW(Config{Dimension: 16, M: 8, EfConstruction: 64, Seed: 1})
+	for _, v := range vectors[:2000] {
+		index.Add(v)
+	}
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if manifest := readTestManifest(t, dir); len(manifest.Segments) != 0 {
+		t.Fatalf("first save has segments %v", manifest.Segments)
+	}
+	base := map[string]os.FileInfo{}
+	for _, name := range []string{"nodes.lance", "connections.lance", "metadata.lance"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		base[name] = info
+	}
+
+	// Add, delete old and new nodes, and save twice more
+	for _, v := range vectors[2000:2040] {
+		index.Add(v)
+	}
+	for _, id := range []int{0, 17, 2020} {
+		if err := index.Delete(id); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", id, err)
+		}
+	}
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("incremental SaveToLance failed: %v", err)
+	}
+	checkLoadedEqual(t, dir, index)
+
+	for _, v := range vectors[2040:] {
+		index.Add(v)
+	}
+	index.Delete(2050)
+	index.SetDefaultEf(77)
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("incremental SaveToLance failed: %v", err)
+	}
+
+	manifest := readTestManifest(t, dir)
+	if len(manifest.Segments) != 2 || manifest.BaseNodes != 2000 {
+		t.Fatalf("manifest %+v, want 2 segments over 2000 base nodes", manifest)
+	}
+	for _, segment := range manifest.Segments {
+		// Relinked neighbors are changed too, but far from every node
+		if segment.Changes < 40 || segment.Changes > 1000 {
+			t.Errorf("segment %d changes %d nodes", segment.ID, segment.Changes)
+		}
+	}
+	for name, info := range base {
+		now, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || !os.SameFile(info, now) || now.ModTime() != info.ModTime() {
+			t.Errorf("%s of the base was rewritten", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, l0FileName)); !os.IsNotExist(err) {
+		t.Errorf("layer0.adj of the base kept: %v", err)
+	}
+
+	loaded := checkLoadedEqual(t, dir, index)
+	query := vectors[5]
+	want, _ := index.Search(query, 10, 100)
+	got, _ := loaded.Search(query, 10, 100)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("loaded search %v, want %v", got, want)
+	}
+
+	// Nothing changed, nothing written
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if got := readTestManifest(t, dir); len(got.Segments) != 2 {
+		t.Errorf("unchanged save wrote segment %v", got.Segments[len(got.Segments)-1])
+	}
+
+	// A loaded index appends to the segments it was loaded from
+	loaded.Add(vectors[0])
+	if err := loaded.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance of loaded index failed: %v", err)
+	}
+	if got := readTestManifest(t, dir); len(got.Segments) != 3 {
+		t.Fatalf("loaded index saved segments %v, want 3", got.Segments)
+	}
+	checkLoadedEqual(t, dir, loaded)
+
+	// Another directory gets the whole index
+	other := t.TempDir()
+	if err := index.SaveToLance(other); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if got := readTestManifest(t, other); len(got.Segments) != 0 {
+		t.Errorf("save to a new directory has segments %v", got.Segments)
+	}
+	checkLoadedEqual(t, other, index)
+}
+
+func TestIncrementalSaveInterrupted(t *testing.T) {
+	vectors := generateRandomVectors(520, 8, 2)
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 32, Seed: 2})
+	for _, v := range vectors[:500] {
+		index.Add(v)
+	}
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	for _, v := range vectors[500:510] {
+		index.Add(v)
+	}
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	saved, err := LoadHNSWFromLance(dir)
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	// A save that wrote its segment but not the manifest
+	for _, v := range vectors[510:] {
+		index.Add(v)
+	}
+	index.Delete(3)
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFileName), manifest, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentFileName("nodes", 2))); err != nil {
+		t.Fatalf("dangling segment missing: %v", err)
+	}
+
+	// Loading ignores the dangling segment, and the next save replaces it
+	loaded := checkLoadedEqual(t, dir, saved)
+	loaded.Add(vectors[0])
+	if err := loaded.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	checkLoadedEqual(t, dir, loaded)
+
+	// The index that saved it finds the manifest changed and saves whole
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if got := readTestManifest(t, dir); len(got.Segments) != 0 {
+		t.Errorf("save over a foreign manifest appended segments %v", got.Segments)
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentFileName("nodes", 2))); !os.IsNotExist(err) {
+		t.Errorf("whole save kept segment files: %v", err)
+	}
+	checkLoadedEqual(t, dir, index)
+}
+
+func TestCompact(t *testing.T) {
+	if err := NewHNSW(Config{Dimension: 4}).Compact(); !errors.Is(err, ErrNotSaved) {
+		t.Errorf("Compact of an unsaved index: %v", err)
+	}
+
+	vectors := generateRandomVectors(1000, 8, 3)
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 32, Seed: 3})
+	for _, v := range vectors[:400] {
+		index.Add(v)
+	}
+	dir := t.TempDir()
+	index.SaveToLance(dir)
+	for _, v := range vectors[400:410] {
+		index.Add(v)
+	}
+	index.SaveToLance(dir)
+	if got := readTestManifest(t, dir); len(got.Segments) != 1 {
+		t.Fatalf("segments %v, want 1", got.Segments)
+	}
+
+	if err := index.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestFileName)); !os.IsNotExist(err) {
+		t.Errorf("compacted index kept its manifest: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*-*.lance")); len(matches) != 0 {
+		t.Errorf("compacted index kept segment files %v", matches)
+	}
+	tiered := checkLoadedEqual(t, dir, index, WithGraphStorage(TieredL0))
+	if tiered.GraphStorage() != TieredL0 {
+		t.Error("compacted index does not load tiered")
+	}
+
+	// Segments holding more changes than the base are compacted by the
+	// save after them
+	for _, v := range vectors[410:] {
+		index.Add(v)
+	}
+	index.SaveToLance(dir)
+	if got := readTestManifest(t, dir); len(got.Segments) != 1 {
+		t.Fatalf("segments %v, want 1", got.Segments)
+	}
+	index.Delete(1)
+	index.SaveToLance(dir)
+	if got := readTestManifest(t, dir); len(got.Segments) != 0 {
+		t.Errorf("segments %v not compacted", got.Segments)
+	}
+	checkLoadedEqual(t, dir, index)
+}
@@ -5,8 +5,13 @@ import (
 	"github.com/wzqhbustb/vego/storage/arrow"
 	"github.com/wzqhbustb/vego/storage/column"
 	"github.com/wzqhbustb/vego/storage/encoding" // [NEW] Import encoding package
+	"github.com/wzqhbustb/vego/storage/format"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 )
 
 // [NEW] Helper function: create default EncoderFactory
@@ -14,18 +19,54 @@ func defaultEncoderFactory() *encoding.EncoderFactory {
 	return encoding.NewEncoderFactory(3) // Default compression level 3
 }
 
-// SchemaForNodes creates schema for node storage
+// SchemaForNodes creates schema for node storage. external_id is written
+// only by indexes with external IDs, see AddWithID; files without it load
+// with node IDs as external IDs.
 func SchemaForNodes(dimension int) *arrow.Schema {
+	return schemaForNodes(dimension, Float32)
+}
+
+// schemaForNodes is SchemaForNodes with vectors saved at precision
+func schemaForNodes(dimension int, precision VectorPrecision) *arrow.Schema {
+	vectorType := arrow.VectorType(dimension)
+	if precision == Float16 {
+		vectorType = arrow.Float16VectorType(dimension)
+	}
+	return arrow.NewSchema([]arrow.Field{
+		arrow.NewField("id", arrow.PrimInt32(), false),
+		arrow.NewField("vector", vectorType, false),
+		arrow.NewField("level", arrow.PrimInt32(), false),
+		arrow.NewField("external_id", arrow.PrimInt64(), true),
+	}, map[string]string{
+		"purpose":   "hnsw_nodes",
+		"dimension": fmt.Sprintf("%d", dimension),
+	})
+}
+
+// SchemaForQuantizedNodes creates schema for node storage of a quantized or
+// binary index, whose vectors are saved in codes.sq8 or vectors.bin instead
+func SchemaForQuantizedNodes(dimension int) *arrow.Schema {
 	return arrow.NewSchema([]arrow.Field{
 		arrow.NewField("id", arrow.PrimInt32(), false),
-		arrow.NewField("vector", arrow.VectorType(dimension), false),
 		arrow.NewField("level", arrow.PrimInt32(), false),
+		arrow.NewField("external_id", arrow.PrimInt64(), true),
 	}, map[string]string{
 		"purpose":   "hnsw_nodes",
 		"dimension": fmt.Sprintf("%d", dimension),
 	})
 }
 
+// SchemaForNodeChanges creates schema for the nodes changed by a segment of
+// an incrementally saved index, whose level is -1 for deleted nodes
+func SchemaForNodeChanges() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		arrow.NewField("id", arrow.PrimInt32(), false),
+		arrow.NewField("level", arrow.PrimInt32(), false),
+	}, map[string]string{
+		"purpose": "hnsw_node_changes",
+	})
+}
+
 // SchemaForConnections creates schema for connection storage
 func SchemaForConnections() *arrow.Schema {
 	return arrow.NewSchema([]arrow.Field{
@@ -48,13 +89,71 @@ func SchemaForMetadata() *arrow.Schema {
 		arrow.NewField("entryPoint", arrow.PrimInt32(), false),
 		arrow.NewField("maxLevel", arrow.PrimInt32(), false),
 		arrow.NewField("numNodes", arrow.PrimInt32(), false),
+		arrow.NewField("defaultEf", arrow.PrimInt32(), false),
+		arrow.NewField("quantization", arrow.PrimInt32(), false),
+		arrow.NewField("fullVectors", arrow.PrimInt32(), false),
+		arrow.NewField("normalized", arrow.PrimInt32(), false),
+		arrow.NewField("binary", arrow.PrimInt32(), false),
+		arrow.NewField("vectorPrecision", arrow.PrimInt32(), false),
 	}, map[string]string{
 		"purpose": "hnsw_metadata",
 	})
 }
 
-// SaveToLance saves HNSW index to Lance format files
+// VectorPrecision is the precision SaveToLanceWithOptions saves vectors at
+type VectorPrecision int
+
+const (
+	// Float32 saves vectors as they are held in memory (default).
+	Float32 VectorPrecision = iota
+
+	// Float16 saves vectors at half precision, halving nodes.lance, and
+	// loads them back as float32. Each component is rounded to 11
+	// significant bits, a relative error of at most 2^-11, so distances
+	// move by about as much and only near-ties between candidates change
+	// order: recall typically stays within a few tenths of a percent of
+	// the float32 index. Components beyond ±65504 become infinite and
+	// those below about 6e-8 in magnitude become 0, which normalized
+	// embeddings never reach.
+	//
+	// vectors.f32, the float32 copy Mapped loading maps, is not written,
+	// so such an index loads into memory instead. Quantized and binary
+	// indexes save their codes as they are whatever the precision.
+	Float16
+)
+
+// SaveOptions customizes SaveToLanceWithOptions
+type SaveOptions struct {
+	// Factory encodes the Lance files; nil uses the default (zstd level 3)
+	Factory *encoding.EncoderFactory
+
+	// VectorPrecision is the precision vectors are saved at. A save to the
+	// directory of the last one appends a segment only at the same
+	// precision; otherwise the index is rewritten.
+	VectorPrecision VectorPrecision
+}
+
+// SaveToLance saves HNSW index to Lance format files. Saving again to the
+// directory the index was last saved to or loaded from only appends the
+// nodes changed since then; see Compact.
 func (h *HNSWIndex) SaveToLance(baseDir string) error {
+	return h.SaveToLanceWithOptions(baseDir, SaveOptions{})
+}
+
+// SaveToLanceWithFactory saves the index using the given encoder factory for
+// the Lance files. A nil factory uses the default (zstd level 3).
+func (h *HNSWIndex) SaveToLanceWithFactory(baseDir string, factory *encoding.EncoderFactory) error {
+	return h.SaveToLanceWithOptions(baseDir, SaveOptions{Factory: factory})
+}
+
+// SaveToLanceWithOptions saves the index as SaveToLance, with opts
+func (h *HNSWIndex) SaveToLanceWithOptions(baseDir string, opts SaveOptions) error {
+	if opts.Factory == nil {
+		opts.Factory = defaultEncoderFactory()
+	}
+
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
 	h.globalLock.RLock()
 	defer h.globalLock.RUnlock()
 
@@ -63,97 +162,244 @@ func (h *HNSWIndex) SaveToLance(baseDir string) error {
 		return fmt.Errorf("create directory failed: %w", err)
 	}
 
+	var err error
+	if h.appendable(baseDir, opts) {
+		err = h.saveSegment(baseDir, opts)
+	} else {
+		err = h.saveFull(baseDir, opts)
+	}
+	if err != nil {
+		// The changes may be half written; the next save rewrites everything
+		h.saved = saveState{}
+	}
+	return err
+}
+
+// saveFull writes the whole index to baseDir as its base, dropping the
+// segments of earlier saves
+func (h *HNSWIndex) saveFull(baseDir string, opts SaveOptions) error {
+	factory := opts.Factory
+
+	// Everything is written below, so nothing remains changed. Flags are
+	// cleared before the nodes are read, so changes made meanwhile are
+	// saved again next time.
+	for _, node := range h.nodes {
+		node.changed.Store(false)
+	}
+
+	// Without the manifest the directory holds the base alone, so segments
+	// of earlier saves are not applied to the new base
+	if err := os.Remove(filepath.Join(baseDir, manifestFileName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove manifest failed: %w", err)
+	}
+
+	ids := h.liveIDs()
+
 	// Save node data
-	if err := h.saveNodes(filepath.Join(baseDir, "nodes.lance")); err != nil {
+	if err := h.saveNodes(filepath.Join(baseDir, "nodes.lance"), ids, opts.VectorPrecision, factory); err != nil {
 		return fmt.Errorf("save nodes failed: %w", err)
 	}
 
 	// Save connection data
-	if err := h.saveConnections(filepath.Join(baseDir, "connections.lance")); err != nil {
+	if err := h.saveConnections(filepath.Join(baseDir, "connections.lance"), ids, factory); err != nil {
 		return fmt.Errorf("save connections failed: %w", err)
 	}
 
+	// Save the vectors kept outside nodes.lance, dropping those of a
+	// previous save it does not replace
+	if err := h.saveVectorFiles(baseDir, opts.VectorPrecision); err != nil {
+		return fmt.Errorf("save vectors failed: %w", err)
+	}
+
+	// Save layer-0 adjacency in a fixed-stride layout for TieredL0 loading
+	if err := writeL0File(filepath.Join(baseDir, l0FileName), h.nodes); err != nil {
+		return fmt.Errorf("save layer0 failed: %w", err)
+	}
+
+	// Save node levels and upper layers for Mapped loading
+	if err := writeUpperFile(filepath.Join(baseDir, upperFileName), h.nodes); err != nil {
+		return fmt.Errorf("save upper layers failed: %w", err)
+	}
+
 	// Save metadata
-	if err := h.saveMetadata(filepath.Join(baseDir, "metadata.lance")); err != nil {
+	metadata := h.metadataValues(opts.VectorPrecision)
+	if err := h.saveMetadata(filepath.Join(baseDir, "metadata.lance"), metadata, factory); err != nil {
 		return fmt.Errorf("save metadata failed: %w", err)
 	}
 
+	if err := removeSegmentFiles(baseDir); err != nil {
+		return fmt.Errorf("remove segments failed: %w", err)
+	}
+	base, err := baseInfo(baseDir)
+	if err != nil {
+		return err
+	}
+	h.saved = saveState{
+		dir:      filepath.Clean(baseDir),
+		options:  opts,
+		numNodes: len(h.nodes),
+		metadata: metadata,
+		base:     base,
+		manifest: segmentManifest{Version: manifestVersion, BaseNodes: len(h.nodes)},
+	}
 	return nil
 }
 
-// saveNodes saves all node data
-func (h *HNSWIndex) saveNodes(filename string) error {
-	if len(h.nodes) == 0 {
-		return fmt.Errorf("no nodes to save")
+// liveIDs returns the IDs of the nodes that are not deleted, ascending
+func (h *HNSWIndex) liveIDs() []int {
+	ids := make([]int, 0, len(h.nodes)-h.deleted)
+	for _, node := range h.nodes {
+		if !node.deleted.Load() {
+			ids = append(ids, node.id)
+		}
 	}
+	return ids
+}
 
-	schema := SchemaForNodes(h.dimension)
+// saveNodes saves the data of the nodes of ids, which ascend and hold no
+// deleted node, with vectors at precision
+func (h *HNSWIndex) saveNodes(filename string, ids []int, precision VectorPrecision, factory *encoding.EncoderFactory) error {
+	// An empty index has no node file; drop the one of a previous save
+	if len(ids) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove stale nodes failed: %w", err)
+		}
+		return nil
+	}
 
-	// Prepare data arrays
-	numNodes := len(h.nodes)
+	if h.sq8 != nil || h.binary {
+		return h.saveQuantizedNodes(filename, ids, factory)
+	}
+
+	schema := schemaForNodes(h.dimension, precision)
+
+	// Prepare data arrays; deleted nodes are left out, the gaps in the IDs
+	// mark them
+	numNodes := len(ids)
 
 	// ID array
-	ids := make([]int32, numNodes)
+	nodeIDs := make([]int32, 0, numNodes)
 	// Vector array (flattened)
 	vectors := make([]float32, numNodes*h.dimension)
 	// Level array
-	levels := make([]int32, numNodes)
+	levels := make([]int32, 0, numNodes)
 
-	for i, node := range h.nodes {
-		ids[i] = int32(node.ID())
+	for i, id := range ids {
+		nodeIDs = append(nodeIDs, int32(id))
 
 		// Copy vector data
-		nodeVector := node.Vector()
-		copy(vectors[i*h.dimension:(i+1)*h.dimension], nodeVector)
+		copy(vectors[i*h.dimension:(i+1)*h.dimension], h.vectors.at(id))
 
-		levels[i] = int32(node.Level())
+		levels = append(levels, int32(h.nodes[id].Level()))
 	}
 
 	// Create Arrow arrays
-	idArray := arrow.NewInt32Array(ids, nil)
-	vectorArray := arrow.NewFloat32Array(vectors, nil)
+	idArray := arrow.NewInt32Array(nodeIDs, nil)
+	var vectorArray arrow.Array = arrow.NewFloat32Array(vectors, nil)
+	valueBytes := 4
+	if precision == Float16 {
+		vectorArray = arrow.NewFloat16ArrayFromFloat32(vectors, nil)
+		valueBytes = 2
+	}
 	levelArray := arrow.NewInt32Array(levels, nil)
 
 	// Create FixedSizeListArray for vectors
-	vectorType := arrow.VectorType(h.dimension).(*arrow.FixedSizeListType)
+	vectorType := schema.Field(1).Type.(*arrow.FixedSizeListType)
 	vectorListArray := arrow.NewFixedSizeListArray(vectorType, vectorArray, nil)
 
 	// Create RecordBatch
-	batch, err := arrow.NewRecordBatch(schema, numNodes, []arrow.Array{
+	schema, columns := h.externalIDColumn(schema, []arrow.Array{
 		idArray,
 		vectorListArray,
 		levelArray,
-	})
+	}, ids)
+	batch, err := arrow.NewRecordBatch(schema, numNodes, columns)
 	if err != nil {
 		return fmt.Errorf("create record batch failed: %w", err)
 	}
 
-	writer, err := column.NewWriter(filename, schema, defaultEncoderFactory())
+	if err := writeBatchFile(filename, schema, batch, pageRows(h.dimension*valueBytes), factory); err != nil {
+		return fmt.Errorf("write nodes failed: %w", err)
+	}
+
+	return nil
+}
+
+// saveQuantizedNodes saves the IDs and levels of the nodes of a quantized
+// or binary index
+func (h *HNSWIndex) saveQuantizedNodes(filename string, ids []int, factory *encoding.EncoderFactory) error {
+	schema := SchemaForQuantizedNodes(h.dimension)
+	numNodes := len(ids)
+	nodeIDs := make([]int32, 0, numNodes)
+	levels := make([]int32, 0, numNodes)
+	for _, id := range ids {
+		nodeIDs = append(nodeIDs, int32(id))
+		levels = append(levels, int32(h.nodes[id].Level()))
+	}
+
+	schema, columns := h.externalIDColumn(schema, []arrow.Array{
+		arrow.NewInt32Array(nodeIDs, nil),
+		arrow.NewInt32Array(levels, nil),
+	}, ids)
+	batch, err := arrow.NewRecordBatch(schema, numNodes, columns)
 	if err != nil {
-		return fmt.Errorf("create writer failed: %w", err)
+		return fmt.Errorf("create record batch failed: %w", err)
 	}
-	defer writer.Close()
 
-	if err := writer.WriteRecordBatch(batch); err != nil {
+	if err := writeBatchFile(filename, schema, batch, pageRows(8), factory); err != nil {
 		return fmt.Errorf("write nodes failed: %w", err)
 	}
 
 	return nil
 }
 
-// saveConnections saves connection relationships
-func (h *HNSWIndex) saveConnections(filename string) error {
+// saveVectorFiles writes the vectors kept outside nodes.lance: codes.sq8,
+// and vectors.f32 in FullVectorsOnDisk mode, for a quantized index,
+// vectors.bin for a binary one, and vectors.f32 for Mapped loading of any
+// other saved at Float32. Files the index does not use are removed.
+func (h *HNSWIndex) saveVectorFiles(baseDir string, precision VectorPrecision) error {
+	save := map[string]func(path string) error{}
+	switch {
+	case h.sq8 != nil:
+		save[codesFileName] = h.saveCodes
+		if h.fullVectors == FullVectorsOnDisk {
+			save[vectorsFileName] = h.saveFullVectors
+		}
+	case h.binary:
+		save[binaryFileName] = h.saveBinaryVectors
+	case precision == Float32:
+		save[vectorsFileName] = h.saveFullVectors
+	}
+
+	for _, name := range []string{codesFileName, vectorsFileName, binaryFileName} {
+		path := filepath.Join(baseDir, name)
+		if fn := save[name]; fn != nil {
+			if err := fn(path); err != nil {
+				return err
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveConnections saves the connection relationships of the nodes of ids,
+// which ascend and hold no deleted node
+func (h *HNSWIndex) saveConnections(filename string, ids []int, factory *encoding.EncoderFactory) error {
 	schema := SchemaForConnections()
 
 	// Collect all connections
 	var nodeIDs, layers, neighborIDs []int32
 
-	for _, node := range h.nodes {
-		nodeID := int32(node.ID())
+	for _, id := range ids {
+		node := h.nodes[id]
+		nodeID := int32(id)
 
-		// Iterate through all layers of this node
+		// Iterate through all layers of this node, leaving out links to
+		// deleted nodes
 		for layer := 0; layer <= node.Level(); layer++ {
-			connections := node.GetConnections(layer)
+			connections := live(h.nodes, node.neighbors(layer))
 
 			// Add all connections at this layer
 			for _, neighborID := range connections {
@@ -165,7 +411,11 @@ func (h *HNSWIndex) saveConnections(filename string) error {
 	}
 
 	// If no connections, don't create file (avoid empty array validation error)
+	// and drop the one of a previous save
 	if len(nodeIDs) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove stale connections failed: %w", err)
+		}
 		return nil
 	}
 
@@ -184,34 +434,43 @@ func (h *HNSWIndex) saveConnections(filename string) error {
 		return fmt.Errorf("create record batch failed: %w", err)
 	}
 
-	writer, err := column.NewWriter(filename, schema, defaultEncoderFactory())
-	if err != nil {
-		return fmt.Errorf("create writer failed: %w", err)
-	}
-	defer writer.Close()
-
-	if err := writer.WriteRecordBatch(batch); err != nil {
+	if err := writeBatchFile(filename, schema, batch, pageRows(4), factory); err != nil {
 		return fmt.Errorf("write connections failed: %w", err)
 	}
 
 	return nil
 }
 
-// saveMetadata saves HNSW configuration metadata
-func (h *HNSWIndex) saveMetadata(filename string) error {
-	schema := SchemaForMetadata()
-
-	// Prepare metadata (single row record)
-	metadata := []int32{
+// metadataValues returns the metadata row of the index saved with vectors
+// at precision, a value for each field of SchemaForMetadata
+func (h *HNSWIndex) metadataValues(precision VectorPrecision) []int32 {
+	// A binary index saves its dimension in bits, as configured
+	dimension := h.dimension
+	if h.binary {
+		dimension *= 32
+	}
+	return []int32{
 		int32(h.M),
 		int32(h.Mmax),
 		int32(h.Mmax0),
 		int32(h.efConstruction),
-		int32(h.dimension),
+		int32(dimension),
 		h.entryPoint,
 		h.maxLevel,
 		int32(len(h.nodes)),
+		h.defaultEf.Load(),
+		int32(h.quantization),
+		int32(h.fullVectors),
+		boolInt32(h.normalized),
+		boolInt32(h.binary),
+		int32(precision),
 	}
+}
+
+// saveMetadata saves HNSW configuration metadata, as returned by
+// metadataValues
+func (h *HNSWIndex) saveMetadata(filename string, metadata []int32, factory *encoding.EncoderFactory) error {
+	schema := SchemaForMetadata()
 
 	// Create Arrow arrays (each field is an array of length 1)
 	mArray := arrow.NewInt32Array([]int32{metadata[0]}, nil)
@@ -222,6 +481,12 @@ func (h *HNSWIndex) saveMetadata(filename string) error {
 	entryPointArray := arrow.NewInt32Array([]int32{metadata[5]}, nil)
 	maxLevelArray := arrow.NewInt32Array([]int32{metadata[6]}, nil)
 	numNodesArray := arrow.NewInt32Array([]int32{metadata[7]}, nil)
+	defaultEfArray := arrow.NewInt32Array([]int32{metadata[8]}, nil)
+	quantizationArray := arrow.NewInt32Array([]int32{metadata[9]}, nil)
+	fullVectorsArray := arrow.NewInt32Array([]int32{metadata[10]}, nil)
+	normalizedArray := arrow.NewInt32Array([]int32{metadata[11]}, nil)
+	binaryArray := arrow.NewInt32Array([]int32{metadata[12]}, nil)
+	vectorPrecisionArray := arrow.NewInt32Array([]int32{metadata[13]}, nil)
 
 	// Create RecordBatch
 	batch, err := arrow.NewRecordBatch(schema, 1, []arrow.Array{
@@ -233,28 +498,121 @@ func (h *HNSWIndex) saveMetadata(filename string) error {
 		entryPointArray,
 		maxLevelArray,
 		numNodesArray,
+		defaultEfArray,
+		quantizationArray,
+		fullVectorsArray,
+		normalizedArray,
+		binaryArray,
+		vectorPrecisionArray,
 	})
 	if err != nil {
 		return fmt.Errorf("create record batch failed: %w", err)
 	}
 
-	writer, err := column.NewWriter(filename, schema, defaultEncoderFactory())
+	if err := writeBatchFile(filename, schema, batch, 1, factory); err != nil {
+		return fmt.Errorf("write metadata failed: %w", err)
+	}
+
+	return nil
+}
+
+// boolInt32 returns 1 for true and 0 for false, for metadata flags
+func boolInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pageRows returns how many rows of a column whose values are rowBytes wide
+// fit a page of the default size
+func pageRows(rowBytes int) int {
+	return max(format.DefaultPageSize/rowBytes, 1)
+}
+
+// writeBatchFile writes batch to a temporary file that replaces filename
+// once complete, rowsPerPage rows at a time so that large indexes stay under
+// the page size limit and pages can be decoded in parallel when loading.
+// The previous file is unlinked rather than overwritten, so hard links to
+// it (collection checkpoints) keep their content.
+func writeBatchFile(filename string, schema *arrow.Schema, batch *arrow.RecordBatch, rowsPerPage int, factory *encoding.EncoderFactory) error {
+	tmpFile := filename + ".tmp"
+	writer, err := column.NewWriter(tmpFile, schema, factory)
 	if err != nil {
 		return fmt.Errorf("create writer failed: %w", err)
 	}
-	defer writer.Close()
 
-	if err := writer.WriteRecordBatch(batch); err != nil {
-		return fmt.Errorf("write metadata failed: %w", err)
+	for start := 0; start < batch.NumRows(); start += rowsPerPage {
+		chunk := batch.Slice(start, min(rowsPerPage, batch.NumRows()-start))
+		if err := writer.WriteRecordBatch(chunk); err != nil {
+			writer.Close()
+			os.Remove(tmpFile)
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("close writer failed: %w", err)
+	}
+	if err := lanceio.ReplaceFile(tmpFile, filename); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("replace file failed: %w", err)
 	}
-
 	return nil
 }
 
-// LoadFromLance loads HNSW index from Lance format files
-func LoadHNSWFromLance(baseDir string) (*HNSWIndex, error) {
-	// Load metadata to determine HNSW configuration
-	metadata, err := loadMetadata(filepath.Join(baseDir, "metadata.lance"))
+// LoadOption customizes how LoadHNSWFromLance builds the index.
+type LoadOption func(*Config)
+
+// WithGraphStorage selects where layer-0 adjacency lives after loading.
+func WithGraphStorage(mode GraphStorage) LoadOption {
+	return func(c *Config) {
+		c.GraphStorage = mode
+	}
+}
+
+// WithL0CacheSize sets how many layer-0 lists TieredL0 mode keeps decoded.
+func WithL0CacheSize(n int) LoadOption {
+	return func(c *Config) {
+		c.L0CacheSize = n
+	}
+}
+
+// WithLoadWorkers sets how many goroutines decode pages and rebuild nodes
+// and adjacency. 1 loads serially; the loaded index is the same either way.
+func WithLoadWorkers(n int) LoadOption {
+	return func(c *Config) {
+		c.LoadWorkers = n
+	}
+}
+
+// WithLoadDistance sets the distance function of the loaded index. Index
+// files do not record it, so it must be the one the index was built with;
+// nil keeps the default L2Distance.
+func WithLoadDistance(fn DistanceFunc) LoadOption {
+	return func(c *Config) {
+		if fn != nil {
+			c.DistanceFunc = fn
+		}
+	}
+}
+
+// LoadFromLance loads HNSW index from Lance format files, applying the
+// segments of incremental saves to the base
+func LoadHNSWFromLance(baseDir string, opts ...LoadOption) (*HNSWIndex, error) {
+	manifest, err := readManifest(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("load manifest failed: %w", err)
+	}
+	segments := len(manifest.Segments) > 0
+
+	// Load metadata to determine HNSW configuration, that of the latest
+	// segment if there are any
+	metadataFile := "metadata.lance"
+	if segments {
+		metadataFile = segmentFileName("metadata", manifest.Segments[len(manifest.Segments)-1].ID)
+	}
+	metadata, err := loadMetadata(filepath.Join(baseDir, metadataFile))
 	if err != nil {
 		return nil, fmt.Errorf("load metadata failed: %w", err)
 	}
@@ -265,27 +623,177 @@ func LoadHNSWFromLance(baseDir string) (*HNSWIndex, error) {
 		EfConstruction: int(metadata[3]),
 		Dimension:      int(metadata[4]),
 		DistanceFunc:   L2Distance,
+		Quantization:   Quantization(metadata[9]),
+		FullVectors:    FullVectors(metadata[10]),
+	}
+	if metadata[12] != 0 {
+		config.BinaryVectors = true
+		config.DistanceFunc = nil // Hamming distance
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	hnsw, err := NewHNSWChecked(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index configuration: %w", err)
+	}
+	hnsw.defaultEf.Store(metadata[8])
+	workers := config.LoadWorkers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
 	}
-
-	hnsw := NewHNSW(config)
 
 	// Set state loaded from metadata
 	hnsw.entryPoint = metadata[5]
 	hnsw.maxLevel = metadata[6]
 
-	// Load node data
-	if err := hnsw.loadNodes(filepath.Join(baseDir, "nodes.lance")); err != nil {
+	// Later saves to the directory append to what was loaded
+	if !segments {
+		manifest = segmentManifest{Version: manifestVersion, BaseNodes: int(metadata[7])}
+	}
+	base, err := baseInfo(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("load metadata failed: %w", err)
+	}
+	precision := VectorPrecision(metadata[13])
+	hnsw.saved = saveState{
+		dir:      filepath.Clean(baseDir),
+		options:  SaveOptions{VectorPrecision: precision},
+		numNodes: int(metadata[7]),
+		metadata: metadata,
+		base:     base,
+		manifest: manifest,
+	}
+
+	// An empty index was saved without node and connection files; one whose
+	// nodes were all deleted keeps its node count
+	if metadata[7] == 0 || metadata[5] < 0 {
+		hnsw.nodes = deletedNodes(int(metadata[7]))
+		hnsw.deleted = len(hnsw.nodes)
+		hnsw.globalLock.Lock()
+		hnsw.publish()
+		hnsw.globalLock.Unlock()
+		return hnsw, nil
+	}
+
+	// In mapped mode vectors and lists are served from the files, read only
+	// as searches reach them; if they cannot be used, load everything
+	if hnsw.graphStorage == Mapped {
+		err := hnsw.loadMapped(baseDir, int(metadata[7]), metadata[11] != 0, segments, precision)
+		if err == nil {
+			hnsw.matchNormalization(metadata[11] != 0)
+			hnsw.globalLock.Lock()
+			hnsw.publish()
+			hnsw.globalLock.Unlock()
+			return hnsw, nil
+		}
+		log.Printf("Warning: mapped load unavailable, loading into memory: %v", err)
+	}
+
+	// Load node data; the base of a segmented index may have been empty
+	nodesFile := filepath.Join(baseDir, "nodes.lance")
+	if _, err := os.Stat(nodesFile); segments && os.IsNotExist(err) {
+		hnsw.nodes = deletedNodes(int(metadata[7]))
+		hnsw.deleted = len(hnsw.nodes)
+	} else if err := hnsw.loadNodes(nodesFile, int(metadata[7]), workers, precision); err != nil {
 		return nil, fmt.Errorf("load nodes failed: %w", err)
 	}
+	var owner []int
+	if segments {
+		if owner, err = hnsw.loadSegments(baseDir, manifest, workers, precision); err != nil {
+			return nil, fmt.Errorf("load segments failed: %w", err)
+		}
+	}
+	if hnsw.binary {
+		if err := hnsw.loadBinaryVectors(filepath.Join(baseDir, binaryFileName), len(hnsw.nodes)); err != nil {
+			return nil, fmt.Errorf("load vectors failed: %w", err)
+		}
+	}
+	if hnsw.sq8 != nil {
+		if err := hnsw.loadCodes(filepath.Join(baseDir, codesFileName), len(hnsw.nodes)); err != nil {
+			return nil, fmt.Errorf("load codes failed: %w", err)
+		}
+		if hnsw.fullVectors == FullVectorsOnDisk {
+			file, err := openVectorFile(filepath.Join(baseDir, vectorsFileName), len(hnsw.nodes), hnsw.dimension)
+			if err != nil {
+				return nil, fmt.Errorf("open full vectors failed: %w", err)
+			}
+			hnsw.vectorFile = file
+		}
+	}
+	hnsw.matchNormalization(metadata[11] != 0)
+	if boolInt32(hnsw.normalized) != metadata[11] {
+		// The saved vectors differ from those in memory now
+		hnsw.saved = saveState{}
+	}
+
+	// In tiered mode layer 0 is served from the mapped file; if it cannot be
+	// used, fall back to loading every layer from connections.lance. Saves
+	// that append segments remove it until the index is compacted.
+	skipLayer0 := false
+	if hnsw.graphStorage == TieredL0 && segments {
+		log.Printf("Warning: tiered layer0 unavailable until the index is compacted, loading graph into memory")
+	} else if hnsw.graphStorage == TieredL0 {
+		store, err := openL0Store(filepath.Join(baseDir, l0FileName), len(hnsw.nodes), hnsw.l0CacheSize)
+		if err != nil {
+			log.Printf("Warning: tiered layer0 unavailable, loading graph into memory: %v", err)
+		} else {
+			hnsw.l0 = store
+			for _, node := range hnsw.nodes {
+				node.disk = store
+			}
+			skipLayer0 = true
+		}
+	}
 
-	// Load connection data
-	if err := hnsw.loadConnections(filepath.Join(baseDir, "connections.lance")); err != nil {
+	// Load connection data, each node's from the base or the segment that
+	// changed it last
+	inBase := func(id int) bool { return owner == nil || owner[id] == 0 }
+	if err := hnsw.loadConnections(filepath.Join(baseDir, "connections.lance"), skipLayer0, workers, inBase); err != nil {
+		hnsw.Close()
 		return nil, fmt.Errorf("load connections failed: %w", err)
 	}
+	for i, segment := range manifest.Segments {
+		filename := filepath.Join(baseDir, segmentFileName("connections", segment.ID))
+		if err := hnsw.loadConnections(filename, false, workers, func(id int) bool { return owner[id] == i+1 }); err != nil {
+			hnsw.Close()
+			return nil, fmt.Errorf("load connections of segment %d failed: %w", segment.ID, err)
+		}
+	}
+
+	// Loading is not a change to save
+	for _, node := range hnsw.nodes {
+		node.changed.Store(false)
+	}
+
+	hnsw.globalLock.Lock()
+	hnsw.publish()
+	hnsw.globalLock.Unlock()
 
 	return hnsw, nil
 }
 
+// matchNormalization reconciles the loaded vectors, normalized or not as
+// saved, with the distance function the index was loaded with. Vectors
+// saved unnormalized for a cosine index, by versions that did not normalize
+// them, are normalized now; quantized ones are compared as saved instead.
+func (h *HNSWIndex) matchNormalization(saved bool) {
+	switch {
+	case saved == h.normalized:
+	case saved:
+		log.Printf("Warning: index was saved with normalized vectors for cosine distance, loaded with %q", h.metric)
+	case h.sq8 != nil:
+		h.normalized = false
+		h.compare = h.distFunc
+	default:
+		for id, node := range h.nodes {
+			if !node.deleted.Load() {
+				h.vectors.set(id, normalize(h.vectors.at(id)))
+			}
+		}
+	}
+}
+
 // loadMetadata loads metadata
 func loadMetadata(filename string) ([]int32, error) {
 	reader, err := column.NewReader(filename)
@@ -299,9 +807,10 @@ func loadMetadata(filename string) ([]int32, error) {
 		return nil, fmt.Errorf("read metadata failed: %w", err)
 	}
 
-	// Extract all metadata values
-	metadata := make([]int32, 8)
-	for i := 0; i < 8; i++ {
+	// Extract all metadata values; files written before defaultEf or
+	// quantization were recorded have fewer columns and leave them 0
+	metadata := make([]int32, len(SchemaForMetadata().Fields()))
+	for i := 0; i < min(len(metadata), batch.NumCols()); i++ {
 		array := batch.Column(i).(*arrow.Int32Array)
 		metadata[i] = array.Value(0)
 	}
@@ -309,87 +818,193 @@ func loadMetadata(filename string) ([]int32, error) {
 	return metadata, nil
 }
 
-// loadNodes loads node data
-func (h *HNSWIndex) loadNodes(filename string) error {
-	reader, err := column.NewReader(filename)
+// readBatchFile reads the single record batch of a Lance file, decoding
+// its pages with up to workers goroutines. With schema set, the columns of
+// schema are read, see column.ReaderOption.Schema; else all.
+func readBatchFile(filename string, workers int, schema *arrow.Schema) (*arrow.RecordBatch, error) {
+	reader, err := column.NewReaderWithOptions(filename, nil, column.ReaderOption{DecodeWorkers: workers, Schema: schema})
 	if err != nil {
-		return fmt.Errorf("create reader failed: %w", err)
+		return nil, fmt.Errorf("create reader failed: %w", err)
 	}
 	defer reader.Close()
 
-	batch, err := reader.ReadRecordBatch()
+	return reader.ReadRecordBatch()
+}
+
+// parallelRanges splits [0, n) into up to workers contiguous ranges and
+// runs fn on each concurrently. It returns the error of the lowest range
+// that failed, so errors match those of a serial pass.
+func parallelRanges(n, workers int, fn func(start, end int) error) error {
+	workers = max(min(workers, n), 1)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = fn(n*w/workers, n*(w+1)/workers)
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadNodes loads the node data of an index of numNodes nodes, saved with
+// vectors at precision. IDs missing from the file are those of deleted
+// nodes, which are restored as deleted nodes without vector or links.
+func (h *HNSWIndex) loadNodes(filename string, numNodes, workers int, precision VectorPrecision) error {
+	// Quantized and binary indexes save only IDs and levels; their vectors
+	// are loaded from codes.sq8 or vectors.bin
+	packed := h.sq8 != nil || h.binary
+	schema := schemaForNodes(h.dimension, precision)
+	if packed {
+		schema = SchemaForQuantizedNodes(h.dimension)
+	}
+	batch, err := readBatchFile(filename, workers, schema)
 	if err != nil {
 		return fmt.Errorf("read nodes failed: %w", err)
 	}
 
 	idArray := batch.Column(0).(*arrow.Int32Array)
-	vectorListArray := batch.Column(1).(*arrow.FixedSizeListArray)
-	levelArray := batch.Column(2).(*arrow.Int32Array)
-
-	// Get underlying float array
-	vectorArray := vectorListArray.Values().(*arrow.Float32Array)
-	vectorValues := vectorArray.Values()
+	levelArray := batch.Column(batch.NumCols() - 2).(*arrow.Int32Array)
+	var vectorValues []float32
+	if !packed {
+		vectorValues = nodeVectors(batch.Column(1))
+	}
 
-	// Verify continuity of node IDs
-	numNodes := idArray.Len()
-	for i := 0; i < numNodes; i++ {
+	// Verify node IDs ascend within the node count; files written before
+	// Delete existed hold every ID
+	rows := idArray.Len()
+	for i := 0; i < rows; i++ {
 		id := int(idArray.Value(i))
-		if id != i {
-			return fmt.Errorf("node ID mismatch at index %d: expected %d, got %d", i, i, id)
+		if id < i || id >= numNodes || (i > 0 && id <= int(idArray.Value(i-1))) {
+			return fmt.Errorf("node ID mismatch at index %d: got %d, IDs must ascend below %d", i, id, numNodes)
 		}
 	}
 
-	// Reconstruct nodes
-	h.nodes = make([]*Node, numNodes)
+	// Reconstruct nodes, each worker a range of them
+	h.nodes = deletedNodes(numNodes)
+	h.deleted = numNodes - rows
+	if !packed {
+		h.vectors.reserve(numNodes)
+	}
+	h.loadExternalIDs(idArray, batch.Column(batch.NumCols()-1))
 
-	for i := 0; i < numNodes; i++ {
-		id := int(idArray.Value(i))
-		level := int(levelArray.Value(i))
+	return parallelRanges(rows, workers, func(start, end int) error {
+		for i := start; i < end; i++ {
+			id := int(idArray.Value(i))
+			level := int(levelArray.Value(i))
 
-		// Extract vector
-		start := i * h.dimension
-		end := start + h.dimension
-		vector := make([]float32, h.dimension)
-		copy(vector, vectorValues[start:end])
+			if !packed {
+				copy(h.vectors.at(id), vectorValues[i*h.dimension:(i+1)*h.dimension])
+			}
+			h.nodes[id] = newNode(id, level, h.nodeArena())
+		}
+		return nil
+	})
+}
 
-		// Create node
-		node := NewNode(id, vector, level)
-		h.nodes[i] = node
+// nodeVectors returns the flattened vectors of the vector column of a nodes
+// file as float32, converting those saved at Float16
+func nodeVectors(column arrow.Array) []float32 {
+	switch values := column.(*arrow.FixedSizeListArray).Values().(type) {
+	case *arrow.Float16Array:
+		return values.Float32Values()
+	default:
+		return values.(*arrow.Float32Array).Values()
 	}
+}
 
-	return nil
+// deletedNodes returns n placeholder nodes marked as deleted, for the IDs
+// of nodes deleted before a save
+func deletedNodes(n int) []*Node {
+	nodes := make([]*Node, n)
+	for i := range nodes {
+		nodes[i] = newNode(i, 0, nil)
+		nodes[i].deleted.Store(true)
+	}
+	return nodes
 }
 
-// loadConnections loads connection relationships
-func (h *HNSWIndex) loadConnections(filename string) error {
+// loadConnections loads connection relationships of the nodes keep reports.
+// Layer-0 entries are skipped when skipLayer0 is set because they are
+// served from layer0.adj.
+// Connections are saved grouped by node, so each worker rebuilds the lists
+// of a range of nodes; files that are not grouped are loaded serially.
+func (h *HNSWIndex) loadConnections(filename string, skipLayer0 bool, workers int, keep func(id int) bool) error {
 	// Check if file exists (handle case with no connections)
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		// File doesn't exist, meaning no connections were saved, which is valid
 		return nil
 	}
 
-	reader, err := column.NewReader(filename)
+	batch, err := readBatchFile(filename, workers, nil)
 	if err != nil {
-		return fmt.Errorf("create reader failed: %w", err)
+		return fmt.Errorf("read connections failed: %w", err)
 	}
-	defer reader.Close()
 
-	batch, err := reader.ReadRecordBatch()
-	if err != nil {
-		return fmt.Errorf("read connections failed: %w", err)
+	nodeIDs := batch.Column(0).(*arrow.Int32Array).Values()
+	layers := batch.Column(1).(*arrow.Int32Array).Values()
+	neighborIDs := batch.Column(2).(*arrow.Int32Array).Values()
+
+	// Split the rows at node boundaries
+	bounds := []int{0}
+	grouped := true
+	for i := 1; i < len(nodeIDs) && grouped; i++ {
+		grouped = nodeIDs[i] >= nodeIDs[i-1]
 	}
+	if grouped && workers > 1 {
+		for w := 1; w < workers; w++ {
+			b := max(len(nodeIDs)*w/workers, bounds[len(bounds)-1])
+			for b > 0 && b < len(nodeIDs) && nodeIDs[b] == nodeIDs[b-1] {
+				b++
+			}
+			if b > bounds[len(bounds)-1] && b < len(nodeIDs) {
+				bounds = append(bounds, b)
+			}
+		}
+	}
+	bounds = append(bounds, len(nodeIDs))
 
-	nodeIDArray := batch.Column(0).(*arrow.Int32Array)
-	layerArray := batch.Column(1).(*arrow.Int32Array)
-	neighborIDArray := batch.Column(2).(*arrow.Int32Array)
+	return parallelRanges(len(bounds)-1, len(bounds)-1, func(first, last int) error {
+		for r := first; r < last; r++ {
+			if err := h.linkConnections(nodeIDs, layers, neighborIDs, bounds[r], bounds[r+1], skipLayer0, grouped, keep); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	numConnections := nodeIDArray.Len()
+// linkConnections adds connection rows [start, end) to the nodes keep
+// reports. With
+// grouped set, the rows of a node are contiguous and within the range, so
+// each neighbor list is built once and published whole; otherwise the rows
+// are appended one at a time.
+func (h *HNSWIndex) linkConnections(nodeIDs, layers, neighborIDs []int32, start, end int, skipLayer0, grouped bool, keep func(id int) bool) error {
+	var lists [][]int
+	current := -1
+	publish := func() {
+		if current < 0 {
+			return
+		}
+		for layer, list := range lists {
+			if list != nil {
+				h.nodes[current].connections[layer].Store(&list)
+			}
+		}
+	}
 
-	// Rebuild connection relationships
-	for i := 0; i < numConnections; i++ {
-		nodeID := int(nodeIDArray.Value(i))
-		layer := int(layerArray.Value(i))
-		neighborID := int(neighborIDArray.Value(i))
+	for i := start; i < end; i++ {
+		nodeID := int(nodeIDs[i])
+		layer := int(layers[i])
+		neighborID := int(neighborIDs[i])
 
 		if nodeID < 0 || nodeID >= len(h.nodes) {
 			return fmt.Errorf("invalid node_id %d at connection index %d (valid range: [0, %d])",
@@ -399,14 +1014,30 @@ func (h *HNSWIndex) loadConnections(filename string) error {
 			return fmt.Errorf("invalid neighbor_id %d at connection index %d (valid range: [0, %d])",
 				neighborID, i, len(h.nodes))
 		}
+		if !keep(nodeID) {
+			continue
+		}
 		if layer < 0 || layer > h.nodes[nodeID].Level() {
 			return fmt.Errorf("invalid layer %d for node %d at connection index %d (valid range: [0, %d])",
 				layer, nodeID, i, h.nodes[nodeID].Level())
 		}
 
-		node := h.nodes[nodeID]
-		node.AddConnection(layer, neighborID)
+		if layer == 0 && skipLayer0 {
+			continue
+		}
+
+		if !grouped {
+			h.nodes[nodeID].AddConnection(layer, neighborID)
+			continue
+		}
+		if nodeID != current {
+			publish()
+			current = nodeID
+			lists = make([][]int, h.nodes[nodeID].Level()+1)
+		}
+		lists[layer] = append(lists[layer], neighborID)
 	}
+	publish()
 
 	return nil
 }
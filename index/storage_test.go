@@ -1,9 +1,14 @@
 package hnsw
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
 )
 
 func TestHNSWStorageBasic(t *testing.T) {
@@ -160,16 +165,20 @@ func TestHNSWStorageEmptyIndex(t *testing.T) {
 
 	hnsw := NewHNSW(config)
 
-	// Attempting to save empty index should fail
-	err := hnsw.SaveToLance(tempDir)
-	if err == nil {
-		t.Error("Expected error when saving empty HNSW, but got none")
+	// An empty index saves and loads back empty
+	if err := hnsw.SaveToLance(tempDir); err != nil {
+		t.Fatalf("Saving empty HNSW failed: %v", err)
 	}
-	if err != nil && err.Error() != "save nodes failed: no nodes to save" {
-		t.Errorf("Expected 'no nodes to save' error, got: %v", err)
+	loaded, err := LoadHNSWFromLance(tempDir)
+	if err != nil {
+		t.Fatalf("Loading empty HNSW failed: %v", err)
+	}
+	defer loaded.Close()
+	if loaded.Len() != 0 || loaded.dimension != 3 {
+		t.Errorf("Expected empty index of dimension 3, got %d nodes of dimension %d", loaded.Len(), loaded.dimension)
 	}
 
-	t.Logf("✓ Empty index test passed: correctly rejected empty HNSW")
+	t.Logf("✓ Empty index test passed: empty HNSW round-tripped")
 }
 
 func TestHNSWStorageLargeDataset(t *testing.T) {
@@ -545,3 +554,333 @@ func abs(x float32) float32 {
 	}
 	return x
 }
+
+func TestHNSWStoragePathWithSpacesAndUnicode(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "index dir", "índice 索引")
+
+	index := NewHNSW(Config{M: 8, Dimension: 4, Seed: 3})
+	for i := 0; i < 50; i++ {
+		if _, err := index.Add([]float32{float32(i), 1, 2, 3}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// Saving twice replaces every file in place
+	for i := 0; i < 2; i++ {
+		if err := index.SaveToLance(dir); err != nil {
+			t.Fatalf("SaveToLance failed: %v", err)
+		}
+	}
+
+	for _, mode := range []GraphStorage{InMemory, TieredL0} {
+		loaded, err := LoadHNSWFromLance(dir, WithGraphStorage(mode))
+		if err != nil {
+			t.Fatalf("LoadHNSWFromLance(%v) failed: %v", mode, err)
+		}
+		if loaded.GraphStorage() != mode {
+			t.Errorf("Expected %v storage, got %v", mode, loaded.GraphStorage())
+		}
+		results, err := loaded.Search([]float32{7, 1, 2, 3}, 1, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if results[0].ID != 7 {
+			t.Errorf("%v: expected node 7, got %d", mode, results[0].ID)
+		}
+		loaded.Close()
+	}
+}
+
+func TestLoadHNSWFromLanceParallel(t *testing.T) {
+	tempDir := t.TempDir()
+	vectors := generateRandomVectors(4000, 16, 7)
+	index, err := BuildBulk(context.Background(), vectors, Config{Dimension: 16, M: 8, EfConstruction: 64, Seed: 7}, BulkOptions{})
+	if err != nil {
+		t.Fatalf("BuildBulk failed: %v", err)
+	}
+	if err := index.SaveToLance(tempDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	serial, err := LoadHNSWFromLance(tempDir, WithLoadWorkers(1))
+	if err != nil {
+		t.Fatalf("serial load failed: %v", err)
+	}
+	defer serial.Close()
+	if serial.GraphHash() != index.GraphHash() {
+		t.Fatal("serial load differs from the saved index")
+	}
+
+	queries := generateRandomVectors(50, 16, 8)
+	for _, workers := range []int{2, 3, 8, 64} {
+		for _, mode := range []GraphStorage{InMemory, TieredL0} {
+			loaded, err := LoadHNSWFromLance(tempDir, WithLoadWorkers(workers), WithGraphStorage(mode))
+			if err != nil {
+				t.Fatalf("load with %d workers failed: %v", workers, err)
+			}
+			if loaded.GraphHash() != serial.GraphHash() {
+				t.Errorf("%d workers, storage %v: graph hash differs from the serial load", workers, mode)
+			}
+			for _, q := range queries {
+				want, _ := serial.Search(q, 10, 50)
+				got, err := loaded.Search(q, 10, 50)
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("%d workers, storage %v: results %v, serial %v", workers, mode, got, want)
+					break
+				}
+			}
+			loaded.Close()
+		}
+	}
+
+	if _, err := LoadHNSWFromLance(tempDir, WithLoadWorkers(-1)); err == nil {
+		t.Error("negative LoadWorkers accepted")
+	}
+}
+
+func TestLoadHNSWFromLanceDistance(t *testing.T) {
+	tempDir := t.TempDir()
+	vectors := generateRandomVectors(500, 8, 3)
+	index := NewHNSW(Config{Dimension: 8, M: 8, EfConstruction: 64, DistanceFunc: CosineDistance, Seed: 3})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	if err := index.SaveToLance(tempDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := LoadHNSWFromLance(tempDir, WithLoadDistance(CosineDistance))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer loaded.Close()
+	for _, q := range generateRandomVectors(20, 8, 4) {
+		want, _ := index.Search(q, 5, 50)
+		got, err := loaded.Search(q, 5, 50)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("results %v after load, %v before", got, want)
+		}
+	}
+}
+
+func TestHNSWDefaultEf(t *testing.T) {
+	tempDir := t.TempDir()
+	vectors := generateRandomVectors(500, 8, 5)
+	index := NewHNSW(Config{Dimension: 8, Adaptive: true, ExpectedSize: 500, DistanceFunc: CosineDistance, Seed: 5})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+
+	m, efConstruction, defaultEf, metric := index.Params()
+	if m != index.M || efConstruction != index.EfConstruction() || defaultEf != 0 || metric != "cosine" {
+		t.Fatalf("Params() = %d, %d, %d, %q", m, efConstruction, defaultEf, metric)
+	}
+
+	// ef 0 searches with the default once set
+	queries := generateRandomVectors(20, 8, 6)
+	index.SetDefaultEf(12)
+	for _, q := range queries {
+		want, _ := index.Search(q, 10, 12)
+		got, err := index.Search(q, 10, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("Search with ef 0 = %v, with the default ef %v", got, want)
+		}
+	}
+
+	if err := index.SaveToLance(tempDir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadHNSWFromLance(tempDir, WithLoadDistance(CosineDistance))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer loaded.Close()
+	if gotM, gotEfc, gotEf, gotMetric := loaded.Params(); gotM != m || gotEfc != efConstruction || gotEf != 12 || gotMetric != "cosine" {
+		t.Errorf("Params() after load = %d, %d, %d, %q", gotM, gotEfc, gotEf, gotMetric)
+	}
+	for _, q := range queries {
+		want, _ := index.Search(q, 10, 0)
+		got, _ := loaded.Search(q, 10, 0)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("results %v after load, %v before", got, want)
+		}
+	}
+
+	// Metadata written before the default was recorded loads without one
+	schema := SchemaForMetadata()
+	legacy := arrow.NewSchema(schema.Fields()[:8], schema.Metadata())
+	columns := make([]arrow.Array, 8)
+	for i, v := range []int32{int32(m), int32(m), int32(2 * m), int32(efConstruction), 8, loaded.entryPoint, loaded.maxLevel, int32(len(loaded.nodes))} {
+		columns[i] = arrow.NewInt32Array([]int32{v}, nil)
+	}
+	batch, err := arrow.NewRecordBatch(legacy, 1, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBatchFile(filepath.Join(tempDir, "metadata.lance"), legacy, batch, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	old, err := LoadHNSWFromLance(tempDir, WithLoadDistance(CosineDistance))
+	if err != nil {
+		t.Fatalf("Load of legacy metadata failed: %v", err)
+	}
+	defer old.Close()
+	if _, _, ef, _ := old.Params(); ef != 0 {
+		t.Errorf("default ef %d from legacy metadata, want 0", ef)
+	}
+
+	index.SetDefaultEf(-1)
+	if _, _, ef, _ := index.Params(); ef != 0 {
+		t.Errorf("default ef %d after SetDefaultEf(-1), want 0", ef)
+	}
+}
+
+func TestCosineNormalizedSaveLoad(t *testing.T) {
+	vectors := generateRandomVectors(200, 8, 41)
+	for _, v := range vectors {
+		v[0] *= 10
+	}
+
+	// Saved normalized vectors are loaded as they are
+	dir := t.TempDir()
+	index := NewHNSW(Config{Dimension: 8, M: 8, DistanceFunc: CosineDistance, Seed: 41})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	loaded, err := LoadHNSWFromLance(dir, WithLoadDistance(CosineDistance))
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	sameVectors := func(loaded *HNSWIndex) {
+		t.Helper()
+		for id := range vectors {
+			got, _ := loaded.Vector(id)
+			want, _ := index.Vector(id)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("vector %d loaded as %v, want %v", id, got, want)
+			}
+		}
+	}
+	sameVectors(loaded)
+
+	// Vectors saved without normalization are normalized on load
+	dir = t.TempDir()
+	raw := NewHNSW(Config{Dimension: 8, M: 8, DistanceFunc: func(a, b []float32) float32 { return CosineDistance(a, b) }, Seed: 41})
+	for _, v := range vectors {
+		raw.Add(v)
+	}
+	if err := raw.SaveToLance(dir); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	loaded, err = LoadHNSWFromLance(dir, WithLoadDistance(CosineDistance))
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	sameVectors(loaded)
+}
+
+func TestFloat16SaveLoad(t *testing.T) {
+	const (
+		n   = 10000
+		dim = 32
+		k   = 10
+		ef  = 64
+	)
+	vectors := generateRandomVectors(n, dim, 51)
+	queries := generateRandomVectors(100, dim, 52)
+	index := NewHNSW(Config{Dimension: dim, M: 16, EfConstruction: 100, Seed: 51})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	groundTruth := computeGroundTruthParallel(index, queries, k)
+
+	dir32, dir16 := t.TempDir(), t.TempDir()
+	if err := index.SaveToLance(dir32); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if err := index.SaveToLanceWithOptions(dir16, SaveOptions{VectorPrecision: Float16}); err != nil {
+		t.Fatalf("SaveToLanceWithOptions failed: %v", err)
+	}
+
+	// Vectors take half the space
+	size := func(dir string) int64 {
+		info, err := os.Stat(filepath.Join(dir, "nodes.lance"))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		return info.Size()
+	}
+	if size32, size16 := size(dir32), size(dir16); size16 > size32*6/10 {
+		t.Errorf("nodes.lance is %d bytes at float16, %d at float32", size16, size32)
+	}
+	if _, err := os.Stat(filepath.Join(dir16, vectorsFileName)); err == nil {
+		t.Error("vectors.f32 saved at float16")
+	}
+
+	loaded, err := LoadHNSWFromLance(dir16)
+	if err != nil {
+		t.Fatalf("LoadHNSWFromLance failed: %v", err)
+	}
+	defer loaded.Close()
+	if loaded.GraphHash() != index.GraphHash() {
+		t.Fatal("loaded graph differs from the index in memory")
+	}
+	got, _ := loaded.Vector(3)
+	for i, v := range vectors[3] {
+		if math.Abs(float64(got[i]-v)) > math.Abs(float64(v))/2048 {
+			t.Fatalf("vector 3 loaded as %v, want %v", got, vectors[3])
+		}
+	}
+
+	recall := recallAt(index, queries, groundTruth, k, ef)
+	recall16 := recallAt(loaded, queries, groundTruth, k, ef)
+	t.Logf("recall@%d: %.4f at float32, %.4f at float16", k, recall, recall16)
+	if recall16 < recall-0.01 {
+		t.Errorf("recall %.4f at float16, want within 0.01 of %.4f", recall16, recall)
+	}
+
+	// Segments are saved at the precision of the base; saving at another
+	// rewrites the index
+	loaded.Add(queries[0])
+	if err := loaded.SaveToLanceWithOptions(dir16, SaveOptions{VectorPrecision: Float16}); err != nil {
+		t.Fatalf("SaveToLanceWithOptions failed: %v", err)
+	}
+	if manifest := readTestManifest(t, dir16); len(manifest.Segments) != 1 {
+		t.Errorf("%d segments after an incremental save, want 1", len(manifest.Segments))
+	}
+	// Vectors are rounded, so only the graph is compared
+	sameGraph := func(opts ...LoadOption) *HNSWIndex {
+		t.Helper()
+		reloaded, err := LoadHNSWFromLance(dir16, opts...)
+		if err != nil {
+			t.Fatalf("LoadHNSWFromLance failed: %v", err)
+		}
+		t.Cleanup(func() { reloaded.Close() })
+		if reloaded.GraphHash() != loaded.GraphHash() || reloaded.Len() != loaded.Len() {
+			t.Fatal("reloaded graph differs from the index in memory")
+		}
+		return reloaded
+	}
+	if reloaded := sameGraph(WithGraphStorage(Mapped)); reloaded.GraphStorage() != InMemory {
+		t.Errorf("float16 vectors loaded %v, want InMemory", reloaded.GraphStorage())
+	}
+	if err := loaded.SaveToLance(dir16); err != nil {
+		t.Fatalf("SaveToLance failed: %v", err)
+	}
+	if manifest := readTestManifest(t, dir16); len(manifest.Segments) != 0 {
+		t.Errorf("%d segments after changing precision, want 0", len(manifest.Segments))
+	}
+	sameGraph()
+}
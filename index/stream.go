@@ -0,0 +1,193 @@
+package hnsw
+
+import (
+	"container/heap"
+	"context"
+)
+
+// streamCheckInterval is how many expansions SearchStream runs between
+// checks of its context
+const streamCheckInterval = 64
+
+// StreamParams controls SearchStream. With neither K nor Radius set, the
+// traversal is bounded by EfBase alone and every node it admits is emitted,
+// which suits callers that filter the hits themselves.
+type StreamParams struct {
+	K      int     // Stop after K hits, 0 = no limit
+	Radius float32 // Only emit hits with Distance <= Radius when > 0
+
+	// EfUpperLayers is the beam width of the descent, as in SearchParams
+	EfUpperLayers int
+
+	// EfBase bounds the set of closest nodes that decides when the layer-0
+	// traversal stops, as ef does in Search. Values <= 0 select the default
+	// max(200, 2K), and any value below K is raised to K. Nodes within Radius
+	// are always explored, so a radius search is not capped by EfBase.
+	EfBase int
+
+	// MaxResults is a safety cap for SearchRadius, 0 = none. Unlike K, it
+	// signals the cap: when more hits were found, the first MaxResults are
+	// returned with ErrResultsTruncated. SearchStream ignores it.
+	MaxResults int
+}
+
+// SearchStream calls emit with the hits of a layer-0 traversal in
+// non-decreasing distance order, until emit returns false, K hits were
+// emitted, ctx is done or the traversal ends.
+//
+// Once the traversal has seen EfBase nodes, a hit is emitted as soon as its
+// distance is at most that of every candidate it has yet to expand; before
+// that, and for whatever is left when the traversal ends, hits are held back.
+// The graph can still lead from a candidate to a node closer than hits
+// already emitted; such a node is explored but not emitted, which keeps the
+// order strict at the cost of rarely missing a neighbor that Search with the
+// same ef would return.
+//
+// It returns ctx.Err() if ctx ended the traversal.
+func (h *HNSWIndex) SearchStream(ctx context.Context, query []float32, params StreamParams, emit func(SearchResult) bool) error {
+	if err := h.checkVector(len(query)); err != nil {
+		return err
+	}
+	if params.K < 0 {
+		return ErrInvalidK
+	}
+
+	if params.EfBase <= 0 {
+		params.EfBase = max(200, params.K*2)
+	}
+	if params.EfBase < params.K {
+		params.EfBase = params.K
+	}
+	if params.EfUpperLayers < 1 {
+		params.EfUpperLayers = 1
+	}
+
+	view := h.snapshot()
+	if view.entryPoint == -1 {
+		return ErrEmptyIndex
+	}
+
+	query = h.prepareQuery(query)
+	entries := h.descend(view, query, params.EfUpperLayers)
+	return h.streamLayer0(ctx, view.nodes, query, entries, params, emit)
+}
+
+// streamLayer0 runs the layer-0 traversal of SearchStream
+func (h *HNSWIndex) streamLayer0(ctx context.Context, nodes []*Node, query []float32, entries []SearchResult, params StreamParams, emit func(SearchResult) bool) error {
+	ef := params.EfBase
+	visited := make(map[int]bool, ef*2)
+
+	candidates := &PriorityQueue{} // Unexpanded nodes, closest first
+	closest := &MaxHeap{}          // The ef closest nodes seen, farthest first
+	pending := &PriorityQueue{}    // Hits not emitted yet, closest first
+
+	var last float32 // Distance of the last emitted hit
+	emitted := 0
+
+	within := func(dist float32) bool {
+		return params.Radius <= 0 || dist <= params.Radius
+	}
+	admit := func(id int, dist float32) {
+		heap.Push(candidates, &Item{value: id, priority: dist})
+		if nodes[id].deleted.Load() {
+			return // Traversed but never a hit
+		}
+		heap.Push(closest, &Item{value: id, priority: dist})
+		if closest.Len() > ef {
+			heap.Pop(closest)
+		}
+		if within(dist) && (emitted == 0 || dist >= last) {
+			heap.Push(pending, &Item{value: id, priority: dist})
+		}
+	}
+
+	// flush emits the pending hits up to watermark and reports whether the
+	// traversal should go on
+	flush := func(watermark float32, all bool) bool {
+		for pending.Len() > 0 && (all || (*pending)[0].priority <= watermark) {
+			item := heap.Pop(pending).(*Item)
+			if !emit(SearchResult{ID: item.value, Distance: item.priority, ExternalID: h.externalID(item.value)}) {
+				return false
+			}
+			last = item.priority
+			emitted++
+			if params.K > 0 && emitted >= params.K {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, e := range entries {
+		if !visited[e.ID] {
+			visited[e.ID] = true
+			admit(e.ID, e.Distance)
+		}
+	}
+
+	for steps := 0; candidates.Len() > 0; steps++ {
+		if steps%streamCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		// Nothing left to expand is closer than the pending hits up to here.
+		// Until the closest set is full the traversal is still converging on
+		// the query and the frontier says little, so nothing is emitted yet.
+		if closest.Len() >= ef && !flush((*candidates)[0].priority, false) {
+			return nil
+		}
+
+		current := heap.Pop(candidates).(*Item)
+		if closest.Len() >= ef && current.priority > (*closest)[0].priority && !(params.Radius > 0 && current.priority <= params.Radius) {
+			break
+		}
+		if current.value < 0 || current.value >= len(nodes) {
+			continue
+		}
+
+		for _, neighborID := range nodes[current.value].neighbors(0) {
+			if neighborID < 0 || neighborID >= len(nodes) || visited[neighborID] {
+				continue
+			}
+			visited[neighborID] = true
+
+			dist := h.distTo(query, neighborID)
+			if closest.Len() < ef || dist < (*closest)[0].priority || (params.Radius > 0 && dist <= params.Radius) {
+				admit(neighborID, dist)
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	flush(0, true)
+	return nil
+}
+
+// SearchRadius returns the hits SearchStream would emit, in the same order:
+// with Radius set, every node found within it, closest first. A radius
+// closer than the nearest node gives no results and no error. If more than
+// MaxResults hits were found, the first MaxResults are returned with
+// ErrResultsTruncated.
+func (h *HNSWIndex) SearchRadius(ctx context.Context, query []float32, params StreamParams) ([]SearchResult, error) {
+	var results []SearchResult
+	truncated := false
+	err := h.SearchStream(ctx, query, params, func(r SearchResult) bool {
+		if params.MaxResults > 0 && len(results) == params.MaxResults {
+			truncated = true
+			return false
+		}
+		results = append(results, r)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return results, ErrResultsTruncated
+	}
+	return results, nil
+}
@@ -0,0 +1,146 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+func newStreamTestIndex(t *testing.T) (*HNSWIndex, [][]float32) {
+	t.Helper()
+	vectors := generateRandomVectors(3000, 16, 1)
+	index := NewHNSW(Config{Dimension: 16, M: 16, EfConstruction: 100, Seed: 1})
+	for _, v := range vectors {
+		index.Add(v)
+	}
+	return index, vectors
+}
+
+func TestSearchStreamOrderAndK(t *testing.T) {
+	index, _ := newStreamTestIndex(t)
+	queries := generateRandomVectors(20, 16, 2)
+	groundTruth := computeGroundTruthParallel(index, queries, 500)
+
+	var recall float64
+	for i, q := range queries {
+		var hits []SearchResult
+		err := index.SearchStream(context.Background(), q, StreamParams{K: 500}, func(r SearchResult) bool {
+			hits = append(hits, r)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("SearchStream failed: %v", err)
+		}
+		if len(hits) != 500 {
+			t.Fatalf("got %d hits, want 500", len(hits))
+		}
+		if !sort.SliceIsSorted(hits, func(a, b int) bool { return hits[a].Distance < hits[b].Distance }) {
+			t.Fatalf("hits are not in ascending distance order")
+		}
+		recall += calculateRecall2(groundTruth[i], hits)
+	}
+	recall /= float64(len(queries))
+	t.Logf("Recall@500: %.4f", recall)
+	if recall < 0.95 {
+		t.Errorf("recall %.4f below 0.95", recall)
+	}
+}
+
+func TestSearchStreamRadius(t *testing.T) {
+	index, vectors := newStreamTestIndex(t)
+	query := generateRandomVectors(1, 16, 3)[0]
+
+	// A radius that holds a few hundred vectors
+	dists := make([]float32, len(vectors))
+	for i, v := range vectors {
+		dists[i] = L2Distance(query, v)
+	}
+	sort.Slice(dists, func(a, b int) bool { return dists[a] < dists[b] })
+	radius := dists[300]
+
+	hits, err := index.SearchRadius(context.Background(), query, StreamParams{Radius: radius})
+	if err != nil {
+		t.Fatalf("SearchRadius failed: %v", err)
+	}
+	for _, h := range hits {
+		if h.Distance > radius {
+			t.Fatalf("hit %d at %f is outside radius %f", h.ID, h.Distance, radius)
+		}
+	}
+	if len(hits) < 290 {
+		t.Errorf("got %d hits within radius, want close to 301", len(hits))
+	}
+
+	var streamed []SearchResult
+	index.SearchStream(context.Background(), query, StreamParams{Radius: radius}, func(r SearchResult) bool {
+		streamed = append(streamed, r)
+		return true
+	})
+	if len(streamed) != len(hits) {
+		t.Fatalf("stream emitted %d hits, SearchRadius returned %d", len(streamed), len(hits))
+	}
+	for i := range hits {
+		if streamed[i] != hits[i] {
+			t.Fatalf("hit %d differs: stream %v, SearchRadius %v", i, streamed[i], hits[i])
+		}
+	}
+
+	// K caps a radius search
+	capped, err := index.SearchRadius(context.Background(), query, StreamParams{K: 10, Radius: radius})
+	if err != nil || len(capped) != 10 {
+		t.Fatalf("SearchRadius(K: 10) = %d hits, %v; want 10", len(capped), err)
+	}
+
+	// MaxResults caps it too, but says so
+	truncated, err := index.SearchRadius(context.Background(), query, StreamParams{Radius: radius, MaxResults: 50})
+	if !errors.Is(err, ErrResultsTruncated) || len(truncated) != 50 {
+		t.Fatalf("SearchRadius(MaxResults: 50) = %d hits, %v; want 50, ErrResultsTruncated", len(truncated), err)
+	}
+	for i := range truncated {
+		if truncated[i] != hits[i] {
+			t.Fatalf("truncated hit %d is %v, want %v", i, truncated[i], hits[i])
+		}
+	}
+	if all, err := index.SearchRadius(context.Background(), query, StreamParams{Radius: radius, MaxResults: len(hits)}); err != nil || len(all) != len(hits) {
+		t.Errorf("SearchRadius(MaxResults: %d) = %d hits, %v; want all, nil", len(hits), len(all), err)
+	}
+
+	// A radius closer than the nearest node finds nothing, which is no error
+	if empty, err := index.SearchRadius(context.Background(), query, StreamParams{Radius: dists[0] / 2}); err != nil || len(empty) != 0 {
+		t.Errorf("SearchRadius(tiny radius) = %v, %v; want no hits, nil", empty, err)
+	}
+}
+
+func TestSearchStreamStops(t *testing.T) {
+	index, _ := newStreamTestIndex(t)
+	query := generateRandomVectors(1, 16, 4)[0]
+
+	calls := 0
+	err := index.SearchStream(context.Background(), query, StreamParams{K: 100}, func(SearchResult) bool {
+		calls++
+		return calls < 5
+	})
+	if err != nil || calls != 5 {
+		t.Errorf("emit returning false: %d calls, err %v; want 5 calls", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = index.SearchStream(ctx, query, StreamParams{K: 100}, func(SearchResult) bool { return true })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: err = %v, want context.Canceled", err)
+	}
+
+	// Without K or Radius at least the EfBase closest nodes are emitted
+	all, err := index.SearchRadius(context.Background(), query, StreamParams{EfBase: 50})
+	if err != nil || len(all) < 50 {
+		t.Errorf("EfBase only: %d hits, err %v; want at least 50", len(all), err)
+	}
+	if !sort.SliceIsSorted(all, func(a, b int) bool { return all[a].Distance < all[b].Distance }) {
+		t.Errorf("EfBase only: hits are not in ascending distance order")
+	}
+	if err := index.SearchStream(context.Background(), query, StreamParams{K: -1}, nil); !errors.Is(err, ErrInvalidK) {
+		t.Errorf("negative K: err = %v, want ErrInvalidK", err)
+	}
+}
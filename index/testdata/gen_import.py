@@ -0,0 +1,77 @@
+#!/usr/bin/env python3
+"""Generate the lib_* fixtures read by TestImportLibraryFixtures.
+
+Unlike the import_* fixtures, which the test's own encoders write, these are
+saved by hnswlib and FAISS themselves, and the expected neighbours are the
+ones each library's search returned. Regenerate them with
+
+    python3 gen_import.py
+
+from this directory; hnswlib, faiss-cpu and numpy must be installed. Keep the
+parameters in sync with libraryFixtures in import_test.go.
+"""
+
+import json
+import os
+
+import faiss
+import hnswlib
+import numpy as np
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+
+N, DIM, M, EF_CONSTRUCTION, EF_SEARCH, K = 300, 8, 8, 100, 100, 10
+
+
+def data():
+    rng = np.random.default_rng(11)
+    vectors = rng.standard_normal((N, DIM)).astype(np.float32)
+    queries = rng.standard_normal((5, DIM)).astype(np.float32)
+    labels = 1000 + 7 * np.arange(N, dtype=np.int64)
+    return vectors, queries, labels
+
+
+def write_expected(name, source, queries, labels):
+    expected = {
+        "source": source,
+        "queries": queries.tolist(),
+        "labels": [[int(l) for l in row] for row in labels],
+    }
+    with open(os.path.join(HERE, name + ".json"), "w") as f:
+        json.dump(expected, f, indent=2)
+
+
+def gen_hnswlib(vectors, queries, labels):
+    index = hnswlib.Index(space="l2", dim=DIM)
+    index.init_index(max_elements=N, M=M, ef_construction=EF_CONSTRUCTION, random_seed=5)
+    index.add_items(vectors, labels, num_threads=1)
+    index.set_ef(EF_SEARCH)
+    found, _ = index.knn_query(queries, k=K)
+
+    name = "lib_hnswlib_l2.bin"
+    index.save_index(os.path.join(HERE, name))
+    write_expected(name, "hnswlib " + hnswlib.__version__, queries, found)
+
+
+def gen_faiss(vectors, queries, labels):
+    hnsw = faiss.IndexHNSWFlat(DIM, M, faiss.METRIC_INNER_PRODUCT)
+    hnsw.hnsw.efConstruction = EF_CONSTRUCTION
+    index = faiss.IndexIDMap(hnsw)
+    faiss.omp_set_num_threads(1)
+    index.add_with_ids(vectors, labels)
+    hnsw.hnsw.efSearch = EF_SEARCH
+    _, found = index.search(queries, K)
+
+    name = "lib_faiss_ip.index"
+    faiss.write_index(index, os.path.join(HERE, name))
+    write_expected(name, "faiss " + faiss.__version__, queries, found)
+
+
+def main():
+    vectors, queries, labels = data()
+    gen_hnswlib(vectors, queries, labels)
+    gen_faiss(vectors, queries, labels)
+
+
+if __name__ == "__main__":
+    main()
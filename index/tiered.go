@@ -0,0 +1,264 @@
+package hnsw
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+// GraphStorage selects where the graph's adjacency lists live.
+type GraphStorage int
+
+const (
+	// InMemory keeps every layer's neighbor lists on the heap (default).
+	InMemory GraphStorage = iota
+
+	// TieredL0 keeps layers >= 1 and all vectors in memory, while layer-0
+	// neighbor lists are read on demand from the memory-mapped layer0.adj
+	// file written by SaveToLance. Lists modified after loading (e.g. by new
+	// inserts) move back to memory.
+	TieredL0
+
+	// Mapped serves vectors as well from memory-mapped files written by
+	// SaveToLance, so loading reads no vector and no list: layer 0 as in
+	// TieredL0, and each upper layer from upper.adj when a search first
+	// reaches it. The OS page cache decides what stays resident. Added nodes
+	// and modified lists live in memory. Quantized indexes load InMemory.
+	Mapped
+)
+
+// String returns the name of the storage mode.
+func (g GraphStorage) String() string {
+	switch g {
+	case InMemory:
+		return "InMemory"
+	case TieredL0:
+		return "TieredL0"
+	case Mapped:
+		return "Mapped"
+	default:
+		return fmt.Sprintf("GraphStorage(%d)", int(g))
+	}
+}
+
+const (
+	// l0FileName stores layer-0 adjacency in a fixed-stride layout
+	l0FileName = "layer0.adj"
+
+	l0Magic      = 0x41304C56 // "VL0A"
+	l0Version    = 1
+	l0HeaderSize = 16 // magic, version, numNodes, stride (uint32 each)
+
+	// defaultL0CacheSize is the number of decoded layer-0 lists kept hot
+	defaultL0CacheSize = 4096
+)
+
+// writeL0File writes layer-0 neighbor lists so that node i's list starts at
+// l0HeaderSize + i*recordSize, where each record is a uint32 count followed
+// by stride int32 slots. The file is written to a temporary name and renamed
+// into place so an existing mapping of the old file stays valid.
+func writeL0File(path string, nodes []*Node) error {
+	stride := 0
+	lists := make([][]int, len(nodes))
+	for i, node := range nodes {
+		// Deleted nodes are not saved, and neither are links to them
+		if !node.deleted.Load() {
+			lists[i] = live(nodes, node.neighbors(0))
+		}
+		if len(lists[i]) > stride {
+			stride = len(lists[i])
+		}
+	}
+	recordSize := 4 + 4*stride
+
+	buf := make([]byte, l0HeaderSize+recordSize*len(nodes))
+	binary.LittleEndian.PutUint32(buf[0:], l0Magic)
+	binary.LittleEndian.PutUint32(buf[4:], l0Version)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(nodes)))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(stride))
+	for i, ids := range lists {
+		rec := buf[l0HeaderSize+i*recordSize:]
+		binary.LittleEndian.PutUint32(rec, uint32(len(ids)))
+		for j, id := range ids {
+			binary.LittleEndian.PutUint32(rec[4+4*j:], uint32(int32(id)))
+		}
+	}
+
+	return lanceio.WriteFileAtomic(path, buf, 0644)
+}
+
+// l0Store serves layer-0 neighbor lists from layer0.adj.
+type l0Store struct {
+	file       *os.File
+	data       []byte // mapped file contents; nil when using positional reads
+	numNodes   int
+	stride     int
+	recordSize int
+
+	cache *l0Cache
+}
+
+// openL0Store opens path and validates it against the expected node count.
+func openL0Store(path string, numNodes, cacheSize int) (*l0Store, error) {
+	// Opened shareable so a later SaveToLance can replace the file while it
+	// is still mapped, including on Windows
+	f, err := lanceio.OpenShared(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	var header [l0HeaderSize]byte
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read layer0 header: %w", err)
+	}
+	magic := binary.LittleEndian.Uint32(header[0:])
+	version := binary.LittleEndian.Uint32(header[4:])
+	n := int(binary.LittleEndian.Uint32(header[8:]))
+	stride := int(binary.LittleEndian.Uint32(header[12:]))
+	recordSize := 4 + 4*stride
+
+	switch {
+	case magic != l0Magic || version != l0Version:
+		err = fmt.Errorf("invalid layer0 header (magic 0x%08X, version %d)", magic, version)
+	case n != numNodes:
+		err = fmt.Errorf("layer0 file has %d nodes, index has %d", n, numNodes)
+	case info.Size() != int64(l0HeaderSize+recordSize*n):
+		err = fmt.Errorf("layer0 file size %d does not match %d records of %d bytes", info.Size(), n, recordSize)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s := &l0Store{
+		file:       f,
+		numNodes:   n,
+		stride:     stride,
+		recordSize: recordSize,
+		cache:      newL0Cache(cacheSize),
+	}
+	if data, err := mmapFile(f, int(info.Size())); err == nil {
+		s.data = data
+	}
+	return s, nil
+}
+
+// neighbors returns node id's layer-0 list. The result is shared through the
+// cache and must not be modified.
+func (s *l0Store) neighbors(id int) []int {
+	if ids, ok := s.cache.get(id); ok {
+		return ids
+	}
+
+	ids, err := s.read(id)
+	if err != nil {
+		// Searches cannot surface I/O errors per neighbor list; treat the
+		// node as a dead end rather than failing the whole query
+		log.Printf("Warning: failed to read layer0 neighbors of node %d: %v", id, err)
+		return nil
+	}
+	s.cache.put(id, ids)
+	return ids
+}
+
+func (s *l0Store) read(id int) ([]int, error) {
+	if id < 0 || id >= s.numNodes {
+		return nil, fmt.Errorf("node %d out of range [0, %d)", id, s.numNodes)
+	}
+
+	offset := l0HeaderSize + id*s.recordSize
+	var rec []byte
+	if s.data != nil {
+		rec = s.data[offset : offset+s.recordSize]
+	} else {
+		rec = make([]byte, s.recordSize)
+		if _, err := s.file.ReadAt(rec, int64(offset)); err != nil {
+			return nil, err
+		}
+	}
+
+	count := int(binary.LittleEndian.Uint32(rec))
+	if count > s.stride {
+		return nil, fmt.Errorf("neighbor count %d exceeds stride %d", count, s.stride)
+	}
+	ids := make([]int, count)
+	for i := range ids {
+		ids[i] = int(int32(binary.LittleEndian.Uint32(rec[4+4*i:])))
+	}
+	return ids, nil
+}
+
+// Close unmaps and closes the file.
+func (s *l0Store) Close() error {
+	err := munmapFile(s.data)
+	s.data = nil
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// l0Cache is a small LRU of decoded layer-0 neighbor lists.
+type l0Cache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[int]*list.Element
+	lru      *list.List
+}
+
+type l0CacheEntry struct {
+	id  int
+	ids []int
+}
+
+func newL0Cache(capacity int) *l0Cache {
+	if capacity <= 0 {
+		capacity = defaultL0CacheSize
+	}
+	return &l0Cache{
+		capacity: capacity,
+		items:    make(map[int]*list.Element, capacity),
+		lru:      list.New(),
+	}
+}
+
+func (c *l0Cache) get(id int) ([]int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[id]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*l0CacheEntry).ids, true
+	}
+	return nil, false
+}
+
+func (c *l0Cache) put(id int, ids []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[id]; ok {
+		// Another search decoded the same cold node concurrently
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	for c.lru.Len() >= c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*l0CacheEntry).id)
+	}
+	c.items[id] = c.lru.PushFront(&l0CacheEntry{id: id, ids: ids})
+}
@@ -0,0 +1,275 @@
+package hnsw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildSavedIndex builds an index of n random vectors and saves it to a
+// temporary directory.
+func buildSavedIndex(t testing.TB, n, dim int) (*HNSWIndex, string, [][]float32) {
+	t.Helper()
+
+	index := NewHNSW(Config{M: 16, EfConstruction: 100, Dimension: dim, Seed: 42})
+	vectors := generateRandomVectors(n, dim, 7)
+	for i, vec := range vectors {
+		if _, err := index.Add(vec); err != nil {
+			t.Fatalf("Failed to add vector %d: %v", i, err)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("Failed to save HNSW: %v", err)
+	}
+	return index, dir, vectors
+}
+
+func TestTieredL0SearchMatchesInMemory(t *testing.T) {
+	_, dir, _ := buildSavedIndex(t, 500, 16)
+
+	inMemory, err := LoadHNSWFromLance(dir)
+	if err != nil {
+		t.Fatalf("Failed to load in-memory index: %v", err)
+	}
+	tiered, err := LoadHNSWFromLance(dir, WithGraphStorage(TieredL0), WithL0CacheSize(32))
+	if err != nil {
+		t.Fatalf("Failed to load tiered index: %v", err)
+	}
+	defer tiered.Close()
+
+	if tiered.GraphStorage() != TieredL0 {
+		t.Fatalf("Expected TieredL0 storage, got %v", tiered.GraphStorage())
+	}
+
+	for i, node := range inMemory.nodes {
+		want := node.GetConnections(0)
+		got := tiered.nodes[i].GetConnections(0)
+		if fmt.Sprint(want) != fmt.Sprint(got) {
+			t.Fatalf("Node %d layer 0: expected %v, got %v", i, want, got)
+		}
+	}
+
+	for q, query := range generateRandomVectors(50, 16, 99) {
+		want, err := inMemory.Search(query, 10, 50)
+		if err != nil {
+			t.Fatalf("In-memory search failed: %v", err)
+		}
+		got, err := tiered.Search(query, 10, 50)
+		if err != nil {
+			t.Fatalf("Tiered search failed: %v", err)
+		}
+		if len(want) != len(got) {
+			t.Fatalf("Query %d: expected %d results, got %d", q, len(want), len(got))
+		}
+		for i := range want {
+			if want[i] != got[i] {
+				t.Errorf("Query %d result %d: expected %+v, got %+v", q, i, want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestTieredL0MissingFileFallsBack(t *testing.T) {
+	_, dir, vectors := buildSavedIndex(t, 200, 8)
+
+	if err := os.Remove(filepath.Join(dir, l0FileName)); err != nil {
+		t.Fatalf("Failed to remove layer0 file: %v", err)
+	}
+
+	index, err := LoadHNSWFromLance(dir, WithGraphStorage(TieredL0))
+	if err != nil {
+		t.Fatalf("Expected fallback load to succeed, got: %v", err)
+	}
+	defer index.Close()
+
+	if index.GraphStorage() != InMemory {
+		t.Errorf("Expected fallback to InMemory, got %v", index.GraphStorage())
+	}
+	for i, node := range index.nodes {
+		if node.ConnectionCount(0) == 0 {
+			t.Fatalf("Node %d has no layer 0 connections after fallback", i)
+		}
+	}
+
+	results, err := index.Search(vectors[5], 1, 50)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if results[0].ID != 5 {
+		t.Errorf("Expected node 5 as nearest neighbor, got %d", results[0].ID)
+	}
+}
+
+func TestTieredL0InvalidFileFallsBack(t *testing.T) {
+	_, dir, _ := buildSavedIndex(t, 100, 8)
+
+	path := filepath.Join(dir, l0FileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read layer0 file: %v", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)-3], 0644); err != nil {
+		t.Fatalf("Failed to truncate layer0 file: %v", err)
+	}
+
+	index, err := LoadHNSWFromLance(dir, WithGraphStorage(TieredL0))
+	if err != nil {
+		t.Fatalf("Expected fallback load to succeed, got: %v", err)
+	}
+	if index.GraphStorage() != InMemory {
+		t.Errorf("Expected fallback to InMemory, got %v", index.GraphStorage())
+	}
+}
+
+func TestTieredL0ConcurrentColdNode(t *testing.T) {
+	_, dir, vectors := buildSavedIndex(t, 300, 8)
+
+	// A single-entry cache forces constant eviction, so concurrent searches
+	// keep decoding the same cold lists from the mapped file
+	index, err := LoadHNSWFromLance(dir, WithGraphStorage(TieredL0), WithL0CacheSize(1))
+	if err != nil {
+		t.Fatalf("Failed to load tiered index: %v", err)
+	}
+	defer index.Close()
+
+	want, err := index.Search(vectors[0], 5, 50)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				got, err := index.Search(vectors[0], 5, 50)
+				if err != nil {
+					errs <- err
+					return
+				}
+				for j := range want {
+					if got[j] != want[j] {
+						errs <- fmt.Errorf("result %d: expected %+v, got %+v", j, want[j], got[j])
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestTieredL0AddAndResave(t *testing.T) {
+	_, dir, _ := buildSavedIndex(t, 200, 8)
+
+	index, err := LoadHNSWFromLance(dir, WithGraphStorage(TieredL0))
+	if err != nil {
+		t.Fatalf("Failed to load tiered index: %v", err)
+	}
+
+	extra := generateRandomVectors(50, 8, 123)
+	for i, vec := range extra {
+		if _, err := index.Add(vec); err != nil {
+			t.Fatalf("Failed to add vector %d: %v", i, err)
+		}
+	}
+
+	// Overwrite the file that is currently mapped
+	if err := index.SaveToLance(dir); err != nil {
+		t.Fatalf("Failed to re-save tiered index: %v", err)
+	}
+	if err := index.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reloaded, err := LoadHNSWFromLance(dir, WithGraphStorage(TieredL0))
+	if err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	defer reloaded.Close()
+
+	if reloaded.Len() != 250 {
+		t.Fatalf("Expected 250 nodes, got %d", reloaded.Len())
+	}
+	for i, vec := range extra {
+		results, err := reloaded.Search(vec, 1, 50)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if results[0].ID != 200+i {
+			t.Errorf("Expected node %d for added vector, got %d", 200+i, results[0].ID)
+		}
+	}
+}
+
+// BenchmarkGraphStorage compares heap usage and search latency of a loaded
+// index in InMemory and TieredL0 mode. The default size keeps the run short;
+// set VEGO_TIERED_BENCH_N=1000000 and VEGO_TIERED_BENCH_DIM=768 for the
+// large-scale numbers.
+func BenchmarkGraphStorage(b *testing.B) {
+	n := envInt("VEGO_TIERED_BENCH_N", 20000)
+	dim := envInt("VEGO_TIERED_BENCH_DIM", 128)
+
+	_, dir, _ := buildSavedIndex(b, n, dim)
+	queries := generateRandomVectors(200, dim, 11)
+
+	for _, mode := range []GraphStorage{InMemory, TieredL0} {
+		b.Run(mode.String(), func(b *testing.B) {
+			index, err := LoadHNSWFromLance(dir, WithGraphStorage(mode))
+			if err != nil {
+				b.Fatalf("Failed to load: %v", err)
+			}
+
+			latencies := make([]time.Duration, 0, b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				start := time.Now()
+				if _, err := index.Search(queries[i%len(queries)], 10, 100); err != nil {
+					b.Fatalf("Search failed: %v", err)
+				}
+				latencies = append(latencies, time.Since(start))
+			}
+			b.StopTimer()
+
+			// Heap retained by the index: live heap with it minus live heap
+			// once it is released
+			withIndex := liveHeap()
+			index.Close()
+			runtime.KeepAlive(index)
+			retained := withIndex - liveHeap()
+
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+			b.ReportMetric(float64(retained)/(1024*1024), "heap-MB")
+		})
+	}
+}
+
+func liveHeap() int64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.HeapAlloc)
+}
+
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,145 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+const (
+	defaultM              = 16
+	defaultEfConstruction = 200
+	minM                  = 2 // ml = 1/ln(M) is undefined for M = 1
+
+	// Soft limits: configurations past these are accepted but logged
+	adviseMaxM                 = 100  // More connections rarely improve recall
+	adviseEfConstructionFactor = 2    // EfConstruction below this multiple of M starves pruning
+	adviseMaxManualDimension   = 4096 // Very high dimensions usually want Adaptive
+)
+
+// ConfigError reports a Config field outside its allowed range. It wraps
+// ErrInvalidParameter.
+type ConfigError struct {
+	Field   string // Config field name
+	Value   any    // Rejected value
+	Allowed string // Allowed range
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("hnsw: invalid Config.%s = %v: %s", e.Field, e.Value, e.Allowed)
+}
+
+// Unwrap returns ErrInvalidParameter so errors.Is works for every field
+func (e *ConfigError) Unwrap() error { return ErrInvalidParameter }
+
+// Validate reports every field of c outside its allowed range as a
+// *ConfigError, joined into one error. Zero values stand for defaults and
+// are valid; Adaptive choices and defaults are filled in before the checks
+// that relate fields to each other, such as EfConstruction >= M.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Dimension <= 0 {
+		errs = append(errs, &ConfigError{"Dimension", c.Dimension, "must be > 0"})
+	}
+	if c.M < 0 || c.M == 1 {
+		errs = append(errs, &ConfigError{"M", c.M, fmt.Sprintf("must be >= %d, or 0 for the default", minM)})
+	}
+	if c.EfConstruction < 0 {
+		errs = append(errs, &ConfigError{"EfConstruction", c.EfConstruction, "must be >= M, or 0 for the default"})
+	}
+	if c.ExpectedSize < 0 {
+		errs = append(errs, &ConfigError{"ExpectedSize", c.ExpectedSize, "must be >= 0"})
+	}
+	if c.GraphStorage < InMemory || c.GraphStorage > Mapped {
+		errs = append(errs, &ConfigError{"GraphStorage", c.GraphStorage, "must be InMemory, TieredL0 or Mapped"})
+	}
+	if c.L0CacheSize < 0 {
+		errs = append(errs, &ConfigError{"L0CacheSize", c.L0CacheSize, "must be >= 0"})
+	}
+	if c.LoadWorkers < 0 {
+		errs = append(errs, &ConfigError{"LoadWorkers", c.LoadWorkers, "must be >= 0"})
+	}
+	if c.Quantization < NoQuantization || c.Quantization > ScalarQuant8 {
+		errs = append(errs, &ConfigError{"Quantization", c.Quantization, "must be NoQuantization or ScalarQuant8"})
+	}
+	if c.BinaryVectors {
+		if c.Dimension%64 != 0 {
+			errs = append(errs, &ConfigError{"Dimension", c.Dimension, "must be a multiple of 64 with BinaryVectors"})
+		}
+		if c.Quantization != NoQuantization {
+			errs = append(errs, &ConfigError{"Quantization", c.Quantization, "must be NoQuantization with BinaryVectors"})
+		}
+		if c.DistanceFunc != nil {
+			errs = append(errs, &ConfigError{"DistanceFunc", "custom", "must be unset with BinaryVectors, which use Hamming distance"})
+		}
+	}
+	if c.FullVectors < DropFullVectors || c.FullVectors > FullVectorsOnDisk {
+		errs = append(errs, &ConfigError{"FullVectors", c.FullVectors, "must be DropFullVectors or FullVectorsOnDisk"})
+	} else if c.FullVectors != DropFullVectors && c.Quantization == NoQuantization {
+		errs = append(errs, &ConfigError{"FullVectors", c.FullVectors, "requires Quantization"})
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	r := c.withDefaults()
+	if r.EfConstruction < r.M {
+		return &ConfigError{"EfConstruction", r.EfConstruction, fmt.Sprintf("must be >= M (%d)", r.M)}
+	}
+	return nil
+}
+
+// Warnings describes valid but inadvisable choices in c, after defaults and
+// Adaptive choices are filled in. NewHNSW logs them.
+func (c Config) Warnings() []string {
+	r := c.withDefaults()
+
+	var warnings []string
+	if r.M > adviseMaxM {
+		warnings = append(warnings, fmt.Sprintf("M = %d is above %d; memory grows with M while recall rarely improves", r.M, adviseMaxM))
+	}
+	if r.EfConstruction < adviseEfConstructionFactor*r.M {
+		warnings = append(warnings, fmt.Sprintf("EfConstruction = %d is below 2*M (%d); neighbor selection has too few candidates", r.EfConstruction, adviseEfConstructionFactor*r.M))
+	}
+	if r.Dimension > adviseMaxManualDimension && !r.Adaptive {
+		warnings = append(warnings, fmt.Sprintf("Dimension = %d with Adaptive off; consider Adaptive to size M and EfConstruction", r.Dimension))
+	}
+	return warnings
+}
+
+// withDefaults returns c with Adaptive choices and defaults filled in for
+// zero values. Seed is left alone.
+func (c Config) withDefaults() Config {
+	if c.Adaptive && c.Dimension > 0 {
+		adaptive := calculateAdaptiveParams(c.Dimension, c.ExpectedSize)
+
+		// Only override values not explicitly set by user
+		if c.M == 0 {
+			c.M = adaptive.M
+		}
+		if c.EfConstruction == 0 {
+			c.EfConstruction = adaptive.EfConstruction
+		}
+		if c.DistanceFunc == nil {
+			c.DistanceFunc = adaptive.DistanceFunc
+		}
+	}
+
+	if c.M == 0 {
+		c.M = defaultM
+	}
+	if c.EfConstruction == 0 {
+		c.EfConstruction = defaultEfConstruction
+	}
+	if c.DistanceFunc == nil {
+		c.DistanceFunc = L2Distance
+	}
+	return c
+}
+
+// logWarnings logs c's Warnings
+func (c Config) logWarnings() {
+	for _, w := range c.Warnings() {
+		log.Printf("Warning: hnsw: %s", w)
+	}
+}
@@ -0,0 +1,111 @@
+package hnsw
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidateRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		field  string
+	}{
+		{"zero dimension", Config{Dimension: 0}, "Dimension"},
+		{"negative dimension", Config{Dimension: -8}, "Dimension"},
+		{"M of one", Config{Dimension: 8, M: 1}, "M"},
+		{"negative M", Config{Dimension: 8, M: -1}, "M"},
+		{"negative EfConstruction", Config{Dimension: 8, EfConstruction: -1}, "EfConstruction"},
+		{"EfConstruction below M", Config{Dimension: 8, M: 32, EfConstruction: 16}, "EfConstruction"},
+		{"EfConstruction below default M", Config{Dimension: 8, EfConstruction: 10}, "EfConstruction"},
+		{"EfConstruction below adaptive M", Config{Dimension: 2048, Adaptive: true, EfConstruction: 40}, "EfConstruction"},
+		{"negative ExpectedSize", Config{Dimension: 8, ExpectedSize: -1}, "ExpectedSize"},
+		{"unknown GraphStorage", Config{Dimension: 8, GraphStorage: GraphStorage(7)}, "GraphStorage"},
+		{"negative L0CacheSize", Config{Dimension: 8, L0CacheSize: -1}, "L0CacheSize"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if !errors.Is(err, ErrInvalidParameter) {
+				t.Fatalf("Validate() = %v, want ErrInvalidParameter", err)
+			}
+			var cfgErr *ConfigError
+			if !errors.As(err, &cfgErr) || cfgErr.Field != tt.field {
+				t.Errorf("Validate() = %v, want a ConfigError for %s", err, tt.field)
+			}
+
+			h, err := NewHNSWChecked(tt.config)
+			if h != nil || !errors.Is(err, ErrInvalidParameter) {
+				t.Errorf("NewHNSWChecked() = %v, %v, want nil, ErrInvalidParameter", h, err)
+			}
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewHNSW did not panic")
+				}
+			}()
+			NewHNSW(tt.config)
+		})
+	}
+}
+
+func TestConfigValidateAccepts(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"defaults", Config{Dimension: 8}},
+		{"explicit", Config{Dimension: 128, M: 16, EfConstruction: 200}},
+		{"EfConstruction equal to M", Config{Dimension: 8, M: 8, EfConstruction: 8}},
+		{"adaptive", Config{Dimension: 1536, Adaptive: true, ExpectedSize: 1000000}},
+		{"tiered", Config{Dimension: 8, GraphStorage: TieredL0, L0CacheSize: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if h, err := NewHNSWChecked(tt.config); h == nil || err != nil {
+				t.Errorf("NewHNSWChecked() = %v, %v, want an index", h, err)
+			}
+		})
+	}
+}
+
+func TestConfigValidateReportsEveryField(t *testing.T) {
+	err := Config{Dimension: -1, M: -1, ExpectedSize: -1}.Validate()
+	for _, field := range []string{"Dimension", "M", "ExpectedSize"} {
+		if err == nil || !strings.Contains(err.Error(), "Config."+field) {
+			t.Errorf("Validate() = %v, want it to name %s", err, field)
+		}
+	}
+}
+
+func TestConfigWarnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string // Substring of the only warning, "" for none
+	}{
+		{"defaults", Config{Dimension: 128}, ""},
+		{"adaptive high dimension", Config{Dimension: 8192, Adaptive: true}, ""},
+		{"large M", Config{Dimension: 128, M: 128, EfConstruction: 400}, "M = 128"},
+		{"EfConstruction below 2*M", Config{Dimension: 128, M: 32, EfConstruction: 40}, "EfConstruction = 40"},
+		{"high dimension without adaptive", Config{Dimension: 8192}, "Dimension = 8192"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := tt.config.Warnings()
+			if tt.want == "" {
+				if len(warnings) != 0 {
+					t.Errorf("Warnings() = %q, want none", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0], tt.want) {
+				t.Errorf("Warnings() = %q, want one mentioning %q", warnings, tt.want)
+			}
+		})
+	}
+}
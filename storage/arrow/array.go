@@ -1,5 +1,7 @@
 package arrow
 
+import "fmt"
+
 // Array is the interface for all Arrow arrays
 type Array interface {
 	// DataType returns the data type of this array
@@ -143,6 +145,58 @@ func (a *Int64Array) Values() []int64 {
 	return a.data.buffers[0].Int64()
 }
 
+// --- Float16Array ---
+
+// Float16Array holds half-precision floats as their uint16 bits; use
+// Float32 or Float32Values to get them as float32
+type Float16Array struct {
+	data *ArrayData
+}
+
+func NewFloat16Array(data []uint16, nullBitmap *Bitmap) *Float16Array {
+	buf := NewUint16Buffer(data)
+	arrayData := NewArrayData(PrimFloat16(), len(data), []*Buffer{buf}, nullBitmap, nil)
+	return &Float16Array{data: arrayData}
+}
+
+// NewFloat16ArrayFromFloat32 rounds data to half precision
+func NewFloat16ArrayFromFloat32(data []float32, nullBitmap *Bitmap) *Float16Array {
+	return NewFloat16Array(Float32sToFloat16(data), nullBitmap)
+}
+
+func (a *Float16Array) DataType() DataType { return a.data.dtype }
+func (a *Float16Array) Len() int           { return a.data.length }
+func (a *Float16Array) NullN() int         { return a.data.nulls }
+func (a *Float16Array) Data() *ArrayData   { return a.data }
+func (a *Float16Array) Release()           {}
+func (a *Float16Array) IsNull(i int) bool {
+	if a.data.nullBitmap == nil {
+		return false
+	}
+	return !a.data.nullBitmap.IsSet(i)
+}
+func (a *Float16Array) IsValid(i int) bool { return !a.IsNull(i) }
+
+// Value returns the bits of value i
+func (a *Float16Array) Value(i int) uint16 {
+	return a.data.buffers[0].Uint16()[i]
+}
+
+// Values returns the bits of all values (zero-copy)
+func (a *Float16Array) Values() []uint16 {
+	return a.data.buffers[0].Uint16()
+}
+
+// Float32 returns value i as a float32, which is exact
+func (a *Float16Array) Float32(i int) float32 {
+	return Float16ToFloat32(a.Value(i))
+}
+
+// Float32Values returns a float32 copy of all values
+func (a *Float16Array) Float32Values() []float32 {
+	return Float16sToFloat32(a.Values())
+}
+
 // --- Float32Array ---
 type Float32Array struct {
 	data *ArrayData
@@ -154,6 +208,17 @@ func NewFloat32Array(data []float32, nullBitmap *Bitmap) *Float32Array {
 	return &Float32Array{data: arrayData}
 }
 
+// NewFloat32ArrayFromBuffer wraps buf, little-endian float32 values, without
+// copying it; buf must not be modified afterwards. Its length must be a
+// multiple of 4.
+func NewFloat32ArrayFromBuffer(buf *Buffer, nullBitmap *Bitmap) *Float32Array {
+	if buf.Len()%4 != 0 {
+		panic(fmt.Sprintf("buffer size %d not aligned to float32", buf.Len()))
+	}
+	arrayData := NewArrayData(PrimFloat32(), buf.Len()/4, []*Buffer{buf}, nullBitmap, nil)
+	return &Float32Array{data: arrayData}
+}
+
 func (a *Float32Array) DataType() DataType { return a.data.dtype }
 func (a *Float32Array) Len() int           { return a.data.length }
 func (a *Float32Array) NullN() int         { return a.data.nulls }
@@ -256,6 +321,8 @@ func (a *FixedSizeListArray) ValueSlice(i int) interface{} {
 	switch arr := a.values.(type) {
 	case *Float32Array:
 		return arr.Values()[start:end]
+	case *Float16Array:
+		return arr.Values()[start:end]
 	case *Int32Array:
 		return arr.Values()[start:end]
 	default:
@@ -312,3 +379,125 @@ func (a *ListArray) ValueOffsets(i int) (start, end int32) {
 	offsets := a.Offsets()
 	return offsets[i], offsets[i+1]
 }
+
+// --- BinaryArray / StringArray (variable-length) ---
+
+// BinaryArray holds variable-length byte strings as Arrow does: value i is
+// data[offsets[i]:offsets[i+1]], with one more offset than values.
+type BinaryArray struct {
+	data    *ArrayData
+	offsets *Buffer // int32 offsets
+	values  *Buffer // concatenated value bytes
+}
+
+// NewBinaryArray creates a binary array from its offsets and value bytes
+func NewBinaryArray(offsets []int32, data []byte, nullBitmap *Bitmap) *BinaryArray {
+	offsetBuf := NewInt32Buffer(offsets)
+	valueBuf := NewBufferBytes(data)
+	arrayData := NewArrayData(PrimBinary(), len(offsets)-1, []*Buffer{offsetBuf, valueBuf}, nullBitmap, nil)
+	return &BinaryArray{data: arrayData, offsets: offsetBuf, values: valueBuf}
+}
+
+func (a *BinaryArray) DataType() DataType { return a.data.dtype }
+func (a *BinaryArray) Len() int           { return a.data.length }
+func (a *BinaryArray) NullN() int         { return a.data.nulls }
+func (a *BinaryArray) Data() *ArrayData   { return a.data }
+func (a *BinaryArray) Release()           {}
+func (a *BinaryArray) IsNull(i int) bool {
+	if a.data.nullBitmap == nil {
+		return false
+	}
+	return !a.data.nullBitmap.IsSet(i)
+}
+func (a *BinaryArray) IsValid(i int) bool { return !a.IsNull(i) }
+
+// Value returns the bytes of value i, sharing the array's memory
+func (a *BinaryArray) Value(i int) []byte {
+	start, end := a.ValueOffsets(i)
+	return a.values.Bytes()[start:end]
+}
+
+// Offsets returns the offset buffer
+func (a *BinaryArray) Offsets() []int32 {
+	return a.offsets.Int32()
+}
+
+// ValueBytes returns the concatenated value bytes
+func (a *BinaryArray) ValueBytes() []byte {
+	return a.values.Bytes()
+}
+
+// ValueOffsets returns the start and end offset of value i
+func (a *BinaryArray) ValueOffsets(i int) (start, end int32) {
+	offsets := a.Offsets()
+	return offsets[i], offsets[i+1]
+}
+
+// StringArray is a BinaryArray of UTF-8 strings
+type StringArray struct {
+	BinaryArray
+}
+
+// NewStringArray creates a string array from its offsets and UTF-8 bytes
+func NewStringArray(offsets []int32, data []byte, nullBitmap *Bitmap) *StringArray {
+	arr := NewBinaryArray(offsets, data, nullBitmap)
+	arr.data.dtype = PrimString()
+	return &StringArray{BinaryArray: *arr}
+}
+
+// Value returns value i as a string
+func (a *StringArray) Value(i int) string {
+	return string(a.BinaryArray.Value(i))
+}
+
+// --- BooleanArray ---
+
+// BooleanArray holds bit-packed booleans, value i being bit i of the values
+// bitmap
+type BooleanArray struct {
+	data   *ArrayData
+	values *Bitmap
+}
+
+// NewBooleanArray creates a boolean array, packing data into a bitmap
+func NewBooleanArray(data []bool, nullBitmap *Bitmap) *BooleanArray {
+	values := NewBitmap(len(data))
+	for i, v := range data {
+		if v {
+			values.Set(i)
+		}
+	}
+	return newBooleanArray(values, nullBitmap)
+}
+
+// newBooleanArray creates a boolean array over the bitmap of its values
+func newBooleanArray(values, nullBitmap *Bitmap) *BooleanArray {
+	arrayData := NewArrayData(PrimBool(), values.Len(), []*Buffer{NewBufferBytes(values.Bytes())}, nullBitmap, nil)
+	return &BooleanArray{data: arrayData, values: values}
+}
+
+func (a *BooleanArray) DataType() DataType { return a.data.dtype }
+func (a *BooleanArray) Len() int           { return a.data.length }
+func (a *BooleanArray) NullN() int         { return a.data.nulls }
+func (a *BooleanArray) Data() *ArrayData   { return a.data }
+func (a *BooleanArray) Release()           {}
+func (a *BooleanArray) IsNull(i int) bool {
+	if a.data.nullBitmap == nil {
+		return false
+	}
+	return !a.data.nullBitmap.IsSet(i)
+}
+func (a *BooleanArray) IsValid(i int) bool { return !a.IsNull(i) }
+
+// Value returns value i
+func (a *BooleanArray) Value(i int) bool {
+	if i < 0 || i >= a.Len() {
+		panic("index out of range")
+	}
+	return a.values.IsSet(i)
+}
+
+// ValueBitmap returns the bitmap of the values
+func (a *BooleanArray) ValueBitmap() *Bitmap {
+	return a.values
+}
@@ -75,6 +75,31 @@ func TestFloat32Array(t *testing.T) {
 	}
 }
 
+func TestFloat32ArrayFromBuffer(t *testing.T) {
+	data := []float32{1.5, -2, 3.25}
+	buf := NewFloat32Buffer(data)
+	arr := NewFloat32ArrayFromBuffer(buf, nil)
+
+	if arr.Len() != 3 {
+		t.Errorf("expected length 3, got %d", arr.Len())
+	}
+	for i, expected := range data {
+		if arr.Value(i) != expected {
+			t.Errorf("element %d: expected %f, got %f", i, expected, arr.Value(i))
+		}
+	}
+	if &arr.Data().Buffers()[0].Bytes()[0] != &buf.Bytes()[0] {
+		t.Error("buffer was copied")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for a buffer of 5 bytes")
+		}
+	}()
+	NewFloat32ArrayFromBuffer(NewBuffer(5), nil)
+}
+
 func TestInt64Array(t *testing.T) {
 	data := []int64{100, 200, 300, 400}
 	arr := NewInt64Array(data, nil)
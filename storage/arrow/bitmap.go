@@ -6,7 +6,9 @@ import "math/bits"
 // Used primarily for null masks in Arrow arrays
 type Bitmap struct {
 	buf    []byte
-	length int // number of bits
+	offset int  // bit of buf holding bit 0, non-zero only for slices, < 8
+	length int  // number of bits
+	slice  bool // buf is shared with the bitmap this was sliced from
 }
 
 // NewBitmap creates a new bitmap with specified length
@@ -31,9 +33,49 @@ func (b *Bitmap) Len() int {
 	return b.length
 }
 
-// Bytes returns the underlying byte buffer
+// Bytes returns the underlying byte buffer. For a slice that does not start
+// on a byte boundary it returns a copy shifted so that bit 0 is the lowest
+// bit of the first byte.
 func (b *Bitmap) Bytes() []byte {
-	return b.buf
+	if b.offset == 0 {
+		return b.buf
+	}
+	out := make([]byte, (b.length+7)/8)
+	for j := range out {
+		out[j] = b.alignedByte(j)
+	}
+	if remainder := b.length % 8; remainder > 0 {
+		out[len(out)-1] &= byte((1 << remainder) - 1)
+	}
+	return out
+}
+
+// alignedByte returns bits 8j to 8j+7 as one byte, joining the two bytes of
+// buf they straddle in a slice that does not start on a byte boundary. Bits
+// past the end of buf read as 0.
+func (b *Bitmap) alignedByte(j int) byte {
+	k := j + b.offset/8
+	v := b.buf[k] >> b.offset
+	if b.offset != 0 && k+1 < len(b.buf) {
+		v |= b.buf[k+1] << (8 - b.offset)
+	}
+	return v
+}
+
+// Slice returns a view of length bits starting at bit offset. The view
+// shares b's buffer without copying, also when offset is not a multiple of
+// 8, so Set and Clear on either are seen by both.
+func (b *Bitmap) Slice(offset, length int) *Bitmap {
+	if offset < 0 || length < 0 || offset+length > b.length {
+		panic("bitmap slice out of range")
+	}
+	start := b.offset + offset
+	return &Bitmap{
+		buf:    b.buf[start/8 : (start+length+7)/8],
+		offset: start % 8,
+		length: length,
+		slice:  true,
+	}
 }
 
 // Set sets the bit at index i to 1
@@ -41,6 +83,7 @@ func (b *Bitmap) Set(i int) {
 	if i < 0 || i >= b.length {
 		panic("bitmap index out of range")
 	}
+	i += b.offset
 	b.buf[i/8] |= 1 << (i % 8)
 }
 
@@ -49,6 +92,7 @@ func (b *Bitmap) Clear(i int) {
 	if i < 0 || i >= b.length {
 		panic("bitmap index out of range")
 	}
+	i += b.offset
 	b.buf[i/8] &^= 1 << (i % 8)
 }
 
@@ -57,20 +101,43 @@ func (b *Bitmap) IsSet(i int) bool {
 	if i < 0 || i >= b.length {
 		panic("bitmap index out of range")
 	}
+	i += b.offset
 	return (b.buf[i/8] & (1 << (i % 8))) != 0
 }
 
-// SetAll sets all bits to 1
+// SetAll sets all bits to 1. Bits of a shared buffer outside a slice are
+// left alone.
 func (b *Bitmap) SetAll() {
-	for i := range b.buf {
-		b.buf[i] = 0xFF
-	}
+	b.fill(0xFF)
 }
 
 // ClearAll sets all bits to 0
 func (b *Bitmap) ClearAll() {
-	for i := range b.buf {
-		b.buf[i] = 0
+	b.fill(0)
+}
+
+// fill sets every bit to the bit of v. A bitmap owning its buffer fills it
+// whole, padding included; a slice fills whole bytes only where it covers
+// them.
+func (b *Bitmap) fill(v byte) {
+	if !b.slice {
+		for i := range b.buf {
+			b.buf[i] = v
+		}
+		return
+	}
+	i := 0
+	if b.offset == 0 {
+		for ; i+8 <= b.length; i += 8 {
+			b.buf[i/8] = v
+		}
+	}
+	for ; i < b.length; i++ {
+		if v != 0 {
+			b.Set(i)
+		} else {
+			b.Clear(i)
+		}
 	}
 }
 
@@ -81,14 +148,14 @@ func (b *Bitmap) CountSet() int {
 
 	// ✅ 批量处理完整字节（8x 加速）
 	for i := 0; i < fullBytes; i++ {
-		count += bits.OnesCount8(b.buf[i]) // CPU 指令级优化
+		count += bits.OnesCount8(b.alignedByte(i)) // CPU 指令级优化
 	}
 
 	// 处理剩余位
 	remainder := b.length % 8
 	if remainder > 0 {
 		mask := byte((1 << remainder) - 1)
-		count += bits.OnesCount8(b.buf[fullBytes] & mask)
+		count += bits.OnesCount8(b.alignedByte(fullBytes) & mask)
 	}
 	return count
 }
@@ -98,7 +165,20 @@ func (b *Bitmap) Resize(newLength int) {
 		return
 	}
 
-	newNumBytes := (newLength + 7) / 8
+	// Growing a slice would expose bits of the bitmap sharing its buffer, so
+	// it gets a buffer of its own
+	if b.slice && newLength > b.length {
+		own := NewBitmap(newLength)
+		for i := 0; i < b.length; i++ {
+			if b.IsSet(i) {
+				own.Set(i)
+			}
+		}
+		*b = *own
+		return
+	}
+
+	newNumBytes := (b.offset + newLength + 7) / 8
 	if newNumBytes > len(b.buf) {
 		newBuf := make([]byte, newNumBytes)
 		copy(newBuf, b.buf)
@@ -68,6 +68,17 @@ func (b *Buffer) Int64() []int64 {
 	return unsafe.Slice((*int64)(unsafe.Pointer(&b.buf[0])), len(b.buf)/8)
 }
 
+// Uint16 returns a uint16 view of the buffer
+func (b *Buffer) Uint16() []uint16 {
+	if len(b.buf) == 0 {
+		return nil
+	}
+	if len(b.buf)%2 != 0 {
+		panic(fmt.Sprintf("buffer size %d not aligned to uint16", len(b.buf)))
+	}
+	return unsafe.Slice((*uint16)(unsafe.Pointer(&b.buf[0])), len(b.buf)/2)
+}
+
 // Float32 returns a float32 view of the buffer
 func (b *Buffer) Float32() []float32 {
 	if len(b.buf) == 0 {
@@ -110,6 +121,15 @@ func NewInt64Buffer(data []int64) *Buffer {
 	return &Buffer{buf: buf}
 }
 
+// NewUint16Buffer creates a buffer from uint16 slice
+func NewUint16Buffer(data []uint16) *Buffer {
+	buf := make([]byte, len(data)*2)
+	for i, v := range data {
+		binary.LittleEndian.PutUint16(buf[i*2:], v)
+	}
+	return &Buffer{buf: buf}
+}
+
 // NewFloat32Buffer creates a buffer from float32 slice
 func NewFloat32Buffer(data []float32) *Buffer {
 	buf := make([]byte, len(data)*4)
@@ -1,5 +1,7 @@
 package arrow
 
+import "math"
+
 // Builder is the interface for building arrays incrementally
 type Builder interface {
 	// Reserve reserves space for n additional elements
@@ -148,6 +150,76 @@ func (b *Int64Builder) NewArray() Array {
 
 func (b *Int64Builder) Release() {}
 
+// --- Float16Builder ---
+
+type Float16Builder struct {
+	data     []uint16
+	nulls    *Bitmap
+	hasNulls bool
+}
+
+func NewFloat16Builder() *Float16Builder {
+	return &Float16Builder{
+		data:  make([]uint16, 0, 16),
+		nulls: NewBitmap(0),
+	}
+}
+
+func (b *Float16Builder) Reserve(n int) {
+	if cap(b.data)-len(b.data) < n {
+		newCap := len(b.data) + n
+		newData := make([]uint16, len(b.data), newCap)
+		copy(newData, b.data)
+		b.data = newData
+	}
+}
+
+// Append appends the half-precision value with bits v
+func (b *Float16Builder) Append(v uint16) {
+	b.data = append(b.data, v)
+	if b.hasNulls {
+		b.nulls.Resize(len(b.data))
+		b.nulls.Set(len(b.data) - 1)
+	}
+}
+
+// AppendFloat32 appends v rounded to half precision
+func (b *Float16Builder) AppendFloat32(v float32) {
+	b.Append(Float32ToFloat16(v))
+}
+
+func (b *Float16Builder) AppendNull() {
+	if !b.hasNulls {
+		b.hasNulls = true
+		b.nulls = NewBitmap(len(b.data))
+		b.nulls.SetAll()
+	}
+	b.data = append(b.data, 0)
+	b.nulls.Resize(len(b.data))
+	b.nulls.Clear(len(b.data) - 1)
+}
+
+func (b *Float16Builder) Len() int {
+	return len(b.data)
+}
+
+func (b *Float16Builder) NewArray() Array {
+	var nullBitmap *Bitmap
+	if b.hasNulls {
+		nullBitmap = b.nulls
+	}
+
+	arr := NewFloat16Array(b.data, nullBitmap)
+
+	b.data = make([]uint16, 0, 16)
+	b.nulls = NewBitmap(0)
+	b.hasNulls = false
+
+	return arr
+}
+
+func (b *Float16Builder) Release() {}
+
 // --- Float32Builder ---
 
 type Float32Builder struct {
@@ -280,20 +352,34 @@ func (b *Float64Builder) Release() {}
 
 type FixedSizeListBuilder struct {
 	listType *FixedSizeListType
-	values   *Float32Builder
+	values   Builder // *Float32Builder, or *Float16Builder for float16 lists
 	nulls    *Bitmap
 	hasNulls bool
 	length   int // number of lists
 }
 
 func NewFixedSizeListBuilder(listType *FixedSizeListType) *FixedSizeListBuilder {
+	var values Builder = NewFloat32Builder()
+	if listType.Elem().ID() == FLOAT16 {
+		values = NewFloat16Builder()
+	}
 	return &FixedSizeListBuilder{
 		listType: listType,
-		values:   NewFloat32Builder(),
+		values:   values,
 		nulls:    NewBitmap(0),
 	}
 }
 
+// appendValue appends v to the child values, rounding it for float16 lists
+func (b *FixedSizeListBuilder) appendValue(v float32) {
+	switch values := b.values.(type) {
+	case *Float16Builder:
+		values.AppendFloat32(v)
+	case *Float32Builder:
+		values.Append(v)
+	}
+}
+
 func (b *FixedSizeListBuilder) Reserve(n int) {
 	b.values.Reserve(n * b.listType.Size())
 }
@@ -304,7 +390,7 @@ func (b *FixedSizeListBuilder) AppendValues(values []float32) {
 		panic("fixed-size list size mismatch")
 	}
 	for _, v := range values {
-		b.values.Append(v)
+		b.appendValue(v)
 	}
 	if b.hasNulls {
 		b.nulls.Resize(b.length + 1)
@@ -321,7 +407,7 @@ func (b *FixedSizeListBuilder) AppendNull() {
 	}
 	// Append placeholder values
 	for i := 0; i < b.listType.Size(); i++ {
-		b.values.Append(0)
+		b.appendValue(0)
 	}
 	b.nulls.Resize(b.length + 1)
 	b.nulls.Clear(b.length)
@@ -439,3 +525,165 @@ func (b *ListBuilder) NewArray() Array {
 func (b *ListBuilder) Release() {
 	b.values.Release()
 }
+
+// --- BinaryBuilder / StringBuilder (variable-length) ---
+
+type BinaryBuilder struct {
+	offsets  []int32
+	data     []byte
+	nulls    *Bitmap
+	hasNulls bool
+}
+
+func NewBinaryBuilder() *BinaryBuilder {
+	return &BinaryBuilder{
+		offsets: []int32{0},
+		nulls:   NewBitmap(0),
+	}
+}
+
+// Reserve reserves offsets for n more values; the value bytes grow as
+// appended
+func (b *BinaryBuilder) Reserve(n int) {
+	if cap(b.offsets)-len(b.offsets) < n {
+		newOffsets := make([]int32, len(b.offsets), len(b.offsets)+n)
+		copy(newOffsets, b.offsets)
+		b.offsets = newOffsets
+	}
+}
+
+// Append appends a copy of v. It panics once the values pass the 2GB that
+// int32 offsets can address.
+func (b *BinaryBuilder) Append(v []byte) {
+	if len(b.data)+len(v) > math.MaxInt32 {
+		panic("binary builder: values exceed int32 offsets")
+	}
+	b.data = append(b.data, v...)
+	b.offsets = append(b.offsets, int32(len(b.data)))
+	if b.hasNulls {
+		b.nulls.Resize(b.Len())
+		b.nulls.Set(b.Len() - 1)
+	}
+}
+
+func (b *BinaryBuilder) AppendNull() {
+	if !b.hasNulls {
+		b.hasNulls = true
+		b.nulls = NewBitmap(b.Len())
+		b.nulls.SetAll()
+	}
+	b.offsets = append(b.offsets, int32(len(b.data))) // empty placeholder
+	b.nulls.Resize(b.Len())
+	b.nulls.Clear(b.Len() - 1)
+}
+
+func (b *BinaryBuilder) Len() int {
+	return len(b.offsets) - 1
+}
+
+func (b *BinaryBuilder) NewArray() Array {
+	return b.newBinaryArray()
+}
+
+func (b *BinaryBuilder) newBinaryArray() *BinaryArray {
+	var nullBitmap *Bitmap
+	if b.hasNulls {
+		nullBitmap = b.nulls
+	}
+
+	arr := NewBinaryArray(b.offsets, b.data, nullBitmap)
+
+	// Reset
+	b.offsets = []int32{0}
+	b.data = nil
+	b.nulls = NewBitmap(0)
+	b.hasNulls = false
+
+	return arr
+}
+
+func (b *BinaryBuilder) Release() {}
+
+// StringBuilder builds a StringArray
+type StringBuilder struct {
+	BinaryBuilder
+}
+
+func NewStringBuilder() *StringBuilder {
+	return &StringBuilder{BinaryBuilder: *NewBinaryBuilder()}
+}
+
+// Append appends v. Like BinaryBuilder.Append it panics past 2GB of values.
+func (b *StringBuilder) Append(v string) {
+	b.BinaryBuilder.Append([]byte(v))
+}
+
+func (b *StringBuilder) NewArray() Array {
+	arr := b.newBinaryArray()
+	arr.data.dtype = PrimString()
+	return &StringArray{BinaryArray: *arr}
+}
+
+// --- BooleanBuilder ---
+
+type BooleanBuilder struct {
+	data     []bool
+	nulls    *Bitmap
+	hasNulls bool
+}
+
+func NewBooleanBuilder() *BooleanBuilder {
+	return &BooleanBuilder{
+		data:  make([]bool, 0, 16),
+		nulls: NewBitmap(0),
+	}
+}
+
+func (b *BooleanBuilder) Reserve(n int) {
+	if cap(b.data)-len(b.data) < n {
+		newData := make([]bool, len(b.data), len(b.data)+n)
+		copy(newData, b.data)
+		b.data = newData
+	}
+}
+
+func (b *BooleanBuilder) Append(v bool) {
+	b.data = append(b.data, v)
+	if b.hasNulls {
+		b.nulls.Resize(len(b.data))
+		b.nulls.Set(len(b.data) - 1)
+	}
+}
+
+func (b *BooleanBuilder) AppendNull() {
+	if !b.hasNulls {
+		b.hasNulls = true
+		b.nulls = NewBitmap(len(b.data))
+		b.nulls.SetAll()
+	}
+	b.data = append(b.data, false) // placeholder
+	b.nulls.Resize(len(b.data))
+	b.nulls.Clear(len(b.data) - 1)
+}
+
+func (b *BooleanBuilder) Len() int {
+	return len(b.data)
+}
+
+func (b *BooleanBuilder) NewArray() Array {
+	var nullBitmap *Bitmap
+	if b.hasNulls {
+		nullBitmap = b.nulls
+	}
+
+	arr := NewBooleanArray(b.data, nullBitmap)
+
+	// Reset
+	b.data = make([]bool, 0, 16)
+	b.nulls = NewBitmap(0)
+	b.hasNulls = false
+
+	return arr
+}
+
+func (b *BooleanBuilder) Release() {}
@@ -264,3 +264,71 @@ func BenchmarkFloat32BuilderAppend(b *testing.B) {
 		builder.Append(float32(i))
 	}
 }
+
+func TestStringBuilder(t *testing.T) {
+	builder := NewStringBuilder()
+	builder.Append("héllo")
+	builder.AppendNull()
+	builder.Append("")
+	builder.Append("世界")
+
+	if builder.Len() != 4 {
+		t.Errorf("expected 4 strings, got %d", builder.Len())
+	}
+
+	arr := builder.NewArray().(*StringArray)
+	if arr.Len() != 4 || arr.NullN() != 1 || arr.DataType().ID() != STRING {
+		t.Fatalf("got %s len %d nulls %d", arr.DataType().Name(), arr.Len(), arr.NullN())
+	}
+	if !arr.IsNull(1) || arr.Value(0) != "héllo" || arr.Value(2) != "" || arr.Value(3) != "世界" {
+		t.Errorf("unexpected values %q %q %q", arr.Value(0), arr.Value(2), arr.Value(3))
+	}
+	if got := arr.Offsets(); len(got) != 5 || got[4] != int32(len("héllo世界")) {
+		t.Errorf("unexpected offsets %v", got)
+	}
+
+	// The builder is reset
+	if builder.Len() != 0 || builder.NewArray().Len() != 0 {
+		t.Error("builder was not reset")
+	}
+}
+
+func TestBinaryBuilder(t *testing.T) {
+	builder := NewBuilderForType(PrimBinary()).(*BinaryBuilder)
+	value := []byte{0, 1, 2}
+	builder.Append(value)
+	builder.Append(nil)
+	value[0] = 9
+
+	arr := builder.NewArray().(*BinaryArray)
+	if arr.Len() != 2 || arr.NullN() != 0 {
+		t.Fatalf("got len %d nulls %d", arr.Len(), arr.NullN())
+	}
+	if got := arr.Value(0); len(got) != 3 || got[0] != 0 || len(arr.Value(1)) != 0 {
+		t.Errorf("unexpected values %v %v", arr.Value(0), arr.Value(1))
+	}
+}
+
+func TestBooleanBuilder(t *testing.T) {
+	builder := NewBuilderForType(PrimBool()).(*BooleanBuilder)
+	for i := 0; i < 10; i++ {
+		builder.Append(i%3 == 0)
+	}
+	builder.AppendNull()
+
+	arr := builder.NewArray().(*BooleanArray)
+	if arr.Len() != 11 || arr.NullN() != 1 || arr.DataType().ID() != BOOL {
+		t.Fatalf("got %s len %d nulls %d", arr.DataType().Name(), arr.Len(), arr.NullN())
+	}
+	for i := 0; i < 10; i++ {
+		if arr.Value(i) != (i%3 == 0) {
+			t.Errorf("value %d: expected %v", i, i%3 == 0)
+		}
+	}
+	if !arr.IsNull(10) {
+		t.Error("expected value 10 to be null")
+	}
+	if got := arr.ValueBitmap().Bytes(); len(got) != 2 || got[0] != 0x49 || got[1] != 0x02 {
+		t.Errorf("unexpected packed values %08b", got)
+	}
+}
@@ -15,13 +15,15 @@ const (
 	FIXED_SIZE_LIST
 	LIST
 	STRUCT
+	FLOAT16
+	BOOL
 )
 
 // DataType represents the type of data stored in a column
 type DataType interface {
 	ID() TypeID
 	Name() string
-	ByteWidth() int // -1 for variable length
+	ByteWidth() int // -1 for variable length, 0 for bit-packed bool
 }
 
 // --- Primitive Types ---
@@ -38,6 +40,22 @@ func (t *Int64Type) ID() TypeID     { return INT64 }
 func (t *Int64Type) Name() string   { return "int64" }
 func (t *Int64Type) ByteWidth() int { return 8 }
 
+// Float16Type is an IEEE 754 half-precision float, stored as its uint16
+// bits. It has about 3 significant decimal digits, plenty for persisted
+// embeddings; arithmetic is done after converting to float32.
+type Float16Type struct{}
+
+func (t *Float16Type) ID() TypeID     { return FLOAT16 }
+func (t *Float16Type) Name() string   { return "float16" }
+func (t *Float16Type) ByteWidth() int { return 2 }
+
+// BooleanType is a bit-packed boolean, one bit per value
+type BooleanType struct{}
+
+func (t *BooleanType) ID() TypeID     { return BOOL }
+func (t *BooleanType) Name() string   { return "bool" }
+func (t *BooleanType) ByteWidth() int { return 0 }
+
 type Float32Type struct{}
 
 func (t *Float32Type) ID() TypeID     { return FLOAT32 }
@@ -109,10 +127,12 @@ func (t *StructType) Fields() []Field { return t.fields }
 
 func PrimInt32() DataType   { return &Int32Type{} }
 func PrimInt64() DataType   { return &Int64Type{} }
+func PrimFloat16() DataType { return &Float16Type{} }
 func PrimFloat32() DataType { return &Float32Type{} }
 func PrimFloat64() DataType { return &Float64Type{} }
 func PrimBinary() DataType  { return &BinaryType{} }
 func PrimString() DataType  { return &StringType{} }
+func PrimBool() DataType    { return &BooleanType{} }
 
 func FixedSizeListOf(elem DataType, size int) DataType {
 	return &FixedSizeListType{elem: elem, size: size}
@@ -130,3 +150,9 @@ func StructOf(fields []Field) DataType {
 func VectorType(dim int) DataType {
 	return FixedSizeListOf(PrimFloat32(), dim)
 }
+
+// Float16VectorType creates a fixed-size float16 vector type, half the size
+// of VectorType
+func Float16VectorType(dim int) DataType {
+	return FixedSizeListOf(PrimFloat16(), dim)
+}
@@ -0,0 +1,337 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Minimal FlatBuffers support for the Arrow IPC metadata messages.
+//
+// Only the subset needed by ipc.go is implemented: tables with scalar and
+// offset fields, strings, vectors of tables and vectors of structs. Objects are
+// described as a tree and serialized front-to-back, so every uoffset points
+// forward as the FlatBuffers spec requires.
+
+// fbObject is anything that can be referenced through a uoffset.
+type fbObject interface {
+	write(w *fbWriter) int
+}
+
+// fbTable is a table under construction; slots are indexed by field id.
+type fbTable struct {
+	slots []fbSlot
+}
+
+type fbSlot struct {
+	set    bool
+	scalar []byte   // little-endian scalar value
+	ref    fbObject // non-nil for offset fields
+}
+
+func (t *fbTable) slot(id int) *fbSlot {
+	for len(t.slots) <= id {
+		t.slots = append(t.slots, fbSlot{})
+	}
+	return &t.slots[id]
+}
+
+func (t *fbTable) addUint8(id int, v uint8) *fbTable {
+	s := t.slot(id)
+	s.set, s.scalar = true, []byte{v}
+	return t
+}
+
+func (t *fbTable) addBool(id int, v bool) *fbTable {
+	if v {
+		return t.addUint8(id, 1)
+	}
+	return t.addUint8(id, 0)
+}
+
+func (t *fbTable) addInt16(id int, v int16) *fbTable {
+	s := t.slot(id)
+	s.set, s.scalar = true, binary.LittleEndian.AppendUint16(nil, uint16(v))
+	return t
+}
+
+func (t *fbTable) addInt32(id int, v int32) *fbTable {
+	s := t.slot(id)
+	s.set, s.scalar = true, binary.LittleEndian.AppendUint32(nil, uint32(v))
+	return t
+}
+
+func (t *fbTable) addInt64(id int, v int64) *fbTable {
+	s := t.slot(id)
+	s.set, s.scalar = true, binary.LittleEndian.AppendUint64(nil, uint64(v))
+	return t
+}
+
+func (t *fbTable) addRef(id int, o fbObject) *fbTable {
+	s := t.slot(id)
+	s.set, s.ref = true, o
+	return t
+}
+
+func (t *fbTable) write(w *fbWriter) int {
+	// Lay out fields relative to the table start; the table start is aligned
+	// to the widest scalar so relative and absolute alignment agree.
+	tableAlign := 4
+	offsets := make([]int, len(t.slots))
+	size := 4 // soffset to vtable
+	for i, s := range t.slots {
+		if !s.set {
+			continue
+		}
+		width := 4
+		if s.ref == nil {
+			width = len(s.scalar)
+		}
+		if width > tableAlign {
+			tableAlign = width
+		}
+		size = alignUp(size, width)
+		offsets[i] = size
+		size += width
+	}
+	size = alignUp(size, 4)
+
+	// vtable: [vtable size][table size][field offsets...]
+	w.pad(2)
+	vtablePos := len(w.buf)
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(4+2*len(t.slots)))
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(size))
+	for _, off := range offsets {
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(off))
+	}
+
+	w.pad(tableAlign)
+	tablePos := len(w.buf)
+	w.buf = append(w.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(w.buf[tablePos:], uint32(int32(tablePos-vtablePos)))
+	for i, s := range t.slots {
+		if s.set && s.ref == nil {
+			copy(w.buf[tablePos+offsets[i]:], s.scalar)
+		}
+	}
+
+	for i, s := range t.slots {
+		if s.set && s.ref != nil {
+			w.patch(tablePos+offsets[i], s.ref.write(w))
+		}
+	}
+	return tablePos
+}
+
+// fbString is a FlatBuffers string.
+type fbString string
+
+func (s fbString) write(w *fbWriter) int {
+	w.pad(4)
+	pos := len(w.buf)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(s)))
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, 0)
+	return pos
+}
+
+// fbRefVector is a vector of tables or strings.
+type fbRefVector []fbObject
+
+func (v fbRefVector) write(w *fbWriter) int {
+	w.pad(4)
+	pos := len(w.buf)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(v)))
+	slots := len(w.buf)
+	w.buf = append(w.buf, make([]byte, 4*len(v))...)
+	for i, o := range v {
+		w.patch(slots+4*i, o.write(w))
+	}
+	return pos
+}
+
+// fbStructVector is a vector of fixed-size structs stored inline.
+type fbStructVector struct {
+	elemSize  int
+	elemAlign int
+	data      []byte
+}
+
+func (v fbStructVector) write(w *fbWriter) int {
+	// The elements (not the length prefix) must be aligned
+	for (len(w.buf)+4)%v.elemAlign != 0 {
+		w.buf = append(w.buf, 0)
+	}
+	pos := len(w.buf)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(v.data)/v.elemSize))
+	w.buf = append(w.buf, v.data...)
+	return pos
+}
+
+// fbWriter accumulates a serialized buffer.
+type fbWriter struct {
+	buf []byte
+}
+
+func (w *fbWriter) pad(align int) {
+	for len(w.buf)%align != 0 {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// patch stores a uoffset at slot pointing to target.
+func (w *fbWriter) patch(slot, target int) {
+	binary.LittleEndian.PutUint32(w.buf[slot:], uint32(target-slot))
+}
+
+// fbFinish serializes root into a FlatBuffer padded to 8 bytes.
+func fbFinish(root *fbTable) []byte {
+	w := &fbWriter{buf: make([]byte, 4, 256)}
+	w.patch(0, root.write(w))
+	w.pad(8)
+	return w.buf
+}
+
+func alignUp(n, align int) int {
+	return (n + align - 1) &^ (align - 1)
+}
+
+// --- Reading ---
+
+// fbError is raised (via panic) by the reader on out-of-bounds access and
+// converted back to an error at the API boundary.
+type fbError struct {
+	msg string
+}
+
+func (e fbError) Error() string { return e.msg }
+
+// fbRef is a reference to a table inside a FlatBuffer.
+type fbRef struct {
+	buf []byte
+	pos int
+}
+
+func fbRoot(buf []byte) fbRef {
+	r := fbRef{buf: buf}
+	r.pos = r.deref(0)
+	return r
+}
+
+func (r fbRef) check(pos, n int) {
+	if pos < 0 || n < 0 || pos+n > len(r.buf) {
+		panic(fbError{fmt.Sprintf("flatbuffer access [%d:%d] out of bounds (%d)", pos, pos+n, len(r.buf))})
+	}
+}
+
+func (r fbRef) u16(pos int) uint16 {
+	r.check(pos, 2)
+	return binary.LittleEndian.Uint16(r.buf[pos:])
+}
+
+func (r fbRef) u32(pos int) uint32 {
+	r.check(pos, 4)
+	return binary.LittleEndian.Uint32(r.buf[pos:])
+}
+
+func (r fbRef) u64(pos int) uint64 {
+	r.check(pos, 8)
+	return binary.LittleEndian.Uint64(r.buf[pos:])
+}
+
+// deref follows the uoffset stored at pos.
+func (r fbRef) deref(pos int) int {
+	return pos + int(r.u32(pos))
+}
+
+// field returns the absolute position of field id, or 0 if absent.
+func (r fbRef) field(id int) int {
+	vtable := r.pos - int(int32(r.u32(r.pos)))
+	vtableSize := int(r.u16(vtable))
+	entry := 4 + 2*id
+	if entry+2 > vtableSize {
+		return 0
+	}
+	off := int(r.u16(vtable + entry))
+	if off == 0 {
+		return 0
+	}
+	return r.pos + off
+}
+
+func (r fbRef) uint8(id int, def uint8) uint8 {
+	pos := r.field(id)
+	if pos == 0 {
+		return def
+	}
+	r.check(pos, 1)
+	return r.buf[pos]
+}
+
+func (r fbRef) bool(id int, def bool) bool {
+	d := uint8(0)
+	if def {
+		d = 1
+	}
+	return r.uint8(id, d) != 0
+}
+
+func (r fbRef) int16(id int, def int16) int16 {
+	pos := r.field(id)
+	if pos == 0 {
+		return def
+	}
+	return int16(r.u16(pos))
+}
+
+func (r fbRef) int32(id int, def int32) int32 {
+	pos := r.field(id)
+	if pos == 0 {
+		return def
+	}
+	return int32(r.u32(pos))
+}
+
+func (r fbRef) int64(id int, def int64) int64 {
+	pos := r.field(id)
+	if pos == 0 {
+		return def
+	}
+	return int64(r.u64(pos))
+}
+
+func (r fbRef) table(id int) (fbRef, bool) {
+	pos := r.field(id)
+	if pos == 0 {
+		return fbRef{}, false
+	}
+	return fbRef{buf: r.buf, pos: r.deref(pos)}, true
+}
+
+func (r fbRef) string(id int) string {
+	pos := r.field(id)
+	if pos == 0 {
+		return ""
+	}
+	return r.stringAt(r.deref(pos))
+}
+
+func (r fbRef) stringAt(pos int) string {
+	n := int(r.u32(pos))
+	r.check(pos+4, n)
+	return string(r.buf[pos+4 : pos+4+n])
+}
+
+// vector returns the position of the first element and the element count.
+func (r fbRef) vector(id int) (int, int) {
+	pos := r.field(id)
+	if pos == 0 {
+		return 0, 0
+	}
+	vec := r.deref(pos)
+	return vec + 4, int(r.u32(vec))
+}
+
+// tableAt returns the i-th table of a vector of tables starting at elems.
+func (r fbRef) tableAt(elems, i int) fbRef {
+	return fbRef{buf: r.buf, pos: r.deref(elems + 4*i)}
+}
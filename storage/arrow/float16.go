@@ -0,0 +1,92 @@
+package arrow
+
+import "math"
+
+// Float32ToFloat16 rounds f to the nearest half-precision value, ties to
+// even, and returns its bits. Values beyond ±65504 become ±Inf and values
+// below 2^-24 in magnitude become ±0; NaNs stay NaN.
+func Float32ToFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if exp == 0xff {
+		if mant != 0 {
+			return sign | 0x7e00 // NaN
+		}
+		return sign | 0x7c00 // Inf
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		return sign | 0x7c00 // 溢出为 Inf
+	}
+	if e <= 0 {
+		// Subnormal: the value is m * 2^-24 with the implicit bit in mant
+		if e < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - e)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || rem == halfway && half&1 == 1 {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	// A carry out of the mantissa rounds up into the exponent, and up to
+	// Inf from the largest finite value, as it should
+	half := uint32(e)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || rem == 0x1000 && half&1 == 1 {
+		half++
+	}
+	return sign | uint16(half)
+}
+
+// Float16ToFloat32 returns the half-precision value with bits h as a
+// float32, which represents every one of them exactly
+func Float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0x1f: // Inf / NaN
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Subnormal: normalize into a float32 exponent
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3ff)<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// Float32sToFloat16 rounds every value of src to half precision
+func Float32sToFloat16(src []float32) []uint16 {
+	dst := make([]uint16, len(src))
+	for i, v := range src {
+		dst[i] = Float32ToFloat16(v)
+	}
+	return dst
+}
+
+// Float16sToFloat32 converts half-precision bits to float32
+func Float16sToFloat32(src []uint16) []float32 {
+	dst := make([]float32, len(src))
+	for i, v := range src {
+		dst[i] = Float16ToFloat32(v)
+	}
+	return dst
+}
@@ -0,0 +1,154 @@
+package arrow
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestFloat16Conversion(t *testing.T) {
+	cases := []struct {
+		f    float32
+		bits uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},                 // 最大有限值
+		{6.103515625e-05, 0x0400},       // 最小正规数
+		{5.960464477539063e-08, 0x0001}, // 最小次正规数
+		{float32(math.Inf(1)), 0x7c00},
+		{float32(math.Inf(-1)), 0xfc00},
+		{1e6, 0x7c00},          // 溢出
+		{1e-9, 0x0000},         // 下溢
+		{1 + 1.0/2048, 0x3c00}, // 恰在中点，舍入到偶数
+		{1 + 3.0/2048, 0x3c02}, // 恰在中点，舍入到偶数
+		{65520, 0x7c00},        // 最大有限值之后的中点舍入为 Inf
+	}
+	for _, c := range cases {
+		if got := Float32ToFloat16(c.f); got != c.bits {
+			t.Errorf("Float32ToFloat16(%g) = %#04x, want %#04x", c.f, got, c.bits)
+		}
+	}
+
+	if got := Float32ToFloat16(float32(math.NaN())); got&0x7c00 != 0x7c00 || got&0x3ff == 0 {
+		t.Errorf("Float32ToFloat16(NaN) = %#04x, not a NaN", got)
+	}
+}
+
+func TestFloat16RoundTrip(t *testing.T) {
+	// Every half-precision value survives a round trip through float32
+	for h := 0; h <= 0xffff; h++ {
+		bits := uint16(h)
+		f := Float16ToFloat32(bits)
+		if math.IsNaN(float64(f)) {
+			if bits&0x7c00 != 0x7c00 || bits&0x3ff == 0 {
+				t.Fatalf("%#04x decoded to NaN", bits)
+			}
+			continue
+		}
+		if got := Float32ToFloat16(f); got != bits {
+			t.Fatalf("%#04x -> %g -> %#04x", bits, f, got)
+		}
+	}
+
+	// Normal values round with a relative error of at most 2^-11
+	for _, f := range []float32{0.1, -0.3333, 3.14159, 123.456, -4000.5, 0.0009765} {
+		got := Float16ToFloat32(Float32ToFloat16(f))
+		if rel := math.Abs(float64(got-f) / float64(f)); rel > 1.0/2048 {
+			t.Errorf("%g rounded to %g, relative error %g", f, got, rel)
+		}
+	}
+}
+
+func TestFloat16Array(t *testing.T) {
+	data := []float32{1.5, -2.25, 0.125}
+	validity := NewBitmapAllSet(3)
+	validity.Clear(1)
+	arr := NewFloat16ArrayFromFloat32(data, validity)
+
+	if arr.Len() != 3 || arr.NullN() != 1 || arr.DataType().ByteWidth() != 2 {
+		t.Fatalf("unexpected array: len %d, nulls %d", arr.Len(), arr.NullN())
+	}
+	if !arr.IsNull(1) || arr.IsNull(0) {
+		t.Errorf("null mismatch")
+	}
+	if arr.Float32(0) != 1.5 || arr.Float32(2) != 0.125 {
+		t.Errorf("expected exact values, got %v", arr.Float32Values())
+	}
+	if s := SliceArray(arr, 1, 2).(*Float16Array); s.Float32(1) != 0.125 {
+		t.Errorf("slice: expected 0.125, got %g", s.Float32(1))
+	}
+
+	builder := NewBuilderForType(PrimFloat16()).(*Float16Builder)
+	builder.AppendFloat32(3)
+	builder.AppendNull()
+	built := builder.NewArray().(*Float16Array)
+	if built.Float32(0) != 3 || !built.IsNull(1) {
+		t.Errorf("builder: got %v", built.Float32Values())
+	}
+}
+
+func TestFixedSizeListBuilderFloat16(t *testing.T) {
+	listType := Float16VectorType(3).(*FixedSizeListType)
+	builder := NewFixedSizeListBuilder(listType)
+	builder.AppendValues([]float32{1, 2, 3})
+	builder.AppendNull()
+	builder.AppendValues([]float32{0.1, 0.2, 0.3})
+
+	arr := builder.NewArray().(*FixedSizeListArray)
+	if arr.Len() != 3 || arr.NullN() != 1 {
+		t.Fatalf("expected 3 lists with 1 null, got %d with %d", arr.Len(), arr.NullN())
+	}
+	values, ok := arr.Values().(*Float16Array)
+	if !ok {
+		t.Fatalf("expected float16 child values, got %T", arr.Values())
+	}
+	if values.Float32(1) != 2 {
+		t.Errorf("expected 2, got %g", values.Float32(1))
+	}
+	if got := arr.ValueSlice(2).([]uint16)[0]; got != Float32ToFloat16(0.1) {
+		t.Errorf("expected %#04x, got %#04x", Float32ToFloat16(0.1), got)
+	}
+	if arr.DataType().Name() != "fixed_size_list<float16>[3]" {
+		t.Errorf("unexpected type name %s", arr.DataType().Name())
+	}
+}
+
+func TestIPCRoundTripFloat16(t *testing.T) {
+	schema := NewSchema([]Field{
+		NewField("half", PrimFloat16(), false),
+		NewField("vector", Float16VectorType(2), false),
+	}, nil)
+	listType := Float16VectorType(2).(*FixedSizeListType)
+	batch, err := NewRecordBatch(schema, 2, []Array{
+		NewFloat16ArrayFromFloat32([]float32{0.5, -1}, nil),
+		NewFixedSizeListArray(listType, NewFloat16ArrayFromFloat32([]float32{1, 2, 3, 4}, nil), nil),
+	})
+	if err != nil {
+		t.Fatalf("NewRecordBatch failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteIPC(&buf, batch); err != nil {
+		t.Fatalf("WriteIPC failed: %v", err)
+	}
+	got, err := ReadIPC(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadIPC failed: %v", err)
+	}
+	if !schema.Equal(got.Schema()) {
+		t.Fatalf("schema mismatch: %s", got.Schema())
+	}
+	if v := got.Column(0).(*Float16Array).Float32Values(); v[0] != 0.5 || v[1] != -1 {
+		t.Errorf("half column: got %v", v)
+	}
+	vectors := got.Column(1).(*FixedSizeListArray).Values().(*Float16Array).Float32Values()
+	for i, want := range []float32{1, 2, 3, 4} {
+		if vectors[i] != want {
+			t.Errorf("vector value %d: expected %g, got %g", i, want, vectors[i])
+		}
+	}
+}
@@ -0,0 +1,679 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+// Arrow IPC streaming format (a.k.a. Feather v2 stream) support.
+//
+// A stream is a Schema message, followed by RecordBatch messages, followed by
+// an end-of-stream marker. Each message is:
+//
+//	<0xFFFFFFFF continuation> <int32 metadata size> <flatbuffer Message> <body>
+//
+// with the metadata padded so the body starts on an 8-byte boundary and every
+// body buffer padded to 8 bytes. Only the types this package supports are
+// handled; dictionaries and body compression are rejected.
+
+const (
+	ipcContinuation    = 0xFFFFFFFF
+	ipcMetadataVersion = 4 // MetadataVersion.V5
+
+	// MessageHeader union
+	ipcHeaderSchema      = 1
+	ipcHeaderRecordBatch = 3
+
+	// Type union
+	ipcTypeInt           = 2
+	ipcTypeFloatingPoint = 3
+	ipcTypeBinary        = 4
+	ipcTypeUtf8          = 5
+	ipcTypeBool          = 6
+	ipcTypeList          = 12
+	ipcTypeFixedSizeList = 16
+
+	// FloatingPoint precision
+	ipcPrecisionHalf   = 0
+	ipcPrecisionSingle = 1
+	ipcPrecisionDouble = 2
+)
+
+// WriteIPC writes batch to w as a complete Arrow IPC stream (schema message,
+// one record batch message, end-of-stream marker).
+func WriteIPC(w io.Writer, batch *RecordBatch) error {
+	if batch == nil {
+		return lerrors.InvalidArg("write_ipc", "nil record batch")
+	}
+
+	schema, err := ipcSchemaTable(batch.Schema())
+	if err != nil {
+		return err
+	}
+	if err := writeIPCMessage(w, ipcHeaderSchema, schema, nil); err != nil {
+		return err
+	}
+
+	enc := &ipcBodyEncoder{}
+	for i, col := range batch.Columns() {
+		if err := enc.encode(col); err != nil {
+			return lerrors.New(lerrors.ErrEncodeFailed).
+				Op("write_ipc").
+				Context("column", batch.Schema().Field(i).Name).
+				Wrap(err).
+				Build()
+		}
+	}
+	header := (&fbTable{}).
+		addInt64(0, int64(batch.NumRows())).
+		addRef(1, fbStructVector{elemSize: 16, elemAlign: 8, data: enc.nodes}).
+		addRef(2, fbStructVector{elemSize: 16, elemAlign: 8, data: enc.buffers})
+	if err := writeIPCMessage(w, ipcHeaderRecordBatch, header, enc.body); err != nil {
+		return err
+	}
+
+	// End-of-stream marker
+	eos := binary.LittleEndian.AppendUint32(nil, ipcContinuation)
+	eos = binary.LittleEndian.AppendUint32(eos, 0)
+	if _, err := w.Write(eos); err != nil {
+		return lerrors.IO("write_ipc", "", err)
+	}
+	return nil
+}
+
+// ReadIPC reads an Arrow IPC stream from r and returns its first record
+// batch. A stream without record batches yields an empty batch.
+func ReadIPC(r io.Reader) (batch *RecordBatch, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			fe, ok := rec.(fbError)
+			if !ok {
+				panic(rec)
+			}
+			batch, err = nil, lerrors.New(lerrors.ErrCorruptedFile).Op("read_ipc").Wrap(fe).Build()
+		}
+	}()
+
+	msg, _, err := readIPCMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if msg.pos == 0 || msg.uint8(1, 0) != ipcHeaderSchema {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("read_ipc").
+			Context("message", "stream must start with a schema message").
+			Build()
+	}
+	header, _ := msg.table(2)
+	schema, err := ipcReadSchema(header)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, body, err := readIPCMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if msg.pos == 0 {
+		return ipcEmptyBatch(schema)
+	}
+	if msg.uint8(1, 0) != ipcHeaderRecordBatch {
+		return nil, lerrors.New(lerrors.ErrNotSupported).
+			Op("read_ipc").
+			Context("header_type", msg.uint8(1, 0)).
+			Build()
+	}
+	header, _ = msg.table(2)
+	return ipcReadRecordBatch(schema, header, body)
+}
+
+// --- Writing ---
+
+func writeIPCMessage(w io.Writer, headerType uint8, header *fbTable, body []byte) error {
+	msg := (&fbTable{}).
+		addInt16(0, ipcMetadataVersion).
+		addUint8(1, headerType).
+		addRef(2, header).
+		addInt64(3, int64(len(body)))
+	meta := fbFinish(msg)
+
+	prefix := binary.LittleEndian.AppendUint32(nil, ipcContinuation)
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(meta)))
+	for _, chunk := range [][]byte{prefix, meta, body} {
+		if _, err := w.Write(chunk); err != nil {
+			return lerrors.IO("write_ipc", "", err)
+		}
+	}
+	return nil
+}
+
+func ipcSchemaTable(schema *Schema) (*fbTable, error) {
+	fields := make(fbRefVector, 0, schema.NumFields())
+	for _, f := range schema.Fields() {
+		field, err := ipcFieldTable(f.Name, f.Type, f.Nullable, f.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	t := (&fbTable{}).
+		addInt16(0, 0). // Little endian
+		addRef(1, fields)
+	if len(schema.Metadata()) > 0 {
+		t.addRef(2, ipcKeyValues(schema.Metadata()))
+	}
+	return t, nil
+}
+
+func ipcFieldTable(name string, dtype DataType, nullable bool, metadata map[string]string) (*fbTable, error) {
+	var typeID uint8
+	typeTable := &fbTable{}
+	children := fbRefVector{}
+
+	switch dtype.ID() {
+	case INT32:
+		typeID = ipcTypeInt
+		typeTable.addInt32(0, 32).addBool(1, true)
+	case INT64:
+		typeID = ipcTypeInt
+		typeTable.addInt32(0, 64).addBool(1, true)
+	case FLOAT16:
+		typeID = ipcTypeFloatingPoint
+		typeTable.addInt16(0, ipcPrecisionHalf)
+	case FLOAT32:
+		typeID = ipcTypeFloatingPoint
+		typeTable.addInt16(0, ipcPrecisionSingle)
+	case FLOAT64:
+		typeID = ipcTypeFloatingPoint
+		typeTable.addInt16(0, ipcPrecisionDouble)
+	case BINARY:
+		typeID = ipcTypeBinary
+	case STRING:
+		typeID = ipcTypeUtf8
+	case BOOL:
+		typeID = ipcTypeBool
+	case FIXED_SIZE_LIST:
+		listType := dtype.(*FixedSizeListType)
+		typeID = ipcTypeFixedSizeList
+		typeTable.addInt32(0, int32(listType.Size()))
+		child, err := ipcFieldTable("item", listType.Elem(), true, nil)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	case LIST:
+		listType := dtype.(*ListType)
+		typeID = ipcTypeList
+		child, err := ipcFieldTable("item", listType.Elem(), true, nil)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	default:
+		return nil, lerrors.New(lerrors.ErrUnsupportedType).
+			Op("write_ipc").
+			Context("field", name).
+			Context("type", dtype.Name()).
+			Build()
+	}
+
+	t := (&fbTable{}).
+		addRef(0, fbString(name)).
+		addBool(1, nullable).
+		addUint8(2, typeID).
+		addRef(3, typeTable).
+		addRef(5, children)
+	if len(metadata) > 0 {
+		t.addRef(6, ipcKeyValues(metadata))
+	}
+	return t, nil
+}
+
+func ipcKeyValues(m map[string]string) fbRefVector {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make(fbRefVector, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, (&fbTable{}).addRef(0, fbString(k)).addRef(1, fbString(m[k])))
+	}
+	return kvs
+}
+
+// ipcBodyEncoder flattens arrays into field nodes, buffer descriptors and
+// the message body, in depth-first pre-order as the format requires.
+type ipcBodyEncoder struct {
+	nodes   []byte
+	buffers []byte
+	body    []byte
+}
+
+func (e *ipcBodyEncoder) addNode(length, nulls int) {
+	e.nodes = binary.LittleEndian.AppendUint64(e.nodes, uint64(length))
+	e.nodes = binary.LittleEndian.AppendUint64(e.nodes, uint64(nulls))
+}
+
+func (e *ipcBodyEncoder) addBuffer(data []byte) {
+	e.buffers = binary.LittleEndian.AppendUint64(e.buffers, uint64(len(e.body)))
+	e.buffers = binary.LittleEndian.AppendUint64(e.buffers, uint64(len(data)))
+	e.body = append(e.body, data...)
+	for len(e.body)%8 != 0 {
+		e.body = append(e.body, 0)
+	}
+}
+
+func (e *ipcBodyEncoder) addValidity(data *ArrayData) {
+	if data.nullBitmap == nil || data.nulls == 0 {
+		e.addBuffer(nil)
+		return
+	}
+	bitmap := make([]byte, (data.length+7)/8)
+	copy(bitmap, data.nullBitmap.Bytes())
+	e.addBuffer(bitmap)
+}
+
+func (e *ipcBodyEncoder) encode(arr Array) error {
+	data := arr.Data()
+	e.addNode(data.length, data.nulls)
+	e.addValidity(data)
+
+	switch a := arr.(type) {
+	case *Int32Array, *Int64Array, *Float16Array, *Float32Array, *Float64Array:
+		width := a.DataType().ByteWidth()
+		e.addBuffer(data.buffers[0].Bytes()[:data.length*width])
+		return nil
+	case *BooleanArray:
+		e.addBuffer(a.values.Bytes())
+		return nil
+	case *BinaryArray:
+		e.addBuffer(a.offsets.Bytes())
+		e.addBuffer(a.values.Bytes())
+		return nil
+	case *StringArray:
+		e.addBuffer(a.offsets.Bytes())
+		e.addBuffer(a.values.Bytes())
+		return nil
+	case *FixedSizeListArray:
+		return e.encode(a.Values())
+	case *ListArray:
+		e.addBuffer(a.offsets.Bytes())
+		return e.encode(a.Values())
+	default:
+		return lerrors.New(lerrors.ErrUnsupportedType).
+			Op("write_ipc").
+			Context("type", arr.DataType().Name()).
+			Build()
+	}
+}
+
+// --- Reading ---
+
+// readIPCMessage reads one encapsulated message. A zero fbRef (pos == 0)
+// signals end of stream.
+func readIPCMessage(r io.Reader) (fbRef, []byte, error) {
+	var word [4]byte
+	if _, err := io.ReadFull(r, word[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fbRef{}, nil, nil
+		}
+		return fbRef{}, nil, lerrors.IO("read_ipc", "", err)
+	}
+	size := binary.LittleEndian.Uint32(word[:])
+	if size == ipcContinuation {
+		if _, err := io.ReadFull(r, word[:]); err != nil {
+			return fbRef{}, nil, lerrors.IO("read_ipc", "", err)
+		}
+		size = binary.LittleEndian.Uint32(word[:])
+	}
+	if size == 0 {
+		return fbRef{}, nil, nil
+	}
+	if size > 1<<30 {
+		return fbRef{}, nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("read_ipc").
+			Context("metadata_size", size).
+			Build()
+	}
+
+	meta := make([]byte, size)
+	if _, err := io.ReadFull(r, meta); err != nil {
+		return fbRef{}, nil, lerrors.IO("read_ipc", "", err)
+	}
+	msg := fbRoot(meta)
+	if msg.pos == 0 {
+		panic(fbError{"message root points at offset 0"})
+	}
+
+	bodyLen := msg.int64(3, 0)
+	if bodyLen < 0 || bodyLen > 1<<40 {
+		return fbRef{}, nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("read_ipc").
+			Context("body_length", bodyLen).
+			Build()
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return fbRef{}, nil, lerrors.IO("read_ipc", "", err)
+	}
+	return msg, body, nil
+}
+
+func ipcReadSchema(t fbRef) (*Schema, error) {
+	elems, n := t.vector(1)
+	fields := make([]Field, 0, n)
+	for i := 0; i < n; i++ {
+		f, err := ipcReadField(t.tableAt(elems, i))
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return NewSchema(fields, ipcReadKeyValues(t, 2)), nil
+}
+
+func ipcReadField(t fbRef) (Field, error) {
+	name := t.string(0)
+	typeTable, _ := t.table(3)
+
+	var dtype DataType
+	switch typeID := t.uint8(2, 0); typeID {
+	case ipcTypeInt:
+		bitWidth, signed := typeTable.int32(0, 0), typeTable.bool(1, false)
+		switch {
+		case bitWidth == 32 && signed:
+			dtype = PrimInt32()
+		case bitWidth == 64 && signed:
+			dtype = PrimInt64()
+		}
+	case ipcTypeFloatingPoint:
+		switch typeTable.int16(0, 0) {
+		case ipcPrecisionHalf:
+			dtype = PrimFloat16()
+		case ipcPrecisionSingle:
+			dtype = PrimFloat32()
+		case ipcPrecisionDouble:
+			dtype = PrimFloat64()
+		}
+	case ipcTypeBinary:
+		dtype = PrimBinary()
+	case ipcTypeUtf8:
+		dtype = PrimString()
+	case ipcTypeBool:
+		dtype = PrimBool()
+	case ipcTypeFixedSizeList, ipcTypeList:
+		elems, n := t.vector(5)
+		if n != 1 {
+			return Field{}, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("read_ipc").
+				Context("field", name).
+				Context("children", n).
+				Build()
+		}
+		child, err := ipcReadField(t.tableAt(elems, 0))
+		if err != nil {
+			return Field{}, err
+		}
+		if typeID == ipcTypeList {
+			dtype = ListOf(child.Type)
+		} else {
+			dtype = FixedSizeListOf(child.Type, int(typeTable.int32(0, 0)))
+		}
+	}
+	if dtype == nil {
+		return Field{}, lerrors.New(lerrors.ErrUnsupportedType).
+			Op("read_ipc").
+			Context("field", name).
+			Context("type_id", t.uint8(2, 0)).
+			Build()
+	}
+	if _, ok := t.table(4); ok {
+		return Field{}, lerrors.New(lerrors.ErrNotSupported).
+			Op("read_ipc").
+			Context("field", name).
+			Context("message", "dictionary-encoded fields are not supported").
+			Build()
+	}
+
+	field := NewField(name, dtype, t.bool(1, false))
+	for k, v := range ipcReadKeyValues(t, 6) {
+		field.Metadata[k] = v
+	}
+	return field, nil
+}
+
+func ipcReadKeyValues(t fbRef, id int) map[string]string {
+	elems, n := t.vector(id)
+	if n == 0 {
+		return nil
+	}
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		kv := t.tableAt(elems, i)
+		m[kv.string(0)] = kv.string(1)
+	}
+	return m
+}
+
+// ipcBodyDecoder walks field nodes and buffers in the same order the
+// encoder produced them.
+type ipcBodyDecoder struct {
+	t          fbRef
+	body       []byte
+	nodes      int
+	numNodes   int
+	buffers    int
+	numBuffers int
+}
+
+func (d *ipcBodyDecoder) nextNode() (length, nulls int, err error) {
+	if d.numNodes == 0 {
+		return 0, 0, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("read_ipc").
+			Context("message", "not enough field nodes").
+			Build()
+	}
+	length = int(int64(d.t.u64(d.nodes)))
+	nulls = int(int64(d.t.u64(d.nodes + 8)))
+	d.nodes += 16
+	d.numNodes--
+	if length < 0 || nulls < 0 || nulls > length {
+		return 0, 0, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("read_ipc").
+			Context("length", length).
+			Context("null_count", nulls).
+			Build()
+	}
+	return length, nulls, nil
+}
+
+// nextBuffer returns a copy of the next body buffer, so typed views over it
+// are always properly aligned.
+func (d *ipcBodyDecoder) nextBuffer() ([]byte, error) {
+	if d.numBuffers == 0 {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("read_ipc").
+			Context("message", "not enough buffers").
+			Build()
+	}
+	offset := int64(d.t.u64(d.buffers))
+	length := int64(d.t.u64(d.buffers + 8))
+	d.buffers += 16
+	d.numBuffers--
+	if offset < 0 || length < 0 || offset+length > int64(len(d.body)) {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("read_ipc").
+			Context("buffer_offset", offset).
+			Context("buffer_length", length).
+			Context("body_length", len(d.body)).
+			Build()
+	}
+	buf := make([]byte, length)
+	copy(buf, d.body[offset:offset+length])
+	return buf, nil
+}
+
+func (d *ipcBodyDecoder) decode(dtype DataType) (Array, error) {
+	length, nulls, err := d.nextNode()
+	if err != nil {
+		return nil, err
+	}
+	validity, err := d.nextBuffer()
+	if err != nil {
+		return nil, err
+	}
+	var bitmap *Bitmap
+	if nulls > 0 {
+		if len(validity) < (length+7)/8 {
+			return nil, lerrors.BufferTooSmall("read_ipc", (length+7)/8, len(validity))
+		}
+		bitmap = NewBitmapFromBytes(validity, length)
+	}
+
+	switch dtype.ID() {
+	case INT32, INT64, FLOAT16, FLOAT32, FLOAT64:
+		values, err := d.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		size := length * dtype.ByteWidth()
+		if len(values) < size {
+			return nil, lerrors.BufferTooSmall("read_ipc", size, len(values))
+		}
+		data := NewArrayData(dtype, length, []*Buffer{NewBufferBytes(values[:size])}, bitmap, nil)
+		switch dtype.ID() {
+		case INT32:
+			return &Int32Array{data: data}, nil
+		case INT64:
+			return &Int64Array{data: data}, nil
+		case FLOAT16:
+			return &Float16Array{data: data}, nil
+		case FLOAT32:
+			return &Float32Array{data: data}, nil
+		default:
+			return &Float64Array{data: data}, nil
+		}
+	case BOOL:
+		values, err := d.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) < (length+7)/8 {
+			return nil, lerrors.BufferTooSmall("read_ipc", (length+7)/8, len(values))
+		}
+		return newBooleanArray(NewBitmapFromBytes(values, length), bitmap), nil
+	case BINARY, STRING:
+		offsets, values, err := d.binaryBuffers(length)
+		if err != nil {
+			return nil, err
+		}
+		if dtype.ID() == STRING {
+			return NewStringArray(offsets, values, bitmap), nil
+		}
+		return NewBinaryArray(offsets, values, bitmap), nil
+	case FIXED_SIZE_LIST:
+		listType := dtype.(*FixedSizeListType)
+		values, err := d.decode(listType.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if values.Len() != length*listType.Size() {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("read_ipc").
+				Context("list_length", length).
+				Context("values_length", values.Len()).
+				Build()
+		}
+		return NewFixedSizeListArray(listType, values, bitmap), nil
+	case LIST:
+		listType := dtype.(*ListType)
+		offsetBytes, err := d.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		if len(offsetBytes) < (length+1)*4 {
+			return nil, lerrors.BufferTooSmall("read_ipc", (length+1)*4, len(offsetBytes))
+		}
+		offsets := NewBufferBytes(offsetBytes[:(length+1)*4]).Int32()
+		values, err := d.decode(listType.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return NewListArray(listType, offsets, values, bitmap), nil
+	default:
+		return nil, lerrors.New(lerrors.ErrUnsupportedType).
+			Op("read_ipc").
+			Context("type", dtype.Name()).
+			Build()
+	}
+}
+
+// binaryBuffers reads the offsets and value bytes of a binary or string
+// array of length values, checking the offsets stay within the values
+func (d *ipcBodyDecoder) binaryBuffers(length int) ([]int32, []byte, error) {
+	offsetBytes, err := d.nextBuffer()
+	if err != nil {
+		return nil, nil, err
+	}
+	if length == 0 && len(offsetBytes) == 0 {
+		// Writers may leave out the single offset of an empty array
+		offsetBytes = make([]byte, 4)
+	}
+	if len(offsetBytes) < (length+1)*4 {
+		return nil, nil, lerrors.BufferTooSmall("read_ipc", (length+1)*4, len(offsetBytes))
+	}
+	offsets := NewBufferBytes(offsetBytes[:(length+1)*4]).Int32()
+	values, err := d.nextBuffer()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < length; i++ {
+		if offsets[i] < 0 || offsets[i] > offsets[i+1] || int(offsets[i+1]) > len(values) {
+			return nil, nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("read_ipc").
+				Context("value", i).
+				Context("start", offsets[i]).
+				Context("end", offsets[i+1]).
+				Context("values_length", len(values)).
+				Build()
+		}
+	}
+	return offsets, values, nil
+}
+
+func ipcReadRecordBatch(schema *Schema, t fbRef, body []byte) (*RecordBatch, error) {
+	if _, ok := t.table(3); ok {
+		return nil, lerrors.New(lerrors.ErrNotSupported).
+			Op("read_ipc").
+			Context("message", "compressed record batch bodies are not supported").
+			Build()
+	}
+
+	d := &ipcBodyDecoder{t: t, body: body}
+	d.nodes, d.numNodes = t.vector(1)
+	d.buffers, d.numBuffers = t.vector(2)
+
+	numRows := int(t.int64(0, 0))
+	columns := make([]Array, schema.NumFields())
+	for i, f := range schema.Fields() {
+		col, err := d.decode(f.Type)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = col
+	}
+	return NewRecordBatch(schema, numRows, columns)
+}
+
+func ipcEmptyBatch(schema *Schema) (*RecordBatch, error) {
+	columns := make([]Array, schema.NumFields())
+	for i, f := range schema.Fields() {
+		columns[i] = NewBuilderForType(f.Type).NewArray()
+	}
+	return NewRecordBatch(schema, 0, columns)
+}
@@ -14,40 +14,72 @@ type RecordBatch struct {
 	columns []Array
 }
 
-// NewRecordBatch creates a new record batch
+// NewRecordBatch creates a new record batch. It fails unless there is one
+// column per schema field, of the field's type, holding exactly numRows
+// values; the error names the offending column.
 func NewRecordBatch(schema *Schema, numRows int, columns []Array) (*RecordBatch, error) {
+	if err := ValidateColumns("new_record_batch", schema, numRows, columns); err != nil {
+		return nil, err
+	}
+
+	return &RecordBatch{
+		schema:  schema,
+		numRows: numRows,
+		columns: columns,
+	}, nil
+}
+
+// ValidateColumns checks that columns fit schema and numRows as
+// NewRecordBatch requires, reporting failures under op. It is exported for
+// writers that receive batches built elsewhere.
+func ValidateColumns(op string, schema *Schema, numRows int, columns []Array) error {
+	if schema == nil {
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op(op).
+			Context("message", "batch has no schema").
+			Build()
+	}
 	if schema.NumFields() != len(columns) {
-		return nil, lerrors.New(lerrors.ErrInvalidArgument).
-			Op("new_record_batch").
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op(op).
 			Context("schema_fields", schema.NumFields()).
 			Context("column_count", len(columns)).
-			Context("message", "schema/column count mismatch").
+			Context("message", fmt.Sprintf("schema has %d fields, batch has %d columns", schema.NumFields(), len(columns))).
 			Build()
 	}
 
 	for i, col := range columns {
-		if col.Len() != numRows {
-			return nil, lerrors.New(lerrors.ErrInvalidArgument).
-				Op("new_record_batch").
+		field := schema.Field(i)
+		mismatch := func(message string) error {
+			return lerrors.New(lerrors.ErrInvalidArgument).
+				Op(op).
 				Context("column_index", i).
-				Context("column_rows", col.Len()).
-				Context("expected_rows", numRows).
-				Context("message", "column row count mismatch").
+				Context("column", field.Name).
+				Context("message", message).
 				Build()
 		}
+		if col == nil {
+			return mismatch(fmt.Sprintf("column %q is nil", field.Name))
+		}
+		if col.Len() != numRows {
+			return mismatch(fmt.Sprintf("column %q has %d rows, batch has %d", field.Name, col.Len(), numRows))
+		}
 
-		field := schema.Field(i)
 		if col.DataType().ID() != field.Type.ID() {
-			return nil, lerrors.TypeMismatch("new_record_batch", field.Name,
+			return lerrors.TypeMismatch(op, field.Name,
 				field.Type.Name(), col.DataType().Name())
 		}
-	}
 
-	return &RecordBatch{
-		schema:  schema,
-		numRows: numRows,
-		columns: columns,
-	}, nil
+		// A list builder that missed an Append leaves a partial last list,
+		// which the list length silently drops
+		if list, ok := col.(*FixedSizeListArray); ok {
+			if want := list.Len() * list.ListSize(); list.Values().Len() != want {
+				return mismatch(fmt.Sprintf("column %q has %d list values, %d rows of %d need %d",
+					field.Name, list.Values().Len(), list.Len(), list.ListSize(), want))
+			}
+		}
+	}
+	return nil
 }
 
 // Schema returns the schema
@@ -145,12 +177,13 @@ func (b *RecordBatchBuilder) NewBatch() (*RecordBatch, error) {
 	// Verify all builders have the same length
 	for i, builder := range b.builders {
 		if builder.Len() != numRows {
+			name := b.schema.Field(i).Name
 			return nil, lerrors.New(lerrors.ErrInvalidArgument).
 				Op("record_batch_builder_new_batch").
 				Context("builder_index", i).
-				Context("builder_rows", builder.Len()).
-				Context("expected_rows", numRows).
-				Context("message", "builder row count mismatch").
+				Context("column", name).
+				Context("message", fmt.Sprintf("column %q has %d rows, column %q has %d",
+					name, builder.Len(), b.schema.Field(0).Name, numRows)).
 				Build()
 		}
 	}
@@ -179,10 +212,18 @@ func NewBuilderForType(dtype DataType) Builder {
 		return NewInt32Builder()
 	case INT64:
 		return NewInt64Builder()
+	case FLOAT16:
+		return NewFloat16Builder()
 	case FLOAT32:
 		return NewFloat32Builder()
 	case FLOAT64:
 		return NewFloat64Builder()
+	case BINARY:
+		return NewBinaryBuilder()
+	case STRING:
+		return NewStringBuilder()
+	case BOOL:
+		return NewBooleanBuilder()
 	case FIXED_SIZE_LIST:
 		listType := dtype.(*FixedSizeListType)
 		return NewFixedSizeListBuilder(listType)
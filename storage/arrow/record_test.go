@@ -1,6 +1,9 @@
 package arrow
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestNewRecordBatch(t *testing.T) {
 	schema := NewSchema([]Field{
@@ -94,6 +97,57 @@ func TestRecordBatchValidation(t *testing.T) {
 	}
 }
 
+func TestRecordBatchValidation_NamesColumn(t *testing.T) {
+	schema := NewSchema([]Field{
+		NewField("id", PrimInt32(), false),
+		NewField("embedding", FixedSizeListOf(PrimFloat32(), 4), false),
+	}, nil)
+	listType := schema.Field(1).Type.(*FixedSizeListType)
+	ids := NewInt32Array([]int32{1, 2}, nil)
+	vectors := NewFixedSizeListArray(listType, NewFloat32Array(make([]float32, 8), nil), nil)
+
+	cases := []struct {
+		name    string
+		columns []Array
+		want    []string
+	}{
+		{"column count", []Array{ids}, []string{"schema has 2 fields, batch has 1 columns"}},
+		{"short column", []Array{NewInt32Array([]int32{1}, nil), vectors}, []string{`column "id"`, "has 1 rows, batch has 2"}},
+		{"nil column", []Array{ids, nil}, []string{`column "embedding" is nil`}},
+		{"partial list", []Array{ids, NewFixedSizeListArray(listType, NewFloat32Array(make([]float32, 11), nil), nil)},
+			[]string{`column "embedding"`, "has 11 list values"}},
+	}
+	for _, tc := range cases {
+		_, err := NewRecordBatch(schema, 2, tc.columns)
+		if err == nil {
+			t.Errorf("%s: expected error", tc.name)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not contain %q", tc.name, err, want)
+			}
+		}
+	}
+
+	if _, err := NewRecordBatch(schema, 2, []Array{ids, vectors}); err != nil {
+		t.Errorf("valid batch rejected: %v", err)
+	}
+
+	// The builder names the column that fell behind
+	builder := NewRecordBatchBuilder(NewSchema([]Field{
+		NewField("a", PrimInt32(), false),
+		NewField("b", PrimInt32(), false),
+	}, nil))
+	builder.Field(0).(*Int32Builder).Append(1)
+	builder.Field(0).(*Int32Builder).Append(2)
+	builder.Field(1).(*Int32Builder).Append(1)
+	_, err := builder.NewBatch()
+	if err == nil || !strings.Contains(err.Error(), `column "b" has 1 rows, column "a" has 2`) {
+		t.Errorf("builder mismatch error %v", err)
+	}
+}
+
 func TestRecordBatchBuilder(t *testing.T) {
 	schema := SchemaForVectors(768)
 	builder := NewRecordBatchBuilder(schema)
@@ -11,6 +11,11 @@ type Field struct {
 	Type     DataType
 	Nullable bool
 	Metadata map[string]string
+
+	// ID identifies the field across schema versions: readers resolve
+	// columns by ID, so a renamed field keeps its data. 0 means unassigned,
+	// see NewSchema.
+	ID int32
 }
 
 // NewField creates a new field
@@ -35,11 +40,28 @@ type Schema struct {
 	metadata map[string]string
 }
 
-// NewSchema creates a new schema
+// NewSchema creates a new schema. Fields without an ID are assigned one
+// above the highest ID in use, in order, so a schema of unassigned fields
+// numbers them from 1. Assigned IDs must be unique.
 func NewSchema(fields []Field, metadata map[string]string) *Schema {
 	if metadata == nil {
 		metadata = make(map[string]string)
 	}
+	var maxID int32
+	unassigned := false
+	for _, field := range fields {
+		maxID = max(maxID, field.ID)
+		unassigned = unassigned || field.ID == 0
+	}
+	if unassigned {
+		fields = append([]Field(nil), fields...)
+		for i := range fields {
+			if fields[i].ID == 0 {
+				maxID++
+				fields[i].ID = maxID
+			}
+		}
+	}
 	return &Schema{
 		fields:   fields,
 		metadata: metadata,
@@ -71,6 +93,16 @@ func (s *Schema) FieldByName(name string) (Field, int, bool) {
 	return Field{}, -1, false
 }
 
+// FieldByID returns the field with the given ID
+func (s *Schema) FieldByID(id int32) (Field, int, bool) {
+	for i, field := range s.fields {
+		if field.ID == id {
+			return field, i, true
+		}
+	}
+	return Field{}, -1, false
+}
+
 // Metadata returns the schema metadata
 func (s *Schema) Metadata() map[string]string {
 	return s.metadata
@@ -85,7 +117,7 @@ func (s *Schema) String() string {
 		if field.Nullable {
 			nullable = ", nullable"
 		}
-		sb.WriteString(fmt.Sprintf("  %d: %s: %s%s\n", i, field.Name, field.Type.Name(), nullable))
+		sb.WriteString(fmt.Sprintf("  %d: %s: %s%s (id %d)\n", i, field.Name, field.Type.Name(), nullable, field.ID))
 	}
 	if len(s.metadata) > 0 {
 		sb.WriteString("  metadata: ")
@@ -95,14 +127,15 @@ func (s *Schema) String() string {
 	return sb.String()
 }
 
-// Equal checks if two schemas are equal
+// Equal checks if two schemas have the same fields, IDs included, in the
+// same order
 func (s *Schema) Equal(other *Schema) bool {
 	if s.NumFields() != other.NumFields() {
 		return false
 	}
 	for i := 0; i < s.NumFields(); i++ {
 		f1, f2 := s.fields[i], other.fields[i]
-		if f1.Name != f2.Name || f1.Type.ID() != f2.Type.ID() || f1.Nullable != f2.Nullable {
+		if f1.Name != f2.Name || f1.Type.ID() != f2.Type.ID() || f1.Nullable != f2.Nullable || f1.ID != f2.ID {
 			return false
 		}
 	}
@@ -153,6 +153,43 @@ func TestSchemaFieldByName(t *testing.T) {
 	}
 }
 
+func TestSchemaFieldIDs(t *testing.T) {
+	fields := []Field{
+		NewField("id", PrimInt32(), false),
+		{ID: 7, Name: "vector", Type: VectorType(4)},
+		NewField("level", PrimInt32(), false),
+	}
+	schema := NewSchema(fields, nil)
+
+	// Unassigned IDs follow the highest assigned one, in order
+	for i, want := range []int32{8, 7, 9} {
+		if got := schema.Field(i).ID; got != want {
+			t.Errorf("field %d: expected ID %d, got %d", i, want, got)
+		}
+	}
+	if fields[0].ID != 0 {
+		t.Error("NewSchema modified the caller's fields")
+	}
+
+	field, idx, found := schema.FieldByID(7)
+	if !found || idx != 1 || field.Name != "vector" {
+		t.Errorf("FieldByID(7) = %v, %d, %t", field, idx, found)
+	}
+	if _, _, found := schema.FieldByID(1); found {
+		t.Error("expected not to find field ID 1")
+	}
+
+	// Schemas differing only in IDs are not equal
+	renumbered := NewSchema([]Field{
+		NewField("id", PrimInt32(), false),
+		NewField("vector", VectorType(4), false),
+		NewField("level", PrimInt32(), false),
+	}, nil)
+	if schema.Equal(renumbered) {
+		t.Error("expected schemas to be different (field IDs)")
+	}
+}
+
 func TestSchemaEqual(t *testing.T) {
 	fields1 := []Field{
 		NewField("id", PrimInt32(), false),
@@ -0,0 +1,139 @@
+package arrow
+
+import "fmt"
+
+// Slices are zero-copy views of a row range: they share the buffers of the
+// array they were taken from, with the value buffer and null bitmap narrowed
+// to the window, so Len, NullN, Value and IsValid only see the sliced rows.
+// Writes through either side, such as Bitmap.Set, are seen by both.
+
+// checkSlice panics unless [offset, offset+length) lies within n elements
+func checkSlice(offset, length, n int) {
+	if offset < 0 || length < 0 || offset+length > n {
+		panic(fmt.Sprintf("slice [%d:%d] out of range for length %d", offset, offset+length, n))
+	}
+}
+
+// sliceData returns the window of a fixed-width array's data whose elements
+// are width bytes wide
+func (d *ArrayData) sliceData(offset, length, width int) *ArrayData {
+	checkSlice(offset, length, d.length)
+	buf := d.buffers[0].Bytes()
+	buffers := []*Buffer{NewBufferBytes(buf[offset*width : (offset+length)*width])}
+	return NewArrayData(d.dtype, length, buffers, d.sliceNulls(offset, length), nil)
+}
+
+// sliceNulls returns the window of the null bitmap, nil if there is none
+func (d *ArrayData) sliceNulls(offset, length int) *Bitmap {
+	if d.nullBitmap == nil {
+		return nil
+	}
+	return d.nullBitmap.Slice(offset, length)
+}
+
+// Slice returns a view of length values starting at offset
+func (a *Int32Array) Slice(offset, length int) *Int32Array {
+	return &Int32Array{data: a.data.sliceData(offset, length, 4)}
+}
+
+// Slice returns a view of length values starting at offset
+func (a *Int64Array) Slice(offset, length int) *Int64Array {
+	return &Int64Array{data: a.data.sliceData(offset, length, 8)}
+}
+
+// Slice returns a view of length values starting at offset
+func (a *Float16Array) Slice(offset, length int) *Float16Array {
+	return &Float16Array{data: a.data.sliceData(offset, length, 2)}
+}
+
+// Slice returns a view of length values starting at offset
+func (a *Float32Array) Slice(offset, length int) *Float32Array {
+	return &Float32Array{data: a.data.sliceData(offset, length, 4)}
+}
+
+// Slice returns a view of length values starting at offset
+func (a *Float64Array) Slice(offset, length int) *Float64Array {
+	return &Float64Array{data: a.data.sliceData(offset, length, 8)}
+}
+
+// Slice returns a view of length lists starting at offset. The values array
+// is narrowed to the lists' elements.
+func (a *FixedSizeListArray) Slice(offset, length int) *FixedSizeListArray {
+	checkSlice(offset, length, a.Len())
+	size := a.ListSize()
+	values := SliceArray(a.values, offset*size, length*size)
+	data := NewArrayData(a.data.dtype, length, nil, a.data.sliceNulls(offset, length), []*ArrayData{values.Data()})
+	return &FixedSizeListArray{data: data, values: values}
+}
+
+// Slice returns a view of length lists starting at offset. As in Arrow, the
+// values array is shared whole and Offsets starts at the first list's
+// offset rather than 0.
+func (a *ListArray) Slice(offset, length int) *ListArray {
+	checkSlice(offset, length, a.Len())
+	offsets := NewBufferBytes(a.offsets.Bytes()[offset*4 : (offset+length+1)*4])
+	data := NewArrayData(a.data.dtype, length, []*Buffer{offsets}, a.data.sliceNulls(offset, length), a.data.children)
+	return &ListArray{data: data, offsets: offsets, values: a.values}
+}
+
+// Slice returns a view of length values starting at offset. As with
+// ListArray, the value bytes are shared whole and Offsets starts at the
+// first value's offset.
+func (a *BinaryArray) Slice(offset, length int) *BinaryArray {
+	checkSlice(offset, length, a.Len())
+	offsets := NewBufferBytes(a.offsets.Bytes()[offset*4 : (offset+length+1)*4])
+	data := NewArrayData(a.data.dtype, length, []*Buffer{offsets, a.values}, a.data.sliceNulls(offset, length), nil)
+	return &BinaryArray{data: data, offsets: offsets, values: a.values}
+}
+
+// Slice returns a view of length strings starting at offset
+func (a *StringArray) Slice(offset, length int) *StringArray {
+	return &StringArray{BinaryArray: *a.BinaryArray.Slice(offset, length)}
+}
+
+// Slice returns a view of length values starting at offset
+func (a *BooleanArray) Slice(offset, length int) *BooleanArray {
+	checkSlice(offset, length, a.Len())
+	return newBooleanArray(a.values.Slice(offset, length), a.data.sliceNulls(offset, length))
+}
+
+// SliceArray returns a view of length elements of arr starting at offset,
+// for any array type of this package. It panics if the window is out of
+// range or the type is unsupported.
+func SliceArray(arr Array, offset, length int) Array {
+	switch a := arr.(type) {
+	case *Int32Array:
+		return a.Slice(offset, length)
+	case *Int64Array:
+		return a.Slice(offset, length)
+	case *Float16Array:
+		return a.Slice(offset, length)
+	case *Float32Array:
+		return a.Slice(offset, length)
+	case *Float64Array:
+		return a.Slice(offset, length)
+	case *FixedSizeListArray:
+		return a.Slice(offset, length)
+	case *ListArray:
+		return a.Slice(offset, length)
+	case *BinaryArray:
+		return a.Slice(offset, length)
+	case *StringArray:
+		return a.Slice(offset, length)
+	case *BooleanArray:
+		return a.Slice(offset, length)
+	default:
+		panic(fmt.Sprintf("unsupported type: %s", arr.DataType().Name()))
+	}
+}
+
+// Slice returns a view of length rows starting at offset, slicing every
+// column without copying. It panics if the window is out of range.
+func (r *RecordBatch) Slice(offset, length int) *RecordBatch {
+	checkSlice(offset, length, r.numRows)
+	columns := make([]Array, len(r.columns))
+	for i, col := range r.columns {
+		columns[i] = SliceArray(col, offset, length)
+	}
+	return &RecordBatch{schema: r.schema, numRows: length, columns: columns}
+}
@@ -0,0 +1,363 @@
+package arrow
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// randomArrays returns one array of every type with n rows, each row null
+// with probability nullRate
+func randomArrays(rng *rand.Rand, n int, nullRate float64) []Array {
+	null := func() bool { return rng.Float64() < nullRate }
+
+	i32, i64 := NewInt32Builder(), NewInt64Builder()
+	f32, f64 := NewFloat32Builder(), NewFloat64Builder()
+	vectors := NewFixedSizeListBuilder(VectorType(3).(*FixedSizeListType))
+	lists := NewListBuilder(ListOf(PrimInt32()).(*ListType), NewInt32Builder())
+	strs := NewStringBuilder()
+	bools := NewBooleanBuilder()
+	for i := 0; i < n; i++ {
+		if null() {
+			i32.AppendNull()
+		} else {
+			i32.Append(rng.Int31())
+		}
+		if null() {
+			i64.AppendNull()
+		} else {
+			i64.Append(rng.Int63())
+		}
+		if null() {
+			f32.AppendNull()
+		} else {
+			f32.Append(rng.Float32())
+		}
+		if null() {
+			f64.AppendNull()
+		} else {
+			f64.Append(rng.Float64())
+		}
+		if null() {
+			vectors.AppendNull()
+		} else {
+			vectors.AppendValues([]float32{rng.Float32(), rng.Float32(), rng.Float32()})
+		}
+		if null() {
+			lists.AppendNull()
+		} else {
+			lists.Append(true)
+			for j := rng.Intn(4); j > 0; j-- {
+				lists.ValueBuilder().(*Int32Builder).Append(rng.Int31())
+			}
+			lists.UpdateOffset()
+		}
+		if null() {
+			strs.AppendNull()
+		} else {
+			strs.Append(strings.Repeat("é", rng.Intn(3)))
+		}
+		if null() {
+			bools.AppendNull()
+		} else {
+			bools.Append(rng.Intn(2) == 0)
+		}
+	}
+	return []Array{i32.NewArray(), i64.NewArray(), f32.NewArray(), f64.NewArray(), vectors.NewArray(), lists.NewArray(), strs.NewArray(), bools.NewArray()}
+}
+
+// materialize copies rows [offset, offset+length) of arr into a new array
+// built row by row
+func materialize(arr Array, offset, length int) Array {
+	switch a := arr.(type) {
+	case *Int32Array:
+		b := NewInt32Builder()
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+			} else {
+				b.Append(a.Value(i))
+			}
+		}
+		return b.NewArray()
+	case *Int64Array:
+		b := NewInt64Builder()
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+			} else {
+				b.Append(a.Value(i))
+			}
+		}
+		return b.NewArray()
+	case *Float32Array:
+		b := NewFloat32Builder()
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+			} else {
+				b.Append(a.Value(i))
+			}
+		}
+		return b.NewArray()
+	case *Float64Array:
+		b := NewFloat64Builder()
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+			} else {
+				b.Append(a.Value(i))
+			}
+		}
+		return b.NewArray()
+	case *FixedSizeListArray:
+		b := NewFixedSizeListBuilder(a.DataType().(*FixedSizeListType))
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+			} else {
+				b.AppendValues(a.ValueSlice(i).([]float32))
+			}
+		}
+		return b.NewArray()
+	case *ListArray:
+		b := NewListBuilder(a.DataType().(*ListType), NewInt32Builder())
+		values := a.Values().(*Int32Array)
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+				continue
+			}
+			b.Append(true)
+			start, end := a.ValueOffsets(i)
+			for j := start; j < end; j++ {
+				b.ValueBuilder().(*Int32Builder).Append(values.Value(int(j)))
+			}
+			b.UpdateOffset()
+		}
+		return b.NewArray()
+	case *StringArray:
+		b := NewStringBuilder()
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+			} else {
+				b.Append(a.Value(i))
+			}
+		}
+		return b.NewArray()
+	case *BooleanArray:
+		b := NewBooleanBuilder()
+		for i := offset; i < offset+length; i++ {
+			if a.IsNull(i) {
+				b.AppendNull()
+			} else {
+				b.Append(a.Value(i))
+			}
+		}
+		return b.NewArray()
+	}
+	panic("unsupported type " + arr.DataType().Name())
+}
+
+// assertSameArray fails unless got and want hold the same rows
+func assertSameArray(t *testing.T, name string, got, want Array) {
+	t.Helper()
+	if got.DataType().ID() != want.DataType().ID() || got.Len() != want.Len() || got.NullN() != want.NullN() {
+		t.Fatalf("%s: got %s len %d nulls %d, want %s len %d nulls %d", name,
+			got.DataType().Name(), got.Len(), got.NullN(), want.DataType().Name(), want.Len(), want.NullN())
+	}
+	for i := 0; i < want.Len(); i++ {
+		if got.IsValid(i) != want.IsValid(i) || got.IsNull(i) != want.IsNull(i) {
+			t.Fatalf("%s: row %d valid = %v, want %v", name, i, got.IsValid(i), want.IsValid(i))
+		}
+		if want.IsNull(i) {
+			continue
+		}
+		same := true
+		switch w := want.(type) {
+		case *Int32Array:
+			same = got.(*Int32Array).Value(i) == w.Value(i)
+		case *Int64Array:
+			same = got.(*Int64Array).Value(i) == w.Value(i)
+		case *Float32Array:
+			same = got.(*Float32Array).Value(i) == w.Value(i)
+		case *Float64Array:
+			same = got.(*Float64Array).Value(i) == w.Value(i)
+		case *FixedSizeListArray:
+			g, v := got.(*FixedSizeListArray).ValueSlice(i).([]float32), w.ValueSlice(i).([]float32)
+			for j := range v {
+				same = same && g[j] == v[j]
+			}
+		case *ListArray:
+			g := got.(*ListArray)
+			gs, ge := g.ValueOffsets(i)
+			ws, we := w.ValueOffsets(i)
+			same = ge-gs == we-ws
+			for j := int32(0); same && j < we-ws; j++ {
+				same = g.Values().(*Int32Array).Value(int(gs+j)) == w.Values().(*Int32Array).Value(int(ws+j))
+			}
+		case *StringArray:
+			same = got.(*StringArray).Value(i) == w.Value(i)
+		case *BinaryArray:
+			same = bytes.Equal(got.(*BinaryArray).Value(i), w.Value(i))
+		case *Float16Array:
+			same = got.(*Float16Array).Value(i) == w.Value(i)
+		case *BooleanArray:
+			same = got.(*BooleanArray).Value(i) == w.Value(i)
+		}
+		if !same {
+			t.Fatalf("%s: row %d differs", name, i)
+		}
+	}
+}
+
+// TestSliceMatchesCopy compares slices, and slices of slices, at random
+// windows against materialized copies of the same rows
+func TestSliceMatchesCopy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, nullRate := range []float64{0, 0.3, 1} {
+		for round := 0; round < 40; round++ {
+			n := rng.Intn(200)
+			for _, arr := range randomArrays(rng, n, nullRate) {
+				name := arr.DataType().Name()
+				offset := rng.Intn(n + 1)
+				length := rng.Intn(n - offset + 1)
+				view := SliceArray(arr, offset, length)
+				assertSameArray(t, name, view, materialize(arr, offset, length))
+
+				inner := rng.Intn(length + 1)
+				innerLen := rng.Intn(length - inner + 1)
+				assertSameArray(t, name+" of slice", SliceArray(view, inner, innerLen),
+					materialize(arr, offset+inner, innerLen))
+			}
+		}
+	}
+}
+
+// TestSliceSharesBuffers checks that views do not copy values or bitmaps
+func TestSliceSharesBuffers(t *testing.T) {
+	nulls := NewBitmapAllSet(20)
+	nulls.Clear(11)
+	arr := NewInt32Array([]int32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, nulls)
+	view := arr.Slice(5, 10)
+
+	if &view.Values()[0] != &arr.Values()[5] {
+		t.Error("slice copied its values")
+	}
+	if view.NullN() != 1 || !view.IsNull(6) {
+		t.Errorf("slice nulls = %d, IsNull(6) = %v", view.NullN(), view.IsNull(6))
+	}
+	arr.Data().NullBitmap().Clear(7)
+	if !view.IsNull(2) || view.NullN() != 1 {
+		t.Error("slice does not see its parent's bitmap")
+	}
+
+	vectors := NewFixedSizeListArray(VectorType(2).(*FixedSizeListType),
+		NewFloat32Array([]float32{0, 1, 2, 3, 4, 5, 6, 7}, nil), nil)
+	vview := vectors.Slice(1, 2)
+	if vview.Len() != 2 || &vview.ValueSlice(0).([]float32)[0] != &vectors.ValueSlice(1).([]float32)[0] {
+		t.Error("vector slice copied its values")
+	}
+}
+
+func TestBitmapSlice(t *testing.T) {
+	bm := NewBitmap(40)
+	for i := 0; i < 40; i += 3 {
+		bm.Set(i)
+	}
+
+	for offset := 0; offset <= 40; offset++ {
+		for length := 0; offset+length <= 40; length++ {
+			view := bm.Slice(offset, length)
+			want := 0
+			for i := 0; i < length; i++ {
+				if view.IsSet(i) != bm.IsSet(offset+i) {
+					t.Fatalf("Slice(%d, %d): bit %d differs", offset, length, i)
+				}
+				if bm.IsSet(offset + i) {
+					want++
+				}
+			}
+			if got := view.CountSet(); got != want {
+				t.Fatalf("Slice(%d, %d): CountSet = %d, want %d", offset, length, got, want)
+			}
+
+			// Bytes starts at bit 0 and leaves the padding clear
+			out := view.Bytes()
+			for i := 0; i < len(out)*8; i++ {
+				set := out[i/8]&(1<<(i%8)) != 0
+				if (i < length && set != view.IsSet(i)) || (i >= length && set && offset%8 != 0) {
+					t.Fatalf("Slice(%d, %d): Bytes bit %d = %v", offset, length, i, set)
+				}
+			}
+		}
+	}
+
+	// Writes through a view stay within its window
+	view := bm.Slice(5, 6)
+	view.SetAll()
+	view.ClearAll()
+	for i := 0; i < 40; i++ {
+		want := i%3 == 0 && (i < 5 || i >= 11)
+		if bm.IsSet(i) != want {
+			t.Fatalf("bit %d = %v after SetAll and ClearAll on bits 5-10", i, bm.IsSet(i))
+		}
+	}
+
+	// A grown view gets its own buffer
+	view = bm.Slice(3, 4)
+	view.Resize(12)
+	view.Set(10)
+	if !view.IsSet(0) || view.IsSet(4) || bm.IsSet(13) {
+		t.Error("Resize of a view did not copy it")
+	}
+}
+
+func TestRecordBatchSlice(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	// The last, string, column is left out: IPC has no string support
+	columns := randomArrays(rng, 100, 0.2)
+	columns = columns[:len(columns)-1]
+	fields := make([]Field, len(columns))
+	for i, col := range columns {
+		fields[i] = NewField(col.DataType().Name(), col.DataType(), true)
+	}
+	batch, err := NewRecordBatch(NewSchema(fields, nil), 100, columns)
+	if err != nil {
+		t.Fatalf("NewRecordBatch failed: %v", err)
+	}
+
+	view := batch.Slice(13, 50)
+	if view.NumRows() != 50 || view.NumCols() != batch.NumCols() || view.Schema() != batch.Schema() {
+		t.Fatalf("Slice = %s", view)
+	}
+	for i, col := range columns {
+		assertSameArray(t, fields[i].Name, view.Column(i), materialize(col, 13, 50))
+	}
+
+	// Views serialize like copies of their rows
+	var buf bytes.Buffer
+	if err := WriteIPC(&buf, view); err != nil {
+		t.Fatalf("WriteIPC failed: %v", err)
+	}
+	read, err := ReadIPC(&buf)
+	if err != nil {
+		t.Fatalf("ReadIPC failed: %v", err)
+	}
+	for i, col := range columns {
+		assertSameArray(t, fields[i].Name+" via IPC", read.Column(i), materialize(col, 13, 50))
+	}
+
+	for _, window := range [][2]int{{-1, 1}, {0, 101}, {99, 2}, {5, -1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Slice(%d, %d) did not panic", window[0], window[1])
+				}
+			}()
+			batch.Slice(window[0], window[1])
+		}()
+	}
+}
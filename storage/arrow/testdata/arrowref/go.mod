@@ -0,0 +1,21 @@
+module github.com/wzqhbustb/vego/storage/arrow/testdata/arrowref
+
+go 1.23
+
+require github.com/apache/arrow/go/arrow v0.0.0-20211112161151-bc219186db40
+
+require (
+	github.com/google/flatbuffers v2.0.0+incompatible // indirect
+	github.com/klauspost/compress v1.13.1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.8 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+)
+
+// The fixtures are uncompressed, so lz4 is stubbed out and the remaining
+// dependencies are pinned to versions that build offline
+replace (
+	github.com/google/flatbuffers => github.com/google/flatbuffers v1.12.1
+	github.com/klauspost/compress => github.com/klauspost/compress v1.18.0
+	github.com/pierrec/lz4/v4 => ./lz4stub
+	golang.org/x/xerrors => golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da
+)
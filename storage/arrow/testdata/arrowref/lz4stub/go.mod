@@ -0,0 +1,3 @@
+module github.com/pierrec/lz4/v4
+
+go 1.15
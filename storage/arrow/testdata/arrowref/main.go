@@ -0,0 +1,154 @@
+// Command arrowref checks the Arrow IPC stream code of package arrow against
+// Apache Arrow's Go implementation, a reference implementation independent
+// of it. Run from this directory:
+//
+//	go run . gen    writes ../ref_basic.arrows and ../ref_empty.arrows
+//	go run . check  reads ../vego_basic.arrows and compares it to the batch
+//
+// Both use the batch of referenceBatch in ipc_test.go; keep them in sync.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/float16"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: arrowref gen|check")
+	}
+	var err error
+	switch os.Args[1] {
+	case "gen":
+		err = gen()
+	case "check":
+		err = check()
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func schema() *arrow.Schema {
+	metadata := arrow.NewMetadata([]string{"purpose"}, []string{"reference_fixture"})
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "ts", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "score", Type: arrow.PrimitiveTypes.Float32, Nullable: true},
+		{Name: "half", Type: arrow.FixedWidthTypes.Float16},
+		{Name: "weight", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "vector", Type: arrow.FixedSizeListOf(3, arrow.PrimitiveTypes.Float32)},
+		{Name: "tags", Type: arrow.ListOf(arrow.PrimitiveTypes.Int32)},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "data", Type: arrow.BinaryTypes.Binary},
+		{Name: "flag", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+	}, &metadata)
+}
+
+// record builds the fixture batch; row 2 is null in every nullable column
+func record() array.Record {
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), schema())
+	defer b.Release()
+
+	valid := []bool{true, true, false, true}
+	b.Field(0).(*array.Int32Builder).AppendValues([]int32{1, 2, 3, 4}, nil)
+	b.Field(1).(*array.Int64Builder).AppendValues([]int64{10, 20, 30, 1 << 40}, nil)
+	b.Field(2).(*array.Float32Builder).AppendValues([]float32{0.5, 1.5, 0, 3.5}, valid)
+	for _, f := range []float32{0.5, 1, -2, 65504} {
+		b.Field(3).(*array.Float16Builder).Append(float16.New(f))
+	}
+	b.Field(4).(*array.Float64Builder).AppendValues([]float64{0.25, 0.5, 0.75, 1}, nil)
+
+	vectors := b.Field(5).(*array.FixedSizeListBuilder)
+	for i := 0; i < 4; i++ {
+		vectors.Append(true)
+		vectors.ValueBuilder().(*array.Float32Builder).AppendValues(
+			[]float32{float32(3*i + 1), float32(3*i + 2), float32(3*i + 3)}, nil)
+	}
+
+	tags := b.Field(6).(*array.ListBuilder)
+	for _, row := range [][]int32{{1}, {}, {2, 3}, {4, 5, 6}} {
+		tags.Append(true)
+		tags.ValueBuilder().(*array.Int32Builder).AppendValues(row, nil)
+	}
+
+	b.Field(7).(*array.StringBuilder).AppendValues([]string{"alpha", "", "", "δelta"}, valid)
+	b.Field(8).(*array.BinaryBuilder).AppendValues([][]byte{{0, 1}, {}, []byte("xyz"), {0xFF}}, nil)
+	b.Field(9).(*array.BooleanBuilder).AppendValues([]bool{true, false, false, true}, valid)
+	return b.NewRecord()
+}
+
+func write(name string, records ...array.Record) error {
+	f, err := os.Create(filepath.Join("..", name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := ipc.NewWriter(f, ipc.WithSchema(schema()))
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func gen() error {
+	rec := record()
+	defer rec.Release()
+	if err := write("ref_basic.arrows", rec); err != nil {
+		return err
+	}
+	return write("ref_empty.arrows")
+}
+
+func check() error {
+	f, err := os.Open(filepath.Join("..", "vego_basic.arrows"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := ipc.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+
+	want := record()
+	defer want.Release()
+	if !r.Schema().Equal(want.Schema()) {
+		return fmt.Errorf("schema differs:\n%v\nwant:\n%v", r.Schema(), want.Schema())
+	}
+	rows := 0
+	for r.Next() {
+		got := r.Record()
+		rows += int(got.NumRows())
+		for i, col := range got.Columns() {
+			if !array.ArrayEqual(col, want.Column(i)) {
+				return fmt.Errorf("column %s differs: %v, want %v", got.ColumnName(i), col, want.Column(i))
+			}
+		}
+		fmt.Println(got)
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if rows != int(want.NumRows()) {
+		return fmt.Errorf("read %d rows, want %d", rows, want.NumRows())
+	}
+	fmt.Println("ok")
+	return nil
+}
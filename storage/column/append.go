@@ -0,0 +1,129 @@
+package column
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+// OpenWriterAppend opens the Lance file filename to add rows to it with
+// WriteRecordBatch. schema must match the file's fields, in order, with
+// the same names, types, nullability and IDs.
+//
+// The file is left untouched until Close: its pages are copied to a
+// temporary file next to it, the new pages are written after them, and
+// Close writes the footer with the old and new page indexes and then
+// replaces filename. A crash before then leaves the old file readable; a
+// writer that is never closed leaves the temporary file behind. Files with
+// a row index are rejected, since it would not cover the new rows.
+func OpenWriterAppend(filename string, schema *arrow.Schema, factory *encoding.EncoderFactory) (*Writer, error) {
+	reader, err := NewReader(filename)
+	if err != nil {
+		return nil, lerrors.New(lerrors.ErrIO).
+			Op("open_writer_append").
+			Path(filename).
+			Wrap(err).
+			Build()
+	}
+	defer reader.Close()
+
+	if err := checkAppendTarget(reader, schema, filename); err != nil {
+		return nil, err
+	}
+
+	if factory == nil {
+		factory = encoding.NewEncoderFactory(3)
+	}
+
+	// Files written before column summaries get theirs from the page
+	// headers, so the new footer covers the old pages too
+	summaries, err := reader.columnSummaries()
+	if err != nil {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("open_writer_append").
+			Path(filename).
+			Wrap(err).
+			Build()
+	}
+
+	// The old footer is at the end of the file, right after the pages
+	info, err := reader.file.Stat()
+	if err != nil {
+		return nil, lerrors.IO("open_writer_append", filename, err)
+	}
+	pagesEnd := info.Size() - format.FooterSize
+
+	tmp := filename + ".append.tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, lerrors.IO("open_writer_append", tmp, err)
+	}
+	if _, err := io.Copy(file, io.NewSectionReader(reader.file, 0, pagesEnd)); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return nil, lerrors.IO("open_writer_append", tmp, err)
+	}
+
+	return &Writer{
+		file:       file,
+		header:     reader.header,
+		footer:     reader.footer,
+		pageWriter: NewPageWriter(factory),
+		headerSize: HeaderReservedSize,
+		currentPos: pagesEnd,
+		factory:    factory,
+		target:     filename,
+		summaries:  summaries,
+	}, nil
+}
+
+// checkAppendTarget checks that reader, opened from path, can take rows of
+// schema
+func checkAppendTarget(reader *Reader, schema *arrow.Schema, path string) error {
+	if reader.footer.HasRowIndex() {
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op("open_writer_append").
+			Path(path).
+			Context("message", "files with a row index cannot be appended to").
+			Build()
+	}
+
+	stored := reader.header.Schema
+	if schema.NumFields() != stored.NumFields() {
+		return lerrors.SchemaMismatch(path, "num_fields",
+			fmt.Sprint(stored.NumFields()), fmt.Sprint(schema.NumFields()))
+	}
+	for i := 0; i < stored.NumFields(); i++ {
+		if f, g := stored.Field(i), schema.Field(i); !mergeFieldEqual(f, g) {
+			return lerrors.SchemaMismatch(path, fmt.Sprintf("field %d", i),
+				fieldString(f), fieldString(g))
+		}
+	}
+	return nil
+}
+
+// commitAppend makes the temporary file of OpenWriterAppend durable and
+// moves it over the file appended to
+func (w *Writer) commitAppend() error {
+	tmp := w.file.Name()
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		os.Remove(tmp)
+		return lerrors.IO("sync_append", tmp, err)
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(tmp)
+		return lerrors.IO("close_file", tmp, err)
+	}
+	if err := lanceio.ReplaceFile(tmp, w.target); err != nil {
+		os.Remove(tmp)
+		return lerrors.IO("replace_file", w.target, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package column
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+// checkAppendTestFile checks that filename holds rows [0, numRows) as
+// written by writeMergeBatches
+func checkAppendTestFile(t *testing.T, filename string, numRows int) {
+	t.Helper()
+
+	batch := readMergeTestFile(t, filename)
+	if batch.NumRows() != numRows {
+		t.Fatalf("file has %d rows, want %d", batch.NumRows(), numRows)
+	}
+	ids := batch.Column(0).(*arrow.Int32Array)
+	scores := batch.Column(1).(*arrow.Float64Array)
+	vectors := batch.Column(2).(*arrow.FixedSizeListArray)
+	values := vectors.Values().(*arrow.Float32Array)
+	for row := 0; row < numRows; row++ {
+		if ids.Value(row) != int32(row) {
+			t.Fatalf("id[%d] = %d", row, ids.Value(row))
+		}
+		if row%7 == 0 {
+			if !scores.IsNull(row) {
+				t.Fatalf("score[%d] should be null", row)
+			}
+		} else if scores.Value(row) != float64(row)*0.25 {
+			t.Fatalf("score[%d] = %v", row, scores.Value(row))
+		}
+		for d := 0; d < mergeTestDim; d++ {
+			if v := values.Value(row*mergeTestDim + d); v != float32(row*mergeTestDim+d) {
+				t.Fatalf("vector[%d][%d] = %v", row, d, v)
+			}
+		}
+	}
+}
+
+func TestOpenWriterAppend(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "append.lance")
+	schema := mergeTestSchema(true, mergeTestDim)
+	rows := writeMergeSource(t, filename, schema, 0, []int{100})
+
+	for _, batches := range [][]int{{50, 7}, {300}} {
+		writer, err := OpenWriterAppend(filename, schema, nil)
+		if err != nil {
+			t.Fatalf("OpenWriterAppend failed: %v", err)
+		}
+		rows += writeMergeBatches(t, writer, schema, rows, batches)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		checkAppendTestFile(t, filename, rows)
+	}
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	if n := reader.NumBatches(); n != 4 {
+		t.Errorf("Expected the 4 written batches, got %d", n)
+	}
+	if report, err := reader.ValidatePages(context.Background()); err != nil || !report.OK() {
+		t.Errorf("ValidatePages: %v %+v", err, report)
+	}
+	if _, err := os.Stat(filename + ".append.tmp"); !os.IsNotExist(err) {
+		t.Errorf("Temporary file left behind: %v", err)
+	}
+}
+
+func TestOpenWriterAppend_Unclosed(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "append.lance")
+	schema := mergeTestSchema(true, mergeTestDim)
+	rows := writeMergeSource(t, filename, schema, 0, []int{100})
+
+	// A writer that dies before Close leaves the file as it was
+	writer, err := OpenWriterAppend(filename, schema, nil)
+	if err != nil {
+		t.Fatalf("OpenWriterAppend failed: %v", err)
+	}
+	writeMergeBatches(t, writer, schema, rows, []int{50})
+	checkAppendTestFile(t, filename, rows)
+	writer.file.Close()
+}
+
+func TestOpenWriterAppend_SchemaMismatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "append.lance")
+	writeMergeSource(t, filename, mergeTestSchema(true, mergeTestDim), 0, []int{10})
+
+	for name, schema := range map[string]*arrow.Schema{
+		"nullability": mergeTestSchema(false, mergeTestDim),
+		"type":        mergeTestSchema(true, mergeTestDim+1),
+		"fields": arrow.NewSchema([]arrow.Field{
+			{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
+		}, nil),
+	} {
+		if _, err := OpenWriterAppend(filename, schema, nil); !lerrors.Is(err, lerrors.ErrSchemaMismatch) {
+			t.Errorf("%s: expected ErrSchemaMismatch, got %v", name, err)
+		}
+	}
+	checkAppendTestFile(t, filename, 10)
+}
@@ -107,6 +107,8 @@ func estimateBytesPerValue(dt arrow.DataType) int32 {
 		return 4
 	case *arrow.Int64Type:
 		return 8
+	case *arrow.Float16Type:
+		return 2
 	case *arrow.Float32Type:
 		return 4
 	case *arrow.Float64Type:
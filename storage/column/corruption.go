@@ -0,0 +1,167 @@
+package column
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/format"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+// ReaderOption configures how a Reader treats damaged pages and schedules
+// and decodes its reads
+type ReaderOption struct {
+	// SkipCorruptPages replaces a page that fails to read or decode with an
+	// all-null segment of the same length instead of failing the whole read.
+	// Rows stay aligned across columns, and the damaged pages are listed in
+	// the Reader's CorruptionReport. Default is strict: any bad page fails
+	// ReadRecordBatch.
+	SkipCorruptPages bool
+
+	// Priority is the AsyncIO priority of the Reader's page reads, unless a
+	// read overrides it with ReadRecordBatchWithPriority. The default is
+	// interactive; scans such as compaction should use
+	// lanceio.PriorityBackground. Synchronous readers ignore it.
+	Priority lanceio.Priority
+
+	// DecodeWorkers is how many goroutines decode the pages of a column
+	// concurrently when reading synchronously (0 or 1 = one at a time)
+	DecodeWorkers int
+
+	// SkipChecksum decodes pages without verifying their CRC32, trading
+	// corruption detection for read speed. The footer is always verified.
+	SkipChecksum bool
+
+	// CoalesceGap merges the AsyncIO reads of pages less than this many
+	// bytes apart into one read, gap included, so that runs of small pages
+	// cost one request. 0 uses DefaultCoalesceGap; a negative gap reads
+	// every page by itself. Synchronous readers ignore it.
+	CoalesceGap int64
+
+	// Timeout bounds each read whose context has no deadline, including
+	// the reads without a context. 0 uses DefaultReadTimeout; a read past
+	// it fails with context.DeadlineExceeded.
+	Timeout time.Duration
+
+	// Schema, if set, is the schema the Reader presents instead of the
+	// file's, resolved by field ID as in ReadRecordBatchWithSchema: every
+	// read returns its fields, nullable fields the file lacks read as all
+	// null and names are those of Schema. A file it is incompatible with
+	// fails to open with an ErrSchemaMismatch listing the offending fields.
+	Schema *arrow.Schema
+}
+
+// DefaultCoalesceGap is the ReaderOption.CoalesceGap of a zero ReaderOption
+const DefaultCoalesceGap = 64 << 10
+
+// DefaultReadTimeout is the ReaderOption.Timeout of a zero ReaderOption
+const DefaultReadTimeout = 30 * time.Second
+
+// CorruptPage describes one page replaced by nulls in tolerant mode
+type CorruptPage struct {
+	Column   int   // Column index in the schema
+	Page     int   // Page position within the column
+	FirstRow int64 // First row covered by the page
+	NumRows  int64 // Number of rows replaced by nulls
+	Err      error // Why the page could not be read
+}
+
+// String returns a human-readable description of the damaged range
+func (p CorruptPage) String() string {
+	return fmt.Sprintf("column %d page %d rows [%d, %d): %v",
+		p.Column, p.Page, p.FirstRow, p.FirstRow+p.NumRows, p.Err)
+}
+
+// CorruptionReport lists the pages skipped by a tolerant ReadRecordBatch
+type CorruptionReport struct {
+	Pages []CorruptPage
+}
+
+// HasCorruption reports whether any page was skipped
+func (r *CorruptionReport) HasCorruption() bool {
+	return r != nil && len(r.Pages) > 0
+}
+
+// RowsLost returns the number of column values replaced by nulls, summed
+// over all damaged pages
+func (r *CorruptionReport) RowsLost() int64 {
+	if r == nil {
+		return 0
+	}
+	var n int64
+	for _, p := range r.Pages {
+		n += p.NumRows
+	}
+	return n
+}
+
+// corruptionCollector gathers damaged pages from concurrent column reads
+type corruptionCollector struct {
+	mu    sync.Mutex
+	pages []CorruptPage
+}
+
+func (c *corruptionCollector) add(p CorruptPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages = append(c.pages, p)
+}
+
+// report returns the collected pages ordered by column and page, or nil if
+// none were skipped
+func (c *corruptionCollector) report() *CorruptionReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pages) == 0 {
+		return nil
+	}
+	pages := append([]CorruptPage(nil), c.pages...)
+	sort.Slice(pages, func(i, j int) bool {
+		if pages[i].Column != pages[j].Column {
+			return pages[i].Column < pages[j].Column
+		}
+		return pages[i].Page < pages[j].Page
+	})
+	return &CorruptionReport{Pages: pages}
+}
+
+// skipCorruptPage records page i of pageIndices, some pages of a column, as
+// damaged and returns an all-null array of the page's length in its place
+func (r *Reader) skipCorruptPage(pageIndices []format.PageIndex, i int, dataType arrow.DataType, err error) arrow.Array {
+	page, firstRow := r.pagePosition(pageIndices[i])
+	numRows := int(pageIndices[i].NumValues)
+
+	r.corruption.add(CorruptPage{
+		Column:   int(pageIndices[i].ColumnIndex),
+		Page:     page,
+		FirstRow: firstRow,
+		NumRows:  int64(numRows),
+		Err:      err,
+	})
+
+	builder := arrow.NewBuilderForType(dataType)
+	builder.Reserve(numRows)
+	for j := 0; j < numRows; j++ {
+		builder.AppendNull()
+	}
+	return builder.NewArray()
+}
+
+// pagePosition returns the position of a page within its whole column, not
+// the pages read, and the first row it covers
+func (r *Reader) pagePosition(pageIndex format.PageIndex) (int, int64) {
+	page := 0
+	var firstRow int64
+	for _, idx := range r.footer.GetColumnPages(pageIndex.ColumnIndex) {
+		if idx.Offset == pageIndex.Offset {
+			break
+		}
+		page++
+		firstRow += int64(idx.NumValues)
+	}
+	return page, firstRow
+}
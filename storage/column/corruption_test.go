@@ -0,0 +1,383 @@
+package column
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+const (
+	corruptTestPages       = 5
+	corruptTestRowsPerPage = 100
+)
+
+// writeMultiPageFile writes corruptTestPages batches so that every column
+// has one page per batch. Row i has id i and value i*0.5.
+func writeMultiPageFile(t *testing.T, filename string) {
+	t.Helper()
+
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
+		{Name: "value", Type: arrow.PrimFloat64(), Nullable: true},
+	}, nil)
+
+	writer, err := NewWriter(filename, schema, encoding.NewEncoderFactory(3))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	for p := 0; p < corruptTestPages; p++ {
+		ids := arrow.NewInt32Builder()
+		values := &arrow.Float64Builder{}
+		for i := 0; i < corruptTestRowsPerPage; i++ {
+			row := p*corruptTestRowsPerPage + i
+			ids.Append(int32(row))
+			values.Append(float64(row) * 0.5)
+		}
+		batch, err := arrow.NewRecordBatch(schema, corruptTestRowsPerPage,
+			[]arrow.Array{ids.NewArray(), values.NewArray()})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// corruptPage flips bytes in the data of the given page of a column
+func corruptPage(t *testing.T, filename string, column int32, page int) {
+	t.Helper()
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	pages := reader.footer.GetColumnPages(column)
+	reader.Close()
+	if len(pages) != corruptTestPages {
+		t.Fatalf("Expected %d pages in column %d, got %d", corruptTestPages, column, len(pages))
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	start := pages[page].Offset + format.PageHeaderSize
+	for i := start; i < start+8; i++ {
+		data[i] ^= 0xFF
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+func TestReader_StrictModeFailsOnCorruptPage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "corrupt.lance")
+	writeMultiPageFile(t, filename)
+	corruptPage(t, filename, 1, 2)
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := reader.ReadRecordBatch(); err == nil {
+		t.Fatal("Expected strict read of a corrupt page to fail")
+	}
+}
+
+func TestReader_SkipCorruptPages(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "corrupt.lance")
+	writeMultiPageFile(t, filename)
+	corruptPage(t, filename, 1, 2)
+
+	reader, err := NewReaderWithOptions(filename, nil, ReaderOption{SkipCorruptPages: true})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer reader.Close()
+
+	batch, err := reader.ReadRecordBatch()
+	if err != nil {
+		t.Fatalf("Tolerant ReadRecordBatch failed: %v", err)
+	}
+
+	total := corruptTestPages * corruptTestRowsPerPage
+	if batch.NumRows() != total {
+		t.Fatalf("Expected %d rows, got %d", total, batch.NumRows())
+	}
+	for c := 0; c < batch.NumCols(); c++ {
+		if batch.Column(c).Len() != total {
+			t.Errorf("Column %d has %d rows, expected %d", c, batch.Column(c).Len(), total)
+		}
+	}
+
+	report := reader.CorruptionReport()
+	if !report.HasCorruption() || len(report.Pages) != 1 {
+		t.Fatalf("Expected exactly one corrupt page, got %+v", report)
+	}
+	bad := report.Pages[0]
+	if bad.Column != 1 || bad.Page != 2 || bad.FirstRow != 200 || bad.NumRows != 100 || bad.Err == nil {
+		t.Errorf("Report does not pinpoint column 1 page 2 rows [200, 300): %v", bad)
+	}
+	if report.RowsLost() != 100 {
+		t.Errorf("Expected 100 rows lost, got %d", report.RowsLost())
+	}
+
+	ids := batch.Column(0).(*arrow.Int32Array)
+	values := batch.Column(1).(*arrow.Float64Array)
+	for i := 0; i < total; i++ {
+		if ids.IsNull(i) || ids.Value(i) != int32(i) {
+			t.Fatalf("id[%d]: expected %d, got %v (null=%v)", i, i, ids.Value(i), ids.IsNull(i))
+		}
+
+		damaged := int64(i) >= bad.FirstRow && int64(i) < bad.FirstRow+bad.NumRows
+		if damaged {
+			if !values.IsNull(i) {
+				t.Fatalf("value[%d] in damaged range should be null", i)
+			}
+			continue
+		}
+		if values.IsNull(i) || values.Value(i) != float64(i)*0.5 {
+			t.Fatalf("value[%d]: expected %v, got %v (null=%v)", i, float64(i)*0.5, values.Value(i), values.IsNull(i))
+		}
+	}
+}
+
+func TestReader_SkipCorruptPages_CleanFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "clean.lance")
+	writeMultiPageFile(t, filename)
+
+	reader, err := NewReaderWithOptions(filename, nil, ReaderOption{SkipCorruptPages: true})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := reader.ReadRecordBatch(); err != nil {
+		t.Fatalf("ReadRecordBatch failed: %v", err)
+	}
+	if report := reader.CorruptionReport(); report.HasCorruption() {
+		t.Errorf("Expected no corruption on a clean file, got %+v", report)
+	}
+}
+
+func TestReader_DecodeWorkers(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pages.lance")
+	writeMultiPageFile(t, filename)
+
+	reader, err := NewReaderWithOptions(filename, nil, ReaderOption{DecodeWorkers: 3})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	batch, err := reader.ReadRecordBatch()
+	reader.Close()
+	if err != nil {
+		t.Fatalf("ReadRecordBatch failed: %v", err)
+	}
+	ids := batch.Column(0).(*arrow.Int32Array)
+	values := batch.Column(1).(*arrow.Float64Array)
+	for i := 0; i < corruptTestPages*corruptTestRowsPerPage; i++ {
+		if ids.Value(i) != int32(i) || values.Value(i) != float64(i)*0.5 {
+			t.Fatalf("row %d: id %d, value %v", i, ids.Value(i), values.Value(i))
+		}
+	}
+
+	// Damaged pages are reported as by a serial read
+	corruptPage(t, filename, 1, 1)
+	corruptPage(t, filename, 1, 3)
+	strict, err := NewReaderWithOptions(filename, nil, ReaderOption{DecodeWorkers: 3})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer strict.Close()
+	if _, err := strict.ReadRecordBatch(); err == nil || !strings.Contains(err.Error(), "page_index:1") {
+		t.Errorf("strict parallel read: err = %v, want the failure of page 1", err)
+	}
+
+	tolerant, err := NewReaderWithOptions(filename, nil, ReaderOption{DecodeWorkers: 3, SkipCorruptPages: true})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer tolerant.Close()
+	if _, err := tolerant.ReadRecordBatch(); err != nil {
+		t.Fatalf("tolerant parallel read failed: %v", err)
+	}
+	report := tolerant.CorruptionReport()
+	if len(report.Pages) != 2 || report.Pages[0].Page != 1 || report.Pages[1].Page != 3 {
+		t.Errorf("report = %+v, want pages 1 and 3 of column 1", report)
+	}
+}
+
+// flipByte inverts the byte at offset of a file
+func flipByte(t *testing.T, filename string, offset int64) {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[offset] ^= 0xFF
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
+// pageDataMiddle returns the offset of the middle byte of the data of the
+// given page of a column
+func pageDataMiddle(t *testing.T, filename string, column int32, page int) int64 {
+	t.Helper()
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	idx := reader.footer.GetColumnPages(column)[page]
+	return idx.Offset + format.PageHeaderSize + int64(idx.Size-format.PageHeaderSize)/2
+}
+
+func TestReader_PageChecksumMismatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "flipped.lance")
+	writeMultiPageFile(t, filename)
+	flipByte(t, filename, pageDataMiddle(t, filename, 1, 2))
+
+	t.Run("Sync", func(t *testing.T) {
+		reader, err := NewReader(filename)
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		defer reader.Close()
+
+		_, err = reader.ReadRecordBatch()
+		if !lerrors.Is(err, lerrors.ErrChecksumMismatch) {
+			t.Fatalf("Expected ErrChecksumMismatch, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "page of column 1") || !strings.Contains(err.Error(), "page:2") {
+			t.Errorf("Error does not name column 1 page 2: %v", err)
+		}
+	})
+
+	t.Run("AsyncIO", func(t *testing.T) {
+		asyncIO := setupAsyncIO(t)
+		defer asyncIO.Close()
+
+		reader, err := NewReaderWithAsyncIO(filename, asyncIO)
+		if err != nil {
+			t.Fatalf("NewReaderWithAsyncIO failed: %v", err)
+		}
+		defer reader.Close()
+
+		if _, err := reader.ReadRecordBatch(); !lerrors.Is(err, lerrors.ErrChecksumMismatch) {
+			t.Fatalf("Expected ErrChecksumMismatch, got %v", err)
+		}
+	})
+
+	t.Run("SkipCorruptPages", func(t *testing.T) {
+		reader, err := NewReaderWithOptions(filename, nil, ReaderOption{SkipCorruptPages: true})
+		if err != nil {
+			t.Fatalf("NewReaderWithOptions failed: %v", err)
+		}
+		defer reader.Close()
+
+		if _, err := reader.ReadRecordBatch(); err != nil {
+			t.Fatalf("Tolerant ReadRecordBatch failed: %v", err)
+		}
+		report := reader.CorruptionReport()
+		if !report.HasCorruption() || !lerrors.Is(report.Pages[0].Err, lerrors.ErrChecksumMismatch) {
+			t.Fatalf("Expected the page to be reported for its checksum, got %+v", report)
+		}
+	})
+
+	t.Run("SkipChecksum", func(t *testing.T) {
+		reader, err := NewReaderWithOptions(filename, nil, ReaderOption{SkipChecksum: true})
+		if err != nil {
+			t.Fatalf("NewReaderWithOptions failed: %v", err)
+		}
+		defer reader.Close()
+
+		// The page decodes or not, but its checksum is not checked
+		if _, err := reader.ReadRecordBatch(); lerrors.Is(err, lerrors.ErrChecksumMismatch) {
+			t.Fatalf("Expected no checksum verification, got %v", err)
+		}
+	})
+}
+
+func TestReader_FooterChecksumMismatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "flipped.lance")
+	writeMultiPageFile(t, filename)
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	// Byte 8 of the footer is in its CreatedAt timestamp
+	flipByte(t, filename, info.Size()-format.FooterSize+8)
+
+	if _, err := NewReader(filename); !lerrors.Is(err, lerrors.ErrChecksumMismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+// TestReader_ZeroedChecksums checks that a zero checksum is verified like
+// any other: every writer sets them, so a zeroed one is corruption
+func TestReader_ZeroedChecksums(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "zeroed.lance")
+	writeMultiPageFile(t, filename)
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	indices := reader.footer.PageIndexList.Indices
+	footerChecksum := reader.footer.Checksum
+	reader.Close()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	for _, idx := range indices {
+		copy(data[idx.Offset+18:idx.Offset+22], make([]byte, 4))
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	reader, err = NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	_, err = reader.ReadRecordBatch()
+	reader.Close()
+	if !lerrors.Is(err, lerrors.ErrChecksumMismatch) {
+		t.Errorf("Zeroed page checksums: expected ErrChecksumMismatch, got %v", err)
+	}
+
+	footer := data[len(data)-format.FooterSize:]
+	sum := make([]byte, 4)
+	format.ByteOrder.PutUint32(sum, footerChecksum)
+	at := bytes.LastIndex(footer, sum)
+	if at < 0 {
+		t.Fatal("Footer checksum not found")
+	}
+	copy(footer[at:at+4], make([]byte, 4))
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := NewReader(filename); !lerrors.Is(err, lerrors.ErrChecksumMismatch) {
+		t.Errorf("Zeroed footer checksum: expected ErrChecksumMismatch, got %v", err)
+	}
+}
@@ -166,7 +166,7 @@ func TestE2E_CompressionRatio(t *testing.T) {
 func TestE2E_MultiplePages(t *testing.T) {
 	// 创建临时文件测试完整 Writer/Reader
 	tmpDir := t.TempDir()
-	filename := tmpDir + "/test.lance"
+	filename := filepath.Join(tmpDir, "test.lance")
 
 	schema := arrow.NewSchema([]arrow.Field{
 		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
@@ -418,7 +418,7 @@ func TestE2E_Dictionary_LowCardinality(t *testing.T) {
 // TestE2E_MixedTypes 验证多列不同类型
 func TestE2E_MixedTypes(t *testing.T) {
 	tmpDir := t.TempDir()
-	filename := tmpDir + "/mixed.lance"
+	filename := filepath.Join(tmpDir, "mixed.lance")
 
 	schema := arrow.NewSchema([]arrow.Field{
 		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
@@ -766,7 +766,7 @@ func TestE2E_VectorColumn(t *testing.T) {
 // TestE2E_MixedTypes_Fixed 修复后的多列类型测试（完整验证）
 func TestE2E_MixedTypes_Fixed(t *testing.T) {
 	tmpDir := t.TempDir()
-	filename := tmpDir + "/mixed.lance"
+	filename := filepath.Join(tmpDir, "mixed.lance")
 
 	schema := arrow.NewSchema([]arrow.Field{
 		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
@@ -861,7 +861,7 @@ func TestE2E_MixedTypes_Fixed(t *testing.T) {
 // TestE2E_Int64Type Int64 类型完整测试
 func TestE2E_Int64Type(t *testing.T) {
 	tmpDir := t.TempDir()
-	filename := tmpDir + "/int64.lance"
+	filename := filepath.Join(tmpDir, "int64.lance")
 
 	schema := arrow.NewSchema([]arrow.Field{
 		{Name: "timestamp", Type: arrow.PrimInt64(), Nullable: false},
@@ -912,7 +912,7 @@ func TestE2E_Int64Type(t *testing.T) {
 // TestE2E_Float64Type Float64 类型完整测试
 func TestE2E_Float64Type(t *testing.T) {
 	tmpDir := t.TempDir()
-	filename := tmpDir + "/float64.lance"
+	filename := filepath.Join(tmpDir, "float64.lance")
 
 	schema := arrow.NewSchema([]arrow.Field{
 		{Name: "measurement", Type: arrow.PrimFloat64(), Nullable: true},
@@ -1101,7 +1101,7 @@ func TestE2E_EncodingSelection(t *testing.T) {
 // TestE2E_WriterReaderRoundtrip 完整的文件级往返测试
 func TestE2E_WriterReaderRoundtrip(t *testing.T) {
 	tmpDir := t.TempDir()
-	filename := tmpDir + "/roundtrip.lance"
+	filename := filepath.Join(tmpDir, "roundtrip.lance")
 
 	schema := arrow.NewSchema([]arrow.Field{
 		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
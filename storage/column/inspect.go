@@ -0,0 +1,122 @@
+package column
+
+import (
+	"fmt"
+
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// FileReport describes how a Lance file stores its data: the size of its
+// fixed parts and, for every column, its pages, bytes and encodings. It
+// marshals to JSON as is, for tools that print or compare it.
+type FileReport struct {
+	Path     string `json:"path"`
+	FileSize int64  `json:"file_size"`
+	NumRows  int64  `json:"num_rows"`
+	Version  string `json:"version"`
+
+	// HeaderBytes and FooterBytes are the fixed space the header and footer
+	// reserve, padding included
+	HeaderBytes int64 `json:"header_bytes"`
+	FooterBytes int64 `json:"footer_bytes"`
+	// RowIndexBytes is the size of the row index page, if the file has one
+	RowIndexBytes int64 `json:"row_index_bytes,omitempty"`
+
+	Columns []ColumnReport `json:"columns"`
+}
+
+// ColumnReport is the part of a FileReport about one column
+type ColumnReport struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	format.ColumnSummary
+	// Ratio is UncompressedBytes over CompressedBytes, 0 without pages
+	Ratio float64 `json:"ratio"`
+}
+
+// DataBytes returns the bytes of the pages of every column, headers
+// included. With the header, footer and row index they make up the file.
+func (r *FileReport) DataBytes() int64 {
+	var total int64
+	for _, col := range r.Columns {
+		total += col.CompressedBytes
+	}
+	return total
+}
+
+// Inspect reports how the Lance file filename stores each column. Files
+// written with column summaries are described from the footer alone; for
+// older files the header of every page is read for its uncompressed size,
+// but no page is decoded.
+func Inspect(filename string) (*FileReport, error) {
+	reader, err := NewReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	info, err := reader.file.Stat()
+	if err != nil {
+		return nil, lerrors.IO("inspect", filename, err)
+	}
+	summaries, err := reader.columnSummaries()
+	if err != nil {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("inspect").
+			Path(filename).
+			Wrap(err).
+			Build()
+	}
+
+	report := &FileReport{
+		Path:        filename,
+		FileSize:    info.Size(),
+		NumRows:     reader.header.NumRows,
+		Version:     reader.footer.GetFormatVersion().String(),
+		HeaderBytes: HeaderReservedSize,
+		FooterBytes: format.FooterSize,
+	}
+	if _, size, _, ok := reader.footer.GetRowIndexInfo(); ok {
+		report.RowIndexBytes = int64(size)
+	}
+
+	schema := reader.header.Schema
+	for i, summary := range summaries {
+		col := ColumnReport{
+			Name:          schema.Field(i).Name,
+			Type:          schema.Field(i).Type.Name(),
+			ColumnSummary: summary,
+		}
+		if summary.CompressedBytes > 0 {
+			col.Ratio = float64(summary.UncompressedBytes) / float64(summary.CompressedBytes)
+		}
+		report.Columns = append(report.Columns, col)
+	}
+	return report, nil
+}
+
+// columnSummaries returns the summary of every column, from the footer if
+// the writer stored them there, or else from the page index and the page
+// headers
+func (r *Reader) columnSummaries() ([]format.ColumnSummary, error) {
+	numColumns := r.header.Schema.NumFields()
+	if summaries, ok := r.footer.GetColumnSummaries(); ok && len(summaries) == numColumns {
+		return summaries, nil
+	}
+
+	summaries := make([]format.ColumnSummary, numColumns)
+	header := make([]byte, format.PageHeaderSize)
+	for _, idx := range r.footer.PageIndexList.Indices {
+		if idx.ColumnIndex < 0 || int(idx.ColumnIndex) >= numColumns {
+			return nil, fmt.Errorf("page of column %d in a file of %d columns", idx.ColumnIndex, numColumns)
+		}
+		// The uncompressed size follows type, encoding, column and values
+		if _, err := r.file.ReadAt(header, idx.Offset); err != nil {
+			return nil, err
+		}
+		uncompressed := int32(format.ByteOrder.Uint32(header[10:14]))
+		summaries[idx.ColumnIndex].AddPage(idx.Size, uncompressed, idx.NumValues, idx.Encoding)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,196 @@
+package column
+
+import (
+	"encoding/json"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+func inspectTestSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "status", Type: arrow.PrimInt32(), Nullable: false},
+		{Name: "category", Type: arrow.PrimInt64(), Nullable: false},
+		{Name: "payload", Type: arrow.PrimInt64(), Nullable: false},
+	}, nil)
+}
+
+// writeInspectBatch writes n rows of a long-run column for RLE, a column
+// of 8 shuffled values for Dictionary and random wide integers for Zstd
+func writeInspectBatch(t *testing.T, writer *Writer, rng *rand.Rand, n int) {
+	t.Helper()
+	status := make([]int32, n)
+	category := make([]int64, n)
+	payload := make([]int64, n)
+	for i := 0; i < n; i++ {
+		status[i] = int32(i / 1000)
+		category[i] = 1_000_000 * rng.Int63n(8)
+		payload[i] = rng.Int63()
+	}
+	batch, err := arrow.NewRecordBatch(inspectTestSchema(), n, []arrow.Array{
+		arrow.NewInt32Array(status, nil),
+		arrow.NewInt64Array(category, nil),
+		arrow.NewInt64Array(payload, nil),
+	})
+	if err != nil {
+		t.Fatalf("NewRecordBatch failed: %v", err)
+	}
+	if err := writer.WriteRecordBatch(batch); err != nil {
+		t.Fatalf("WriteRecordBatch failed: %v", err)
+	}
+}
+
+// checkFileReport checks that the columns of report add up to the file
+func checkFileReport(t *testing.T, report *FileReport) {
+	t.Helper()
+	if got := report.HeaderBytes + report.DataBytes() + report.FooterBytes; got != report.FileSize {
+		t.Errorf("header %d + pages %d + footer %d = %d, file is %d bytes",
+			report.HeaderBytes, report.DataBytes(), report.FooterBytes, got, report.FileSize)
+	}
+	for _, col := range report.Columns {
+		if col.Values != report.NumRows {
+			t.Errorf("column %s has %d values, file %d rows", col.Name, col.Values, report.NumRows)
+		}
+		pages := 0
+		for _, n := range col.Encodings {
+			pages += n
+		}
+		if pages != col.Pages {
+			t.Errorf("column %s: encodings count %d pages, want %d", col.Name, pages, col.Pages)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mixed.lance")
+	rng := rand.New(rand.NewSource(1))
+	writer, err := NewWriter(path, inspectTestSchema(), encoding.NewEncoderFactory(3))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	writeInspectBatch(t, writer, rng, 5000)
+	writeInspectBatch(t, writer, rng, 5000)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	report, err := Inspect(path)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if report.NumRows != 10000 || len(report.Columns) != 3 {
+		t.Fatalf("Report has %d rows and %d columns", report.NumRows, len(report.Columns))
+	}
+	checkFileReport(t, report)
+
+	for i, want := range []format.EncodingType{format.EncodingRLE, format.EncodingDictionary, format.EncodingZstd} {
+		col := report.Columns[i]
+		if col.Pages != 2 || col.Encodings[want.String()] != 2 {
+			t.Errorf("column %s: %d pages, encodings %v, want 2 %s pages", col.Name, col.Pages, col.Encodings, want)
+		}
+		if col.UncompressedBytes <= 0 || col.Ratio <= 0 {
+			t.Errorf("column %s: uncompressed %d bytes, ratio %v", col.Name, col.UncompressedBytes, col.Ratio)
+		}
+	}
+	if report.Columns[0].Ratio < 10 || report.Columns[2].Ratio > 1.1 {
+		t.Errorf("RLE ratio %v, random ratio %v", report.Columns[0].Ratio, report.Columns[2].Ratio)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded FileReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, report) {
+		t.Errorf("JSON round trip changed the report:\n%s", data)
+	}
+}
+
+// TestInspect_WithoutSummaries checks that the summaries of files written
+// without them are rebuilt from the page headers
+func TestInspect_WithoutSummaries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mixed.lance")
+	rng := rand.New(rand.NewSource(2))
+	writer, err := NewWriter(path, inspectTestSchema(), nil)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	writeInspectBatch(t, writer, rng, 3000)
+	writeInspectBatch(t, writer, rng, 100)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := NewReader(path)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	stored, ok := reader.footer.GetColumnSummaries()
+	if !ok {
+		t.Fatal("Footer has no column summaries")
+	}
+	delete(reader.footer.Metadata, format.MetadataColumnSummaries)
+	rebuilt, err := reader.columnSummaries()
+	if err != nil {
+		t.Fatalf("columnSummaries failed: %v", err)
+	}
+	if !reflect.DeepEqual(rebuilt, stored) {
+		t.Errorf("Rebuilt summaries %+v, footer has %+v", rebuilt, stored)
+	}
+}
+
+func TestInspect_AppendAndMerge(t *testing.T) {
+	dir := t.TempDir()
+	rng := rand.New(rand.NewSource(3))
+	schema := inspectTestSchema()
+	paths := []string{filepath.Join(dir, "a.lance"), filepath.Join(dir, "b.lance")}
+	for _, path := range paths {
+		writer, err := NewWriter(path, schema, nil)
+		if err != nil {
+			t.Fatalf("NewWriter failed: %v", err)
+		}
+		writeInspectBatch(t, writer, rng, 2000)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	writer, err := OpenWriterAppend(paths[0], schema, nil)
+	if err != nil {
+		t.Fatalf("OpenWriterAppend failed: %v", err)
+	}
+	writeInspectBatch(t, writer, rng, 1000)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	appended, err := Inspect(paths[0])
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if appended.NumRows != 3000 || appended.Columns[0].Pages != 2 {
+		t.Errorf("Appended file: %d rows, %d pages", appended.NumRows, appended.Columns[0].Pages)
+	}
+	checkFileReport(t, appended)
+
+	merged := filepath.Join(dir, "merged.lance")
+	if err := MergeFiles(merged, paths, MergeOptions{}); err != nil {
+		t.Fatalf("MergeFiles failed: %v", err)
+	}
+	report, err := Inspect(merged)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if report.NumRows != 5000 || report.Columns[0].Pages != 3 {
+		t.Errorf("Merged file: %d rows, %d pages", report.NumRows, report.Columns[0].Pages)
+	}
+	checkFileReport(t, report)
+}
@@ -0,0 +1,167 @@
+package column
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// PageInfo describes where and how one page of a column is stored
+type PageInfo struct {
+	Column     int    // Column index in the schema
+	ColumnName string // Column name in the schema
+	Page       int    // Page position within the column
+	FirstRow   int64  // First row covered by the page
+	NumValues  int32  // Number of values in the page
+	Offset     int64  // Byte offset of the page header in the file
+	Size       int32  // Bytes on disk, page header included
+
+	// CompressedSize is the size of the encoded page data
+	CompressedSize int32
+	// UncompressedSize is the raw data size recorded in the page header.
+	// The footer does not store it, so PageLayout leaves it zero and
+	// ValidatePages fills it in for every page it could read.
+	UncompressedSize int32
+
+	Encoding format.EncodingType
+}
+
+// String returns a one-line description of the page
+func (p PageInfo) String() string {
+	return fmt.Sprintf("column %d (%s) page %d rows [%d, %d) offset %d size %d encoding %s",
+		p.Column, p.ColumnName, p.Page, p.FirstRow, p.FirstRow+int64(p.NumValues),
+		p.Offset, p.Size, p.Encoding)
+}
+
+// PageLayout lists every page of the file ordered by column and page,
+// using only the footer; no page is read
+func (r *Reader) PageLayout() ([]PageInfo, error) {
+	if r.closed {
+		return nil, lerrors.New(lerrors.ErrInvalidArgument).
+			Op("page_layout").
+			Context("message", "reader is closed").
+			Build()
+	}
+
+	schema := r.header.Schema
+	layout := make([]PageInfo, 0, len(r.footer.PageIndexList.Indices))
+	for col := 0; col < schema.NumFields(); col++ {
+		var firstRow int64
+		for i, idx := range r.footer.GetColumnPages(int32(col)) {
+			layout = append(layout, PageInfo{
+				Column:         col,
+				ColumnName:     schema.Field(col).Name,
+				Page:           i,
+				FirstRow:       firstRow,
+				NumValues:      idx.NumValues,
+				Offset:         idx.Offset,
+				Size:           idx.Size,
+				CompressedSize: idx.Size - format.PageHeaderSize,
+				Encoding:       idx.Encoding,
+			})
+			firstRow += int64(idx.NumValues)
+		}
+	}
+	return layout, nil
+}
+
+// ValidationReport is the result of ValidatePages
+type ValidationReport struct {
+	Pages    []PageInfo    // Layout of the file, see PageLayout
+	Failures []CorruptPage // Pages that failed to read, checksum or decode
+}
+
+// OK reports whether every page passed
+func (r *ValidationReport) OK() bool {
+	return r != nil && len(r.Failures) == 0
+}
+
+// ValidatePages reads every page of the file, verifies its checksum, checks
+// its header against the footer and decodes it. Damaged pages are listed in
+// the report rather than returned as an error; the error is only set if the
+// reader is closed or ctx is done, which is checked between pages.
+func (r *Reader) ValidatePages(ctx context.Context) (*ValidationReport, error) {
+	layout, err := r.PageLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	schema := r.header.Schema
+	report := &ValidationReport{Pages: layout}
+	for i := range layout {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		info := &layout[i]
+		uncompressed, err := r.validatePage(ctx, info, schema.Field(info.Column).Type)
+		if err != nil {
+			report.Failures = append(report.Failures, CorruptPage{
+				Column:   info.Column,
+				Page:     info.Page,
+				FirstRow: info.FirstRow,
+				NumRows:  int64(info.NumValues),
+				Err:      err,
+			})
+			continue
+		}
+		info.UncompressedSize = uncompressed
+	}
+	return report, nil
+}
+
+// validatePage reads and decodes one page and returns the uncompressed size
+// from its header. The read is bounded as those of ReadRecordBatchContext.
+func (r *Reader) validatePage(ctx context.Context, info *PageInfo, dataType arrow.DataType) (int32, error) {
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	page, err := r.readPage(ctx, format.PageIndex{
+		ColumnIndex: int32(info.Column),
+		Offset:      info.Offset,
+		Size:        info.Size,
+		NumValues:   info.NumValues,
+		Encoding:    info.Encoding,
+	})
+	if err != nil {
+		return 0, lerrors.New(lerrors.ErrIO).
+			Op("validate_page").
+			Context("column", info.Column).
+			Context("page_index", info.Page).
+			Wrap(err).
+			Build()
+	}
+
+	var mismatch string
+	switch {
+	case page.ColumnIndex != int32(info.Column):
+		mismatch = fmt.Sprintf("page header column %d", page.ColumnIndex)
+	case page.NumValues != info.NumValues:
+		mismatch = fmt.Sprintf("page header has %d values, footer %d", page.NumValues, info.NumValues)
+	case page.Encoding != info.Encoding:
+		mismatch = fmt.Sprintf("page header encoding %s, footer %s", page.Encoding, info.Encoding)
+	case page.CompressedSize != info.CompressedSize:
+		mismatch = fmt.Sprintf("page header size %d, footer %d", page.CompressedSize, info.CompressedSize)
+	}
+	if mismatch != "" {
+		return 0, lerrors.FormatCorrupted("", info.Offset, mismatch)
+	}
+
+	array, err := r.pageReader.ReadPage(page, dataType)
+	if err != nil {
+		return 0, lerrors.New(lerrors.ErrDecodeFailed).
+			Op("validate_page").
+			Context("column", info.Column).
+			Context("page_index", info.Page).
+			Wrap(err).
+			Build()
+	}
+	if array.Len() != int(info.NumValues) {
+		return 0, lerrors.FormatCorrupted("", info.Offset,
+			fmt.Sprintf("page decoded to %d values, footer %d", array.Len(), info.NumValues))
+	}
+	return page.UncompressedSize, nil
+}
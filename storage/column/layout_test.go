@@ -0,0 +1,150 @@
+package column
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+func TestReader_PageLayout(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "layout.lance")
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
+		{Name: "score", Type: arrow.PrimFloat32(), Nullable: false},
+		{Name: "ts", Type: arrow.PrimInt64(), Nullable: false},
+	}, nil)
+
+	writer, err := NewWriter(filename, schema, encoding.NewEncoderFactory(3))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	batchRows := []int{50, 120, 7}
+	row := 0
+	for _, n := range batchRows {
+		ids := arrow.NewInt32Builder()
+		scores := arrow.NewFloat32Builder()
+		ts := arrow.NewInt64Builder()
+		for i := 0; i < n; i++ {
+			ids.Append(int32(row))
+			scores.Append(float32(row%10) / 10)
+			ts.Append(1700000000 + int64(row))
+			row++
+		}
+		batch, err := arrow.NewRecordBatch(schema, n,
+			[]arrow.Array{ids.NewArray(), scores.NewArray(), ts.NewArray()})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	written := append([]format.PageIndex(nil), writer.footer.PageIndexList.Indices...)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+
+	layout, err := reader.PageLayout()
+	if err != nil {
+		t.Fatalf("PageLayout failed: %v", err)
+	}
+	if len(layout) != len(written) || len(layout) != len(batchRows)*schema.NumFields() {
+		t.Fatalf("Expected %d pages, got %d", len(written), len(layout))
+	}
+
+	// The writer emits pages batch by batch; the layout groups them by column
+	i := 0
+	for col := 0; col < schema.NumFields(); col++ {
+		var firstRow int64
+		for page, n := range batchRows {
+			got := layout[i]
+			want := written[page*schema.NumFields()+col]
+			i++
+
+			if got.Column != col || got.ColumnName != schema.Field(col).Name || got.Page != page {
+				t.Errorf("Page %d: got column %d (%s) page %d", i, got.Column, got.ColumnName, got.Page)
+			}
+			if got.FirstRow != firstRow || got.NumValues != int32(n) || got.NumValues != want.NumValues {
+				t.Errorf("%v: expected rows [%d, %d)", got, firstRow, firstRow+int64(n))
+			}
+			if got.Offset != want.Offset || got.Size != want.Size || got.Encoding != want.Encoding {
+				t.Errorf("%v: writer recorded offset %d size %d encoding %s", got, want.Offset, want.Size, want.Encoding)
+			}
+			if got.CompressedSize != got.Size-format.PageHeaderSize || got.CompressedSize <= 0 {
+				t.Errorf("%v: compressed size %d", got, got.CompressedSize)
+			}
+			if got.UncompressedSize != 0 {
+				t.Errorf("%v: PageLayout should not read the uncompressed size", got)
+			}
+			firstRow += int64(n)
+		}
+	}
+
+	report, err := reader.ValidatePages(context.Background())
+	if err != nil {
+		t.Fatalf("ValidatePages failed: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("Expected a clean file to validate, got %v", report.Failures)
+	}
+	for _, p := range report.Pages {
+		if p.UncompressedSize <= 0 {
+			t.Errorf("%v: uncompressed size not filled in", p)
+		}
+	}
+}
+
+func TestReader_ValidatePages(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "corrupt.lance")
+	writeMultiPageFile(t, filename)
+	corruptPage(t, filename, 1, 3)
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+
+	report, err := reader.ValidatePages(context.Background())
+	if err != nil {
+		t.Fatalf("ValidatePages failed: %v", err)
+	}
+	if report.OK() || len(report.Failures) != 1 {
+		t.Fatalf("Expected exactly one failed page, got %v", report.Failures)
+	}
+	bad := report.Failures[0]
+	if bad.Column != 1 || bad.Page != 3 || bad.FirstRow != 300 || bad.NumRows != 100 || bad.Err == nil {
+		t.Errorf("Report does not pinpoint column 1 page 3 rows [300, 400): %v", bad)
+	}
+	if len(report.Pages) != 2*corruptTestPages {
+		t.Fatalf("Expected %d pages in the report, got %d", 2*corruptTestPages, len(report.Pages))
+	}
+	for _, p := range report.Pages {
+		failed := p.Column == 1 && p.Page == 3
+		if got := p.UncompressedSize > 0; got == failed {
+			t.Errorf("%v: uncompressed size %d", p, p.UncompressedSize)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := reader.ValidatePages(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	reader.Close()
+	if _, err := reader.PageLayout(); err == nil {
+		t.Error("Expected PageLayout on a closed reader to fail")
+	}
+}
@@ -0,0 +1,279 @@
+package column
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// MergeOptions configures MergeFiles
+type MergeOptions struct {
+	// VerifyChecksums checks the CRC of every page while it is copied
+	VerifyChecksums bool
+
+	// Metadata is the user metadata of the merged file's footer. When nil,
+	// the user metadata of the first source is kept.
+	Metadata map[string]string
+}
+
+// mergeSource is an opened source file and where its pages go in dst
+type mergeSource struct {
+	path   string
+	reader *Reader
+	shift  int64 // Added to every page offset of the source
+}
+
+// MergeFiles concatenates the Lance files srcs into a new file dst. Pages
+// are copied byte for byte, without decoding or re-encoding, so the cost is
+// that of copying the data; only the footer is rewritten with the new page
+// offsets, and the rows of each column follow the order of srcs.
+//
+// Every source must have the same fields, in the same order, with the same
+// names, types and nullability, and the same format version. Sources with a
+// row index are rejected, since its row numbers would no longer match.
+// All checks, including that the merged footer fits, are done before dst
+// is created, and dst only appears once it is complete.
+func MergeFiles(dst string, srcs []string, opts MergeOptions) error {
+	if len(srcs) == 0 {
+		return lerrors.InvalidArg("merge_files", "no source files")
+	}
+
+	sources := make([]*mergeSource, 0, len(srcs))
+	defer func() {
+		for _, src := range sources {
+			src.reader.Close()
+		}
+	}()
+	for _, path := range srcs {
+		reader, err := NewReader(path)
+		if err != nil {
+			return lerrors.New(lerrors.ErrIO).
+				Op("merge_files").
+				Path(path).
+				Wrap(err).
+				Build()
+		}
+		sources = append(sources, &mergeSource{path: path, reader: reader})
+		if err := checkMergeSource(sources[0].reader, reader, path); err != nil {
+			return err
+		}
+	}
+
+	first := sources[0].reader
+	header := format.NewHeader(first.header.Schema, 0)
+	header.Version = first.header.Version
+	header.Flags = first.header.Flags
+	header.PageSize = first.header.PageSize
+
+	footer := format.NewFooter()
+	footer.Version = first.footer.Version
+	if _, ok := first.footer.Metadata[format.MetadataFormatVersion]; ok {
+		footer.SetFormatVersion(first.footer.GetFormatVersion())
+	}
+	if blockSize, ok := first.footer.GetBlockCacheInfo(); ok {
+		footer.SetBlockCacheInfo(blockSize)
+	}
+	metadata := opts.Metadata
+	if metadata == nil {
+		metadata = first.footer.GetUserMetadata()
+	}
+	footer.MergeMetadata(metadata)
+
+	// Lay the pages of each source out after those of the previous one
+	pos := int64(HeaderReservedSize)
+	pageNums := make([]int32, header.NumColumns)
+	summaries := make([]format.ColumnSummary, header.NumColumns)
+	for _, src := range sources {
+		srcSummaries, err := src.reader.columnSummaries()
+		if err != nil {
+			return lerrors.New(lerrors.ErrCorruptedFile).
+				Op("merge_files").
+				Path(src.path).
+				Wrap(err).
+				Build()
+		}
+		for col := range summaries {
+			summaries[col].Add(srcSummaries[col])
+		}
+		src.shift = pos - HeaderReservedSize
+		for _, idx := range src.reader.footer.PageIndexList.Indices {
+			footer.PageIndexList.AddWithStats(idx.ColumnIndex, pageNums[idx.ColumnIndex],
+				idx.Offset+src.shift, idx.Size, idx.NumValues, idx.Encoding, idx.Stats)
+			pageNums[idx.ColumnIndex]++
+			pos = max(pos, idx.Offset+src.shift+int64(idx.Size))
+		}
+		header.NumRows += src.reader.header.NumRows
+	}
+	footer.NumPages = int32(len(footer.PageIndexList.Indices))
+	footer.SetColumnSummaries(summaries)
+
+	// Serialize both ends first so that a merge too large for the footer
+	// fails before anything is written
+	var headerBuf, footerBuf bytes.Buffer
+	if _, err := header.WriteTo(&headerBuf); err != nil {
+		return lerrors.New(lerrors.ErrIO).Op("merge_files").Wrap(err).Build()
+	}
+	if headerBuf.Len() > HeaderReservedSize {
+		return lerrors.New(lerrors.ErrMetadataError).
+			Op("merge_files").
+			Context("header_size", headerBuf.Len()).
+			Context("reserved_size", HeaderReservedSize).
+			Context("message", "header size exceeds reserved size").
+			Build()
+	}
+	if _, err := footer.WriteTo(&footerBuf); err != nil {
+		return lerrors.New(lerrors.ErrMetadataError).
+			Op("merge_files").
+			Context("num_pages", footer.NumPages).
+			Wrap(err).
+			Build()
+	}
+
+	tmp := dst + ".merge.tmp"
+	if err := writeMerged(tmp, headerBuf.Bytes(), footerBuf.Bytes(), sources, opts); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return lerrors.IO("merge_files", dst, err)
+	}
+	return nil
+}
+
+// checkMergeSource checks that reader, opened from path, can be merged
+// after first
+func checkMergeSource(first, reader *Reader, path string) error {
+	if reader.footer.HasRowIndex() {
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op("merge_files").
+			Path(path).
+			Context("message", "files with a row index cannot be merged").
+			Build()
+	}
+	if got, want := reader.footer.GetFormatVersion(), first.footer.GetFormatVersion(); got != want {
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op("merge_files").
+			Path(path).
+			Context("expected_version", want.String()).
+			Context("actual_version", got.String()).
+			Context("message", "format version mismatch").
+			Build()
+	}
+
+	want, got := first.header.Schema, reader.header.Schema
+	if got.NumFields() != want.NumFields() {
+		return lerrors.SchemaMismatch(path, "num_fields",
+			fmt.Sprint(want.NumFields()), fmt.Sprint(got.NumFields()))
+	}
+	for i := 0; i < want.NumFields(); i++ {
+		if f, g := want.Field(i), got.Field(i); !mergeFieldEqual(f, g) {
+			return lerrors.SchemaMismatch(path, fmt.Sprintf("field %d", i),
+				fieldString(f), fieldString(g))
+		}
+	}
+
+	// Every page must belong to a column, and every column must hold the
+	// rows announced by the header, or the merged columns would not line up
+	for _, idx := range reader.footer.PageIndexList.Indices {
+		if idx.ColumnIndex < 0 || int(idx.ColumnIndex) >= got.NumFields() {
+			return lerrors.FormatCorrupted(path, idx.Offset,
+				fmt.Sprintf("page of unknown column %d", idx.ColumnIndex))
+		}
+		if idx.Size < format.PageHeaderSize || idx.Size > format.PageHeaderSize+format.MaxPageSize {
+			return lerrors.FormatCorrupted(path, idx.Offset,
+				fmt.Sprintf("invalid page size %d", idx.Size))
+		}
+	}
+	for col := 0; col < got.NumFields(); col++ {
+		var rows int64
+		for _, idx := range reader.footer.GetColumnPages(int32(col)) {
+			rows += int64(idx.NumValues)
+		}
+		if rows != reader.header.NumRows {
+			return lerrors.FormatCorrupted(path, 0, fmt.Sprintf(
+				"column %d has %d rows, header %d", col, rows, reader.header.NumRows))
+		}
+	}
+	return nil
+}
+
+func mergeFieldEqual(a, b arrow.Field) bool {
+	return a.Name == b.Name && a.Nullable == b.Nullable && a.ID == b.ID &&
+		fieldTypeEqual(a.Type, b.Type)
+}
+
+// writeMerged writes the merged file to path: the header, the pages of
+// every source at their shifted offsets, and the footer
+func writeMerged(path string, header, footer []byte, sources []*mergeSource, opts MergeOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return lerrors.IO("merge_files", path, err)
+	}
+	defer file.Close()
+
+	padded := make([]byte, HeaderReservedSize)
+	copy(padded, header)
+	if _, err := file.Write(padded); err != nil {
+		return lerrors.IO("merge_files", path, err)
+	}
+
+	end := int64(HeaderReservedSize)
+	var buf []byte
+	for _, src := range sources {
+		for _, idx := range src.reader.footer.PageIndexList.Indices {
+			if cap(buf) < int(idx.Size) {
+				buf = make([]byte, idx.Size)
+			}
+			data := buf[:idx.Size]
+			if _, err := src.reader.file.ReadAt(data, idx.Offset); err != nil {
+				return lerrors.IO("merge_files", src.path, err)
+			}
+			if opts.VerifyChecksums {
+				if err := verifyMergePage(data, idx); err != nil {
+					return lerrors.New(lerrors.ErrCorruptedFile).
+						Op("merge_files").
+						Path(src.path).
+						Offset(idx.Offset).
+						Context("column", idx.ColumnIndex).
+						Context("page_index", idx.PageNum).
+						Wrap(err).
+						Build()
+				}
+			}
+			if _, err := file.WriteAt(data, idx.Offset+src.shift); err != nil {
+				return lerrors.IO("merge_files", path, err)
+			}
+			end = max(end, idx.Offset+src.shift+int64(idx.Size))
+		}
+	}
+
+	if _, err := file.WriteAt(footer, end); err != nil {
+		return lerrors.IO("merge_files", path, err)
+	}
+	if err := file.Sync(); err != nil {
+		return lerrors.IO("merge_files", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return lerrors.IO("merge_files", path, err)
+	}
+	return nil
+}
+
+// verifyMergePage parses the page in data, which checks its CRC, and
+// checks its header against the footer entry idx
+func verifyMergePage(data []byte, idx format.PageIndex) error {
+	page := &format.Page{}
+	n, err := page.ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if n != int64(len(data)) || page.ColumnIndex != idx.ColumnIndex || page.NumValues != idx.NumValues {
+		return fmt.Errorf("page header does not match the footer")
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package column
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+const mergeTestDim = 4
+
+func mergeTestSchema(scoreNullable bool, dim int) *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
+		{Name: "score", Type: arrow.PrimFloat64(), Nullable: scoreNullable},
+		{Name: "vector", Type: arrow.FixedSizeListOf(arrow.PrimFloat32(), dim), Nullable: false},
+	}, nil)
+}
+
+// writeMergeSource writes rows starting at firstRow, split into batches of
+// the given sizes, and returns the number of rows written
+func writeMergeSource(t *testing.T, filename string, schema *arrow.Schema, firstRow int, batches []int) int {
+	t.Helper()
+
+	writer, err := NewWriter(filename, schema, encoding.NewEncoderFactory(3))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	n := writeMergeBatches(t, writer, schema, firstRow, batches)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return n
+}
+
+// writeMergeBatches writes the batches of writeMergeSource to writer,
+// without closing it
+func writeMergeBatches(t *testing.T, writer *Writer, schema *arrow.Schema, firstRow int, batches []int) int {
+	t.Helper()
+
+	dim := schema.Field(2).Type.(*arrow.FixedSizeListType).Size()
+	row := firstRow
+	for _, n := range batches {
+		ids := arrow.NewInt32Builder()
+		scores := &arrow.Float64Builder{}
+		values := arrow.NewFloat32Builder()
+		for i := 0; i < n; i++ {
+			ids.Append(int32(row))
+			if row%7 == 0 {
+				scores.AppendNull()
+			} else {
+				scores.Append(float64(row) * 0.25)
+			}
+			for d := 0; d < dim; d++ {
+				values.Append(float32(row*dim + d))
+			}
+			row++
+		}
+		listType := schema.Field(2).Type.(*arrow.FixedSizeListType)
+		batch, err := arrow.NewRecordBatch(schema, n, []arrow.Array{
+			ids.NewArray(), scores.NewArray(),
+			arrow.NewFixedSizeListArray(listType, values.NewArray(), nil),
+		})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	return row - firstRow
+}
+
+func readMergeTestFile(t *testing.T, filename string) *arrow.RecordBatch {
+	t.Helper()
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader(%s) failed: %v", filename, err)
+	}
+	defer reader.Close()
+	batch, err := reader.ReadRecordBatch()
+	if err != nil {
+		t.Fatalf("ReadRecordBatch(%s) failed: %v", filename, err)
+	}
+	return batch
+}
+
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	schema := mergeTestSchema(true, mergeTestDim)
+	srcs := []string{
+		filepath.Join(dir, "a.lance"),
+		filepath.Join(dir, "b.lance"),
+		filepath.Join(dir, "c.lance"),
+	}
+	row := 0
+	for i, batches := range [][]int{{100}, {13, 250, 1}, {40, 40}} {
+		row += writeMergeSource(t, srcs[i], schema, row, batches)
+	}
+
+	dst := filepath.Join(dir, "merged.lance")
+	if err := MergeFiles(dst, srcs, MergeOptions{VerifyChecksums: true}); err != nil {
+		t.Fatalf("MergeFiles failed: %v", err)
+	}
+
+	merged := readMergeTestFile(t, dst)
+	if merged.NumRows() != row {
+		t.Fatalf("merged file has %d rows, want %d", merged.NumRows(), row)
+	}
+	if !merged.Schema().Equal(schema) {
+		t.Errorf("merged schema %v, want %v", merged.Schema(), schema)
+	}
+
+	// The merged file holds the rows of the sources read one after another
+	offset := 0
+	for _, src := range srcs {
+		batch := readMergeTestFile(t, src)
+		for col := 0; col < schema.NumFields(); col++ {
+			want, got := batch.Column(col), merged.Column(col)
+			for i := 0; i < batch.NumRows(); i++ {
+				if !mergeTestValueEqual(want, i, got, offset+i) {
+					t.Fatalf("%s column %d row %d differs from merged row %d", src, col, i, offset+i)
+				}
+			}
+		}
+		offset += batch.NumRows()
+	}
+
+	// Pages are copied as they are: same count and sizes, all valid
+	reader, err := NewReader(dst)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	var srcPages, srcBytes int64
+	for _, src := range srcs {
+		r, err := NewReader(src)
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		for _, idx := range r.footer.PageIndexList.Indices {
+			srcPages++
+			srcBytes += int64(idx.Size)
+		}
+		r.Close()
+	}
+	var mergedBytes int64
+	for _, idx := range reader.footer.PageIndexList.Indices {
+		mergedBytes += int64(idx.Size)
+	}
+	if int64(len(reader.footer.PageIndexList.Indices)) != srcPages || mergedBytes != srcBytes {
+		t.Errorf("merged file has %d pages of %d bytes, sources %d of %d",
+			len(reader.footer.PageIndexList.Indices), mergedBytes, srcPages, srcBytes)
+	}
+	report, err := reader.ValidatePages(context.Background())
+	if err != nil || !report.OK() {
+		t.Errorf("ValidatePages = %+v, %v", report, err)
+	}
+}
+
+func mergeTestValueEqual(a arrow.Array, i int, b arrow.Array, j int) bool {
+	if a.IsValid(i) != b.IsValid(j) {
+		return false
+	}
+	if !a.IsValid(i) {
+		return true
+	}
+	switch arr := a.(type) {
+	case *arrow.Int32Array:
+		return arr.Value(i) == b.(*arrow.Int32Array).Value(j)
+	case *arrow.Float64Array:
+		return arr.Value(i) == b.(*arrow.Float64Array).Value(j)
+	case *arrow.FixedSizeListArray:
+		size := arr.ListSize()
+		av := arr.Values().(*arrow.Float32Array)
+		bv := b.(*arrow.FixedSizeListArray).Values().(*arrow.Float32Array)
+		for d := 0; d < size; d++ {
+			if av.Value(i*size+d) != bv.Value(j*size+d) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func TestMergeFiles_SchemaMismatch(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.lance")
+	writeMergeSource(t, base, mergeTestSchema(true, mergeTestDim), 0, []int{10})
+
+	reordered := arrow.NewSchema([]arrow.Field{
+		{Name: "score", Type: arrow.PrimFloat64(), Nullable: true},
+		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
+		{Name: "vector", Type: arrow.FixedSizeListOf(arrow.PrimFloat32(), mergeTestDim), Nullable: false},
+	}, nil)
+	cases := map[string]*arrow.Schema{
+		"nullability": mergeTestSchema(false, mergeTestDim),
+		"vector size": mergeTestSchema(true, mergeTestDim*2),
+	}
+	for name, schema := range cases {
+		other := filepath.Join(dir, name+".lance")
+		writeMergeSource(t, other, schema, 10, []int{10})
+
+		dst := filepath.Join(dir, name+"-merged.lance")
+		err := MergeFiles(dst, []string{base, other}, MergeOptions{})
+		if !lerrors.Is(err, lerrors.ErrSchemaMismatch) {
+			t.Errorf("%s: expected ErrSchemaMismatch, got %v", name, err)
+		}
+		assertNoMergeOutput(t, dir, dst)
+	}
+
+	// Field order is part of the schema; the header alone is enough here
+	other := filepath.Join(dir, "order.lance")
+	writer, err := NewWriter(other, reordered, nil)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	dst := filepath.Join(dir, "order-merged.lance")
+	if err := MergeFiles(dst, []string{base, other}, MergeOptions{}); !lerrors.Is(err, lerrors.ErrSchemaMismatch) {
+		t.Errorf("field order: expected ErrSchemaMismatch, got %v", err)
+	}
+	assertNoMergeOutput(t, dir, dst)
+
+	if err := MergeFiles(dst, nil, MergeOptions{}); !lerrors.Is(err, lerrors.ErrInvalidArgument) {
+		t.Errorf("no sources: expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestMergeFiles_VerifyChecksums(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.lance")
+	bad := filepath.Join(dir, "bad.lance")
+	writeMultiPageFile(t, good)
+	writeMultiPageFile(t, bad)
+	corruptPage(t, bad, 1, 2)
+
+	dst := filepath.Join(dir, "merged.lance")
+	err := MergeFiles(dst, []string{good, bad}, MergeOptions{VerifyChecksums: true})
+	if !lerrors.Is(err, lerrors.ErrCorruptedFile) {
+		t.Fatalf("expected ErrCorruptedFile, got %v", err)
+	}
+	assertNoMergeOutput(t, dir, dst)
+
+	// Without verification the damaged page is copied as it is
+	if err := MergeFiles(dst, []string{good, bad}, MergeOptions{}); err != nil {
+		t.Fatalf("MergeFiles failed: %v", err)
+	}
+	reader, err := NewReader(dst)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	report, err := reader.ValidatePages(context.Background())
+	if err != nil {
+		t.Fatalf("ValidatePages failed: %v", err)
+	}
+	if len(report.Failures) != 1 || report.Failures[0].Column != 1 || report.Failures[0].Page != corruptTestPages+2 {
+		t.Errorf("expected column 1 page %d to fail, got %v", corruptTestPages+2, report.Failures)
+	}
+}
+
+// assertNoMergeOutput checks that a failed merge left nothing in dir
+func assertNoMergeOutput(t *testing.T, dir, dst string) {
+	t.Helper()
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("%s exists after a failed merge", dst)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
@@ -8,14 +8,19 @@ import (
 )
 
 // PageReader handles deserialization of Page data to Arrays
-type PageReader struct{}
+type PageReader struct {
+	// SkipChecksum decodes pages without verifying their CRC32 first
+	SkipChecksum bool
+}
 
 // NewPageReader creates a new page reader
 func NewPageReader() *PageReader {
 	return &PageReader{}
 }
 
-// ReadPage converts a Page back into an Array.
+// ReadPage converts a Page back into an Array. The page data is checked
+// against its checksum first, unless SkipChecksum is set; a mismatch is an
+// ErrChecksumMismatch.
 func (r *PageReader) ReadPage(page *format.Page, dataType arrow.DataType) (arrow.Array, error) {
 	if page == nil {
 		return nil, lerrors.New(lerrors.ErrInvalidArgument).
@@ -31,6 +36,12 @@ func (r *PageReader) ReadPage(page *format.Page, dataType arrow.DataType) (arrow
 			Build()
 	}
 
+	if !r.SkipChecksum {
+		if err := page.VerifyChecksum(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get decoder based on encoding type
 	decoder, err := encoding.GetDecoder(page.Encoding)
 	if err != nil {
@@ -76,6 +87,7 @@ func (r *PageReader) ReadPage(page *format.Page, dataType arrow.DataType) (arrow
 
 // ReadPageFromData 直接从编码后的数据解码 Array（用于 AsyncIO 返回的数据）
 // 注意：data 是完整的 Page 字节流（包含 30 字节 header），需要跳过 header
+// The checksum in the header is verified as in ReadPage.
 func (r *PageReader) ReadPageFromData(data []byte, encodingType format.EncodingType, numValues int32, dataType arrow.DataType) (arrow.Array, error) {
 	// PageHeaderSize = 30 字节
 	const PageHeaderSize = 30
@@ -89,6 +101,12 @@ func (r *PageReader) ReadPageFromData(data []byte, encodingType format.EncodingT
 			Build()
 	}
 
+	if !r.SkipChecksum {
+		if err := format.VerifyEncodedPage(data); err != nil {
+			return nil, err
+		}
+	}
+
 	// 跳过 30 字节的 header，获取实际的编码数据
 	encodedData := data[PageHeaderSize:]
 
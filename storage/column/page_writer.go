@@ -36,15 +36,8 @@ func (w *PageWriter) WritePages(array arrow.Array, columnIndex int32) ([]*format
 			Build()
 	}
 
-	// Special handling for FixedSizeListArray - always use Zstd
-	// FixedSizeListArray is a container type, and individual encoders (BSS, RLE, etc.)
-	// don't know how to handle it. We could extract and encode the child array,
-	// but for simplicity and safety, we use Zstd which handles any data type.
-	if _, isFixedSizeList := array.(*arrow.FixedSizeListArray); isFixedSizeList {
-		return w.writeWithZstd(array, columnIndex)
-	}
-
 	// Step 1: Compute statistics for encoder selection
+	// (for FixedSizeListArray, over the flattened child values)
 	stats := encoding.ComputeStatistics(array)
 
 	// Step 2: Select best encoder based on statistics
@@ -70,25 +63,31 @@ func (w *PageWriter) WritePages(array arrow.Array, columnIndex int32) ([]*format
 	page := format.NewPage(columnIndex, format.PageTypeData, encodedData.Type)
 	page.NumValues = int32(array.Len())
 	page.SetData(encodedData.Data, int32(uncompressedSize))
+	page.Stats = pageStats(array, stats)
 
 	return []*format.Page{page}, nil
 }
 
-// writeWithZstd writes the array using Zstd compression.
-// Used for FixedSizeListArray and as fallback for other types.
-func (w *PageWriter) writeWithZstd(array arrow.Array, columnIndex int32) ([]*format.Page, error) {
-	zstdEncoder := encoding.NewZstdEncoder(w.factory.GetCompressionLevel())
-	encodedData, err := zstdEncoder.Encode(array)
-	if err != nil {
-		return nil, lerrors.EncodeFailed("zstd", array.DataType().Name(), err)
+// pageStats returns the footer stats of a page of array, or nil for
+// columns other than integer and float ones
+func pageStats(array arrow.Array, stats *encoding.Statistics) *format.PageStats {
+	ps := &format.PageStats{NullCount: int32(array.NullN())}
+	switch array.DataType().ID() {
+	case arrow.INT32, arrow.INT64:
+		if stats.MinInt != nil {
+			ps.HasRange = true
+			ps.MinInt, ps.MaxInt = *stats.MinInt, *stats.MaxInt
+		}
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+		ps.Float = true
+		if stats.MinFloat != nil {
+			ps.HasRange = true
+			ps.MinFloat, ps.MaxFloat = *stats.MinFloat, *stats.MaxFloat
+		}
+	default:
+		return nil
 	}
-
-	uncompressedSize := w.calculateUncompressedSize(array)
-	page := format.NewPage(columnIndex, format.PageTypeData, encodedData.Type)
-	page.NumValues = int32(array.Len())
-	page.SetData(encodedData.Data, int32(uncompressedSize))
-
-	return []*format.Page{page}, nil
+	return ps
 }
 
 // encodeWithFallback attempts to encode with the given encoder and falls back to Zstd if needed.
@@ -119,6 +118,14 @@ func (w *PageWriter) calculateUncompressedSize(array arrow.Array) int {
 	valueSize := encoding.GetValueSize(array.DataType().ID())
 	size := array.Len() * valueSize
 
+	// Variable-width values: offsets plus the value bytes
+	switch arr := array.(type) {
+	case *arrow.StringArray:
+		size = binarySize(&arr.BinaryArray)
+	case *arrow.BinaryArray:
+		size = binarySize(arr)
+	}
+
 	// Add null bitmap size if present
 	if array.NullN() > 0 {
 		bitmapSize := (array.Len() + 7) / 8
@@ -128,6 +135,12 @@ func (w *PageWriter) calculateUncompressedSize(array arrow.Array) int {
 	return size
 }
 
+// binarySize returns the size of the offsets and value bytes of arr
+func binarySize(arr *arrow.BinaryArray) int {
+	offsets := arr.Offsets()
+	return len(offsets)*4 + int(offsets[len(offsets)-1]-offsets[0])
+}
+
 // EstimatePageSize estimates the encoded size for an array without actually encoding.
 // This is useful for buffer pre-allocation and planning page splits.
 // Note: This is a best-effort estimate. Actual encoding may fall back to Zstd
@@ -167,13 +180,13 @@ func (w *PageWriter) EstimatePageSize(array arrow.Array) (int, error) {
 
 // encoderSupportsNulls checks if an encoder can handle null values.
 // Currently only Zstd supports null values. All specialized encoders
-// (RLE, BitPacking, BSS, Dictionary) reject arrays with nulls.
+// (RLE, BitPacking, BSS, Dictionary, Delta) reject arrays with nulls.
 func (w *PageWriter) encoderSupportsNulls(encoder encoding.Encoder) bool {
 	switch encoder.Type() {
 	case format.EncodingZstd:
 		return true
 	case format.EncodingRLE, format.EncodingBitPacked,
-		format.EncodingBSSEncoding, format.EncodingDictionary:
+		format.EncodingBSSEncoding, format.EncodingDictionary, format.EncodingDelta:
 		return false
 	default:
 		return false
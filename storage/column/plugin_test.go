@@ -0,0 +1,135 @@
+package column
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+const xorEncoding format.EncodingType = 200
+
+// xorEncoder is a toy user encoding for int32 columns: little-endian values
+// XORed with a fixed key
+type xorEncoder struct{}
+
+func (xorEncoder) Type() format.EncodingType              { return xorEncoding }
+func (xorEncoder) EstimateSize(array arrow.Array) int     { return array.Len() * 4 }
+func (xorEncoder) SupportsType(dtype arrow.DataType) bool { return dtype.ID() == arrow.INT32 }
+
+func (xorEncoder) Encode(array arrow.Array) (*encoding.EncodedData, error) {
+	ints, ok := array.(*arrow.Int32Array)
+	if !ok {
+		return nil, encoding.ErrUnsupportedType
+	}
+	if ints.NullN() > 0 {
+		return nil, encoding.ErrNullNotSupported
+	}
+	data := make([]byte, 4*ints.Len())
+	for i, v := range ints.Values() {
+		binary.LittleEndian.PutUint32(data[4*i:], uint32(v)^0x5a5a5a5a)
+	}
+	return &encoding.EncodedData{Data: data, Type: xorEncoding}, nil
+}
+
+type xorDecoder struct{}
+
+func (xorDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Array, error) {
+	values := make([]int32, len(data)/4)
+	for i := range values {
+		values[i] = int32(binary.LittleEndian.Uint32(data[4*i:]) ^ 0x5a5a5a5a)
+	}
+	return arrow.NewInt32Array(values, nil), nil
+}
+
+func TestUserEncoding_RoundTrip(t *testing.T) {
+	if err := encoding.RegisterEncoder(xorEncoding,
+		func(int) encoding.Encoder { return xorEncoder{} },
+		func() encoding.Decoder { return xorDecoder{} }); err != nil {
+		t.Fatalf("RegisterEncoder failed: %v", err)
+	}
+	defer encoding.UnregisterEncoder(xorEncoding)
+
+	// int32 columns use the plugin, the vectors keep the built-in encodings
+	factory := encoding.NewEncoderFactory(3).WithSelector(func(dtype arrow.DataType, _ *encoding.Statistics) (format.EncodingType, bool) {
+		return xorEncoding, dtype.ID() == arrow.INT32
+	})
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimInt32()},
+		{Name: "vector", Type: arrow.FixedSizeListOf(arrow.PrimFloat32(), 4)},
+	}, nil)
+	filename := filepath.Join(t.TempDir(), "xor.lance")
+	writer, err := NewWriter(filename, schema, factory)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	const rows = 300
+	ids := arrow.NewInt32Builder()
+	values := arrow.NewFloat32Builder()
+	for i := 0; i < rows; i++ {
+		ids.Append(int32(i * 7919))
+		for d := 0; d < 4; d++ {
+			values.Append(float32(i + d))
+		}
+	}
+	batch, err := arrow.NewRecordBatch(schema, rows, []arrow.Array{
+		ids.NewArray(),
+		arrow.NewFixedSizeListArray(schema.Field(1).Type.(*arrow.FixedSizeListType), values.NewArray(), nil),
+	})
+	if err != nil {
+		t.Fatalf("NewRecordBatch failed: %v", err)
+	}
+	if err := writer.WriteRecordBatch(batch); err != nil {
+		t.Fatalf("WriteRecordBatch failed: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	asyncIO := setupAsyncIO(t)
+	defer asyncIO.Close()
+	check := func(name string, reader *Reader) {
+		t.Helper()
+		defer reader.Close()
+		if enc := reader.footer.GetColumnPages(0)[0].Encoding; enc != xorEncoding {
+			t.Errorf("%s: id column recorded as %v", name, enc)
+		}
+		read, err := reader.ReadRecordBatch()
+		if err != nil {
+			t.Fatalf("%s: ReadRecordBatch failed: %v", name, err)
+		}
+		got := read.Column(0).(*arrow.Int32Array)
+		for i := 0; i < rows; i++ {
+			if got.Value(i) != int32(i*7919) {
+				t.Fatalf("%s: id[%d] = %d", name, i, got.Value(i))
+			}
+		}
+	}
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	check("sync", reader)
+	reader, err = NewReaderWithAsyncIO(filename, asyncIO)
+	if err != nil {
+		t.Fatalf("NewReaderWithAsyncIO failed: %v", err)
+	}
+	check("async", reader)
+
+	// Without the plugin the file cannot be read, and the error says why
+	encoding.UnregisterEncoder(xorEncoding)
+	reader, err = NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	_, err = reader.ReadRecordBatch()
+	if !lerrors.Is(err, lerrors.ErrUnsupportedType) || !strings.Contains(err.Error(), "User(200)") {
+		t.Errorf("read without the plugin = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package column
+
+import (
+	"context"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// ValueRange is a predicate on an integer or float column, true of the
+// non-null values v with Min <= v <= Max. Use math.Inf for an open bound;
+// integers are compared as float64.
+type ValueRange struct {
+	Min, Max float64
+}
+
+// contains reports whether row i of values, a column of a type
+// ReadColumnsWhere accepts, is in the range
+func (p ValueRange) contains(values arrow.Array, i int) bool {
+	if values.IsNull(i) {
+		return false
+	}
+	var v float64
+	switch a := values.(type) {
+	case *arrow.Int32Array:
+		v = float64(a.Value(i))
+	case *arrow.Int64Array:
+		v = float64(a.Value(i))
+	case *arrow.Float16Array:
+		v = float64(a.Float32(i))
+	case *arrow.Float32Array:
+		v = float64(a.Value(i))
+	case *arrow.Float64Array:
+		v = a.Value(i)
+	}
+	return v >= p.Min && v <= p.Max
+}
+
+// ReadColumnsWhere reads the rows of all columns whose value in column col
+// is in pred, in file order. Pages of col whose footer stats rule pred out
+// are skipped along with the rows of the other columns beside them; files
+// before V1.3 have no stats and are read whole.
+func (r *Reader) ReadColumnsWhere(col string, pred ValueRange) (*arrow.RecordBatch, error) {
+	if r.closed {
+		return nil, lerrors.New(lerrors.ErrInvalidArgument).
+			Op("read_columns_where").
+			Context("message", "reader is closed").
+			Build()
+	}
+
+	schema, cols := r.view()
+	field, colIdx, ok := schema.FieldByName(col)
+	if !ok {
+		return nil, lerrors.ColumnNotFound(r.file.Name(), col, r.fieldNames())
+	}
+	switch field.Type.ID() {
+	case arrow.INT32, arrow.INT64, arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+	default:
+		return nil, lerrors.New(lerrors.ErrUnsupportedType).
+			Op("read_columns_where").
+			Context("column", col).
+			Context("data_type", field.Type.Name()).
+			Context("message", "predicates apply to integer and float columns").
+			Build()
+	}
+
+	// Read each run of pages that may match, and keep its matching rows. A
+	// column the file lacks is all null and matches none.
+	parts := make([][]arrow.Array, schema.NumFields())
+	var corrupt []CorruptPage
+	numRows := 0
+	var runs [][2]int64
+	if cols[colIdx] >= 0 {
+		runs = candidateRuns(r.footer.GetColumnPages(int32(cols[colIdx])), pred)
+	}
+	for _, run := range runs {
+		batch, err := r.readRecordBatch(context.Background(), schema, cols, run[0], run[1]-run[0], r.options.Priority)
+		if err != nil {
+			return nil, err
+		}
+		if r.report != nil {
+			corrupt = append(corrupt, r.report.Pages...)
+		}
+
+		values := batch.Column(colIdx)
+		for i := 0; i < values.Len(); {
+			if !pred.contains(values, i) {
+				i++
+				continue
+			}
+			j := i + 1
+			for j < values.Len() && pred.contains(values, j) {
+				j++
+			}
+			for c := range parts {
+				parts[c] = append(parts[c], arrow.SliceArray(batch.Column(c), i, j-i))
+			}
+			numRows += j - i
+			i = j
+		}
+	}
+	r.report = nil
+	if len(corrupt) > 0 {
+		r.report = &CorruptionReport{Pages: corrupt}
+	}
+
+	columns := make([]arrow.Array, len(parts))
+	for c, arrays := range parts {
+		var err error
+		if len(arrays) == 0 {
+			columns[c], err = nullArray(schema.Field(c).Type, 0)
+		} else {
+			columns[c], err = r.mergeArrays(arrays, schema.Field(c).Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return arrow.NewRecordBatch(schema, numRows, columns)
+}
+
+// candidateRuns returns the row ranges [start, end) of the runs of
+// consecutive pages that may hold values in pred: those whose stats do or
+// that have none
+func candidateRuns(pageIndices []format.PageIndex, pred ValueRange) [][2]int64 {
+	var runs [][2]int64
+	var row int64
+	for _, idx := range pageIndices {
+		next := row + int64(idx.NumValues)
+		if idx.Stats == nil || idx.Stats.MayContain(pred.Min, pred.Max) {
+			if n := len(runs); n > 0 && runs[n-1][1] == row {
+				runs[n-1][1] = next
+			} else {
+				runs = append(runs, [2]int64{row, next})
+			}
+		}
+		row = next
+	}
+	return runs
+}
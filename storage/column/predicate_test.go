@@ -0,0 +1,168 @@
+package column
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+const (
+	predicateTestPages       = 10
+	predicateTestRowsPerPage = 1000
+)
+
+// writeSortedFile writes a sorted int64 id column and a nullable float64
+// score of id/10, null for every fifth row, one page per 1000 rows
+func writeSortedFile(t *testing.T, filename string) {
+	t.Helper()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimInt64(), Nullable: false},
+		{Name: "score", Type: arrow.PrimFloat64(), Nullable: true},
+	}, nil)
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	for p := 0; p < predicateTestPages; p++ {
+		ids := make([]int64, predicateTestRowsPerPage)
+		scores := make([]float64, predicateTestRowsPerPage)
+		valid := arrow.NewBitmap(predicateTestRowsPerPage)
+		for i := range ids {
+			row := p*predicateTestRowsPerPage + i
+			ids[i] = int64(row)
+			scores[i] = float64(row) / 10
+			if row%5 != 0 {
+				valid.Set(i)
+			}
+		}
+		batch, err := arrow.NewRecordBatch(schema, predicateTestRowsPerPage,
+			[]arrow.Array{arrow.NewInt64Array(ids, nil), arrow.NewFloat64Array(scores, valid)})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("writer.Close() failed: %v", err)
+	}
+}
+
+// rewriteFooterV12 turns filename into a V1.2 file, whose footer has no
+// page stats
+func rewriteFooterV12(t *testing.T, filename string) {
+	t.Helper()
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	footer := reader.footer
+	reader.Close()
+	footer.Version = format.V1_2.Encoded()
+
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+	if _, err := file.Seek(info.Size()-format.FooterSize, 0); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if _, err := footer.WriteTo(file); err != nil {
+		t.Fatalf("Footer WriteTo failed: %v", err)
+	}
+}
+
+// readWhere runs ReadColumnsWhere on a fresh reader of filename and returns
+// the rows and the number of pages decoded
+func readWhere(t *testing.T, filename, col string, pred ValueRange) (*arrow.RecordBatch, int64) {
+	t.Helper()
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	batch, err := reader.ReadColumnsWhere(col, pred)
+	if err != nil {
+		t.Fatalf("ReadColumnsWhere(%s, %+v) failed: %v", col, pred, err)
+	}
+	return batch, reader.PagesRead()
+}
+
+func TestReader_ReadColumnsWhere(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sorted.lance")
+	writeSortedFile(t, filename)
+
+	// id > 8500 is in the last two pages of each column
+	batch, pages := readWhere(t, filename, "id", ValueRange{Min: 8501, Max: math.Inf(1)})
+	if pages != 4 {
+		t.Errorf("Decoded %d pages, want 4", pages)
+	}
+	if batch.NumRows() != 1499 {
+		t.Fatalf("Got %d rows, want 1499", batch.NumRows())
+	}
+	ids := batch.Column(0).(*arrow.Int64Array)
+	scores := batch.Column(1).(*arrow.Float64Array)
+	for i := 0; i < batch.NumRows(); i++ {
+		row := int64(8501 + i)
+		if ids.Value(i) != row || scores.IsNull(i) != (row%5 == 0) {
+			t.Fatalf("Row %d: id %d, score null %v", i, ids.Value(i), scores.IsNull(i))
+		}
+		if !scores.IsNull(i) && scores.Value(i) != float64(row)/10 {
+			t.Fatalf("Row %d: score %v", i, scores.Value(i))
+		}
+	}
+
+	// A range within one page, on the float column, leaves out nulls
+	batch, pages = readWhere(t, filename, "score", ValueRange{Min: 300, Max: 301})
+	if pages != 2 || batch.NumRows() != 8 {
+		t.Errorf("score in [300, 301]: %d rows from %d pages, want 8 from 2", batch.NumRows(), pages)
+	}
+
+	// and ranges matching nothing read nothing
+	batch, pages = readWhere(t, filename, "id", ValueRange{Min: 20000, Max: 30000})
+	if pages != 0 || batch.NumRows() != 0 || batch.NumCols() != 2 {
+		t.Errorf("Empty range: %d rows of %d columns from %d pages", batch.NumRows(), batch.NumCols(), pages)
+	}
+
+	// Merged files keep the stats
+	merged := filepath.Join(t.TempDir(), "merged.lance")
+	if err := MergeFiles(merged, []string{filename}, MergeOptions{}); err != nil {
+		t.Fatalf("MergeFiles failed: %v", err)
+	}
+	if batch, pages = readWhere(t, merged, "id", ValueRange{Min: 8501, Max: math.Inf(1)}); pages != 4 || batch.NumRows() != 1499 {
+		t.Errorf("Merged file: %d rows from %d pages", batch.NumRows(), pages)
+	}
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	if _, err := reader.ReadColumnsWhere("missing", ValueRange{}); !lerrors.Is(err, lerrors.ErrColumnNotFound) {
+		t.Errorf("Expected ErrColumnNotFound, got %v", err)
+	}
+}
+
+func TestReader_ReadColumnsWhereWithoutStats(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "old.lance")
+	writeSortedFile(t, filename)
+	rewriteFooterV12(t, filename)
+
+	// Files without stats are read whole, with the same rows
+	batch, pages := readWhere(t, filename, "id", ValueRange{Min: 8501, Max: math.Inf(1)})
+	if pages != 2*predicateTestPages {
+		t.Errorf("Decoded %d pages, want %d", pages, 2*predicateTestPages)
+	}
+	ids := batch.Column(0).(*arrow.Int64Array)
+	if batch.NumRows() != 1499 || ids.Value(0) != 8501 || ids.Value(1498) != 9999 {
+		t.Errorf("Got %d rows from %d to %d", batch.NumRows(), ids.Value(0), ids.Value(batch.NumRows()-1))
+	}
+}
@@ -1,6 +1,7 @@
 package column
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"hash/fnv"
@@ -10,9 +11,9 @@ import (
 	"github.com/wzqhbustb/vego/storage/format"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	lanceio "github.com/wzqhbustb/vego/storage/io" // 使用别名避免冲突
 )
@@ -31,6 +32,18 @@ type Reader struct {
 	fileID       string // 在 AsyncIO 中注册的文件 ID
 	useAsync     bool   // 是否启用异步模式
 	asyncEnabled bool   // AsyncIO 是否可用（文件已注册）
+
+	options    ReaderOption
+	cols       []int                // file column of each field of options.Schema, -1 if absent
+	priority   lanceio.Priority     // AsyncIO priority of the current read
+	corruption *corruptionCollector // pages skipped by the current read
+	report     *CorruptionReport    // pages skipped by the last read
+
+	batches   []int64 // first row of each batch of Next, once computed
+	nextBatch int     // batch the next call to Next returns
+
+	pagesRead   atomic.Int64 // pages decoded over the Reader's lifetime
+	readsIssued atomic.Int64 // page reads issued over the Reader's lifetime
 }
 
 // NewReader creates a new column reader（同步模式）
@@ -129,6 +142,28 @@ func NewReaderWithAsyncIO(filename string, asyncIO *lanceio.AsyncIO) (*Reader, e
 	return reader, nil
 }
 
+// NewReaderWithOptions creates a reader with the given options. asyncIO may
+// be nil to read synchronously.
+func NewReaderWithOptions(filename string, asyncIO *lanceio.AsyncIO, opts ReaderOption) (*Reader, error) {
+	reader, err := NewReaderWithAsyncIO(filename, asyncIO)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Schema != nil {
+		cols, err := projectSchema(filename, reader.header.Schema, opts.Schema)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		reader.cols = cols
+	}
+	reader.options = opts
+	reader.priority = opts.Priority
+	reader.pageReader.SkipChecksum = opts.SkipChecksum
+	reader.corruption = &corruptionCollector{}
+	return reader, nil
+}
+
 // generateFileID 生成唯一的文件 ID
 // 格式: filename_timestamp_counter
 var fileIDCounter atomic.Uint64
@@ -181,8 +216,15 @@ func (r *Reader) readFooter() error {
 	return nil
 }
 
-// Schema returns the schema of the file
+// Schema returns the schema of the batches the Reader reads: that of the
+// file, or ReaderOption.Schema if set
 func (r *Reader) Schema() *arrow.Schema {
+	schema, _ := r.view()
+	return schema
+}
+
+// FileSchema returns the schema the file was written with
+func (r *Reader) FileSchema() *arrow.Schema {
 	return r.header.Schema
 }
 
@@ -194,6 +236,27 @@ func (r *Reader) NumRows() int64 {
 // ReadRecordBatch reads all data and returns a RecordBatch
 // 根据 Reader 配置自动选择同步或异步模式
 func (r *Reader) ReadRecordBatch() (*arrow.RecordBatch, error) {
+	return r.ReadRecordBatchContext(context.Background())
+}
+
+// ReadRecordBatchContext is ReadRecordBatch bounded by ctx: once ctx is
+// done, outstanding page reads are abandoned and ctx.Err() is returned. A
+// ctx without deadline gets ReaderOption.Timeout.
+func (r *Reader) ReadRecordBatchContext(ctx context.Context) (*arrow.RecordBatch, error) {
+	if r.closed {
+		return nil, lerrors.New(lerrors.ErrInvalidArgument).
+			Op("read_record_batch").
+			Context("message", "reader is closed").
+			Build()
+	}
+
+	schema, cols := r.view()
+	return r.readRecordBatch(ctx, schema, cols, 0, r.header.NumRows, r.options.Priority)
+}
+
+// ReadRecordBatchWithPriority is ReadRecordBatch with the AsyncIO priority
+// of this read overriding the Reader's ReaderOption.Priority
+func (r *Reader) ReadRecordBatchWithPriority(priority lanceio.Priority) (*arrow.RecordBatch, error) {
 	if r.closed {
 		return nil, lerrors.New(lerrors.ErrInvalidArgument).
 			Op("read_record_batch").
@@ -201,25 +264,226 @@ func (r *Reader) ReadRecordBatch() (*arrow.RecordBatch, error) {
 			Build()
 	}
 
-	schema := r.header.Schema
-	numColumns := schema.NumFields()
+	schema, cols := r.view()
+	return r.readRecordBatch(context.Background(), schema, cols, 0, r.header.NumRows, priority)
+}
+
+// view returns the schema reads return and the file column of each of its
+// fields, -1 for one the file lacks: ReaderOption.Schema if set, else the
+// file schema and all its columns
+func (r *Reader) view() (*arrow.Schema, []int) {
+	if r.cols != nil {
+		return r.options.Schema, r.cols
+	}
+	return r.header.Schema, r.allColumns()
+}
+
+// allColumns returns the indexes of all file columns
+func (r *Reader) allColumns() []int {
+	cols := make([]int, r.header.Schema.NumFields())
+	for i := range cols {
+		cols[i] = i
+	}
+	return cols
+}
 
-	columns := make([]arrow.Array, numColumns)
+// ReadRecordBatchWithSchema reads the columns of schema, resolved by field
+// ID rather than position, so files written by older or newer versions of
+// a schema stay readable: columns the file has but schema does not are not
+// read, nullable fields the file lacks read as all null, and renamed fields
+// keep their data. Incompatible fields, such as one whose type changed,
+// are an ErrSchemaMismatch that lists them all.
+func (r *Reader) ReadRecordBatchWithSchema(schema *arrow.Schema) (*arrow.RecordBatch, error) {
+	if r.closed {
+		return nil, lerrors.New(lerrors.ErrInvalidArgument).
+			Op("read_record_batch").
+			Context("message", "reader is closed").
+			Build()
+	}
+
+	cols, err := projectSchema(r.file.Name(), r.header.Schema, schema)
+	if err != nil {
+		return nil, err
+	}
+	return r.readRecordBatch(context.Background(), schema, cols, 0, r.header.NumRows, r.options.Priority)
+}
+
+// ReadColumns reads the named columns, in the order given, without reading
+// the pages of the others. With AsyncIO the columns are fetched
+// concurrently. An unknown name is an ErrColumnNotFound.
+func (r *Reader) ReadColumns(names []string) (*arrow.RecordBatch, error) {
+	return r.ReadColumnsRangeContext(context.Background(), names, 0, r.NumRows())
+}
+
+// ReadColumnsContext is ReadColumns bounded by ctx, as
+// ReadRecordBatchContext
+func (r *Reader) ReadColumnsContext(ctx context.Context, names []string) (*arrow.RecordBatch, error) {
+	return r.ReadColumnsRangeContext(ctx, names, 0, r.NumRows())
+}
+
+// ReadRowRange reads count rows of all columns from row start on, reading
+// only the pages that hold them. A window past the end of the file is an
+// ErrInvalidArgument.
+func (r *Reader) ReadRowRange(start, count int64) (*arrow.RecordBatch, error) {
+	return r.ReadColumnsRangeContext(context.Background(), r.fieldNames(), start, count)
+}
+
+// ReadColumnsRange is ReadColumns of rows [start, start+count) only, as
+// ReadRowRange. Pages partly in the window are decoded whole and sliced.
+func (r *Reader) ReadColumnsRange(names []string, start, count int64) (*arrow.RecordBatch, error) {
+	return r.ReadColumnsRangeContext(context.Background(), names, start, count)
+}
+
+// ReadColumnsRangeContext is ReadColumnsRange bounded by ctx, as
+// ReadRecordBatchContext
+func (r *Reader) ReadColumnsRangeContext(ctx context.Context, names []string, start, count int64) (*arrow.RecordBatch, error) {
+	if r.closed {
+		return nil, lerrors.New(lerrors.ErrInvalidArgument).
+			Op("read_columns").
+			Context("message", "reader is closed").
+			Build()
+	}
+	if start < 0 || count < 0 || start > r.header.NumRows-count {
+		return nil, lerrors.New(lerrors.ErrInvalidArgument).
+			Op("read_columns").
+			Context("start", start).
+			Context("count", count).
+			Context("num_rows", r.header.NumRows).
+			Context("message", "row range out of bounds").
+			Build()
+	}
+
+	view, viewCols := r.view()
+	fields := make([]arrow.Field, len(names))
+	cols := make([]int, len(names))
+	for i, name := range names {
+		field, idx, ok := view.FieldByName(name)
+		if !ok {
+			return nil, lerrors.ColumnNotFound(r.file.Name(), name, r.fieldNames())
+		}
+		fields[i], cols[i] = field, viewCols[idx]
+	}
+	schema := arrow.NewSchema(fields, view.Metadata())
+	return r.readRecordBatch(ctx, schema, cols, start, count, r.options.Priority)
+}
+
+// NumBatches returns the number of batches Next yields
+func (r *Reader) NumBatches() int {
+	return len(r.batchStarts())
+}
+
+// Next returns the next batch of rows of the file, or io.EOF after the
+// last, so that large files can be processed a batch at a time. Batches
+// end where a page starts in every column: one per WriteRecordBatch call
+// for files of Writer, kept by MergeFiles. Each call reads only the pages of
+// its batch; CorruptionReport then covers that batch. A failed batch is
+// returned again by the next call.
+func (r *Reader) Next() (*arrow.RecordBatch, error) {
+	if r.closed {
+		return nil, lerrors.New(lerrors.ErrInvalidArgument).
+			Op("next").
+			Context("message", "reader is closed").
+			Build()
+	}
+
+	starts := r.batchStarts()
+	if r.nextBatch >= len(starts) {
+		return nil, io.EOF
+	}
+	start, end := starts[r.nextBatch], r.header.NumRows
+	if r.nextBatch+1 < len(starts) {
+		end = starts[r.nextBatch+1]
+	}
+	schema, cols := r.view()
+	batch, err := r.readRecordBatch(context.Background(), schema, cols, start, end-start, r.options.Priority)
+	if err != nil {
+		return nil, err
+	}
+	r.nextBatch++
+	return batch, nil
+}
+
+// batchStarts returns the first row of each batch of Next, the rows at
+// which every column starts a page
+func (r *Reader) batchStarts() []int64 {
+	if r.batches != nil {
+		return r.batches
+	}
+	numCols := r.header.Schema.NumFields()
+	columnsAt := make(map[int64]int)
+	for col := 0; col < numCols; col++ {
+		var row int64
+		for _, idx := range r.footer.GetColumnPages(int32(col)) {
+			columnsAt[row]++
+			row += int64(idx.NumValues)
+		}
+	}
+
+	starts := make([]int64, 0)
+	for row, n := range columnsAt {
+		if n == numCols && row < r.header.NumRows {
+			starts = append(starts, row)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	r.batches = starts
+	return starts
+}
+
+// fieldNames returns the names of the fields of Schema
+func (r *Reader) fieldNames() []string {
+	schema := r.Schema()
+	names := make([]string, schema.NumFields())
+	for i := range names {
+		names[i] = schema.Field(i).Name
+	}
+	return names
+}
+
+// readRecordBatch reads rows [start, start+count) of file column cols[i] as
+// column i of schema, or nulls where cols[i] is -1, with async page reads
+// at priority, until ctx is done
+func (r *Reader) readRecordBatch(ctx context.Context, schema *arrow.Schema, cols []int, start, count int64, priority lanceio.Priority) (*arrow.RecordBatch, error) {
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	numRows := int(count)
+	columns := make([]arrow.Array, len(cols))
 	var readErr error
 
+	r.priority = priority
+	r.corruption = &corruptionCollector{}
+	r.report = nil
+
 	if r.useAsync && r.asyncEnabled {
 		// 异步模式：并发读取所有列
-		readErr = r.readColumnsAsync(columns)
+		readErr = r.readColumnsAsync(ctx, columns, cols, start, count)
 	} else {
 		// 同步模式：顺序读取
-		readErr = r.readColumnsSync(columns)
+		readErr = r.readColumnsSync(ctx, columns, cols, start, count)
 	}
 
 	if readErr != nil {
+		// Pages abandoned for ctx fail as they may; report why
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		return nil, readErr
 	}
+	r.report = r.corruption.report()
 
-	batch, err := arrow.NewRecordBatch(schema, int(r.header.NumRows), columns)
+	for i, col := range cols {
+		if col >= 0 {
+			continue
+		}
+		nulls, err := nullArray(schema.Field(i).Type, numRows)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = nulls
+	}
+
+	batch, err := arrow.NewRecordBatch(schema, numRows, columns)
 	if err != nil {
 		return nil, lerrors.New(lerrors.ErrInvalidArgument).
 			Op("create_record_batch").
@@ -231,39 +495,73 @@ func (r *Reader) ReadRecordBatch() (*arrow.RecordBatch, error) {
 	return batch, nil
 }
 
-// readColumnsSync 同步读取所有列
-func (r *Reader) readColumnsSync(columns []arrow.Array) error {
-	schema := r.header.Schema
-	for colIdx := 0; colIdx < schema.NumFields(); colIdx++ {
-		column, err := r.readColumn(int32(colIdx))
+// readContext returns ctx, bounded by ReaderOption.Timeout if it has no
+// deadline
+func (r *Reader) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	timeout := r.options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReadTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// PagesRead returns the number of pages the Reader has read and decoded,
+// which shows how many pages projections, row ranges and predicates skip
+func (r *Reader) PagesRead() int64 {
+	return r.pagesRead.Load()
+}
+
+// ReadsIssued returns the number of reads the Reader has issued for pages:
+// one per page when reading synchronously, fewer with AsyncIO when nearby
+// pages are coalesced into one read.
+func (r *Reader) ReadsIssued() int64 {
+	return r.readsIssued.Load()
+}
+
+// CorruptionReport returns the pages replaced by nulls during the last
+// ReadRecordBatch, or nil if none were. It is always nil in strict mode.
+func (r *Reader) CorruptionReport() *CorruptionReport {
+	return r.report
+}
+
+// readColumnsSync 同步读取所有列: rows [start, start+count) of file column
+// cols[i] into columns[i], skipping those where cols[i] is -1
+func (r *Reader) readColumnsSync(ctx context.Context, columns []arrow.Array, cols []int, start, count int64) error {
+	for i, colIdx := range cols {
+		if colIdx < 0 {
+			continue
+		}
+		column, err := r.readColumn(ctx, int32(colIdx), start, count)
 		if err != nil {
-			lerrors.New(lerrors.ErrColumnNotFound).
+			return lerrors.New(lerrors.ErrColumnNotFound).
 				Op("read_columns_sync").
 				Context("column_index", colIdx).
 				Wrap(err).
 				Build()
 		}
-		columns[colIdx] = column
+		columns[i] = column
 	}
 	return nil
 }
 
-// readColumnsAsync 异步并发读取所有列
-func (r *Reader) readColumnsAsync(columns []arrow.Array) error {
-	schema := r.header.Schema
-	numColumns := schema.NumFields()
-
+// readColumnsAsync 异步并发读取所有列, as readColumnsSync
+func (r *Reader) readColumnsAsync(ctx context.Context, columns []arrow.Array, cols []int, start, count int64) error {
 	// 使用 WaitGroup 等待所有列读取完成
 	var wg sync.WaitGroup
-	wg.Add(numColumns)
-
-	errChan := make(chan error, numColumns)
+	errChan := make(chan error, len(cols))
 
-	for colIdx := 0; colIdx < numColumns; colIdx++ {
-		go func(idx int) {
+	for i, colIdx := range cols {
+		if colIdx < 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(i, idx int) {
 			defer wg.Done()
 
-			column, err := r.readColumnAsync(int32(idx))
+			column, err := r.readColumnAsync(ctx, int32(idx), start, count)
 			if err != nil {
 				errChan <- lerrors.New(lerrors.ErrColumnNotFound).
 					Op("read_columns_async").
@@ -272,17 +570,8 @@ func (r *Reader) readColumnsAsync(columns []arrow.Array) error {
 					Build()
 				return
 			}
-			// 改进：添加边界检查
-			if idx >= len(columns) {
-				errChan <- lerrors.New(lerrors.ErrInvalidArgument).
-					Op("read_columns_async").
-					Context("column_index", idx).
-					Context("message", "column index out of bounds").
-					Build()
-				return
-			}
-			columns[idx] = column
-		}(colIdx)
+			columns[i] = column
+		}(i, colIdx)
 	}
 
 	wg.Wait()
@@ -298,8 +587,8 @@ func (r *Reader) readColumnsAsync(columns []arrow.Array) error {
 	return nil
 }
 
-// readColumn reads a single column from the file
-func (r *Reader) readColumn(columnIndex int32) (arrow.Array, error) {
+// readColumn reads rows [start, start+count) of a single column from the file
+func (r *Reader) readColumn(ctx context.Context, columnIndex int32, start, count int64) (arrow.Array, error) {
 	pageIndices := r.footer.GetColumnPages(columnIndex)
 	if len(pageIndices) == 0 {
 		return nil, lerrors.PageNotFound("", columnIndex, 0)
@@ -314,34 +603,18 @@ func (r *Reader) readColumn(columnIndex int32) (arrow.Array, error) {
 	}
 	field := r.header.Schema.Field(int(columnIndex))
 
-	// 读取所有 pages
-	var arrays []arrow.Array
-	for _, pageIdx := range pageIndices {
-		page, err := r.readPage(pageIdx)
-		if err != nil {
-			return nil, lerrors.IO("read_page", "", err)
-		}
-
-		array, err := r.pageReader.ReadPage(page, field.Type)
-		if err != nil {
-			return nil, lerrors.New(lerrors.ErrDecodeFailed).
-				Op("deserialize_page").
-				Wrap(err).
-				Build()
-		}
-
-		arrays = append(arrays, array)
-	}
-
-	if len(arrays) == 1 {
-		return arrays[0], nil
+	// 只读取窗口内的 pages
+	pageIndices, first := pagesInRange(pageIndices, start, count)
+	arrays, err := r.readPagesSync(ctx, pageIndices, field.Type)
+	if err != nil {
+		return nil, err
 	}
 
-	return r.mergeArrays(arrays, field.Type)
+	return r.sliceRows(arrays, field.Type, start-first, count)
 }
 
-// 批量异步读取所有 pages
-func (r *Reader) readColumnAsync(columnIndex int32) (arrow.Array, error) {
+// 批量异步读取窗口内的 pages
+func (r *Reader) readColumnAsync(ctx context.Context, columnIndex int32, start, count int64) (arrow.Array, error) {
 	pageIndices := r.footer.GetColumnPages(columnIndex)
 	if len(pageIndices) == 0 {
 		return nil, fmt.Errorf("no pages found for column %d", columnIndex)
@@ -350,120 +623,169 @@ func (r *Reader) readColumnAsync(columnIndex int32) (arrow.Array, error) {
 	field := r.header.Schema.Field(int(columnIndex))
 
 	// 使用已有的 readPagesAsync 批量读取
-	arrays, err := r.readPagesAsync(pageIndices, field.Type)
+	pageIndices, first := pagesInRange(pageIndices, start, count)
+	arrays, err := r.readPagesAsync(ctx, pageIndices, field.Type)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(arrays) == 1 {
-		return arrays[0], nil
-	}
-
-	return r.mergeArrays(arrays, field.Type)
+	return r.sliceRows(arrays, field.Type, start-first, count)
 }
 
-// readPageAsyncWithEncoding 使用指定编码异步读取 page
-func (r *Reader) readPageAsyncWithEncoding(pageIdx format.PageIndex, encoding format.EncodingType, dataType arrow.DataType) (arrow.Array, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// 读取 page 原始数据
-	resultCh := r.asyncIO.Read(ctx, r.fileID, pageIdx.Offset, pageIdx.Size)
-
-	select {
-	case result := <-resultCh:
-		if result.Error != nil {
-			return nil, result.Error
+// pagesInRange returns the pages of a column holding rows
+// [start, start+count), and the first row of the first of them
+func pagesInRange(pageIndices []format.PageIndex, start, count int64) ([]format.PageIndex, int64) {
+	var row, first int64
+	lo, hi := len(pageIndices), len(pageIndices)
+	for i, idx := range pageIndices {
+		next := row + int64(idx.NumValues)
+		if lo == len(pageIndices) && next > start {
+			lo, first = i, row
 		}
+		if row >= start+count {
+			hi = i
+			break
+		}
+		row = next
+	}
+	if count == 0 {
+		return nil, start
+	}
+	return pageIndices[lo:hi], first
+}
 
-		// 直接解码数据
-		return r.pageReader.ReadPageFromData(result.Data, encoding, pageIdx.NumValues, dataType)
-
-	case <-ctx.Done():
-		return nil, lerrors.New(lerrors.ErrTimeout).
-			Op("read_page_async_with_encoding").
-			Context("message", "timeout reading page").
+// sliceRows merges the pages read for a window and slices off the rows
+// before offset and after offset+count, of pages partly in it
+func (r *Reader) sliceRows(arrays []arrow.Array, dataType arrow.DataType, offset, count int64) (arrow.Array, error) {
+	if len(arrays) == 0 {
+		return nullArray(dataType, 0)
+	}
+	merged, err := r.mergeArrays(arrays, dataType)
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 && int64(merged.Len()) == count {
+		return merged, nil
+	}
+	if offset+count > int64(merged.Len()) {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("slice_rows").
+			Context("message", "pages hold fewer rows than the footer").
 			Build()
 	}
+	return arrow.SliceArray(merged, int(offset), int(count)), nil
 }
 
 // readPagesAsync 批量异步读取多个 Page
-// 【修改】修复 ReadPages 使用方式，避免超时
-// readPagesAsync 批量异步读取多个 Page
-// 【修改】使用 SubmitBatch 批量提交，避免过多 goroutine
-func (r *Reader) readPagesAsync(pageIndices []format.PageIndex, dataType arrow.DataType) ([]arrow.Array, error) {
+// Pages close to each other in the file are fetched by one read, see
+// ReaderOption.CoalesceGap, and sliced apart again for decoding.
+func (r *Reader) readPagesAsync(ctx context.Context, pageIndices []format.PageIndex, dataType arrow.DataType) ([]arrow.Array, error) {
 	if !r.useAsync || !r.asyncEnabled {
-		return r.readPagesSync(pageIndices, dataType)
+		return r.readPagesSync(ctx, pageIndices, dataType)
 	}
 
 	if len(pageIndices) == 0 {
 		return []arrow.Array{}, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	arrays := make([]arrow.Array, len(pageIndices))
 	errChan := make(chan error, len(pageIndices))
 	var wg sync.WaitGroup
 
-	// 【修改】限制并发度，避免过多 goroutine
+	// 限制并发度，避免过多 goroutine：reads and decodes are limited
+	// separately so that a read never waits for the decodes of another
 	const maxConcurrency = 8
-	semaphore := make(chan struct{}, maxConcurrency)
+	readSem := make(chan struct{}, maxConcurrency)
+	decodeSem := make(chan struct{}, maxConcurrency)
+
+	fail := func(idx int, err error) {
+		if r.options.SkipCorruptPages {
+			arrays[idx] = r.skipCorruptPage(pageIndices, idx, dataType, err)
+			return
+		}
+		errChan <- err
+	}
 
-	for i, pIdx := range pageIndices {
+	decode := func(idx int, data []byte) {
+		defer wg.Done()
+		defer func() { <-decodeSem }()
+
+		pageIdx := pageIndices[idx]
+		array, err := r.pageReader.ReadPageFromData(data, pageIdx.Encoding, pageIdx.NumValues, dataType)
+		if err != nil {
+			page, _ := r.pagePosition(pageIdx)
+			fail(idx, lerrors.New(lerrors.ErrDecodeFailed).
+				Op("decode_page_async").
+				Context("page_index", idx).
+				Context("column", pageIdx.ColumnIndex).
+				Context("page", page).
+				Wrap(err).
+				Build())
+			return
+		}
+		arrays[idx] = array
+		r.pagesRead.Add(1)
+	}
+
+	for _, run := range coalescePages(pageIndices, r.coalesceGap()) {
+		// Issue no more reads once ctx is done
+		select {
+		case readSem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
-		semaphore <- struct{}{} // 获取信号量
 
-		go func(idx int, pageIdx format.PageIndex) {
+		go func(run pageRun) {
 			defer wg.Done()
-			defer func() { <-semaphore }() // 释放信号量
 
-			// 【修改】使用单个 Read，但共享同一个 AsyncIO 调度器
-			resultCh := r.asyncIO.Read(ctx, r.fileID, pageIdx.Offset, pageIdx.Size)
+			r.readsIssued.Add(1)
+			resultCh := r.asyncIO.ReadWithPriority(ctx, r.fileID, run.offset, run.size, r.priority)
 
+			var result lanceio.IOResult
 			select {
-			case result := <-resultCh:
-				if result.Error != nil {
-					errChan <- lerrors.New(lerrors.ErrIO).
+			case result = <-resultCh:
+			case <-ctx.Done():
+				result.Error = ctx.Err()
+			}
+			<-readSem
+
+			if result.Error == nil && len(result.Data) < int(run.size) {
+				result.Error = io.ErrUnexpectedEOF
+			}
+			if result.Error != nil {
+				for _, idx := range run.pages {
+					code := lerrors.ErrIO
+					if ctx.Err() != nil {
+						code = lerrors.ErrTimeout
+					}
+					fail(idx, lerrors.New(code).
 						Op("read_pages_async").
 						Context("page_index", idx).
 						Wrap(result.Error).
-						Build()
-					return
+						Build())
 				}
-
-				array, err := r.pageReader.ReadPageFromData(
-					result.Data,
-					pageIdx.Encoding,
-					pageIdx.NumValues,
-					dataType,
-				)
-				if err != nil {
-					errChan <- lerrors.New(lerrors.ErrDecodeFailed).
-						Op("decode_page_async").
-						Context("page_index", idx).
-						Wrap(err).
-						Build()
-					return
-				}
-
-				arrays[idx] = array
-
-			case <-ctx.Done():
-				errChan <- lerrors.New(lerrors.ErrTimeout).
-					Op("read_pages_async").
-					Context("page_index", idx).
-					Context("message", "timeout reading page").
-					Build()
 				return
 			}
-		}(i, pIdx)
+
+			for _, idx := range run.pages {
+				start := pageIndices[idx].Offset - run.offset
+				data := result.Data[start : start+int64(pageIndices[idx].Size)]
+				wg.Add(1)
+				decodeSem <- struct{}{}
+				go decode(idx, data)
+			}
+		}(run)
 	}
 
 	wg.Wait()
 	close(errChan)
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	for err := range errChan {
 		if err != nil {
 			return nil, err
@@ -473,30 +795,131 @@ func (r *Reader) readPagesAsync(pageIndices []format.PageIndex, dataType arrow.D
 	return arrays, nil
 }
 
+// pageRun is one read of readPagesAsync: the bytes [offset, offset+size)
+// of the file, holding the pages at the given positions of the pages read
+type pageRun struct {
+	offset int64
+	size   int32
+	pages  []int
+}
+
+// maxCoalescedRead caps the size of a read merging several pages
+const maxCoalescedRead = 8 << 20
+
+// coalescePages groups pageIndices by offset into runs of pages less than
+// gap bytes apart, reading the gaps along with them. A gap of 0 or less
+// reads every page by itself.
+func coalescePages(pageIndices []format.PageIndex, gap int64) []pageRun {
+	order := make([]int, len(pageIndices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return pageIndices[order[a]].Offset < pageIndices[order[b]].Offset
+	})
+
+	var runs []pageRun
+	for _, i := range order {
+		idx := pageIndices[i]
+		if n := len(runs); n > 0 && gap > 0 {
+			last := &runs[n-1]
+			end := last.offset + int64(last.size)
+			newEnd := max(end, idx.Offset+int64(idx.Size))
+			if idx.Offset-end < gap && newEnd-last.offset <= maxCoalescedRead {
+				last.size = int32(newEnd - last.offset)
+				last.pages = append(last.pages, i)
+				continue
+			}
+		}
+		runs = append(runs, pageRun{offset: idx.Offset, size: idx.Size, pages: []int{i}})
+	}
+	return runs
+}
+
+// coalesceGap returns the gap of ReaderOption.CoalesceGap in effect
+func (r *Reader) coalesceGap() int64 {
+	if r.options.CoalesceGap == 0 {
+		return DefaultCoalesceGap
+	}
+	return r.options.CoalesceGap
+}
+
 // readPagesSync 同步读取多个 Page（回退方案）
-func (r *Reader) readPagesSync(pageIndices []format.PageIndex, dataType arrow.DataType) ([]arrow.Array, error) {
+// Pages are read in order and decoded by up to ReaderOption.DecodeWorkers
+// goroutines; errors are reported in page order either way.
+func (r *Reader) readPagesSync(ctx context.Context, pageIndices []format.PageIndex, dataType arrow.DataType) ([]arrow.Array, error) {
 	arrays := make([]arrow.Array, len(pageIndices))
+	errs := make([]error, len(pageIndices))
+	pages := make([]*format.Page, len(pageIndices))
 
 	for i, pageIdx := range pageIndices {
-		page, err := r.readPage(pageIdx)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := r.readPage(ctx, pageIdx)
 		if err != nil {
-			return nil, lerrors.New(lerrors.ErrIO).
+			errs[i] = lerrors.New(lerrors.ErrIO).
 				Op("read_pages_sync").
 				Context("page_index", i).
 				Wrap(err).
 				Build()
+			if !r.options.SkipCorruptPages {
+				break
+			}
+			continue
 		}
+		pages[i] = page
+	}
 
-		array, err := r.pageReader.ReadPage(page, dataType)
+	decode := func(i int) {
+		if pages[i] == nil || ctx.Err() != nil {
+			return
+		}
+		array, err := r.pageReader.ReadPage(pages[i], dataType)
 		if err != nil {
-			return nil, lerrors.New(lerrors.ErrDecodeFailed).
+			page, _ := r.pagePosition(pageIndices[i])
+			errs[i] = lerrors.New(lerrors.ErrDecodeFailed).
 				Op("deserialize_page_sync").
 				Context("page_index", i).
+				Context("column", pageIndices[i].ColumnIndex).
+				Context("page", page).
 				Wrap(err).
 				Build()
+			return
 		}
-
 		arrays[i] = array
+		r.pagesRead.Add(1)
+	}
+	if workers := min(r.options.DecodeWorkers, len(pages)); workers > 1 {
+		var next atomic.Int64
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := int(next.Add(1)) - 1; i < len(pages); i = int(next.Add(1)) - 1 {
+					decode(i)
+				}
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i := range pages {
+			decode(i)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !r.options.SkipCorruptPages {
+			return nil, err
+		}
+		arrays[i] = r.skipCorruptPage(pageIndices, i, dataType, err)
 	}
 
 	return arrays, nil
@@ -504,10 +927,12 @@ func (r *Reader) readPagesSync(pageIndices []format.PageIndex, dataType arrow.Da
 
 // readPage reads a single page from the file
 // 优先使用 AsyncIO（如果启用），否则使用同步 I/O
-func (r *Reader) readPage(pageIndex format.PageIndex) (*format.Page, error) {
+func (r *Reader) readPage(ctx context.Context, pageIndex format.PageIndex) (*format.Page, error) {
+	r.readsIssued.Add(1)
+
 	// 如果 AsyncIO 启用，使用异步读取
 	if r.useAsync && r.asyncEnabled {
-		return r.readPageAsync(pageIndex)
+		return r.readPageAsync(ctx, pageIndex)
 	}
 
 	// 同步读取
@@ -520,8 +945,9 @@ func (r *Reader) readPageSync(pageIndex format.PageIndex) (*format.Page, error)
 		return nil, err
 	}
 
+	// The checksum is left to the decode, which may run in parallel
 	page := &format.Page{}
-	if _, err := page.ReadFrom(r.file); err != nil {
+	if _, err := page.ReadFromUnverified(r.file); err != nil {
 		return nil, err
 	}
 
@@ -529,12 +955,9 @@ func (r *Reader) readPageSync(pageIndex format.PageIndex) (*format.Page, error)
 }
 
 // readPageAsync 异步读取 Page
-func (r *Reader) readPageAsync(pageIndex format.PageIndex) (*format.Page, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
+func (r *Reader) readPageAsync(ctx context.Context, pageIndex format.PageIndex) (*format.Page, error) {
 	// 使用 AsyncIO 读取
-	resultCh := r.asyncIO.Read(ctx, r.fileID, pageIndex.Offset, pageIndex.Size)
+	resultCh := r.asyncIO.ReadWithPriority(ctx, r.fileID, pageIndex.Offset, pageIndex.Size, r.priority)
 
 	select {
 	case result := <-resultCh:
@@ -547,7 +970,7 @@ func (r *Reader) readPageAsync(pageIndex format.PageIndex) (*format.Page, error)
 
 		// 从 result.Data 构造 Page
 		page := &format.Page{}
-		if err := page.UnmarshalBinary(result.Data); err != nil {
+		if _, err := page.ReadFromUnverified(bytes.NewReader(result.Data)); err != nil {
 			return nil, lerrors.New(lerrors.ErrCorruptedFile).
 				Op("unmarshal_page").
 				Wrap(err).
@@ -560,6 +983,7 @@ func (r *Reader) readPageAsync(pageIndex format.PageIndex) (*format.Page, error)
 		return nil, lerrors.New(lerrors.ErrTimeout).
 			Op("read_page_async").
 			Context("message", "async read timeout").
+			Wrap(ctx.Err()).
 			Build()
 	}
 }
@@ -582,12 +1006,16 @@ func (r *Reader) mergeArrays(arrays []arrow.Array, dataType arrow.DataType) (arr
 		return r.mergeInt32Arrays(arrays)
 	case arrow.INT64:
 		return r.mergeInt64Arrays(arrays)
+	case arrow.FLOAT16:
+		return r.mergeFloat16Arrays(arrays)
 	case arrow.FLOAT32:
 		return r.mergeFloat32Arrays(arrays)
 	case arrow.FLOAT64:
 		return r.mergeFloat64Arrays(arrays)
 	case arrow.FIXED_SIZE_LIST:
 		return r.mergeFixedSizeListArrays(arrays, dataType.(*arrow.FixedSizeListType))
+	case arrow.STRING, arrow.BINARY:
+		return r.mergeBinaryArrays(arrays, dataType)
 	default:
 		return nil, lerrors.UnsupportedType("merge_arrays", dataType.Name(), "")
 	}
@@ -644,8 +1072,37 @@ func (r *Reader) mergeInt64Arrays(arrays []arrow.Array) (arrow.Array, error) {
 	return builder.NewArray(), nil
 }
 
+// mergeFloat16Arrays merges multiple Float16Array into one
+func (r *Reader) mergeFloat16Arrays(arrays []arrow.Array) (arrow.Array, error) {
+	builder := arrow.NewFloat16Builder()
+	defer builder.Release()
+
+	totalSize := 0
+	for _, arr := range arrays {
+		totalSize += arr.Len()
+	}
+	builder.Reserve(totalSize)
+
+	for _, arr := range arrays {
+		float16Arr := arr.(*arrow.Float16Array)
+		for i := 0; i < float16Arr.Len(); i++ {
+			if float16Arr.IsNull(i) {
+				builder.AppendNull()
+			} else {
+				builder.Append(float16Arr.Value(i))
+			}
+		}
+	}
+
+	return builder.NewArray(), nil
+}
+
 // mergeFloat32Arrays merges multiple Float32Array into one
 func (r *Reader) mergeFloat32Arrays(arrays []arrow.Array) (arrow.Array, error) {
+	if buf := concatValueBuffers(arrays); buf != nil {
+		return arrow.NewFloat32ArrayFromBuffer(buf, nil), nil
+	}
+
 	builder := arrow.NewFloat32Builder()
 	defer builder.Release()
 
@@ -693,8 +1150,59 @@ func (r *Reader) mergeFloat64Arrays(arrays []arrow.Array) (arrow.Array, error) {
 	return builder.NewArray(), nil
 }
 
-// mergeFixedSizeListArrays merges multiple FixedSizeListArray into one
+// mergeBinaryArrays merges multiple StringArray or BinaryArray into one
+func (r *Reader) mergeBinaryArrays(arrays []arrow.Array, dataType arrow.DataType) (arrow.Array, error) {
+	builder := arrow.NewStringBuilder()
+	defer builder.Release()
+
+	totalSize := 0
+	for _, arr := range arrays {
+		totalSize += arr.Len()
+	}
+	builder.Reserve(totalSize)
+
+	for _, arr := range arrays {
+		var binaryArr *arrow.BinaryArray
+		switch a := arr.(type) {
+		case *arrow.StringArray:
+			binaryArr = &a.BinaryArray
+		default:
+			binaryArr = arr.(*arrow.BinaryArray)
+		}
+		for i := 0; i < binaryArr.Len(); i++ {
+			if binaryArr.IsNull(i) {
+				builder.AppendNull()
+			} else {
+				builder.BinaryBuilder.Append(binaryArr.Value(i))
+			}
+		}
+	}
+
+	if dataType.ID() == arrow.BINARY {
+		return builder.BinaryBuilder.NewArray(), nil
+	}
+	return builder.NewArray(), nil
+}
+
+// mergeFixedSizeListArrays merges multiple FixedSizeListArray into one.
+// Float32 lists without nulls are merged by copying their values' bytes.
 func (r *Reader) mergeFixedSizeListArrays(arrays []arrow.Array, listType *arrow.FixedSizeListType) (arrow.Array, error) {
+	if listType.Elem().ID() == arrow.FLOAT32 {
+		children := make([]arrow.Array, len(arrays))
+		for i, arr := range arrays {
+			listArr := arr.(*arrow.FixedSizeListArray)
+			if listArr.NullN() > 0 {
+				children = nil
+				break
+			}
+			children[i] = listArr.Values()
+		}
+		if buf := concatValueBuffers(children); buf != nil {
+			values := arrow.NewFloat32ArrayFromBuffer(buf, nil)
+			return arrow.NewFixedSizeListArray(listType, values, nil), nil
+		}
+	}
+
 	builder := arrow.NewFixedSizeListBuilder(listType)
 	defer builder.Release()
 
@@ -721,6 +1229,29 @@ func (r *Reader) mergeFixedSizeListArrays(arrays []arrow.Array, listType *arrow.
 	return builder.NewArray(), nil
 }
 
+// concatValueBuffers concatenates the value buffers of fixed-width arrays
+// of one type with copy. It returns nil if there are no arrays or any has
+// nulls, which the builders merge value by value.
+func concatValueBuffers(arrays []arrow.Array) *arrow.Buffer {
+	size := 0
+	for _, arr := range arrays {
+		if arr.NullN() > 0 {
+			return nil
+		}
+		size += arr.Data().Buffers()[0].Len()
+	}
+	if len(arrays) == 0 {
+		return nil
+	}
+
+	buf := arrow.NewBuffer(size)
+	pos := 0
+	for _, arr := range arrays {
+		pos += copy(buf.Bytes()[pos:], arr.Data().Buffers()[0].Bytes())
+	}
+	return buf
+}
+
 // getFixedSizeListValues extracts values from a FixedSizeListArray at index i
 func (r *Reader) getFixedSizeListValues(arr *arrow.FixedSizeListArray, index int) []float32 {
 	listSize := arr.ListSize()
@@ -737,6 +1268,11 @@ func (r *Reader) getFixedSizeListValues(arr *arrow.FixedSizeListArray, index int
 		for j := 0; j < listSize; j++ {
 			values[j] = valArr.Value(startOffset + j)
 		}
+	case *arrow.Float16Array:
+		// Exact, so the builder rounds it back to the same bits
+		for j := 0; j < listSize; j++ {
+			values[j] = valArr.Float32(startOffset + j)
+		}
 	case *arrow.Int32Array:
 		for j := 0; j < listSize; j++ {
 			values[j] = float32(valArr.Value(startOffset + j))
@@ -1,9 +1,13 @@
 package column
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -1232,3 +1236,309 @@ func BenchmarkReader_Float64(b *testing.B) {
 		reader.Close()
 	}
 }
+
+func TestReader_WithAsyncIO_Priority(t *testing.T) {
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test_async_priority.lance")
+	createTestFile(t, filename, 500, 3)
+
+	asyncIO := setupAsyncIO(t)
+	defer asyncIO.Close()
+
+	reader, err := NewReaderWithOptions(filename, asyncIO, ReaderOption{Priority: lanceio.PriorityBackground})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer reader.Close()
+
+	// The Reader's default priority
+	if _, err := reader.ReadRecordBatch(); err != nil {
+		t.Fatalf("ReadRecordBatch failed: %v", err)
+	}
+	stats := asyncIO.Stats().Scheduler
+	if stats.Background.Dispatched == 0 || stats.Interactive.Dispatched != 0 {
+		t.Fatalf("background reader dispatched %d background, %d interactive reads",
+			stats.Background.Dispatched, stats.Interactive.Dispatched)
+	}
+
+	// A per-call override
+	batch, err := reader.ReadRecordBatchWithPriority(lanceio.PriorityInteractive)
+	if err != nil {
+		t.Fatalf("ReadRecordBatchWithPriority failed: %v", err)
+	}
+	if batch.NumRows() != 500 {
+		t.Errorf("Expected 500 rows, got %d", batch.NumRows())
+	}
+	after := asyncIO.Stats().Scheduler
+	if after.Interactive.Dispatched == 0 || after.Background.Dispatched != stats.Background.Dispatched {
+		t.Errorf("override dispatched %d interactive, %d more background reads",
+			after.Interactive.Dispatched, after.Background.Dispatched-stats.Background.Dispatched)
+	}
+}
+
+// TestReader_WithAsyncIO_CoalescedReads reads 100 contiguous pages of about
+// 4KB and checks that they take a handful of reads and decode as they do
+// one read per page
+func TestReader_WithAsyncIO_CoalescedReads(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "small_pages.lance")
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "value", Type: arrow.PrimInt64(), Nullable: false},
+	}, nil)
+
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(7))
+	const numPages, pageRows = 100, 500
+	for p := 0; p < numPages; p++ {
+		builder := &arrow.Int64Builder{}
+		for i := 0; i < pageRows; i++ {
+			builder.Append(rng.Int63())
+		}
+		batch, err := arrow.NewRecordBatch(schema, pageRows, []arrow.Array{builder.NewArray()})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	asyncIO := setupAsyncIO(t)
+	defer asyncIO.Close()
+
+	read := func(opts ReaderOption) (*arrow.RecordBatch, *Reader) {
+		reader, err := NewReaderWithOptions(filename, asyncIO, opts)
+		if err != nil {
+			t.Fatalf("NewReaderWithOptions failed: %v", err)
+		}
+		batch, err := reader.ReadRecordBatch()
+		if err != nil {
+			t.Fatalf("ReadRecordBatch failed: %v", err)
+		}
+		reader.Close()
+		return batch, reader
+	}
+
+	coalesced, reader := read(ReaderOption{})
+	if reader.PagesRead() != numPages {
+		t.Fatalf("Expected %d pages served, got %d", numPages, reader.PagesRead())
+	}
+	if reads := reader.ReadsIssued(); reads > 10 {
+		t.Errorf("Expected a handful of reads for %d contiguous pages, got %d", numPages, reads)
+	}
+
+	separate, reader := read(ReaderOption{CoalesceGap: -1})
+	if reads := reader.ReadsIssued(); reads != numPages {
+		t.Errorf("Expected one read per page without coalescing, got %d", reads)
+	}
+
+	if !arraysEqual(coalesced.Column(0), separate.Column(0)) {
+		t.Error("Coalesced reads decode differently from one read per page")
+	}
+}
+
+// TestReader_ReadRecordBatchContext_Cancel cancels a read of a large file
+// once it is under way and checks that it stops promptly with ctx.Err()
+// and leaves no goroutines behind
+func TestReader_ReadRecordBatchContext_Cancel(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "large.lance")
+	const numPages, pageRows, dim = 1000, 256, 64
+	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), dim)
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "embedding", Type: listType, Nullable: false},
+	}, nil)
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(3))
+	for p := 0; p < numPages; p++ {
+		child := arrow.NewFloat32Builder()
+		for i := 0; i < pageRows*dim; i++ {
+			child.Append(rng.Float32())
+		}
+		vectors := arrow.NewFixedSizeListArray(listType.(*arrow.FixedSizeListType), child.NewArray(), nil)
+		batch, err := arrow.NewRecordBatch(schema, pageRows, []arrow.Array{vectors})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	asyncIO := setupAsyncIO(t)
+	defer asyncIO.Close()
+
+	for name, aio := range map[string]*lanceio.AsyncIO{"Sync": nil, "AsyncIO": asyncIO} {
+		t.Run(name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			reader, err := NewReaderWithOptions(filename, aio, ReaderOption{})
+			if err != nil {
+				t.Fatalf("NewReaderWithOptions failed: %v", err)
+			}
+			defer reader.Close()
+
+			// Cancel once the first pages are decoded
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				for reader.PagesRead() < 10 {
+					time.Sleep(100 * time.Microsecond)
+				}
+				cancel()
+			}()
+
+			_, err = reader.ReadRecordBatchContext(ctx)
+			cancelled := time.Now()
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected context.Canceled, got %v", err)
+			}
+			if pages := reader.PagesRead(); pages >= numPages {
+				t.Errorf("All %d pages were read despite the cancellation", pages)
+			}
+
+			// Abandoned requests drain in bounded time
+			for runtime.NumGoroutine() > before && time.Since(cancelled) < 2*time.Second {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if n := runtime.NumGoroutine(); n > before {
+				t.Errorf("Goroutines leaked: %d before the read, %d after", before, n)
+			}
+		})
+	}
+}
+
+func TestReader_ReadColumnsContext_Deadline(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.lance")
+	createTestFile(t, filename, 1000, 2)
+
+	reader, err := NewReaderWithOptions(filename, nil, ReaderOption{Timeout: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer reader.Close()
+
+	// The default timeout applies to reads without a deadline
+	if _, err := reader.ReadColumns([]string{"col1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// A deadline of the caller replaces it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	batch, err := reader.ReadColumnsContext(ctx, []string{"col1"})
+	if err != nil {
+		t.Fatalf("ReadColumnsContext failed: %v", err)
+	}
+	if batch.NumRows() != 1000 {
+		t.Errorf("Expected 1000 rows, got %d", batch.NumRows())
+	}
+}
+
+// setupCachedAsyncIO 创建带 PageCache 的 AsyncIO
+func setupCachedAsyncIO(t testing.TB, cacheBytes int64) *lanceio.AsyncIO {
+	config := lanceio.DefaultConfig()
+	config.Workers = 4
+	config.PageCacheBytes = cacheBytes
+	asyncIO, err := lanceio.New(config)
+	if err != nil {
+		t.Fatalf("Failed to create AsyncIO: %v", err)
+	}
+	return asyncIO
+}
+
+// TestReader_WithAsyncIO_PageCache reads a file with several Readers on an
+// AsyncIO with a page cache: only the first goes to disk, and a rewrite of
+// the file is not hidden by the cache
+func TestReader_WithAsyncIO_PageCache(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cached.lance")
+	schema := mergeTestSchema(true, mergeTestDim)
+	rows := writeMergeSource(t, filename, schema, 0, []int{100, 100, 100})
+
+	asyncIO := setupCachedAsyncIO(t, 64<<20)
+	defer asyncIO.Close()
+
+	read := func() *arrow.RecordBatch {
+		reader, err := NewReaderWithAsyncIO(filename, asyncIO)
+		if err != nil {
+			t.Fatalf("NewReaderWithAsyncIO failed: %v", err)
+		}
+		defer reader.Close()
+		batch, err := reader.ReadRecordBatch()
+		if err != nil {
+			t.Fatalf("ReadRecordBatch failed: %v", err)
+		}
+		return batch
+	}
+
+	first := read()
+	misses := asyncIO.Stats().PageCache.Misses
+	if misses == 0 {
+		t.Fatal("Expected the first pass to miss the cache")
+	}
+	for i := 0; i < 3; i++ {
+		if batch := read(); !arraysEqual(batch.Column(0), first.Column(0)) {
+			t.Fatal("Cached pages decode differently")
+		}
+	}
+	stats := asyncIO.Stats().PageCache
+	if stats.Misses != misses {
+		t.Errorf("Later passes missed the cache %d times", stats.Misses-misses)
+	}
+	if stats.Hits != 3*misses {
+		t.Errorf("Expected %d hits, got %d", 3*misses, stats.Hits)
+	}
+
+	// Appending replaces the file; new readers must see the new rows
+	writer, err := OpenWriterAppend(filename, schema, nil)
+	if err != nil {
+		t.Fatalf("OpenWriterAppend failed: %v", err)
+	}
+	rows += writeMergeBatches(t, writer, schema, rows, []int{50})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if batch := read(); batch.NumRows() != rows {
+		t.Errorf("Expected %d rows after append, got %d", rows, batch.NumRows())
+	}
+}
+
+// BenchmarkReader_AsyncIO_PageCache reads the same file over and over with
+// and without a page cache; misses/op shows the reads that hit the disk
+func BenchmarkReader_AsyncIO_PageCache(b *testing.B) {
+	filename := filepath.Join(b.TempDir(), "bench_cache.lance")
+	createTestFile(b, filename, 10000, 10)
+
+	for _, cacheBytes := range []int64{0, 64 << 20} {
+		b.Run(fmt.Sprintf("Cache%dMB", cacheBytes>>20), func(b *testing.B) {
+			asyncIO := setupCachedAsyncIO(b, cacheBytes)
+			defer asyncIO.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				reader, err := NewReaderWithAsyncIO(filename, asyncIO)
+				if err != nil {
+					b.Fatalf("NewReaderWithAsyncIO failed: %v", err)
+				}
+				if _, err := reader.ReadRecordBatch(); err != nil {
+					b.Fatalf("ReadRecordBatch failed: %v", err)
+				}
+				reader.Close()
+			}
+			b.StopTimer()
+
+			stats := asyncIO.Stats()
+			b.ReportMetric(float64(stats.Scheduler.Submitted)/float64(b.N), "disk-reads/op")
+			b.ReportMetric(float64(stats.PageCache.Hits)/float64(b.N), "hits/op")
+		})
+	}
+}
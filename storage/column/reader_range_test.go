@@ -0,0 +1,363 @@
+package column
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+// openRangeTestReaders returns a synchronous and an AsyncIO reader of filename
+func openRangeTestReaders(t *testing.T, filename string) map[string]*Reader {
+	t.Helper()
+	sync, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	asyncIO := setupAsyncIO(t)
+	async, err := NewReaderWithAsyncIO(filename, asyncIO)
+	if err != nil {
+		t.Fatalf("NewReaderWithAsyncIO failed: %v", err)
+	}
+	t.Cleanup(func() {
+		sync.Close()
+		async.Close()
+		asyncIO.Close()
+	})
+	return map[string]*Reader{"Sync": sync, "Async": async}
+}
+
+func TestReader_ReadColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "mixed.lance")
+	createTestFileMixed(t, filename, 450)
+
+	for name, reader := range openRangeTestReaders(t, filename) {
+		t.Run(name, func(t *testing.T) {
+			batch, err := reader.ReadColumns([]string{"count", "id", "value"})
+			if err != nil {
+				t.Fatalf("ReadColumns failed: %v", err)
+			}
+			if batch.NumRows() != 450 || batch.NumCols() != 3 {
+				t.Fatalf("Expected 450 rows of 3 columns, got %d of %d", batch.NumRows(), batch.NumCols())
+			}
+			for i, want := range []string{"count", "id", "value"} {
+				if got := batch.Schema().Field(i).Name; got != want {
+					t.Errorf("Field %d is %q, want %q", i, got, want)
+				}
+			}
+			counts := batch.Column(0).(*arrow.Int64Array)
+			ids := batch.Column(1).(*arrow.Int32Array)
+			values := batch.Column(2).(*arrow.Float64Array)
+			for i := 0; i < 450; i++ {
+				if counts.Value(i) != int64(i)*1000 || ids.Value(i) != int32(i) || values.Value(i) != float64(i)*2.718281828 {
+					t.Fatalf("Row %d: count %d, id %d, value %v", i, counts.Value(i), ids.Value(i), values.Value(i))
+				}
+			}
+
+			_, err = reader.ReadColumns([]string{"id", "missing"})
+			if !lerrors.Is(err, lerrors.ErrColumnNotFound) {
+				t.Errorf("Expected ErrColumnNotFound for an unknown column, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReader_ReadRowRange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "mixed.lance")
+	createTestFileMixed(t, filename, 450)
+
+	for name, reader := range openRangeTestReaders(t, filename) {
+		t.Run(name, func(t *testing.T) {
+			// Windows within a page, across pages, page-aligned, up to
+			// the end and empty
+			for _, w := range [][2]int64{{10, 20}, {50, 300}, {100, 200}, {420, 30}, {0, 450}, {450, 0}, {7, 0}} {
+				start, count := w[0], w[1]
+				batch, err := reader.ReadRowRange(start, count)
+				if err != nil {
+					t.Fatalf("ReadRowRange(%d, %d) failed: %v", start, count, err)
+				}
+				if batch.NumRows() != int(count) || batch.NumCols() != 5 {
+					t.Fatalf("ReadRowRange(%d, %d): %d rows of %d columns", start, count, batch.NumRows(), batch.NumCols())
+				}
+				ids := batch.Column(0).(*arrow.Int32Array)
+				ratios := batch.Column(4).(*arrow.Float32Array)
+				for i := 0; i < int(count); i++ {
+					row := start + int64(i)
+					if ids.Value(i) != int32(row) || ratios.Value(i) != float32(row)/100.0 {
+						t.Fatalf("ReadRowRange(%d, %d) row %d: id %d, ratio %v", start, count, i, ids.Value(i), ratios.Value(i))
+					}
+				}
+			}
+
+			batch, err := reader.ReadColumnsRange([]string{"score"}, 95, 10)
+			if err != nil {
+				t.Fatalf("ReadColumnsRange failed: %v", err)
+			}
+			scores := batch.Column(0).(*arrow.Float32Array)
+			if batch.NumCols() != 1 || scores.Len() != 10 || scores.Value(0) != 47.5 || scores.Value(9) != 52 {
+				t.Errorf("ReadColumnsRange: %d columns, scores %v", batch.NumCols(), scores)
+			}
+
+			for _, w := range [][2]int64{{-1, 10}, {0, -1}, {440, 11}, {451, 0}, {0, 1 << 62}} {
+				_, err := reader.ReadRowRange(w[0], w[1])
+				if !lerrors.Is(err, lerrors.ErrInvalidArgument) {
+					t.Errorf("ReadRowRange(%d, %d): expected ErrInvalidArgument, got %v", w[0], w[1], err)
+				}
+			}
+		})
+	}
+}
+
+func TestReader_ReadRowRangeSkipsPages(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pages.lance")
+	writeMultiPageFile(t, filename)
+	corruptPage(t, filename, 1, 0)
+	corruptPage(t, filename, 1, 3)
+
+	// Damaged pages outside the projection or the window are never read
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	if _, err := reader.ReadColumns([]string{"id"}); err != nil {
+		t.Errorf("ReadColumns of the intact column failed: %v", err)
+	}
+	if _, err := reader.ReadRowRange(100, 200); err != nil {
+		t.Errorf("ReadRowRange between damaged pages failed: %v", err)
+	}
+	if _, err := reader.ReadRowRange(250, 100); err == nil {
+		t.Error("Expected ReadRowRange over a damaged page to fail")
+	}
+
+	// and tolerant reads place the ones inside within the whole column
+	tolerant, err := NewReaderWithOptions(filename, nil, ReaderOption{SkipCorruptPages: true})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer tolerant.Close()
+	batch, err := tolerant.ReadRowRange(250, 100)
+	if err != nil {
+		t.Fatalf("ReadRowRange failed: %v", err)
+	}
+	report := tolerant.CorruptionReport()
+	if len(report.Pages) != 1 || report.Pages[0].Page != 3 || report.Pages[0].FirstRow != 300 {
+		t.Fatalf("Expected page 3 from row 300 reported, got %+v", report)
+	}
+	values := batch.Column(1)
+	for i := 0; i < 100; i++ {
+		if values.IsNull(i) != (i >= 50) {
+			t.Fatalf("Row %d of the window: null %v", 250+i, values.IsNull(i))
+		}
+	}
+}
+
+// BenchmarkReader_ReadColumns compares reading 2 of 50 columns to reading
+// all of them
+func BenchmarkReader_ReadColumns(b *testing.B) {
+	// 20 pages per column keeps the page index within the footer
+	filename := filepath.Join(b.TempDir(), "wide.lance")
+	createTestFile(b, filename, 2000, 50)
+
+	for _, mode := range []string{"Sync", "Async"} {
+		b.Run(mode, func(b *testing.B) {
+			open := func() *Reader {
+				var reader *Reader
+				var err error
+				if mode == "Async" {
+					asyncIO := setupAsyncIO(b)
+					b.Cleanup(func() { asyncIO.Close() })
+					reader, err = NewReaderWithAsyncIO(filename, asyncIO)
+				} else {
+					reader, err = NewReader(filename)
+				}
+				if err != nil {
+					b.Fatalf("Failed to open reader: %v", err)
+				}
+				b.Cleanup(func() { reader.Close() })
+				return reader
+			}
+
+			b.Run("All", func(b *testing.B) {
+				reader := open()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := reader.ReadRecordBatch(); err != nil {
+						b.Fatalf("ReadRecordBatch failed: %v", err)
+					}
+				}
+			})
+			b.Run("2of50", func(b *testing.B) {
+				reader := open()
+				names := []string{"col7", "col42"}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := reader.ReadColumns(names); err != nil {
+						b.Fatalf("ReadColumns failed: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// writeBatchesTestFile writes batches of the given sizes of an id, a
+// nullable score and a nullable 4-dimensional vector column
+func writeBatchesTestFile(t *testing.T, filename string, sizes []int) {
+	t.Helper()
+	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), 4)
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
+		{Name: "score", Type: arrow.PrimFloat64(), Nullable: true},
+		{Name: "vec", Type: listType, Nullable: true},
+	}, nil)
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	row := 0
+	for _, size := range sizes {
+		ids := arrow.NewInt32Builder()
+		scores := make([]float64, size)
+		scoreValid := arrow.NewBitmap(size)
+		values := make([]float32, size*4)
+		vecValid := arrow.NewBitmap(size)
+		for i := 0; i < size; i++ {
+			ids.Append(int32(row))
+			scores[i] = float64(row) * 0.25
+			if row%7 != 0 {
+				scoreValid.Set(i)
+			}
+			for d := 0; d < 4; d++ {
+				values[i*4+d] = float32(row*4 + d)
+			}
+			if row%11 != 0 {
+				vecValid.Set(i)
+			}
+			row++
+		}
+		vecs := arrow.NewFixedSizeListArray(listType.(*arrow.FixedSizeListType), arrow.NewFloat32Array(values, nil), vecValid)
+		batch, err := arrow.NewRecordBatch(schema, size,
+			[]arrow.Array{ids.NewArray(), arrow.NewFloat64Array(scores, scoreValid), vecs})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("writer.Close() failed: %v", err)
+	}
+}
+
+func TestReader_Next(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "batches.lance")
+	sizes := []int{100, 37, 250, 1}
+	writeBatchesTestFile(t, filename, sizes)
+
+	for name, reader := range openRangeTestReaders(t, filename) {
+		t.Run(name, func(t *testing.T) {
+			if n := reader.NumBatches(); n != len(sizes) {
+				t.Fatalf("NumBatches = %d, want %d", n, len(sizes))
+			}
+			var batches []*arrow.RecordBatch
+			for {
+				batch, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next failed: %v", err)
+				}
+				batches = append(batches, batch)
+			}
+			if len(batches) != len(sizes) {
+				t.Fatalf("Next returned %d batches, want %d", len(batches), len(sizes))
+			}
+			for i, batch := range batches {
+				for col := 0; col < batch.NumCols(); col++ {
+					if n := batch.Column(col).Len(); n != sizes[i] {
+						t.Fatalf("Batch %d column %d has %d rows, want %d", i, col, n, sizes[i])
+					}
+				}
+			}
+			if _, err := reader.Next(); err != io.EOF {
+				t.Errorf("Expected io.EOF after the last batch, got %v", err)
+			}
+
+			// Concatenated, the batches are the whole file
+			all, err := reader.ReadRecordBatch()
+			if err != nil {
+				t.Fatalf("ReadRecordBatch failed: %v", err)
+			}
+			for col := 0; col < all.NumCols(); col++ {
+				parts := make([]arrow.Array, len(batches))
+				for i, batch := range batches {
+					parts[i] = batch.Column(col)
+				}
+				merged, err := reader.mergeArrays(parts, all.Schema().Field(col).Type)
+				if err != nil {
+					t.Fatalf("mergeArrays failed: %v", err)
+				}
+				if !arraysEqual(merged, all.Column(col)) {
+					t.Errorf("Column %d of the batches differs from ReadRecordBatch", col)
+				}
+				for row := 0; row < merged.Len(); row++ {
+					if merged.IsNull(row) != all.Column(col).IsNull(row) {
+						t.Fatalf("Column %d row %d: null %v", col, row, merged.IsNull(row))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReader_NextMerged(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "a.lance"), filepath.Join(dir, "b.lance")
+	writeBatchesTestFile(t, first, []int{20, 30})
+	writeBatchesTestFile(t, second, []int{40})
+	merged := filepath.Join(dir, "merged.lance")
+	if err := MergeFiles(merged, []string{first, second}, MergeOptions{}); err != nil {
+		t.Fatalf("MergeFiles failed: %v", err)
+	}
+
+	reader, err := NewReader(merged)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	var sizes []int
+	for {
+		batch, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		sizes = append(sizes, batch.NumRows())
+	}
+	if fmt.Sprint(sizes) != "[20 30 40]" {
+		t.Errorf("Batches of the merged file have %v rows, want [20 30 40]", sizes)
+	}
+
+	empty := filepath.Join(dir, "empty.lance")
+	writeBatchesTestFile(t, empty, nil)
+	reader, err = NewReader(empty)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	if n := reader.NumBatches(); n != 0 {
+		t.Errorf("Empty file has %d batches", n)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF from an empty file, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package column
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// stringTestValue returns the name of row i: empty, long (>64KB),
+// non-ASCII or null
+func stringTestValue(i int) (string, bool) {
+	switch {
+	case i%11 == 0:
+		return "", false
+	case i%7 == 0:
+		return "", true
+	case i == 3:
+		return strings.Repeat("long-", 20000), true
+	case i%2 == 0:
+		return "héllo, 世界 🌍", true
+	default:
+		return "row-" + strings.Repeat("x", i%5), true
+	}
+}
+
+// writeStringsFile writes an int32 id, a nullable string name and a
+// binary blob, in batches of the given sizes
+func writeStringsFile(t *testing.T, filename string, sizes []int) int {
+	t.Helper()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimInt32(), Nullable: false},
+		{Name: "name", Type: arrow.PrimString(), Nullable: true},
+		{Name: "blob", Type: arrow.PrimBinary(), Nullable: false},
+	}, nil)
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	row := 0
+	for _, size := range sizes {
+		ids := arrow.NewInt32Builder()
+		names := arrow.NewStringBuilder()
+		blobs := arrow.NewBinaryBuilder()
+		for i := 0; i < size; i++ {
+			ids.Append(int32(row))
+			if name, ok := stringTestValue(row); ok {
+				names.Append(name)
+			} else {
+				names.AppendNull()
+			}
+			blobs.Append([]byte{byte(row), 0, byte(row >> 8)})
+			row++
+		}
+		batch, err := arrow.NewRecordBatch(schema, size,
+			[]arrow.Array{ids.NewArray(), names.NewArray(), blobs.NewArray()})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("writer.Close() failed: %v", err)
+	}
+	return row
+}
+
+// checkStringRows fails unless batch holds rows [first, first+batch.NumRows())
+// of writeStringsFile
+func checkStringRows(t *testing.T, batch *arrow.RecordBatch, first int) {
+	t.Helper()
+	ids := batch.Column(0).(*arrow.Int32Array)
+	names := batch.Column(1).(*arrow.StringArray)
+	blobs := batch.Column(2).(*arrow.BinaryArray)
+	for i := 0; i < batch.NumRows(); i++ {
+		row := first + i
+		name, ok := stringTestValue(row)
+		if int(ids.Value(i)) != row || names.IsValid(i) != ok || names.Value(i) != name {
+			t.Fatalf("Row %d: id %d, name %.20q (valid %v), want %.20q (valid %v)",
+				row, ids.Value(i), names.Value(i), names.IsValid(i), name, ok)
+		}
+		if blob := blobs.Value(i); len(blob) != 3 || blob[0] != byte(row) || blob[2] != byte(row>>8) {
+			t.Fatalf("Row %d: blob %v", row, blob)
+		}
+	}
+}
+
+func TestReader_StringColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "strings.lance")
+	numRows := writeStringsFile(t, filename, []int{300, 1, 500})
+
+	for name, reader := range openRangeTestReaders(t, filename) {
+		t.Run(name, func(t *testing.T) {
+			batch, err := reader.ReadRecordBatch()
+			if err != nil {
+				t.Fatalf("ReadRecordBatch failed: %v", err)
+			}
+			if batch.NumRows() != numRows {
+				t.Fatalf("Read %d rows, want %d", batch.NumRows(), numRows)
+			}
+			checkStringRows(t, batch, 0)
+
+			// A range across pages slices and merges the string pages
+			batch, err = reader.ReadRowRange(250, 100)
+			if err != nil {
+				t.Fatalf("ReadRowRange failed: %v", err)
+			}
+			checkStringRows(t, batch, 250)
+
+			batch, err = reader.ReadColumns([]string{"name"})
+			if err != nil {
+				t.Fatalf("ReadColumns failed: %v", err)
+			}
+			if batch.NumRows() != numRows || batch.Column(0).(*arrow.StringArray).Value(3) != strings.Repeat("long-", 20000) {
+				t.Errorf("ReadColumns(name) read %d rows", batch.NumRows())
+			}
+
+			row := 0
+			for {
+				batch, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next failed: %v", err)
+				}
+				checkStringRows(t, batch, row)
+				row += batch.NumRows()
+			}
+			if row != numRows {
+				t.Errorf("Next read %d rows, want %d", row, numRows)
+			}
+		})
+	}
+}
+
+func TestPageWriter_StringEncoding(t *testing.T) {
+	writer := NewPageWriter(defaultEncoderFactory())
+	reader := NewPageReader()
+
+	// Low-cardinality strings are dictionary encoded
+	words := []string{"", "red", "grün", "青"}
+	builder := arrow.NewStringBuilder()
+	for i := 0; i < 1000; i++ {
+		builder.Append(words[i%len(words)])
+	}
+	array := builder.NewArray()
+	pages, err := writer.WritePages(array, 0)
+	if err != nil {
+		t.Fatalf("WritePages failed: %v", err)
+	}
+	if pages[0].Encoding != format.EncodingDictionary {
+		t.Errorf("Expected Dictionary, got %v", pages[0].Encoding)
+	}
+	result, err := reader.ReadPage(pages[0], arrow.PrimString())
+	if err != nil {
+		t.Fatalf("ReadPage failed: %v", err)
+	}
+	if !arraysEqual(array, result) {
+		t.Error("Dictionary string roundtrip failed")
+	}
+
+	// and fall back to Zstd with nulls
+	for i := 0; i < 1000; i++ {
+		if i%10 == 0 {
+			builder.AppendNull()
+		} else {
+			builder.Append(words[i%len(words)])
+		}
+	}
+	array = builder.NewArray()
+	if pages, err = writer.WritePages(array, 0); err != nil {
+		t.Fatalf("WritePages failed: %v", err)
+	}
+	if pages[0].Encoding != format.EncodingZstd {
+		t.Errorf("Expected Zstd fallback, got %v", pages[0].Encoding)
+	}
+	if result, err = reader.ReadPage(pages[0], arrow.PrimString()); err != nil || !arraysEqual(array, result) {
+		t.Errorf("Zstd string roundtrip failed: %v", err)
+	}
+}
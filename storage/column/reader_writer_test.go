@@ -1,12 +1,16 @@
 package column
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/rand"
 	"github.com/wzqhbustb/vego/storage/arrow"
 	"github.com/wzqhbustb/vego/storage/encoding" // [NEW] 导入 encoding 包
 	"github.com/wzqhbustb/vego/storage/format"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -503,6 +507,82 @@ func TestWriterReader_VectorColumn(t *testing.T) {
 	}
 }
 
+// TestWriterReader_Float16VectorColumn writes half-precision vectors over
+// several pages, with and without nulls, and reads the same bits back
+func TestWriterReader_Float16VectorColumn(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_float16.lance")
+
+	const dim, pageRows = 128, 200
+	listType := arrow.Float16VectorType(dim).(*arrow.FixedSizeListType)
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "score", Type: arrow.PrimFloat16(), Nullable: true},
+		{Name: "embedding", Type: listType, Nullable: true},
+	}, nil)
+
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(11))
+	var scores, vectors []arrow.Array
+	for p := 0; p < 3; p++ {
+		scoreBuilder := arrow.NewFloat16Builder()
+		vectorBuilder := arrow.NewFixedSizeListBuilder(listType)
+		vec := make([]float32, dim)
+		for i := 0; i < pageRows; i++ {
+			if p == 1 && i%17 == 0 {
+				scoreBuilder.AppendNull()
+				vectorBuilder.AppendNull()
+				continue
+			}
+			scoreBuilder.AppendFloat32(rng.Float32())
+			for d := range vec {
+				vec[d] = float32(rng.NormFloat64()) * 0.05
+			}
+			vectorBuilder.AppendValues(vec)
+		}
+		score, vector := scoreBuilder.NewArray(), vectorBuilder.NewArray()
+		batch, err := arrow.NewRecordBatch(schema, pageRows, []arrow.Array{score, vector})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+		scores, vectors = append(scores, score), append(vectors, vector)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	if got := reader.Schema().Field(1).Type.Name(); got != listType.Name() {
+		t.Errorf("Expected type %s, got %s", listType.Name(), got)
+	}
+	for p := 0; p < 3; p++ {
+		batch, err := reader.ReadColumnsRange([]string{"score", "embedding"}, int64(p*pageRows), pageRows)
+		if err != nil {
+			t.Fatalf("ReadColumnsRange failed: %v", err)
+		}
+		if !arraysEqual(scores[p], batch.Column(0)) {
+			t.Errorf("page %d: score column mismatch", p)
+		}
+		if !arraysEqual(vectors[p], batch.Column(1)) {
+			t.Errorf("page %d: embedding column mismatch", p)
+		}
+	}
+
+	// About half the bytes of the same vectors at float32
+	pageBytes := reader.footer.GetColumnPages(1)[0].Size
+	if float32Bytes := int32(pageRows * dim * 4); pageBytes > float32Bytes*55/100 {
+		t.Errorf("float16 page takes %d bytes, %d at float32", pageBytes, float32Bytes)
+	}
+}
+
 // ====================
 // Multi-Page Tests
 // ====================
@@ -709,6 +789,27 @@ func TestWriter_SchemaMismatch(t *testing.T) {
 	}
 }
 
+func TestWriter_InvalidBatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test_invalid_batch.lance")
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "data", Type: arrow.PrimInt32(), Nullable: false},
+	}, nil)
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	defer writer.Close()
+
+	// A batch not built by NewRecordBatch is checked before anything is
+	// written
+	if err := writer.WriteRecordBatch(&arrow.RecordBatch{}); err == nil {
+		t.Error("expected error for batch without schema")
+	}
+	if writer.header.NumRows != 0 {
+		t.Errorf("rejected batch counted %d rows", writer.header.NumRows)
+	}
+}
+
 func TestWriter_ClosedWriter(t *testing.T) {
 	tmpDir := t.TempDir()
 	filename := filepath.Join(tmpDir, "test_closed.lance")
@@ -840,6 +941,16 @@ func arraysEqual(a, b arrow.Array) bool {
 				return false
 			}
 		}
+	case *arrow.Float16Array:
+		barr := b.(*arrow.Float16Array)
+		for i := 0; i < a.Len(); i++ {
+			if a.IsValid(i) != b.IsValid(i) {
+				return false
+			}
+			if a.IsValid(i) && arr.Value(i) != barr.Value(i) {
+				return false
+			}
+		}
 	case *arrow.Float64Array:
 		barr := b.(*arrow.Float64Array)
 		for i := 0; i < a.Len(); i++ {
@@ -857,6 +968,26 @@ func arraysEqual(a, b arrow.Array) bool {
 		}
 		// Compare child arrays
 		return arraysEqual(arr.Values(), barr.Values())
+	case *arrow.StringArray:
+		barr := b.(*arrow.StringArray)
+		for i := 0; i < a.Len(); i++ {
+			if a.IsValid(i) != b.IsValid(i) {
+				return false
+			}
+			if a.IsValid(i) && arr.Value(i) != barr.Value(i) {
+				return false
+			}
+		}
+	case *arrow.BinaryArray:
+		barr := b.(*arrow.BinaryArray)
+		for i := 0; i < a.Len(); i++ {
+			if a.IsValid(i) != b.IsValid(i) {
+				return false
+			}
+			if a.IsValid(i) && !bytes.Equal(arr.Value(i), barr.Value(i)) {
+				return false
+			}
+		}
 	default:
 		return false
 	}
@@ -931,6 +1062,67 @@ func BenchmarkWriteVectorArray(b *testing.B) {
 	}
 }
 
+// BenchmarkReadVectorFile reads a 10K x 768 vector column written in pages
+// of 1000; without nulls the pages are decoded and merged without copying
+// each value, with a null per page they take the general path
+func BenchmarkReadVectorFile(b *testing.B) {
+	const dim, numVectors, pageVectors = 768, 10000, 1000
+	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), dim).(*arrow.FixedSizeListType)
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "vector", Type: listType, Nullable: true},
+	}, nil)
+
+	for _, withNulls := range []bool{false, true} {
+		name := "NoNulls"
+		if withNulls {
+			name = "WithNulls"
+		}
+		filename := filepath.Join(b.TempDir(), name+".lance")
+		writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+		if err != nil {
+			b.Fatalf("NewWriter failed: %v", err)
+		}
+		for start := 0; start < numVectors; start += pageVectors {
+			values := make([]float32, pageVectors*dim)
+			for i := range values {
+				values[i] = float32(start*dim+i) * 0.001
+			}
+			var nulls *arrow.Bitmap
+			if withNulls {
+				nulls = arrow.NewBitmap(pageVectors)
+				nulls.SetAll()
+				nulls.Clear(0)
+			}
+			array := arrow.NewFixedSizeListArray(listType, arrow.NewFloat32Array(values, nil), nulls)
+			batch, err := arrow.NewRecordBatch(schema, pageVectors, []arrow.Array{array})
+			if err != nil {
+				b.Fatalf("NewRecordBatch failed: %v", err)
+			}
+			if err := writer.WriteRecordBatch(batch); err != nil {
+				b.Fatalf("WriteRecordBatch failed: %v", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			b.Fatalf("Close failed: %v", err)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(numVectors * dim * 4)
+			for i := 0; i < b.N; i++ {
+				reader, err := NewReader(filename)
+				if err != nil {
+					b.Fatalf("NewReader failed: %v", err)
+				}
+				if _, err := reader.ReadRecordBatch(); err != nil {
+					b.Fatalf("ReadRecordBatch failed: %v", err)
+				}
+				reader.Close()
+			}
+		})
+	}
+}
+
 func BenchmarkFileRoundtrip(b *testing.B) {
 	tmpDir := b.TempDir()
 
@@ -1039,6 +1231,39 @@ func TestEncoderSelection(t *testing.T) {
 	}
 }
 
+// TestDeltaPages 验证单调整数列选择 Delta 并正确解码
+func TestDeltaPages(t *testing.T) {
+	ids := make([]int32, 1000)
+	timestamps := make([]int64, 1000)
+	for i := range ids {
+		ids[i] = int32(1_000_000 + i)
+		timestamps[i] = 1_700_000_000_000 - int64(i)*1000 // 降序
+	}
+
+	for _, array := range []arrow.Array{
+		arrow.NewInt32Array(ids, nil),
+		arrow.NewInt64Array(timestamps, nil),
+	} {
+		writer := NewPageWriter(defaultEncoderFactory())
+		pages, err := writer.WritePages(array, 0)
+		if err != nil {
+			t.Fatalf("WritePages failed: %v", err)
+		}
+		if pages[0].Encoding != format.EncodingDelta {
+			t.Errorf("%s: expected Delta, got %v", array.DataType().Name(), pages[0].Encoding)
+		}
+
+		reader := NewPageReader()
+		result, err := reader.ReadPage(pages[0], array.DataType())
+		if err != nil {
+			t.Fatalf("ReadPage failed: %v", err)
+		}
+		if !arraysEqual(array, result) {
+			t.Errorf("%s: data mismatch after roundtrip", array.DataType().Name())
+		}
+	}
+}
+
 // TestNullFallbackToZstd 验证 specialized encoders 正确处理 null
 func TestNullFallbackToZstd(t *testing.T) {
 	tests := []struct {
@@ -1087,6 +1312,21 @@ func TestNullFallbackToZstd(t *testing.T) {
 			},
 			dtype: arrow.PrimInt32(),
 		},
+		{
+			name: "Delta_with_nulls_fallback",
+			buildArray: func() arrow.Array {
+				builder := arrow.NewInt64Builder()
+				for i := 0; i < 1000; i++ {
+					if i%100 == 7 {
+						builder.AppendNull()
+					} else {
+						builder.Append(1_700_000_000_000 + int64(i)*1000)
+					}
+				}
+				return builder.NewArray()
+			},
+			dtype: arrow.PrimInt64(),
+		},
 		{
 			name: "Dictionary_with_nulls_fallback",
 			buildArray: func() arrow.Array {
@@ -1433,30 +1673,177 @@ func TestEncoderFallback_DictionaryTooLarge(t *testing.T) {
 // P1: FixedSizeList 编码器交互测试
 // ====================
 
-func TestFixedSizeList_AlwaysUsesZstd(t *testing.T) {
+func TestFixedSizeList_EncodingSelection(t *testing.T) {
 	factory := encoding.NewEncoderFactory(3)
 	writer := NewPageWriter(factory)
 
 	dim := 128
 	numVectors := 100
+	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), dim).(*arrow.FixedSizeListType)
 
-	childBuilder := arrow.NewFloat32Builder()
-	for i := 0; i < numVectors*dim; i++ {
-		childBuilder.Append(float32(i))
+	// 低熵（整数值的 float）：应选择 BSS
+	lowEntropy := make([]float32, numVectors*dim)
+	for i := range lowEntropy {
+		lowEntropy[i] = float32(i)
 	}
-	childArray := childBuilder.NewArray()
-
-	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), dim)
-	array := arrow.NewFixedSizeListArray(listType.(*arrow.FixedSizeListType), childArray, nil)
-
-	pages, err := writer.WritePages(array, 0)
+	pages, err := writer.WritePages(arrow.NewFixedSizeListArray(listType, arrow.NewFloat32Array(lowEntropy, nil), nil), 0)
 	if err != nil {
 		t.Fatalf("WritePages failed: %v", err)
 	}
+	if pages[0].Encoding != format.EncodingBSSEncoding {
+		t.Errorf("Low-entropy FixedSizeList should use BSS, but got %v", pages[0].Encoding)
+	}
 
-	// FixedSizeList 应该总是使用 Zstd
+	// 高熵（随机 bit 模式）：应保持 Zstd
+	rng := rand.New(rand.NewSource(1))
+	highEntropy := make([]float32, numVectors*dim)
+	for i := range highEntropy {
+		highEntropy[i] = math.Float32frombits(rng.Uint32()&0x3FFFFFFF | 0x3F000000)
+	}
+	pages, err = writer.WritePages(arrow.NewFixedSizeListArray(listType, arrow.NewFloat32Array(highEntropy, nil), nil), 0)
+	if err != nil {
+		t.Fatalf("WritePages failed: %v", err)
+	}
 	if pages[0].Encoding != format.EncodingZstd {
-		t.Errorf("FixedSizeList should use Zstd, but got %v", pages[0].Encoding)
+		t.Errorf("High-entropy FixedSizeList should use Zstd, but got %v", pages[0].Encoding)
+	}
+}
+
+func TestFixedSizeList_BSSRoundTripWithNulls(t *testing.T) {
+	writer := NewPageWriter(encoding.NewEncoderFactory(3))
+	reader := NewPageReader()
+
+	for _, elem := range []arrow.DataType{arrow.PrimFloat32(), arrow.PrimFloat64()} {
+		t.Run(elem.Name(), func(t *testing.T) {
+			dim := 16
+			numVectors := 1000
+			listType := arrow.FixedSizeListOf(elem, dim).(*arrow.FixedSizeListType)
+
+			var child arrow.Array
+			if elem.ID() == arrow.FLOAT32 {
+				values := make([]float32, numVectors*dim)
+				for i := range values {
+					values[i] = float32(i%97) * 0.25
+				}
+				child = arrow.NewFloat32Array(values, nil)
+			} else {
+				values := make([]float64, numVectors*dim)
+				for i := range values {
+					values[i] = float64(i%97) * 0.25
+				}
+				child = arrow.NewFloat64Array(values, nil)
+			}
+
+			nulls := arrow.NewBitmapAllSet(numVectors)
+			for i := 0; i < numVectors; i += 7 {
+				nulls.Clear(i)
+			}
+			array := arrow.NewFixedSizeListArray(listType, child, nulls)
+
+			pages, err := writer.WritePages(array, 0)
+			if err != nil {
+				t.Fatalf("WritePages failed: %v", err)
+			}
+			if pages[0].Encoding != format.EncodingBSSEncoding {
+				t.Fatalf("Expected BSS encoding, got %v", pages[0].Encoding)
+			}
+
+			result, err := reader.ReadPage(pages[0], listType)
+			if err != nil {
+				t.Fatalf("ReadPage failed: %v", err)
+			}
+			decoded := result.(*arrow.FixedSizeListArray)
+			if decoded.Len() != numVectors || decoded.NullN() != array.NullN() {
+				t.Fatalf("Expected %d lists with %d nulls, got %d with %d",
+					numVectors, array.NullN(), decoded.Len(), decoded.NullN())
+			}
+			for i := 0; i < numVectors; i++ {
+				if decoded.IsNull(i) != array.IsNull(i) {
+					t.Fatalf("List %d: null mismatch", i)
+				}
+			}
+			for i := 0; i < numVectors*dim; i++ {
+				var want, got float64
+				switch c := child.(type) {
+				case *arrow.Float32Array:
+					want, got = float64(c.Value(i)), float64(decoded.Values().(*arrow.Float32Array).Value(i))
+				case *arrow.Float64Array:
+					want, got = c.Value(i), decoded.Values().(*arrow.Float64Array).Value(i)
+				}
+				if want != got {
+					t.Fatalf("Value %d: expected %v, got %v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+// TestFixedSizeList_BSSCompressionRatio compares BSS+Zstd against the
+// Zstd-only path on normalized 768-dim embeddings
+func TestFixedSizeList_BSSCompressionRatio(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping 10k x 768 compression comparison in short mode")
+	}
+
+	const numVectors, dim = 10000, 768
+	rng := rand.New(rand.NewSource(42))
+	values := make([]float32, numVectors*dim)
+	for i := 0; i < numVectors; i++ {
+		vec := values[i*dim : (i+1)*dim]
+		var norm float64
+		for j := range vec {
+			x := rng.NormFloat64()
+			vec[j] = float32(x)
+			norm += x * x
+		}
+		norm = math.Sqrt(norm)
+		for j := range vec {
+			vec[j] = float32(float64(vec[j]) / norm)
+		}
+	}
+	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), dim).(*arrow.FixedSizeListType)
+	array := arrow.NewFixedSizeListArray(listType, arrow.NewFloat32Array(values, nil), nil)
+
+	// Unit-norm embeddings sit around 5 bits of average byte entropy (the
+	// exponent byte is far lower), above the 4-bit default threshold
+	stats := encoding.ComputeStatistics(array)
+	t.Logf("Average byte-position entropy: %.2f bits", stats.GetAverageEntropy())
+
+	encode := func(threshold float64) *format.Page {
+		config := encoding.DefaultEncoderConfig()
+		config.BSSEntropyThreshold = threshold
+		writer := NewPageWriter(encoding.NewEncoderFactoryWithConfig(3, config))
+		pages, err := writer.WritePages(array, 0)
+		if err != nil {
+			t.Fatalf("WritePages failed: %v", err)
+		}
+		return pages[0]
+	}
+
+	zstdPage := encode(0)
+	bssPage := encode(6)
+	if zstdPage.Encoding != format.EncodingZstd || bssPage.Encoding != format.EncodingBSSEncoding {
+		t.Fatalf("Unexpected encodings: %v and %v", zstdPage.Encoding, bssPage.Encoding)
+	}
+
+	raw := numVectors * dim * 4
+	saving := 1 - float64(len(bssPage.Data))/float64(len(zstdPage.Data))
+	t.Logf("Raw %d bytes, Zstd %d (%.3fx), BSS+Zstd %d (%.3fx), %.1f%% smaller",
+		raw, len(zstdPage.Data), float64(raw)/float64(len(zstdPage.Data)),
+		len(bssPage.Data), float64(raw)/float64(len(bssPage.Data)), saving*100)
+	if saving < 0.10 {
+		t.Errorf("Expected BSS+Zstd to be at least 10%% smaller than Zstd, got %.1f%%", saving*100)
+	}
+
+	result, err := NewPageReader().ReadPage(bssPage, listType)
+	if err != nil {
+		t.Fatalf("ReadPage failed: %v", err)
+	}
+	decoded := result.(*arrow.FixedSizeListArray).Values().(*arrow.Float32Array).Values()
+	for i := range values {
+		if decoded[i] != values[i] {
+			t.Fatalf("Value %d: expected %v, got %v", i, values[i], decoded[i])
+		}
 	}
 }
 
@@ -1524,7 +1911,7 @@ func TestEncoderFactory_ExtremeThresholds(t *testing.T) {
 
 func TestWriter_HeaderExceedsReservedSize(t *testing.T) {
 	tmpDir := t.TempDir()
-	filename := tmpDir + "/huge_schema.lance"
+	filename := filepath.Join(tmpDir, "huge_schema.lance")
 
 	// 创建一个超大 Schema（超过 8KB）
 	fields := make([]arrow.Field, 1000) // 1000 列
@@ -1545,3 +1932,88 @@ func TestWriter_HeaderExceedsReservedSize(t *testing.T) {
 		t.Logf("Large schema rejected as expected: %v", err)
 	}
 }
+
+// TestWriter_StreamsPages writes far more data than one batch and checks
+// that the heap stays bounded by a batch, not the file
+func TestWriter_StreamsPages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping streaming write test in short mode")
+	}
+
+	filename := filepath.Join(t.TempDir(), "stream.lance")
+
+	const (
+		numBatches = 1000
+		batchRows  = 512
+		dim        = 128
+	)
+	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), dim)
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "embedding", Type: listType, Nullable: false},
+	}, nil)
+
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	batchOf := func(b int) *arrow.RecordBatch {
+		child := arrow.NewFloat32Builder()
+		child.Reserve(batchRows * dim)
+		for i := 0; i < batchRows*dim; i++ {
+			child.Append(float32(b) + rng.Float32())
+		}
+		vectors := arrow.NewFixedSizeListArray(listType.(*arrow.FixedSizeListType), child.NewArray(), nil)
+		batch, err := arrow.NewRecordBatch(schema, batchRows, []arrow.Array{vectors})
+		if err != nil {
+			t.Fatalf("NewRecordBatch failed: %v", err)
+		}
+		return batch
+	}
+
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	baseline, peak := stats.HeapAlloc, stats.HeapAlloc
+
+	for b := 0; b < numBatches; b++ {
+		if err := writer.WriteRecordBatch(batchOf(b)); err != nil {
+			t.Fatalf("WriteRecordBatch %d failed: %v", b, err)
+		}
+		if b%10 == 0 {
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapAlloc)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	total := uint64(numBatches * batchRows * dim * 4)
+	if grown := peak - min(peak, baseline); grown > total/4 {
+		t.Errorf("Heap grew by %d bytes writing %d bytes; pages are being buffered", grown, total)
+	}
+
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	if reader.NumRows() != numBatches*batchRows {
+		t.Fatalf("Expected %d rows, got %d", numBatches*batchRows, reader.NumRows())
+	}
+
+	// Regenerate the batches to check each of them read back
+	rng = rand.New(rand.NewSource(1))
+	for b := 0; b < numBatches; b++ {
+		want := batchOf(b)
+		got, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next %d failed: %v", b, err)
+		}
+		if !arraysEqual(want.Column(0), got.Column(0)) {
+			t.Fatalf("Batch %d does not match what was written", b)
+		}
+	}
+}
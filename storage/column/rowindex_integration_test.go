@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
 	"github.com/wzqhbustb/vego/storage/format"
 )
 
@@ -526,3 +527,79 @@ func TestBlockCache_NoCacheV10(t *testing.T) {
 		t.Error("HasBlockCache() = true for V1.0 file, want false")
 	}
 }
+
+// TestRowIndexWriterFloat16NeedsV14 checks float16 columns are refused in
+// files stamped with a version older readers would misread them under
+func TestRowIndexWriterFloat16NeedsV14(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		arrow.NewField("id", arrow.PrimInt64(), false),
+		arrow.NewField("embedding", arrow.Float16VectorType(8), false),
+	}, nil)
+
+	filename := filepath.Join(t.TempDir(), "float16_v13.lance")
+	if _, err := NewRowIndexWriter(filename, schema, format.V1_3, nil); !lerrors.Is(err, lerrors.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported for float16 in a V1.3 file, got %v", err)
+	}
+
+	writer, err := NewRowIndexWriter(filepath.Join(t.TempDir(), "float16_v14.lance"), schema, format.V1_4, nil)
+	if err != nil {
+		t.Fatalf("Failed to create V1.4 writer: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// TestRowIndexWriterDeltaNeedsV15 checks Delta is only picked for monotonic
+// columns of files stamped V1.5 or later
+func TestRowIndexWriterDeltaNeedsV15(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		arrow.NewField("ts", arrow.PrimInt64(), false),
+	}, nil)
+	timestamps := make([]int64, 1000)
+	for i := range timestamps {
+		timestamps[i] = 1_700_000_000_000 + int64(i)*1000
+	}
+	batch, err := arrow.NewRecordBatch(schema, len(timestamps), []arrow.Array{arrow.NewInt64Array(timestamps, nil)})
+	if err != nil {
+		t.Fatalf("NewRecordBatch failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		version format.VersionPolicy
+		delta   bool
+	}{
+		{format.V1_4, false},
+		{format.V1_5, true},
+	} {
+		filename := filepath.Join(t.TempDir(), "delta.lance")
+		writer, err := NewRowIndexWriter(filename, schema, tc.version, nil)
+		if err != nil {
+			t.Fatalf("V%s: failed to create writer: %v", tc.version, err)
+		}
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			t.Fatalf("V%s: WriteRecordBatch failed: %v", tc.version, err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("V%s: Close failed: %v", tc.version, err)
+		}
+
+		reader, err := NewReader(filename)
+		if err != nil {
+			t.Fatalf("V%s: NewReader failed: %v", tc.version, err)
+		}
+		for _, idx := range reader.footer.PageIndexList.Indices {
+			if got := idx.Encoding == format.EncodingDelta; got != tc.delta {
+				t.Errorf("V%s: page %d encoded %v", tc.version, idx.PageNum, idx.Encoding)
+			}
+		}
+		read, err := reader.ReadRecordBatch()
+		if err != nil {
+			t.Fatalf("V%s: ReadRecordBatch failed: %v", tc.version, err)
+		}
+		if !arraysEqual(batch.Column(0), read.Column(0)) {
+			t.Errorf("V%s: data mismatch after roundtrip", tc.version)
+		}
+		reader.Close()
+	}
+}
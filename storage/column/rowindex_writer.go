@@ -27,6 +27,27 @@ func NewRowIndexWriter(filename string, schema *arrow.Schema, version format.Ver
 		factory = encoding.NewEncoderFactory(3)
 	}
 
+	// Readers of older versions do not know the float16 type
+	if !version.HasFeature(format.FeatureFloat16) {
+		for _, field := range schema.Fields() {
+			if hasFloat16(field.Type) {
+				return nil, lerrors.New(lerrors.ErrNotSupported).
+					Op("new_rowindex_writer").
+					Context("field", field.Name).
+					Context("version", version.String()).
+					Context("message", "float16 columns need format V1.4").
+					Build()
+			}
+		}
+	}
+
+	// Nor Delta encoded pages
+	if !version.HasFeature(format.FeatureDeltaEncoding) && factory.Config().EnableDeltaEncoding {
+		config := factory.Config()
+		config.EnableDeltaEncoding = false
+		factory = factory.WithConfig(&config)
+	}
+
 	writer, err := NewWriter(filename, schema, factory)
 	if err != nil {
 		return nil, err
@@ -42,6 +63,19 @@ func NewRowIndexWriter(filename string, schema *arrow.Schema, version format.Ver
 	}, nil
 }
 
+// hasFloat16 reports whether dtype is float16 or a list of float16
+func hasFloat16(dtype arrow.DataType) bool {
+	switch t := dtype.(type) {
+	case *arrow.Float16Type:
+		return true
+	case *arrow.FixedSizeListType:
+		return hasFloat16(t.Elem())
+	case *arrow.ListType:
+		return hasFloat16(t.Elem())
+	}
+	return false
+}
+
 // SetBlockSize sets the block size hint for BlockCache
 // Only meaningful for V1.2+ files
 func (w *RowIndexWriter) SetBlockSize(blockSize int32) {
@@ -0,0 +1,72 @@
+package column
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+// fieldTypeEqual reports whether a and b are the same type, list sizes and
+// element types included
+func fieldTypeEqual(a, b arrow.DataType) bool {
+	return a.ID() == b.ID() && a.Name() == b.Name()
+}
+
+func fieldString(f arrow.Field) string {
+	return fmt.Sprintf("%s %s nullable=%t id=%d", f.Name, f.Type.Name(), f.Nullable, f.ID)
+}
+
+// projectSchema resolves the fields of schema among the columns of file,
+// the schema of the file at path, by field ID. It returns the file column of
+// every field, or -1 for a nullable field the file does not have. File
+// columns schema does not ask for are left out; a field may be renamed but
+// not change type, nor become non-nullable when the file's column is
+// nullable. The error lists every field that breaks these rules.
+func projectSchema(path string, file, schema *arrow.Schema) ([]int, error) {
+	cols := make([]int, schema.NumFields())
+	var problems []string
+	for i, field := range schema.Fields() {
+		stored, col, ok := file.FieldByID(field.ID)
+		switch {
+		case !ok && !field.Nullable:
+			problems = append(problems, fmt.Sprintf("%s (id %d): non-nullable field missing from file",
+				field.Name, field.ID))
+		case !ok:
+			col = -1
+		case !fieldTypeEqual(stored.Type, field.Type):
+			problems = append(problems, fmt.Sprintf("%s (id %d): type %s, file has %s",
+				field.Name, field.ID, field.Type.Name(), stored.Type.Name()))
+		case stored.Nullable && !field.Nullable:
+			problems = append(problems, fmt.Sprintf("%s (id %d): non-nullable, file column is nullable",
+				field.Name, field.ID))
+		}
+		cols[i] = col
+	}
+	if len(problems) > 0 {
+		return nil, lerrors.New(lerrors.ErrSchemaMismatch).
+			Op("project_schema").
+			Path(path).
+			Context("fields", strings.Join(problems, "; ")).
+			Context("message", fmt.Sprintf("%d incompatible fields", len(problems))).
+			Build()
+	}
+	return cols, nil
+}
+
+// nullArray returns an array of n nulls of type dtype
+func nullArray(dtype arrow.DataType, n int) (arrow.Array, error) {
+	switch dtype.ID() {
+	case arrow.INT32, arrow.INT64, arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64, arrow.FIXED_SIZE_LIST, arrow.LIST,
+		arrow.STRING, arrow.BINARY:
+	default:
+		return nil, lerrors.UnsupportedType("null_array", dtype.Name(), "")
+	}
+	builder := arrow.NewBuilderForType(dtype)
+	builder.Reserve(n)
+	for i := 0; i < n; i++ {
+		builder.AppendNull()
+	}
+	return builder.NewArray(), nil
+}
@@ -0,0 +1,219 @@
+package column
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+func TestReadRecordBatchWithSchema(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v1.lance")
+	v1 := mergeTestSchema(true, mergeTestDim) // id 1, score 2, vector 3
+	rows := writeMergeSource(t, filename, v1, 0, []int{40, 60})
+
+	asyncIO := setupAsyncIO(t)
+	defer asyncIO.Close()
+	for _, async := range []bool{false, true} {
+		var reader *Reader
+		var err error
+		if async {
+			reader, err = NewReaderWithAsyncIO(filename, asyncIO)
+		} else {
+			reader, err = NewReader(filename)
+		}
+		if err != nil {
+			t.Fatalf("open reader failed: %v", err)
+		}
+		defer reader.Close()
+
+		// v2 adds a nullable column, which the v1 file reads as all null
+		v2 := arrow.NewSchema(append(append([]arrow.Field(nil), v1.Fields()...),
+			arrow.Field{ID: 4, Name: "label", Type: arrow.PrimInt64(), Nullable: true}), nil)
+		batch, err := reader.ReadRecordBatchWithSchema(v2)
+		if err != nil {
+			t.Fatalf("async=%t: read v2 failed: %v", async, err)
+		}
+		if batch.NumRows() != rows || batch.NumCols() != 4 {
+			t.Fatalf("async=%t: v2 batch has %d rows, %d columns", async, batch.NumRows(), batch.NumCols())
+		}
+		if label := batch.Column(3); label.NullN() != rows {
+			t.Errorf("async=%t: added column has %d nulls, want %d", async, label.NullN(), rows)
+		}
+		if id := batch.Column(0).(*arrow.Int32Array); id.Value(57) != 57 {
+			t.Errorf("async=%t: id[57] = %d", async, id.Value(57))
+		}
+
+		// A pruned schema reads only its columns, in its order, under
+		// their new names
+		pruned := arrow.NewSchema([]arrow.Field{
+			{ID: 3, Name: "embedding", Type: arrow.FixedSizeListOf(arrow.PrimFloat32(), mergeTestDim), Nullable: false},
+			{ID: 1, Name: "row", Type: arrow.PrimInt32(), Nullable: false},
+		}, nil)
+		batch, err = reader.ReadRecordBatchWithSchema(pruned)
+		if err != nil {
+			t.Fatalf("async=%t: read pruned failed: %v", async, err)
+		}
+		if batch.NumCols() != 2 || batch.Schema().Field(0).Name != "embedding" {
+			t.Fatalf("async=%t: pruned batch schema %s", async, batch.Schema())
+		}
+		values := batch.Column(0).(*arrow.FixedSizeListArray).Values().(*arrow.Float32Array)
+		if got := values.Value(57 * mergeTestDim); got != float32(57*mergeTestDim) {
+			t.Errorf("async=%t: embedding[57][0] = %v", async, got)
+		}
+		if id := batch.Column(1).(*arrow.Int32Array); id.Value(99) != 99 {
+			t.Errorf("async=%t: row[99] = %d", async, id.Value(99))
+		}
+	}
+}
+
+func TestReadRecordBatchWithSchema_Incompatible(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v1.lance")
+	writeMergeSource(t, filename, mergeTestSchema(true, mergeTestDim), 0, []int{10})
+	reader, err := NewReader(filename)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+
+	vector := arrow.Field{ID: 3, Name: "vector", Type: arrow.FixedSizeListOf(arrow.PrimFloat32(), mergeTestDim)}
+	cases := map[string]arrow.Field{
+		"type change":        {ID: 2, Name: "score", Type: arrow.PrimInt64(), Nullable: true},
+		"dimension change":   {ID: 3, Name: "vector", Type: arrow.FixedSizeListOf(arrow.PrimFloat32(), mergeTestDim+1)},
+		"nullable narrowing": {ID: 2, Name: "score", Type: arrow.PrimFloat64(), Nullable: false},
+		"missing required":   {ID: 9, Name: "extra", Type: arrow.PrimInt32(), Nullable: false},
+	}
+	for name, field := range cases {
+		fields := []arrow.Field{field}
+		if field.ID != vector.ID {
+			fields = append(fields, vector)
+		}
+		_, err := reader.ReadRecordBatchWithSchema(arrow.NewSchema(fields, nil))
+		if !lerrors.Is(err, lerrors.ErrSchemaMismatch) {
+			t.Errorf("%s: expected ErrSchemaMismatch, got %v", name, err)
+		}
+	}
+}
+
+// TestReaderOption_Schema reads a v1 file through a v2 schema that adds a
+// nullable column, with every kind of read
+func TestReaderOption_Schema(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v1.lance")
+	v1 := mergeTestSchema(true, mergeTestDim) // id 1, score 2, vector 3
+	rows := writeMergeSource(t, filename, v1, 0, []int{40, 60})
+
+	v2 := arrow.NewSchema(append(append([]arrow.Field(nil), v1.Fields()...),
+		arrow.Field{ID: 4, Name: "label", Type: arrow.PrimInt64(), Nullable: true}), nil)
+	reader, err := NewReaderWithOptions(filename, nil, ReaderOption{Schema: v2})
+	if err != nil {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer reader.Close()
+	if !reader.Schema().Equal(v2) || !reader.FileSchema().Equal(v1) {
+		t.Fatalf("Schema %s, FileSchema %s", reader.Schema(), reader.FileSchema())
+	}
+
+	batch, err := reader.ReadRecordBatch()
+	if err != nil {
+		t.Fatalf("ReadRecordBatch failed: %v", err)
+	}
+	if batch.NumCols() != 4 || batch.Column(3).Len() != rows || batch.Column(3).NullN() != rows {
+		t.Fatalf("batch has %d columns, label %d values, %d null",
+			batch.NumCols(), batch.Column(3).Len(), batch.Column(3).NullN())
+	}
+	if id := batch.Column(0).(*arrow.Int32Array); id.Value(57) != 57 {
+		t.Errorf("id[57] = %d", id.Value(57))
+	}
+
+	batch, err = reader.ReadColumnsRange([]string{"label", "id"}, 30, 20)
+	if err != nil {
+		t.Fatalf("ReadColumnsRange failed: %v", err)
+	}
+	if batch.NumRows() != 20 || batch.Column(0).NullN() != 20 {
+		t.Errorf("range has %d rows, %d null labels", batch.NumRows(), batch.Column(0).NullN())
+	}
+	if id := batch.Column(1).(*arrow.Int32Array); id.Value(0) != 30 {
+		t.Errorf("id[30] = %d", id.Value(0))
+	}
+
+	for i := 0; i < reader.NumBatches(); i++ {
+		batch, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if batch.NumCols() != 4 || batch.Column(3).NullN() != batch.NumRows() {
+			t.Errorf("batch %d: %d columns, %d of %d labels null",
+				i, batch.NumCols(), batch.Column(3).NullN(), batch.NumRows())
+		}
+	}
+
+	batch, err = reader.ReadColumnsWhere("label", ValueRange{Min: math.Inf(-1), Max: math.Inf(1)})
+	if err != nil {
+		t.Fatalf("ReadColumnsWhere failed: %v", err)
+	}
+	if batch.NumRows() != 0 || batch.NumCols() != 4 {
+		t.Errorf("all-null label matched %d rows of %d columns", batch.NumRows(), batch.NumCols())
+	}
+}
+
+func TestReaderOption_SchemaIncompatible(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v1.lance")
+	v1 := mergeTestSchema(true, mergeTestDim)
+	writeMergeSource(t, filename, v1, 0, []int{10})
+
+	// id changes from int32 to float32, and a required field is added
+	fields := append([]arrow.Field(nil), v1.Fields()...)
+	fields[0].Type = arrow.PrimFloat32()
+	fields = append(fields, arrow.Field{ID: 9, Name: "extra", Type: arrow.PrimInt32(), Nullable: false})
+	_, err := NewReaderWithOptions(filename, nil, ReaderOption{Schema: arrow.NewSchema(fields, nil)})
+	if !lerrors.Is(err, lerrors.ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch, got %v", err)
+	}
+	for _, want := range []string{"id (id 1): type float32, file has int32", "extra (id 9)", "2 incompatible fields", filename} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestWriter_ColumnOrderByFieldID(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ordered.lance")
+	schema := arrow.NewSchema([]arrow.Field{
+		{ID: 1, Name: "a", Type: arrow.PrimInt32()},
+		{ID: 2, Name: "b", Type: arrow.PrimInt64()},
+	}, nil)
+	writer, err := NewWriter(filename, schema, defaultEncoderFactory())
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	// The batch lists the fields the other way round
+	swapped := arrow.NewSchema([]arrow.Field{schema.Field(1), schema.Field(0)}, nil)
+	batch, err := arrow.NewRecordBatch(swapped, 2, []arrow.Array{
+		arrow.NewInt64Array([]int64{20, 21}, nil),
+		arrow.NewInt32Array([]int32{10, 11}, nil),
+	})
+	if err != nil {
+		t.Fatalf("NewRecordBatch failed: %v", err)
+	}
+	if err := writer.WriteRecordBatch(batch); err != nil {
+		t.Fatalf("WriteRecordBatch failed: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	read := readMergeTestFile(t, filename)
+	if !read.Schema().Equal(schema) {
+		t.Fatalf("file schema %s, want %s", read.Schema(), schema)
+	}
+	if a := read.Column(0).(*arrow.Int32Array); a.Value(1) != 11 {
+		t.Errorf("a[1] = %d, want 11", a.Value(1))
+	}
+	if b := read.Column(1).(*arrow.Int64Array); b.Value(1) != 21 {
+		t.Errorf("b[1] = %d, want 21", b.Value(1))
+	}
+}
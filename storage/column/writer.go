@@ -8,6 +8,10 @@ import (
 	lerrors "github.com/wzqhbustb/vego/storage/errors"
 	"github.com/wzqhbustb/vego/storage/format"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -16,7 +20,10 @@ const (
 	HeaderReservedSize = 8192 // 8KB should be enough for any reasonable schema
 )
 
-// Writer writes RecordBatch data to a Lance file
+// Writer writes RecordBatch data to a Lance file. Pages are encoded and
+// written as each batch arrives; only their footer index is kept until
+// Close writes the footer and patches the header, so memory is bounded by
+// one batch whatever the size of the file.
 type Writer struct {
 	file       *os.File
 	header     *format.Header
@@ -26,10 +33,30 @@ type Writer struct {
 	currentPos int64 // Current write position
 	factory    *encoding.EncoderFactory
 	closed     bool
+	target     string // File replaced at Close, for OpenWriterAppend
+
+	// summaries totals the pages of each column for the footer
+	summaries []format.ColumnSummary
+
+	options WriterOption
+}
+
+// WriterOption configures how a Writer encodes its batches
+type WriterOption struct {
+	// EncodeWorkers is how many goroutines encode the columns of a batch
+	// concurrently (0 = GOMAXPROCS, 1 = one at a time). Pages are written
+	// in column order whatever the count, so the file does not depend on
+	// it. The factory's EncoderSelector, if any, is called concurrently.
+	EncodeWorkers int
 }
 
 // NewWriter creates a new column writer
 func NewWriter(filename string, schema *arrow.Schema, factory *encoding.EncoderFactory) (*Writer, error) {
+	return NewWriterWithOptions(filename, schema, factory, WriterOption{})
+}
+
+// NewWriterWithOptions creates a column writer configured by opts
+func NewWriterWithOptions(filename string, schema *arrow.Schema, factory *encoding.EncoderFactory, opts WriterOption) (*Writer, error) {
 	file, err := os.Create(filename)
 	if err != nil {
 		return nil, lerrors.IO("new_writer", filename, err)
@@ -47,6 +74,8 @@ func NewWriter(filename string, schema *arrow.Schema, factory *encoding.EncoderF
 		factory:    factory,
 		closed:     false,
 		headerSize: HeaderReservedSize,
+		summaries:  make([]format.ColumnSummary, schema.NumFields()),
+		options:    opts,
 	}
 
 	if err := writer.writeHeaderWithPadding(); err != nil {
@@ -120,22 +149,21 @@ func (w *Writer) WriteRecordBatch(batch *arrow.RecordBatch) error {
 			Build()
 	}
 
-	// Validate schema matches
-	if !w.header.Schema.Equal(batch.Schema()) {
-		return lerrors.New(lerrors.ErrSchemaMismatch).
-			Op("write_record_batch").
-			Context("message", "schema mismatch").
-			Build()
+	// Batches may be assembled without NewRecordBatch, so check the column
+	// lengths again before any of them is written
+	if err := arrow.ValidateColumns("write_record_batch", batch.Schema(), batch.NumRows(), batch.Columns()); err != nil {
+		return err
 	}
 
-	// Update header row count
-	w.header.NumRows += int64(batch.NumRows())
-
-	// Write each column
-	for colIdx := 0; colIdx < batch.NumCols(); colIdx++ {
-		column := batch.Column(colIdx)
-		field := batch.Schema().Field(colIdx)
+	// Resolve the file's columns in the batch by field ID, so the batch may
+	// order its columns differently
+	columns, err := w.batchColumns(batch)
+	if err != nil {
+		return err
+	}
 
+	for colIdx, column := range columns {
+		field := w.header.Schema.Field(colIdx)
 		if err := validateArray(column, field); err != nil {
 			return lerrors.New(lerrors.ErrInvalidArgument).
 				Op("write_record_batch").
@@ -145,33 +173,135 @@ func (w *Writer) WriteRecordBatch(batch *arrow.RecordBatch) error {
 				Wrap(err).
 				Build()
 		}
+	}
 
-		if err := w.writeColumn(int32(colIdx), column); err != nil {
-			return lerrors.New(lerrors.ErrIO).
-				Op("write_record_batch").
-				Context("column_index", colIdx).
-				Context("column_name", field.Name).
-				Context("message", "write column failed").
-				Wrap(err).
-				Build()
+	// Encode every column before writing any, so a column that fails to
+	// encode leaves the file as it was
+	pages, err := w.encodeColumns(columns)
+	if err != nil {
+		return err
+	}
+
+	// Update header row count
+	w.header.NumRows += int64(batch.NumRows())
+
+	// Write each column
+	for colIdx, columnPages := range pages {
+		if err := w.writePages(int32(colIdx), columnPages); err != nil {
+			return w.columnError(colIdx, err)
 		}
 	}
 
 	return nil
 }
 
-// writeColumn writes a single column (Array) to the file
-func (w *Writer) writeColumn(columnIndex int32, array arrow.Array) error {
-	// Convert array to pages
+// columnError wraps the error of column colIdx of a batch
+func (w *Writer) columnError(colIdx int, err error) error {
+	return lerrors.New(lerrors.ErrIO).
+		Op("write_record_batch").
+		Context("column_index", colIdx).
+		Context("column_name", w.header.Schema.Field(colIdx).Name).
+		Context("message", "write column failed").
+		Wrap(err).
+		Build()
+}
+
+// encodeColumns encodes the columns of a batch into pages, up to
+// WriterOption.EncodeWorkers at a time. The PageWriter and its factory
+// keep no state between pages, so the workers share them. Errors are
+// reported in column order either way.
+func (w *Writer) encodeColumns(columns []arrow.Array) ([][]*format.Page, error) {
+	pages := make([][]*format.Page, len(columns))
+	errs := make([]error, len(columns))
+	encode := func(i int) {
+		pages[i], errs[i] = w.encodeColumn(int32(i), columns[i])
+	}
+
+	workers := w.options.EncodeWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers = min(workers, len(columns)); workers > 1 {
+		var next atomic.Int64
+		var wg sync.WaitGroup
+		for n := 0; n < workers; n++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := int(next.Add(1)) - 1; i < len(columns); i = int(next.Add(1)) - 1 {
+					encode(i)
+				}
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i := range columns {
+			encode(i)
+		}
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, w.columnError(i, err)
+		}
+	}
+	return pages, nil
+}
+
+// batchColumns returns the columns of batch in the file's column order. The
+// batch must have a field of the same name, type and nullability for every
+// field ID of the file, and no others.
+func (w *Writer) batchColumns(batch *arrow.RecordBatch) ([]arrow.Array, error) {
+	schema := w.header.Schema
+	if batch.Schema().NumFields() != schema.NumFields() {
+		return nil, lerrors.New(lerrors.ErrSchemaMismatch).
+			Op("write_record_batch").
+			Context("expected_fields", schema.NumFields()).
+			Context("actual_fields", batch.Schema().NumFields()).
+			Context("message", "schema mismatch").
+			Build()
+	}
+
+	columns := make([]arrow.Array, schema.NumFields())
+	for i, field := range schema.Fields() {
+		got, idx, ok := batch.Schema().FieldByID(field.ID)
+		if !ok {
+			return nil, lerrors.New(lerrors.ErrSchemaMismatch).
+				Op("write_record_batch").
+				Context("field", field.Name).
+				Context("field_id", field.ID).
+				Context("message", "field missing from batch").
+				Build()
+		}
+		if got.Name != field.Name || got.Nullable != field.Nullable || !fieldTypeEqual(got.Type, field.Type) {
+			return nil, lerrors.New(lerrors.ErrSchemaMismatch).
+				Op("write_record_batch").
+				Context("field", field.Name).
+				Context("field_id", field.ID).
+				Context("expected_type", fieldString(field)).
+				Context("actual_type", fieldString(got)).
+				Build()
+		}
+		columns[i] = batch.Column(idx)
+	}
+	return columns, nil
+}
+
+// encodeColumn converts a single column (Array) to pages
+func (w *Writer) encodeColumn(columnIndex int32, array arrow.Array) ([]*format.Page, error) {
 	pages, err := w.pageWriter.WritePages(array, columnIndex)
 	if err != nil {
-		return lerrors.New(lerrors.ErrEncodeFailed).
+		return nil, lerrors.New(lerrors.ErrEncodeFailed).
 			Op("write_column").
 			Context("message", "create pages failed").
 			Wrap(err).
 			Build()
 	}
+	return pages, nil
+}
 
+// writePages writes the pages of a single column to the file
+func (w *Writer) writePages(columnIndex int32, pages []*format.Page) error {
 	// Write each page and record metadata
 	for pageNum, page := range pages {
 		// Record current position (relative to file start)
@@ -187,15 +317,16 @@ func (w *Writer) writeColumn(columnIndex int32, array arrow.Array) error {
 		w.currentPos += n
 
 		// Add page index to footer
-		w.footer.PageIndexList.Add(
+		w.footer.PageIndexList.AddWithStats(
 			columnIndex,
 			int32(pageNum),
 			pageOffset,
 			int32(n),
 			page.NumValues,
 			page.Encoding, // 添加 encoding 参数
+			page.Stats,
 		)
-
+		w.summaries[columnIndex].AddPage(int32(n), page.UncompressedSize, page.NumValues, page.Encoding)
 	}
 
 	return nil
@@ -214,6 +345,8 @@ func (w *Writer) Close() error {
 
 	// Update footer
 	w.footer.NumPages = int32(len(w.footer.PageIndexList.Indices))
+	w.footer.ModifiedAt = time.Now().Unix()
+	w.footer.SetColumnSummaries(w.summaries)
 
 	// Write footer at current position (after all pages)
 	if _, err := w.file.Seek(w.currentPos, io.SeekStart); err != nil {
@@ -257,6 +390,10 @@ func (w *Writer) Close() error {
 		return lerrors.IO("rewrite_header", "", err)
 	}
 
+	if w.target != "" {
+		return w.commitAppend()
+	}
+
 	// Close file
 	if err := w.file.Close(); err != nil {
 		return lerrors.IO("close_file", "", err)
@@ -0,0 +1,166 @@
+package column
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// wideTestBatch returns a batch of numCols columns cycling through random
+// int64, random float32, low-cardinality int32 and increasing int64 ones
+func wideTestBatch(tb testing.TB, numCols, numRows int) *arrow.RecordBatch {
+	tb.Helper()
+	rng := rand.New(rand.NewSource(int64(numCols*numRows + 1)))
+	fields := make([]arrow.Field, numCols)
+	columns := make([]arrow.Array, numCols)
+	for c := 0; c < numCols; c++ {
+		name := fmt.Sprintf("c%d", c)
+		switch c % 4 {
+		case 0:
+			values := make([]int64, numRows)
+			for i := range values {
+				values[i] = rng.Int63()
+			}
+			fields[c] = arrow.Field{Name: name, Type: arrow.PrimInt64()}
+			columns[c] = arrow.NewInt64Array(values, nil)
+		case 1:
+			values := make([]float32, numRows)
+			for i := range values {
+				values[i] = rng.Float32()
+			}
+			fields[c] = arrow.Field{Name: name, Type: arrow.PrimFloat32()}
+			columns[c] = arrow.NewFloat32Array(values, nil)
+		case 2:
+			values := make([]int32, numRows)
+			for i := range values {
+				values[i] = int32(rng.Intn(16))
+			}
+			fields[c] = arrow.Field{Name: name, Type: arrow.PrimInt32()}
+			columns[c] = arrow.NewInt32Array(values, nil)
+		default:
+			values := make([]int64, numRows)
+			ts := int64(1_700_000_000_000)
+			for i := range values {
+				ts += 900 + rng.Int63n(200)
+				values[i] = ts
+			}
+			fields[c] = arrow.Field{Name: name, Type: arrow.PrimInt64()}
+			columns[c] = arrow.NewInt64Array(values, nil)
+		}
+	}
+	batch, err := arrow.NewRecordBatch(arrow.NewSchema(fields, nil), numRows, columns)
+	if err != nil {
+		tb.Fatalf("NewRecordBatch failed: %v", err)
+	}
+	return batch
+}
+
+// writeWideFile writes batch to filename twice with workers encoders
+func writeWideFile(tb testing.TB, filename string, batch *arrow.RecordBatch, workers int) {
+	tb.Helper()
+	writer, err := NewWriterWithOptions(filename, batch.Schema(), nil, WriterOption{EncodeWorkers: workers})
+	if err != nil {
+		tb.Fatalf("NewWriterWithOptions failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := writer.WriteRecordBatch(batch); err != nil {
+			tb.Fatalf("WriteRecordBatch failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		tb.Fatalf("Close failed: %v", err)
+	}
+}
+
+// fileDigest returns the SHA-256 of filename with the footer timestamps
+// reset, which are all that may differ between two writes of the same
+// batches
+func fileDigest(t *testing.T, filename string) [sha256.Size]byte {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	tail := len(data) - format.FooterSize
+	footer := &format.Footer{}
+	if _, err := footer.ReadFrom(bytes.NewReader(data[tail:])); err != nil {
+		t.Fatalf("Footer ReadFrom failed: %v", err)
+	}
+	footer.CreatedAt, footer.ModifiedAt = 1, 1
+	var buf bytes.Buffer
+	if _, err := footer.WriteTo(&buf); err != nil {
+		t.Fatalf("Footer WriteTo failed: %v", err)
+	}
+	return sha256.Sum256(append(data[:tail:tail], buf.Bytes()...))
+}
+
+func TestWriterParallelEncoding_Deterministic(t *testing.T) {
+	dir := t.TempDir()
+	batch := wideTestBatch(t, 60, 5000)
+
+	serial := filepath.Join(dir, "serial.lance")
+	writeWideFile(t, serial, batch, 1)
+	want := fileDigest(t, serial)
+
+	for i, workers := range []int{0, 4, 16, 16, 64} {
+		filename := filepath.Join(dir, fmt.Sprintf("parallel-%d.lance", i))
+		writeWideFile(t, filename, batch, workers)
+		if got := fileDigest(t, filename); got != want {
+			t.Errorf("EncodeWorkers %d: file differs from the serial write", workers)
+		}
+	}
+
+	reader, err := NewReader(serial)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer reader.Close()
+	result, err := reader.ReadRowRange(5000, 5000)
+	if err != nil {
+		t.Fatalf("ReadRowRange failed: %v", err)
+	}
+	for c := 0; c < batch.NumCols(); c++ {
+		if !arraysEqual(batch.Column(c), result.Column(c)) {
+			t.Errorf("Column %d differs after the round trip", c)
+		}
+	}
+}
+
+// BenchmarkWriter_ParallelEncoding writes a 50-column x 100K-row batch one
+// column at a time and with a worker per CPU; run it with -cpu to compare
+// core counts
+func BenchmarkWriter_ParallelEncoding(b *testing.B) {
+	batch := wideTestBatch(b, 50, 100_000)
+	dir := b.TempDir()
+
+	for _, bench := range []struct {
+		name    string
+		workers int
+	}{{"Serial", 1}, {"Parallel", 0}} {
+		workers := bench.workers
+		b.Run(bench.name, func(b *testing.B) {
+			// The columns average 6 bytes a value: 8, 4, 4 and 8
+			b.SetBytes(int64(batch.NumCols()) * int64(batch.NumRows()) * 6)
+			for i := 0; i < b.N; i++ {
+				filename := filepath.Join(dir, bench.name+".lance")
+				writer, err := NewWriterWithOptions(filename, batch.Schema(), nil, WriterOption{EncodeWorkers: workers})
+				if err != nil {
+					b.Fatalf("NewWriterWithOptions failed: %v", err)
+				}
+				if err := writer.WriteRecordBatch(batch); err != nil {
+					b.Fatalf("WriteRecordBatch failed: %v", err)
+				}
+				if err := writer.Close(); err != nil {
+					b.Fatalf("Close failed: %v", err)
+				}
+			}
+		})
+	}
+}
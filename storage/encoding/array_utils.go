@@ -1,6 +1,8 @@
 package encoding
 
 import (
+	"encoding/binary"
+
 	lerrors "github.com/wzqhbustb/vego/storage/errors"
 	"github.com/wzqhbustb/vego/storage/arrow"
 	"unsafe"
@@ -16,6 +18,8 @@ func ArrayToBytes(array arrow.Array) ([]byte, error) {
 		return int32SliceToBytes(arr.Values()), nil
 	case *arrow.Int64Array:
 		return int64SliceToBytes(arr.Values()), nil
+	case *arrow.Float16Array:
+		return uint16SliceToBytes(arr.Values()), nil
 	case *arrow.Float32Array:
 		return float32SliceToBytes(arr.Values()), nil
 	case *arrow.Float64Array:
@@ -23,6 +27,10 @@ func ArrayToBytes(array arrow.Array) ([]byte, error) {
 	case *arrow.FixedSizeListArray:
 		// For FixedSizeListArray, recursively get bytes from child array
 		return ArrayToBytes(arr.Values())
+	case *arrow.StringArray:
+		return binaryToBytes(&arr.BinaryArray), nil
+	case *arrow.BinaryArray:
+		return binaryToBytes(arr), nil
 	default:
 		return nil, lerrors.New(lerrors.ErrUnsupportedType).
 			Op("array_to_bytes").
@@ -31,6 +39,20 @@ func ArrayToBytes(array arrow.Array) ([]byte, error) {
 	}
 }
 
+// binaryToBytes returns the Len()+1 offsets of a string or binary array,
+// rebased to start at 0, followed by the value bytes they cover
+func binaryToBytes(arr *arrow.BinaryArray) []byte {
+	offsets := arr.Offsets()
+	base := offsets[0]
+	values := arr.ValueBytes()[base:offsets[len(offsets)-1]]
+
+	buf := make([]byte, 0, len(offsets)*4+len(values))
+	for _, off := range offsets {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(off-base))
+	}
+	return append(buf, values...)
+}
+
 // int32SliceToBytes converts []int32 to []byte without copy (unsafe but efficient).
 // For production, consider using safe copy if memory aliasing is a concern.
 func int32SliceToBytes(values []int32) []byte {
@@ -66,6 +88,17 @@ func int64SliceToBytes(values []int64) []byte {
 	return *(*[]byte)(unsafe.Pointer(&header))
 }
 
+func uint16SliceToBytes(values []uint16) []byte {
+	if len(values) == 0 {
+		return []byte{}
+	}
+	byteLen := len(values) * 2
+	header := *(*sliceHeader)(unsafe.Pointer(&values))
+	header.Len = byteLen
+	header.Cap = byteLen
+	return *(*[]byte)(unsafe.Pointer(&header))
+}
+
 func float32SliceToBytes(values []float32) []byte {
 	if len(values) == 0 {
 		return []byte{}
@@ -24,11 +24,17 @@ func (d *BSSDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Array, err
 			Build()
 	}
 
+	if listType, ok := dtype.(*arrow.FixedSizeListType); ok {
+		return d.decodeFixedSizeList(data, listType)
+	}
+
 	// Read numValues
 	numValues := binary.LittleEndian.Uint32(data[0:4])
 	headerSize := 4
 
 	switch dtype.ID() {
+	case arrow.FLOAT16:
+		return d.decodeFloat16(data[headerSize:], int(numValues))
 	case arrow.FLOAT32:
 		return d.decodeFloat32(data[headerSize:], int(numValues))
 	case arrow.FLOAT64:
@@ -40,6 +46,26 @@ func (d *BSSDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Array, err
 	}
 }
 
+func (d *BSSDecoder) decodeFloat16(data []byte, numValues int) (arrow.Array, error) {
+	// Format: [stream0...][stream1...]
+	expectedSize := numValues * 2
+	if len(data) < expectedSize {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("bss_decode_float16").
+			Context("reason", "insufficient data").
+			Context("expected", expectedSize).
+			Context("actual", len(data)).
+			Build()
+	}
+
+	values := make([]uint16, numValues)
+	for i := 0; i < numValues; i++ {
+		values[i] = uint16(data[i]) | uint16(data[numValues+i])<<8
+	}
+
+	return arrow.NewFloat16Array(values, nil), nil
+}
+
 func (d *BSSDecoder) decodeFloat32(data []byte, numValues int) (arrow.Array, error) {
 	// Format: [stream0...][stream1...][stream2...][stream3...]
 	// Each stream has numValues bytes
@@ -95,3 +121,58 @@ func (d *BSSDecoder) decodeFloat64(data []byte, numValues int) (arrow.Array, err
 
 	return arrow.NewFloat64Array(values, nil), nil
 }
+
+// decodeFixedSizeList reverses FixedSizeListBSSEncoder: it decompresses and
+// un-splits the child values, then re-wraps them with the list null bitmap.
+func (d *BSSDecoder) decodeFixedSizeList(data []byte, listType *arrow.FixedSizeListType) (arrow.Array, error) {
+	if len(data) < 8 {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("bss_decode_fixed_size_list").
+			Context("reason", "data too short for header").
+			Context("min_required", 8).
+			Context("actual", len(data)).
+			Build()
+	}
+
+	numLists := int(binary.LittleEndian.Uint32(data[0:4]))
+	bitmapLen := int(binary.LittleEndian.Uint32(data[4:8]))
+	if bitmapLen != 0 && bitmapLen != (numLists+7)/8 || len(data) < 8+bitmapLen {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("bss_decode_fixed_size_list").
+			Context("reason", "invalid null bitmap length").
+			Context("num_lists", numLists).
+			Context("bitmap_len", bitmapLen).
+			Build()
+	}
+
+	zstdDecoder, err := NewZstdDecoder()
+	if err != nil {
+		return nil, err
+	}
+	split, err := zstdDecoder.decompress(data[8+bitmapLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	child, err := d.Decode(split, listType.Elem())
+	if err != nil {
+		return nil, err
+	}
+	if child.Len() != numLists*listType.Size() {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("bss_decode_fixed_size_list").
+			Context("reason", "child value count mismatch").
+			Context("expected", numLists*listType.Size()).
+			Context("actual", child.Len()).
+			Build()
+	}
+
+	var nullBitmap *arrow.Bitmap
+	if bitmapLen > 0 {
+		bitmap := make([]byte, bitmapLen)
+		copy(bitmap, data[8:8+bitmapLen])
+		nullBitmap = arrow.NewBitmapFromBytes(bitmap, numLists)
+	}
+
+	return arrow.NewFixedSizeListArray(listType, child, nullBitmap), nil
+}
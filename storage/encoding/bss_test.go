@@ -65,6 +65,49 @@ func TestBSSEncoder_Encode_Float32(t *testing.T) {
 	}
 }
 
+func TestBSSEncoder_Encode_Float16(t *testing.T) {
+	values := make([]float32, 100)
+	for i := range values {
+		values[i] = float32(i) * 0.001
+	}
+	array := arrow.NewFloat16ArrayFromFloat32(values, nil)
+
+	encoded, err := NewBSSEncoder().Encode(array)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(encoded.Data) != 4+2*len(values) {
+		t.Errorf("Expected %d bytes, got %d", 4+2*len(values), len(encoded.Data))
+	}
+
+	decoded, err := NewBSSDecoder().Decode(encoded.Data, arrow.PrimFloat16())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	result := decoded.(*arrow.Float16Array)
+	for i, v := range array.Values() {
+		if result.Value(i) != v {
+			t.Fatalf("Value %d: expected %#04x, got %#04x", i, v, result.Value(i))
+		}
+	}
+
+	// Vectors go through FixedSizeListBSSEncoder and back
+	listType := arrow.Float16VectorType(4).(*arrow.FixedSizeListType)
+	list := arrow.NewFixedSizeListArray(listType, array, nil)
+	encoded, err = NewFixedSizeListBSSEncoder(3).Encode(list)
+	if err != nil {
+		t.Fatalf("Encode list failed: %v", err)
+	}
+	decoded, err = NewBSSDecoder().Decode(encoded.Data, listType)
+	if err != nil {
+		t.Fatalf("Decode list failed: %v", err)
+	}
+	child := decoded.(*arrow.FixedSizeListArray).Values().(*arrow.Float16Array)
+	if !bytes.Equal(uint16SliceToBytes(child.Values()), uint16SliceToBytes(array.Values())) {
+		t.Error("Decoded list data mismatch")
+	}
+}
+
 func TestBSSEncoder_Encode_Float64(t *testing.T) {
 	encoder := NewBSSEncoder()
 	decoder := NewBSSDecoder()
@@ -8,7 +8,13 @@ import (
 
 // GetDecoder returns a Decoder for the given encoding type.
 // Returns (nil, nil) for plain encoding (no decoding needed).
+// Encoding types from format.EncodingUserMin up are looked up among the
+// encodings registered with RegisterEncoder.
 func GetDecoder(encodingType format.EncodingType) (Decoder, error) {
+	if encodingType >= format.EncodingUserMin {
+		return registeredDecoder(encodingType)
+	}
+
 	switch encodingType {
 	case format.EncodingPlain:
 		return nil, nil
@@ -23,10 +29,7 @@ func GetDecoder(encodingType format.EncodingType) (Decoder, error) {
 	case format.EncodingBSSEncoding:
 		return NewBSSDecoder(), nil
 	case format.EncodingDelta:
-		return nil, lerrors.New(lerrors.ErrNotSupported).
-			Op("get_decoder").
-			Context("encoding", "delta").
-			Build()
+		return NewDeltaDecoder(), nil
 	default:
 		return nil, lerrors.New(lerrors.ErrUnsupportedType).
 			Op("get_decoder").
@@ -0,0 +1,101 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+type DeltaDecoder struct{}
+
+func NewDeltaDecoder() *DeltaDecoder {
+	return &DeltaDecoder{}
+}
+
+func (d *DeltaDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Array, error) {
+	if len(data) < deltaHeaderSize {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("delta_decode").
+			Context("reason", "data too short for header").
+			Context("min_required", deltaHeaderSize).
+			Context("actual", len(data)).
+			Build()
+	}
+
+	numValues := int(binary.LittleEndian.Uint32(data[0:4]))
+	if numValues == 0 {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("delta_decode").
+			Context("reason", "no values").
+			Build()
+	}
+	if dtype.ID() != arrow.INT32 && dtype.ID() != arrow.INT64 {
+		return nil, lerrors.New(lerrors.ErrUnsupportedType).
+			Op("delta_decode").
+			Context("got_type", fmt.Sprintf("%v", dtype)).
+			Build()
+	}
+
+	values := make([]int64, numValues)
+	values[0] = int64(binary.LittleEndian.Uint64(data[4:12]))
+	pos := deltaHeaderSize
+	for start := 1; start < numValues; start += deltaBlockSize {
+		count := numValues - start
+		if count > deltaBlockSize {
+			count = deltaBlockSize
+		}
+
+		zigzag, n := binary.Uvarint(data[pos:])
+		if n <= 0 || pos+n >= len(data) {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("delta_decode").
+				Context("reason", "block header truncated").
+				Context("offset", pos).
+				Build()
+		}
+		minDelta := zigzagDecode(zigzag)
+		pos += n
+		bitWidth := data[pos]
+		pos++
+		if bitWidth > 64 {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("delta_decode").
+				Context("reason", "invalid bitWidth in block header").
+				Context("bit_width", bitWidth).
+				Build()
+		}
+
+		// 验证数据长度是否足够
+		packedBytes := (count*int(bitWidth) + 7) / 8
+		if len(data)-pos < packedBytes {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("delta_decode").
+				Context("reason", "data truncated").
+				Context("expected", packedBytes).
+				Context("actual", len(data)-pos).
+				Build()
+		}
+
+		block := values[start : start+count]
+		if bitWidth > 0 {
+			copy(block, unpackBitsToInt64(data[pos:pos+packedBytes], count, bitWidth))
+		}
+		pos += packedBytes
+		prev := values[start-1]
+		for i := range block {
+			prev += block[i] + minDelta
+			block[i] = prev
+		}
+	}
+
+	if dtype.ID() == arrow.INT32 {
+		narrowed := make([]int32, numValues)
+		for i, v := range values {
+			narrowed[i] = int32(v)
+		}
+		return arrow.NewInt32Array(narrowed, nil), nil
+	}
+	return arrow.NewInt64Array(values, nil), nil
+}
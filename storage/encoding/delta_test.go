@@ -0,0 +1,298 @@
+package encoding
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// deltaRoundTrip encodes array with Delta, decodes it as dtype and returns
+// the encoded size
+func deltaRoundTrip(t *testing.T, array arrow.Array, dtype arrow.DataType) int {
+	t.Helper()
+	encoded, err := NewDeltaEncoder().Encode(array)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if encoded.Type != format.EncodingDelta {
+		t.Errorf("Expected encoding type Delta, got %v", encoded.Type)
+	}
+
+	decoder, err := GetDecoder(format.EncodingDelta)
+	if err != nil {
+		t.Fatalf("GetDecoder failed: %v", err)
+	}
+	decoded, err := decoder.Decode(encoded.Data, dtype)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Len() != array.Len() {
+		t.Fatalf("Expected %d values, got %d", array.Len(), decoded.Len())
+	}
+
+	switch want := array.(type) {
+	case *arrow.Int32Array:
+		got := decoded.(*arrow.Int32Array)
+		for i := 0; i < want.Len(); i++ {
+			if got.Value(i) != want.Value(i) {
+				t.Fatalf("Value mismatch at %d: expected %d, got %d", i, want.Value(i), got.Value(i))
+			}
+		}
+	case *arrow.Int64Array:
+		got := decoded.(*arrow.Int64Array)
+		for i := 0; i < want.Len(); i++ {
+			if got.Value(i) != want.Value(i) {
+				t.Fatalf("Value mismatch at %d: expected %d, got %d", i, want.Value(i), got.Value(i))
+			}
+		}
+	}
+	if estimate := NewDeltaEncoder().EstimateSize(array); estimate != len(encoded.Data) {
+		t.Errorf("EstimateSize = %d, encoded %d bytes", estimate, len(encoded.Data))
+	}
+	return len(encoded.Data)
+}
+
+func TestDeltaEncoder_Type(t *testing.T) {
+	encoder := NewDeltaEncoder()
+	if encoder.Type() != format.EncodingDelta {
+		t.Errorf("Expected type Delta, got %v", encoder.Type())
+	}
+}
+
+func TestDeltaEncoder_RoundTrip_Int64(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name   string
+		values func() []int64
+	}{
+		{"increasing", func() []int64 {
+			values := make([]int64, 1000)
+			for i := range values {
+				values[i] = 1_700_000_000_000 + int64(i)*1000
+			}
+			return values
+		}},
+		{"decreasing_runs", func() []int64 {
+			// Sawtooth: runs going up and down
+			values := make([]int64, 1000)
+			for i := range values {
+				if (i/100)%2 == 0 {
+					values[i] = int64(i % 100)
+				} else {
+					values[i] = int64(100 - i%100)
+				}
+			}
+			return values
+		}},
+		{"negative_deltas", func() []int64 {
+			values := make([]int64, 1000)
+			v := int64(-5000)
+			for i := range values {
+				v += rng.Int63n(201) - 100
+				values[i] = v
+			}
+			return values
+		}},
+		{"extremes", func() []int64 {
+			// Differences overflow int64 and wrap around
+			return []int64{math.MinInt64, math.MaxInt64, 0, math.MinInt64, -1, math.MaxInt64}
+		}},
+		{"constant", func() []int64 { return []int64{7, 7, 7, 7} }},
+		{"single", func() []int64 { return []int64{-42} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltaRoundTrip(t, arrow.NewInt64Array(tt.values(), nil), arrow.PrimInt64())
+		})
+	}
+}
+
+func TestDeltaEncoder_RoundTrip_Int32(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int32
+	}{
+		{"increasing", []int32{10, 11, 12, 15, 20, 21, 100}},
+		{"decreasing", []int32{100, 90, 80, 79, 78, -5, -1000}},
+		{"extremes", []int32{math.MinInt32, math.MaxInt32, math.MinInt32, 0}},
+		{"single", []int32{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltaRoundTrip(t, arrow.NewInt32Array(tt.values, nil), arrow.PrimInt32())
+		})
+	}
+}
+
+func TestDeltaEncoder_NullNotSupported(t *testing.T) {
+	builder := arrow.NewInt64Builder()
+	builder.Append(1)
+	builder.AppendNull()
+	builder.Append(3)
+	array := builder.NewArray()
+
+	if _, err := NewDeltaEncoder().Encode(array); err != ErrNullNotSupported {
+		t.Errorf("Expected ErrNullNotSupported, got %v", err)
+	}
+}
+
+func TestDeltaEncoder_UnsupportedType(t *testing.T) {
+	encoder := NewDeltaEncoder()
+	if _, err := encoder.Encode(arrow.NewFloat32Array([]float32{1, 2}, nil)); err == nil {
+		t.Error("Expected error for float32 array")
+	}
+	if _, err := encoder.Encode(arrow.NewInt32Array([]int32{}, nil)); err != ErrEmptyArray {
+		t.Errorf("Expected ErrEmptyArray, got %v", err)
+	}
+	if !encoder.SupportsType(arrow.PrimInt64()) || encoder.SupportsType(arrow.PrimFloat64()) {
+		t.Error("SupportsType should accept integers only")
+	}
+}
+
+func TestDeltaDecoder_Corrupted(t *testing.T) {
+	// One block: minDelta 1 in a 1-byte uvarint, then the bit width
+	encoded, err := NewDeltaEncoder().Encode(arrow.NewInt64Array([]int64{1, 2, 4, 1000}, nil))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoder := NewDeltaDecoder()
+
+	if _, err := decoder.Decode(encoded.Data[:5], arrow.PrimInt64()); err == nil {
+		t.Error("Expected error for a truncated header")
+	}
+	if _, err := decoder.Decode(encoded.Data[:deltaHeaderSize+1], arrow.PrimInt64()); err == nil {
+		t.Error("Expected error for a truncated block header")
+	}
+	if _, err := decoder.Decode(encoded.Data[:len(encoded.Data)-1], arrow.PrimInt64()); err == nil {
+		t.Error("Expected error for truncated deltas")
+	}
+	bad := append([]byte(nil), encoded.Data...)
+	bad[deltaHeaderSize+1] = 65
+	if _, err := decoder.Decode(bad, arrow.PrimInt64()); err == nil {
+		t.Error("Expected error for an invalid bit width")
+	}
+	if _, err := decoder.Decode(encoded.Data, arrow.PrimFloat64()); err == nil {
+		t.Error("Expected error for float64")
+	}
+}
+
+func TestComputeStatistics_Sortedness(t *testing.T) {
+	increasing := make([]int64, 1000)
+	for i := range increasing {
+		increasing[i] = 1_000_000_000 + int64(i)*3
+	}
+	stats := ComputeStatistics(arrow.NewInt64Array(increasing, nil))
+	if stats.GetSortedness() != 1 || stats.GetDeltaBitWidth() != 0 {
+		t.Errorf("Sortedness/DeltaBitWidth = %v/%d, want 1/0", stats.GetSortedness(), stats.GetDeltaBitWidth())
+	}
+
+	stats = ComputeStatistics(arrow.NewInt32Array([]int32{5, 4, 2, 1, 0}, nil))
+	if stats.GetSortedness() != 0 || stats.GetDeltaBitWidth() != 1 {
+		t.Errorf("Sortedness/DeltaBitWidth = %v/%d, want 0/1", stats.GetSortedness(), stats.GetDeltaBitWidth())
+	}
+	if clone := stats.Clone(); *clone.Sortedness != 0 || *clone.DeltaBitWidth != 1 {
+		t.Error("Clone lost Sortedness")
+	}
+
+	// Not computed with nulls, which Delta does not encode
+	builder := arrow.NewInt32Builder()
+	builder.Append(1)
+	builder.AppendNull()
+	builder.Append(2)
+	stats = ComputeStatistics(builder.NewArray())
+	if stats.Sortedness != nil || stats.GetSortedness() != 0.5 || stats.GetDeltaBitWidth() != 64 {
+		t.Errorf("Sortedness computed with nulls: %v", stats.Sortedness)
+	}
+}
+
+func TestEncoderFactory_SelectEncoder_Delta(t *testing.T) {
+	factory := NewEncoderFactory(3)
+	timestamps := make([]int64, 1000)
+	rng := rand.New(rand.NewSource(2))
+	ts := int64(1_700_000_000_000)
+	for i := range timestamps {
+		ts += 500 + rng.Int63n(1000)
+		timestamps[i] = ts
+	}
+
+	// Increasing and decreasing columns with a few rows out of order
+	descending := make([]int64, len(timestamps))
+	for i, v := range timestamps {
+		descending[len(timestamps)-1-i] = v
+	}
+	timestamps[500], timestamps[501] = timestamps[501], timestamps[500]
+	for _, values := range [][]int64{timestamps, descending} {
+		array := arrow.NewInt64Array(values, nil)
+		if encoder := factory.SelectEncoder(array.DataType(), ComputeStatistics(array)); encoder.Type() != format.EncodingDelta {
+			t.Errorf("Expected Delta for a sorted column, got %v", encoder.Type())
+		}
+	}
+
+	// Unordered wide values stay with Zstd
+	shuffled := append([]int64(nil), timestamps...)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	array := arrow.NewInt64Array(shuffled, nil)
+	if encoder := factory.SelectEncoder(array.DataType(), ComputeStatistics(array)); encoder.Type() == format.EncodingDelta {
+		t.Error("Delta selected for unordered values")
+	}
+
+	// Disabled by the config
+	config := DefaultEncoderConfig()
+	config.EnableDeltaEncoding = false
+	array = arrow.NewInt64Array(timestamps, nil)
+	if encoder := NewEncoderFactoryWithConfig(3, config).SelectEncoder(array.DataType(), ComputeStatistics(array)); encoder.Type() == format.EncodingDelta {
+		t.Error("Delta selected with EnableDeltaEncoding off")
+	}
+}
+
+// TestDeltaEncoder_CompressionTimestamps compares Delta with Zstd on 1M
+// increasing millisecond timestamps, one a second with up to 10ms of jitter
+func TestDeltaEncoder_CompressionTimestamps(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 1M row compression test in short mode")
+	}
+	const n = 1_000_000
+	rng := rand.New(rand.NewSource(3))
+	values := make([]int64, n)
+	ts := int64(1_700_000_000_000)
+	for i := range values {
+		ts += 990 + rng.Int63n(21)
+		values[i] = ts
+	}
+	array := arrow.NewInt64Array(values, nil)
+
+	deltaSize := deltaRoundTrip(t, array, arrow.PrimInt64())
+	zstd, err := NewZstdEncoder(3).Encode(array)
+	if err != nil {
+		t.Fatalf("Zstd encode failed: %v", err)
+	}
+	t.Logf("%d timestamps: raw %d bytes, zstd %d, delta %d", n, n*8, len(zstd.Data), deltaSize)
+	if deltaSize*3 > len(zstd.Data) {
+		t.Errorf("Delta %d bytes, want under a third of Zstd's %d", deltaSize, len(zstd.Data))
+	}
+}
+
+func TestDeltaEncoder_Blocks(t *testing.T) {
+	// A constant step packs at 0 bits; an outlier only widens its block
+	values := make([]int64, 10*deltaBlockSize+1)
+	for i := range values {
+		values[i] = int64(i) * 1000
+	}
+	constant := deltaRoundTrip(t, arrow.NewInt64Array(values, nil), arrow.PrimInt64())
+	if constant > deltaHeaderSize+10*3 {
+		t.Errorf("Constant step encoded in %d bytes", constant)
+	}
+
+	for i := 500; i < len(values); i++ {
+		values[i] += 1 << 40
+	}
+	outlier := deltaRoundTrip(t, arrow.NewInt64Array(values, nil), arrow.PrimInt64())
+	if outlier > constant+deltaBlockSize*41/8+8 {
+		t.Errorf("One outlier grew the encoding from %d to %d bytes", constant, outlier)
+	}
+}
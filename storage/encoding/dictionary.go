@@ -38,6 +38,10 @@ func (e *DictionaryEncoder) Encode(array arrow.Array) (*EncodedData, error) {
 		return e.encodeFloat32(arr)
 	case *arrow.Float64Array:
 		return e.encodeFloat64(arr)
+	case *arrow.StringArray:
+		return e.encodeBinary(&arr.BinaryArray)
+	case *arrow.BinaryArray:
+		return e.encodeBinary(arr)
 	default:
 		return nil, lerrors.New(lerrors.ErrUnsupportedType).
 			Op("dictionary_encode").
@@ -147,6 +151,29 @@ func (e *DictionaryEncoder) encodeFloat64(arr *arrow.Float64Array) (*EncodedData
 	return e.packDictionaryBytes(dictBytes, indices, 8, uint32(len(dictValues)))
 }
 
+// encodeBinary dictionary-encodes string and binary values. Entries vary in
+// width, so the header's value size is 0 and each entry is [len:4][bytes].
+func (e *DictionaryEncoder) encodeBinary(arr *arrow.BinaryArray) (*EncodedData, error) {
+	dict := make(map[string]uint32)
+	var dictBytes []byte
+	indices := make([]uint32, arr.Len())
+
+	for i := range indices {
+		v := arr.Value(i)
+		if idx, ok := dict[string(v)]; ok {
+			indices[i] = idx
+		} else {
+			idx := uint32(len(dict))
+			dict[string(v)] = idx
+			dictBytes = binary.LittleEndian.AppendUint32(dictBytes, uint32(len(v)))
+			dictBytes = append(dictBytes, v...)
+			indices[i] = idx
+		}
+	}
+
+	return e.packDictionaryBytes(dictBytes, indices, 0, uint32(len(dict)))
+}
+
 func (e *DictionaryEncoder) packDictionary(dictValues []int32, indices []uint32, valueSize int) (*EncodedData, error) {
 	// 确定索引大小
 	indexSize := 2
@@ -257,5 +284,6 @@ func (e *DictionaryEncoder) EstimateSize(array arrow.Array) int {
 
 func (e *DictionaryEncoder) SupportsType(dtype arrow.DataType) bool {
 	id := dtype.ID()
-	return id == arrow.INT32 || id == arrow.INT64 || id == arrow.FLOAT32 || id == arrow.FLOAT64
+	return id == arrow.INT32 || id == arrow.INT64 || id == arrow.FLOAT32 || id == arrow.FLOAT64 ||
+		id == arrow.STRING || id == arrow.BINARY
 }
@@ -73,6 +73,16 @@ func (d *DictionaryDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Arr
 				Build()
 		}
 		return d.decodeFloat64(data[offset:], int(numEntries), int(numValues), indexSize)
+	case arrow.STRING, arrow.BINARY:
+		if valueSize != 0 {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("dictionary_decode_binary").
+				Context("reason", "unexpected value size").
+				Context("expected", 0).
+				Context("actual", valueSize).
+				Build()
+		}
+		return d.decodeBinary(data[offset:], dtype, int(numEntries), int(numValues), indexSize)
 	default:
 		return nil, lerrors.New(lerrors.ErrUnsupportedType).
 			Op("dictionary_decode").
@@ -250,3 +260,67 @@ func (d *DictionaryDecoder) decodeFloat64(data []byte, numEntries, numValues, in
 
 	return arrow.NewFloat64Array(values, nil), nil
 }
+
+func (d *DictionaryDecoder) decodeBinary(data []byte, dtype arrow.DataType, numEntries, numValues, indexSize int) (arrow.Array, error) {
+	// Read dictionary: numEntries of [len:4][bytes]
+	dict := make([][]byte, numEntries)
+	offset := 0
+	for i := 0; i < numEntries; i++ {
+		if len(data) < offset+4 {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("dictionary_decode_binary").
+				Context("reason", "insufficient data for dictionary").
+				Context("entry", i).
+				Build()
+		}
+		n := int(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+		if n < 0 || len(data)-offset < n {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("dictionary_decode_binary").
+				Context("reason", "insufficient data for dictionary").
+				Context("entry", i).
+				Context("length", n).
+				Build()
+		}
+		dict[i] = data[offset : offset+n]
+		offset += n
+	}
+
+	indexArraySize := numValues * indexSize
+	if len(data) < offset+indexArraySize {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("dictionary_decode_binary").
+			Context("reason", "insufficient data for indices").
+			Context("expected", offset+indexArraySize).
+			Context("actual", len(data)).
+			Build()
+	}
+
+	// Expand values using indices
+	offsets := make([]int32, numValues+1)
+	var values []byte
+	for i := 0; i < numValues; i++ {
+		var idx int
+		if indexSize == 2 {
+			idx = int(binary.LittleEndian.Uint16(data[offset+i*2:]))
+		} else {
+			idx = int(binary.LittleEndian.Uint32(data[offset+i*4:]))
+		}
+		if idx >= numEntries {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("dictionary_decode_binary").
+				Context("reason", "index out of range").
+				Context("index", idx).
+				Context("num_entries", numEntries).
+				Build()
+		}
+		values = append(values, dict[idx]...)
+		offsets[i+1] = int32(len(values))
+	}
+
+	if dtype.ID() == arrow.STRING {
+		return arrow.NewStringArray(offsets, values, nil), nil
+	}
+	return arrow.NewBinaryArray(offsets, values, nil), nil
+}
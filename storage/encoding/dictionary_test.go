@@ -431,6 +431,55 @@ func TestDictionaryEncoder_SupportsType(t *testing.T) {
 	if !encoder.SupportsType(arrow.PrimFloat64()) {
 		t.Error("Should support Float64")
 	}
+	if !encoder.SupportsType(arrow.PrimString()) || !encoder.SupportsType(arrow.PrimBinary()) {
+		t.Error("Should support String and Binary")
+	}
+}
+
+func TestDictionaryEncoder_String(t *testing.T) {
+	encoder := NewDictionaryEncoder()
+	decoder := NewDictionaryDecoder()
+
+	words := []string{"", "apple", "日本語", "apple", "", "zebra"}
+	builder := arrow.NewStringBuilder()
+	for i := 0; i < 1000; i++ {
+		builder.Append(words[i%len(words)])
+	}
+	// Encode a slice, whose offsets do not start at 0
+	array := builder.NewArray().(*arrow.StringArray).Slice(3, 900)
+
+	encoded, err := encoder.Encode(array)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := decoder.Decode(encoded.Data, arrow.PrimString())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	result := decoded.(*arrow.StringArray)
+	if result.Len() != array.Len() {
+		t.Fatalf("Length mismatch: expected %d, got %d", array.Len(), result.Len())
+	}
+	for i := 0; i < array.Len(); i++ {
+		if result.Value(i) != array.Value(i) {
+			t.Fatalf("Value mismatch at %d: expected %q, got %q", i, array.Value(i), result.Value(i))
+		}
+	}
+
+	// Four distinct values take far less than the raw strings
+	if rawSize := len(array.ValueBytes()); len(encoded.Data) > rawSize/2 {
+		t.Errorf("Encoded size %d, raw size %d", len(encoded.Data), rawSize)
+	}
+
+	// The same bytes decode as binary
+	decoded, err = decoder.Decode(encoded.Data, arrow.PrimBinary())
+	if err != nil {
+		t.Fatalf("Decode as binary failed: %v", err)
+	}
+	if got := decoded.(*arrow.BinaryArray).Value(5); string(got) != "日本語" {
+		t.Errorf("Binary value 5 = %q", got)
+	}
 }
 
 // ====================
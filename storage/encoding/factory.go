@@ -2,6 +2,7 @@ package encoding
 
 import (
 	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
 	"github.com/wzqhbustb/vego/storage/format"
 )
 
@@ -31,10 +32,58 @@ func DefaultEncoderConfig() *EncoderConfig {
 		BSSEntropyThreshold:   4.0,
 		SmallDataThreshold:    100,
 		RLEEarlyThreshold:     0.1,
-		EnableDeltaEncoding:   false,
+		EnableDeltaEncoding:   true,
 	}
 }
 
+// Validate checks that the thresholds are within their meaningful ranges
+func (c *EncoderConfig) Validate() error {
+	invalid := func(field string, value interface{}, reason string) error {
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op("validate_encoder_config").
+			Context("field", field).
+			Context("value", value).
+			Context("reason", reason).
+			Build()
+	}
+
+	switch {
+	case c.BitPackingMaxBitWidth < 1 || c.BitPackingMaxBitWidth > 64:
+		return invalid("BitPackingMaxBitWidth", c.BitPackingMaxBitWidth, "must be in [1, 64]")
+	case c.RLEThreshold < 0 || c.RLEThreshold > 1:
+		return invalid("RLEThreshold", c.RLEThreshold, "must be in [0, 1]")
+	case c.RLEEarlyThreshold < 0 || c.RLEEarlyThreshold > 1:
+		return invalid("RLEEarlyThreshold", c.RLEEarlyThreshold, "must be in [0, 1]")
+	case c.DictionaryThreshold < 0 || c.DictionaryThreshold > 1:
+		return invalid("DictionaryThreshold", c.DictionaryThreshold, "must be in [0, 1]")
+	case c.DictionaryMaxSize <= 0:
+		return invalid("DictionaryMaxSize", c.DictionaryMaxSize, "must be positive")
+	case c.BSSEntropyThreshold < 0 || c.BSSEntropyThreshold > 8:
+		return invalid("BSSEntropyThreshold", c.BSSEntropyThreshold, "must be in [0, 8] bits")
+	case c.SmallDataThreshold < 0:
+		return invalid("SmallDataThreshold", c.SmallDataThreshold, "must not be negative")
+	}
+	return nil
+}
+
+// Zstd compression level bounds accepted by the factory
+const (
+	MinCompressionLevel = 1
+	MaxCompressionLevel = 22
+)
+
+// ValidateCompressionLevel rejects levels outside zstd's [1, 22] range
+func ValidateCompressionLevel(level int) error {
+	if level < MinCompressionLevel || level > MaxCompressionLevel {
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op("validate_compression_level").
+			Context("level", level).
+			Context("reason", "must be in [1, 22]").
+			Build()
+	}
+	return nil
+}
+
 // ====================
 // Encoder Factory
 // ====================
@@ -43,6 +92,7 @@ func DefaultEncoderConfig() *EncoderConfig {
 type EncoderFactory struct {
 	compressionLevel int
 	config           *EncoderConfig
+	selector         EncoderSelector
 }
 
 // NewEncoderFactory creates a new encoder factory with default config
@@ -64,8 +114,39 @@ func NewEncoderFactoryWithConfig(compressionLevel int, config *EncoderConfig) *E
 	}
 }
 
+// WithSelector returns a copy of the factory that asks selector first for
+// every page, so it may pick an encoding registered with RegisterEncoder
+func (f *EncoderFactory) WithSelector(selector EncoderSelector) *EncoderFactory {
+	copied := *f
+	copied.selector = selector
+	return &copied
+}
+
+// WithConfig returns a copy of the factory, keeping its selector, that
+// selects encoders with config
+func (f *EncoderFactory) WithConfig(config *EncoderConfig) *EncoderFactory {
+	copied := *f
+	copied.config = config
+	return &copied
+}
+
+// CompressionLevel returns the zstd level used by created encoders
+func (f *EncoderFactory) CompressionLevel() int {
+	return f.compressionLevel
+}
+
+// Config returns a copy of the factory's encoder configuration
+func (f *EncoderFactory) Config() EncoderConfig {
+	return *f.config
+}
+
 // SelectEncoder selects the best encoder based on data type and statistics
 func (f *EncoderFactory) SelectEncoder(dtype arrow.DataType, stats *Statistics) Encoder {
+	// User encodings picked by the selector come first
+	if encoder := f.selectRegistered(dtype, stats); encoder != nil {
+		return encoder
+	}
+
 	// P0: nil 检查
 	if stats == nil {
 		return NewZstdEncoder(f.compressionLevel)
@@ -79,17 +160,19 @@ func (f *EncoderFactory) SelectEncoder(dtype arrow.DataType, stats *Statistics)
 	switch dtype.ID() {
 	case arrow.INT32, arrow.INT64:
 		return f.selectIntegerEncoder(dtype, stats)
-	case arrow.FLOAT32, arrow.FLOAT64:
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
 		return f.selectFloatEncoder(dtype, stats)
 	case arrow.FIXED_SIZE_LIST:
 		return f.selectFixedSizeListEncoder(dtype, stats)
+	case arrow.STRING, arrow.BINARY:
+		return f.selectVarWidthEncoder(dtype, stats)
 	default:
 		return NewZstdEncoder(f.compressionLevel)
 	}
 }
 
 // selectIntegerEncoder selects encoder for integer types
-// 优先级：RLE (极低 run ratio) > Dictionary (极低基数 <10%) > BitPacking > Dictionary (中等基数) > RLE (中等) > Zstd
+// 优先级：RLE (极低 run ratio) > Dictionary (极低基数 <10%) > Delta (单调) > BitPacking > Dictionary (中等基数) > RLE (中等) > Zstd
 func (f *EncoderFactory) selectIntegerEncoder(dtype arrow.DataType, stats *Statistics) Encoder {
 	maxBitWidth := stats.GetMaxBitWidth()
 	runRatio := stats.GetRunRatio()
@@ -105,23 +188,23 @@ func (f *EncoderFactory) selectIntegerEncoder(dtype arrow.DataType, stats *Stati
 		return f.createDictionaryEncoderWithFallback(stats)
 	}
 
-	// P2: Delta 编码预留
-	if f.config.EnableDeltaEncoding && f.isMonotonic(stats) && maxBitWidth > 16 {
-		// return NewDeltaEncoder()
+	// 第三优先级：Delta（单调的 ID、时间戳，差值远比值本身窄）
+	if f.config.EnableDeltaEncoding && f.isMonotonic(stats) && stats.GetDeltaBitWidth()*2 <= maxBitWidth {
+		return NewDeltaEncoder()
 	}
 
-	// 第三优先级：BitPacking（窄整数）
+	// 第四优先级：BitPacking（窄整数）
 	if maxBitWidth <= uint64(f.config.BitPackingMaxBitWidth) {
 		// Safe cast: maxBitWidth <= 64 (which is < 256)
 		return NewBitPackingEncoder(uint8(maxBitWidth))
 	}
 
-	// 第四优先级：Dictionary（中等基数 10% - 50%）
+	// 第五优先级：Dictionary（中等基数 10% - 50%）
 	if cardRatio < f.config.DictionaryThreshold {
 		return f.createDictionaryEncoderWithFallback(stats)
 	}
 
-	// 第五优先级：RLE（中等 run ratio）
+	// 第六优先级：RLE（中等 run ratio）
 	if runRatio < f.config.RLEThreshold {
 		return NewRLEEncoder()
 	}
@@ -143,19 +226,30 @@ func (f *EncoderFactory) selectFloatEncoder(dtype arrow.DataType, stats *Statist
 	return NewZstdEncoder(f.compressionLevel)
 }
 
-// selectFixedSizeListEncoder handles vector types
+// selectFixedSizeListEncoder handles vector types. stats are computed over
+// the flattened child values. Float vectors whose byte-position entropy is
+// below BSSEntropyThreshold are byte-stream-split before compression; the
+// scalar encoders (RLE, BitPacking, Dictionary) cannot encode list
+// structure, so everything else uses Zstd.
 func (f *EncoderFactory) selectFixedSizeListEncoder(dtype arrow.DataType, stats *Statistics) Encoder {
 	fslType := dtype.(*arrow.FixedSizeListType)
-	elemType := fslType.Elem()
 
-	switch elemType.ID() {
-	case arrow.FLOAT32, arrow.FLOAT64:
-		return f.selectFloatEncoder(elemType, stats)
-	case arrow.INT32, arrow.INT64:
-		return f.selectIntegerEncoder(elemType, stats)
-	default:
-		return NewZstdEncoder(f.compressionLevel)
+	switch fslType.Elem().ID() {
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+		if stats.GetAverageEntropy() < f.config.BSSEntropyThreshold {
+			return NewFixedSizeListBSSEncoder(f.compressionLevel)
+		}
 	}
+	return NewZstdEncoder(f.compressionLevel)
+}
+
+// selectVarWidthEncoder selects encoder for string and binary types:
+// Dictionary for low-cardinality values, Zstd otherwise
+func (f *EncoderFactory) selectVarWidthEncoder(dtype arrow.DataType, stats *Statistics) Encoder {
+	if stats.GetCardinalityRatio() < f.config.DictionaryThreshold {
+		return f.createDictionaryEncoderWithFallback(stats)
+	}
+	return NewZstdEncoder(f.compressionLevel)
 }
 
 // createDictionaryEncoderWithFallback creates Dictionary encoder with fallback to Zstd
@@ -169,10 +263,17 @@ func (f *EncoderFactory) createDictionaryEncoderWithFallback(stats *Statistics)
 	return NewDictionaryEncoder()
 }
 
+// monotonicThreshold is the fraction of adjacent values that must go the
+// same way for isMonotonic, leaving room for a few out-of-order rows
+const monotonicThreshold = 0.95
+
 // isMonotonic checks if data is monotonically increasing/decreasing
 func (f *EncoderFactory) isMonotonic(stats *Statistics) bool {
-	// TODO: implement monotonicity detection in Statistics
-	return false
+	if stats.Sortedness == nil {
+		return false
+	}
+	sortedness := *stats.Sortedness
+	return sortedness >= monotonicThreshold || sortedness <= 1-monotonicThreshold
 }
 
 // GetCompressionLevel returns the compression level
@@ -1,9 +1,11 @@
 package encoding
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
 	"github.com/wzqhbustb/vego/storage/format"
 )
 
@@ -167,6 +169,55 @@ func TestEncoderFactory_SelectEncoder_NilConfig(t *testing.T) {
 	}
 }
 
+func TestEncoderConfig_Validate(t *testing.T) {
+	if err := DefaultEncoderConfig().Validate(); err != nil {
+		t.Fatalf("Default config should be valid: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*EncoderConfig)
+	}{
+		{"zero bit width", func(c *EncoderConfig) { c.BitPackingMaxBitWidth = 0 }},
+		{"bit width over 64", func(c *EncoderConfig) { c.BitPackingMaxBitWidth = 65 }},
+		{"negative RLE threshold", func(c *EncoderConfig) { c.RLEThreshold = -0.1 }},
+		{"dictionary threshold over 1", func(c *EncoderConfig) { c.DictionaryThreshold = 1.5 }},
+		{"zero dictionary size", func(c *EncoderConfig) { c.DictionaryMaxSize = 0 }},
+		{"entropy over 8 bits", func(c *EncoderConfig) { c.BSSEntropyThreshold = 9 }},
+		{"negative small data threshold", func(c *EncoderConfig) { c.SmallDataThreshold = -1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultEncoderConfig()
+			tt.modify(config)
+			if err := config.Validate(); !lerrors.Is(err, lerrors.ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateCompressionLevel(t *testing.T) {
+	for _, level := range []int{1, 3, 9, 19, 22} {
+		if err := ValidateCompressionLevel(level); err != nil {
+			t.Errorf("Level %d should be valid: %v", level, err)
+		}
+	}
+	for _, level := range []int{-1, 0, 23, 100} {
+		if err := ValidateCompressionLevel(level); !lerrors.Is(err, lerrors.ErrInvalidArgument) {
+			t.Errorf("Level %d: expected ErrInvalidArgument, got %v", level, err)
+		}
+	}
+
+	factory := NewEncoderFactoryWithConfig(19, DefaultEncoderConfig())
+	if factory.CompressionLevel() != 19 {
+		t.Errorf("Expected level 19, got %d", factory.CompressionLevel())
+	}
+	if factory.Config() != *DefaultEncoderConfig() {
+		t.Errorf("Expected default config, got %+v", factory.Config())
+	}
+}
+
 func TestEncoderFactory_E2E_Int32(t *testing.T) {
 	factory := NewEncoderFactory(3)
 
@@ -229,3 +280,28 @@ func BenchmarkEncoderFactory_Encode(b *testing.B) {
 		encoder.Encode(arr)
 	}
 }
+
+func TestEncoderFactory_SelectEncoder_String(t *testing.T) {
+	factory := NewEncoderFactory(3)
+
+	// 10 distinct values repeated 10k times use Dictionary
+	builder := arrow.NewStringBuilder()
+	for i := 0; i < 10000; i++ {
+		for v := 0; v < 10; v++ {
+			builder.Append(fmt.Sprintf("category-%d", v))
+		}
+	}
+	stats := ComputeStatistics(builder.NewArray())
+	if encoder := factory.SelectEncoder(arrow.PrimString(), stats); encoder.Type() != format.EncodingDictionary {
+		t.Errorf("Expected Dictionary for low-cardinality strings, got %v", encoder.Type())
+	}
+
+	// Distinct values use Zstd
+	for i := 0; i < 10000; i++ {
+		builder.Append(fmt.Sprintf("id-%d", i))
+	}
+	stats = ComputeStatistics(builder.NewArray())
+	if encoder := factory.SelectEncoder(arrow.PrimString(), stats); encoder.Type() != format.EncodingZstd {
+		t.Errorf("Expected Zstd for unique strings, got %v", encoder.Type())
+	}
+}
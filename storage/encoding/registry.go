@@ -0,0 +1,122 @@
+package encoding
+
+import (
+	"sync"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+// ====================
+// User Encoding Registry
+// ====================
+
+// EncoderFactoryFunc creates the encoder of a registered encoding. It is
+// given the compression level of the EncoderFactory selecting it.
+type EncoderFactoryFunc func(compressionLevel int) Encoder
+
+// DecoderFunc creates the decoder of a registered encoding
+type DecoderFunc func() Decoder
+
+// EncoderSelector picks a registered encoding for a page, see
+// EncoderFactory.WithSelector. It returns ok=false to leave the choice to the
+// built-in selection. stats may be nil.
+type EncoderSelector func(dtype arrow.DataType, stats *Statistics) (id format.EncodingType, ok bool)
+
+type registeredEncoding struct {
+	encoder EncoderFactoryFunc
+	decoder DecoderFunc
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[format.EncodingType]registeredEncoding)
+)
+
+// RegisterEncoder makes a user-defined encoding available to writers and
+// readers in this process. id must be in the user range starting at
+// format.EncodingUserMin and not registered yet. Pages record id as their
+// encoding, so files using it can be read by any process that registers
+// the same encoding under the same id.
+//
+// Encoders of the encoding must return id from Type and in
+// EncodedData.Type. An encoder that cannot handle an array may return
+// ErrNullNotSupported or ErrUnsupportedType, and the page is written with
+// Zstd instead.
+func RegisterEncoder(id format.EncodingType, enc EncoderFactoryFunc, dec DecoderFunc) error {
+	invalid := func(reason string) error {
+		return lerrors.New(lerrors.ErrInvalidArgument).
+			Op("register_encoder").
+			Context("encoding_id", int(id)).
+			Context("reason", reason).
+			Build()
+	}
+	if id < format.EncodingUserMin {
+		return invalid("encoding IDs below 128 are reserved for built-in encodings")
+	}
+	if enc == nil || dec == nil {
+		return invalid("encoder and decoder are required")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[id]; exists {
+		return invalid("encoding ID is already registered")
+	}
+	registry[id] = registeredEncoding{encoder: enc, decoder: dec}
+	return nil
+}
+
+// UnregisterEncoder removes the encoding registered under id, if any.
+// Files using it can no longer be read.
+func UnregisterEncoder(id format.EncodingType) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, id)
+}
+
+// lookupEncoding returns the encoding registered under id
+func lookupEncoding(id format.EncodingType) (registeredEncoding, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[id]
+	return r, ok
+}
+
+// registeredDecoder returns the decoder of the user encoding id, or an
+// error naming id if no encoding is registered under it
+func registeredDecoder(id format.EncodingType) (Decoder, error) {
+	r, ok := lookupEncoding(id)
+	if !ok {
+		return nil, lerrors.New(lerrors.ErrUnsupportedType).
+			Op("get_decoder").
+			Context("encoding", id.String()).
+			Context("encoding_id", int(id)).
+			Context("message", "user encoding is not registered in this process, see encoding.RegisterEncoder").
+			Build()
+	}
+	return r.decoder(), nil
+}
+
+// selectRegistered returns the encoder of the registered encoding the
+// factory's selector picks, or nil to use the built-in selection. Picks of
+// unregistered IDs, and encoders reporting another type, are ignored.
+func (f *EncoderFactory) selectRegistered(dtype arrow.DataType, stats *Statistics) Encoder {
+	if f.selector == nil {
+		return nil
+	}
+	id, ok := f.selector(dtype, stats)
+	if !ok {
+		return nil
+	}
+	r, ok := lookupEncoding(id)
+	if !ok {
+		return nil
+	}
+	encoder := r.encoder(f.compressionLevel)
+	if encoder == nil || encoder.Type() != id {
+		return nil
+	}
+	return encoder
+}
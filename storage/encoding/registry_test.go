@@ -0,0 +1,55 @@
+package encoding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+	"github.com/wzqhbustb/vego/storage/format"
+)
+
+func TestRegisterEncoder(t *testing.T) {
+	const id format.EncodingType = 201
+	enc := func(int) Encoder { return NewRLEEncoder() }
+	dec := func() Decoder { return NewRLEDecoder() }
+
+	// Built-in IDs are reserved
+	for _, reserved := range []format.EncodingType{format.EncodingZstd, format.EncodingUserMin - 1} {
+		if err := RegisterEncoder(reserved, enc, dec); !lerrors.Is(err, lerrors.ErrInvalidArgument) {
+			t.Errorf("RegisterEncoder(%d) = %v, want ErrInvalidArgument", reserved, err)
+		}
+	}
+	if err := RegisterEncoder(id, nil, dec); !lerrors.Is(err, lerrors.ErrInvalidArgument) {
+		t.Errorf("RegisterEncoder without encoder = %v, want ErrInvalidArgument", err)
+	}
+
+	if err := RegisterEncoder(id, enc, dec); err != nil {
+		t.Fatalf("RegisterEncoder failed: %v", err)
+	}
+	if err := RegisterEncoder(id, enc, dec); !lerrors.Is(err, lerrors.ErrInvalidArgument) {
+		t.Errorf("duplicate RegisterEncoder = %v, want ErrInvalidArgument", err)
+	}
+	if decoder, err := GetDecoder(id); err != nil || decoder == nil {
+		t.Errorf("GetDecoder(%d) = %v, %v", id, decoder, err)
+	}
+
+	// The selector only picks registered encodings of the right type
+	stats := ComputeStatistics(createInt32Array([]int32{1, 2, 3}))
+	factory := NewEncoderFactory(3).WithSelector(func(arrow.DataType, *Statistics) (format.EncodingType, bool) {
+		return id, true
+	})
+	if encoder := factory.SelectEncoder(arrow.PrimInt32(), stats); encoder.Type() == id {
+		t.Errorf("selected an encoder reporting type %v for ID %d", encoder.Type(), id)
+	}
+
+	UnregisterEncoder(id)
+	_, err := GetDecoder(id)
+	if !lerrors.Is(err, lerrors.ErrUnsupportedType) || !strings.Contains(err.Error(), "201") {
+		t.Errorf("GetDecoder of an unregistered encoding = %v", err)
+	}
+	if err := RegisterEncoder(id, enc, dec); err != nil {
+		t.Errorf("RegisterEncoder after UnregisterEncoder failed: %v", err)
+	}
+	UnregisterEncoder(id)
+}
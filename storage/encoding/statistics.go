@@ -1,6 +1,7 @@
 package encoding
 
 import (
+	"hash/fnv"
 	"math"
 	"math/bits"
 	"github.com/wzqhbustb/vego/storage/arrow"
@@ -20,6 +21,7 @@ const (
 	StatMaxLength
 	StatRunCount
 	StatBytePositionEntropy
+	StatSortedness
 )
 
 func (s Stat) String() string {
@@ -38,6 +40,8 @@ func (s Stat) String() string {
 		return "RunCount"
 	case StatBytePositionEntropy:
 		return "BytePositionEntropy"
+	case StatSortedness:
+		return "Sortedness"
 	default:
 		return "Unknown"
 	}
@@ -69,6 +73,20 @@ type Statistics struct {
 
 	// BSS (Byte Stream Split) decision
 	BytePositionEntropy *[]uint64 // Entropy per byte position (scaled by 1000)
+
+	// Delta decision, for integer arrays without nulls of 2 values or more:
+	// the fraction of adjacent pairs that do not decrease (1 = sorted
+	// ascending, 0 = strictly descending), and the bit width of the range
+	// of the differences between adjacent values, the most Delta packs
+	// them at
+	Sortedness    *float64
+	DeltaBitWidth *uint64
+
+	// Value range of integer (MinInt/MaxInt) or float (MinFloat/MaxFloat)
+	// arrays over their non-null, non-NaN values; nil if there are none.
+	// Not computed for vectors.
+	MinInt, MaxInt     *int64
+	MinFloat, MaxFloat *float64
 }
 
 // ComputeStatistics computes all relevant statistics for an Arrow array
@@ -90,12 +108,25 @@ func ComputeStatistics(array arrow.Array) *Statistics {
 	switch arr := array.(type) {
 	case *arrow.Int32Array:
 		computeFixedWidthStats(stats, arr.Data().Buffers()[0], 32, arr.Len())
+		computeIntRange(stats, arr, func(i int) int64 { return int64(arr.Value(i)) })
+		computeSortedness(stats, arr, func(i int) int64 { return int64(arr.Value(i)) })
 	case *arrow.Int64Array:
 		computeFixedWidthStats(stats, arr.Data().Buffers()[0], 64, arr.Len())
+		computeIntRange(stats, arr, arr.Value)
+		computeSortedness(stats, arr, arr.Value)
+	case *arrow.Float16Array:
+		computeFloat16Stats(stats, arr.Data().Buffers()[0], arr.Len())
+		computeFloatRange(stats, arr, func(i int) float64 { return float64(arr.Float32(i)) })
 	case *arrow.Float32Array:
 		computeFloat32Stats(stats, arr.Data().Buffers()[0], arr.Len())
+		computeFloatRange(stats, arr, func(i int) float64 { return float64(arr.Value(i)) })
 	case *arrow.Float64Array:
 		computeFloat64Stats(stats, arr.Data().Buffers()[0], arr.Len())
+		computeFloatRange(stats, arr, arr.Value)
+	case *arrow.StringArray:
+		computeVarWidthStats(stats, &arr.BinaryArray)
+	case *arrow.BinaryArray:
+		computeVarWidthStats(stats, arr)
 	case *arrow.FixedSizeListArray:
 		// For FSL (vectors), compute stats on the flattened values
 		values := arr.Values()
@@ -103,8 +134,12 @@ func ComputeStatistics(array arrow.Array) *Statistics {
 		stats.NumValues = int64(values.Len())
 
 		switch valArr := values.(type) {
+		case *arrow.Float16Array:
+			computeFloat16Stats(stats, valArr.Data().Buffers()[0], valArr.Len())
 		case *arrow.Float32Array:
 			computeFloat32Stats(stats, valArr.Data().Buffers()[0], valArr.Len())
+		case *arrow.Float64Array:
+			computeFloat64Stats(stats, valArr.Data().Buffers()[0], valArr.Len())
 		case *arrow.Int32Array:
 			computeFixedWidthStats(stats, valArr.Data().Buffers()[0], 32, valArr.Len())
 		}
@@ -113,6 +148,106 @@ func ComputeStatistics(array arrow.Array) *Statistics {
 	return stats
 }
 
+// computeVarWidthStats sets DataSize, MaxLength and Cardinality of a string
+// or binary array. Cardinality is estimated over 64-bit FNV hashes of the
+// non-null values.
+func computeVarWidthStats(stats *Statistics, arr *arrow.BinaryArray) {
+	offsets := arr.Offsets()
+	dataSize := uint64(offsets[len(offsets)-1] - offsets[0])
+	stats.DataSize = &dataSize
+
+	var maxLength uint64
+	hashes := make([]int64, 0, arr.Len()-arr.NullN())
+	h := fnv.New64a()
+	for i := 0; i < arr.Len(); i++ {
+		if arr.IsNull(i) {
+			continue
+		}
+		value := arr.Value(i)
+		if uint64(len(value)) > maxLength {
+			maxLength = uint64(len(value))
+		}
+		h.Reset()
+		h.Write(value)
+		hashes = append(hashes, int64(h.Sum64()))
+	}
+	stats.MaxLength = &maxLength
+
+	cardinality := computeCardinality64(hashes)
+	stats.Cardinality = &cardinality
+}
+
+// computeIntRange sets MinInt and MaxInt over the non-null values of arr
+func computeIntRange(stats *Statistics, arr arrow.Array, value func(int) int64) {
+	var min, max int64
+	found := false
+	for i := 0; i < arr.Len(); i++ {
+		if arr.IsNull(i) {
+			continue
+		}
+		v := value(i)
+		if !found || v < min {
+			min = v
+		}
+		if !found || v > max {
+			max = v
+		}
+		found = true
+	}
+	if found {
+		stats.MinInt, stats.MaxInt = &min, &max
+	}
+}
+
+// computeSortedness sets Sortedness and DeltaBitWidth of arr. Arrays with
+// nulls, which Delta does not encode, or fewer than 2 values get neither.
+func computeSortedness(stats *Statistics, arr arrow.Array, value func(int) int64) {
+	if arr.NullN() > 0 || arr.Len() < 2 {
+		return
+	}
+	var ascending int
+	var minDelta, maxDelta int64
+	prev := value(0)
+	for i := 1; i < arr.Len(); i++ {
+		v := value(i)
+		if v >= prev {
+			ascending++
+		}
+		delta := v - prev
+		if i == 1 || delta < minDelta {
+			minDelta = delta
+		}
+		if i == 1 || delta > maxDelta {
+			maxDelta = delta
+		}
+		prev = v
+	}
+	sortedness := float64(ascending) / float64(arr.Len()-1)
+	deltaBitWidth := uint64(64 - bits.LeadingZeros64(uint64(maxDelta)-uint64(minDelta)))
+	stats.Sortedness, stats.DeltaBitWidth = &sortedness, &deltaBitWidth
+}
+
+// computeFloatRange sets MinFloat and MaxFloat over the non-null values of
+// arr, ignoring NaNs
+func computeFloatRange(stats *Statistics, arr arrow.Array, value func(int) float64) {
+	min, max := math.Inf(1), math.Inf(-1)
+	found := false
+	for i := 0; i < arr.Len(); i++ {
+		if arr.IsNull(i) {
+			continue
+		}
+		v := value(i)
+		if math.IsNaN(v) {
+			continue
+		}
+		min, max = math.Min(min, v), math.Max(max, v)
+		found = true
+	}
+	if found {
+		stats.MinFloat, stats.MaxFloat = &min, &max
+	}
+}
+
 // computeFixedWidthStats computes statistics for fixed-width integer types
 func computeFixedWidthStats(stats *Statistics, buffer *arrow.Buffer, bitsPerValue int, numValues int) {
 	data := buffer.Bytes()
@@ -225,6 +360,27 @@ func computeFloat32Stats(stats *Statistics, buffer *arrow.Buffer, numValues int)
 	stats.RunCount = &runCount
 }
 
+// computeFloat16Stats computes statistics for float16 arrays, comparing
+// values by their bits like computeRunCountFloat32
+func computeFloat16Stats(stats *Statistics, buffer *arrow.Buffer, numValues int) {
+	data := buffer.Bytes()
+
+	dataSize := uint64(len(data))
+	stats.DataSize = &dataSize
+
+	entropy := computeBytePositionEntropy(data, 2)
+	stats.BytePositionEntropy = &entropy
+
+	values := buffer.Uint16()
+	runCount := uint64(0)
+	for i := range values {
+		if i == 0 || values[i] != values[i-1] {
+			runCount++
+		}
+	}
+	stats.RunCount = &runCount
+}
+
 // computeFloat64Stats computes statistics for float64 arrays
 func computeFloat64Stats(stats *Statistics, buffer *arrow.Buffer, numValues int) {
 	data := buffer.Bytes()
@@ -600,6 +756,24 @@ func (s *Statistics) GetMaxBitWidth() uint64 {
 	return maxWidth
 }
 
+// GetSortedness returns the fraction of adjacent values that do not
+// decrease, or 0.5 (unordered) if it was not computed
+func (s *Statistics) GetSortedness() float64 {
+	if s.Sortedness == nil {
+		return 0.5
+	}
+	return *s.Sortedness
+}
+
+// GetDeltaBitWidth returns the bit width Delta packs differences at, or 64
+// if it was not computed
+func (s *Statistics) GetDeltaBitWidth() uint64 {
+	if s.DeltaBitWidth == nil {
+		return 64
+	}
+	return *s.DeltaBitWidth
+}
+
 // GetAverageEntropy returns the average entropy across all byte positions
 func (s *Statistics) GetAverageEntropy() float64 {
 	if s.BytePositionEntropy == nil || len(*s.BytePositionEntropy) == 0 {
@@ -691,6 +865,10 @@ func (s *Statistics) Validate() error {
 	if s.DataSize != nil && s.NumValues > 0 {
 		// Sanity check: data size should be reasonable relative to number of values
 		maxExpectedSize := uint64(s.NumValues) * 16 // Assume max 16 bytes per value
+		if s.MaxLength != nil {
+			// Variable-width values are at most MaxLength bytes
+			maxExpectedSize = uint64(s.NumValues) * *s.MaxLength
+		}
 		if *s.DataSize > maxExpectedSize {
 			return lerrors.New(lerrors.ErrInvalidArgument).
 				Op("statistics_validate").
@@ -754,5 +932,20 @@ func (s *Statistics) Clone() *Statistics {
 		clone.BytePositionEntropy = &entropy
 	}
 
+	if s.Sortedness != nil {
+		sortedness, deltaBitWidth := *s.Sortedness, *s.DeltaBitWidth
+		clone.Sortedness, clone.DeltaBitWidth = &sortedness, &deltaBitWidth
+	}
+
+	if s.MinInt != nil {
+		minInt, maxInt := *s.MinInt, *s.MaxInt
+		clone.MinInt, clone.MaxInt = &minInt, &maxInt
+	}
+
+	if s.MinFloat != nil {
+		minFloat, maxFloat := *s.MinFloat, *s.MaxFloat
+		clone.MinFloat, clone.MaxFloat = &minFloat, &maxFloat
+	}
+
 	return clone
 }
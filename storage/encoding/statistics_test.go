@@ -362,6 +362,35 @@ func TestComputeStatistics_Float64(t *testing.T) {
 	}
 }
 
+func TestComputeStatistics_ValueRange(t *testing.T) {
+	// Nulls are left out of the range, whatever their slots hold
+	ints := arrow.NewInt64Array([]int64{7, -100, 3, 900, -2},
+		newBitmapFromBools([]bool{true, false, true, false, true}))
+	stats := ComputeStatistics(ints)
+	if stats.MinInt == nil || *stats.MinInt != -2 || *stats.MaxInt != 7 {
+		t.Errorf("Int64 range = %v..%v, want -2..7", stats.MinInt, stats.MaxInt)
+	}
+	if stats.MinFloat != nil {
+		t.Errorf("Int64 array has a float range")
+	}
+
+	// and so are NaNs
+	floats := arrow.NewFloat32Array([]float32{2.5, float32(math.NaN()), -1, 4}, nil)
+	stats = ComputeStatistics(floats)
+	if stats.MinFloat == nil || *stats.MinFloat != -1 || *stats.MaxFloat != 4 {
+		t.Errorf("Float32 range = %v..%v, want -1..4", stats.MinFloat, stats.MaxFloat)
+	}
+	clone := stats.Clone()
+	if clone.MinFloat == stats.MinFloat || *clone.MinFloat != -1 || *clone.MaxFloat != 4 {
+		t.Errorf("Clone does not copy the range")
+	}
+
+	allNull := arrow.NewInt32Array([]int32{1, 2}, newBitmapFromBools([]bool{false, false}))
+	if stats := ComputeStatistics(allNull); stats.MinInt != nil {
+		t.Errorf("All-null array has range %d..%d", *stats.MinInt, *stats.MaxInt)
+	}
+}
+
 // ====================
 // RunCount Tests
 // ====================
@@ -20,31 +20,44 @@ func NewZstdEncoder(level int) *ZstdEncoder {
 	if level < 1 {
 		level = 1
 	}
-	if level > 9 {
-		level = 9
+	if level > MaxCompressionLevel {
+		level = MaxCompressionLevel
 	}
 
-	pool := &sync.Pool{
-		New: func() interface{} {
-			var encoderLevel zstd.EncoderLevel
-			switch {
-			case level <= 3:
-				encoderLevel = zstd.SpeedFastest
-			case level <= 6:
-				encoderLevel = zstd.SpeedDefault
-			case level <= 8:
-				encoderLevel = zstd.SpeedBetterCompression
-			default:
-				encoderLevel = zstd.SpeedBestCompression
-			}
-			enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel))
-			return enc
-		},
+	var encoderLevel zstd.EncoderLevel
+	switch {
+	case level <= 3:
+		encoderLevel = zstd.SpeedFastest
+	case level <= 6:
+		encoderLevel = zstd.SpeedDefault
+	case level <= 8:
+		encoderLevel = zstd.SpeedBetterCompression
+	default: // 9-22
+		encoderLevel = zstd.SpeedBestCompression
 	}
+	pool := zstdEncoderPool(encoderLevel)
 
 	return &ZstdEncoder{level: level, encoderPool: pool}
 }
 
+// zstdEncoderPools holds one *sync.Pool of encoders per zstd.EncoderLevel,
+// shared by all ZstdEncoders: one is created per page, and a zstd encoder
+// allocates megabytes of history on first use
+var zstdEncoderPools sync.Map
+
+func zstdEncoderPool(level zstd.EncoderLevel) *sync.Pool {
+	if pool, ok := zstdEncoderPools.Load(level); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := zstdEncoderPools.LoadOrStore(level, &sync.Pool{
+		New: func() interface{} {
+			enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+			return enc
+		},
+	})
+	return pool.(*sync.Pool)
+}
+
 func (e *ZstdEncoder) Type() format.EncodingType {
 	return format.EncodingZstd
 }
@@ -64,19 +77,22 @@ func (e *ZstdEncoder) Encode(array arrow.Array) (*EncodedData, error) {
 			Build()
 	}
 
-	encoder := e.encoderPool.Get().(*zstd.Encoder)
-	defer e.encoderPool.Put(encoder)
-
-	encoder.Reset(nil)
-	compressed := encoder.EncodeAll(data, make([]byte, 0, len(data)/2))
-
 	return &EncodedData{
-		Data:     compressed,
+		Data:     e.compress(data),
 		Type:     format.EncodingZstd,
 		Metadata: nil,
 	}, nil
 }
 
+// compress zstd-compresses raw bytes
+func (e *ZstdEncoder) compress(data []byte) []byte {
+	encoder := e.encoderPool.Get().(*zstd.Encoder)
+	defer e.encoderPool.Put(encoder)
+
+	encoder.Reset(nil)
+	return encoder.EncodeAll(data, make([]byte, 0, len(data)/2))
+}
+
 func (e *ZstdEncoder) EstimateSize(array arrow.Array) int {
 	// 保守估计：原始大小的 50%
 	return array.Len() * GetValueSize(array.DataType().ID()) / 2
@@ -15,18 +15,21 @@ type ZstdDecoder struct {
 	decoderPool *sync.Pool
 }
 
-func NewZstdDecoder() (*ZstdDecoder, error) {
-	pool := &sync.Pool{
-		New: func() interface{} {
-			dec, err := zstd.NewReader(nil)
-			if err != nil {
-				return err
-			}
-			return dec
-		},
-	}
+// zstdDecoderPool is shared by all ZstdDecoders, like the encoder pools:
+// GetDecoder creates one per page, and a zstd decoder allocates its
+// buffers on first use
+var zstdDecoderPool = &sync.Pool{
+	New: func() interface{} {
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return err
+		}
+		return dec
+	},
+}
 
-	return &ZstdDecoder{decoderPool: pool}, nil
+func NewZstdDecoder() (*ZstdDecoder, error) {
+	return &ZstdDecoder{decoderPool: zstdDecoderPool}, nil
 }
 
 func (d *ZstdDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Array, error) {
@@ -39,6 +42,17 @@ func (d *ZstdDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Array, er
 			Build()
 	}
 
+	decompressed, err := d.decompress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reconstruct array based on type
+	return bytesToArray(decompressed, dtype)
+}
+
+// decompress reverses ZstdEncoder.compress
+func (d *ZstdDecoder) decompress(data []byte) ([]byte, error) {
 	// Get decoder from pool
 	decoderRaw := d.decoderPool.Get()
 	if err, ok := decoderRaw.(error); ok {
@@ -60,9 +74,7 @@ func (d *ZstdDecoder) Decode(data []byte, dtype arrow.DataType) (arrow.Array, er
 			Wrap(err).
 			Build()
 	}
-
-	// Reconstruct array based on type
-	return bytesToArray(decompressed, dtype)
+	return decompressed, nil
 }
 
 // bytesToArray converts bytes back to Arrow array
@@ -84,13 +96,25 @@ func bytesToArray(data []byte, dtype arrow.DataType) (arrow.Array, error) {
 		return bytesToInt32Array(data, numValues)
 	case arrow.INT64:
 		return bytesToInt64Array(data, numValues)
+	case arrow.FLOAT16:
+		return bytesToFloat16Array(data, numValues)
 	case arrow.FLOAT32:
+		if values := float32View(data, numValues); values != nil {
+			return values, nil
+		}
 		return bytesToFloat32Array(data, numValues)
 	case arrow.FLOAT64:
 		return bytesToFloat64Array(data, numValues)
 	case arrow.FIXED_SIZE_LIST:
 		listType := dtype.(*arrow.FixedSizeListType)
+		if listType.Elem().ID() == arrow.FLOAT32 {
+			if values := float32View(data, numValues*listType.Size()); values != nil {
+				return arrow.NewFixedSizeListArray(listType, values, nil), nil
+			}
+		}
 		return bytesToFixedSizeListArray(data, listType, numValues)
+	case arrow.STRING, arrow.BINARY:
+		return bytesToBinaryArray(data, dtype, numValues)
 	default:
 		return nil, lerrors.New(lerrors.ErrUnsupportedType).
 			Op("zstd_bytes_to_array").
@@ -98,6 +122,18 @@ func bytesToArray(data []byte, dtype arrow.DataType) (arrow.Array, error) {
 	}
 }
 
+// float32View wraps the numValues float32 values of decompressed data, laid
+// out as Arrow lays them, without copying them. It returns nil for data
+// with a null bitmap or too short, which the copying path handles. The
+// values start 4 bytes into data, which keeps them aligned.
+func float32View(data []byte, numValues int) *arrow.Float32Array {
+	end := 4 + 4*numValues
+	if numValues == 0 || len(data) < end+2 || binary.LittleEndian.Uint16(data[end:]) != 0 {
+		return nil
+	}
+	return arrow.NewFloat32ArrayFromBuffer(arrow.NewBufferBytes(data[4:end:end]), nil)
+}
+
 func bytesToInt32Array(data []byte, numValues int) (arrow.Array, error) {
 	valueSize := 4 * numValues
 	if len(data) < 4+valueSize+2 {
@@ -172,6 +208,42 @@ func bytesToInt64Array(data []byte, numValues int) (arrow.Array, error) {
 	return arrow.NewInt64Array(values, nullBitmap), nil
 }
 
+func bytesToFloat16Array(data []byte, numValues int) (arrow.Array, error) {
+	valueSize := 2 * numValues
+	if len(data) < 4+valueSize+2 {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("zstd_bytes_to_float16").
+			Context("reason", "insufficient data").
+			Context("expected", 4+valueSize+2).
+			Context("actual", len(data)).
+			Build()
+	}
+
+	valuesBuf := data[4 : 4+valueSize]
+	values := make([]uint16, numValues)
+	for i := 0; i < numValues; i++ {
+		values[i] = binary.LittleEndian.Uint16(valuesBuf[i*2:])
+	}
+
+	bitmapLen := int(binary.LittleEndian.Uint16(data[4+valueSize:]))
+	var nullBitmap *arrow.Bitmap
+	if bitmapLen > 0 {
+		bitmapStart := 4 + valueSize + 2
+		if len(data) < bitmapStart+bitmapLen {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("zstd_bytes_to_float16").
+				Context("reason", "insufficient data for bitmap").
+				Context("expected", bitmapStart+bitmapLen).
+				Context("actual", len(data)).
+				Build()
+		}
+		bitmapData := data[bitmapStart : bitmapStart+bitmapLen]
+		nullBitmap = arrow.NewBitmapFromBytes(bitmapData, numValues)
+	}
+
+	return arrow.NewFloat16Array(values, nullBitmap), nil
+}
+
 func bytesToFloat32Array(data []byte, numValues int) (arrow.Array, error) {
 	// Reuse int32 deserialization then convert bits
 	arr, err := bytesToInt32Array(data, numValues)
@@ -238,6 +310,8 @@ func bytesToFixedSizeListArray(data []byte, listType *arrow.FixedSizeListType, n
 	// 计算 child values 的大小
 	childValueSize := 0
 	switch elemType.ID() {
+	case arrow.FLOAT16:
+		childValueSize = 2 * totalChildValues
 	case arrow.FLOAT32:
 		childValueSize = 4 * totalChildValues
 	case arrow.INT32:
@@ -279,6 +353,8 @@ func bytesToFixedSizeListArray(data []byte, listType *arrow.FixedSizeListType, n
 	var err error
 
 	switch elemType.ID() {
+	case arrow.FLOAT16:
+		childArray, err = bytesToFloat16Array(childPacket, totalChildValues)
 	case arrow.FLOAT32:
 		childArray, err = bytesToFloat32Array(childPacket, totalChildValues)
 	case arrow.INT32:
@@ -319,6 +395,64 @@ func bytesToFixedSizeListArray(data []byte, listType *arrow.FixedSizeListType, n
 	return arrow.NewFixedSizeListArray(listType, childArray, listNullBitmap), nil
 }
 
+// bytesToBinaryArray decodes a string or binary array
+// Format: [numValues:4][offsets:(numValues+1)*4][values...][bitmapLen:2][bitmap...]
+func bytesToBinaryArray(data []byte, dtype arrow.DataType, numValues int) (arrow.Array, error) {
+	offsetsEnd := 4 + (numValues+1)*4
+	if len(data) < offsetsEnd+2 {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("zstd_bytes_to_binary").
+			Context("reason", "insufficient data for offsets").
+			Context("expected", offsetsEnd+2).
+			Context("actual", len(data)).
+			Build()
+	}
+
+	offsets := make([]int32, numValues+1)
+	for i := range offsets {
+		offsets[i] = int32(binary.LittleEndian.Uint32(data[4+i*4:]))
+		if offsets[i] < 0 || (i > 0 && offsets[i] < offsets[i-1]) {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("zstd_bytes_to_binary").
+				Context("reason", "invalid offset").
+				Context("index", i).
+				Context("offset", offsets[i]).
+				Build()
+		}
+	}
+
+	valuesEnd := offsetsEnd + int(offsets[numValues])
+	if len(data) < valuesEnd+2 {
+		return nil, lerrors.New(lerrors.ErrCorruptedFile).
+			Op("zstd_bytes_to_binary").
+			Context("reason", "insufficient data for values").
+			Context("expected", valuesEnd+2).
+			Context("actual", len(data)).
+			Build()
+	}
+	values := data[offsetsEnd:valuesEnd]
+
+	bitmapLen := int(binary.LittleEndian.Uint16(data[valuesEnd:]))
+	var nullBitmap *arrow.Bitmap
+	if bitmapLen > 0 {
+		bitmapStart := valuesEnd + 2
+		if len(data) < bitmapStart+bitmapLen {
+			return nil, lerrors.New(lerrors.ErrCorruptedFile).
+				Op("zstd_bytes_to_binary").
+				Context("reason", "insufficient data for bitmap").
+				Context("expected", bitmapStart+bitmapLen).
+				Context("actual", len(data)).
+				Build()
+		}
+		nullBitmap = arrow.NewBitmapFromBytes(data[bitmapStart:bitmapStart+bitmapLen], numValues)
+	}
+
+	if dtype.ID() == arrow.STRING {
+		return arrow.NewStringArray(offsets, values, nullBitmap), nil
+	}
+	return arrow.NewBinaryArray(offsets, values, nullBitmap), nil
+}
+
 func float32FromBits(bits uint32) float32 {
 	return *(*float32)(unsafe.Pointer(&bits))
 }
@@ -1,6 +1,7 @@
 package encoding
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/wzqhbustb/vego/storage/arrow"
@@ -139,6 +140,48 @@ func TestZstdEncoder_WithNulls(t *testing.T) {
 	}
 }
 
+func TestZstdEncoder_String(t *testing.T) {
+	encoder := NewZstdEncoder(3)
+	decoder, err := NewZstdDecoder()
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+
+	builder := arrow.NewStringBuilder()
+	for i := 0; i < 200; i++ {
+		switch {
+		case i%7 == 0:
+			builder.AppendNull()
+		case i%5 == 0:
+			builder.Append("")
+		default:
+			builder.Append(fmt.Sprintf("value-%d-é", i))
+		}
+	}
+	// A slice keeps its nulls and offsets relative to the window
+	array := builder.NewArray().(*arrow.StringArray).Slice(10, 150)
+
+	encoded, err := encoder.Encode(array)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := decoder.Decode(encoded.Data, arrow.PrimString())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	result := decoded.(*arrow.StringArray)
+	if result.Len() != array.Len() || result.NullN() != array.NullN() {
+		t.Fatalf("Got len %d nulls %d, want len %d nulls %d", result.Len(), result.NullN(), array.Len(), array.NullN())
+	}
+	for i := 0; i < array.Len(); i++ {
+		if result.IsNull(i) != array.IsNull(i) || result.Value(i) != array.Value(i) {
+			t.Fatalf("Row %d: got %q (null %v), want %q (null %v)",
+				i, result.Value(i), result.IsNull(i), array.Value(i), array.IsNull(i))
+		}
+	}
+}
+
 func TestZstdEncoder_WithAllNulls(t *testing.T) {
 	encoder := NewZstdEncoder(3)
 	decoder, err := NewZstdDecoder()
@@ -282,6 +325,62 @@ func TestZstdEncoder_DifferentLevels(t *testing.T) {
 	}
 }
 
+// TestZstdDecoder_Float32View checks that float32 and float32 list pages
+// decode the same without nulls, where they wrap the decompressed data, and
+// with them, where they are copied
+func TestZstdDecoder_Float32View(t *testing.T) {
+	const dim = 8
+	values := make([]float32, 50*dim)
+	for i := range values {
+		values[i] = float32(i) * 0.5
+	}
+	listType := arrow.FixedSizeListOf(arrow.PrimFloat32(), dim).(*arrow.FixedSizeListType)
+	nulls := arrow.NewBitmap(50)
+	nulls.SetAll()
+	nulls.Clear(7)
+
+	decoder, err := NewZstdDecoder()
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %v", err)
+	}
+	for _, array := range []arrow.Array{
+		arrow.NewFloat32Array(values, nil),
+		arrow.NewFixedSizeListArray(listType, arrow.NewFloat32Array(values, nil), nil),
+		arrow.NewFixedSizeListArray(listType, arrow.NewFloat32Array(values, nil), nulls),
+	} {
+		encoded, err := NewZstdEncoder(3).Encode(array)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		decoded, err := decoder.Decode(encoded.Data, array.DataType())
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if decoded.Len() != array.Len() || decoded.NullN() != array.NullN() {
+			t.Fatalf("Decoded %d values with %d nulls, want %d with %d",
+				decoded.Len(), decoded.NullN(), array.Len(), array.NullN())
+		}
+
+		got := decoded
+		if list, ok := decoded.(*arrow.FixedSizeListArray); ok {
+			got = list.Values()
+		}
+		for i, v := range got.(*arrow.Float32Array).Values() {
+			if v != values[i] {
+				t.Fatalf("Value %d = %v, want %v", i, v, values[i])
+			}
+		}
+
+		decompressed, err := decoder.decompress(encoded.Data)
+		if err != nil {
+			t.Fatalf("decompress failed: %v", err)
+		}
+		if _, err := bytesToArray(decompressed[:len(decompressed)-3], array.DataType()); err == nil {
+			t.Error("Expected error for truncated data")
+		}
+	}
+}
+
 func BenchmarkZstdEncoder_Encode(b *testing.B) {
 	encoder := NewZstdEncoder(3)
 	values := make([]int32, 10000)
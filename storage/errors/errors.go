@@ -47,6 +47,9 @@ const (
 	ErrPageNotFound
 	ErrTypeMismatch
 	ErrBufferTooSmall
+
+	// 校验错误 (500-599)
+	ErrChecksumMismatch
 )
 
 func (c ErrorCode) String() string {
@@ -73,6 +76,8 @@ func (c ErrorCode) String() string {
 		return "FileNotFound"
 	case ErrColumnNotFound:
 		return "ColumnNotFound"
+	case ErrChecksumMismatch:
+		return "ChecksumMismatch"
 	// ... 其他映射
 	default:
 		return fmt.Sprintf("ErrorCode(%d)", c)
@@ -35,6 +35,19 @@ func FormatCorrupted(path string, offset int64, reason string) error {
 		Build()
 }
 
+// ChecksumMismatch 校验和不匹配：what 是被校验的数据（如 "page"、"footer"）
+func ChecksumMismatch(path string, offset int64, what string, computed, stored uint32) error {
+	return New(ErrChecksumMismatch).
+		Op("verify_checksum").
+		Path(path).
+		Offset(offset).
+		Context("data", what).
+		Context("computed", fmt.Sprintf("0x%08X", computed)).
+		Context("stored", fmt.Sprintf("0x%08X", stored)).
+		Severity(SeverityFatal).
+		Build()
+}
+
 // SchemaMismatch Schema不匹配
 func SchemaMismatch(path string, field string, expected, actual string) error {
 	return New(ErrSchemaMismatch).
@@ -0,0 +1,69 @@
+package format
+
+import "encoding/json"
+
+// ColumnSummary totals the pages of one column: how many there are, the
+// bytes they take on disk and before encoding, and the encodings chosen
+// for them. Writers keep one per column in the footer metadata, so tools
+// can tell how a file compresses without reading its pages.
+type ColumnSummary struct {
+	Pages  int   `json:"pages"`
+	Values int64 `json:"values"`
+	// CompressedBytes is the size of the pages on disk, headers included
+	CompressedBytes int64 `json:"compressed_bytes"`
+	// UncompressedBytes is the raw data size recorded in the page headers
+	UncompressedBytes int64 `json:"uncompressed_bytes"`
+	// Encodings counts the pages of each encoding, by EncodingType name
+	Encodings map[string]int `json:"encodings"`
+}
+
+// AddPage adds a page of size bytes on disk, header included
+func (s *ColumnSummary) AddPage(size, uncompressed, numValues int32, encoding EncodingType) {
+	s.Pages++
+	s.Values += int64(numValues)
+	s.CompressedBytes += int64(size)
+	s.UncompressedBytes += int64(uncompressed)
+	if s.Encodings == nil {
+		s.Encodings = make(map[string]int)
+	}
+	s.Encodings[encoding.String()]++
+}
+
+// Add adds the totals of other to s
+func (s *ColumnSummary) Add(other ColumnSummary) {
+	s.Pages += other.Pages
+	s.Values += other.Values
+	s.CompressedBytes += other.CompressedBytes
+	s.UncompressedBytes += other.UncompressedBytes
+	for name, n := range other.Encodings {
+		if s.Encodings == nil {
+			s.Encodings = make(map[string]int)
+		}
+		s.Encodings[name] += n
+	}
+}
+
+// SetColumnSummaries stores the summaries of the columns in footer
+// metadata, indexed by column
+func (f *Footer) SetColumnSummaries(summaries []ColumnSummary) {
+	if f.Metadata == nil {
+		f.Metadata = make(map[string]string)
+	}
+	data, _ := json.Marshal(summaries) // Plain fields, cannot fail
+	f.Metadata[MetadataColumnSummaries] = string(data)
+}
+
+// GetColumnSummaries extracts the column summaries from footer metadata.
+// Returns ok=false if the file was written without them or they do not
+// parse; files written before summaries existed have none.
+func (f *Footer) GetColumnSummaries() ([]ColumnSummary, bool) {
+	data, ok := f.Metadata[MetadataColumnSummaries]
+	if !ok {
+		return nil, false
+	}
+	var summaries []ColumnSummary
+	if err := json.Unmarshal([]byte(data), &summaries); err != nil {
+		return nil, false
+	}
+	return summaries, true
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"io"
+	"sort"
 	"time"
 
 	lerrors "github.com/wzqhbustb/vego/storage/errors"
@@ -73,6 +74,9 @@ func (f *Footer) EncodedSize() int {
 
 	// Add page index list size
 	baseSize += f.PageIndexList.EncodedSize()
+	if hasPageStats(f.Version) {
+		baseSize += f.PageIndexList.pageStatsSize()
+	}
 
 	// Add metadata size: count(4) + entries
 	baseSize += 4
@@ -101,10 +105,16 @@ func (f *Footer) WriteTo(w io.Writer) (int64, error) {
 	// Write page index list
 	f.PageIndexList.WriteTo(buf)
 
-	// Write metadata
+	// Write metadata, sorted by key so the same footer writes the same bytes
 	metaCount := int32(len(f.Metadata))
 	binary.Write(buf, ByteOrder, metaCount)
-	for k, v := range f.Metadata {
+	keys := make([]string, 0, len(f.Metadata))
+	for k := range f.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := f.Metadata[k]
 		// Write key
 		keyLen := int32(len(k))
 		binary.Write(buf, ByteOrder, keyLen)
@@ -116,6 +126,16 @@ func (f *Footer) WriteTo(w io.Writer) (int64, error) {
 		buf.WriteString(v)
 	}
 
+	// Page stats (V1.3+) are optional: a footer they would overflow is
+	// written without them
+	if hasPageStats(f.Version) {
+		if buf.Len()+f.PageIndexList.pageStatsSize()+4 <= FooterSize {
+			f.PageIndexList.writePageStats(buf)
+		} else {
+			binary.Write(buf, ByteOrder, int32(0))
+		}
+	}
+
 	// Calculate checksum (excluding the checksum field itself)
 	data := buf.Bytes()
 	f.Checksum = crc32.ChecksumIEEE(data)
@@ -191,6 +211,12 @@ func (f *Footer) ReadFrom(r io.Reader) (int64, error) {
 		f.Metadata[key] = value
 	}
 
+	if hasPageStats(f.Version) {
+		if err := f.PageIndexList.readPageStats(reader); err != nil {
+			return int64(n), err
+		}
+	}
+
 	// Read checksum
 	var storedChecksum uint32
 	binary.Read(reader, ByteOrder, &storedChecksum)
@@ -203,8 +229,7 @@ func (f *Footer) ReadFrom(r io.Reader) (int64, error) {
 
 	computed := crc32.ChecksumIEEE(dataForChecksum)
 	if computed != storedChecksum {
-		return int64(n), lerrors.FormatCorrupted("", 0,
-			fmt.Sprintf("footer checksum mismatch: computed 0x%08X vs stored 0x%08X", computed, storedChecksum))
+		return int64(n), lerrors.ChecksumMismatch("", 0, "footer", computed, storedChecksum)
 	}
 
 	f.Checksum = storedChecksum
@@ -12,8 +12,8 @@ const (
 	// MagicNumber identifies a Lance file (ASCII "LANC")
 	MagicNumber uint32 = 0x4C414E43
 
-	// CurrentVersion is the current file format version (V1.2)
-	CurrentVersion uint16 = 0x0102
+	// CurrentVersion is the current file format version (V1.5)
+	CurrentVersion uint16 = 0x0105
 
 	// MinSupportedVersion is the minimum version this implementation can read (V1.0)
 	MinSupportedVersion uint16 = 0x0100
@@ -48,6 +48,11 @@ const (
 	EncodingBSSEncoding                     // Byte Stream Split Encoding
 )
 
+// EncodingUserMin is the first encoding ID of the range reserved for
+// encodings registered at run time with encoding.RegisterEncoder. Built-in
+// encodings stay below it.
+const EncodingUserMin EncodingType = 128
+
 func (e EncodingType) String() string {
 	switch e {
 	case EncodingPlain:
@@ -67,6 +72,9 @@ func (e EncodingType) String() string {
 	case EncodingBSSEncoding:
 		return "BSSEncoding"
 	default:
+		if e >= EncodingUserMin {
+			return fmt.Sprintf("User(%d)", e)
+		}
 		return fmt.Sprintf("Unknown(%d)", e)
 	}
 }
@@ -201,6 +201,7 @@ func serializeSchemaToJSON(schema *arrow.Schema) []byte {
 		Name     string `json:"name"`
 		Type     string `json:"type"`
 		Nullable bool   `json:"nullable"`
+		ID       int32  `json:"id"`
 	}
 
 	type schemaJSON struct {
@@ -215,6 +216,7 @@ func serializeSchemaToJSON(schema *arrow.Schema) []byte {
 			Name:     field.Name,
 			Type:     serializeTypeName(field.Type),
 			Nullable: field.Nullable,
+			ID:       field.ID,
 		}
 	}
 
@@ -234,6 +236,8 @@ func serializeTypeName(dt arrow.DataType) string {
 		return "int32"
 	case *arrow.Int64Type:
 		return "int64"
+	case *arrow.Float16Type:
+		return "float16"
 	case *arrow.Float32Type:
 		return "float32"
 	case *arrow.Float64Type:
@@ -257,6 +261,7 @@ func deserializeSchemaFromJSON(data []byte) (*arrow.Schema, error) {
 			Name     string `json:"name"`
 			Type     string `json:"type"`
 			Nullable bool   `json:"nullable"`
+			ID       int32  `json:"id"`
 		} `json:"fields"`
 		Metadata map[string]string `json:"metadata"`
 	}
@@ -291,10 +296,11 @@ func deserializeSchemaFromJSON(data []byte) (*arrow.Schema, error) {
 			Type:     dataType,
 			Nullable: f.Nullable,
 			Metadata: make(map[string]string),
+			ID:       f.ID,
 		}
 	}
 
-	// Create schema with metadata using constructor
+	// Files written before field IDs have none and get positional ones
 	schema := arrow.NewSchema(fields, schemaJSON.Metadata)
 
 	return schema, nil
@@ -308,6 +314,8 @@ func parseDataType(typeStr string) (arrow.DataType, error) {
 		return arrow.PrimInt32(), nil
 	case "int64":
 		return arrow.PrimInt64(), nil
+	case "float16":
+		return arrow.PrimFloat16(), nil
 	case "float32":
 		return arrow.PrimFloat32(), nil
 	case "float64":
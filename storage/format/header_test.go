@@ -222,6 +222,39 @@ func TestHeaderWithCustomSchema(t *testing.T) {
 	}
 }
 
+// TestHeaderFieldIDs tests that field IDs are persisted, and that headers
+// written without them number their fields by position
+func TestHeaderFieldIDs(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{ID: 5, Name: "id", Type: arrow.PrimInt32()},
+		{ID: 2, Name: "embedding", Type: arrow.VectorType(8)},
+	}, nil)
+
+	buf := new(bytes.Buffer)
+	if _, err := NewHeader(schema, 10).WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	deserialized := &Header{}
+	if _, err := deserialized.ReadFrom(buf); err != nil {
+		t.Fatalf("ReadFrom failed: %v", err)
+	}
+	if !deserialized.Schema.Equal(schema) {
+		t.Errorf("Schema mismatch: got %s, want %s", deserialized.Schema, schema)
+	}
+
+	legacy, err := deserializeSchemaFromJSON([]byte(`{"fields":[` +
+		`{"name":"id","type":"int32","nullable":false},` +
+		`{"name":"score","type":"float64","nullable":true}]}`))
+	if err != nil {
+		t.Fatalf("deserializeSchemaFromJSON failed: %v", err)
+	}
+	for i := 0; i < legacy.NumFields(); i++ {
+		if got := legacy.Field(i).ID; got != int32(i+1) {
+			t.Errorf("legacy field %d: expected ID %d, got %d", i, i+1, got)
+		}
+	}
+}
+
 // TestHeaderValidation tests header validation using error codes
 func TestHeaderValidation(t *testing.T) {
 	tests := []struct {
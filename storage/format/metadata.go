@@ -24,6 +24,9 @@ const (
 	// BlockCache info (V1.2+)
 	MetadataBlockCacheEnabled   = "vego.blockcache.enabled"    // "true" or "false"
 	MetadataBlockCacheBlockSize = "vego.blockcache.block_size" // int as string
+
+	// Per-column page and byte totals, see ColumnSummary
+	MetadataColumnSummaries = "vego.columns.summary" // JSON array, one entry per column
 )
 
 // FormatMetadata provides structured access to format-related metadata
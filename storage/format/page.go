@@ -20,6 +20,7 @@ type Page struct {
 	Checksum         uint32       // CRC32 checksum
 	Data             []byte       // Page data
 	Offset           int64        // Offset in file (for reading)
+	Stats            *PageStats   // Value range for the footer, not stored in the page
 }
 
 // PageHeader is the fixed-size header for each page
@@ -53,8 +54,16 @@ func (p *Page) SetData(data []byte, uncompressedSize int32) {
 	p.Checksum = crc32.ChecksumIEEE(data)
 }
 
-// Validate validates the page
+// Validate validates the page layout and checksum
 func (p *Page) Validate() error {
+	if err := p.validateLayout(); err != nil {
+		return err
+	}
+	return p.VerifyChecksum()
+}
+
+// validateLayout is Validate without the checksum
+func (p *Page) validateLayout() error {
 	if p.NumValues < 0 {
 		return lerrors.ValidationFailed("validate_page", "",
 			fmt.Sprintf("invalid num values: %d", p.NumValues))
@@ -76,14 +85,31 @@ func (p *Page) Validate() error {
 			Context("message", "data size mismatch").
 			Build()
 	}
+	return nil
+}
 
-	// Verify checksum
-	computed := crc32.ChecksumIEEE(p.Data)
-	if computed != p.Checksum {
-		return lerrors.FormatCorrupted("", p.Offset,
-			fmt.Sprintf("page checksum mismatch: computed 0x%08X vs stored 0x%08X", computed, p.Checksum))
+// VerifyChecksum checks the page data against its CRC32, failing with
+// ErrChecksumMismatch
+func (p *Page) VerifyChecksum() error {
+	if computed := crc32.ChecksumIEEE(p.Data); computed != p.Checksum {
+		return lerrors.ChecksumMismatch("", p.Offset,
+			fmt.Sprintf("page of column %d", p.ColumnIndex), computed, p.Checksum)
 	}
+	return nil
+}
 
+// VerifyEncodedPage checks the checksum of a page as written by WriteTo,
+// header and data, without parsing it, as VerifyChecksum does
+func VerifyEncodedPage(data []byte) error {
+	if len(data) < PageHeaderSize {
+		return lerrors.FormatCorrupted("", -1,
+			fmt.Sprintf("page of %d bytes is shorter than its header", len(data)))
+	}
+	column := int32(ByteOrder.Uint32(data[2:6]))
+	stored := ByteOrder.Uint32(data[18:22])
+	if computed := crc32.ChecksumIEEE(data[PageHeaderSize:]); computed != stored {
+		return lerrors.ChecksumMismatch("", -1, fmt.Sprintf("page of column %d", column), computed, stored)
+	}
 	return nil
 }
 
@@ -127,8 +153,20 @@ func (p *Page) WriteTo(w io.Writer) (int64, error) {
 	return int64(n), err
 }
 
-// ReadFrom reads the page from a reader
+// ReadFrom reads the page from a reader and validates it, checksum
+// included
 func (p *Page) ReadFrom(r io.Reader) (int64, error) {
+	n, err := p.ReadFromUnverified(r)
+	if err != nil {
+		return n, err
+	}
+	return n, p.VerifyChecksum()
+}
+
+// ReadFromUnverified reads the page from a reader like ReadFrom but leaves
+// the checksum to the caller, for readers that verify it later or not at
+// all
+func (p *Page) ReadFromUnverified(r io.Reader) (int64, error) {
 	// Read header
 	headerBuf := make([]byte, PageHeaderSize)
 	n, err := io.ReadFull(r, headerBuf)
@@ -158,7 +196,7 @@ func (p *Page) ReadFrom(r io.Reader) (int64, error) {
 	}
 
 	// Validate
-	if err := p.Validate(); err != nil {
+	if err := p.validateLayout(); err != nil {
 		return int64(n + dataRead), err
 	}
 
@@ -189,6 +227,7 @@ type PageIndex struct {
 	Size        int32        // Size in bytes
 	NumValues   int32        // Number of values
 	Encoding    EncodingType // Encoding type for this page
+	Stats       *PageStats   // Value range, nil if unknown (footer of V1.3+ only)
 }
 
 // EncodedSize returns the size of the encoded PageIndex
@@ -261,6 +300,11 @@ func NewPageIndexList() *PageIndexList {
 }
 
 func (l *PageIndexList) Add(columnIndex, pageNum int32, offset int64, size, numValues int32, encoding EncodingType) {
+	l.AddWithStats(columnIndex, pageNum, offset, size, numValues, encoding, nil)
+}
+
+// AddWithStats is Add of a page with value range stats, which may be nil
+func (l *PageIndexList) AddWithStats(columnIndex, pageNum int32, offset int64, size, numValues int32, encoding EncodingType, stats *PageStats) {
 	l.Indices = append(l.Indices, PageIndex{
 		ColumnIndex: columnIndex,
 		PageNum:     pageNum,
@@ -268,6 +312,7 @@ func (l *PageIndexList) Add(columnIndex, pageNum int32, offset int64, size, numV
 		Size:        size,
 		NumValues:   numValues,
 		Encoding:    encoding, // 添加 Encoding 字段
+		Stats:       stats,
 	})
 }
 
@@ -0,0 +1,124 @@
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	lerrors "github.com/wzqhbustb/vego/storage/errors"
+)
+
+// PageStats is the value range of a page of a numeric column. V1.3+ files
+// keep it in the footer so readers can skip pages a predicate rules out.
+type PageStats struct {
+	NullCount int32
+	// HasRange is false when the page has no non-null values
+	HasRange bool
+	// Float is set for float columns, whose range is MinFloat..MaxFloat;
+	// integer columns use MinInt..MaxInt
+	Float              bool
+	MinInt, MaxInt     int64
+	MinFloat, MaxFloat float64
+}
+
+const (
+	pageStatsHasRange = 1 << iota
+	pageStatsFloat
+)
+
+// pageStatsEntrySize is the encoded size of the stats of one page:
+// page(4) + flags(1) + nullCount(4) + min(8) + max(8)
+const pageStatsEntrySize = 4 + 1 + 4 + 8 + 8
+
+// MayContain reports whether the page may hold a non-null value in
+// [min, max]. Pages without a range hold only nulls.
+func (s *PageStats) MayContain(min, max float64) bool {
+	if !s.HasRange {
+		return false
+	}
+	if s.Float {
+		return s.MaxFloat >= min && s.MinFloat <= max
+	}
+	return float64(s.MaxInt) >= min && float64(s.MinInt) <= max
+}
+
+// hasPageStats reports whether the footer of version has a stats section
+func hasPageStats(version uint16) bool {
+	return version >= V1_3.Encoded()
+}
+
+// pageStatsSize returns the encoded size of the stats section of l
+func (l *PageIndexList) pageStatsSize() int {
+	size := 4
+	for _, idx := range l.Indices {
+		if idx.Stats != nil {
+			size += pageStatsEntrySize
+		}
+	}
+	return size
+}
+
+// writePageStats writes the stats of the pages that have them, by position
+// in l
+func (l *PageIndexList) writePageStats(buf *bytes.Buffer) {
+	var count int32
+	for _, idx := range l.Indices {
+		if idx.Stats != nil {
+			count++
+		}
+	}
+	binary.Write(buf, ByteOrder, count)
+	for i, idx := range l.Indices {
+		s := idx.Stats
+		if s == nil {
+			continue
+		}
+		var flags uint8
+		min, max := uint64(s.MinInt), uint64(s.MaxInt)
+		if s.HasRange {
+			flags |= pageStatsHasRange
+		}
+		if s.Float {
+			flags |= pageStatsFloat
+			min, max = math.Float64bits(s.MinFloat), math.Float64bits(s.MaxFloat)
+		}
+		binary.Write(buf, ByteOrder, int32(i))
+		binary.Write(buf, ByteOrder, flags)
+		binary.Write(buf, ByteOrder, s.NullCount)
+		binary.Write(buf, ByteOrder, min)
+		binary.Write(buf, ByteOrder, max)
+	}
+}
+
+// readPageStats reads a stats section into the pages of l
+func (l *PageIndexList) readPageStats(r io.Reader) error {
+	var count int32
+	if err := binary.Read(r, ByteOrder, &count); err != nil {
+		return NewFileError("read page stats count", err)
+	}
+	for i := int32(0); i < count; i++ {
+		var page int32
+		var flags uint8
+		var min, max uint64
+		s := &PageStats{}
+		for _, v := range []any{&page, &flags, &s.NullCount, &min, &max} {
+			if err := binary.Read(r, ByteOrder, v); err != nil {
+				return NewFileError("read page stats", err)
+			}
+		}
+		if page < 0 || int(page) >= len(l.Indices) {
+			return lerrors.FormatCorrupted("", 0, fmt.Sprintf("stats of unknown page %d", page))
+		}
+		s.HasRange = flags&pageStatsHasRange != 0
+		s.Float = flags&pageStatsFloat != 0
+		if s.Float {
+			s.MinFloat, s.MaxFloat = math.Float64frombits(min), math.Float64frombits(max)
+		} else {
+			s.MinInt, s.MaxInt = int64(min), int64(max)
+		}
+		l.Indices[page].Stats = s
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package format
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// roundTripFooter writes f and reads it back
+func roundTripFooter(t *testing.T, f *Footer) *Footer {
+	t.Helper()
+	f.NumPages = int32(len(f.PageIndexList.Indices))
+	buf := new(bytes.Buffer)
+	if _, err := f.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	read := &Footer{}
+	if _, err := read.ReadFrom(buf); err != nil {
+		t.Fatalf("ReadFrom failed: %v", err)
+	}
+	return read
+}
+
+func TestFooterPageStats(t *testing.T) {
+	stats := []*PageStats{
+		{NullCount: 3, HasRange: true, MinInt: -5, MaxInt: math.MaxInt64},
+		nil,
+		{HasRange: true, Float: true, MinFloat: -0.5, MaxFloat: math.Inf(1)},
+		{NullCount: 10, Float: true},
+	}
+	f := NewFooter()
+	for i, s := range stats {
+		f.PageIndexList.AddWithStats(0, int32(i), int64(100*i), 100, 10, EncodingPlain, s)
+	}
+	f.AddMetadata("user.key", "value")
+
+	read := roundTripFooter(t, f)
+	if read.Version != CurrentVersion || read.Metadata["user.key"] != "value" {
+		t.Fatalf("Read footer version 0x%04X, metadata %v", read.Version, read.Metadata)
+	}
+	for i, s := range stats {
+		if got := read.PageIndexList.Indices[i].Stats; !reflect.DeepEqual(got, s) {
+			t.Errorf("Page %d stats %+v, want %+v", i, got, s)
+		}
+	}
+
+	// V1.2 footers have no stats section
+	f.Version = V1_2.Encoded()
+	read = roundTripFooter(t, f)
+	for i, idx := range read.PageIndexList.Indices {
+		if idx.Stats != nil {
+			t.Errorf("Page %d of a V1.2 footer has stats %+v", i, idx.Stats)
+		}
+	}
+}
+
+func TestFooterPageStatsOverflow(t *testing.T) {
+	// Pages whose index fits the footer but not with stats
+	f := NewFooter()
+	n := (FooterSize - 1024) / 25
+	for i := 0; i < n; i++ {
+		f.PageIndexList.AddWithStats(0, int32(i), int64(i), 1, 1, EncodingPlain,
+			&PageStats{HasRange: true, MinInt: int64(i), MaxInt: int64(i)})
+	}
+	read := roundTripFooter(t, f)
+	if len(read.PageIndexList.Indices) != n {
+		t.Fatalf("Read %d pages, want %d", len(read.PageIndexList.Indices), n)
+	}
+	for _, idx := range read.PageIndexList.Indices {
+		if idx.Stats != nil {
+			t.Fatalf("Expected stats to be dropped, page %d has %+v", idx.PageNum, idx.Stats)
+		}
+	}
+}
+
+func TestPageStatsMayContain(t *testing.T) {
+	ints := &PageStats{HasRange: true, MinInt: 100, MaxInt: 199}
+	floats := &PageStats{HasRange: true, Float: true, MinFloat: -1, MaxFloat: 1}
+	tests := []struct {
+		stats    *PageStats
+		min, max float64
+		want     bool
+	}{
+		{ints, 150, 160, true},
+		{ints, 199, math.Inf(1), true},
+		{ints, 200, math.Inf(1), false},
+		{ints, math.Inf(-1), 99.5, false},
+		{floats, 0.5, 2, true},
+		{floats, 1.5, 2, false},
+		{&PageStats{NullCount: 5}, math.Inf(-1), math.Inf(1), false},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.MayContain(tt.min, tt.max); got != tt.want {
+			t.Errorf("%+v.MayContain(%v, %v) = %v, want %v", tt.stats, tt.min, tt.max, got, tt.want)
+		}
+	}
+}
@@ -23,6 +23,9 @@ const (
 	FeatureFullZip         // Phase 3: Full zip compression
 	FeatureChecksum        // Per-page CRC32 checksum
 	FeatureEncryption      // AES encryption
+	FeaturePageStats       // V1.3: per-page min/max in the footer
+	FeatureFloat16       // V1.4: float16 columns
+	FeatureDeltaEncoding // V1.5: Delta encoded integer pages
 )
 
 // FeatureFlagName returns the string representation of a feature flag
@@ -50,6 +53,12 @@ func FeatureFlagName(f uint32) string {
 		return "Checksum"
 	case FeatureEncryption:
 		return "Encryption"
+	case FeaturePageStats:
+		return "PageStats"
+	case FeatureFloat16:
+		return "Float16"
+	case FeatureDeltaEncoding:
+		return "DeltaEncoding"
 	default:
 		return fmt.Sprintf("Unknown(%d)", f)
 	}
@@ -94,8 +103,26 @@ var (
 		FeatureFlags: V1_1.FeatureFlags | FeatureBlockCache,
 	}
 
+	V1_3 = VersionPolicy{
+		MajorVersion: 1,
+		MinorVersion: 3,
+		FeatureFlags: V1_2.FeatureFlags | FeaturePageStats,
+	}
+
+	V1_4 = VersionPolicy{
+		MajorVersion: 1,
+		MinorVersion: 4,
+		FeatureFlags: V1_3.FeatureFlags | FeatureFloat16,
+	}
+
+	V1_5 = VersionPolicy{
+		MajorVersion: 1,
+		MinorVersion: 5,
+		FeatureFlags: V1_4.FeatureFlags | FeatureDeltaEncoding,
+	}
+
 	// CurrentFormatVersion is the latest version supported by this implementation
-	CurrentFormatVersion = V1_2
+	CurrentFormatVersion = V1_5
 
 	// MinReadableVersion is the oldest version that can be read
 	MinReadableVersion = V1_0
@@ -161,6 +188,12 @@ func ParseVersion(s string) (VersionPolicy, error) {
 		vp.FeatureFlags = V1_1.FeatureFlags
 	case V1_2.Encoded():
 		vp.FeatureFlags = V1_2.FeatureFlags
+	case V1_3.Encoded():
+		vp.FeatureFlags = V1_3.FeatureFlags
+	case V1_4.Encoded():
+		vp.FeatureFlags = V1_4.FeatureFlags
+	case V1_5.Encoded():
+		vp.FeatureFlags = V1_5.FeatureFlags
 	default:
 		// Unknown version, features will be empty
 		vp.FeatureFlags = 0
@@ -184,6 +217,12 @@ func VersionFromEncoded(encoded uint16) VersionPolicy {
 		vp.FeatureFlags = V1_1.FeatureFlags
 	case V1_2.Encoded():
 		vp.FeatureFlags = V1_2.FeatureFlags
+	case V1_3.Encoded():
+		vp.FeatureFlags = V1_3.FeatureFlags
+	case V1_4.Encoded():
+		vp.FeatureFlags = V1_4.FeatureFlags
+	case V1_5.Encoded():
+		vp.FeatureFlags = V1_5.FeatureFlags
 	}
 
 	return vp
@@ -196,7 +235,7 @@ func NormalizeVersion(v uint16) uint16 {
 	case 1:
 		// Legacy format V1 (before structured versioning)
 		return V1_0.Encoded() // 0x0100
-	case V1_0.Encoded(), V1_1.Encoded(), V1_2.Encoded():
+	case V1_0.Encoded(), V1_1.Encoded(), V1_2.Encoded(), V1_3.Encoded(), V1_4.Encoded(), V1_5.Encoded():
 		// Already new format
 		return v
 	default:
@@ -32,6 +32,8 @@ func TestLegacyVersionMapping_NormalizeVersion(t *testing.T) {
 			{0x0102, 0x0102, "V1.2 unchanged"},
 			{0x0200, 0x0200, "V2.0 unchanged"},
 			{0x0103, 0x0103, "V1.3 unchanged"},
+			{0x0104, 0x0104, "V1.4 unchanged"},
+			{0x0105, 0x0105, "V1.5 unchanged"},
 		}
 		
 		for _, tc := range testCases {
@@ -23,6 +23,9 @@ func TestFeatureConstants(t *testing.T) {
 		FeatureFullZip,
 		FeatureChecksum,
 		FeatureEncryption,
+		FeaturePageStats,
+		FeatureFloat16,
+		FeatureDeltaEncoding,
 	}
 
 	for i, f := range features {
@@ -107,6 +110,8 @@ func TestParseVersion(t *testing.T) {
 		{"1.0", 1, 0, V1_0.FeatureFlags, false},
 		{"1.1", 1, 1, V1_1.FeatureFlags, false},
 		{"1.2", 1, 2, V1_2.FeatureFlags, false},
+		{"1.4", 1, 4, V1_4.FeatureFlags, false},
+		{"1.5", 1, 5, V1_5.FeatureFlags, false},
 		{"2.0", 2, 0, 0, false},           // Unknown version, no flags
 		{"0.1", 0, 1, 0, false},           // Edge case
 		{"invalid", 0, 0, 0, true},        // Invalid format
@@ -457,3 +462,32 @@ func BenchmarkVersionChecker(b *testing.B) {
 		_ = checker.CheckReadCompatibility(V1_0.Encoded())
 	}
 }
+
+func TestFloat16NeedsV14(t *testing.T) {
+	if !V1_4.HasFeature(FeatureFloat16) || V1_3.HasFeature(FeatureFloat16) {
+		t.Fatal("float16 columns should need V1.4")
+	}
+	if VersionFromEncoded(V1_4.Encoded()).FeatureFlags != V1_4.FeatureFlags {
+		t.Error("V1.4 features not looked up from its encoding")
+	}
+
+	// A release that only knows V1.3 refuses the file instead of misreading it
+	err := NewVersionChecker(V1_3).CheckReadCompatibility(V1_4.Encoded())
+	if !errors.Is(err, ErrVersionTooNew) {
+		t.Errorf("expected ErrVersionTooNew reading V1.4 with a V1.3 reader, got %v", err)
+	}
+}
+
+func TestDeltaEncodingNeedsV15(t *testing.T) {
+	if !V1_5.HasFeature(FeatureDeltaEncoding) || V1_4.HasFeature(FeatureDeltaEncoding) {
+		t.Fatal("Delta encoded pages should need V1.5")
+	}
+	if CurrentFormatVersion.Encoded() != CurrentVersion {
+		t.Errorf("CurrentFormatVersion %s does not match CurrentVersion 0x%04X", CurrentFormatVersion, CurrentVersion)
+	}
+
+	err := NewVersionChecker(V1_4).CheckReadCompatibility(V1_5.Encoded())
+	if !errors.Is(err, ErrVersionTooNew) {
+		t.Errorf("expected ErrVersionTooNew reading V1.5 with a V1.4 reader, got %v", err)
+	}
+}
@@ -14,6 +14,7 @@ type AsyncIO struct {
 	scheduler *Scheduler
 	executor  *Executor
 	filePool  *FilePool
+	pageCache *PageCache // nil unless Config.PageCacheBytes > 0
 
 	mu     sync.RWMutex
 	closed bool
@@ -24,6 +25,13 @@ type Config struct {
 	Workers      int // Worker goroutine 数量
 	QueueSize    int // Executor 队列大小
 	SchedulerCap int // Scheduler 队列容量
+
+	Scheduling SchedulingConfig // 交互请求与后台请求之间的调度
+
+	// PageCacheBytes is the budget of a PageCache of read results shared by
+	// every reader of the AsyncIO, so that reading the same bytes of a file
+	// again does not touch the disk (0 = no cache)
+	PageCacheBytes int64
 }
 
 // DefaultConfig 返回默认配置
@@ -32,6 +40,7 @@ func DefaultConfig() *Config {
 		Workers:      4,
 		QueueSize:    1000,
 		SchedulerCap: 10000,
+		Scheduling:   DefaultSchedulingConfig(),
 	}
 }
 
@@ -40,6 +49,9 @@ func New(cfg *Config) (*AsyncIO, error) {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
+	if err := cfg.Scheduling.Validate(); err != nil {
+		return nil, err
+	}
 
 	// 1. 创建文件池
 	filePool := NewFilePool()
@@ -48,13 +60,17 @@ func New(cfg *Config) (*AsyncIO, error) {
 	executor := NewExecutor(cfg.Workers, cfg.QueueSize, filePool)
 
 	// 3. 创建 Scheduler
-	scheduler := NewScheduler(executor, cfg.SchedulerCap)
+	scheduler := NewSchedulerWithConfig(executor, cfg.SchedulerCap, cfg.Scheduling)
 
-	return &AsyncIO{
+	a := &AsyncIO{
 		scheduler: scheduler,
 		executor:  executor,
 		filePool:  filePool,
-	}, nil
+	}
+	if cfg.PageCacheBytes > 0 {
+		a.pageCache = NewPageCache(cfg.PageCacheBytes)
+	}
+	return a, nil
 }
 
 // RegisterFile 注册文件到 AsyncIO
@@ -66,12 +82,24 @@ func (a *AsyncIO) RegisterFile(fileID string, path string) error {
 	}
 	a.mu.RUnlock()
 
-	return a.filePool.Register(fileID, path)
+	if err := a.filePool.Register(fileID, path); err != nil {
+		return err
+	}
+	if a.pageCache != nil {
+		return a.pageCache.register(fileID, path)
+	}
+	return nil
 }
 
 // Read 异步读取
 // 返回的 channel 会收到读取结果
 func (a *AsyncIO) Read(ctx context.Context, fileID string, offset int64, size int32) <-chan IOResult {
+	return a.ReadWithPriority(ctx, fileID, offset, size, PriorityNormal)
+}
+
+// ReadWithPriority 以指定优先级异步读取
+// PriorityBackground 的请求在交互请求排队时只分到配置的份额，见 SchedulingConfig
+func (a *AsyncIO) ReadWithPriority(ctx context.Context, fileID string, offset int64, size int32, priority Priority) <-chan IOResult {
 	a.mu.RLock()
 	if a.closed {
 		a.mu.RUnlock()
@@ -82,8 +110,11 @@ func (a *AsyncIO) Read(ctx context.Context, fileID string, offset int64, size in
 	}
 	a.mu.RUnlock()
 
-	req := NewIORequest(fileID, offset, size, PriorityNormal)
+	req := NewIORequest(fileID, offset, size, priority)
 	req.WithContext(ctx)
+	if ch, ok := a.cacheRead(req); ok {
+		return ch
+	}
 
 	// 提交请求
 	if err := a.scheduler.Submit(req); err != nil {
@@ -100,6 +131,11 @@ func (a *AsyncIO) Read(ctx context.Context, fileID string, offset int64, size in
 // 适用于列式扫描场景
 // 修复：使用 SubmitBatch 批量提交
 func (a *AsyncIO) ReadPages(ctx context.Context, fileID string, offsets []int64, size int32) []<-chan IOResult {
+	return a.ReadPagesWithPriority(ctx, fileID, offsets, size, PriorityNormal)
+}
+
+// ReadPagesWithPriority 以指定优先级批量读取多个 Page
+func (a *AsyncIO) ReadPagesWithPriority(ctx context.Context, fileID string, offsets []int64, size int32, priority Priority) []<-chan IOResult {
 	results := make([]<-chan IOResult, len(offsets))
 
 	a.mu.RLock()
@@ -116,12 +152,16 @@ func (a *AsyncIO) ReadPages(ctx context.Context, fileID string, offsets []int64,
 	}
 	a.mu.RUnlock()
 
-	// 创建批量请求
-	reqs := make([]*IORequest, len(offsets))
+	// 创建批量请求，缓存命中的不再提交
+	reqs := make([]*IORequest, 0, len(offsets))
 	for i, offset := range offsets {
-		req := NewIORequest(fileID, offset, size, PriorityNormal)
+		req := NewIORequest(fileID, offset, size, priority)
 		req.WithContext(ctx)
-		reqs[i] = req
+		if ch, ok := a.cacheRead(req); ok {
+			results[i] = ch
+			continue
+		}
+		reqs = append(reqs, req)
 		results[i] = req.Callback
 	}
 
@@ -153,6 +193,12 @@ func (a *AsyncIO) Write(ctx context.Context, fileID string, offset int64, data [
 
 	req := NewIOWriteRequest(fileID, offset, data, PriorityNormal)
 	req.WithContext(ctx)
+	if a.pageCache != nil {
+		// Drop the file's cached data before and after the write, so that
+		// no read racing with it leaves the old bytes cached
+		a.pageCache.invalidate(fileID)
+		req.onResult = func(IOResult) { a.pageCache.invalidate(fileID) }
+	}
 
 	if err := a.scheduler.Submit(req); err != nil {
 		ch := make(chan IOResult, 1)
@@ -170,7 +216,33 @@ func (a *AsyncIO) Stats() AsyncIOStats {
 		Scheduler: a.scheduler.Stats(),
 		Executor:  a.executor.Stats(),
 		FilePool:  a.filePool.Stats(),
+		PageCache: a.pageCache.Stats(),
+	}
+}
+
+// cacheRead serves req from the PageCache if it holds its bytes, or
+// arranges for the result to be cached once read
+func (a *AsyncIO) cacheRead(req *IORequest) (<-chan IOResult, bool) {
+	if a.pageCache == nil {
+		return nil, false
+	}
+	key, ok := a.pageCache.key(req.FileID, req.Offset, req.Size)
+	if !ok {
+		return nil, false
+	}
+	if data, hit := a.pageCache.get(key); hit {
+		ch := make(chan IOResult, 1)
+		ch <- IOResult{RequestID: req.ID, Data: data}
+		close(ch)
+		return ch, true
+	}
+	req.onResult = func(result IOResult) {
+		// Reads cut short by the end of the file are not cached
+		if result.Error == nil && len(result.Data) == int(req.Size) {
+			a.pageCache.put(key, result.Data)
+		}
 	}
+	return nil, false
 }
 
 // Close 关闭 AsyncIO
@@ -222,6 +294,7 @@ type AsyncIOStats struct {
 	Scheduler SchedulerStats
 	Executor  ExecutorStats
 	FilePool  FilePoolStats
+	PageCache PageCacheStats // Zero without Config.PageCacheBytes
 }
 
 // async.go 中添加
@@ -141,6 +141,9 @@ func (e *Executor) execute(req *IORequest) {
 		}
 	}
 
+	if req.onResult != nil {
+		req.onResult(result)
+	}
 	e.sendResult(req, result)
 }
 
@@ -222,6 +225,11 @@ func (e *Executor) sendResult(req *IORequest, result IOResult) {
 		// 不应该发生：channel 已满或关闭
 		// 这说明用户代码有问题
 	}
+
+	// Frees the request's slot in the Scheduler
+	if req.onDone != nil {
+		req.onDone()
+	}
 }
 
 // Submit 提交一个 I/O 请求到执行队列（阻塞直到有空间）
@@ -0,0 +1,185 @@
+package io
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileVersion 标识文件的一个版本：同一路径被重写后 size 或 mtime 会变化
+type fileVersion struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+// pageCacheKey 标识一次读取的数据
+type pageCacheKey struct {
+	file   fileVersion
+	offset int64
+	size   int32
+}
+
+type pageCacheEntry struct {
+	key  pageCacheKey
+	data []byte
+}
+
+// PageCache 是 AsyncIO 读取结果的 LRU 缓存，按字节预算淘汰
+//
+// Entries are keyed by file path, offset and length, so readers of the same
+// file share them whatever their file IDs. Registering a file whose size or
+// mtime changed drops the entries of its previous version.
+type PageCache struct {
+	mu       sync.Mutex
+	capacity int64
+	size     int64
+	items    map[pageCacheKey]*list.Element
+	lru      *list.List
+
+	files    map[string]fileVersion // file ID -> version at registration
+	versions map[string]fileVersion // path -> latest version
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// NewPageCache 创建容量为 capacityBytes 的缓存
+func NewPageCache(capacityBytes int64) *PageCache {
+	return &PageCache{
+		capacity: capacityBytes,
+		items:    make(map[pageCacheKey]*list.Element),
+		lru:      list.New(),
+		files:    make(map[string]fileVersion),
+		versions: make(map[string]fileVersion),
+	}
+}
+
+// register records the version of the file behind fileID, and drops the
+// cached data of its path if the file changed since it was last registered
+func (c *PageCache) register(fileID, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	version := fileVersion{path: path, size: info.Size(), modTime: info.ModTime().UnixNano()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.versions[path]; ok && old != version {
+		c.dropLocked(old)
+	}
+	c.versions[path] = version
+	c.files[fileID] = version
+	return nil
+}
+
+// invalidate drops the cached data of the file behind fileID, after a write
+func (c *PageCache) invalidate(fileID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version, ok := c.files[fileID]; ok {
+		c.dropLocked(version)
+	}
+}
+
+// dropLocked removes every entry of version. Must be called with mu held.
+func (c *PageCache) dropLocked(version fileVersion) {
+	for key, elem := range c.items {
+		if key.file == version {
+			c.removeLocked(elem)
+		}
+	}
+}
+
+// key returns the cache key of a read, or false if fileID is unknown
+func (c *PageCache) key(fileID string, offset int64, size int32) (pageCacheKey, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	version, ok := c.files[fileID]
+	return pageCacheKey{file: version, offset: offset, size: size}, ok
+}
+
+// get returns the cached data of key, counting a hit or a miss. The data is
+// shared and must not be modified.
+func (c *PageCache) get(key pageCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.hits++
+		return elem.Value.(*pageCacheEntry).data, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// put caches data under key, evicting the least recently used entries to
+// stay within capacity. Data larger than the capacity is not cached.
+func (c *PageCache) put(key pageCacheKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := int64(len(data))
+	if size > c.capacity {
+		return
+	}
+	// A file re-registered meanwhile no longer needs the old data
+	if c.versions[key.file.path] != key.file {
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.size+size > c.capacity && c.lru.Len() > 0 {
+		c.removeLocked(c.lru.Back())
+		c.evictions++
+	}
+	c.items[key] = c.lru.PushFront(&pageCacheEntry{key: key, data: data})
+	c.size += size
+}
+
+func (c *PageCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*pageCacheEntry)
+	delete(c.items, entry.key)
+	c.lru.Remove(elem)
+	c.size -= int64(len(entry.data))
+}
+
+// Stats 返回缓存统计信息
+func (c *PageCache) Stats() PageCacheStats {
+	if c == nil {
+		return PageCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return PageCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   len(c.items),
+		Size:      c.size,
+		Capacity:  c.capacity,
+	}
+}
+
+// PageCacheStats 缓存统计信息；缓存关闭时全部为零
+type PageCacheStats struct {
+	Hits      uint64 // Reads served from memory
+	Misses    uint64 // Reads that went to the file
+	Evictions uint64 // Entries evicted to stay within Capacity
+	Entries   int
+	Size      int64 // Bytes cached
+	Capacity  int64
+}
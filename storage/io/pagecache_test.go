@@ -0,0 +1,238 @@
+package io
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setupCachedAsyncIO 创建带 PageCache 的 AsyncIO
+func setupCachedAsyncIO(t testing.TB, cacheBytes int64) *AsyncIO {
+	config := DefaultConfig()
+	config.Workers = 4
+	config.PageCacheBytes = cacheBytes
+	aio, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create AsyncIO: %v", err)
+	}
+	t.Cleanup(func() { aio.Close() })
+	return aio
+}
+
+func readOnce(t testing.TB, aio *AsyncIO, fileID string, offset int64, size int32) []byte {
+	result := <-aio.Read(context.Background(), fileID, offset, size)
+	if result.Error != nil {
+		t.Fatalf("Read(%s, %d, %d) failed: %v", fileID, offset, size, result.Error)
+	}
+	return result.Data
+}
+
+func TestPageCache_HitMiss(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.dat")
+	data := bytes.Repeat([]byte("0123456789abcdef"), 256) // 4KB
+	if err := os.WriteFile(testFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	aio := setupCachedAsyncIO(t, 1<<20)
+	// Two file IDs of the same path share the cached data
+	for _, id := range []string{"a", "b"} {
+		if err := aio.RegisterFile(id, testFile); err != nil {
+			t.Fatalf("RegisterFile failed: %v", err)
+		}
+	}
+
+	for pass := 0; pass < 3; pass++ {
+		for _, id := range []string{"a", "b"} {
+			got := readOnce(t, aio, id, 1024, 512)
+			if !bytes.Equal(got, data[1024:1536]) {
+				t.Fatalf("pass %d, %s: wrong data", pass, id)
+			}
+		}
+	}
+
+	stats := aio.Stats().PageCache
+	if stats.Misses != 1 || stats.Hits != 5 {
+		t.Errorf("Hits/Misses = %d/%d, want 5/1", stats.Hits, stats.Misses)
+	}
+	if stats.Entries != 1 || stats.Size != 512 {
+		t.Errorf("Entries/Size = %d/%d, want 1/512", stats.Entries, stats.Size)
+	}
+	if reads := aio.Stats().Scheduler.Submitted; reads != 1 {
+		t.Errorf("Submitted %d reads, want 1", reads)
+	}
+
+	// Reads past the end of the file come back short and are not cached
+	readOnce(t, aio, "a", 4000, 512)
+	readOnce(t, aio, "a", 4000, 512)
+	if stats := aio.Stats().PageCache; stats.Entries != 1 {
+		t.Errorf("Short read cached: %d entries", stats.Entries)
+	}
+
+	// ReadPages goes through the cache too
+	for _, ch := range aio.ReadPages(context.Background(), "b", []int64{1024, 2048}, 512) {
+		if result := <-ch; result.Error != nil {
+			t.Fatalf("ReadPages failed: %v", result.Error)
+		}
+	}
+	if stats := aio.Stats().PageCache; stats.Entries != 2 || stats.Hits != 6 {
+		t.Errorf("After ReadPages Entries/Hits = %d/%d, want 2/6", stats.Entries, stats.Hits)
+	}
+}
+
+func TestPageCache_Eviction(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.dat")
+	createTestFile2(t, testFile, 8192)
+
+	aio := setupCachedAsyncIO(t, 2048)
+	if err := aio.RegisterFile("test", testFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+
+	// Four 1KB pages through a 2KB cache: only the last two stay
+	for i := int64(0); i < 4; i++ {
+		readOnce(t, aio, "test", i*1024, 1024)
+	}
+	stats := aio.Stats().PageCache
+	if stats.Entries != 2 || stats.Size != 2048 || stats.Evictions != 2 {
+		t.Errorf("Entries/Size/Evictions = %d/%d/%d, want 2/2048/2",
+			stats.Entries, stats.Size, stats.Evictions)
+	}
+
+	// Page 2 is used, so page 3 is the one evicted next
+	readOnce(t, aio, "test", 2048, 1024)
+	readOnce(t, aio, "test", 0, 1024)
+	readOnce(t, aio, "test", 2048, 1024)
+	stats = aio.Stats().PageCache
+	if stats.Hits != 2 || stats.Misses != 5 {
+		t.Errorf("Hits/Misses = %d/%d, want 2/5", stats.Hits, stats.Misses)
+	}
+
+	// Reads larger than the whole cache are not cached
+	readOnce(t, aio, "test", 0, 4096)
+	if stats := aio.Stats().PageCache; stats.Size > stats.Capacity {
+		t.Errorf("Size %d over capacity %d", stats.Size, stats.Capacity)
+	}
+}
+
+func TestPageCache_InvalidateOnRewrite(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.dat")
+	if err := os.WriteFile(testFile, bytes.Repeat([]byte{1}, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	aio := setupCachedAsyncIO(t, 1<<20)
+	if err := aio.RegisterFile("old", testFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+	readOnce(t, aio, "old", 0, 1024)
+
+	// Rewrite the file, as a compaction or append would, and register it
+	// again under a new ID
+	newData := bytes.Repeat([]byte{2}, 8192)
+	if err := os.WriteFile(testFile, newData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(testFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := aio.RegisterFile("new", testFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+	if stats := aio.Stats().PageCache; stats.Entries != 0 {
+		t.Errorf("Stale entries kept after rewrite: %d", stats.Entries)
+	}
+
+	if got := readOnce(t, aio, "new", 0, 1024); !bytes.Equal(got, newData[:1024]) {
+		t.Errorf("Read stale data after rewrite")
+	}
+	if stats := aio.Stats().PageCache; stats.Hits != 0 {
+		t.Errorf("Hits = %d, want 0", stats.Hits)
+	}
+}
+
+func TestPageCache_InvalidateOnWrite(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.dat")
+	createTestFile2(t, testFile, 4096)
+
+	aio := setupCachedAsyncIO(t, 1<<20)
+	if err := aio.RegisterFile("test", testFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+	readOnce(t, aio, "test", 0, 16)
+
+	patch := []byte("new bytes here!!")
+	if result := <-aio.Write(context.Background(), "test", 0, patch); result.Error != nil {
+		t.Fatalf("Write failed: %v", result.Error)
+	}
+	if got := readOnce(t, aio, "test", 0, 16); !bytes.Equal(got, patch) {
+		t.Errorf("Read %q after write, want %q", got, patch)
+	}
+}
+
+func TestPageCache_Concurrent(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.dat")
+	data := make([]byte, 64*1024)
+	for i := range data {
+		data[i] = byte(i / 1024)
+	}
+	if err := os.WriteFile(testFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for half of the pages, so readers evict each other's entries
+	aio := setupCachedAsyncIO(t, 32*1024)
+	if err := aio.RegisterFile("test", testFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				page := int64((g*7 + i) % 64)
+				result := <-aio.Read(context.Background(), "test", page*1024, 1024)
+				if result.Error != nil {
+					t.Errorf("Read failed: %v", result.Error)
+					return
+				}
+				if !bytes.Equal(result.Data, data[page*1024:(page+1)*1024]) {
+					t.Errorf("Wrong data for page %d", page)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := aio.Stats().PageCache
+	if stats.Hits+stats.Misses != 8*200 {
+		t.Errorf("Hits+Misses = %d, want %d", stats.Hits+stats.Misses, 8*200)
+	}
+	if stats.Size > stats.Capacity {
+		t.Errorf("Size %d over capacity %d", stats.Size, stats.Capacity)
+	}
+}
+
+func TestPageCache_Disabled(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.dat")
+	createTestFile2(t, testFile, 4096)
+
+	aio := setupCachedAsyncIO(t, 0)
+	if err := aio.RegisterFile("test", testFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+	readOnce(t, aio, "test", 0, 1024)
+	readOnce(t, aio, "test", 0, 1024)
+
+	if stats := aio.Stats().PageCache; stats != (PageCacheStats{}) {
+		t.Errorf("Stats of a disabled cache = %+v, want zero", stats)
+	}
+}
@@ -0,0 +1,54 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ReplaceFile 将 oldpath 重命名为 newpath，若 newpath 已存在则原子地替换它。
+// Windows 上目标文件可能被其他句柄短暂占用，实现会重试而不是直接失败。
+func ReplaceFile(oldpath, newpath string) error {
+	return replaceFile(oldpath, newpath)
+}
+
+// WriteFileAtomic 先把 data 写入同目录下的临时文件并 fsync，再替换 path，
+// 保证读者要么看到旧内容，要么看到完整的新内容。
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := ReplaceFile(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// OpenShared 以只读方式打开文件，并允许其他句柄在文件打开期间将其删除或替换。
+// 在 Unix 上与 os.Open 相同；在 Windows 上额外指定 FILE_SHARE_DELETE，
+// 这样保持打开的只读文件（例如内存映射的索引文件）不会阻塞 ReplaceFile。
+func OpenShared(path string) (*os.File, error) {
+	return openShared(path)
+}
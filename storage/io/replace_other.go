@@ -0,0 +1,13 @@
+//go:build !windows
+
+package io
+
+import "os"
+
+func replaceFile(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func openShared(path string) (*os.File, error) {
+	return os.Open(path)
+}
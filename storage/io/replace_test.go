@@ -0,0 +1,103 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceFileOverExisting(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dir with spaces", "目录")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	target := filepath.Join(dir, "données.bin")
+	src := filepath.Join(dir, "new file.bin")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := ReplaceFile(src, target); err != nil {
+		t.Fatalf("ReplaceFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "new" {
+		t.Errorf("Expected %q, got %q", "new", data)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("Expected source to be gone, got %v", err)
+	}
+}
+
+func TestReplaceFileWhileOpenShared(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "mapped.adj")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	reader, err := OpenShared(target)
+	if err != nil {
+		t.Fatalf("OpenShared failed: %v", err)
+	}
+	defer reader.Close()
+
+	if err := WriteFileAtomic(target, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic over open file failed: %v", err)
+	}
+
+	// The open handle keeps seeing the old contents
+	buf := make([]byte, 3)
+	if _, err := reader.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if string(buf) != "old" {
+		t.Errorf("Expected open handle to read %q, got %q", "old", buf)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "new" {
+		t.Errorf("Expected %q, got %q", "new", data)
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTemporaries(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ünïcødé dir")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	path := filepath.Join(dir, "metadata.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFileAtomic failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "metadata.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Expected only metadata.json, got %v", names)
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "x"), []byte("x"), 0644); err == nil {
+		t.Error("Expected error writing into a missing directory")
+	}
+}
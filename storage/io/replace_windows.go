@@ -0,0 +1,60 @@
+//go:build windows
+
+package io
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// 目标文件被占用时的重试次数与初始退避时间
+	replaceRetries = 10
+	replaceBackoff = 5 * time.Millisecond
+
+	errorSharingViolation syscall.Errno = 32
+)
+
+// replaceFile 使用 os.Rename（MoveFileEx + MOVEFILE_REPLACE_EXISTING）。
+// 目标被杀毒软件、索引服务或并发读者短暂打开时会返回 ACCESS_DENIED 或
+// SHARING_VIOLATION，此时按指数退避重试。
+func replaceFile(oldpath, newpath string) error {
+	backoff := replaceBackoff
+	var err error
+	for i := 0; i < replaceRetries; i++ {
+		if err = os.Rename(oldpath, newpath); err == nil || !isSharingError(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return err
+}
+
+func isSharingError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == syscall.ERROR_ACCESS_DENIED || errno == errorSharingViolation
+}
+
+func openShared(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := syscall.CreateFile(name,
+		syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -24,6 +24,19 @@ const (
 	PriorityLow                    // 后台任务（空闲时执行）
 )
 
+// The Scheduler keeps two queues: interactive requests (PriorityHigh and
+// PriorityNormal) and background requests (PriorityLow), see
+// SchedulingConfig
+const (
+	PriorityInteractive = PriorityHigh
+	PriorityBackground  = PriorityLow
+)
+
+// background reports whether p is served from the background queue
+func (p Priority) background() bool {
+	return p >= PriorityLow
+}
+
 // IORequest 表示一个 I/O 请求
 type IORequest struct {
 	ID       uint64          // 唯一标识
@@ -36,6 +49,10 @@ type IORequest struct {
 	Context  context.Context // 用于取消
 	Data     []byte          // 写入时的数据
 	Callback chan IOResult   // 结果回调通道, NOTE: 用户必须消费该 Channel，否则可能会导致 worker 阻塞
+
+	enqueued time.Time      // When the Scheduler queued the request
+	onDone   func()         // Called by the Executor once the result is sent
+	onResult func(IOResult) // Called by the Executor before the result is sent
 }
 
 // IOResult 表示 I/O 操作的结果
@@ -7,17 +7,115 @@ import (
 	"time"
 )
 
+// SchedulingPolicy 定义交互请求与后台请求之间的调度策略
+type SchedulingPolicy int
+
+const (
+	// StrictPriority serves background requests only while no interactive
+	// request is queued, apart from the MinBackgroundShare
+	StrictPriority SchedulingPolicy = iota
+	// WeightedFair shares the workers between the classes in proportion to
+	// their weights while both have requests queued
+	WeightedFair
+)
+
+func (p SchedulingPolicy) String() string {
+	switch p {
+	case StrictPriority:
+		return "strict"
+	case WeightedFair:
+		return "weighted-fair"
+	default:
+		return fmt.Sprintf("SchedulingPolicy(%d)", int(p))
+	}
+}
+
+// defaultMinBackgroundShare 是 MinBackgroundShare 为 0 时使用的值
+const defaultMinBackgroundShare = 0.05
+
+// SchedulingConfig 配置交互请求（PriorityHigh、PriorityNormal）与后台请求
+// （PriorityLow）之间的调度
+type SchedulingConfig struct {
+	Policy SchedulingPolicy
+
+	// Weights of the classes under WeightedFair (0 = 4 and 1)
+	InteractiveWeight int
+	BackgroundWeight  int
+
+	// MinBackgroundShare is the fraction of dispatches that go to
+	// background requests while both classes have requests queued, under
+	// either policy, so that background work is never starved (0 = 0.05)
+	MinBackgroundShare float64
+}
+
+// DefaultSchedulingConfig 返回默认调度配置
+func DefaultSchedulingConfig() SchedulingConfig {
+	return SchedulingConfig{
+		Policy:             StrictPriority,
+		InteractiveWeight:  4,
+		BackgroundWeight:   1,
+		MinBackgroundShare: defaultMinBackgroundShare,
+	}
+}
+
+// Validate 检查配置参数
+func (c SchedulingConfig) Validate() error {
+	if c.Policy != StrictPriority && c.Policy != WeightedFair {
+		return fmt.Errorf("unknown scheduling policy: %v", c.Policy)
+	}
+	if c.InteractiveWeight < 0 || c.BackgroundWeight < 0 {
+		return fmt.Errorf("scheduling weights must not be negative: interactive=%d, background=%d",
+			c.InteractiveWeight, c.BackgroundWeight)
+	}
+	if c.MinBackgroundShare < 0 || c.MinBackgroundShare >= 1 {
+		return fmt.Errorf("min background share must be in [0, 1): %v", c.MinBackgroundShare)
+	}
+	return nil
+}
+
+// backgroundShare 返回两类请求都在排队时分给后台请求的调度比例
+func (c SchedulingConfig) backgroundShare() float64 {
+	minShare := c.MinBackgroundShare
+	if minShare == 0 {
+		minShare = defaultMinBackgroundShare
+	}
+	if c.Policy != WeightedFair {
+		return minShare
+	}
+	iw, bw := c.InteractiveWeight, c.BackgroundWeight
+	if iw == 0 && bw == 0 {
+		iw, bw = 4, 1
+	}
+	return max(float64(bw)/float64(iw+bw), minShare)
+}
+
 // Scheduler 负责调度 I/O 请求
+//
+// 交互请求与后台请求分别排队。Scheduler 最多同时向 Executor 下发与 worker
+// 数量相同的请求，其余请求留在队列中，这样新到的交互请求不必排在已下发的
+// 后台请求之后。
 type Scheduler struct {
 	mu           sync.Mutex
-	queue        *priorityQueue
+	queue        *priorityQueue // 交互请求
+	background   *priorityQueue // 后台请求
 	executor     *Executor
 	maxQueueSize int
 
+	inFlight    int // 已下发到 Executor、尚未完成的请求数
+	maxInFlight int
+
+	// 两类请求都在排队时，每次调度给后台请求累积 backgroundShare 的额度，
+	// 额度满 1 时调度一个后台请求
+	backgroundShare  float64
+	backgroundCredit float64
+
 	submitted uint64
 	completed uint64
 	errors    uint64
 
+	interactiveStats PriorityStats
+	backgroundStats  PriorityStats
+
 	scheduleChan chan struct{}
 	stopChan     chan struct{}
 	wg           sync.WaitGroup
@@ -26,18 +124,27 @@ type Scheduler struct {
 	cond *sync.Cond
 }
 
-// NewScheduler 创建一个新的 Scheduler
+// NewScheduler 使用默认调度配置创建一个新的 Scheduler
 func NewScheduler(executor *Executor, maxQueueSize int) *Scheduler {
+	return NewSchedulerWithConfig(executor, maxQueueSize, DefaultSchedulingConfig())
+}
+
+// NewSchedulerWithConfig 创建一个使用指定调度配置的 Scheduler，
+// cfg 应已通过 Validate 检查
+func NewSchedulerWithConfig(executor *Executor, maxQueueSize int, cfg SchedulingConfig) *Scheduler {
 	if maxQueueSize <= 0 {
 		maxQueueSize = 10000
 	}
 
 	s := &Scheduler{
-		queue:        newPriorityQueue(maxQueueSize),
-		executor:     executor,
-		maxQueueSize: maxQueueSize,
-		scheduleChan: make(chan struct{}, 100),
-		stopChan:     make(chan struct{}),
+		queue:           newPriorityQueue(0),
+		background:      newPriorityQueue(0),
+		executor:        executor,
+		maxQueueSize:    maxQueueSize,
+		maxInFlight:     executor.workers,
+		backgroundShare: cfg.backgroundShare(),
+		scheduleChan:    make(chan struct{}, 100),
+		stopChan:        make(chan struct{}),
 	}
 
 	// 初始化条件变量
@@ -62,7 +169,7 @@ func (s *Scheduler) Submit(req *IORequest) error {
 	}
 
 	// 如果队列已满，等待
-	for s.queue.Len() >= s.maxQueueSize {
+	for s.queued() >= s.maxQueueSize {
 		select {
 		case <-s.stopChan:
 			s.mu.Unlock()
@@ -73,7 +180,7 @@ func (s *Scheduler) Submit(req *IORequest) error {
 		}
 	}
 
-	heap.Push(s.queue, req)
+	s.push(req)
 	s.submitted++
 	s.mu.Unlock()
 
@@ -99,7 +206,7 @@ func (s *Scheduler) SubmitBatch(reqs []*IORequest) error {
 	}
 
 	// 如果空间不足，等待
-	for s.queue.Len()+len(reqs) > s.maxQueueSize {
+	for s.queued()+len(reqs) > s.maxQueueSize {
 		select {
 		case <-s.stopChan:
 			s.mu.Unlock()
@@ -110,7 +217,7 @@ func (s *Scheduler) SubmitBatch(reqs []*IORequest) error {
 	}
 
 	for _, req := range reqs {
-		heap.Push(s.queue, req)
+		s.push(req)
 		s.submitted++
 	}
 	s.mu.Unlock()
@@ -124,8 +231,70 @@ func (s *Scheduler) SubmitBatch(reqs []*IORequest) error {
 	return nil
 }
 
+// queued 返回两个队列中的请求总数，调用者需持有锁
+func (s *Scheduler) queued() int {
+	return s.queue.Len() + s.background.Len()
+}
+
+// push 将请求放入其优先级对应的队列，调用者需持有锁
+func (s *Scheduler) push(req *IORequest) {
+	req.enqueued = time.Now()
+	req.onDone = s.done
+	if req.Priority.background() {
+		heap.Push(s.background, req)
+		s.backgroundStats.QueueDepth++
+	} else {
+		heap.Push(s.queue, req)
+		s.interactiveStats.QueueDepth++
+	}
+}
+
+// next 按调度策略取出下一个请求，调用者需持有锁且至少有一个队列非空
+func (s *Scheduler) next() *IORequest {
+	takeBackground := s.queue.Len() == 0
+	if s.queue.Len() > 0 && s.background.Len() > 0 {
+		s.backgroundCredit += s.backgroundShare
+		// 容差避免 0.1 之类的份额因浮点舍入少调度一次
+		if s.backgroundCredit >= 1-1e-9 {
+			s.backgroundCredit--
+			takeBackground = true
+		}
+	}
+
+	if takeBackground {
+		s.backgroundStats.QueueDepth--
+		return heap.Pop(s.background).(*IORequest)
+	}
+	s.interactiveStats.QueueDepth--
+	return heap.Pop(s.queue).(*IORequest)
+}
+
+// unpop 将 next 取出但未能下发的请求放回队列，调用者需持有锁
+func (s *Scheduler) unpop(req *IORequest) {
+	if req.Priority.background() {
+		heap.Push(s.background, req)
+		s.backgroundStats.QueueDepth++
+	} else {
+		heap.Push(s.queue, req)
+		s.interactiveStats.QueueDepth++
+	}
+}
+
+// done 在 Executor 完成一个已下发的请求后调用
+func (s *Scheduler) done() {
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+
+	select {
+	case s.scheduleChan <- struct{}{}:
+	default:
+	}
+}
+
 // tryScheduleBatch 尝试批量调度多个请求到 Executor
 // 优化：批量取出后再提交，批量更新统计
+// 每批最多取出空闲 worker 数量的请求，worker 完成请求后会再次触发调度
 func (s *Scheduler) tryScheduleBatch() {
 	const batchSize = 32 // 每次最多调度 32 个请求
 
@@ -133,38 +302,49 @@ func (s *Scheduler) tryScheduleBatch() {
 		// 1. 在持有锁的情况下，批量取出请求
 		s.mu.Lock()
 
-		if s.queue.Len() == 0 {
+		if s.queued() == 0 || s.inFlight >= s.maxInFlight {
 			s.mu.Unlock()
-			return // 队列空了，退出
+			return // 队列空了或 worker 已满，退出
 		}
 
 		batch := make([]*IORequest, 0, batchSize)
 
-		for i := 0; i < batchSize && s.queue.Len() > 0; i++ {
-			item := heap.Pop(s.queue).(*IORequest)
+		now := time.Now()
+		for len(batch) < batchSize && s.queued() > 0 && s.inFlight < s.maxInFlight {
+			item := s.next()
 
 			// 检查超时
-			if !item.Deadline.IsZero() && time.Now().After(item.Deadline) {
+			if !item.Deadline.IsZero() && now.After(item.Deadline) {
 				s.sendTimeout(item)
 				s.errors++
 				continue
 			}
 
+			stats := &s.interactiveStats
+			if item.Priority.background() {
+				stats = &s.backgroundStats
+			}
+			stats.record(now.Sub(item.enqueued))
+
+			s.inFlight++
 			batch = append(batch, item)
 			s.cond.Signal() // 通知一个等待的提交者
 		}
 
-		hasMore := s.queue.Len() > 0
 		s.mu.Unlock()
 
 		// 2. 在锁外提交到 Executor
 		successCount := 0
-		for _, item := range batch {
+		for i, item := range batch {
 			err := s.executor.Submit(item) // 阻塞提交
 			if err != nil {
 				// Executor 已关闭，放回队列
 				s.mu.Lock()
-				heap.Push(s.queue, item)
+				for _, rest := range batch[i:] {
+					s.unpop(rest)
+					s.inFlight--
+				}
+				s.completed += uint64(successCount)
 				s.mu.Unlock()
 				return // Executor 关闭了，退出循环
 			}
@@ -177,12 +357,7 @@ func (s *Scheduler) tryScheduleBatch() {
 			s.completed += uint64(successCount)
 			s.mu.Unlock()
 		}
-
-		// 4. 如果队列中没有更多请求，退出
-		if !hasMore {
-			return
-		}
-		// 否则继续处理下一批
+		// 继续处理下一批，直到队列为空或 worker 已满
 	}
 }
 
@@ -223,8 +398,8 @@ func (s *Scheduler) Stop() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for s.queue.Len() > 0 {
-		req := heap.Pop(s.queue).(*IORequest)
+	for s.queued() > 0 {
+		req := s.next()
 		s.sendCancel(req)
 	}
 
@@ -248,11 +423,14 @@ func (s *Scheduler) Stats() SchedulerStats {
 	defer s.mu.Unlock()
 
 	return SchedulerStats{
-		QueueSize: s.queue.Len(),
-		QueueCap:  s.maxQueueSize, // 添加这一行
-		Submitted: s.submitted,
-		Completed: s.completed,
-		Errors:    s.errors,
+		QueueSize:   s.queued(),
+		QueueCap:    s.maxQueueSize, // 添加这一行
+		Submitted:   s.submitted,
+		Completed:   s.completed,
+		Errors:      s.errors,
+		InFlight:    s.inFlight,
+		Interactive: s.interactiveStats,
+		Background:  s.backgroundStats,
 	}
 }
 
@@ -262,6 +440,33 @@ type SchedulerStats struct {
 	Submitted uint64
 	Completed uint64
 	Errors    uint64
+	InFlight  int // 已下发到 Executor、尚未完成的请求数
+
+	Interactive PriorityStats // PriorityHigh 与 PriorityNormal 请求
+	Background  PriorityStats // PriorityLow 请求
+}
+
+// PriorityStats 是一类请求的调度统计
+// 等待时间为请求从进入队列到下发给 Executor 的时间
+type PriorityStats struct {
+	QueueDepth int           // 当前排队的请求数
+	Dispatched uint64        // 已下发的请求数
+	TotalWait  time.Duration // 已下发请求的等待时间总和
+	MaxWait    time.Duration // 已下发请求的最长等待时间
+}
+
+// AvgWait 返回已下发请求的平均等待时间
+func (p PriorityStats) AvgWait() time.Duration {
+	if p.Dispatched == 0 {
+		return 0
+	}
+	return p.TotalWait / time.Duration(p.Dispatched)
+}
+
+func (p *PriorityStats) record(wait time.Duration) {
+	p.Dispatched++
+	p.TotalWait += wait
+	p.MaxWait = max(p.MaxWait, wait)
 }
 
 // priorityQueue 优先级队列实现
@@ -279,8 +484,13 @@ func (pq *priorityQueue) Len() int {
 	return len(pq.items)
 }
 
+// Less 按优先级排序，同一优先级按提交顺序（ID 递增）
 func (pq *priorityQueue) Less(i, j int) bool {
-	return pq.items[i].Priority < pq.items[j].Priority
+	a, b := pq.items[i], pq.items[j]
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	return a.ID < b.ID
 }
 
 func (pq *priorityQueue) Swap(i, j int) {
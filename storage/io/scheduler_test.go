@@ -1,7 +1,9 @@
 package io
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -30,7 +32,7 @@ func TestNewScheduler(t *testing.T) {
 
 func TestScheduler_Submit(t *testing.T) {
 	tmpDir := t.TempDir()
-	testFile := tmpDir + "/test.dat"
+	testFile := filepath.Join(tmpDir, "test.dat")
 
 	if err := createTestFile(testFile, 1024); err != nil {
 		t.Fatalf("Failed to create test file: %v", err)
@@ -80,7 +82,7 @@ func TestScheduler_Submit(t *testing.T) {
 
 func TestScheduler_PriorityOrdering(t *testing.T) {
 	tmpDir := t.TempDir()
-	testFile := tmpDir + "/test.dat"
+	testFile := filepath.Join(tmpDir, "test.dat")
 
 	if err := createTestFile(testFile, 1024); err != nil {
 		t.Fatalf("Failed to create test file: %v", err)
@@ -146,7 +148,7 @@ func TestScheduler_PriorityOrdering(t *testing.T) {
 
 func TestScheduler_SubmitBatch(t *testing.T) {
 	tmpDir := t.TempDir()
-	testFile := tmpDir + "/test.dat"
+	testFile := filepath.Join(tmpDir, "test.dat")
 
 	if err := createTestFile(testFile, 10240); err != nil {
 		t.Fatalf("Failed to create test file: %v", err)
@@ -220,7 +222,7 @@ func TestScheduler_QueueFullBlocking(t *testing.T) {
 
 func TestScheduler_Stop(t *testing.T) {
 	tmpDir := t.TempDir()
-	testFile := tmpDir + "/test.dat"
+	testFile := filepath.Join(tmpDir, "test.dat")
 
 	if err := createTestFile(testFile, 1024); err != nil {
 		t.Fatalf("Failed to create test file: %v", err)
@@ -286,7 +288,7 @@ func createTestFile(path string, size int) error {
 
 func BenchmarkScheduler_Submit(b *testing.B) {
 	tmpDir := b.TempDir()
-	testFile := tmpDir + "/bench.dat"
+	testFile := filepath.Join(tmpDir, "bench.dat")
 
 	if err := createTestFile(testFile, 1024*1024); err != nil {
 		b.Fatalf("Failed to create test file: %v", err)
@@ -314,3 +316,134 @@ func BenchmarkScheduler_Submit(b *testing.B) {
 		<-req.Callback
 	}
 }
+
+func TestScheduler_BackgroundShare(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  SchedulingConfig
+		want int // background dispatches out of 100 while both classes queue
+	}{
+		{"strict", DefaultSchedulingConfig(), 5},
+		{"strict min share", SchedulingConfig{Policy: StrictPriority, MinBackgroundShare: 0.1}, 10},
+		{"weighted", SchedulingConfig{Policy: WeightedFair, InteractiveWeight: 3, BackgroundWeight: 1}, 25},
+		{"weighted default", SchedulingConfig{Policy: WeightedFair}, 20},
+		{"weighted below min share", SchedulingConfig{Policy: WeightedFair, InteractiveWeight: 99, BackgroundWeight: 1, MinBackgroundShare: 0.1}, 10},
+	}
+	for _, tc := range cases {
+		if err := tc.cfg.Validate(); err != nil {
+			t.Fatalf("%s: Validate failed: %v", tc.name, err)
+		}
+		s := &Scheduler{
+			queue:           newPriorityQueue(0),
+			background:      newPriorityQueue(0),
+			backgroundShare: tc.cfg.backgroundShare(),
+		}
+		for i := 0; i < 200; i++ {
+			s.push(NewIORequest("test", 0, 1, PriorityInteractive))
+			s.push(NewIORequest("test", 0, 1, PriorityBackground))
+		}
+		background := 0
+		for i := 0; i < 100; i++ {
+			if s.next().Priority.background() {
+				background++
+			}
+		}
+		if background != tc.want {
+			t.Errorf("%s: %d of 100 dispatches were background, want %d", tc.name, background, tc.want)
+		}
+		if got := s.backgroundStats.QueueDepth + s.interactiveStats.QueueDepth; got != 300 {
+			t.Errorf("%s: queue depth %d, want 300", tc.name, got)
+		}
+	}
+
+	// Either class alone gets every dispatch
+	s := &Scheduler{queue: newPriorityQueue(0), background: newPriorityQueue(0), backgroundShare: 0.05}
+	for i := 0; i < 3; i++ {
+		s.push(NewIORequest("test", 0, 1, PriorityBackground))
+	}
+	for i := 0; i < 3; i++ {
+		if !s.next().Priority.background() {
+			t.Error("lone background request was not dispatched")
+		}
+	}
+
+	invalid := []SchedulingConfig{
+		{Policy: SchedulingPolicy(7)},
+		{InteractiveWeight: -1},
+		{MinBackgroundShare: 1},
+		{MinBackgroundShare: -0.5},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestScheduler_InteractiveWaitUnderBackgroundLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "large.dat")
+
+	const blockSize = 1 << 20
+	if err := createTestFile(testFile, 8*blockSize); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Workers = 2
+	aio, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer aio.Close()
+	if err := aio.RegisterFile("large", testFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+
+	// Saturate the workers with background reads
+	const numBackground = 400
+	ctx := context.Background()
+	background := make([]<-chan IOResult, 0, numBackground)
+	for i := 0; i < numBackground; i++ {
+		offset := int64(i%8) * blockSize
+		background = append(background, aio.ReadWithPriority(ctx, "large", offset, blockSize, PriorityBackground))
+	}
+
+	// Interactive reads issued while the background queue is full
+	const numInteractive = 20
+	for i := 0; i < numInteractive; i++ {
+		result := <-aio.ReadWithPriority(ctx, "large", int64(i)*4096, 4096, PriorityInteractive)
+		if result.Error != nil {
+			t.Fatalf("interactive read %d failed: %v", i, result.Error)
+		}
+	}
+	mid := aio.Stats().Scheduler
+	if mid.Interactive.Dispatched != numInteractive {
+		t.Fatalf("dispatched %d interactive reads, want %d", mid.Interactive.Dispatched, numInteractive)
+	}
+	if mid.Background.QueueDepth == 0 {
+		t.Skip("background reads finished before the interactive reads; disk too fast to saturate")
+	}
+
+	for i, ch := range background {
+		if result := <-ch; result.Error != nil {
+			t.Fatalf("background read %d failed: %v", i, result.Error)
+		}
+	}
+	stats := aio.Stats().Scheduler
+	if stats.Background.Dispatched != numBackground {
+		t.Errorf("dispatched %d background reads, want %d", stats.Background.Dispatched, numBackground)
+	}
+
+	// An interactive read waits for at most one background read per
+	// worker, far less than the background backlog
+	t.Logf("interactive wait avg %v max %v, background wait avg %v max %v",
+		stats.Interactive.AvgWait(), stats.Interactive.MaxWait, stats.Background.AvgWait(), stats.Background.MaxWait)
+	if stats.Interactive.MaxWait*4 > stats.Background.MaxWait {
+		t.Errorf("interactive max wait %v not bounded well below background max wait %v",
+			stats.Interactive.MaxWait, stats.Background.MaxWait)
+	}
+	if stats.Interactive.QueueDepth != 0 || stats.Background.QueueDepth != 0 || stats.QueueSize != 0 {
+		t.Errorf("queues not drained: %+v", stats)
+	}
+}
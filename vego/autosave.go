@@ -0,0 +1,45 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultAutoSaveInterval is how often a collection with only an auto-save
+// threshold is saved, see WithAutoSave
+const defaultAutoSaveInterval = time.Minute
+
+// markDirty counts n inserts, updates or deletes, and triggers the
+// auto-save once the threshold is reached. c.mu must be held for writing.
+func (c *Collection) markDirty(n int) {
+	pending := c.dirty.Add(int64(n))
+	threshold := c.config.AutoSaveThreshold
+	if c.autoSave != nil && threshold > 0 && pending >= int64(threshold) {
+		c.sched.trigger(c.autoSave)
+	}
+}
+
+// autoSaveTask is the background task saving a collection with unsaved
+// changes. The scheduler never runs it twice at once, and triggers while it
+// runs coalesce into one more run, which finds nothing to save unless
+// writes got in before the save gate closed.
+func (c *Collection) autoSaveTask(context.Context) error {
+	if c.dirty.Load() == 0 {
+		return nil
+	}
+	// A collection being closed saves as it closes
+	if err := c.Save(); err != nil && !errors.Is(err, ErrCollectionClosed) {
+		return err
+	}
+	return nil
+}
+
+// lastSaveTime returns when the collection was last saved, zero if it has
+// not been since it was opened
+func (c *Collection) lastSaveTime() time.Time {
+	if nanos := c.lastSave.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
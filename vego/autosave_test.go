@@ -0,0 +1,166 @@
+package vego
+
+import (
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAutoSave(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	open := func(t *testing.T, path string, opts ...Option) (*DB, *Collection) {
+		t.Helper()
+		db, err := Open(path, append([]Option{WithDimension(16)}, opts...)...)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		coll, err := db.Collection("docs")
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+		return db, coll
+	}
+	saved := func(coll *Collection) bool {
+		stats := coll.Stats()
+		return stats.PendingMutations == 0 && !stats.LastSaveTime.IsZero()
+	}
+
+	t.Run("Threshold", func(t *testing.T) {
+		db, coll := open(t, t.TempDir(), WithAutoSave(time.Hour, 10))
+		defer db.Close()
+
+		if err := coll.InsertBatch(barrierDocs(rng, 0, 6)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := coll.Delete("doc_000"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if stats := coll.Stats(); stats.PendingMutations != 7 || !stats.LastSaveTime.IsZero() {
+			t.Fatalf("PendingMutations = %d, LastSaveTime = %v before the threshold", stats.PendingMutations, stats.LastSaveTime)
+		}
+
+		if err := coll.InsertBatch(barrierDocs(rng, 6, 9)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if !waitFor(10*time.Second, func() bool { return saved(coll) }) {
+			t.Fatalf("not saved at the threshold: %+v", coll.Stats())
+		}
+
+		// Saved as a crash right now would find it
+		reopened, err := NewCollection("docs", crashCopy(t, coll), barrierConfig(true))
+		if err != nil {
+			t.Fatalf("NewCollection failed: %v", err)
+		}
+		defer reopened.Close()
+		if n := reopened.Count(); n != 8 {
+			t.Errorf("auto-saved %d documents, want 8", n)
+		}
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		db, coll := open(t, t.TempDir(), WithAutoSave(20*time.Millisecond, 0))
+		defer db.Close()
+
+		if err := coll.Insert(barrierDocs(rng, 0, 1)[0]); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if !waitFor(10*time.Second, func() bool { return saved(coll) }) {
+			t.Fatalf("not saved after the interval: %+v", coll.Stats())
+		}
+
+		// Nothing to save, so later runs leave the last save alone
+		last := coll.Stats().LastSaveTime
+		time.Sleep(100 * time.Millisecond)
+		if got := coll.Stats().LastSaveTime; !got.Equal(last) {
+			t.Errorf("clean collection saved again at %v", got)
+		}
+	})
+
+	t.Run("CloseFlushes", func(t *testing.T) {
+		path := t.TempDir()
+		db, coll := open(t, path, WithAutoSave(time.Hour, 1000))
+		if err := coll.InsertBatch(barrierDocs(rng, 0, 5)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		db, coll = open(t, path)
+		defer db.Close()
+		if n := coll.Count(); n != 5 {
+			t.Errorf("%d documents after Close, want 5", n)
+		}
+	})
+}
+
+func TestSaveDoesNotBlockSearches(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	coll, err := NewCollection("docs", filepath.Join(t.TempDir(), "docs"), barrierConfig(false))
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	defer coll.Close()
+	docs := barrierDocs(rng, 0, 50)
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	// Hold a save part way through
+	blocked, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	afterSaveStep = func(step string) error {
+		if step == "documents" {
+			once.Do(func() {
+				close(blocked)
+				<-release
+			})
+		}
+		return nil
+	}
+	t.Cleanup(func() { afterSaveStep = nil })
+	saveErr := make(chan error, 1)
+	go func() { saveErr <- coll.Save() }()
+	<-blocked
+
+	search := func() {
+		t.Helper()
+		done := make(chan error, 1)
+		go func() {
+			_, err := coll.Search(docs[0].Vector, 5)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Search failed: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("search blocked by the save")
+		}
+	}
+	search()
+
+	// A write waits for the save, without holding back searches meanwhile
+	inserted := make(chan error, 1)
+	go func() { inserted <- coll.Insert(barrierDocs(rng, 50, 51)[0]) }()
+	time.Sleep(50 * time.Millisecond)
+	search()
+	select {
+	case err := <-inserted:
+		t.Fatalf("insert finished during the save: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-saveErr; err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := <-inserted; err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if stats := coll.Stats(); stats.PendingMutations != 1 {
+		t.Errorf("PendingMutations = %d after the save, want 1", stats.PendingMutations)
+	}
+}
@@ -0,0 +1,245 @@
+package vego
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+const (
+	// setsDirName holds hard-linked copies of the files of the last saves,
+	// one directory per save
+	setsDirName = "sets"
+
+	// stampFileName records which save the files of a directory belong to
+	stampFileName = "stamp.json"
+
+	// savedSetsKept is how many complete save sets are kept: the published
+	// one and the one before it to fall back to
+	savedSetsKept = 2
+)
+
+// setEntries are the files and directories of a save that must come from the
+// same save, relative to the collection directory
+var setEntries = []string{"documents", "index", "mappings.json"}
+
+// afterSaveStep, when set by tests, runs after each step of save; an error
+// stops the save at that point as a crash would
+var afterSaveStep func(step string) error
+
+// saveStep reports the end of a save step to afterSaveStep
+func saveStep(step string) error {
+	if afterSaveStep == nil {
+		return nil
+	}
+	return afterSaveStep(step)
+}
+
+// stamp is the content of a stamp file and of the checkpoint field of
+// mappings.json. Checkpoint 0 means the files changed outside a save.
+type stamp struct {
+	Checkpoint uint64 `json:"checkpoint"`
+}
+
+// readStamp returns the save recorded in dir's stamp file, or 0 if it has
+// none
+func readStamp(dir string) (uint64, error) {
+	return readCheckpointField(filepath.Join(dir, stampFileName))
+}
+
+// writeStamp atomically records that the files of dir belong to save id
+func writeStamp(dir string, id uint64) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(stamp{Checkpoint: id})
+	if err != nil {
+		return err
+	}
+	return lanceio.WriteFileAtomic(filepath.Join(dir, stampFileName), data, 0644)
+}
+
+// readCheckpointField reads the checkpoint field of the JSON file path, or 0
+// if the file does not exist
+func readCheckpointField(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var s stamp
+	if err := json.Unmarshal(data, &s); err != nil {
+		return 0, fmt.Errorf("%w: parse %s: %v", ErrStorageCorrupted, filepath.Base(path), err)
+	}
+	return s.Checkpoint, nil
+}
+
+// setMatches reports whether the documents, index and mappings under dir all
+// belong to save id
+func setMatches(dir string, id uint64) bool {
+	for _, read := range []func() (uint64, error){
+		func() (uint64, error) { return readStamp(filepath.Join(dir, "documents")) },
+		func() (uint64, error) { return readStamp(filepath.Join(dir, "index")) },
+		func() (uint64, error) { return readCheckpointField(filepath.Join(dir, "mappings.json")) },
+	} {
+		if got, err := read(); err != nil || got != id {
+			return false
+		}
+	}
+	return true
+}
+
+// saveSetDir returns the directory of the set of save id under the
+// collection directory dir
+func saveSetDir(dir string, id uint64) string {
+	return filepath.Join(dir, setsDirName, fmt.Sprintf("%020d", id))
+}
+
+// listSaveSets returns the saves with a complete set under the collection
+// directory dir, oldest first. Sets are renamed into place once linked, so
+// any directory with a plain numeric name is complete.
+func listSaveSets(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, setsDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, entry := range entries {
+		if id, err := strconv.ParseUint(entry.Name(), 10, 64); err == nil && entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// linkSaveSet hard-links the files just written by save id into its set.
+// c.mu must be held.
+func (c *Collection) linkSaveSet(id uint64) error {
+	dir := saveSetDir(c.path, id)
+	tmp := dir + ".tmp"
+	for _, stale := range []string{dir, tmp} {
+		if err := os.RemoveAll(stale); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range setEntries {
+		if _, err := linkTree(filepath.Join(c.path, name), filepath.Join(tmp, name)); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, dir)
+}
+
+// pruneSaveSets removes all but the newest savedSetsKept sets and any set
+// left incomplete by a crash
+func (c *Collection) pruneSaveSets() error {
+	ids, err := listSaveSets(c.path)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(ids)-savedSetsKept; i++ {
+		if err := os.RemoveAll(saveSetDir(c.path, ids[i])); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(c.path, setsDirName))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			if err := os.RemoveAll(filepath.Join(c.path, setsDirName, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// publishedSet returns the newest complete set of the collection directory
+// dir that is not newer than the published save, and false if there is none
+func publishedSet(dir string, info generationInfo) (uint64, bool, error) {
+	if info.Checkpoint == 0 {
+		return 0, false, nil
+	}
+	ids, err := listSaveSets(dir)
+	if err != nil {
+		return 0, false, err
+	}
+	for i := len(ids) - 1; i >= 0; i-- {
+		if ids[i] <= info.Checkpoint {
+			return ids[i], true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// restoreSaveSet makes the documents, index and mappings of the collection
+// directory dir match a single published save before they are opened. If
+// they do not all carry the published save's stamp, because the process
+// stopped during a save or changed documents after one, they are replaced by
+// links to that save's set, or to the newest complete set before it if that
+// one is missing, and the manifest is rewritten to point at it. Collections
+// without any set are left for the repair path of load.
+func restoreSaveSet(dir string) error {
+	info, err := readGeneration(dir)
+	if err != nil {
+		return err
+	}
+	if info.Checkpoint > 0 && !info.Saving && setMatches(dir, info.Checkpoint) {
+		return nil
+	}
+
+	id, ok, err := publishedSet(dir, info)
+	if err != nil || !ok {
+		return err
+	}
+	if !setMatches(dir, id) {
+		for _, name := range setEntries {
+			if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+			if _, err := linkTree(filepath.Join(saveSetDir(dir, id), name), filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+		log.Printf("Restored %s to save %d after an incomplete save or unsaved changes", dir, id)
+	}
+
+	if info.Generation != id || info.Checkpoint != id || info.Saving {
+		return writeGeneration(dir, generationInfo{Generation: id, Checkpoint: id})
+	}
+	return nil
+}
+
+// publishedDir returns where a replica of the collection directory dir loads
+// documents, index and mappings from: dir itself if its files all belong to
+// the published save, else that save's set, which stays until the writer
+// has saved twice more. Collections without sets load from dir.
+func publishedDir(dir string, info generationInfo) (string, error) {
+	if info.Checkpoint == 0 || setMatches(dir, info.Checkpoint) {
+		return dir, nil
+	}
+	id, ok, err := publishedSet(dir, info)
+	if err != nil || !ok {
+		return dir, err
+	}
+	return saveSetDir(dir, id), nil
+}
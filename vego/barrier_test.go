@@ -0,0 +1,251 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// errCrash stops a save at an injected point as a crash would
+var errCrash = errors.New("injected crash")
+
+// crashAfter makes saves stop after step until the test ends
+func crashAfter(t *testing.T, step string) {
+	t.Helper()
+	afterSaveStep = func(s string) error {
+		if s == step {
+			return errCrash
+		}
+		return nil
+	}
+	t.Cleanup(func() { afterSaveStep = nil })
+}
+
+func barrierConfig(readOnly bool) *Config {
+	config := DefaultConfig()
+	config.Dimension = 16
+	config.ReadOnly = readOnly
+	return config
+}
+
+func barrierDocs(rng *rand.Rand, from, to int) []*Document {
+	docs := make([]*Document, 0, to-from)
+	for i := from; i < to; i++ {
+		doc := &Document{
+			ID:       fmt.Sprintf("doc_%03d", i),
+			Vector:   make([]float32, 16),
+			Metadata: map[string]interface{}{"i": i},
+		}
+		for j := range doc.Vector {
+			doc.Vector[j] = rng.Float32()
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// mutate inserts, updates and deletes documents without saving
+func mutate(t *testing.T, coll *Collection, rng *rand.Rand, from, to int) {
+	t.Helper()
+	if err := coll.InsertBatch(barrierDocs(rng, from, to)); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	doc, err := coll.Get("doc_000")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	doc.Metadata["i"] = -from
+	if err := coll.Update(doc); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := coll.Delete(fmt.Sprintf("doc_%03d", from-1)); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+}
+
+func contentHash(t *testing.T, coll *Collection) string {
+	t.Helper()
+	hash, err := coll.ContentHash(context.Background())
+	if err != nil {
+		t.Fatalf("ContentHash failed: %v", err)
+	}
+	return hash
+}
+
+// checkIndexMatches verifies that every document is found by its own vector,
+// so the index and mappings belong to the same save as the documents
+func checkIndexMatches(t *testing.T, coll *Collection) {
+	t.Helper()
+	coll.mu.RLock()
+	ids := make([]string, 0, len(coll.docToNode))
+	for id := range coll.docToNode {
+		ids = append(ids, id)
+	}
+	coll.mu.RUnlock()
+
+	for _, id := range ids {
+		doc, err := coll.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", id, err)
+		}
+		// Updated documents leave their old node behind, so look past it
+		results, err := coll.Search(doc.Vector, 3, WithEF(100))
+		if err != nil || len(results) == 0 || results[0].Document.ID != id {
+			t.Fatalf("document %s not found by its own vector: %v, %v", id, results, err)
+		}
+	}
+}
+
+func TestSaveCrashRecovery(t *testing.T) {
+	steps := []string{"begin", "documents", "index", "mappings", "set", "manifest"}
+	for _, step := range steps {
+		t.Run(step, func(t *testing.T) {
+			dir := t.TempDir()
+			rng := rand.New(rand.NewSource(1))
+
+			coll, err := NewCollection("docs", dir, barrierConfig(false))
+			if err != nil {
+				t.Fatalf("NewCollection failed: %v", err)
+			}
+			if err := coll.InsertBatch(barrierDocs(rng, 0, 100)); err != nil {
+				t.Fatalf("InsertBatch failed: %v", err)
+			}
+			if err := coll.Save(); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			saved := contentHash(t, coll)
+
+			mutate(t, coll, rng, 100, 150)
+			unsaved := contentHash(t, coll)
+
+			// The process stops after step; the collection is abandoned
+			crashAfter(t, step)
+			if err := coll.Save(); !errors.Is(err, errCrash) {
+				t.Fatalf("Save: err = %v, want the injected crash", err)
+			}
+			afterSaveStep = nil
+
+			// Only the manifest publishes the new save
+			want := saved
+			if step == "manifest" {
+				want = unsaved
+			}
+
+			replica, err := NewCollection("docs", dir, barrierConfig(true))
+			if err != nil {
+				t.Fatalf("NewCollection (read-only) failed: %v", err)
+			}
+			if got := contentHash(t, replica); got != want {
+				t.Errorf("replica does not hold exactly one published save")
+			}
+			checkIndexMatches(t, replica)
+			replica.Close()
+
+			reopened, err := NewCollection("docs", dir, barrierConfig(false))
+			if err != nil {
+				t.Fatalf("NewCollection failed: %v", err)
+			}
+			if got := contentHash(t, reopened); got != want {
+				t.Errorf("reopened collection does not hold exactly one published save")
+			}
+			if reopened.LoadReport().IndexRebuilt {
+				t.Errorf("index was rebuilt instead of restored")
+			}
+			checkIndexMatches(t, reopened)
+
+			// The restored collection saves and reopens normally
+			if err := reopened.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			again, err := NewCollection("docs", dir, barrierConfig(false))
+			if err != nil {
+				t.Fatalf("NewCollection failed: %v", err)
+			}
+			defer again.Close()
+			if got := contentHash(t, again); got != want {
+				t.Errorf("content changed across a clean reopen")
+			}
+		})
+	}
+}
+
+func TestSaveCrashFallsBackToPreviousSet(t *testing.T) {
+	dir := t.TempDir()
+	rng := rand.New(rand.NewSource(1))
+
+	coll, err := NewCollection("docs", dir, barrierConfig(false))
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	if err := coll.InsertBatch(barrierDocs(rng, 0, 100)); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	first := contentHash(t, coll)
+
+	mutate(t, coll, rng, 100, 150)
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	mutate(t, coll, rng, 150, 200)
+	crashAfter(t, "mappings")
+	if err := coll.Save(); !errors.Is(err, errCrash) {
+		t.Fatalf("Save: err = %v, want the injected crash", err)
+	}
+
+	// The published save's set is lost as well
+	if err := os.RemoveAll(saveSetDir(dir, 2)); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+
+	reopened, err := NewCollection("docs", dir, barrierConfig(false))
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	defer reopened.Close()
+	if got := contentHash(t, reopened); got != first {
+		t.Errorf("reopened collection does not hold the previous complete save")
+	}
+	checkIndexMatches(t, reopened)
+
+	info, err := readGeneration(dir)
+	if err != nil || info != (generationInfo{Generation: 1, Checkpoint: 1}) {
+		t.Errorf("manifest = %+v, %v; want it to point at save 1", info, err)
+	}
+}
+
+func TestSaveKeepsTwoSets(t *testing.T) {
+	dir := t.TempDir()
+	coll, err := NewCollection("docs", dir, barrierConfig(false))
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	defer coll.Close()
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 4; i++ {
+		if err := coll.InsertBatch(barrierDocs(rng, i*10, i*10+10)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	ids, err := listSaveSets(dir)
+	if err != nil || len(ids) != 2 || ids[0] != 3 || ids[1] != 4 {
+		t.Errorf("save sets = %v, %v; want [3 4]", ids, err)
+	}
+	if !setMatches(dir, 4) || !setMatches(saveSetDir(dir, 4), 4) || !setMatches(saveSetDir(dir, 3), 3) {
+		t.Errorf("stamps do not match their saves")
+	}
+	if _, err := os.Stat(filepath.Join(dir, setsDirName, fmt.Sprintf("%020d.tmp", 4))); !os.IsNotExist(err) {
+		t.Errorf("temporary set left behind: %v", err)
+	}
+}
@@ -1,11 +1,16 @@
 package vego
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
 )
 
 // setupBenchmarkCollection creates a collection for benchmarking
@@ -210,6 +215,73 @@ func BenchmarkSearchWithFilter(b *testing.B) {
 	}
 }
 
+// BenchmarkSearchIDs compares ID-only search against full document
+// hydration on 100K documents carrying heavyweight metadata
+func BenchmarkSearchIDs(b *testing.B) {
+	const size, dimension = 100000, 128
+
+	coll, cleanup := setupBenchmarkCollection(b, dimension)
+	defer cleanup()
+
+	b.Logf("Preparing %d documents...", size)
+	start := time.Now()
+
+	payload := strings.Repeat("x", 2048)
+	batchSize := 1000
+	for batch := 0; batch < size/batchSize; batch++ {
+		docs := make([]*Document, batchSize)
+		for i := 0; i < batchSize; i++ {
+			n := batch*batchSize + i
+			category := "A"
+			if n%2 == 0 {
+				category = "B"
+			}
+			docs[i] = &Document{
+				ID:     fmt.Sprintf("ids_doc_%d", n),
+				Vector: generateRandomVector(dimension, n),
+				Metadata: map[string]interface{}{
+					"category": category,
+					"body":     payload,
+					"index":    n,
+				},
+			}
+		}
+		if err := coll.InsertBatch(docs); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Logf("Prepared %d documents in %v", size, time.Since(start))
+
+	ctx := context.Background()
+	query := generateRandomVector(dimension, 999999)
+	filter := &MetadataFilter{Field: "category", Operator: "eq", Value: "A"}
+
+	b.Run("SearchWithFilter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := coll.SearchWithFilter(query, 10, filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("SearchIDsWithFilter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := coll.SearchIDs(ctx, query, 10, filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("SearchIDs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := coll.SearchIDs(ctx, query, 10, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 // BenchmarkSearchBatch benchmarks batch search
 func BenchmarkSearchBatch(b *testing.B) {
 	coll, cleanup := setupBenchmarkCollection(b, 128)
@@ -364,6 +436,50 @@ func BenchmarkGetBatch(b *testing.B) {
 	}
 }
 
+// BenchmarkDistancesTo compares scoring 1000 candidates with DistancesTo
+// against a Get and a distance call per candidate
+func BenchmarkDistancesTo(b *testing.B) {
+	const k = 1000
+	coll, cleanup := setupBenchmarkCollection(b, 128)
+	defer cleanup()
+
+	docs := make([]*Document, k)
+	ids := make([]string, k)
+	for i := range docs {
+		ids[i] = fmt.Sprintf("dist_doc_%d", i)
+		docs[i] = &Document{ID: ids[i], Vector: generateRandomVector(128, i)}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		b.Fatal(err)
+	}
+	if err := coll.Save(); err != nil {
+		b.Fatal(err)
+	}
+	query := generateRandomVector(128, k)
+	ctx := context.Background()
+
+	b.Run("DistancesTo", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := coll.DistancesTo(ctx, query, ids); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("GetEach", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			distances := make([]float32, k)
+			for j, id := range ids {
+				doc, err := coll.Get(id)
+				if err != nil {
+					b.Fatal(err)
+				}
+				distances[j] = hnsw.L2Distance(query, doc.Vector)
+			}
+		}
+	})
+}
+
 // ==================== 更新和删除性能基准测试 ====================
 
 // BenchmarkUpdate benchmarks document update
@@ -474,6 +590,63 @@ func BenchmarkMixedWorkload(b *testing.B) {
 	}
 }
 
+// BenchmarkSearchDuringInsertBatch measures search tail latency while a
+// large InsertBatch is building the graph in the background
+func BenchmarkSearchDuringInsertBatch(b *testing.B) {
+	coll, cleanup := setupBenchmarkCollection(b, 128)
+	defer cleanup()
+
+	for i := 0; i < 500; i++ {
+		coll.Insert(&Document{
+			ID:     fmt.Sprintf("base_doc_%d", i),
+			Vector: generateRandomVector(128, i),
+		})
+	}
+
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for batch := 0; ; batch++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			docs := make([]*Document, 200)
+			for i := range docs {
+				docs[i] = &Document{
+					ID:     fmt.Sprintf("bulk_doc_%d_%d", batch, i),
+					Vector: generateRandomVector(128, batch*200+i),
+				}
+			}
+			if err := coll.InsertBatch(docs); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+
+	query := generateRandomVector(128, 4242)
+	latencies := make([]time.Duration, 0, b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if _, err := coll.Search(query, 10); err != nil {
+			b.Fatal(err)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	b.StopTimer()
+
+	close(stop)
+	<-writerDone
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+}
+
 // BenchmarkCollectionMemoryUsage benchmarks memory usage at different scales
 func BenchmarkCollectionMemoryUsage(b *testing.B) {
 	sizes := []int{1000, 5000, 10000}
@@ -543,3 +716,33 @@ func BenchmarkSave(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkOpenInMemory benchmarks opening an in-memory database with one
+// collection, compared with a database on disk
+func BenchmarkOpenInMemory(b *testing.B) {
+	b.Run("memory", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			db, err := OpenInMemory(WithDimension(128))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := db.Collection("bench"); err != nil {
+				b.Fatal(err)
+			}
+			db.Close()
+		}
+	})
+	b.Run("disk", func(b *testing.B) {
+		dir := b.TempDir()
+		for i := 0; i < b.N; i++ {
+			db, err := Open(filepath.Join(dir, fmt.Sprint(i)), WithDimension(128))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := db.Collection("bench"); err != nil {
+				b.Fatal(err)
+			}
+			db.Close()
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package vego
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultBoostCandidates is the BoostExpr.CandidateMultiplier used when it
+// is 0
+const defaultBoostCandidates = 4
+
+// BoostMode is how a boost term enters the score
+type BoostMode int
+
+const (
+	// BoostAdd adds the term to the score
+	BoostAdd BoostMode = iota
+	// BoostMultiply multiplies the similarity by 1 + the term
+	BoostMultiply
+)
+
+// BoostExpr adjusts the ranking of search results by their stored
+// metadata, see WithBoost. Every term is computed from one field; a
+// document without the field, or whose value does not convert, gets a term
+// of 0 and so is neither boosted nor penalised by it.
+type BoostExpr struct {
+	Recency []RecencyBoost
+	Values  []ValueBoost
+	Linear  []LinearBoost
+
+	// CandidateMultiplier is how many times k nearest documents are scored,
+	// so that boosts can promote documents from beyond the raw top k
+	// (0 = 4)
+	CandidateMultiplier int
+}
+
+// RecencyBoost decays exponentially with the age of the time in Field, as
+// read by Document.GetTime: Weight for a document of age 0, Weight/2 one
+// HalfLife later. Times in the future count as age 0.
+type RecencyBoost struct {
+	Field    string
+	HalfLife time.Duration
+	Weight   float64
+	Mode     BoostMode
+	Now      time.Time // Time ages are measured at (zero = time of the search)
+}
+
+// ValueBoost is the weight of the value of Field in Weights, or 0 if it is
+// not there. Values that are not strings are looked up as formatted by
+// fmt.Sprint, so 2024 matches "2024".
+type ValueBoost struct {
+	Field   string
+	Weights map[string]float64
+	Mode    BoostMode
+}
+
+// LinearBoost is Coefficient times the number in Field
+type LinearBoost struct {
+	Field       string
+	Coefficient float64
+	Mode        BoostMode
+}
+
+// WithBoost ranks results by a Score combining vector similarity with
+// metadata:
+//
+//	Score = similarity × Π(1 + multiplicative terms) + Σ additive terms
+//
+// where similarity is 1/(1+Distance), or 1-Distance for the negative
+// distances of inner product, so it is positive and falls as Distance
+// grows. The CandidateMultiplier×k nearest documents are scored and the k
+// with the highest Score returned, highest first, each with the formula
+// spelled out in Explain. Distance keeps the unmodified metric value.
+// Boosts only see stored metadata, as filters do.
+//
+// SearchContext, SearchFiltered, SearchShards and SearchSimilarTo apply it;
+// the other searches ignore it.
+func WithBoost(expr BoostExpr) SearchOption {
+	return func(o *SearchOptions) {
+		o.Boost = &expr
+	}
+}
+
+// validate checks the parameters of expr
+func (expr *BoostExpr) validate() error {
+	if expr.CandidateMultiplier < 0 {
+		return fmt.Errorf("%w: boost candidate multiplier %d is negative", ErrValidationFailed, expr.CandidateMultiplier)
+	}
+	for _, r := range expr.Recency {
+		if r.HalfLife <= 0 {
+			return fmt.Errorf("%w: recency boost of %q needs a positive half-life", ErrValidationFailed, r.Field)
+		}
+	}
+	return nil
+}
+
+// boostCandidates returns how many hits a search for k fetches: k, or k
+// times the candidate multiplier when boosting
+func boostCandidates(k int, options *SearchOptions) int {
+	if options.Boost == nil {
+		return k
+	}
+	multiplier := options.Boost.CandidateMultiplier
+	if multiplier == 0 {
+		multiplier = defaultBoostCandidates
+	}
+	return k * multiplier
+}
+
+// checkBoost validates the boost of options, if any
+func (c *Collection) checkBoost(op string, options *SearchOptions) error {
+	if options.Boost == nil {
+		return nil
+	}
+	if err := options.Boost.validate(); err != nil {
+		return wrapError(op, c.name, "", err)
+	}
+	return nil
+}
+
+// boost scores results with options.Boost and returns the k best, highest
+// Score first. Without a boost it returns results unchanged.
+func boost(results []SearchResult, k int, options *SearchOptions) []SearchResult {
+	expr := options.Boost
+	if expr == nil {
+		return results
+	}
+
+	now := time.Now()
+	for i := range results {
+		results[i].Score, results[i].Explain = expr.score(results[i].Document, results[i].Distance, now)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		return a.Document.ID < b.Document.ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// similarity maps a distance to a positive number that falls as it grows
+func similarity(distance float32) float64 {
+	d := float64(distance)
+	if d < 0 {
+		return 1 - d
+	}
+	return 1 / (1 + d)
+}
+
+// score returns the Score of doc at distance from the query and the
+// formula it was computed with
+func (expr *BoostExpr) score(doc *Document, distance float32, now time.Time) (float32, string) {
+	sim := similarity(distance)
+	factor := 1.0
+	var added float64
+	var multiplied, additions []string
+	apply := func(mode BoostMode, term float64, label string) {
+		if mode == BoostMultiply {
+			factor *= 1 + term
+			multiplied = append(multiplied, fmt.Sprintf(" × (1 + %.4g %s)", term, label))
+		} else {
+			added += term
+			additions = append(additions, fmt.Sprintf(" + %.4g %s", term, label))
+		}
+	}
+
+	for _, r := range expr.Recency {
+		term, label := 0.0, fmt.Sprintf("[recency %s missing]", r.Field)
+		if t, ok := doc.GetTime(r.Field); ok {
+			at := r.Now
+			if at.IsZero() {
+				at = now
+			}
+			age := max(at.Sub(t), 0)
+			term = r.Weight * math.Exp2(-float64(age)/float64(r.HalfLife))
+			label = fmt.Sprintf("[recency %s, age %s, half-life %s]", r.Field, age.Round(time.Second), r.HalfLife)
+		}
+		apply(r.Mode, term, label)
+	}
+	for _, v := range expr.Values {
+		term, label := 0.0, fmt.Sprintf("[%s missing]", v.Field)
+		if value, ok := doc.Metadata[v.Field]; ok && value != nil {
+			s, ok := value.(string)
+			if !ok {
+				s = fmt.Sprint(value)
+			}
+			term, label = v.Weights[s], fmt.Sprintf("[%s=%s]", v.Field, s)
+		}
+		apply(v.Mode, term, label)
+	}
+	for _, l := range expr.Linear {
+		term, label := 0.0, fmt.Sprintf("[%s missing]", l.Field)
+		if x, ok := doc.GetFloat(l.Field); ok {
+			term, label = l.Coefficient*x, fmt.Sprintf("[%g × %s=%g]", l.Coefficient, l.Field, x)
+		}
+		apply(l.Mode, term, label)
+	}
+
+	score := sim*factor + added
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "score %.4g = similarity %.4g [distance %.4g]", score, sim, distance)
+	for _, m := range multiplied {
+		sb.WriteString(m)
+	}
+	for _, a := range additions {
+		sb.WriteString(a)
+	}
+	return float32(score), sb.String()
+}
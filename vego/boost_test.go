@@ -0,0 +1,122 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchBoost(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	db, err := OpenInMemory(WithDimension(2))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer db.Close()
+	coll, _ := db.Collection("docs")
+
+	// Ordered by distance from the origin: old, recent, spam, news, popular, bare
+	docs := []*Document{
+		{ID: "old", Vector: []float32{0.10, 0}, Metadata: map[string]interface{}{"published": now.Add(-730 * day).Format(time.RFC3339)}},
+		{ID: "recent", Vector: []float32{0.20, 0}, Metadata: map[string]interface{}{"published": now.Add(-day).Format(time.RFC3339)}},
+		{ID: "spam", Vector: []float32{0.30, 0}, Metadata: map[string]interface{}{"category": "spam"}},
+		{ID: "news", Vector: []float32{0.40, 0}, Metadata: map[string]interface{}{"category": "news"}},
+		{ID: "popular", Vector: []float32{0.50, 0}, Metadata: map[string]interface{}{"views": 900}},
+		{ID: "bare", Vector: []float32{0.60, 0}},
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	ctx := context.Background()
+	query := []float32{0, 0}
+	plain, err := coll.SearchContext(ctx, query, len(docs))
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	if got := fmt.Sprint(resultIDs(plain)); got != "[old recent spam news popular bare]" {
+		t.Fatalf("unboosted order %s", got)
+	}
+	distances := make(map[string]float32)
+	for _, r := range plain {
+		distances[r.Document.ID] = r.Distance
+	}
+
+	recency := RecencyBoost{Field: "published", HalfLife: 30 * day, Weight: 1, Mode: BoostMultiply, Now: now}
+	category := ValueBoost{Field: "category", Weights: map[string]float64{"news": 0.5, "spam": -0.5}}
+	views := LinearBoost{Field: "views", Coefficient: 0.001}
+	cases := []struct {
+		name string
+		expr BoostExpr
+		k    int
+		want string
+	}{
+		{"recency", BoostExpr{Recency: []RecencyBoost{recency}}, 2, "[recent old]"},
+		{"category", BoostExpr{Values: []ValueBoost{category}}, 3, "[news old recent]"},
+		{"linear", BoostExpr{Linear: []LinearBoost{views}}, 2, "[popular old]"},
+		{"combined", BoostExpr{Recency: []RecencyBoost{recency}, Values: []ValueBoost{category}, Linear: []LinearBoost{views}}, 4,
+			"[recent popular news old]"},
+		// Only the nearest candidate is scored, so nothing can overtake it
+		{"no candidates", BoostExpr{Linear: []LinearBoost{views}, CandidateMultiplier: 1}, 1, "[old]"},
+	}
+	for _, tc := range cases {
+		results, err := coll.SearchContext(ctx, query, tc.k, WithBoost(tc.expr))
+		if err != nil {
+			t.Fatalf("%s: SearchContext failed: %v", tc.name, err)
+		}
+		if got := fmt.Sprint(resultIDs(results)); got != tc.want {
+			t.Errorf("%s: order %s, want %s", tc.name, got, tc.want)
+		}
+		for i, r := range results {
+			if r.Distance != distances[r.Document.ID] {
+				t.Errorf("%s: %s has distance %v, unboosted %v", tc.name, r.Document.ID, r.Distance, distances[r.Document.ID])
+			}
+			if i > 0 && r.Score > results[i-1].Score {
+				t.Errorf("%s: scores not descending at %d", tc.name, i)
+			}
+			if !strings.HasPrefix(r.Explain, "score ") {
+				t.Errorf("%s: %s explained as %q", tc.name, r.Document.ID, r.Explain)
+			}
+		}
+	}
+
+	// A document without the boosted fields scores its similarity alone
+	all := BoostExpr{Recency: []RecencyBoost{recency}, Values: []ValueBoost{category}, Linear: []LinearBoost{views}}
+	results, err := coll.SearchContext(ctx, query, len(docs), WithBoost(all))
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Document.ID != "bare" {
+			continue
+		}
+		if want := float32(similarity(r.Distance)); r.Score != want {
+			t.Errorf("bare scored %v, want %v", r.Score, want)
+		}
+		if !strings.Contains(r.Explain, "[views missing]") || !strings.Contains(r.Explain, "[recency published missing]") {
+			t.Errorf("bare explained as %q", r.Explain)
+		}
+	}
+
+	// The other searches that take options boost too
+	expr := WithBoost(BoostExpr{Recency: []RecencyBoost{recency}})
+	filtered, err := coll.SearchFiltered(ctx, query, 1, &MetadataFilter{Field: "published", Operator: "ne", Value: ""}, expr)
+	if err != nil || len(filtered.Results) != 1 || filtered.Results[0].Document.ID != "recent" {
+		t.Errorf("SearchFiltered = %+v, %v", filtered, err)
+	}
+	similar, err := coll.SearchSimilarTo(ctx, "spam", 1, nil, expr)
+	if err != nil || len(similar) != 1 || similar[0].Document.ID != "recent" {
+		t.Errorf("SearchSimilarTo = %v, %v", resultIDs(similar), err)
+	}
+
+	// Unboosted results carry no score
+	if plain[0].Score != 0 || plain[0].Explain != "" {
+		t.Errorf("unboosted result %+v", plain[0])
+	}
+	bad := WithBoost(BoostExpr{Recency: []RecencyBoost{{Field: "published", Weight: 1}}})
+	if _, err := coll.SearchContext(ctx, query, 1, bad); !IsValidationFailed(err) {
+		t.Errorf("boost without half-life = %v, want ErrValidationFailed", err)
+	}
+}
@@ -0,0 +1,548 @@
+package vego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+const (
+	// checkpointsDirName holds one directory per retained checkpoint
+	checkpointsDirName = "checkpoints"
+
+	// checkpointFileName describes a checkpoint; it is written last, so a
+	// directory without it is an incomplete checkpoint and is ignored
+	checkpointFileName = "checkpoint.json"
+)
+
+// checkpointEntries are the files and directories of a save that make up a
+// checkpoint, relative to the collection directory
+var checkpointEntries = []string{settingsFileName, generationFileName, "mappings.json", "index", "documents"}
+
+// Checkpoint describes a retained save of a collection
+type Checkpoint struct {
+	ID        uint64    `json:"id"`             // Save generation
+	Time      time.Time `json:"time"`           // When the save completed
+	Size      int64     `json:"size"`           // Bytes of saved files; unchanged files are shared with the live collection
+	Documents int       `json:"documents"`      // Documents in the checkpoint
+	Tags      []string  `json:"tags,omitempty"` // Names given by TagCheckpoint
+}
+
+// HasTag reports whether the checkpoint is tagged tag
+func (cp Checkpoint) HasTag(tag string) bool {
+	for _, t := range cp.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkpointDir returns the directory of checkpoint id under the collection
+// directory dir
+func checkpointDir(dir string, id uint64) string {
+	return filepath.Join(dir, checkpointsDirName, fmt.Sprintf("%020d", id))
+}
+
+// Checkpoints lists the retained checkpoints of the collection, oldest first.
+// Checkpoints are only kept when the database is opened with
+// WithCheckpointRetention.
+func (c *Collection) Checkpoints() ([]Checkpoint, error) {
+	_, done, err := c.begin(context.Background(), "Checkpoints")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if c.config.InMemory {
+		return nil, wrapError("Checkpoints", c.name, "", errNoFiles("checkpoints"))
+	}
+	checkpoints, err := listCheckpoints(c.path)
+	if err != nil {
+		return nil, wrapError("Checkpoints", c.name, "", err)
+	}
+	return checkpoints, nil
+}
+
+// listCheckpoints reads the complete checkpoints of the collection directory
+// dir, oldest first
+func listCheckpoints(dir string) ([]Checkpoint, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, checkpointsDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []Checkpoint
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, checkpointsDirName, entry.Name(), checkpointFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal(data, &cp); err != nil {
+			return nil, fmt.Errorf("%w: parse checkpoint %s: %v", ErrStorageCorrupted, entry.Name(), err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].ID < checkpoints[j].ID })
+	return checkpoints, nil
+}
+
+// checkpoint records the save that just completed as checkpoint id and
+// prunes checkpoints past the configured retention. Saved files are
+// hard-linked, not copied: every save replaces its files rather than
+// rewriting them, so a link keeps the checkpoint's content. c.mu must be held.
+func (c *Collection) checkpoint(id uint64) error {
+	dir := checkpointDir(c.path, id)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	var size int64
+	for _, name := range checkpointEntries {
+		n, err := linkTree(filepath.Join(c.path, name), filepath.Join(dir, name))
+		if err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+		size += n
+	}
+
+	cp := Checkpoint{ID: id, Time: time.Now(), Size: size, Documents: len(c.docToNode)}
+	if err := writeCheckpoint(c.path, cp); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	return c.pruneCheckpoints()
+}
+
+// writeCheckpoint atomically replaces the description of checkpoint cp of
+// the collection directory dir
+func writeCheckpoint(dir string, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return lanceio.WriteFileAtomic(filepath.Join(checkpointDir(dir, cp.ID), checkpointFileName), data, 0644)
+}
+
+// pruneCheckpoints removes untagged checkpoints beyond CheckpointRetention
+// and, except for the newest, those older than CheckpointMaxAge. Tagged
+// checkpoints are kept until their tags are deleted and do not count towards
+// the retention.
+func (c *Collection) pruneCheckpoints() error {
+	checkpoints, err := listCheckpoints(c.path)
+	if err != nil || len(checkpoints) == 0 {
+		return err
+	}
+
+	newestID := checkpoints[len(checkpoints)-1].ID
+	var untagged []Checkpoint
+	for _, cp := range checkpoints {
+		if len(cp.Tags) == 0 {
+			untagged = append(untagged, cp)
+		}
+	}
+
+	keep := max(c.config.CheckpointRetention, 0)
+	for i, cp := range untagged {
+		newest := cp.ID == newestID
+		expired := c.config.CheckpointMaxAge > 0 && time.Since(cp.Time) > c.config.CheckpointMaxAge
+		if i < len(untagged)-keep || (expired && !newest) {
+			if err := os.RemoveAll(checkpointDir(c.path, cp.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// linkTree hard-links the file or directory tree src to dst and returns the
+// total size of the files. Missing sources and temporary files are skipped.
+// Files are copied where hard links are not supported.
+func linkTree(src, dst string) (int64, error) {
+	info, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !info.IsDir() {
+		if strings.Contains(filepath.Base(src), ".tmp") {
+			return 0, nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return 0, err
+		}
+		if err := os.Link(src, dst); err != nil {
+			if err := copyFile(src, dst); err != nil {
+				return 0, err
+			}
+		}
+		return info.Size(), nil
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, entry := range entries {
+		n, err := linkTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()))
+		if err != nil {
+			return 0, err
+		}
+		size += n
+	}
+	return size, nil
+}
+
+// copyFile copies src to a new file dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// CheckpointSelector picks one of the retained checkpoints of a collection,
+// given oldest first, for DB.CollectionAt
+type CheckpointSelector func(checkpoints []Checkpoint) (Checkpoint, bool)
+
+// AtTime selects the newest checkpoint saved at or before at
+func AtTime(at time.Time) CheckpointSelector {
+	return func(checkpoints []Checkpoint) (Checkpoint, bool) {
+		for i := len(checkpoints) - 1; i >= 0; i-- {
+			if !checkpoints[i].Time.After(at) {
+				return checkpoints[i], true
+			}
+		}
+		return Checkpoint{}, false
+	}
+}
+
+// AtCheckpoint selects checkpoint id
+func AtCheckpoint(id uint64) CheckpointSelector {
+	return func(checkpoints []Checkpoint) (Checkpoint, bool) {
+		for _, cp := range checkpoints {
+			if cp.ID == id {
+				return cp, true
+			}
+		}
+		return Checkpoint{}, false
+	}
+}
+
+// AtTag selects the checkpoint tagged tag, see Collection.TagCheckpoint
+func AtTag(tag string) CheckpointSelector {
+	return func(checkpoints []Checkpoint) (Checkpoint, bool) {
+		for _, cp := range checkpoints {
+			if cp.HasTag(tag) {
+				return cp, true
+			}
+		}
+		return Checkpoint{}, false
+	}
+}
+
+// CollectionAt opens the collection as of the retained checkpoint at selects,
+// such as AtTime(t) or AtTag("release-2024-06"). The returned collection is
+// read-only and independent of the live one: it reads the checkpoint's files
+// in place, is not listed by Collections, and must be closed by the caller.
+// Once the checkpoint is pruned by a later save, reading documents from a
+// view still open on it fails.
+func (db *DB) CollectionAt(name string, at CheckpointSelector) (*Collection, error) {
+	return db.collectionAt(name, at)
+}
+
+// CollectionAtCheckpoint opens the collection as of checkpoint id, as
+// CollectionAt(name, AtCheckpoint(id)) does
+func (db *DB) CollectionAtCheckpoint(name string, id uint64) (*Collection, error) {
+	return db.collectionAt(name, AtCheckpoint(id))
+}
+
+// collectionAt opens the checkpoint of collection name chosen by pick
+func (db *DB) collectionAt(name string, pick CheckpointSelector) (*Collection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+	if db.config.InMemory {
+		return nil, wrapError("CollectionAt", name, "", errNoFiles("checkpoints"))
+	}
+
+	collPath := filepath.Join(db.path, name)
+	if _, err := os.Stat(collPath); err != nil {
+		return nil, wrapError("CollectionAt", name, "", ErrCollectionNotFound)
+	}
+	checkpoints, err := listCheckpoints(collPath)
+	if err != nil {
+		return nil, wrapError("CollectionAt", name, "", err)
+	}
+	cp, ok := pick(checkpoints)
+	if !ok {
+		return nil, wrapError("CollectionAt", name, "", ErrCheckpointNotFound)
+	}
+
+	config := *db.config
+	config.ReadOnly = true
+	config.AutoRefreshInterval = 0
+	config.OrphanSweepInterval = 0
+	config.AutoOptimizeInterval = 0
+	config.CheckpointRetention = 0
+
+	return NewCollectionContext(context.Background(), name, checkpointDir(collPath, cp.ID), &config)
+}
+
+// TagCheckpoint names the most recent checkpoint tag, so that it can be
+// opened with AtTag and restored with RollbackTo. Changes made since the
+// last Save are not part of it; save first to tag them. Tags are unique per
+// collection: ErrTagExists is returned if another checkpoint has tag.
+// Tagged checkpoints are never pruned by retention.
+func (c *Collection) TagCheckpoint(tag string) error {
+	_, done, err := c.beginWrite(context.Background(), "TagCheckpoint")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if c.config.InMemory {
+		return wrapError("TagCheckpoint", c.name, "", errNoFiles("checkpoints"))
+	}
+	if strings.TrimSpace(tag) == "" {
+		return wrapError("TagCheckpoint", c.name, "", fmt.Errorf("%w: checkpoint tag is empty", ErrValidationFailed))
+	}
+
+	// Saves create and prune checkpoints under the write lock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoints, err := listCheckpoints(c.path)
+	if err != nil {
+		return wrapError("TagCheckpoint", c.name, "", err)
+	}
+	if len(checkpoints) == 0 {
+		return wrapError("TagCheckpoint", c.name, "", ErrCheckpointNotFound)
+	}
+	if cp, ok := AtTag(tag)(checkpoints); ok {
+		return wrapError("TagCheckpoint", c.name, "", fmt.Errorf("%w: %q is on checkpoint %d", ErrTagExists, tag, cp.ID))
+	}
+
+	cp := checkpoints[len(checkpoints)-1]
+	cp.Tags = append(cp.Tags, tag)
+	if err := writeCheckpoint(c.path, cp); err != nil {
+		return wrapError("TagCheckpoint", c.name, "", err)
+	}
+	return nil
+}
+
+// DeleteTag removes tag from the checkpoint that has it. The checkpoint
+// itself is kept; once it has no tags left, later saves prune it like any
+// other.
+func (c *Collection) DeleteTag(tag string) error {
+	_, done, err := c.beginWrite(context.Background(), "DeleteTag")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if c.config.InMemory {
+		return wrapError("DeleteTag", c.name, "", errNoFiles("checkpoints"))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoints, err := listCheckpoints(c.path)
+	if err != nil {
+		return wrapError("DeleteTag", c.name, "", err)
+	}
+	cp, ok := AtTag(tag)(checkpoints)
+	if !ok {
+		return wrapError("DeleteTag", c.name, "", ErrCheckpointNotFound)
+	}
+
+	tags := make([]string, 0, len(cp.Tags)-1)
+	for _, t := range cp.Tags {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+	cp.Tags = tags
+	if err := writeCheckpoint(c.path, cp); err != nil {
+		return wrapError("DeleteTag", c.name, "", err)
+	}
+	return nil
+}
+
+// RollbackTo makes the checkpoint tagged tag the live state of the
+// collection. The current state is saved first, which keeps it as a
+// checkpoint, so a rollback can itself be undone from there. The documents,
+// index and mappings of the tagged checkpoint are then loaded and saved as a
+// new generation, which replicas pick up on their next Refresh; the storage
+// settings stay those of the live collection.
+//
+// Rollback needs checkpoints to be retained, see WithCheckpointRetention,
+// and is refused while a batch insert is in progress.
+func (c *Collection) RollbackTo(ctx context.Context, tag string) error {
+	ctx, done, err := c.beginWrite(ctx, "RollbackTo")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if c.config.InMemory {
+		return wrapError("RollbackTo", c.name, "", errNoFiles("checkpoints"))
+	}
+	if c.config.CheckpointRetention <= 0 {
+		return wrapError("RollbackTo", c.name, "", fmt.Errorf("%w: rollback without checkpoint retention", ErrNotSupported))
+	}
+
+	c.lockWrites()
+	defer c.unlockWrites()
+
+	if len(c.pending) > 0 {
+		return wrapError("RollbackTo", c.name, "", fmt.Errorf("rollback while an insert is in progress"))
+	}
+	checkpoints, err := listCheckpoints(c.path)
+	if err != nil {
+		return wrapError("RollbackTo", c.name, "", err)
+	}
+	cp, ok := AtTag(tag)(checkpoints)
+	if !ok {
+		return wrapError("RollbackTo", c.name, "", ErrCheckpointNotFound)
+	}
+
+	// Keep the current state; save only logs a failed checkpoint, so check
+	// it is there before replacing anything
+	if err := c.saveLocked(); err != nil {
+		return err
+	}
+	checkpoints, err = listCheckpoints(c.path)
+	if err != nil {
+		return wrapError("RollbackTo", c.name, "", err)
+	}
+	if _, ok := AtCheckpoint(c.generation)(checkpoints); !ok {
+		return wrapError("RollbackTo", c.name, "", fmt.Errorf("checkpoint of the current state was not kept"))
+	}
+
+	if err := c.restoreCheckpoint(ctx, cp); err != nil {
+		// Put back the files of the save above, as opening after a crash
+		// here would
+		if rerr := restoreSaveSet(c.path); rerr != nil {
+			log.Printf("Warning: failed to restore collection %s after a failed rollback: %v", c.name, rerr)
+		}
+		return wrapError("RollbackTo", c.name, "", err)
+	}
+	return c.saveLocked()
+}
+
+// restoreCheckpoint replaces the documents, index and mappings of the
+// collection, on disk and in memory, with those of checkpoint cp. The next
+// generation is marked as saving first, so replicas and a reopen after a
+// crash keep the published save until saveLocked publishes the restored
+// one. c.mu must be held.
+func (c *Collection) restoreCheckpoint(ctx context.Context, cp Checkpoint) error {
+	info := generationInfo{Generation: c.generation + 1, Saving: true, Checkpoint: c.published}
+	if err := writeGeneration(c.path, info); err != nil {
+		return err
+	}
+
+	src := checkpointDir(c.path, cp.ID)
+	for _, name := range setEntries {
+		if err := os.RemoveAll(filepath.Join(c.path, name)); err != nil {
+			return err
+		}
+		if _, err := linkTree(filepath.Join(src, name), filepath.Join(c.path, name)); err != nil {
+			return err
+		}
+	}
+
+	index, err := newIndex(c.config)
+	if err != nil {
+		return err
+	}
+	storage, err := NewDocumentStorageWithFactory(filepath.Join(c.path, "documents"), c.dimension, c.factory)
+	if err != nil {
+		return err
+	}
+	restored := &Collection{
+		name:      c.name,
+		path:      c.path,
+		dimension: c.dimension,
+		index:     index,
+		storage:   storage,
+		docToNode: make(map[string]int),
+		nodeToDoc: make(map[int]string),
+		info:      make(map[string]string),
+		orphans:   make(map[int]struct{}),
+		config:    c.config,
+		settings:  c.settings,
+		factory:   c.factory,
+		dataDir:   c.path,
+	}
+	if c.text != nil {
+		restored.text = newTextIndex(c.text.field)
+	}
+	restored.metaIndexes = c.metaIndexes.fresh()
+	if err := restored.load(ctx); err != nil && !os.IsNotExist(err) {
+		restored.index.Close()
+		restored.storage.Close()
+		return err
+	}
+
+	oldIndex, oldStorage := c.index, c.storage
+	c.index = restored.index
+	c.storage = restored.storage
+	c.docToNode = restored.docToNode
+	c.nodeToDoc = restored.nodeToDoc
+	c.orphans = restored.orphans
+	c.text = restored.text
+	c.metaIndexes = restored.metaIndexes
+	c.info = restored.info
+	c.loadReport = restored.loadReport
+
+	// Everything was flushed by the save before the rollback
+	if err := oldIndex.Close(); err != nil {
+		log.Printf("Warning: failed to close previous index of collection %s: %v", c.name, err)
+	}
+	if err := oldStorage.Close(); err != nil {
+		log.Printf("Warning: failed to close previous storage of collection %s: %v", c.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkpointDocs returns n documents with random vectors and IDs prefixed by
+// prefix
+func checkpointDocs(prefix string, n int, rng *rand.Rand) []*Document {
+	docs := make([]*Document, n)
+	for i := range docs {
+		docs[i] = &Document{ID: fmt.Sprintf("%s_%03d", prefix, i), Vector: make([]float32, 16)}
+		for j := range docs[i].Vector {
+			docs[i].Vector[j] = rng.Float32()
+		}
+	}
+	return docs
+}
+
+// assertSnapshot checks that coll holds exactly the documents in want and
+// that searching for each of them finds it
+func assertSnapshot(t *testing.T, label string, coll *Collection, want []*Document) {
+	t.Helper()
+	if got := coll.Count(); got != len(want) {
+		t.Errorf("%s: Count = %d, want %d", label, got, len(want))
+	}
+	for _, doc := range want {
+		results, err := coll.Search(doc.Vector, 1)
+		if err != nil {
+			t.Errorf("%s: Search failed: %v", label, err)
+			continue
+		}
+		if len(results) == 0 || results[0].Document.ID != doc.ID {
+			t.Errorf("%s: Search for %s did not return it first", label, doc.ID)
+		}
+	}
+}
+
+func TestCollectionAtCheckpoints(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(16), WithCheckpointRetention(5))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	// Checkpoint 1: a; checkpoint 2: a without its first half, plus b;
+	// checkpoint 3: adds c
+	a := checkpointDocs("a", 40, rng)
+	b := checkpointDocs("b", 40, rng)
+	c := checkpointDocs("c", 40, rng)
+	snapshots := [][]*Document{a, append(append([]*Document{}, a[20:]...), b...), nil}
+	snapshots[2] = append(append([]*Document{}, snapshots[1]...), c...)
+
+	var savedAt []time.Time
+	if err := coll.InsertBatch(a); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	savedAt = append(savedAt, time.Now())
+
+	for _, doc := range a[:20] {
+		if err := coll.Delete(doc.ID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := coll.InsertBatch(b); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	savedAt = append(savedAt, time.Now())
+
+	if err := coll.InsertBatch(c); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	savedAt = append(savedAt, time.Now())
+
+	// The live collection moves on after the last checkpoint
+	if err := coll.Delete(c[0].ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	checkpoints, err := coll.Checkpoints()
+	if err != nil {
+		t.Fatalf("Checkpoints failed: %v", err)
+	}
+	if len(checkpoints) != 3 {
+		t.Fatalf("got %d checkpoints, want 3", len(checkpoints))
+	}
+	for i, cp := range checkpoints {
+		if cp.Documents != len(snapshots[i]) {
+			t.Errorf("checkpoint %d: Documents = %d, want %d", cp.ID, cp.Documents, len(snapshots[i]))
+		}
+		if cp.Size <= 0 {
+			t.Errorf("checkpoint %d: Size = %d", cp.ID, cp.Size)
+		}
+		if cp.Time.After(savedAt[i]) {
+			t.Errorf("checkpoint %d: Time %v after save returned at %v", cp.ID, cp.Time, savedAt[i])
+		}
+	}
+
+	if _, err := db.CollectionAt("docs", AtTime(checkpoints[0].Time.Add(-time.Nanosecond))); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("CollectionAt before first checkpoint: err = %v, want ErrCheckpointNotFound", err)
+	}
+
+	// Open every historical view concurrently with searches on the live
+	// collection
+	var wg sync.WaitGroup
+	for i := range checkpoints {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			view, err := db.CollectionAt("docs", AtTime(savedAt[i]))
+			if err != nil {
+				t.Errorf("CollectionAt %d failed: %v", i, err)
+				return
+			}
+			defer view.Close()
+			assertSnapshot(t, fmt.Sprintf("checkpoint %d", i+1), view, snapshots[i])
+
+			if err := view.Insert(c[0]); !IsReadOnly(err) {
+				t.Errorf("Insert into checkpoint view: err = %v, want ErrReadOnly", err)
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		live := append(append([]*Document{}, snapshots[1]...), c[1:]...)
+		assertSnapshot(t, "live", coll, live)
+	}()
+	wg.Wait()
+
+	view, err := db.CollectionAtCheckpoint("docs", checkpoints[1].ID)
+	if err != nil {
+		t.Fatalf("CollectionAtCheckpoint failed: %v", err)
+	}
+	assertSnapshot(t, "by ID", view, snapshots[1])
+	if _, err := view.Get(a[0].ID); !IsNotFound(err) {
+		t.Errorf("Get of document deleted before checkpoint: err = %v, want not found", err)
+	}
+	view.Close()
+}
+
+func TestCheckpointRetention(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(16), WithCheckpointRetention(2))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 4; i++ {
+		if err := coll.InsertBatch(checkpointDocs(fmt.Sprintf("s%d", i), 10, rng)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	checkpoints, err := coll.Checkpoints()
+	if err != nil {
+		t.Fatalf("Checkpoints failed: %v", err)
+	}
+	if len(checkpoints) != 2 || checkpoints[0].Documents != 30 || checkpoints[1].Documents != 40 {
+		t.Fatalf("retained checkpoints = %+v, want the last two saves", checkpoints)
+	}
+	if _, err := db.CollectionAtCheckpoint("docs", checkpoints[0].ID-1); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("pruned checkpoint: err = %v, want ErrCheckpointNotFound", err)
+	}
+}
+
+func TestCheckpointMaxAge(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(16), WithCheckpointRetention(10), WithCheckpointMaxAge(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 3; i++ {
+		if err := coll.InsertBatch(checkpointDocs(fmt.Sprintf("s%d", i), 10, rng)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Every checkpoint but the newest has expired
+	checkpoints, err := coll.Checkpoints()
+	if err != nil {
+		t.Fatalf("Checkpoints failed: %v", err)
+	}
+	if len(checkpoints) != 1 || checkpoints[0].Documents != 30 {
+		t.Errorf("retained checkpoints = %+v, want only the newest", checkpoints)
+	}
+}
+
+func TestCheckpointsDisabledByDefault(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(16))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	if err := coll.InsertBatch(checkpointDocs("a", 10, rand.New(rand.NewSource(1)))); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	checkpoints, err := coll.Checkpoints()
+	if err != nil {
+		t.Fatalf("Checkpoints failed: %v", err)
+	}
+	if len(checkpoints) != 0 {
+		t.Errorf("got %d checkpoints without retention, want 0", len(checkpoints))
+	}
+	if _, err := db.CollectionAt("docs", AtTime(time.Now())); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("CollectionAt: err = %v, want ErrCheckpointNotFound", err)
+	}
+}
+
+func TestCheckpointTags(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, WithDimension(16), WithCheckpointRetention(2))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+
+	if err := coll.TagCheckpoint("release"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("TagCheckpoint without checkpoints: err = %v, want ErrCheckpointNotFound", err)
+	}
+	release := checkpointDocs("a", 30, rng)
+	if err := coll.InsertBatch(release); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := coll.TagCheckpoint("release"); err != nil {
+		t.Fatalf("TagCheckpoint failed: %v", err)
+	}
+	if err := coll.TagCheckpoint("release"); !errors.Is(err, ErrTagExists) {
+		t.Errorf("duplicate tag: err = %v, want ErrTagExists", err)
+	}
+	if err := coll.TagCheckpoint(" "); !IsValidationFailed(err) {
+		t.Errorf("blank tag: err = %v, want ErrValidationFailed", err)
+	}
+
+	// More saves than the retention keep the tagged checkpoint
+	live := append([]*Document{}, release...)
+	for i := 0; i < 4; i++ {
+		docs := checkpointDocs(fmt.Sprintf("s%d", i), 10, rng)
+		if err := coll.InsertBatch(docs); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		live = append(live, docs...)
+		if err := coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	checkpoints, err := coll.Checkpoints()
+	if err != nil {
+		t.Fatalf("Checkpoints failed: %v", err)
+	}
+	if len(checkpoints) != 3 || !checkpoints[0].HasTag("release") || checkpoints[0].Documents != 30 {
+		t.Fatalf("retained checkpoints = %+v, want the tagged one and the last two saves", checkpoints)
+	}
+
+	view, err := db.CollectionAt("docs", AtTag("release"))
+	if err != nil {
+		t.Fatalf("CollectionAt tag failed: %v", err)
+	}
+	assertSnapshot(t, "tag view", view, release)
+	view.Close()
+	if _, err := db.CollectionAt("docs", AtTag("missing")); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("CollectionAt unknown tag: err = %v, want ErrCheckpointNotFound", err)
+	}
+
+	// Roll back with unsaved changes, which are kept in a new checkpoint
+	extra := checkpointDocs("x", 5, rng)
+	if err := coll.InsertBatch(extra); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	live = append(live, extra...)
+	if err := coll.RollbackTo(ctx, "missing"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("RollbackTo unknown tag: err = %v, want ErrCheckpointNotFound", err)
+	}
+	if err := coll.RollbackTo(ctx, "release"); err != nil {
+		t.Fatalf("RollbackTo failed: %v", err)
+	}
+	assertSnapshot(t, "rolled back", coll, release)
+	if _, err := coll.Get(extra[0].ID); !IsNotFound(err) {
+		t.Errorf("Get of document added after the tag: err = %v, want not found", err)
+	}
+
+	checkpoints, err = coll.Checkpoints()
+	if err != nil {
+		t.Fatalf("Checkpoints failed: %v", err)
+	}
+	before := checkpoints[len(checkpoints)-2]
+	if before.Documents != len(live) || checkpoints[len(checkpoints)-1].Documents != len(release) {
+		t.Fatalf("checkpoints after rollback = %+v", checkpoints)
+	}
+	view, err = db.CollectionAtCheckpoint("docs", before.ID)
+	if err != nil {
+		t.Fatalf("CollectionAtCheckpoint failed: %v", err)
+	}
+	assertSnapshot(t, "pre-rollback checkpoint", view, live)
+	view.Close()
+
+	// The live collection carries on from the restored state, and a tagged
+	// head can be rolled back to in turn
+	if err := coll.Insert(extra[0]); err != nil {
+		t.Fatalf("Insert after rollback failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := coll.TagCheckpoint("head"); err != nil {
+		t.Fatalf("TagCheckpoint failed: %v", err)
+	}
+	if err := coll.Delete(release[0].ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := coll.RollbackTo(ctx, "head"); err != nil {
+		t.Fatalf("RollbackTo head failed: %v", err)
+	}
+	head := append(append([]*Document{}, release...), extra[0])
+	assertSnapshot(t, "head", coll, head)
+
+	// The restored state survives a reopen
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db, err = Open(dir, WithDimension(16), WithCheckpointRetention(2))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	assertSnapshot(t, "reopened", coll, head)
+
+	// Deleting a tag keeps the checkpoint until retention prunes it
+	if err := coll.DeleteTag("release"); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	if err := coll.DeleteTag("release"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("DeleteTag twice: err = %v, want ErrCheckpointNotFound", err)
+	}
+	if _, err := db.CollectionAtCheckpoint("docs", checkpoints[0].ID); err != nil {
+		t.Errorf("untagged checkpoint gone before the next save: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := db.CollectionAt("docs", AtTag("release")); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("CollectionAt deleted tag: err = %v, want ErrCheckpointNotFound", err)
+	}
+	if _, err := db.CollectionAtCheckpoint("docs", checkpoints[0].ID); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("untagged checkpoint not pruned: err = %v", err)
+	}
+	checkpoints, err = coll.Checkpoints()
+	if err != nil {
+		t.Fatalf("Checkpoints failed: %v", err)
+	}
+	if len(checkpoints) != 3 || !checkpoints[0].HasTag("head") {
+		t.Errorf("checkpoints after untagging = %+v, want head and the last two saves", checkpoints)
+	}
+
+	mem, err := OpenInMemory(WithDimension(16))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer mem.Close()
+	memColl, _ := mem.Collection("docs")
+	if err := memColl.TagCheckpoint("release"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("TagCheckpoint in memory: err = %v, want ErrNotSupported", err)
+	}
+}
@@ -3,17 +3,29 @@ package vego
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	hnsw "github.com/wzqhbustb/vego/index"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
 )
 
-// Collection represents a collection of documents with vector search capability
+// Collection represents a collection of documents with vector search capability.
+//
+// Within a process a Collection reads its own writes: a search, Get or
+// stream started after an insert, update or delete call returns observes
+// that change. Writes apply their index and mapping changes under mu before
+// returning and reads take mu, so there is no search snapshot that could lag
+// behind. Read-only replicas opened with WithReadOnly are weaker: they see a
+// write only once the writer has saved it and the replica has refreshed.
 type Collection struct {
 	name      string
 	path      string
@@ -29,15 +41,127 @@ type Collection struct {
 	docToNode map[string]int
 	nodeToDoc map[int]string
 
+	// IDs reserved by an InsertBatch whose nodes are still being built
+	pending map[string]struct{}
+
+	// Inverted index over Config.TextIndexField, nil without one; see
+	// textindex.go
+	text *textIndex
+
+	// Secondary indexes over metadata fields; see metaindex.go
+	metaIndexes metadataIndexes
+
+	// Write-ahead log, nil without Config.WAL; see journal.go
+	wal *journal
+
+	// User and model info saved with the mappings, see info.go
+	info map[string]string
+
 	mu     sync.RWMutex
 	config *Config
+
+	// Writers take saveGate for reading before mu, see lockWrites, so a
+	// save can keep them out while holding mu only for reading
+	saveGate sync.RWMutex
+
+	// Inserts, updates and deletes since the last save, when that was
+	// (UnixNano, 0 = not since opened), and the task saving in the
+	// background, nil without auto-save; see autosave.go
+	dirty    atomic.Int64
+	lastSave atomic.Int64
+	autoSave *scheduledTask
+
+	// Name of config.DistanceFunc, "" for a custom one; see DistanceToScore
+	metric string
+
+	// Storage settings persisted with the collection and the factory built
+	// from them for document storage and index files
+	settings collectionSettings
+	factory  *encoding.EncoderFactory
+
+	// How the index was restored when the collection was opened
+	loadReport LoadReport
+
+	// Last save generation loaded or written, see generation.go
+	generation uint64
+
+	// Last save published as a complete set, 0 = none, and the directory
+	// documents, index and mappings were loaded from: path, or for a replica
+	// the set of the published save while path holds a different one; see
+	// barrier.go
+	published uint64
+	dataDir   string
+
+	// Read-only replicas: refreshMu serializes Refresh
+	refreshMu sync.Mutex
+
+	// Nodes left in the index by inserts whose storage write failed; they
+	// are reaped by the next sweep, see orphans.go
+	orphans map[int]struct{}
+
+	// Clock of retention passes, time.Now outside tests
+	now func() time.Time
+
+	// Periodic tasks (auto-refresh, orphan sweeper, optimizer, retention,
+	// auto-save) run on sched: the DB's shared pool, or for a collection
+	// opened on its own one it owns; see scheduler.go
+	sched    *scheduler
+	ownSched bool
+	tasks    []*scheduledTask
+
+	// Tasks run in chunks during the DB's maintenance window, and the saved
+	// progress of tasks by name; see maintenance.go
+	maintMu    sync.Mutex
+	maint      []*maintenanceTask
+	maintSaved map[string]maintenanceState
+
+	// Recent search latency, which holds maintenance back
+	latency searchLatency
+
+	// Sharded collections route documents to sub-collections and keep no
+	// index or storage of their own; shards[i] is nil if shard i failed to
+	// open, with the reason in shardErrs[i]. See shard.go.
+	shards    []*Collection
+	shardErrs []error
+	shardFn   ShardFunc
+
+	// Lifecycle: in-flight operations are tracked so Close can drain them
+	lifeMu      sync.Mutex
+	closing     bool
+	dropped     bool // Closed by Drop; operations fail with ErrCollectionDropped
+	inflight    sync.WaitGroup
+	closeCtx    context.Context    // Cancelled when the drain timeout expires
+	cancelClose context.CancelFunc // Cancels closeCtx
 }
 
 // NewCollection creates a new collection
 func NewCollection(name, path string, config *Config) (*Collection, error) {
-	if err := os.MkdirAll(path, 0755); err != nil {
-		return nil, err
+	return NewCollectionContext(context.Background(), name, path, config)
+}
+
+// NewCollectionContext creates a new collection or opens an existing one.
+// ctx bounds loading, including any index rebuild from document storage.
+// Its background tasks, if any are configured, run on a pool of its own of
+// Config.BackgroundWorkers goroutines; collections of a DB share the DB's.
+func NewCollectionContext(ctx context.Context, name, path string, config *Config) (*Collection, error) {
+	return newCollection(ctx, name, path, config, nil)
+}
+
+// newCollection opens a collection whose background tasks run on sched, or
+// on a scheduler of its own if sched is nil
+func newCollection(ctx context.Context, name, path string, config *Config, sched *scheduler) (*Collection, error) {
+	if !config.InMemory {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	// Storage and index settings are fixed at creation and reused on reopen
+	settings, err := loadOrCreateSettings(path, config)
+	if err != nil {
+		return nil, wrapError("NewCollection", name, "", err)
 	}
+	config = settings.configure(config)
 
 	coll := &Collection{
 		name:      name,
@@ -45,11 +169,139 @@ func NewCollection(name, path string, config *Config) (*Collection, error) {
 		dimension: config.Dimension,
 		docToNode: make(map[string]int),
 		nodeToDoc: make(map[int]string),
+		pending:   make(map[string]struct{}),
+		info:      make(map[string]string),
+		orphans:   make(map[int]struct{}),
 		config:    config,
+		metric:    metricName(config.DistanceFunc),
+		now:       time.Now,
+		sched:     sched,
 	}
+	coll.closeCtx, coll.cancelClose = context.WithCancel(context.Background())
 
 	// Initialize HNSW index
-	hnswConfig := hnsw.Config{
+	if coll.index, err = newIndex(config); err != nil {
+		return nil, wrapError("NewCollection", name, "", err)
+	}
+	if config.TextIndexField != "" {
+		coll.text = newTextIndex(config.TextIndexField)
+	}
+
+	coll.settings = settings
+	coll.factory = settings.factory()
+	if err := checkShards(settings, config); err != nil {
+		return nil, wrapError("NewCollection", name, "", err)
+	}
+	if settings.Shards > 1 {
+		if err := coll.openShards(ctx); err != nil {
+			return nil, wrapError("NewCollection", name, "", err)
+		}
+		return coll, nil
+	}
+
+	// Documents, index and mappings must come from one published save
+	coll.dataDir = path
+	if config.InMemory {
+		coll.storage = newMemoryStorage(config.Dimension)
+	} else if config.ReadOnly {
+		info, err := readGeneration(path)
+		if err != nil {
+			return nil, wrapError("NewCollection", name, "", err)
+		}
+		if coll.dataDir, err = publishedDir(path, info); err != nil {
+			return nil, wrapError("NewCollection", name, "", err)
+		}
+	} else if err := restoreSaveSet(path); err != nil {
+		return nil, wrapError("NewCollection", name, "", err)
+	}
+
+	// Initialize document storage and try to load existing data
+	if coll.storage == nil {
+		storagePath := filepath.Join(coll.dataDir, "documents")
+		storage, err := NewDocumentStorageWithFactory(storagePath, config.Dimension, coll.factory)
+		if err != nil {
+			return nil, wrapError("NewCollection", name, "", err)
+		}
+		coll.storage = storage
+
+		if err := coll.load(ctx); err != nil && !os.IsNotExist(err) {
+			return nil, wrapError("NewCollection", name, "", err)
+		}
+	}
+
+	if config.WAL && !config.ReadOnly {
+		if err := coll.openJournal(); err != nil {
+			return nil, wrapError("NewCollection", name, "", err)
+		}
+	}
+
+	if config.ReadOnly && config.AutoRefreshInterval > 0 {
+		coll.schedule("auto-refresh", config.AutoRefreshInterval, coll.autoRefresh)
+	}
+	if !config.ReadOnly && config.OrphanSweepInterval > 0 {
+		coll.schedule("orphan sweep", config.OrphanSweepInterval, coll.sweepOrphansTask)
+	}
+	if !config.ReadOnly && config.AutoOptimizeInterval > 0 {
+		coll.schedule("optimize", config.AutoOptimizeInterval, coll.optimizeTask(config.AutoOptimize))
+	}
+	if !config.ReadOnly && config.Retention.enabled() {
+		interval := config.RetentionInterval
+		if interval <= 0 {
+			interval = defaultRetentionInterval
+		}
+		coll.schedule("retention", interval, coll.retentionTask)
+	}
+	if !config.ReadOnly && (config.AutoSaveInterval > 0 || config.AutoSaveThreshold > 0) {
+		interval := config.AutoSaveInterval
+		if interval <= 0 {
+			interval = defaultAutoSaveInterval
+		}
+		coll.autoSave = coll.schedule("auto-save", interval, coll.autoSaveTask)
+	}
+	if !config.ReadOnly && config.Maintenance.enabled() {
+		coll.loadMaintenance()
+		coll.addMaintenance("optimize", coll.optimizeChunk)
+	}
+
+	return coll, nil
+}
+
+// schedule registers a periodic background task of the collection, run
+// every interval plus up to a tenth of it so that the tasks of many
+// collections spread out. It returns the task, nil if the scheduler is
+// closed.
+func (c *Collection) schedule(name string, interval time.Duration, run func(ctx context.Context) error) *scheduledTask {
+	if c.sched == nil {
+		c.sched = newScheduler(c.config.BackgroundWorkers)
+		c.ownSched = true
+	}
+	task := c.sched.schedule(c.name, name, interval, interval/10, run)
+	if task != nil {
+		c.tasks = append(c.tasks, task)
+	}
+	return task
+}
+
+// stopTasks unregisters the collection's background tasks, waiting for any
+// that are running, and stops its own scheduler if it has one
+func (c *Collection) stopTasks() {
+	if c.sched == nil {
+		return
+	}
+	c.sched.remove(c.tasks)
+	if c.ownSched {
+		c.sched.close()
+	}
+}
+
+// newIndex creates an empty HNSW index from config
+func newIndex(config *Config) (*hnsw.HNSWIndex, error) {
+	return hnsw.NewHNSWChecked(indexConfig(config))
+}
+
+// indexConfig returns the HNSW parameters of config
+func indexConfig(config *Config) hnsw.Config {
+	return hnsw.Config{
 		Dimension:      config.Dimension,
 		M:              config.M,
 		EfConstruction: config.EfConstruction,
@@ -57,22 +309,69 @@ func NewCollection(name, path string, config *Config) (*Collection, error) {
 		Adaptive:       config.Adaptive,
 		ExpectedSize:   config.ExpectedSize,
 	}
-	coll.index = hnsw.NewHNSW(hnswConfig)
+}
+
+// begin registers an in-flight operation and returns a context that is also
+// cancelled if Close gives up waiting. The returned func must be called when
+// the operation finishes.
+func (c *Collection) begin(ctx context.Context, op string) (context.Context, func(), error) {
+	if c.shards != nil && !shardedOps[op] {
+		return nil, nil, wrapError(op, c.name, "", fmt.Errorf("%w on a sharded collection", ErrNotSupported))
+	}
 
-	// Initialize document storage
-	storagePath := filepath.Join(path, "documents")
-	storage, err := NewDocumentStorage(storagePath, config.Dimension)
-	if err != nil {
-		return nil, wrapError("NewCollection", name, "", err)
+	c.lifeMu.Lock()
+	if c.closing {
+		err := ErrCollectionClosed
+		if c.dropped {
+			err = ErrCollectionDropped
+		}
+		c.lifeMu.Unlock()
+		return nil, nil, wrapError(op, c.name, "", err)
 	}
-	coll.storage = storage
+	c.inflight.Add(1)
+	c.lifeMu.Unlock()
+
+	opCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.closeCtx, cancel)
+	return opCtx, func() {
+		stop()
+		cancel()
+		c.inflight.Done()
+	}, nil
+}
 
-	// Try to load existing data
-	if err := coll.load(); err != nil && !os.IsNotExist(err) {
-		return nil, wrapError("NewCollection", name, "", err)
+// beginWrite is begin for operations that modify the collection; they fail
+// with ErrReadOnly on read-only collections
+func (c *Collection) beginWrite(ctx context.Context, op string) (context.Context, func(), error) {
+	if c.config.ReadOnly {
+		return nil, nil, wrapError(op, c.name, "", ErrReadOnly)
 	}
+	return c.begin(ctx, op)
+}
 
-	return coll, nil
+// lockWrites locks the collection for a write. Writers wait at saveGate
+// while a save runs rather than on mu, where a waiting writer would hold
+// back searches too.
+func (c *Collection) lockWrites() {
+	c.saveGate.RLock()
+	c.mu.Lock()
+}
+
+// unlockWrites unlocks the collection after a write
+func (c *Collection) unlockWrites() {
+	c.mu.Unlock()
+	c.saveGate.RUnlock()
+}
+
+// unlockJournaled unlocks the collection after a journaled write, then
+// waits for the journal to make the write durable, see journal.commit.
+// err points at the write's result; a failed write does not wait, and a
+// failed fsync fails the write.
+func (c *Collection) unlockJournaled(op string, err *error) {
+	c.unlockWrites()
+	if *err == nil {
+		*err = wrapError(op, c.name, "", c.wal.commit())
+	}
 }
 
 // Insert adds a document to the collection
@@ -82,13 +381,19 @@ func (c *Collection) Insert(doc *Document) error {
 }
 
 // InsertContext adds a document to the collection with context support
-func (c *Collection) InsertContext(ctx context.Context, doc *Document) error {
-	if err := doc.Validate(c.dimension); err != nil {
+func (c *Collection) InsertContext(ctx context.Context, doc *Document) (err error) {
+	if c.shards != nil {
+		return c.shardInsert(ctx, doc)
+	}
+	ctx, done, err := c.beginWrite(ctx, "InsertContext")
+	if err != nil {
 		return err
 	}
+	defer done()
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if err := doc.ValidateWith(c.dimension, c.settings.Constraints); err != nil {
+		return err
+	}
 
 	// Check context cancellation
 	select {
@@ -97,34 +402,71 @@ func (c *Collection) InsertContext(ctx context.Context, doc *Document) error {
 	default:
 	}
 
-	// Check if document already exists
-	if _, exists := c.docToNode[doc.ID]; exists {
+	// Reserve the ID, then link the vector into the graph without the
+	// collection lock, as insertBatch does, so searches are not held back
+	// for the length of the insert
+	c.lockWrites()
+	_, exists := c.docToNode[doc.ID]
+	_, reserved := c.pending[doc.ID]
+	if exists || reserved {
+		c.unlockWrites()
 		return wrapError("InsertContext", c.name, doc.ID, ErrDuplicateID)
 	}
+	c.pending[doc.ID] = struct{}{}
+	c.unlockWrites()
 
-	// Add to HNSW index
 	nodeID, err := c.index.Add(doc.Vector)
+
+	c.lockWrites()
+	defer c.unlockJournaled("InsertContext", &err)
+	delete(c.pending, doc.ID)
 	if err != nil {
 		return wrapError("InsertContext", c.name, doc.ID, err)
 	}
+	doc.Timestamp = time.Now()
+	if err := c.storeDocument(doc, nodeID); err != nil {
+		return wrapError("InsertContext", c.name, doc.ID, err)
+	}
+	return nil
+}
+
+// insertDocument indexes and stores a document whose ID is not in use,
+// stamped with the time of this write. c.mu must be held for writing.
+func (c *Collection) insertDocument(doc *Document) error {
+	doc.Timestamp = time.Now()
+	return c.addDocument(doc)
+}
+
+// addDocument indexes and stores a document whose ID is not in use, keeping
+// its timestamp. c.mu must be held for writing.
+func (c *Collection) addDocument(doc *Document) error {
+	// Add to HNSW index
+	nodeID, err := c.index.Add(doc.Vector)
+	if err != nil {
+		return err
+	}
+	return c.storeDocument(doc, nodeID)
+}
 
-	// Store document
+// storeDocument stores and maps a document whose vector was added to the
+// index as nodeID. c.mu must be held for writing.
+func (c *Collection) storeDocument(doc *Document, nodeID int) error {
 	if err := c.storage.Put(doc); err != nil {
-		// Rollback: Remove from mappings (node remains orphaned in index until rebuilt)
-		// Note: HNSW doesn't support Delete, so the node will stay in the index
-		// but won't be discoverable through normal operations
+		// The unmapped node stays in the index until the next sweep
+		// deletes it
+		c.orphans[nodeID] = struct{}{}
 		log.Printf("Warning: Failed to store document %s, node %d is orphaned", doc.ID, nodeID)
-		return wrapError("InsertContext", c.name, doc.ID, err)
+		return err
 	}
 
 	// Update mappings
 	c.docToNode[doc.ID] = nodeID
 	c.nodeToDoc[nodeID] = doc.ID
+	c.text.add(doc)
+	c.metaIndexes.add(doc)
+	c.markDirty(1)
 
-	// Update timestamp
-	doc.Timestamp = time.Now()
-
-	return nil
+	return c.wal.put(doc)
 }
 
 // InsertBatch adds multiple documents in batch (more efficient)
@@ -133,56 +475,133 @@ func (c *Collection) InsertBatch(docs []*Document) error {
 	return c.InsertBatchContext(context.Background(), docs)
 }
 
-// InsertBatchContext adds multiple documents with context support
+// InsertBatchContext adds multiple documents with context support. The
+// batch fails with ErrDuplicateID, inserting nothing, if any ID is already
+// in use or appears twice; see InsertBatchOpts for other conflict policies.
 func (c *Collection) InsertBatchContext(ctx context.Context, docs []*Document) error {
-	if len(docs) == 0 {
-		return nil
+	if c.shards != nil {
+		return c.shardInsertBatch(ctx, docs)
 	}
+	_, err := c.insertBatch(ctx, "InsertBatchContext", docs, ConflictError)
+	return err
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// insertBatch inserts docs, resolving IDs already in use or repeated in the
+// batch by policy, see InsertBatchOpts
+func (c *Collection) insertBatch(ctx context.Context, op string, docs []*Document, policy ConflictPolicy) (result *InsertBatchResult, err error) {
+	ctx, done, err := c.beginWrite(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	result = &InsertBatchResult{}
+	if len(docs) == 0 {
+		return result, nil
+	}
 
 	// Check context cancellation
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	default:
 	}
 
-	// Validate all documents first
-	for _, doc := range docs {
-		if err := doc.Validate(c.dimension); err != nil {
-			return wrapError("InsertBatchContext", c.name, doc.ID, ErrValidationFailed)
+	if err := c.validateBatch(op, docs); err != nil {
+		return nil, err
+	}
+
+	// Sort out the conflicts and reserve the new documents' IDs. The
+	// collection lock is only held for bookkeeping and replacements, not
+	// while the graph is being built, so searches keep running against the
+	// index during large batches.
+	c.lockWrites()
+	inserts, replaces, skipped, err := c.resolveConflicts(op, docs, policy)
+	if err != nil {
+		c.unlockWrites()
+		return nil, err
+	}
+	result.Skipped = skipped
+	result.Replaced = len(docs) - len(inserts) - len(replaces) - skipped // Earlier copies of repeated IDs
+
+	// Replacements are written one by one, each atomically as with Update
+	now := time.Now()
+	for _, doc := range replaces {
+		doc.Timestamp = now
+		if err := c.replaceDocument(doc, c.docToNode[doc.ID]); err != nil {
+			c.unlockWrites()
+			return result, wrapError(op, c.name, doc.ID, err)
 		}
-		if _, exists := c.docToNode[doc.ID]; exists {
-			return wrapError("InsertBatchContext", c.name, doc.ID, ErrDuplicateID)
+		result.Replaced++
+	}
+	if len(inserts) == 0 {
+		c.unlockWrites()
+		return result, wrapError(op, c.name, "", c.wal.commit())
+	}
+
+	for _, doc := range inserts {
+		c.pending[doc.ID] = struct{}{}
+	}
+	c.unlockWrites()
+
+	release := func() {
+		for _, doc := range inserts {
+			delete(c.pending, doc.ID)
 		}
 	}
 
-	// Insert into HNSW
-	for _, doc := range docs {
+	// Insert into HNSW; nodes are not searchable as documents until mapped
+	nodeIDs := make([]int, len(inserts))
+	for i, doc := range inserts {
 		// Check context cancellation periodically
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			c.lockWrites()
+			release()
+			c.unlockWrites()
+			return result, ctx.Err()
 		default:
 		}
 
 		nodeID, err := c.index.Add(doc.Vector)
 		if err != nil {
-			return wrapError("InsertBatchContext", c.name, doc.ID, err)
+			c.lockWrites()
+			release()
+			c.unlockWrites()
+			return result, wrapError(op, c.name, doc.ID, err)
 		}
-		c.docToNode[doc.ID] = nodeID
-		c.nodeToDoc[nodeID] = doc.ID
-		doc.Timestamp = time.Now()
+		nodeIDs[i] = nodeID
 	}
 
+	c.lockWrites()
+	defer c.unlockJournaled(op, &err)
+	defer release()
+
 	// Store documents
-	if err := c.storage.PutBatch(docs); err != nil {
-		return wrapError("InsertBatchContext", c.name, "", err)
+	for _, doc := range inserts {
+		doc.Timestamp = now
+	}
+	if err := c.storage.PutBatch(inserts); err != nil {
+		for _, nodeID := range nodeIDs {
+			c.orphans[nodeID] = struct{}{}
+		}
+		log.Printf("Warning: Failed to store batch of %d documents, their nodes are orphaned", len(inserts))
+		return result, wrapError(op, c.name, "", err)
 	}
 
-	return nil
+	for i, doc := range inserts {
+		c.docToNode[doc.ID] = nodeIDs[i]
+		c.nodeToDoc[nodeIDs[i]] = doc.ID
+		c.text.add(doc)
+		c.metaIndexes.add(doc)
+	}
+	result.Inserted = len(inserts)
+	c.markDirty(len(inserts))
+
+	if err := c.wal.put(inserts...); err != nil {
+		return result, wrapError(op, c.name, "", err)
+	}
+	return result, nil
 }
 
 // GetBatch retrieves multiple documents by IDs
@@ -191,36 +610,14 @@ func (c *Collection) GetBatch(ids []string) (map[string]*Document, error) {
 	return c.GetBatchContext(context.Background(), ids)
 }
 
-// GetBatchContext retrieves multiple documents with context support
+// GetBatchContext retrieves multiple documents with context support.
+// Missing documents are omitted; use GetBatchOpts to have them reported.
 func (c *Collection) GetBatchContext(ctx context.Context, ids []string) (map[string]*Document, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// Check context cancellation
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	default:
-	}
-
-	results := make(map[string]*Document, len(ids))
-	for _, id := range ids {
-		// Check context cancellation periodically
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		doc, err := c.storage.Get(id)
-		if err != nil {
-			// Skip not found documents
-			continue
-		}
-		results[id] = doc
+	result, err := c.GetBatchOpts(ctx, ids, GetOptions{})
+	if err != nil {
+		return nil, err
 	}
-
-	return results, nil
+	return result.Found, nil
 }
 
 // DeleteBatch removes multiple documents from the collection
@@ -229,9 +626,18 @@ func (c *Collection) DeleteBatch(ids []string) error {
 }
 
 // DeleteBatchContext removes multiple documents with context support
-func (c *Collection) DeleteBatchContext(ctx context.Context, ids []string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Collection) DeleteBatchContext(ctx context.Context, ids []string) (err error) {
+	if c.shards != nil {
+		return c.shardDeleteBatch(ctx, ids)
+	}
+	ctx, done, err := c.beginWrite(ctx, "DeleteBatchContext")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	c.lockWrites()
+	defer c.unlockJournaled("DeleteBatchContext", &err)
 
 	// Check context cancellation
 	select {
@@ -254,15 +660,10 @@ func (c *Collection) DeleteBatchContext(ctx context.Context, ids []string) error
 			continue // Skip non-existent documents
 		}
 
-		// Delete from storage
-		if err := c.storage.Delete(id); err != nil {
+		if err := c.deleteDocument(id, nodeID); err != nil {
 			lastErr = err
 			continue // Continue with other deletions even if one fails
 		}
-
-		// Delete from index mapping
-		delete(c.docToNode, id)
-		delete(c.nodeToDoc, nodeID)
 	}
 
 	return lastErr
@@ -276,6 +677,15 @@ func (c *Collection) Get(id string) (*Document, error) {
 
 // GetContext retrieves a document by ID with context support
 func (c *Collection) GetContext(ctx context.Context, id string) (*Document, error) {
+	if c.shards != nil {
+		return c.shardGet(ctx, id)
+	}
+	ctx, done, err := c.begin(ctx, "GetContext")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -296,9 +706,18 @@ func (c *Collection) Delete(id string) error {
 }
 
 // DeleteContext removes a document from the collection with context support
-func (c *Collection) DeleteContext(ctx context.Context, id string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Collection) DeleteContext(ctx context.Context, id string) (err error) {
+	if c.shards != nil {
+		return c.shardDelete(ctx, id)
+	}
+	ctx, done, err := c.beginWrite(ctx, "DeleteContext")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	c.lockWrites()
+	defer c.unlockJournaled("DeleteContext", &err)
 
 	// Check context cancellation
 	select {
@@ -312,35 +731,57 @@ func (c *Collection) DeleteContext(ctx context.Context, id string) error {
 		return wrapError("DeleteContext", c.name, id, ErrDocumentNotFound)
 	}
 
+	if err := c.deleteDocument(id, nodeID); err != nil {
+		return wrapError("DeleteContext", c.name, id, err)
+	}
+	return nil
+}
+
+// deleteDocument deletes the document id mapped to nodeID from storage, the
+// mappings and the index. c.mu must be held for writing.
+func (c *Collection) deleteDocument(id string, nodeID int) error {
 	// Delete from storage
 	if err := c.storage.Delete(id); err != nil {
-		return wrapError("DeleteContext", c.name, id, err)
+		return err
 	}
 
-	// Delete from index (soft delete - mark as deleted)
-	// Note: Full delete requires rebuilding index
+	// Delete from index mapping and the index
 	delete(c.docToNode, id)
 	delete(c.nodeToDoc, nodeID)
+	c.deleteNode(nodeID)
+	c.text.remove(id)
+	c.metaIndexes.remove(id)
+	c.markDirty(1)
 
-	return nil
+	return c.wal.delete(id)
 }
 
 // Update updates a document's metadata and vector
-// WARNING: This creates a new node in the index. The old node remains (HNSW doesn't support delete).
-// For production use, consider: 1) Periodic index rebuild, 2) Or use Delete + Insert pattern
+// A changed vector is added to the index as a new node and the old node is
+// deleted; an unchanged one keeps its node. Returns ErrDocumentNotFound
+// (see IsNotFound) if the document does not exist.
 // Deprecated: Use UpdateContext instead
 func (c *Collection) Update(doc *Document) error {
 	return c.UpdateContext(context.Background(), doc)
 }
 
 // UpdateContext updates a document with context support
-func (c *Collection) UpdateContext(ctx context.Context, doc *Document) error {
-	if err := doc.Validate(c.dimension); err != nil {
+func (c *Collection) UpdateContext(ctx context.Context, doc *Document) (err error) {
+	if c.shards != nil {
+		return c.shardUpdate(ctx, "UpdateContext", doc)
+	}
+	ctx, done, err := c.beginWrite(ctx, "UpdateContext")
+	if err != nil {
 		return err
 	}
+	defer done()
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	if err := doc.ValidateWith(c.dimension, c.settings.Constraints); err != nil {
+		return err
+	}
+
+	c.lockWrites()
+	defer c.unlockJournaled("UpdateContext", &err)
 
 	// Check context cancellation
 	select {
@@ -354,24 +795,68 @@ func (c *Collection) UpdateContext(ctx context.Context, doc *Document) error {
 		return wrapError("UpdateContext", c.name, doc.ID, ErrDocumentNotFound)
 	}
 
-	// Update storage first
-	if err := c.storage.Put(doc); err != nil {
+	doc.Timestamp = time.Now()
+	if err := c.replaceDocument(doc, oldNodeID); err != nil {
 		return wrapError("UpdateContext", c.name, doc.ID, err)
 	}
+	return nil
+}
+
+// replaceDocument stores doc over the stored document mapped to oldNodeID
+// and re-indexes its vector if it differs from the node's. The new node is
+// added before storage is written and deleted again if the write fails, so
+// on error the document keeps its old node and stored copy. c.mu must be
+// held for writing.
+func (c *Collection) replaceDocument(doc *Document, oldNodeID int) error {
+	if old, err := c.index.Vector(oldNodeID); err == nil && vectorsEqual(old, c.index.StoredVector(doc.Vector)) {
+		if err := c.storage.Put(doc); err != nil {
+			return err
+		}
+		c.text.add(doc)
+		c.metaIndexes.add(doc)
+		c.markDirty(1)
+		return c.wal.put(doc)
+	}
 
-	// Add new vector to index
 	newNodeID, err := c.index.Add(doc.Vector)
 	if err != nil {
-		return wrapError("UpdateContext", c.name, doc.ID, err)
+		return err
+	}
+	if err := c.storage.Put(doc); err != nil {
+		c.deleteNode(newNodeID)
+		return err
 	}
 
-	// Update mappings (old node becomes orphaned)
+	// Remap the document and delete the old node
 	delete(c.nodeToDoc, oldNodeID)
 	c.docToNode[doc.ID] = newNodeID
 	c.nodeToDoc[newNodeID] = doc.ID
-	doc.Timestamp = time.Now()
+	c.deleteNode(oldNodeID)
+	c.text.add(doc)
+	c.metaIndexes.add(doc)
+	c.markDirty(1)
+	return c.wal.put(doc)
+}
 
-	return nil
+// vectorsEqual reports whether a and b hold the same components
+func vectorsEqual(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteNode deletes the node of a document no longer mapped to it from the
+// index. c.mu must be held for writing.
+func (c *Collection) deleteNode(nodeID int) {
+	if err := c.index.Delete(nodeID); err != nil {
+		log.Printf("Warning: failed to delete node %d from index of collection %s: %v", nodeID, c.name, err)
+	}
 }
 
 // Upsert inserts or updates a document
@@ -380,16 +865,48 @@ func (c *Collection) Upsert(doc *Document) error {
 	return c.UpsertContext(context.Background(), doc)
 }
 
-// UpsertContext inserts or updates a document with context support
-func (c *Collection) UpsertContext(ctx context.Context, doc *Document) error {
-	c.mu.RLock()
-	_, exists := c.docToNode[doc.ID]
-	c.mu.RUnlock()
+// UpsertContext inserts a document, or replaces the vector and metadata of
+// the stored document with the same ID, with context support. The check
+// and the write happen under one hold of the collection lock, so concurrent
+// upserts of an ID never fail with ErrDuplicateID, except against a batch
+// insert still adding that ID.
+func (c *Collection) UpsertContext(ctx context.Context, doc *Document) (err error) {
+	if c.shards != nil {
+		return c.shardUpdate(ctx, "UpsertContext", doc)
+	}
+	ctx, done, err := c.beginWrite(ctx, "UpsertContext")
+	if err != nil {
+		return err
+	}
+	defer done()
 
-	if exists {
-		return c.UpdateContext(ctx, doc)
+	if err := doc.ValidateWith(c.dimension, c.settings.Constraints); err != nil {
+		return err
+	}
+
+	c.lockWrites()
+	defer c.unlockJournaled("UpsertContext", &err)
+
+	// Check context cancellation
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if _, reserved := c.pending[doc.ID]; reserved {
+		return wrapError("UpsertContext", c.name, doc.ID, ErrDuplicateID)
+	}
+	if oldNodeID, exists := c.docToNode[doc.ID]; exists {
+		doc.Timestamp = time.Now()
+		err = c.replaceDocument(doc, oldNodeID)
+	} else {
+		err = c.insertDocument(doc)
+	}
+	if err != nil {
+		return wrapError("UpsertContext", c.name, doc.ID, err)
 	}
-	return c.InsertContext(ctx, doc)
+	return nil
 }
 
 // Search performs vector similarity search
@@ -398,33 +915,87 @@ func (c *Collection) Search(query []float32, k int, opts ...SearchOption) ([]Sea
 	return c.SearchContext(context.Background(), query, k, opts...)
 }
 
-// SearchContext performs vector similarity search with context support
+// SearchContext performs vector similarity search with context support.
+// k must be positive (ErrInvalidK otherwise); if fewer than k documents are
+// available, all of them are returned, so an empty collection returns no
+// results rather than an error. WithEF values below k are raised to k.
 func (c *Collection) SearchContext(ctx context.Context, query []float32, k int, opts ...SearchOption) ([]SearchResult, error) {
-	if len(query) != c.dimension {
-		return nil, wrapError("SearchContext", c.name, "", ErrDimensionMismatch)
+	ctx, done, err := c.begin(ctx, "SearchContext")
+	if err != nil {
+		return nil, err
 	}
+	defer done()
 
-	options := &SearchOptions{
-		EF: 0, // Use default
+	if err := c.checkQuery("SearchContext", query); err != nil {
+		return nil, err
 	}
-	for _, opt := range opts {
-		opt(options)
+	if k <= 0 {
+		return nil, wrapError("SearchContext", c.name, "", ErrInvalidK)
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// Check context cancellation
+	options := c.searchOptions(opts)
+	if err := c.checkBoost("SearchContext", options); err != nil {
+		return nil, err
+	}
+	if err := c.checkMMR("SearchContext", options); err != nil {
+		return nil, err
+	}
+	fetch, fetchOptions := boostCandidates(k, options), options
+	if options.MMR != nil {
+		fetch, fetchOptions = mmrCandidates(k, options)
+	}
+	results, err := c.search(ctx, "SearchContext", query, fetch, fetchOptions)
+	if err != nil {
+		return nil, err
+	}
+	results = diversify(boost(results, k, options), k, options, c.config.DistanceFunc)
+	return c.enrich(ctx, "SearchContext", results, options)
+}
+
+// searchOptions applies opts over the collection's defaults
+func (c *Collection) searchOptions(opts []SearchOption) *SearchOptions {
+	options := &SearchOptions{
+		EF:                  0, // Use default
+		Vectors:             c.config.SearchVectors,
+		FilterEscalation:    !c.config.DisableFilterEscalation,
+		MaxFilterCandidates: c.config.MaxFilterCandidates,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// search returns the k nearest documents to query without enrichment; the
+// caller has called begin and checked query and k
+func (c *Collection) search(ctx context.Context, op string, query []float32, k int, options *SearchOptions) ([]SearchResult, error) {
+	if c.shards != nil {
+		return c.shardSearch(ctx, op, query, k, options)
+	}
+	defer c.observeSearch(time.Now())
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Check context cancellation
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 	}
 
+	// An empty collection has no hits rather than an empty index error
+	if c.index.Len() == 0 {
+		return []SearchResult{}, nil
+	}
+
 	// Search HNSW index
-	hnswResults, err := c.index.Search(query, k, options.EF)
+	hnswResults, err := c.index.SearchWithParams(query, k, hnsw.SearchParams{
+		EfUpperLayers: options.EFUpperLayers,
+		EfBase:        options.EF,
+	})
 	if err != nil {
-		return nil, wrapError("SearchContext", c.name, "", err)
+		return nil, wrapError(op, c.name, "", err)
 	}
 
 	// Map to documents
@@ -439,8 +1010,7 @@ func (c *Collection) SearchContext(ctx context.Context, query []float32, k int,
 
 		docID, exists := c.nodeToDoc[hr.ID]
 		if !exists {
-			log.Printf("Warning: node %d has no document mapping (orphaned)", hr.ID)
-			continue // Skip deleted/orphaned nodes
+			continue // Skip deleted/orphaned nodes and nodes of in-progress batches
 		}
 
 		doc, err := c.storage.Get(docID)
@@ -449,101 +1019,283 @@ func (c *Collection) SearchContext(ctx context.Context, query []float32, k int,
 			continue // Skip missing documents
 		}
 
-		results = append(results, SearchResult{
-			Document: doc,
-			Distance: hr.Distance,
-		})
+		// Vectors are returned as stored, not as the index keeps them
+		// (normalized for cosine), and omitted unless requested
+		if !options.Vectors {
+			doc.Vector = nil
+		}
+
+		results = append(results, c.newResult(doc, hr.Distance))
 	}
 
 	return results, nil
 }
 
 // SearchWithFilter performs vector search with metadata filter
-// Dynamically expands search scope until enough filtered results are found
+// The filter is applied while the index is traversed, so documents that do
+// not match are passed through rather than filling the results, and a
+// selective filter still finds k matches. The filter sees each document's
+// ID and Metadata but not its Vector or Timestamp. SearchFiltered instead
+// post-filters growing candidate sets and reports its escalations.
 func (c *Collection) SearchWithFilter(query []float32, k int, filter Filter) ([]SearchResult, error) {
-	batchSize := k * 2
-	maxBatchSize := k * 20
-	maxAttempts := 5
+	ctx, done, err := c.begin(context.Background(), "SearchWithFilter")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
-	var allFiltered []SearchResult
+	if err := c.checkQuery("SearchWithFilter", query); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, wrapError("SearchWithFilter", c.name, "", ErrInvalidK)
+	}
+	return c.searchWithFilter(ctx, "SearchWithFilter", query, k, filter, c.searchOptions(nil))
+}
 
-	for attempt := 0; attempt < maxAttempts && batchSize <= maxBatchSize; attempt++ {
-		// Search with current batch size
-		results, err := c.Search(query, batchSize)
-		if err != nil {
-			return nil, err
-		}
+// searchWithFilter returns the k nearest documents matching filter, which
+// is applied during the index traversal, see SearchWithFilter. Results are
+// not enriched, so filter only sees stored metadata.
+func (c *Collection) searchWithFilter(ctx context.Context, op string, query []float32, k int, filter Filter, options *SearchOptions) ([]SearchResult, error) {
+	if c.shards != nil {
+		return c.shardSearchWithFilter(ctx, op, query, k, filter, options)
+	}
+	defer c.observeSearch(time.Now())
 
-		// Apply filter
-		allFiltered = allFiltered[:0] // Reset
-		for _, r := range results {
-			if r.Document != nil && filter.Match(r.Document) {
-				allFiltered = append(allFiltered, r)
-				if len(allFiltered) >= k {
-					return allFiltered[:k], nil
-				}
-			}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// An empty collection has no hits rather than an empty index error
+	if c.index.Len() == 0 {
+		return []SearchResult{}, nil
+	}
+
+	hits, err := c.filteredHits(query, k, filter, options)
+	if err != nil {
+		return nil, wrapError(op, c.name, "", err)
+	}
+
+	// Hits are mapped documents, loaded in one pass over storage
+	ids := make([]string, len(hits))
+	for i, hr := range hits {
+		ids[i] = c.nodeToDoc[hr.ID]
+	}
+	// As in search, vectors are read from storage only when requested
+	docs, _, err := c.storage.getBatch(ids, options.Vectors, true)
+	if err != nil {
+		return nil, wrapError(op, c.name, "", err)
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for i, docID := range ids {
+		doc, ok := docs[docID]
+		if !ok {
+			log.Printf("Warning: failed to load document %s: %v", docID, ErrDocumentNotFound)
+			continue
 		}
+		results = append(results, c.newResult(doc, hits[i].Distance))
+	}
+	return results, nil
+}
 
-		// If we got all available results and found some matches, return them
-		if len(results) < batchSize {
-			// We got all available results
-			return allFiltered, nil
+// allowFilter returns the index predicate of filter: it accepts the nodes
+// mapped to a document whose ID and stored metadata match, a nil filter
+// every mapped node. c.mu must be held while the predicate is used.
+func (c *Collection) allowFilter(filter Filter) func(nodeID int) bool {
+	return func(nodeID int) bool {
+		docID, exists := c.nodeToDoc[nodeID]
+		if !exists {
+			return false // Deleted/orphaned nodes and nodes of in-progress batches
+		}
+		if filter == nil {
+			return true
+		}
+		metadata, err := c.storage.getMetadata(docID)
+		if err != nil {
+			log.Printf("Warning: failed to load metadata of document %s: %v", docID, err)
+			return false
 		}
+		return filter.Match(&Document{ID: docID, Metadata: metadata})
+	}
+}
 
-		// Otherwise expand search
-		batchSize *= 2
+// SearchSimilarTo finds the k documents nearest to the document with the
+// given ID ("more like this"). The source vector is read from the index, not
+// from storage, and the source document is left out of the results unless
+// WithIncludeSource is given. A nil filter matches every document; other
+// options apply as in SearchContext. Returns ErrDocumentNotFound if id does
+// not exist.
+func (c *Collection) SearchSimilarTo(ctx context.Context, id string, k int, filter Filter, opts ...SearchOption) ([]SearchResult, error) {
+	ctx, done, err := c.begin(ctx, "SearchSimilarTo")
+	if err != nil {
+		return nil, err
 	}
+	defer done()
 
-	return allFiltered, nil
-}
+	if k <= 0 {
+		return nil, wrapError("SearchSimilarTo", c.name, id, ErrInvalidK)
+	}
 
-// SearchBatch performs multiple vector searches in parallel
-func (c *Collection) SearchBatch(queries [][]float32, k int, opts ...SearchOption) ([][]SearchResult, error) {
-	if len(queries) == 0 {
-		return [][]SearchResult{}, nil
+	options := c.searchOptions(opts)
+	if err := c.checkBoost("SearchSimilarTo", options); err != nil {
+		return nil, err
 	}
 
-	results := make([][]SearchResult, len(queries))
-	errors := make([]error, len(queries))
+	c.mu.RLock()
+	nodeID, exists := c.docToNode[id]
+	var query []float32
+	if exists {
+		query, err = c.index.Vector(nodeID)
+	}
+	c.mu.RUnlock()
+	if !exists {
+		return nil, wrapError("SearchSimilarTo", c.name, id, ErrDocumentNotFound)
+	}
+	if err != nil {
+		return nil, wrapError("SearchSimilarTo", c.name, id, err)
+	}
 
-	// Use worker pool for parallel search
-	var wg sync.WaitGroup
-	numWorkers := 4
-	if len(queries) < numWorkers {
-		numWorkers = len(queries)
+	// Ask for one extra hit to make up for the source being dropped
+	want := boostCandidates(k, options)
+	if !options.IncludeSource {
+		want++
 	}
 
-	jobs := make(chan int, len(queries))
+	var results []SearchResult
+	if filter == nil {
+		results, err = c.search(ctx, "SearchSimilarTo", query, want, options)
+	} else {
+		results, err = c.searchWithFilter(ctx, "SearchSimilarTo", query, want, filter, options)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	for w := 0; w < numWorkers; w++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for i := range jobs {
-				results[i], errors[i] = c.Search(queries[i], k, opts...)
+	if !options.IncludeSource {
+		kept := results[:0]
+		for _, r := range results {
+			if r.Document.ID != id {
+				kept = append(kept, r)
 			}
-		}()
+		}
+		results = kept
+	}
+	results = boost(results, k, options)
+	if len(results) > k {
+		results = results[:k]
 	}
+	return c.enrich(ctx, "SearchSimilarTo", results, options)
+}
 
-	for i := range queries {
-		jobs <- i
+// SearchIDs performs vector search and returns only document IDs and
+// distances, in the same order as SearchContext and SearchWithFilter.
+// Unfiltered queries never touch document storage. With a filter, only the
+// metadata of candidate documents is loaded, so the filter sees each
+// document's ID and Metadata but not its Vector or Timestamp.
+func (c *Collection) SearchIDs(ctx context.Context, query []float32, k int, filter Filter) ([]IDResult, error) {
+	ctx, done, err := c.begin(ctx, "SearchIDs")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := c.checkQuery("SearchIDs", query); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, wrapError("SearchIDs", c.name, "", ErrInvalidK)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Filtered as in SearchWithFilter, so both return the same hits
+	var allow func(nodeID int) bool
+	if filter != nil {
+		allow = c.allowFilter(filter)
 	}
-	close(jobs)
-	wg.Wait()
+	return c.searchIDs(query, k, allow)
+}
 
-	// Check for errors
+// searchIDs returns up to k hits mapped to document IDs, skipping orphaned
+// nodes; only nodes allow accepts if it is set. c.mu must be held.
+func (c *Collection) searchIDs(query []float32, k int, allow func(nodeID int) bool) ([]IDResult, error) {
+	if c.index.Len() == 0 {
+		return []IDResult{}, nil
+	}
+	hnswResults, err := c.index.SearchWithFilter(query, k, 0, allow)
+	if err != nil {
+		return nil, wrapError("SearchIDs", c.name, "", err)
+	}
+
+	results := make([]IDResult, 0, len(hnswResults))
+	for _, hr := range hnswResults {
+		docID, exists := c.nodeToDoc[hr.ID]
+		if !exists {
+			continue // Skip deleted/orphaned nodes and nodes of in-progress batches
+		}
+		results = append(results, IDResult{DocID: docID, Distance: hr.Distance})
+	}
+	return results, nil
+}
+
+// SearchBatch performs multiple vector searches in parallel.
+// Queries are validated and searched independently: if any fail, the
+// returned error wraps a *BatchError holding one entry per query, and the
+// results of the successful queries are still returned. SearchBatchContext
+// also takes a context and a concurrency limit.
+func (c *Collection) SearchBatch(queries [][]float32, k int, opts ...SearchOption) ([][]SearchResult, error) {
+	if len(queries) == 0 {
+		return [][]SearchResult{}, nil
+	}
+
+	results, errors := c.searchBatch(context.Background(), queries, k, defaultBatchConcurrency, opts)
+
+	// Each query is validated and searched independently; failures are
+	// reported per query while successful results are still returned
 	for _, err := range errors {
 		if err != nil {
-			return nil, wrapError("SearchBatch", c.name, "", err)
+			return results, wrapError("SearchBatch", c.name, "", &BatchError{Errors: errors})
 		}
 	}
 
 	return results, nil
 }
 
+// Metric returns the name of the collection's distance function, one of
+// the Metric constants, or "" for a custom one
+func (c *Collection) Metric() string {
+	return c.metric
+}
+
+// SetDefaultEF sets the search scope of searches without WithEF, in place
+// of max(200, 2k); ef <= 0 restores that. It applies to searches started
+// afterwards and is kept with the index at the next Save.
+func (c *Collection) SetDefaultEF(ef int) error {
+	if c.shards != nil {
+		return c.shardSetDefaultEF(ef)
+	}
+	_, done, err := c.begin(context.Background(), "SetDefaultEF")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.index.SetDefaultEf(ef)
+	return nil
+}
+
 // Count returns number of documents in collection
 func (c *Collection) Count() int {
+	if c.shards != nil {
+		return c.shardCount()
+	}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return len(c.docToNode)
@@ -554,88 +1306,417 @@ type CollectionStats struct {
 	Name        string    // Collection name
 	Count       int       // Number of documents
 	Dimension   int       // Vector dimension
-	IndexNodes  int       // Total HNSW nodes (includes orphaned)
-	OrphanNodes int       // Orphaned nodes (from updates)
+	IndexNodes  int       // Live HNSW nodes (includes orphaned, not deleted ones)
+	OrphanNodes int       // Index nodes not mapped to a document (from failed inserts)
 	LastUpdate  time.Time // Last modification time
+
+	// Moving average of recent search latency, 0 if there has been no
+	// search for a minute
+	SearchLatency time.Duration
+
+	// Effective index parameters: M and EfConstruction as built, which
+	// Adaptive mode derives from the dimension and expected size, the ef of
+	// searches that set none (0 = max(200, 2k), see SetDefaultEF) and the
+	// distance function, as Metric returns it
+	M              int
+	EfConstruction int
+	DefaultEF      int
+	Metric         string
+
+	// Effective storage settings
+	CompressionLevel int                    // ZSTD level used for data and index files
+	EncoderConfig    encoding.EncoderConfig // Encoder selection thresholds
+
+	// No files are written: opened with OpenInMemory
+	InMemory bool
+
+	// When the collection was last saved, zero if it has not been since it
+	// was opened, and the inserts, updates and deletes made since then
+	LastSaveTime     time.Time
+	PendingMutations int
+
+	// Metadata indexes by field, see CreateMetadataIndex
+	MetadataIndexes []MetadataIndexStats
+
+	// Fsyncs of the write-ahead log, see WithWAL
+	WAL WALStats
+
+	// Sharded collections only: each shard, and the largest shard's
+	// document count over the mean of the available shards (1 = even)
+	Shards    []ShardStats
+	ShardSkew float64
 }
 
 // Stats returns collection statistics
 func (c *Collection) Stats() CollectionStats {
+	if c.shards != nil {
+		return c.shardStats()
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Count unique node IDs in mapping (all nodes ever created)
-	allNodes := make(map[int]bool)
-	for _, nodeID := range c.docToNode {
-		allNodes[nodeID] = true
-	}
-	totalIndexNodes := len(allNodes)
+	totalIndexNodes := c.index.Len()
 	docCount := len(c.docToNode)
+	m, efConstruction, defaultEF, _ := c.index.Params()
 
 	return CollectionStats{
 		Name:        c.name,
 		Count:       docCount,
 		Dimension:   c.dimension,
 		IndexNodes:  totalIndexNodes,
-		OrphanNodes: 0, // Will need HNSW API to accurately count
+		OrphanNodes: max(totalIndexNodes-docCount, 0),
 		LastUpdate:  time.Now(),
+
+		SearchLatency: c.latency.live(),
+
+		M:              m,
+		EfConstruction: efConstruction,
+		DefaultEF:      defaultEF,
+		Metric:         c.metric,
+
+		CompressionLevel: c.settings.CompressionLevel,
+		EncoderConfig:    c.settings.Encoder,
+		InMemory:         c.config.InMemory,
+
+		LastSaveTime:     c.lastSaveTime(),
+		PendingMutations: int(c.dirty.Load()),
+
+		MetadataIndexes: c.metaIndexes.stats(),
+
+		WAL: c.wal.walStats(),
 	}
 }
 
 // Save persists collection to disk
 func (c *Collection) Save() error {
+	if c.shards != nil {
+		return c.shardSave()
+	}
+
+	_, done, err := c.beginWrite(context.Background(), "Save")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return c.save()
+}
+
+// save is the internal save implementation. Writers are kept out at the
+// save gate, so the files are written holding mu only for reading and
+// searches keep running; mu is held for writing only to reap orphans and
+// to publish the save. Saves run one at a time.
+func (c *Collection) save() error {
+	c.saveGate.Lock()
+	defer c.saveGate.Unlock()
+	return c.saveGated()
+}
+
+// saveGated runs the phases of save. c.saveGate must be held for writing
+// and c.mu not at all.
+func (c *Collection) saveGated() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.reapBeforeSave()
+	c.mu.Unlock()
+
+	c.mu.RLock()
+	next, err := c.writeSave()
+	c.mu.RUnlock()
+	if err != nil || next == 0 {
+		return err
+	}
+
+	c.mu.Lock()
+	err = c.publishSave(next)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	c.finishSave(next)
+	return nil
+}
+
+// saveLocked saves the collection. c.mu must be held for writing.
+func (c *Collection) saveLocked() error {
+	c.reapBeforeSave()
+	next, err := c.writeSave()
+	if err != nil || next == 0 {
+		return err
+	}
+	if err := c.publishSave(next); err != nil {
+		return err
+	}
+	c.finishSave(next)
+	return nil
+}
+
+// reapBeforeSave reaps orphans so they are not written to disk. c.mu must
+// be held for writing.
+func (c *Collection) reapBeforeSave() {
+	if err := c.sweepOrphans(); err != nil {
+		log.Printf("Warning: orphan sweep of collection %s failed: %v", c.name, err)
+	}
+}
+
+// writeSave writes the files of the next save and returns its generation,
+// 0 if there is nothing to save. c.mu must be held, for reading at least
+// with writers kept out at the save gate.
+func (c *Collection) writeSave() (uint64, error) {
+	// An in-memory collection has nowhere to save to
+	if c.config.InMemory {
+		return 0, nil
+	}
+
+	// Mark the save in progress so replicas do not load a partial one. The
+	// write lock is the barrier: documents, index and mappings written below
+	// all belong to save next and are stamped with it.
+	next := c.generation + 1
+	if err := writeGeneration(c.path, generationInfo{Generation: next, Saving: true, Checkpoint: c.published}); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+	if err := saveStep("begin"); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+
+	// Flush document storage
+	if err := c.storage.flushCheckpoint(next); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+	if err := saveStep("documents"); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
 
 	// Save HNSW index
 	indexPath := filepath.Join(c.path, "index")
-	if err := c.index.SaveToLance(indexPath); err != nil {
-		return wrapError("Save", c.name, "", err)
+	if err := writeStamp(indexPath, next); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+	if err := c.index.SaveToLanceWithFactory(indexPath, c.factory); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+	if err := saveStep("index"); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
 	}
 
 	// Save mappings
 	mappingsPath := filepath.Join(c.path, "mappings.json")
-	if err := c.saveMappings(mappingsPath); err != nil {
-		return wrapError("Save", c.name, "", err)
+	if err := c.saveMappings(mappingsPath, next); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+	if err := saveStep("mappings"); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
 	}
 
-	// Flush document storage
-	if err := c.storage.Flush(); err != nil {
+	// Keep the matched set, then publish it
+	if err := c.linkSaveSet(next); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+	if err := saveStep("set"); err != nil {
+		return 0, wrapError("Save", c.name, "", err)
+	}
+	return next, nil
+}
+
+// publishSave publishes save next written by writeSave and resets the
+// journal it now contains. c.mu must be held for writing.
+func (c *Collection) publishSave(next uint64) error {
+	if err := writeGeneration(c.path, generationInfo{Generation: next, Checkpoint: next}); err != nil {
+		return wrapError("Save", c.name, "", err)
+	}
+	c.generation = next
+	c.published = next
+	if err := saveStep("manifest"); err != nil {
 		return wrapError("Save", c.name, "", err)
 	}
 
+	// The journal is in the save now; replaying it on top would be harmless
+	// but slow, and it would grow without bound
+	if err := c.wal.reset(next); err != nil {
+		return wrapError("Save", c.name, "", err)
+	}
+	c.dirty.Store(0)
+	c.lastSave.Store(time.Now().UnixNano())
 	return nil
 }
 
-// Close closes the collection
+// finishSave prunes old save sets and checkpoints the published save next.
+// The save is complete, so failures are only logged. c.mu must be held, for
+// reading at least with writers kept out at the save gate.
+func (c *Collection) finishSave(next uint64) {
+	if err := c.pruneSaveSets(); err != nil {
+		log.Printf("Warning: failed to prune save sets of collection %s: %v", c.name, err)
+	}
+
+	// A failed checkpoint only loses history
+	if c.config.CheckpointRetention > 0 {
+		if err := c.checkpoint(next); err != nil {
+			log.Printf("Warning: failed to checkpoint collection %s: %v", c.name, err)
+		}
+	}
+}
+
+// Close closes the collection. New operations fail with ErrCollectionClosed
+// immediately; in-flight operations are given up to Config.CloseTimeout to
+// finish before their contexts are cancelled. Pending data is then saved and
+// the storage is closed. Calling Close more than once is a no-op.
 func (c *Collection) Close() error {
-	// Auto-save on close
-	if err := c.Save(); err != nil {
+	c.lifeMu.Lock()
+	if c.closing {
+		c.lifeMu.Unlock()
+		return nil
+	}
+	c.closing = true
+	c.lifeMu.Unlock()
+
+	if c.shards != nil {
+		return c.shardClose()
+	}
+
+	c.drain()
+	c.stopTasks()
+
+	// Auto-save on close; a read-only collection has nothing to save
+	if !c.config.ReadOnly {
+		if err := c.save(); err != nil {
+			return err
+		}
+	}
+	if err := c.wal.close(); err != nil {
+		return err
+	}
+	if err := c.index.Close(); err != nil {
 		return err
 	}
 	return c.storage.Close()
 }
 
-// Drop removes the collection and all its data
+// drain waits for in-flight operations, cancelling them once the close
+// timeout expires.
+func (c *Collection) drain() {
+	drained := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(drained)
+	}()
+
+	timeout := c.config.CloseTimeout
+	if timeout <= 0 {
+		timeout = defaultCloseTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+	case <-timer.C:
+		c.cancelClose()
+		<-drained
+	}
+	c.cancelClose()
+}
+
+// Drop removes the collection and all its data. Operations in flight are
+// drained as by Close, but nothing is saved, and operations started on the
+// collection afterwards fail with ErrCollectionDropped. The directory is
+// moved to a trash directory before its files are deleted, so a crash
+// during Drop leaves the whole collection or none of it.
 func (c *Collection) Drop() error {
+	if c.config.ReadOnly {
+		return wrapError("Drop", c.name, "", ErrReadOnly)
+	}
+	if err := c.dropHandle(); err != nil {
+		return err
+	}
+	if c.config.InMemory {
+		return nil
+	}
+	if err := removeDir(c.path); err != nil {
+		return wrapError("Drop", c.name, "", err)
+	}
+	return nil
+}
+
+// dropHandle marks the collection dropped, so operations fail with
+// ErrCollectionDropped from now on, and releases it without saving: the
+// part of Drop that leaves the files alone. A closed collection has nothing
+// left to release.
+func (c *Collection) dropHandle() error {
+	c.lifeMu.Lock()
+	if c.dropped {
+		c.lifeMu.Unlock()
+		return wrapError("Drop", c.name, "", ErrCollectionDropped)
+	}
+	closed := c.closing
+	c.closing, c.dropped = true, true
+	c.lifeMu.Unlock()
+
+	if closed {
+		return nil
+	}
+	if c.shards != nil {
+		return c.shardDropHandle()
+	}
+
+	c.drain()
+	c.stopTasks()
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return os.RemoveAll(c.path)
+	if err := c.wal.close(); err != nil {
+		log.Printf("Warning: failed to close journal of collection %s: %v", c.name, err)
+	}
+	if err := c.index.Close(); err != nil {
+		log.Printf("Warning: failed to close index of collection %s: %v", c.name, err)
+	}
+	return nil
 }
 
-func (c *Collection) load() error {
+func (c *Collection) load(ctx context.Context) error {
+	generation, err := readGeneration(c.path)
+	if err != nil {
+		return wrapError("load", c.name, "", err)
+	}
+	c.generation = generation.Generation
+	c.published = generation.Checkpoint
+	if generation.Saving && generation.Checkpoint > 0 {
+		// A replica opened during a save holds the published one
+		c.generation = generation.Checkpoint
+	}
+
 	// Load HNSW index
-	indexPath := filepath.Join(c.path, "index")
-	if _, err := os.Stat(indexPath); err == nil {
-		loadedIndex, err := hnsw.LoadHNSWFromLance(indexPath)
-		if err != nil {
-			return wrapError("load", c.name, "", ErrIndexCorrupted)
+	indexPath := filepath.Join(c.dataDir, "index")
+	_, indexErr := os.Stat(indexPath)
+	if indexErr == nil {
+		loadedIndex, err := hnsw.LoadHNSWFromLance(indexPath,
+			hnsw.WithGraphStorage(c.config.GraphStorage), hnsw.WithLoadDistance(c.config.DistanceFunc))
+		if err == nil {
+			c.index = loadedIndex
+		} else {
+			indexErr = fmt.Errorf("%w: %v", ErrIndexCorrupted, err)
+		}
+	}
+
+	// Every vector is still in document storage, so a missing or unreadable
+	// index can be rebuilt instead of leaving the collection unusable
+	if indexErr != nil && c.storage.Stats().DocumentCount > 0 {
+		if c.config.DisableIndexRebuild {
+			if errors.Is(indexErr, ErrIndexCorrupted) {
+				return wrapError("load", c.name, "", ErrIndexCorrupted)
+			}
+		} else {
+			// Info does not depend on the index and outlives the rebuild
+			if err := c.loadInfo(filepath.Join(c.dataDir, "mappings.json")); err != nil && !os.IsNotExist(err) {
+				log.Printf("Warning: failed to load info of collection %s: %v", c.name, err)
+			}
+			return c.rebuildIndex(ctx, indexErr)
 		}
-		c.index = loadedIndex
 	}
 
 	// Load mappings
-	mappingsPath := filepath.Join(c.path, "mappings.json")
+	mappingsPath := filepath.Join(c.dataDir, "mappings.json")
 	if err := c.loadMappings(mappingsPath); err != nil && !os.IsNotExist(err) {
 		return wrapError("load", c.name, "", err)
 	}
@@ -643,10 +1724,25 @@ func (c *Collection) load() error {
 	return nil
 }
 
-func (c *Collection) saveMappings(path string) error {
+func (c *Collection) saveMappings(path string, checkpoint uint64) error {
+	orphans := make([]int, 0, len(c.orphans))
+	for nodeID := range c.orphans {
+		orphans = append(orphans, nodeID)
+	}
+	sort.Ints(orphans)
+
 	data := map[string]interface{}{
-		"docToNode": c.docToNode,
-		"nodeToDoc": c.nodeToDoc,
+		"checkpoint": checkpoint,
+		"docToNode":  c.docToNode,
+		"nodeToDoc":  c.nodeToDoc,
+		"orphans":    orphans,
+		"info":       c.info,
+	}
+	if c.text != nil {
+		data["text"] = c.text.snapshot()
+	}
+	if len(c.metaIndexes) > 0 {
+		data["metadata_indexes"] = c.metaIndexes.defs()
 	}
 
 	bytes, err := json.MarshalIndent(data, "", "  ")
@@ -654,7 +1750,7 @@ func (c *Collection) saveMappings(path string) error {
 		return err
 	}
 
-	if err := os.WriteFile(path, bytes, 0644); err != nil {
+	if err := lanceio.WriteFileAtomic(path, bytes, 0644); err != nil {
 		return err
 	}
 
@@ -692,9 +1788,75 @@ func (c *Collection) loadMappings(path string) error {
 		}
 	}
 
+	// Load info set with SetInfo and SetModel
+	c.setInfoFrom(mappings)
+
+	// Load orphans not yet reaped when the collection was saved
+	if orphansRaw, ok := mappings["orphans"].([]interface{}); ok {
+		for _, v := range orphansRaw {
+			if nodeID, ok := v.(float64); ok {
+				c.orphans[int(nodeID)] = struct{}{}
+			}
+		}
+	}
+
+	// Load the text index, rebuilding it if it was not saved
+	if c.text != nil {
+		var saved struct {
+			Text *textSnapshot `json:"text"`
+		}
+		if err := json.Unmarshal(data, &saved); err != nil || !c.text.restore(saved.Text) {
+			if err := c.rebuildTextIndex(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Load the metadata indexes and build their entries
+	var saved struct {
+		Indexes []metadataIndexDef `json:"metadata_indexes"`
+	}
+	if err := json.Unmarshal(data, &saved); err == nil {
+		for _, def := range saved.Indexes {
+			typ, ok := parseIndexType(def.Type)
+			if !ok || def.Field == "" || c.metaIndexes[def.Field] != nil {
+				continue
+			}
+			if c.metaIndexes == nil {
+				c.metaIndexes = make(metadataIndexes)
+			}
+			c.metaIndexes[def.Field] = newMetadataIndex(def.Field, typ)
+		}
+	}
+	return c.rebuildMetadataIndexes()
+}
+
+// loadInfo loads only the info of the mappings file at path
+func (c *Collection) loadInfo(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var mappings map[string]interface{}
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return ErrIndexCorrupted
+	}
+	c.setInfoFrom(mappings)
 	return nil
 }
 
+// setInfoFrom loads the info set with SetInfo and SetModel from decoded
+// mappings
+func (c *Collection) setInfoFrom(mappings map[string]interface{}) {
+	if infoRaw, ok := mappings["info"].(map[string]interface{}); ok {
+		for k, v := range infoRaw {
+			if value, ok := v.(string); ok {
+				c.info[k] = value
+			}
+		}
+	}
+}
+
 // parseIntKey converts string key to int (JSON only supports string keys)
 func parseIntKey(s string) (int, bool) {
 	var i int
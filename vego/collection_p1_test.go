@@ -464,11 +464,11 @@ func TestCollectionEmptyOperations(t *testing.T) {
 	coll, cleanup := setupTestCollection(t)
 	defer cleanup()
 
-	// Search on empty collection - should return error
+	// Search on empty collection - no results, no error
 	query := make([]float32, 64)
-	_, err := coll.Search(query, 10)
-	if err == nil {
-		t.Error("Expected error searching empty collection")
+	results, err := coll.Search(query, 10)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected no results searching empty collection, got %v, %v", results, err)
 	}
 
 	// Get non-existent
@@ -2,11 +2,16 @@ package vego
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
 )
 
 // setupTestCollection creates a test collection with cleanup
@@ -342,6 +347,21 @@ func TestCollectionSearch(t *testing.T) {
 		}
 	})
 	
+	t.Run("Search with wide upper layers", func(t *testing.T) {
+		query := make([]float32, 64)
+		for i := range query {
+			query[i] = float32(i) * 0.01
+		}
+
+		results, err := coll.SearchContext(context.Background(), query, 5, WithEFUpperLayers(8))
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 5 || results[0].Document.ID != "search_doc_0" {
+			t.Errorf("Expected 5 results led by search_doc_0, got %d", len(results))
+		}
+	})
+
 	t.Run("Search with wrong dimension", func(t *testing.T) {
 		query := make([]float32, 32) // Wrong dimension
 		
@@ -364,6 +384,358 @@ func TestCollectionSearch(t *testing.T) {
 	})
 }
 
+// TestCollectionSearchVectors tests the vector payload of search results
+func TestCollectionSearchVectors(t *testing.T) {
+	coll, cleanup := setupTestCollection(t)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		doc := createTestDocument(fmt.Sprintf("vec_doc_%d", i), 64, nil)
+		for j := range doc.Vector {
+			doc.Vector[j] = float32(j+i) * 0.01
+		}
+		if err := coll.Insert(doc); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	query := make([]float32, 64)
+	for i := range query {
+		query[i] = float32(i) * 0.01
+	}
+
+	t.Run("Vectors omitted by default", func(t *testing.T) {
+		results, err := coll.Search(query, 5)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, r := range results {
+			if r.Document.Vector != nil {
+				t.Errorf("Expected no vector for %s, got %d values", r.Document.ID, len(r.Document.Vector))
+			}
+		}
+	})
+
+	t.Run("WithVectors populates copies", func(t *testing.T) {
+		results, err := coll.Search(query, 5, WithVectors(true))
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) == 0 {
+			t.Fatal("Expected search results, got none")
+		}
+
+		top := results[0].Document
+		if top.ID != "vec_doc_0" {
+			t.Fatalf("Expected vec_doc_0 as top result, got %s", top.ID)
+		}
+		if len(top.Vector) != 64 {
+			t.Fatalf("Expected 64-dim vector, got %d", len(top.Vector))
+		}
+		for j, v := range top.Vector {
+			if v != query[j] {
+				t.Fatalf("Vector[%d]: expected %v, got %v", j, query[j], v)
+			}
+		}
+
+		// Mutating the returned vector must not affect the index
+		for j := range top.Vector {
+			top.Vector[j] = -1
+		}
+		again, err := coll.Search(query, 1, WithVectors(true))
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if again[0].Document.ID != "vec_doc_0" || again[0].Distance != results[0].Distance {
+			t.Errorf("Index changed after mutating result vector: got %s at %v",
+				again[0].Document.ID, again[0].Distance)
+		}
+		if again[0].Document.Vector[0] != query[0] {
+			t.Errorf("Result vector aliases internal storage: got %v", again[0].Document.Vector[0])
+		}
+	})
+
+	t.Run("WithSearchVectors restores previous default", func(t *testing.T) {
+		coll.config.SearchVectors = true
+		defer func() { coll.config.SearchVectors = false }()
+
+		results, err := coll.Search(query, 3)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, r := range results {
+			if len(r.Document.Vector) != 64 {
+				t.Errorf("Expected vector for %s, got %d values", r.Document.ID, len(r.Document.Vector))
+			}
+		}
+
+		results, err = coll.Search(query, 3, WithVectors(false))
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, r := range results {
+			if r.Document.Vector != nil {
+				t.Errorf("Expected WithVectors(false) to strip vector for %s", r.Document.ID)
+			}
+		}
+	})
+}
+
+// TestCollectionSearchKBounds tests k validation across search entry points
+func TestCollectionSearchIDs(t *testing.T) {
+	coll, cleanup := setupTestCollection(t)
+	defer cleanup()
+
+	docs := make([]*Document, 200)
+	for i := range docs {
+		category := "A"
+		if i%3 == 0 {
+			category = "B"
+		}
+		docs[i] = createTestDocument(fmt.Sprintf("ids_doc_%d", i), 64, map[string]interface{}{"category": category})
+		for j := range docs[i].Vector {
+			docs[i].Vector[j] = float32((i*7+j*13)%101) * 0.01
+		}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+	// Leave an orphaned node behind, which both APIs must skip
+	updated := docs[5].Clone()
+	updated.Vector[0] += 0.5
+	if err := coll.Update(updated); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+
+	ctx := context.Background()
+	filter := &MetadataFilter{Field: "category", Operator: "eq", Value: "B"}
+
+	for q := 0; q < 5; q++ {
+		query := docs[q*31].Vector
+
+		full, err := coll.Search(query, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		ids, err := coll.SearchIDs(ctx, query, 10, nil)
+		if err != nil {
+			t.Fatalf("SearchIDs failed: %v", err)
+		}
+		assertSameHits(t, full, ids)
+
+		full, err = coll.SearchWithFilter(query, 10, filter)
+		if err != nil {
+			t.Fatalf("SearchWithFilter failed: %v", err)
+		}
+		ids, err = coll.SearchIDs(ctx, query, 10, filter)
+		if err != nil {
+			t.Fatalf("SearchIDs with filter failed: %v", err)
+		}
+		assertSameHits(t, full, ids)
+		for _, r := range full {
+			if r.Document.Metadata["category"] != "B" {
+				t.Errorf("Filtered result %s has category %v", r.Document.ID, r.Document.Metadata["category"])
+			}
+		}
+	}
+
+	if _, err := coll.SearchIDs(ctx, docs[0].Vector, 0, nil); !IsInvalidK(err) {
+		t.Errorf("Expected ErrInvalidK for k=0, got %v", err)
+	}
+	if _, err := coll.SearchIDs(ctx, []float32{1, 2}, 10, nil); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+// assertSameHits checks that SearchIDs returned the same IDs, in the same
+// order and with the same distances, as a full search
+func assertSameHits(t *testing.T, full []SearchResult, ids []IDResult) {
+	t.Helper()
+	if len(full) != len(ids) {
+		t.Fatalf("Expected %d hits, got %d", len(full), len(ids))
+	}
+	for i := range full {
+		if full[i].Document.ID != ids[i].DocID || full[i].Distance != ids[i].Distance {
+			t.Errorf("Hit %d: expected %s at %v, got %s at %v",
+				i, full[i].Document.ID, full[i].Distance, ids[i].DocID, ids[i].Distance)
+		}
+	}
+}
+
+func TestCollectionSearchKBounds(t *testing.T) {
+	query := make([]float32, 64)
+	matchAll := &MetadataFilter{Field: "kind", Operator: "eq", Value: "doc"}
+
+	searchers := map[string]func(*Collection, int) ([]SearchResult, error){
+		"Search": func(c *Collection, k int) ([]SearchResult, error) {
+			return c.Search(query, k)
+		},
+		"SearchContext": func(c *Collection, k int) ([]SearchResult, error) {
+			return c.SearchContext(context.Background(), query, k, WithEF(1))
+		},
+		"SearchWithFilter": func(c *Collection, k int) ([]SearchResult, error) {
+			return c.SearchWithFilter(query, k, matchAll)
+		},
+		"SearchBatch": func(c *Collection, k int) ([]SearchResult, error) {
+			results, err := c.SearchBatch([][]float32{query}, k)
+			if err != nil {
+				return nil, err
+			}
+			return results[0], nil
+		},
+	}
+
+	tests := []struct {
+		name    string
+		n       int
+		k       int
+		want    int
+		wantErr bool
+		invalid bool
+	}{
+		{"empty collection", 0, 5, 0, false, false},
+		{"k zero", 10, 0, 0, true, true},
+		{"k negative", 10, -1, 0, true, true},
+		{"single document", 1, 1, 1, false, false},
+		{"single document k > n", 1, 5, 1, false, false},
+		{"k == n", 20, 20, 20, false, false},
+		{"k == n+1", 20, 21, 20, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll, cleanup := setupTestCollection(t)
+			defer cleanup()
+
+			for i := 0; i < tt.n; i++ {
+				doc := createTestDocument(fmt.Sprintf("k_doc_%d", i), 64, map[string]interface{}{"kind": "doc"})
+				doc.Vector[0] = float32(i)
+				if err := coll.Insert(doc); err != nil {
+					t.Fatalf("Failed to insert document: %v", err)
+				}
+			}
+
+			for name, search := range searchers {
+				results, err := search(coll, tt.k)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("%s: expected error %v, got %v", name, tt.wantErr, err)
+				}
+				if tt.invalid && !IsInvalidK(err) {
+					t.Errorf("%s: expected ErrInvalidK, got %v", name, err)
+				}
+				if len(results) != tt.want {
+					t.Errorf("%s: expected %d results, got %d", name, tt.want, len(results))
+				}
+			}
+		})
+	}
+}
+
+// TestCollectionSearchBatchPerQueryErrors tests that batch search reports
+// failures per query
+func TestCollectionSearchBatchPerQueryErrors(t *testing.T) {
+	coll, cleanup := setupTestCollection(t)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		if err := coll.Insert(createTestDocument(fmt.Sprintf("batch_doc_%d", i), 64, nil)); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	queries := [][]float32{make([]float32, 64), make([]float32, 8), make([]float32, 64)}
+	results, err := coll.SearchBatch(queries, 3)
+	if err == nil {
+		t.Fatal("Expected error for wrong-dimension query")
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected *BatchError, got %T: %v", err, err)
+	}
+	if len(batchErr.Errors) != 3 || batchErr.Errors[0] != nil || batchErr.Errors[2] != nil {
+		t.Fatalf("Expected only query 1 to fail, got %v", batchErr.Errors)
+	}
+	if !IsDimensionMismatch(batchErr.Errors[1]) {
+		t.Errorf("Expected dimension mismatch for query 1, got %v", batchErr.Errors[1])
+	}
+	if len(results[0]) != 3 || len(results[2]) != 3 || results[1] != nil {
+		t.Errorf("Expected results for queries 0 and 2 only, got %d/%d/%d",
+			len(results[0]), len(results[1]), len(results[2]))
+	}
+
+	if _, err := coll.SearchBatch(queries[:1], 0); !IsInvalidK(err) {
+		t.Errorf("Expected ErrInvalidK from batch, got %v", err)
+	}
+}
+
+// TestCollectionSearchBatchContext tests per-query errors, the concurrency
+// limit and cancellation of batch search
+func TestCollectionSearchBatchContext(t *testing.T) {
+	coll, cleanup := setupTestCollection(t)
+	defer cleanup()
+
+	for i := 0; i < 20; i++ {
+		if err := coll.Insert(createTestDocument(fmt.Sprintf("batch_doc_%d", i), 64, nil)); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	queries := make([][]float32, 100)
+	for i := range queries {
+		queries[i] = make([]float32, 64)
+		queries[i][i%64] = 1
+	}
+	queries[13] = make([]float32, 8)
+	queries[47] = make([]float32, 65)
+
+	// Count the searches in flight through an enricher, which runs
+	// without the collection's lock
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	enricher := func(ctx context.Context, ids []string) (map[string]map[string]interface{}, error) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil, nil
+	}
+
+	results, errs := coll.SearchBatchContext(context.Background(), queries, 3,
+		WithMaxConcurrency(2), WithBatchSearchOptions(WithEnrichment(enricher)))
+	if len(results) != 100 || len(errs) != 100 {
+		t.Fatalf("Expected 100 slots, got %d results and %d errors", len(results), len(errs))
+	}
+	for i := range queries {
+		if i == 13 || i == 47 {
+			if !IsDimensionMismatch(errs[i]) || results[i] != nil {
+				t.Errorf("Query %d: expected dimension mismatch, got %v", i, errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil || len(results[i]) != 3 {
+			t.Errorf("Query %d: got %d results, %v", i, len(results[i]), errs[i])
+		}
+	}
+	if peak < 1 || peak > 2 {
+		t.Errorf("Expected at most 2 concurrent searches, saw %d", peak)
+	}
+
+	// Queries of a cancelled batch are abandoned
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, errs = coll.SearchBatchContext(ctx, queries, 3)
+	for i := range queries {
+		if !errors.Is(errs[i], context.Canceled) || results[i] != nil {
+			t.Fatalf("Query %d of cancelled batch: got %v", i, errs[i])
+		}
+	}
+}
+
 // TestCollectionBatchOperations tests batch operations
 func TestCollectionBatchOperations(t *testing.T) {
 	coll, cleanup := setupTestCollection(t)
@@ -559,3 +931,230 @@ func TestCollectionSaveAndClose(t *testing.T) {
 }
 
 
+
+// TestCollectionUpdateVector tests that Update re-indexes changed vectors
+// and leaves the document untouched when it fails
+func TestCollectionUpdateVector(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		doc := &Document{ID: fmt.Sprint(i), Vector: []float32{float32(i), 0, 0, 0}, Metadata: map[string]interface{}{"v": 1}}
+		if err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	nearest := func(query []float32) string {
+		t.Helper()
+		results, err := coll.Search(query, 1)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("Search = %v, %v", results, err)
+		}
+		return results[0].Document.ID
+	}
+
+	// A metadata-only update keeps the node
+	node := coll.docToNode["5"]
+	if err := coll.Update(&Document{ID: "5", Vector: []float32{5, 0, 0, 0}, Metadata: map[string]interface{}{"v": 2}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if coll.docToNode["5"] != node {
+		t.Error("metadata-only update re-indexed the vector")
+	}
+
+	// A new vector replaces the node, and searches find it there
+	if err := coll.Update(&Document{ID: "5", Vector: []float32{100, 0, 0, 0}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := nearest([]float32{100, 0, 0, 0}); got != "5" {
+		t.Errorf("nearest to the new vector = %s, want 5", got)
+	}
+	if got := nearest([]float32{5, 0, 0, 0}); got == "5" {
+		t.Error("old vector still found")
+	}
+	if stats := coll.Stats(); stats.IndexNodes != 20 || stats.OrphanNodes != 0 {
+		t.Errorf("after update: %+v", stats)
+	}
+
+	// Failed updates leave the document and its node as they were
+	if err := coll.Update(&Document{ID: "missing", Vector: []float32{1, 2, 3, 4}}); !IsNotFound(err) {
+		t.Errorf("update of missing document = %v, want ErrDocumentNotFound", err)
+	}
+	node = coll.docToNode["7"]
+	if err := coll.Update(&Document{ID: "7", Vector: []float32{1, 2}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("update with wrong dimension = %v, want ErrDimensionMismatch", err)
+	}
+	setStorageFailing(coll.storage, true)
+	err = coll.Update(&Document{ID: "7", Vector: []float32{-50, 0, 0, 0}})
+	setStorageFailing(coll.storage, false)
+	if err == nil {
+		t.Fatal("update with failing storage succeeded")
+	}
+	if coll.docToNode["7"] != node {
+		t.Error("failed update remapped the document")
+	}
+	if stored, err := coll.Get("7"); err != nil || !vectorsEqual(stored.Vector, []float32{7, 0, 0, 0}) {
+		t.Errorf("stored after failed update = %v, %v", stored, err)
+	}
+	if got := nearest([]float32{-50, 0, 0, 0}); got != "0" {
+		t.Errorf("nearest to the failed vector = %s, want 0", got)
+	}
+	if stats := coll.Stats(); stats.IndexNodes != 20 {
+		t.Errorf("after failed updates: %+v", stats)
+	}
+}
+
+// TestCollectionCosineVectors checks that a cosine collection, whose index
+// keeps vectors normalized, returns them as they were inserted and keeps the
+// node on a metadata-only update
+func TestCollectionCosineVectors(t *testing.T) {
+	db, err := OpenInMemory(WithDimension(4), WithDistanceFunc(hnsw.CosineDistance), WithSearchVectors(true))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		doc := &Document{ID: fmt.Sprint(i), Vector: []float32{3, 4, float32(i), 0}, Metadata: map[string]interface{}{"v": 1}}
+		if err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	query := []float32{3, 4, 5, 0}
+	check := func(name string, results []SearchResult, err error) {
+		t.Helper()
+		if err != nil || len(results) == 0 {
+			t.Fatalf("%s = %v, %v", name, results, err)
+		}
+		for _, r := range results {
+			var i int
+			fmt.Sscan(r.Document.ID, &i)
+			if want := []float32{3, 4, float32(i), 0}; !vectorsEqual(r.Document.Vector, want) {
+				t.Errorf("%s: %s has vector %v, want %v", name, r.Document.ID, r.Document.Vector, want)
+			}
+		}
+	}
+	results, err := coll.Search(query, 3)
+	check("Search", results, err)
+	results, err = coll.SearchWithFilter(query, 3, &MetadataFilter{Field: "v", Operator: "eq", Value: 1})
+	check("SearchWithFilter", results, err)
+	filtered, err := coll.SearchFiltered(context.Background(), query, 3, &MetadataFilter{Field: "v", Operator: "eq", Value: 1})
+	if err != nil {
+		t.Fatalf("SearchFiltered failed: %v", err)
+	}
+	check("SearchFiltered", filtered.Results, nil)
+	hits, err := coll.SearchStream(context.Background(), query, StreamOptions{K: 3, Vectors: true})
+	if err != nil {
+		t.Fatalf("SearchStream failed: %v", err)
+	}
+	results = results[:0]
+	for hit := range hits {
+		results = append(results, SearchResult{Document: hit.Document})
+	}
+	check("SearchStream", results, nil)
+
+	// The stored vector is not unit length, yet the update keeps the node
+	node := coll.docToNode["5"]
+	if err := coll.Update(&Document{ID: "5", Vector: []float32{3, 4, 5, 0}, Metadata: map[string]interface{}{"v": 2}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if coll.docToNode["5"] != node {
+		t.Error("metadata-only update re-indexed the vector")
+	}
+	if stats := coll.Stats(); stats.IndexNodes != 10 {
+		t.Errorf("after update: %d index nodes, want 10", stats.IndexNodes)
+	}
+}
+
+// TestCollectionQuantizedUpdate checks that a metadata-only update keeps the
+// node when the index keeps its vectors as ScalarQuant8 codes, so Vector
+// returns them decoded rather than as inserted
+func TestCollectionQuantizedUpdate(t *testing.T) {
+	db, err := OpenInMemory(WithDimension(4))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	config := indexConfig(coll.config)
+	config.Quantization = hnsw.ScalarQuant8
+	if coll.index, err = hnsw.NewHNSWChecked(config); err != nil {
+		t.Fatalf("NewHNSWChecked failed: %v", err)
+	}
+
+	vector := []float32{0.1, -0.37, 0.52, 0.9}
+	if err := coll.Insert(&Document{ID: "a", Vector: vector, Metadata: map[string]interface{}{"v": 1}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if stored, err := coll.index.Vector(coll.docToNode["a"]); err != nil || vectorsEqual(stored, vector) {
+		t.Fatalf("index vector %v, %v; want it quantized", stored, err)
+	}
+
+	node := coll.docToNode["a"]
+	if err := coll.Update(&Document{ID: "a", Vector: vector, Metadata: map[string]interface{}{"v": 2}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if coll.docToNode["a"] != node {
+		t.Error("metadata-only update re-indexed the vector")
+	}
+	if stats := coll.Stats(); stats.IndexNodes != 1 {
+		t.Errorf("after update: %d index nodes, want 1", stats.IndexNodes)
+	}
+}
+
+func TestCollectionDefaultEF(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(16), WithAdaptive(true), WithExpectedSize(1000))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.InsertBatch(barrierDocs(rand.New(rand.NewSource(3)), 0, 100)); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	stats := coll.Stats()
+	m, efConstruction, _, _ := coll.index.Params()
+	if stats.M != m || stats.EfConstruction != efConstruction || stats.M == 0 || stats.DefaultEF != 0 || stats.Metric != MetricL2 {
+		t.Fatalf("Stats() = M %d, EfConstruction %d, DefaultEF %d, Metric %q", stats.M, stats.EfConstruction, stats.DefaultEF, stats.Metric)
+	}
+
+	if err := coll.SetDefaultEF(24); err != nil {
+		t.Fatalf("SetDefaultEF failed: %v", err)
+	}
+	if got := coll.Stats().DefaultEF; got != 24 {
+		t.Errorf("DefaultEF = %d, want 24", got)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(path, WithDimension(16))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if stats := coll.Stats(); stats.DefaultEF != 24 || stats.M != m || stats.EfConstruction != efConstruction {
+		t.Errorf("after reopening: M %d, EfConstruction %d, DefaultEF %d", stats.M, stats.EfConstruction, stats.DefaultEF)
+	}
+}
@@ -0,0 +1,251 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CompareOptions contains options for CompareIndexes
+type CompareOptions struct {
+	GroupField string // Metadata field whose values group the report, "" = no groups
+	EF         int    // Search scope for the neighbor lookups (0 = default)
+}
+
+// CompareOption is a functional option for CompareIndexes
+type CompareOption func(*CompareOptions)
+
+// WithGroupField breaks the overlap report down by the value of a metadata
+// field of the sampled documents, as stored in the first collection
+func WithGroupField(field string) CompareOption {
+	return func(o *CompareOptions) {
+		o.GroupField = field
+	}
+}
+
+// WithCompareEF sets the search scope used to find each sample's neighbors
+func WithCompareEF(ef int) CompareOption {
+	return func(o *CompareOptions) {
+		o.EF = ef
+	}
+}
+
+// OverlapStats summarizes neighbor overlap over a set of sampled documents
+type OverlapStats struct {
+	Documents int     `json:"documents"` // Samples compared
+	Jaccard   float64 `json:"jaccard"`   // Mean Jaccard overlap of the two neighbor sets
+
+	// RankCorrelation is the mean Spearman correlation between the ranks of
+	// the neighbors both lists share. Only samples sharing at least two
+	// neighbors have one; Correlated counts them.
+	RankCorrelation float64 `json:"rank_correlation"`
+	Correlated      int     `json:"correlated"`
+}
+
+// OverlapReport is the result of CompareIndexes. It encodes to JSON as is.
+type OverlapReport struct {
+	K       int      `json:"k"`
+	Missing []string `json:"missing,omitempty"` // Sample IDs absent from either collection
+	OverlapStats
+
+	// Groups breaks the stats down by the value of the group field; samples
+	// without the field are only counted in the totals
+	GroupField string                  `json:"group_field,omitempty"`
+	Groups     map[string]OverlapStats `json:"groups,omitempty"`
+}
+
+// CompareIndexes measures how much the neighborhoods of the same documents
+// differ between two collections, e.g. the same corpus embedded by two
+// models. For each sample ID present in both, its k nearest neighbors are
+// found in each collection with SearchSimilarTo and compared by document
+// ID, so the collections may have different dimensions. Nil sampleIDs
+// samples every document of a that b also holds.
+func CompareIndexes(ctx context.Context, a, b *Collection, sampleIDs []string, k int, opts ...CompareOption) (*OverlapReport, error) {
+	if k <= 0 {
+		return nil, wrapError("CompareIndexes", a.name, "", ErrInvalidK)
+	}
+
+	options := &CompareOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	var searchOpts []SearchOption
+	if options.EF > 0 {
+		searchOpts = append(searchOpts, WithEF(options.EF))
+	}
+
+	if sampleIDs == nil {
+		sampleIDs = sharedIDs(a, b)
+	}
+
+	report := &OverlapReport{K: k, GroupField: options.GroupField}
+	total := &overlapSum{}
+	groups := make(map[string]*overlapSum)
+
+	for _, id := range sampleIDs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		na, err := neighborIDs(ctx, a, id, k, searchOpts)
+		if IsNotFound(err) {
+			report.Missing = append(report.Missing, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		nb, err := neighborIDs(ctx, b, id, k, searchOpts)
+		if IsNotFound(err) {
+			report.Missing = append(report.Missing, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		jaccard := jaccardOverlap(na, nb)
+		rho, ok := rankCorrelation(na, nb)
+		total.add(jaccard, rho, ok)
+
+		if options.GroupField == "" {
+			continue
+		}
+		doc, err := a.GetContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if value, exists := doc.Metadata[options.GroupField]; exists {
+			key := fmt.Sprint(value)
+			if groups[key] == nil {
+				groups[key] = &overlapSum{}
+			}
+			groups[key].add(jaccard, rho, ok)
+		}
+	}
+
+	report.OverlapStats = total.stats()
+	if options.GroupField != "" {
+		report.Groups = make(map[string]OverlapStats, len(groups))
+		for key, sum := range groups {
+			report.Groups[key] = sum.stats()
+		}
+	}
+	return report, nil
+}
+
+// sharedIDs returns the IDs of the documents in both a and b, sorted
+func sharedIDs(a, b *Collection) []string {
+	a.mu.RLock()
+	ids := make([]string, 0, len(a.docToNode))
+	for id := range a.docToNode {
+		ids = append(ids, id)
+	}
+	a.mu.RUnlock()
+
+	b.mu.RLock()
+	shared := ids[:0]
+	for _, id := range ids {
+		if _, ok := b.docToNode[id]; ok {
+			shared = append(shared, id)
+		}
+	}
+	b.mu.RUnlock()
+
+	sort.Strings(shared)
+	return shared
+}
+
+// neighborIDs returns the IDs of the k nearest neighbors of document id in c,
+// nearest first
+func neighborIDs(ctx context.Context, c *Collection, id string, k int, opts []SearchOption) ([]string, error) {
+	results, err := c.SearchSimilarTo(ctx, id, k, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Document.ID
+	}
+	return ids, nil
+}
+
+// jaccardOverlap returns |a ∩ b| / |a ∪ b|, 1 for two empty lists
+func jaccardOverlap(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	inA := make(map[string]bool, len(a))
+	for _, id := range a {
+		inA[id] = true
+	}
+	shared := 0
+	for _, id := range b {
+		if inA[id] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// rankCorrelation returns the Spearman correlation between the orders in
+// which a and b list the IDs they share, and false if they share fewer than
+// two
+func rankCorrelation(a, b []string) (float64, bool) {
+	rankB := make(map[string]int, len(b))
+	for i, id := range b {
+		rankB[id] = i
+	}
+
+	// Shared IDs in a's order, with their position in b
+	var positions []int
+	for _, id := range a {
+		if r, ok := rankB[id]; ok {
+			positions = append(positions, r)
+		}
+	}
+	n := len(positions)
+	if n < 2 {
+		return 0, false
+	}
+
+	// Re-rank the positions in b among the shared IDs
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return positions[order[i]] < positions[order[j]] })
+
+	var d2 float64
+	for rank, i := range order {
+		d := float64(i - rank)
+		d2 += d * d
+	}
+	return 1 - 6*d2/float64(n*(n*n-1)), true
+}
+
+// overlapSum accumulates OverlapStats
+type overlapSum struct {
+	documents, correlated int
+	jaccard, rho          float64
+}
+
+func (s *overlapSum) add(jaccard, rho float64, correlated bool) {
+	s.documents++
+	s.jaccard += jaccard
+	if correlated {
+		s.correlated++
+		s.rho += rho
+	}
+}
+
+func (s *overlapSum) stats() OverlapStats {
+	stats := OverlapStats{Documents: s.documents, Correlated: s.correlated}
+	if s.documents > 0 {
+		stats.Jaccard = s.jaccard / float64(s.documents)
+	}
+	if s.correlated > 0 {
+		stats.RankCorrelation = s.rho / float64(s.correlated)
+	}
+	return stats
+}
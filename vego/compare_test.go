@@ -0,0 +1,149 @@
+package vego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// compareCollection opens a collection of the given dimension in its own
+// database and inserts n documents with vectors from vector
+func compareCollection(t *testing.T, dim, n int, vector func(i int) []float32) *Collection {
+	t.Helper()
+	db, err := Open(t.TempDir(), WithDimension(dim))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	docs := make([]*Document, n)
+	for i := range docs {
+		docs[i] = &Document{
+			ID:       fmt.Sprintf("doc_%03d", i),
+			Vector:   vector(i),
+			Metadata: map[string]interface{}{"category": []string{"red", "green", "blue"}[i%3]},
+		}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	return coll
+}
+
+func TestCompareIndexes(t *testing.T) {
+	ctx := context.Background()
+	const n = 300
+
+	rng := rand.New(rand.NewSource(1))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, 16)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.Float32()
+		}
+	}
+	same := func(i int) []float32 { return vectors[i] }
+	random := func(int) []float32 {
+		v := make([]float32, 24)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		return v
+	}
+
+	a := compareCollection(t, 16, n, same)
+	b := compareCollection(t, 16, n, same)
+	c := compareCollection(t, 24, n, random)
+
+	tests := []struct {
+		name       string
+		other      *Collection
+		minJaccard float64
+		maxJaccard float64
+	}{
+		{"same vectors", b, 0.99, 1},
+		{"independent vectors", c, 0, 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := CompareIndexes(ctx, a, tt.other, nil, 10, WithGroupField("category"))
+			if err != nil {
+				t.Fatalf("CompareIndexes failed: %v", err)
+			}
+			if report.Documents != n || len(report.Missing) != 0 {
+				t.Fatalf("compared %d documents with %d missing, want %d and 0", report.Documents, len(report.Missing), n)
+			}
+			if report.Jaccard < tt.minJaccard || report.Jaccard > tt.maxJaccard {
+				t.Errorf("Jaccard = %.3f, want in [%.2f, %.2f]", report.Jaccard, tt.minJaccard, tt.maxJaccard)
+			}
+			if tt.minJaccard > 0.9 && report.RankCorrelation < 0.99 {
+				t.Errorf("RankCorrelation = %.3f for identical vectors", report.RankCorrelation)
+			}
+
+			sum := 0
+			for _, g := range []string{"red", "green", "blue"} {
+				stats, ok := report.Groups[g]
+				if !ok {
+					t.Fatalf("missing group %q", g)
+				}
+				sum += stats.Documents
+			}
+			if sum != n {
+				t.Errorf("groups cover %d documents, want %d", sum, n)
+			}
+
+			data, err := json.Marshal(report)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var decoded OverlapReport
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if decoded.Jaccard != report.Jaccard || len(decoded.Groups) != 3 {
+				t.Errorf("report did not round-trip through JSON: %s", data)
+			}
+		})
+	}
+
+	// Explicit samples absent from one side are reported, not compared
+	if err := b.Delete("doc_007"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	report, err := CompareIndexes(ctx, a, b, []string{"doc_007", "doc_008", "nope"}, 5)
+	if err != nil {
+		t.Fatalf("CompareIndexes failed: %v", err)
+	}
+	if report.Documents != 1 || len(report.Missing) != 2 || report.Groups != nil {
+		t.Errorf("got %d compared, missing %v, groups %v; want 1, [doc_007 nope], nil", report.Documents, report.Missing, report.Groups)
+	}
+
+	if _, err := CompareIndexes(ctx, a, b, nil, 0); !IsInvalidK(err) {
+		t.Errorf("expected ErrInvalidK for k=0, got %v", err)
+	}
+}
+
+func TestRankCorrelation(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want float64
+		ok   bool
+	}{
+		{[]string{"x", "y", "z"}, []string{"x", "y", "z"}, 1, true},
+		{[]string{"x", "y", "z"}, []string{"z", "y", "x"}, -1, true},
+		{[]string{"x", "y", "q"}, []string{"p", "y", "x"}, -1, true},
+		{[]string{"x", "y"}, []string{"x", "z"}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := rankCorrelation(tt.a, tt.b)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("rankCorrelation(%v, %v) = %v, %v; want %v, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package vego
 
 import (
+	"time"
+
 	hnsw "github.com/wzqhbustb/vego/index"
+	"github.com/wzqhbustb/vego/storage/encoding"
 )
 
 // Config holds database configuration
@@ -15,11 +18,83 @@ type Config struct {
 	ExpectedSize   int
 
 	// Storage configuration
-	CompressionLevel int // 1-9 for ZSTD
-	PageSize         int // Default 1MB
+	CompressionLevel int                     // ZSTD level 1-22, 0 = default 3; fixed per collection at creation
+	EncoderConfig    *encoding.EncoderConfig // Encoder selection thresholds, nil = defaults; fixed per collection at creation
+	PageSize         int                     // Default 1MB
+	GraphStorage     hnsw.GraphStorage       // Graph placement for loaded indexes, default InMemory
+
+	// Validation configuration
+	VectorConstraints *Constraints // Limits on inserted and query vectors, nil = none; fixed per collection at creation
+
+	// Text search: a metadata string field indexed for HybridSearch, see
+	// WithTextIndex
+	TextIndexField string // "" = no text index; fixed per collection at creation
+
+	// Recovery configuration
+	DisableIndexRebuild bool // Don't rebuild a missing or corrupt index from document storage on open, default false
+
+	// Auto-save: a background save runs once AutoSaveInterval has passed
+	// or AutoSaveThreshold mutations are unsaved, whichever comes first,
+	// see autosave.go
+	AutoSaveInterval  time.Duration // 0 = disabled, or every minute with a threshold
+	AutoSaveThreshold int           // Unsaved mutations that trigger a save, 0 = none
+
+	// Orphan sweeping: nodes of inserts whose storage write failed are
+	// reaped at every Save and, if set, at this interval
+	OrphanSweepInterval time.Duration // 0 = sweep only at Save
+
+	// Background index optimization: every AutoOptimizeInterval, neighbor
+	// lists are re-pruned within the bounds of AutoOptimize
+	AutoOptimizeInterval time.Duration        // 0 = disabled
+	AutoOptimize         hnsw.OptimizeOptions // Bounds of each pass
+
+	// Retention: documents beyond the policy's age or count limits are
+	// deleted every RetentionInterval, see retention.go
+	Retention         RetentionPolicy // Zero value = keep everything
+	RetentionInterval time.Duration   // 0 = default 1 minute
+
+	// Maintenance window: tasks such as index optimization run in
+	// time-budgeted chunks during a daily window, see maintenance.go
+	Maintenance MaintenanceWindow // Zero value = no window
+
+	// Write-ahead log: inserts, updates and deletes are journaled so that
+	// changes since the last Save survive a crash, see journal.go
+	WAL             bool          // Default false
+	WALSyncInterval time.Duration // Fsync the journal at most this often, 0 = default 1s, negative = after every write
 
-	// Auto-save configuration
-	AutoSaveInterval int // Seconds, 0 = disabled
+	// Group commit of a journal fsynced after every write: concurrent
+	// writers share fsyncs, see WithWALGroupCommit
+	WALGroupCommitDelay time.Duration // Longest an fsync waits for more writers, 0 = none, negative = no group commit
+	WALGroupCommitBytes int           // Pending bytes that start the fsync before the delay, 0 = no limit
+
+	// Checkpoint configuration: each Save is kept as a checkpoint that
+	// CollectionAt can open; the newest CheckpointRetention are retained
+	CheckpointRetention int           // Checkpoints kept per collection, 0 = none
+	CheckpointMaxAge    time.Duration // Also prune checkpoints older than this, except the newest; 0 = no age limit
+
+	// Replica configuration
+	ReadOnly            bool          // Open without writing; collections follow another process's saves via Refresh
+	AutoRefreshInterval time.Duration // Poll for new saves of read-only collections, 0 = disabled
+
+	// Search configuration
+	SearchVectors bool // Include vectors in search results unless overridden by WithVectors, default false
+
+	// Filtered searches that find fewer than k matches among 20k candidates
+	// double the candidates up to MaxFilterCandidates, see SearchFiltered
+	DisableFilterEscalation bool // Stop at 20k candidates as before, default false
+	MaxFilterCandidates     int  // 0 = default 10000
+
+	// Sharding: each collection spreads its documents over Shards
+	// sub-collections picked by ShardFunc, see shard.go
+	Shards    int       // 0 or 1 = unsharded; fixed per collection at creation
+	ShardFunc ShardFunc // Shard of a document ID, nil = HashShard; must not change once documents are stored
+
+	// In-memory databases write no files, see OpenInMemory
+	InMemory bool
+
+	// Lifecycle configuration
+	CloseTimeout      time.Duration // Max time Close waits for in-flight operations, 0 = default
+	BackgroundWorkers int           // Goroutines running the background tasks of all collections of a DB, 0 = default 4
 }
 
 // DefaultConfig returns default configuration
@@ -34,9 +109,13 @@ func DefaultConfig() *Config {
 		CompressionLevel: 3,
 		PageSize:         1024 * 1024,
 		AutoSaveInterval: 0,
+		CloseTimeout:     defaultCloseTimeout,
 	}
 }
 
+// defaultCloseTimeout is used when Config.CloseTimeout is not set
+const defaultCloseTimeout = 30 * time.Second
+
 // Option is a functional option for configuration
 type Option func(*Config)
 
@@ -83,3 +162,301 @@ func WithEfConstruction(ef int) Option {
 		c.Adaptive = false // Disable adaptive when manually set
 	}
 }
+
+// WithCloseTimeout sets how long Close waits for in-flight operations to drain
+// before cancelling them
+func WithCloseTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.CloseTimeout = d
+	}
+}
+
+// WithGraphStorage selects where the graph of a loaded index lives.
+// hnsw.TieredL0 keeps layer-0 adjacency in a memory-mapped file to reduce
+// heap usage; hnsw.Mapped maps vectors and every layer as well, so the
+// collection opens without reading them.
+func WithGraphStorage(mode hnsw.GraphStorage) Option {
+	return func(c *Config) {
+		c.GraphStorage = mode
+	}
+}
+
+// WithSearchVectors sets whether search results include document vectors by
+// default. Search results omit vectors unless requested; enable this to keep
+// the previous behavior of always returning them.
+func WithSearchVectors(enabled bool) Option {
+	return func(c *Config) {
+		c.SearchVectors = enabled
+	}
+}
+
+// WithFilterEscalation sets whether SearchFiltered searches that find fewer
+// than k matches among the 20k nearest candidates keep doubling the
+// candidates up to WithMaxFilterCandidates (default true). Disabled,
+// selective filters may return fewer than k results even though more
+// documents match.
+func WithFilterEscalation(enabled bool) Option {
+	return func(c *Config) {
+		c.DisableFilterEscalation = !enabled
+	}
+}
+
+// WithMaxFilterCandidates caps how many candidates SearchFiltered searches
+// escalate to (default 10000); searches with a deadline also stop
+// escalating when it passes
+func WithMaxFilterCandidates(n int) Option {
+	return func(c *Config) {
+		c.MaxFilterCandidates = n
+	}
+}
+
+// WithCompressionLevel sets the zstd compression level (1-22) used for new
+// collections. Existing collections keep the level they were created with.
+func WithCompressionLevel(level int) Option {
+	return func(c *Config) {
+		c.CompressionLevel = level
+	}
+}
+
+// WithEncoderConfig sets the encoder selection thresholds used for new
+// collections. Existing collections keep the settings they were created with.
+func WithEncoderConfig(cfg encoding.EncoderConfig) Option {
+	return func(c *Config) {
+		c.EncoderConfig = &cfg
+	}
+}
+
+// WithVectorConstraints rejects inserted and query vectors outside the given
+// norm and value bounds with ErrConstraintViolation. The constraints are
+// stored with new collections; existing collections keep the ones they were
+// created with.
+func WithVectorConstraints(constraints Constraints) Option {
+	return func(c *Config) {
+		c.VectorConstraints = &constraints
+	}
+}
+
+// WithIndexRebuild sets whether opening a collection whose saved index is
+// missing or corrupt rebuilds the index from document storage (default
+// true). When disabled, a corrupt index fails with ErrIndexCorrupted.
+func WithIndexRebuild(enabled bool) Option {
+	return func(c *Config) {
+		c.DisableIndexRebuild = !enabled
+	}
+}
+
+// WithWAL journals every insert, update and delete of the database's
+// collections to a write-ahead log, so that changes made since the last
+// Save are replayed when the collection is opened after a crash. Save
+// empties the journal.
+func WithWAL(enabled bool) Option {
+	return func(c *Config) {
+		c.WAL = enabled
+	}
+}
+
+// WithWALSyncInterval sets how often the journal is fsynced. Records are
+// written to the journal file before a write returns, so they survive the
+// process dying at once; the interval bounds what a machine crash can lose.
+// A negative interval makes every write durable before it returns; see
+// WithWALGroupCommit for how concurrent writers share the fsyncs.
+func WithWALSyncInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.WALSyncInterval = interval
+	}
+}
+
+// WithWALGroupCommit tunes group commit, used when WithWALSyncInterval is
+// negative. Writers append to the journal and wait, without holding the
+// collection lock, for a background fsync that covers every writer waiting
+// by then, so a write still returns only once it is durable but one fsync
+// serves many concurrent writers. By default the fsync starts as soon as
+// the previous one finishes; a positive delay holds it back up to delay
+// for more writers to join, or until bytes are pending if bytes > 0. A
+// negative delay turns group commit off: every write fsyncs the journal
+// itself while holding the collection lock.
+func WithWALGroupCommit(delay time.Duration, bytes int) Option {
+	return func(c *Config) {
+		c.WALGroupCommitDelay = delay
+		c.WALGroupCommitBytes = bytes
+	}
+}
+
+// WithReadOnly opens the database as a read-only replica of data written by
+// another process. Modifications fail with ErrReadOnly, Close saves nothing,
+// and collections pick up the writer's saves through Collection.Refresh.
+func WithReadOnly(enabled bool) Option {
+	return func(c *Config) {
+		c.ReadOnly = enabled
+	}
+}
+
+// WithAutoRefresh makes read-only collections call Refresh every interval.
+// It has no effect unless the database is opened with WithReadOnly.
+func WithAutoRefresh(interval time.Duration) Option {
+	return func(c *Config) {
+		c.AutoRefreshInterval = interval
+	}
+}
+
+// WithAutoSave saves every writable collection in the background every
+// interval, or as soon as dirtyThreshold inserts, updates and deletes are
+// unsaved if that comes first; a collection without unsaved changes is not
+// saved. Either may be 0 to save on the
+// other alone. Searches keep running while a save writes; writes wait.
+func WithAutoSave(interval time.Duration, dirtyThreshold int) Option {
+	return func(c *Config) {
+		c.AutoSaveInterval = interval
+		c.AutoSaveThreshold = dirtyThreshold
+	}
+}
+
+// WithOrphanSweepInterval also reaps index nodes left by failed inserts every
+// interval instead of only at Save
+func WithOrphanSweepInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.OrphanSweepInterval = interval
+	}
+}
+
+// WithAutoOptimize runs Collection.Optimize with opts every interval, so
+// neighbor lists left suboptimal by insertion order improve over time. Bound
+// each pass with opts.MaxNodes or opts.TimeBudget; writes wait while it runs.
+func WithAutoOptimize(interval time.Duration, opts hnsw.OptimizeOptions) Option {
+	return func(c *Config) {
+		c.AutoOptimizeInterval = interval
+		c.AutoOptimize = opts
+	}
+}
+
+// WithRetention deletes documents older than policy.MaxAge and the oldest
+// beyond policy.MaxDocuments in the background of every writable collection,
+// once a minute unless WithRetentionInterval says otherwise. Documents
+// without policy.TimestampField are kept. Collection.ApplyRetention runs a
+// pass on demand.
+func WithRetention(policy RetentionPolicy) Option {
+	return func(c *Config) {
+		c.Retention = policy
+	}
+}
+
+// WithRetentionInterval sets how often WithRetention's policy is enforced
+func WithRetentionInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.RetentionInterval = interval
+	}
+}
+
+// WithBackgroundWorkers bounds how many background tasks (auto-refresh,
+// orphan sweeps, optimization, retention) of a DB's collections run at
+// once. The tasks of every collection share these n goroutines; see
+// DB.BackgroundTasks.
+func WithBackgroundWorkers(n int) Option {
+	return func(c *Config) {
+		c.BackgroundWorkers = n
+	}
+}
+
+// WithMaintenanceWindow runs the maintenance tasks of every writable
+// collection each day during spec, a time range "HH:MM-HH:MM" in local
+// time such as "01:00-05:00", for at most maxDuration per window (0 = the
+// whole window). Tasks work in chunks, each holding a collection for at
+// most the chunk budget of WithMaintenanceLimits, and save their progress
+// so the next window resumes where this one stopped. Index optimization is
+// built in; Collection.RegisterMaintenance adds more. DB.BackgroundTasks
+// shows their progress.
+func WithMaintenanceWindow(spec string, maxDuration time.Duration) Option {
+	return func(c *Config) {
+		c.Maintenance.Daily = spec
+		c.Maintenance.MaxDuration = maxDuration
+	}
+}
+
+// WithMaintenanceLimits bounds each maintenance chunk to chunk (0 = 50ms)
+// and holds maintenance back while the average latency of recent searches
+// exceeds maxSearchLatency (0 = never), until the next check of the window
+func WithMaintenanceLimits(chunk, maxSearchLatency time.Duration) Option {
+	return func(c *Config) {
+		c.Maintenance.ChunkBudget = chunk
+		c.Maintenance.MaxSearchLatency = maxSearchLatency
+	}
+}
+
+// WithCheckpointRetention keeps the last n saves of every collection as
+// checkpoints that DB.CollectionAt can open. Checkpoints hard-link the saved
+// files, so they only cost the space of data that has since been replaced.
+func WithCheckpointRetention(n int) Option {
+	return func(c *Config) {
+		c.CheckpointRetention = n
+	}
+}
+
+// WithCheckpointMaxAge prunes checkpoints older than d at every save; the
+// newest checkpoint is always kept. It has no effect unless
+// WithCheckpointRetention is set.
+func WithCheckpointMaxAge(d time.Duration) Option {
+	return func(c *Config) {
+		c.CheckpointMaxAge = d
+	}
+}
+
+// WithShards spreads each collection's documents over n sub-collections,
+// each with its own HNSW index and storage, placing every document by
+// fn(id, n); nil fn selects HashShard. Inserts and Gets touch one shard,
+// searches query all of them in parallel and merge the hits. The shard
+// count is fixed when a collection is created and reopening it with a
+// different one fails; fn is not persisted and must be passed again.
+func WithShards(n int, fn ShardFunc) Option {
+	return func(c *Config) {
+		c.Shards = n
+		c.ShardFunc = fn
+	}
+}
+
+// CollectionOption overrides a database option for one collection, see
+// DB.CollectionWithOptions
+type CollectionOption func(*Config)
+
+// WithCollectionDimension sets the vector dimension of the collection
+func WithCollectionDimension(d int) CollectionOption {
+	return func(c *Config) {
+		c.Dimension = d
+	}
+}
+
+// WithCollectionDistance sets the distance function of the collection: one
+// of hnsw.L2Distance, hnsw.InnerProductDistance and hnsw.CosineDistance,
+// which are recorded with the collection by name. Custom functions can only
+// be set for the whole database with WithDistanceFunc.
+func WithCollectionDistance(fn hnsw.DistanceFunc) CollectionOption {
+	return func(c *Config) {
+		c.DistanceFunc = fn
+	}
+}
+
+// WithCollectionM sets the HNSW M parameter of the collection
+func WithCollectionM(m int) CollectionOption {
+	return func(c *Config) {
+		c.M = m
+		c.Adaptive = false // Disable adaptive when manually set
+	}
+}
+
+// WithCollectionEfConstruction sets the HNSW efConstruction parameter of
+// the collection
+func WithCollectionEfConstruction(ef int) CollectionOption {
+	return func(c *Config) {
+		c.EfConstruction = ef
+		c.Adaptive = false // Disable adaptive when manually set
+	}
+}
+
+// WithTextIndex indexes the metadata string field of the collection's
+// documents for keyword search with BM25, enabling HybridSearch. The index
+// is kept up to date by inserts, updates and deletes and saved with the
+// collection.
+func WithTextIndex(field string) CollectionOption {
+	return func(c *Config) {
+		c.TextIndexField = field
+	}
+}
@@ -1,9 +1,15 @@
 package vego
 
 import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 
 	hnsw "github.com/wzqhbustb/vego/index"
+	"github.com/wzqhbustb/vego/storage/encoding"
 )
 
 // TestDefaultConfig tests default configuration
@@ -155,38 +161,45 @@ func TestWithEfConstruction(t *testing.T) {
 	}
 }
 
-// TestConfigValidation tests configuration validation
+// TestConfigValidation tests that Open rejects invalid index configurations
 func TestConfigValidation(t *testing.T) {
-	// This test assumes there's validation logic
-	// If validation doesn't exist yet, this serves as documentation of expected behavior
-	
 	testCases := []struct {
-		name        string
-		dimension   int
-		m           int
-		efConst     int
-		shouldWork  bool
+		name       string
+		dimension  int
+		m          int
+		efConst    int
+		shouldWork bool
 	}{
 		{"Valid config", 128, 16, 200, true},
 		{"Zero dimension", 0, 16, 200, false},
 		{"Negative dimension", -1, 16, 200, false},
-		{"Zero M", 128, 0, 200, false},
-		{"Zero EfConstruction", 128, 16, 0, false},
+		{"Zero M uses default", 128, 0, 200, true},
+		{"M of one", 128, 1, 200, false},
+		{"Negative M", 128, -4, 200, false},
+		{"Zero EfConstruction uses default", 128, 16, 0, true},
+		{"EfConstruction below M", 128, 16, 8, false},
 		{"Large values", 4096, 64, 800, true},
 	}
-	
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			config := &Config{
-				Dimension:      tc.dimension,
-				M:              tc.m,
-				EfConstruction: tc.efConst,
+			db, err := Open(t.TempDir(), WithAdaptive(false), WithDimension(tc.dimension),
+				WithM(tc.m), WithEfConstruction(tc.efConst))
+			if tc.shouldWork {
+				if err != nil {
+					t.Fatalf("Open failed: %v", err)
+				}
+				db.Close()
+				return
+			}
+			if err == nil {
+				db.Close()
+				t.Fatal("Expected Open to fail")
+			}
+			var cfgErr *hnsw.ConfigError
+			if !IsValidationFailed(err) || !errors.As(err, &cfgErr) {
+				t.Errorf("Expected ErrValidationFailed with a ConfigError, got %v", err)
 			}
-			
-			// If validation function exists, call it
-			// For now, just log the config
-			t.Logf("Config: Dimension=%d, M=%d, EfConstruction=%d", 
-				config.Dimension, config.M, config.EfConstruction)
 		})
 	}
 }
@@ -262,3 +275,116 @@ func TestOptionChaining(t *testing.T) {
 		t.Error("Options should be composable")
 	}
 }
+
+// TestCompressionOptions tests that compression settings reach the files on
+// disk and are restored on reopen
+func TestCompressionOptions(t *testing.T) {
+	// Compressible vectors: quantized to 16 distinct values, where higher
+	// zstd levels find noticeably better matches
+	rng := rand.New(rand.NewSource(1))
+	docs := make([]*Document, 500)
+	for i := range docs {
+		vector := make([]float32, 64)
+		for j := range vector {
+			vector[j] = float32(rng.Intn(16)) * 0.125
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc_%d", i), Vector: vector}
+	}
+
+	encoderConfig := *encoding.DefaultEncoderConfig()
+	encoderConfig.SmallDataThreshold = 10
+	// Keep vectors on the Zstd-only path so the level is what differs
+	encoderConfig.BSSEntropyThreshold = 0
+
+	sizes := make(map[int]int64)
+	for _, level := range []int{1, 19} {
+		dir := t.TempDir()
+
+		db, err := Open(dir, WithDimension(64), WithCompressionLevel(level), WithEncoderConfig(encoderConfig))
+		if err != nil {
+			t.Fatalf("Open with level %d failed: %v", level, err)
+		}
+		coll, err := db.Collection("docs")
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+		if err := coll.InsertBatch(docs); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		// Document data and index files are both encoded with the settings
+		for _, file := range []string{
+			filepath.Join("documents", dataFileName),
+			filepath.Join("index", "nodes.lance"),
+		} {
+			info, err := os.Stat(filepath.Join(dir, "docs", file))
+			if err != nil {
+				t.Fatalf("Stat %s failed: %v", file, err)
+			}
+			sizes[level] += info.Size()
+		}
+
+		// Reopen with different options; the persisted settings win
+		db, err = Open(dir, WithDimension(64), WithCompressionLevel(5))
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		coll, err = db.Collection("docs")
+		if err != nil {
+			t.Fatalf("Collection after reopen failed: %v", err)
+		}
+		stats := coll.Stats()
+		if stats.CompressionLevel != level {
+			t.Errorf("Expected persisted level %d, got %d", level, stats.CompressionLevel)
+		}
+		if stats.EncoderConfig != encoderConfig {
+			t.Errorf("Expected persisted encoder config %+v, got %+v", encoderConfig, stats.EncoderConfig)
+		}
+		if coll.Count() != len(docs) {
+			t.Errorf("Expected %d documents after reopen, got %d", len(docs), coll.Count())
+		}
+		doc, err := coll.Get("doc_42")
+		if err != nil {
+			t.Fatalf("Get after reopen failed: %v", err)
+		}
+		if doc.Vector[5] != docs[42].Vector[5] {
+			t.Errorf("Vector mismatch after reopen: %v vs %v", doc.Vector[5], docs[42].Vector[5])
+		}
+		db.Close()
+	}
+
+	t.Logf("data + index size: level 1 = %d bytes, level 19 = %d bytes", sizes[1], sizes[19])
+	if float64(sizes[19]) > 0.97*float64(sizes[1]) {
+		t.Errorf("Expected level 19 (%d bytes) to be at least 3%% smaller than level 1 (%d bytes)", sizes[19], sizes[1])
+	}
+}
+
+// TestCompressionOptionsValidation tests that invalid settings are rejected
+func TestCompressionOptionsValidation(t *testing.T) {
+	badEncoder := *encoding.DefaultEncoderConfig()
+	badEncoder.RLEThreshold = 2
+
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"level below range", WithCompressionLevel(-1)},
+		{"level above range", WithCompressionLevel(23)},
+		{"invalid encoder config", WithEncoderConfig(badEncoder)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(t.TempDir(), tt.opt)
+			if err == nil {
+				db.Close()
+				t.Fatal("Expected Open to fail")
+			}
+			if !IsValidationFailed(err) {
+				t.Errorf("Expected ErrValidationFailed, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// testBackend opens a database for the conformance suite
+type testBackend struct {
+	name string
+	open func(t *testing.T, opts ...Option) *DB
+}
+
+var testBackends = []testBackend{
+	{"disk", func(t *testing.T, opts ...Option) *DB {
+		db, err := Open(t.TempDir(), opts...)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return db
+	}},
+	{"memory", func(t *testing.T, opts ...Option) *DB {
+		db, err := OpenInMemory(opts...)
+		if err != nil {
+			t.Fatalf("OpenInMemory failed: %v", err)
+		}
+		return db
+	}},
+}
+
+// conformanceCases exercise the Collection API; every backend must pass
+// all of them
+var conformanceCases = []struct {
+	name string
+	run  func(t *testing.T, coll *Collection)
+}{
+	{"InsertGet", func(t *testing.T, coll *Collection) {
+		doc := createTestDocument("doc1", 16, map[string]interface{}{"key": "value"})
+		if err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		got, err := coll.Get("doc1")
+		if err != nil || got.ID != "doc1" || got.Metadata["key"] != "value" || len(got.Vector) != 16 {
+			t.Fatalf("Get = %+v, %v", got, err)
+		}
+		if err := coll.Insert(doc); !IsDuplicate(err) {
+			t.Errorf("duplicate Insert = %v", err)
+		}
+		if err := coll.Insert(createTestDocument("short", 8, nil)); err == nil {
+			t.Error("Insert with wrong dimension succeeded")
+		}
+		if _, err := coll.Get("missing"); !IsNotFound(err) {
+			t.Errorf("Get of missing document = %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := coll.InsertContext(ctx, createTestDocument("doc2", 16, nil)); err != context.Canceled {
+			t.Errorf("InsertContext with cancelled context = %v", err)
+		}
+	}},
+	{"UpdateUpsertDelete", func(t *testing.T, coll *Collection) {
+		// More documents than the write buffer holds, so some are flushed
+		docs := shardTestDocs(rand.New(rand.NewSource(1)), maxBufferSize+200, 16)
+		for _, doc := range docs {
+			if err := coll.Insert(doc); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		flushed, buffered := docs[10], docs[len(docs)-1]
+		for _, doc := range []*Document{flushed, buffered} {
+			moved := &Document{ID: doc.ID, Vector: docs[0].Vector, Metadata: map[string]interface{}{"moved": true}}
+			if err := coll.Update(moved); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			got, err := coll.Get(doc.ID)
+			if err != nil || got.Metadata["moved"] != true || got.Vector[0] != docs[0].Vector[0] {
+				t.Errorf("Get after Update = %+v, %v", got, err)
+			}
+		}
+		if err := coll.Update(createTestDocument("missing", 16, nil)); !IsNotFound(err) {
+			t.Errorf("Update of missing document = %v", err)
+		}
+		if err := coll.Upsert(createTestDocument("new", 16, nil)); err != nil {
+			t.Fatalf("Upsert of new document failed: %v", err)
+		}
+		if err := coll.Upsert(&Document{ID: docs[20].ID, Vector: docs[20].Vector, Metadata: map[string]interface{}{"n": -1}}); err != nil {
+			t.Fatalf("Upsert of existing document failed: %v", err)
+		}
+		if got, _ := coll.Get(docs[20].ID); got == nil || fmt.Sprint(got.Metadata["n"]) != "-1" {
+			t.Errorf("Get after Upsert = %+v", got)
+		}
+		for _, doc := range []*Document{docs[30], buffered} {
+			if err := coll.Delete(doc.ID); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, err := coll.Get(doc.ID); !IsNotFound(err) {
+				t.Errorf("Get of deleted document = %v", err)
+			}
+		}
+		if err := coll.Delete("missing"); err == nil {
+			t.Error("Delete of missing document succeeded")
+		}
+		if want := len(docs) - 1; coll.Count() != want {
+			t.Errorf("Count = %d, want %d", coll.Count(), want)
+		}
+	}},
+	{"Batch", func(t *testing.T, coll *Collection) {
+		docs := shardTestDocs(rand.New(rand.NewSource(2)), 50, 16)
+		if err := coll.InsertBatch(docs); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		got, err := coll.GetBatch([]string{docs[0].ID, docs[1].ID, "missing"})
+		if err != nil || len(got) != 2 {
+			t.Fatalf("GetBatch = %d documents, %v", len(got), err)
+		}
+		if err := coll.DeleteBatch([]string{docs[0].ID, docs[1].ID}); err != nil {
+			t.Fatalf("DeleteBatch failed: %v", err)
+		}
+		if err := coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		result, err := coll.GetBatchOpts(context.Background(), []string{docs[2].ID, docs[0].ID, docs[3].ID}, GetOptions{})
+		if err != nil {
+			t.Fatalf("GetBatchOpts failed: %v", err)
+		}
+		if len(result.Found) != 2 || fmt.Sprint(result.Missing) != fmt.Sprint([]string{docs[0].ID}) {
+			t.Errorf("GetBatchOpts found %d, missing %v", len(result.Found), result.Missing)
+		}
+		for _, doc := range result.Found {
+			if len(doc.Vector) != 16 {
+				t.Errorf("GetBatchOpts returned %s without its vector", doc.ID)
+			}
+		}
+		if coll.Count() != 48 {
+			t.Errorf("Count = %d, want 48", coll.Count())
+		}
+	}},
+	{"Search", func(t *testing.T, coll *Collection) {
+		docs := shardTestDocs(rand.New(rand.NewSource(3)), 200, 16)
+		if err := coll.InsertBatch(docs); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		results, err := coll.Search(docs[5].Vector, 10)
+		if err != nil || len(results) != 10 || results[0].Document.ID != docs[5].ID {
+			t.Fatalf("Search = %v, %v", results, err)
+		}
+		filtered, err := coll.SearchWithFilter(docs[5].Vector, 5, &MetadataFilter{Field: "n", Operator: "lt", Value: 50})
+		if err != nil || len(filtered) != 5 {
+			t.Fatalf("SearchWithFilter = %d results, %v", len(filtered), err)
+		}
+		for _, r := range filtered {
+			if n, _ := r.Document.GetInt("n"); n >= 50 {
+				t.Errorf("filtered search returned %s", r.Document.ID)
+			}
+		}
+		ids, err := coll.SearchIDs(context.Background(), docs[5].Vector, 10, nil)
+		if err != nil || len(ids) != 10 || ids[0].DocID != docs[5].ID {
+			t.Errorf("SearchIDs = %v, %v", ids, err)
+		}
+		similar, err := coll.SearchSimilarTo(context.Background(), docs[5].ID, 3, nil)
+		if err != nil || len(similar) != 3 {
+			t.Errorf("SearchSimilarTo = %d results, %v", len(similar), err)
+		}
+		batch, err := coll.SearchBatch([][]float32{docs[1].Vector, docs[2].Vector}, 1)
+		if err != nil || len(batch) != 2 || batch[1][0].Document.ID != docs[2].ID {
+			t.Errorf("SearchBatch = %v, %v", batch, err)
+		}
+		distances, err := coll.DistancesTo(context.Background(), docs[5].Vector, []string{docs[5].ID})
+		if err != nil || len(distances) != 1 || distances[0] != 0 {
+			t.Errorf("DistancesTo = %v, %v", distances, err)
+		}
+		if _, err := coll.Search(make([]float32, 8), 10); err == nil {
+			t.Error("Search with wrong dimension succeeded")
+		}
+	}},
+	{"InfoAndStats", func(t *testing.T, coll *Collection) {
+		if err := coll.SetModel("test-model", "1", 16); err != nil {
+			t.Fatalf("SetModel failed: %v", err)
+		}
+		if err := coll.InsertBatch(shardTestDocs(rand.New(rand.NewSource(4)), 20, 16)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if model, ok := coll.Model(); !ok || model.Name != "test-model" {
+			t.Errorf("Model = %+v, %v", model, ok)
+		}
+		stats := coll.Stats()
+		if stats.Count != 20 || stats.IndexNodes != 20 || stats.Dimension != 16 {
+			t.Errorf("Stats = %+v", stats)
+		}
+	}},
+	{"Close", func(t *testing.T, coll *Collection) {
+		if err := coll.Insert(createTestDocument("doc1", 16, nil)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if err := coll.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if _, err := coll.Get("doc1"); !errors.Is(err, ErrCollectionClosed) {
+			t.Errorf("Get after Close = %v", err)
+		}
+		if err := coll.Close(); err != nil {
+			t.Errorf("second Close = %v", err)
+		}
+	}},
+}
+
+// TestConformance runs the conformance cases against every backend
+func TestConformance(t *testing.T) {
+	for _, backend := range testBackends {
+		for _, tc := range conformanceCases {
+			t.Run(backend.name+"/"+tc.name, func(t *testing.T) {
+				db := backend.open(t, WithDimension(16))
+				defer db.Close()
+				coll, err := db.Collection("test")
+				if err != nil {
+					t.Fatalf("Collection failed: %v", err)
+				}
+				if stats := coll.Stats(); stats.InMemory != (backend.name == "memory") {
+					t.Errorf("Stats().InMemory = %v on %s", stats.InMemory, backend.name)
+				}
+				tc.run(t, coll)
+			})
+		}
+	}
+}
+
+// TestConformanceBackendsAgree checks that the same writes leave both
+// backends with the same content and the same search results
+func TestConformanceBackendsAgree(t *testing.T) {
+	docs := shardTestDocs(rand.New(rand.NewSource(5)), maxBufferSize+100, 8)
+	var hashes []string
+	var searches [][]string
+	for _, backend := range testBackends {
+		db := backend.open(t, WithDimension(8))
+		defer db.Close()
+		coll, _ := db.Collection("test")
+		for _, doc := range docs {
+			if err := coll.Insert(doc); err != nil {
+				t.Fatalf("%s: Insert failed: %v", backend.name, err)
+			}
+		}
+		for _, doc := range docs[:100] {
+			if err := coll.Delete(doc.ID); err != nil {
+				t.Fatalf("%s: Delete failed: %v", backend.name, err)
+			}
+		}
+		hash, err := coll.ContentHash(context.Background())
+		if err != nil {
+			t.Fatalf("%s: ContentHash failed: %v", backend.name, err)
+		}
+		results, err := coll.Search(docs[500].Vector, 10)
+		if err != nil {
+			t.Fatalf("%s: Search failed: %v", backend.name, err)
+		}
+		hashes = append(hashes, hash)
+		searches = append(searches, resultIDs(results))
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("content hashes differ: disk %s, memory %s", hashes[0], hashes[1])
+	}
+	if fmt.Sprint(searches[0]) != fmt.Sprint(searches[1]) {
+		t.Errorf("search results differ: disk %v, memory %v", searches[0], searches[1])
+	}
+}
+
+func TestInMemoryWritesNoFiles(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	db, err := OpenInMemory(WithDimension(8), WithShards(2, nil))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	coll, _ := db.Collection("docs")
+	plain, err := OpenInMemory(WithDimension(8))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	other, _ := plain.Collection("docs")
+	for _, c := range []*Collection{coll, other} {
+		if err := c.InsertBatch(shardTestDocs(rand.New(rand.NewSource(6)), maxBufferSize+10, 8)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if !c.Stats().InMemory {
+			t.Error("Stats().InMemory = false")
+		}
+	}
+	if _, err := other.Checkpoints(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Checkpoints = %v, want ErrNotSupported", err)
+	}
+	if _, err := plain.CollectionAtCheckpoint("docs", 1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("CollectionAtCheckpoint = %v, want ErrNotSupported", err)
+	}
+	if _, err := other.Refresh(context.Background()); err == nil {
+		t.Error("Refresh of an in-memory collection succeeded")
+	}
+	if err := db.DropCollection("docs"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := plain.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("in-memory database wrote %d files, first %s", len(entries), entries[0].Name())
+	}
+}
+
+func TestInMemoryRejectsFileOptions(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"TieredL0":    WithGraphStorage(hnsw.TieredL0),
+		"ReadOnly":    WithReadOnly(true),
+		"AutoRefresh": WithAutoRefresh(1),
+		"Checkpoints": WithCheckpointRetention(3),
+	} {
+		if _, err := OpenInMemory(WithDimension(8), opt); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("%s: OpenInMemory = %v, want ErrNotSupported", name, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestReadYourWrites has writers search for each of their changes as soon
+// as the call making it returns: inserted and updated documents must be
+// found at their new vectors and deleted ones never returned
+func TestReadYourWrites(t *testing.T) {
+	duration := 10 * time.Second
+	if testing.Short() {
+		duration = time.Second
+	}
+	const writers = 16
+	const dim = 16
+
+	db, err := Open(t.TempDir(), WithDimension(dim))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("ryw")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	ctx := context.Background()
+	deadline := time.Now().Add(duration)
+	var ops, misses atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			vector := func() []float32 {
+				v := make([]float32, dim)
+				for i := range v {
+					v[i] = rng.Float32()
+				}
+				return v
+			}
+			found := func(id string, query []float32) bool {
+				results, err := coll.SearchContext(ctx, query, 3)
+				if err != nil {
+					t.Errorf("SearchContext failed: %v", err)
+					return false
+				}
+				for _, r := range results {
+					if r.Document.ID == id {
+						return true
+					}
+				}
+				return false
+			}
+			miss := func(format string, args ...interface{}) {
+				misses.Add(1)
+				t.Errorf(format, args...)
+			}
+
+			for i := 0; time.Now().Before(deadline); i++ {
+				id := fmt.Sprintf("w%02d_%06d", w, i)
+				doc := &Document{ID: id, Vector: vector()}
+				var err error
+				switch i % 4 {
+				case 0, 1:
+					err = coll.InsertContext(ctx, doc)
+				case 2:
+					err = coll.InsertBatchContext(ctx, []*Document{doc})
+				case 3:
+					// Move the previous document, then delete this one
+					prev := &Document{ID: fmt.Sprintf("w%02d_%06d", w, i-1), Vector: vector()}
+					if err := coll.UpdateContext(ctx, prev); err != nil {
+						t.Errorf("Update failed: %v", err)
+						return
+					}
+					if !found(prev.ID, prev.Vector) {
+						miss("%s not found at its new vector after Update", prev.ID)
+					}
+					if err := coll.InsertContext(ctx, doc); err != nil {
+						t.Errorf("Insert failed: %v", err)
+						return
+					}
+					if err := coll.DeleteContext(ctx, id); err != nil {
+						t.Errorf("Delete failed: %v", err)
+						return
+					}
+					if found(id, doc.Vector) {
+						miss("%s found after Delete", id)
+					}
+					ops.Add(1)
+					continue
+				}
+				if err != nil {
+					t.Errorf("Insert failed: %v", err)
+					return
+				}
+				if !found(id, doc.Vector) {
+					miss("%s not found after Insert", id)
+				}
+				ops.Add(1)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	t.Logf("%d writes checked by %d writers in %v", ops.Load(), writers, duration)
+	if n := misses.Load(); n != 0 {
+		t.Errorf("%d writes not observed by the next search", n)
+	}
+}
@@ -0,0 +1,156 @@
+package vego
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrConstraintViolation is returned when a vector breaks the collection's
+// Constraints. It is a validation failure, so IsValidationFailed reports it
+// too; errors.As with *ConstraintError tells which constraint failed.
+var ErrConstraintViolation = fmt.Errorf("%w: vector constraint violated", ErrValidationFailed)
+
+// normTolerance is the relative slack of the norm bounds, so vectors
+// normalized in float32 pass bounds of exactly 1
+const normTolerance = 1e-6
+
+// Constraints are domain limits that every inserted vector and every query
+// must satisfy, e.g. unit norm and values in [-1, 1] for normalized
+// embeddings. Out-of-range vectors usually mean an upstream bug, so they are
+// rejected instead of indexed. Bounds are inclusive.
+type Constraints struct {
+	// Euclidean norm bounds, compared with a relative tolerance of 1e-6.
+	// MaxNorm 0 means no upper bound.
+	MinNorm float32 `json:"min_norm"`
+	MaxNorm float32 `json:"max_norm"`
+
+	// Bounds of every vector value. The range only applies when
+	// MinValue < MaxValue; use ±math.MaxFloat32 for an open side.
+	MinValue float32 `json:"min_value"`
+	MaxValue float32 `json:"max_value"`
+}
+
+// ConstraintError describes the first constraint a vector broke
+type ConstraintError struct {
+	Constraint string  // "min_norm", "max_norm", "min_value", "max_value" or "finite"
+	Index      int     // Offending dimension, -1 for the norm
+	Value      float32 // Offending value or norm
+	Bound      float32 // Bound that was broken
+}
+
+// Error describes the violation
+func (e *ConstraintError) Error() string {
+	switch {
+	case e.Constraint == "finite":
+		return fmt.Sprintf("%v: value %v at dimension %d is not finite", ErrConstraintViolation, e.Value, e.Index)
+	case e.Index < 0:
+		return fmt.Sprintf("%v: %s: norm %v, bound %v", ErrConstraintViolation, e.Constraint, e.Value, e.Bound)
+	default:
+		return fmt.Sprintf("%v: %s: value %v at dimension %d, bound %v", ErrConstraintViolation, e.Constraint, e.Value, e.Index, e.Bound)
+	}
+}
+
+// Unwrap returns ErrConstraintViolation for errors.Is support
+func (e *ConstraintError) Unwrap() error { return ErrConstraintViolation }
+
+// IsConstraintViolation checks if an error is ErrConstraintViolation
+func IsConstraintViolation(err error) bool {
+	return errors.Is(err, ErrConstraintViolation)
+}
+
+// validate rejects bounds that no vector could satisfy
+func (c Constraints) validate() error {
+	for _, v := range []float32{c.MinNorm, c.MaxNorm, c.MinValue, c.MaxValue} {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("%w: constraint bounds must be finite", ErrValidationFailed)
+		}
+	}
+	if c.MinNorm < 0 || c.MaxNorm < 0 {
+		return fmt.Errorf("%w: negative norm bound", ErrValidationFailed)
+	}
+	if c.MaxNorm > 0 && c.MinNorm > c.MaxNorm {
+		return fmt.Errorf("%w: MinNorm %v exceeds MaxNorm %v", ErrValidationFailed, c.MinNorm, c.MaxNorm)
+	}
+	if c.MinValue > c.MaxValue {
+		return fmt.Errorf("%w: MinValue %v exceeds MaxValue %v", ErrValidationFailed, c.MinValue, c.MaxValue)
+	}
+	return nil
+}
+
+// Check returns a *ConstraintError for the first constraint vector breaks.
+// Values are checked before the norm; NaN and infinite values always fail.
+func (c *Constraints) Check(vector []float32) error {
+	if c == nil {
+		return nil
+	}
+
+	ranged := c.MinValue < c.MaxValue
+	var sum float32
+	for i, v := range vector {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return &ConstraintError{Constraint: "finite", Index: i, Value: v}
+		}
+		if ranged && v < c.MinValue {
+			return &ConstraintError{Constraint: "min_value", Index: i, Value: v, Bound: c.MinValue}
+		}
+		if ranged && v > c.MaxValue {
+			return &ConstraintError{Constraint: "max_value", Index: i, Value: v, Bound: c.MaxValue}
+		}
+		sum += v * v
+	}
+
+	// Same float32 accumulation as CosineDistance
+	norm := float32(math.Sqrt(float64(sum)))
+	if c.MinNorm > 0 && norm < c.MinNorm*(1-normTolerance) {
+		return &ConstraintError{Constraint: "min_norm", Index: -1, Value: norm, Bound: c.MinNorm}
+	}
+	if c.MaxNorm > 0 && norm > c.MaxNorm*(1+normTolerance) {
+		return &ConstraintError{Constraint: "max_norm", Index: -1, Value: norm, Bound: c.MaxNorm}
+	}
+	return nil
+}
+
+// ValidateWith checks the document like Validate and its vector against
+// constraints; nil constraints only run Validate
+func (d *Document) ValidateWith(dimension int, constraints *Constraints) error {
+	if err := d.Validate(dimension); err != nil {
+		return err
+	}
+	return constraints.Check(d.Vector)
+}
+
+// checkQuery rejects query vectors of the wrong dimension or breaking the
+// collection's constraints
+func (c *Collection) checkQuery(op string, query []float32) error {
+	if len(query) != c.dimension {
+		return wrapError(op, c.name, "", ErrDimensionMismatch)
+	}
+	if err := c.settings.Constraints.Check(query); err != nil {
+		return wrapError(op, c.name, "", err)
+	}
+	return nil
+}
+
+// validateBatch checks every document of a batch, reporting each failure in
+// a *BatchError indexed like docs
+func (c *Collection) validateBatch(op string, docs []*Document) error {
+	var errs []error
+	for i, doc := range docs {
+		err := doc.ValidateWith(c.dimension, c.settings.Constraints)
+		if err == nil {
+			continue
+		}
+		if errs == nil {
+			errs = make([]error, len(docs))
+		}
+		if !errors.Is(err, ErrValidationFailed) {
+			err = fmt.Errorf("%w: %v", ErrValidationFailed, err)
+		}
+		errs[i] = wrapError(op, c.name, doc.ID, err)
+	}
+	if errs != nil {
+		return wrapError(op, c.name, "", &BatchError{Errors: errs})
+	}
+	return nil
+}
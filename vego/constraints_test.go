@@ -0,0 +1,241 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// unitConstraints are the bounds of normalized embeddings
+var unitConstraints = Constraints{MinNorm: 1, MaxNorm: 1, MinValue: -1, MaxValue: 1}
+
+// normalized returns v scaled to unit norm in float32
+func normalized(v []float32) []float32 {
+	var sum float32
+	for _, x := range v {
+		sum += x * x
+	}
+	norm := float32(math.Sqrt(float64(sum)))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+func TestConstraintsCheck(t *testing.T) {
+	nan := float32(math.NaN())
+	tests := []struct {
+		name        string
+		constraints Constraints
+		vector      []float32
+		constraint  string // "" = passes
+		index       int
+	}{
+		{"unit vector", unitConstraints, []float32{1, 0, 0}, "", 0},
+		{"values at both limits", Constraints{MinValue: -1, MaxValue: 1}, []float32{-1, 1, 0}, "", 0},
+		{"value below range", Constraints{MinValue: -1, MaxValue: 1}, []float32{0, -1.0001, 0}, "min_value", 1},
+		{"value above range", Constraints{MinValue: -1, MaxValue: 1}, []float32{0, 0, 1.0001}, "max_value", 2},
+		{"open upper side", Constraints{MinValue: 0, MaxValue: math.MaxFloat32}, []float32{0, 1e30}, "", 0},
+		{"norm at min", Constraints{MinNorm: 5}, []float32{3, 4}, "", 0},
+		{"norm at max", Constraints{MinNorm: 1, MaxNorm: 5}, []float32{3, 4}, "", 0},
+		{"norm below min", Constraints{MinNorm: 5}, []float32{3, 3.9}, "min_norm", -1},
+		{"norm above max", Constraints{MaxNorm: 5}, []float32{3, 4.1}, "max_norm", -1},
+		{"no upper norm bound", Constraints{MinNorm: 1}, []float32{300, 400}, "", 0},
+		{"value checked before norm", unitConstraints, []float32{2, 0}, "max_value", 0},
+		{"NaN", Constraints{MaxNorm: 10}, []float32{0, nan}, "finite", 1},
+		{"zero constraints still reject NaN", Constraints{}, []float32{nan}, "finite", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.constraints.Check(tt.vector)
+			if tt.constraint == "" {
+				if err != nil {
+					t.Fatalf("Check failed: %v", err)
+				}
+				return
+			}
+			var ce *ConstraintError
+			if !errors.As(err, &ce) || !IsConstraintViolation(err) || !IsValidationFailed(err) {
+				t.Fatalf("expected a ConstraintError, got %v", err)
+			}
+			if ce.Constraint != tt.constraint || ce.Index != tt.index {
+				t.Errorf("got constraint %q at %d, want %q at %d (%v)", ce.Constraint, ce.Index, tt.constraint, tt.index, err)
+			}
+		})
+	}
+
+	var none *Constraints
+	if err := none.Check([]float32{nan}); err != nil {
+		t.Errorf("nil constraints rejected a vector: %v", err)
+	}
+}
+
+func TestConstraintsNormalizedVectors(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		v := make([]float32, 1+rng.Intn(1024))
+		for j := range v {
+			v[j] = float32(rng.NormFloat64()) * 10
+		}
+		unit := normalized(v)
+		if err := unitConstraints.Check(unit); err != nil {
+			t.Fatalf("normalized vector of dimension %d rejected: %v", len(v), err)
+		}
+		// The norm is computed like CosineDistance's, so a vector that
+		// passes is one cosine distance treats as unit length
+		if d := hnsw.CosineDistance(unit, unit); math.Abs(float64(d)) > 1e-5 {
+			t.Fatalf("CosineDistance of a normalized vector with itself = %v", d)
+		}
+	}
+}
+
+func TestCollectionVectorConstraints(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := Open(dir, WithDimension(3), WithVectorConstraints(unitConstraints))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	if err := coll.Insert(&Document{ID: "ok", Vector: []float32{0, 1, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	err = coll.Insert(&Document{ID: "long", Vector: []float32{0, 1, 1}})
+	var ce *ConstraintError
+	if !errors.As(err, &ce) || ce.Constraint != "max_norm" {
+		t.Errorf("expected a max_norm violation, got %v", err)
+	}
+	if err := coll.Update(&Document{ID: "ok", Vector: []float32{0, 0.5, 0}}); !IsConstraintViolation(err) {
+		t.Errorf("expected Update to violate constraints, got %v", err)
+	}
+
+	// Batches report every violating document and insert nothing
+	batch := []*Document{
+		{ID: "b0", Vector: []float32{1, 0, 0}},
+		{ID: "b1", Vector: []float32{-1.5, 0, 0}},
+		{ID: "b2", Vector: []float32{0, 0, 1}},
+		{ID: "b3", Vector: []float32{0, 0}},
+	}
+	err = coll.InsertBatch(batch)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != len(batch) {
+		t.Fatalf("expected a BatchError for %d documents, got %v", len(batch), err)
+	}
+	for i, want := range []bool{false, true, false, true} {
+		if got := batchErr.Errors[i] != nil; got != want {
+			t.Errorf("document %d: error %v, want failure %v", i, batchErr.Errors[i], want)
+		}
+	}
+	if !IsConstraintViolation(batchErr.Errors[1]) || IsConstraintViolation(batchErr.Errors[3]) || !IsValidationFailed(batchErr.Errors[3]) {
+		t.Errorf("unexpected per-document errors: %v", batchErr.Errors)
+	}
+	if coll.Count() != 1 {
+		t.Errorf("Count = %d after a rejected batch, want 1", coll.Count())
+	}
+
+	// Queries are checked too
+	if _, err := coll.SearchContext(ctx, []float32{2, 0, 0}, 1); !IsConstraintViolation(err) {
+		t.Errorf("expected SearchContext to reject the query, got %v", err)
+	}
+	if _, err := coll.SearchIDs(ctx, []float32{0, 0, 0}, 1, nil); !IsConstraintViolation(err) {
+		t.Errorf("expected SearchIDs to reject the query, got %v", err)
+	}
+	if _, err := coll.SearchContext(ctx, []float32{1, 0, 0}, 1); err != nil {
+		t.Errorf("SearchContext failed: %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Constraints persist with the collection
+	db, err = Open(dir, WithDimension(3))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.Insert(&Document{ID: "long", Vector: []float32{0, 1, 1}}); !IsConstraintViolation(err) {
+		t.Errorf("expected reopened collection to keep its constraints, got %v", err)
+	}
+}
+
+func TestInvalidVectorConstraints(t *testing.T) {
+	for _, c := range []Constraints{
+		{MinNorm: -1},
+		{MinNorm: 2, MaxNorm: 1},
+		{MinValue: 1, MaxValue: -1},
+		{MaxValue: float32(math.Inf(1))},
+	} {
+		if _, err := Open(t.TempDir(), WithDimension(3), WithVectorConstraints(c)); !IsValidationFailed(err) {
+			t.Errorf("%+v: expected ErrValidationFailed, got %v", c, err)
+		}
+	}
+}
+
+// BenchmarkInsertConstraints compares inserts with and without constraints;
+// the check is a single pass over the vector
+func BenchmarkInsertConstraints(b *testing.B) {
+	for _, bc := range []struct {
+		name        string
+		constraints *Constraints
+	}{
+		{"none", nil},
+		{"unit", &unitConstraints},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			tmpDir := filepath.Join(os.TempDir(), "vego_benchmark", fmt.Sprintf("bench_%d", time.Now().UnixNano()))
+			defer os.RemoveAll(tmpDir)
+			coll, err := NewCollection("benchmark", tmpDir, &Config{
+				Dimension:         128,
+				M:                 16,
+				EfConstruction:    200,
+				VectorConstraints: bc.constraints,
+			})
+			if err != nil {
+				b.Fatalf("Failed to create collection: %v", err)
+			}
+			defer coll.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				doc := &Document{
+					ID:     fmt.Sprintf("doc_%d", i),
+					Vector: normalized(generateRandomVector(128, i+1)),
+				}
+				if err := coll.Insert(doc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkConstraintsCheck measures the check alone on a 768-dimensional
+// vector
+func BenchmarkConstraintsCheck(b *testing.B) {
+	vector := normalized(generateRandomVector(768, 1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := unitConstraints.Check(vector); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
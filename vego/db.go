@@ -1,9 +1,12 @@
 package vego
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -12,6 +15,8 @@ type DB struct {
 	config      *Config
 	path        string                 // Database directory path
 	collections map[string]*Collection // Collection name -> Collection
+	sched       *scheduler             // Runs the background tasks of all collections
+	maint       *maintenanceRunner     // Runs maintenance tasks in the window, nil without one
 
 	mu     sync.RWMutex
 	closed bool
@@ -19,11 +24,22 @@ type DB struct {
 
 // Open opens or creates a database at the given path
 func Open(path string, opts ...Option) (*DB, error) {
+	return OpenContext(context.Background(), path, opts...)
+}
+
+// OpenContext opens or creates a database at the given path. ctx bounds
+// loading existing collections, including rebuilding a missing or corrupt
+// index from document storage.
+func OpenContext(ctx context.Context, path string, opts ...Option) (*DB, error) {
 	config := DefaultConfig()
 	for _, opt := range opts {
 		opt(config)
 	}
 
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+
 	// Ensure directory exists
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
@@ -33,17 +49,42 @@ func Open(path string, opts ...Option) (*DB, error) {
 		config:      config,
 		path:        path,
 		collections: make(map[string]*Collection),
+		sched:       newScheduler(config.BackgroundWorkers),
 	}
 
 	// Load existing collections
-	if err := db.loadCollections(); err != nil {
+	if err := db.loadCollections(ctx); err != nil {
 		return nil, fmt.Errorf("failed to load collections: %w", err)
 	}
+	db.startMaintenance()
 
 	return db, nil
 }
 
-// Close closes the database and all collections
+// validateConfig rejects invalid storage and index settings and, for an
+// in-memory database, options that need files
+func validateConfig(config *Config) error {
+	if err := settingsFromConfig(config).validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := indexConfig(config).Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w: %w", ErrValidationFailed, err)
+	}
+	if err := config.Maintenance.validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if config.InMemory {
+		if err := validateInMemory(config); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database and all collections and stops their background
+// tasks. Operations started after Close return ErrClosed or
+// ErrCollectionClosed; see Collection.Close for how in-flight operations are
+// drained. Calling Close more than once is a no-op.
 func (db *DB) Close() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -52,6 +93,9 @@ func (db *DB) Close() error {
 		return nil
 	}
 
+	// Mark closed first so no new collections are handed out while draining
+	db.closed = true
+
 	var errs []error
 	for name, coll := range db.collections {
 		if err := coll.Close(); err != nil {
@@ -59,51 +103,239 @@ func (db *DB) Close() error {
 		}
 	}
 
-	db.closed = true
+	db.sched.close()
+
 	if len(errs) > 0 {
 		return fmt.Errorf("errors closing collections: %v", errs)
 	}
 	return nil
 }
 
-// Collection returns a collection by name, creates if not exists
+// BackgroundTasks lists the periodic tasks of the database's collections,
+// sorted by collection and task name, with when each last ran, its last
+// error and when it runs next. All of them share one pool of
+// Config.BackgroundWorkers goroutines. With a maintenance window, the
+// window's own task is listed under collection "" and each collection's
+// maintenance tasks with their Maintenance status.
+func (db *DB) BackgroundTasks() []BackgroundTask {
+	tasks := append(db.sched.list(), db.maintenanceStatus()...)
+	sortTasks(tasks)
+	return tasks
+}
+
+// Collection returns a collection by name, creates if not exists. A
+// read-only database returns ErrCollectionNotFound instead of creating one.
+// Names must be a single path element not starting with "."; others return
+// ErrValidationFailed.
 func (db *DB) Collection(name string) (*Collection, error) {
+	return db.collection("Collection", name, nil)
+}
+
+// CollectionWithOptions is Collection with opts overriding the database's
+// options for this collection, so collections of different dimensions or
+// distance functions share one database:
+//
+//	passages, err := db.CollectionWithOptions("passages",
+//		vego.WithCollectionDimension(384), vego.WithCollectionDistance(hnsw.CosineDistance))
+//
+// The dimension, distance function, M, efConstruction and text index a
+// collection is created with are recorded in its settings file and used whenever it is
+// reopened, whatever the options passed to Open. Requesting an existing
+// collection with options that differ from its recorded settings returns
+// ErrValidationFailed instead of a collection its index and storage were
+// not built for.
+func (db *DB) CollectionWithOptions(name string, opts ...CollectionOption) (*Collection, error) {
+	return db.collection("CollectionWithOptions", name, opts)
+}
+
+func (db *DB) collection(op, name string, opts []CollectionOption) (*Collection, error) {
+	if err := checkCollectionName(name); err != nil {
+		return nil, wrapError(op, name, "", err)
+	}
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	var requested Config
+	for _, opt := range opts {
+		opt(&requested)
+	}
+	if requested.DistanceFunc != nil && metricName(requested.DistanceFunc) == "" {
+		return nil, wrapError(op, name, "", fmt.Errorf("%w: only built-in distance functions can be set per collection", ErrValidationFailed))
+	}
+
 	// Check if exists
 	if coll, exists := db.collections[name]; exists {
+		if err := checkCollectionOptions(coll.config, &requested); err != nil {
+			return nil, wrapError(op, name, "", err)
+		}
 		return coll, nil
 	}
 
-	// Create new collection
-	coll, err := db.createCollection(name)
+	// A read-only database opens collections created by the writer since
+	// Open, but never creates one
+	if db.config.ReadOnly {
+		if _, err := os.Stat(filepath.Join(db.path, name)); err != nil {
+			return nil, wrapError(op, name, "", ErrCollectionNotFound)
+		}
+	}
+
+	config := db.config
+	if len(opts) > 0 {
+		overridden := *db.config
+		for _, opt := range opts {
+			opt(&overridden)
+		}
+		if err := validateConfig(&overridden); err != nil {
+			return nil, wrapError(op, name, "", err)
+		}
+		config = &overridden
+	}
+
+	// Create new collection, or open one written by another process
+	coll, err := newCollection(context.Background(), name, filepath.Join(db.path, name), config, db.sched)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkCollectionOptions(coll.config, &requested); err != nil {
+		coll.Close()
+		return nil, wrapError(op, name, "", err)
+	}
 
 	db.collections[name] = coll
 	return coll, nil
 }
 
-// DropCollection removes a collection and all its data
+// checkCollectionOptions rejects the settings in requested that differ
+// from those of a collection's config; zero fields were not requested
+func checkCollectionOptions(config, requested *Config) error {
+	var conflicts []string
+	if requested.Dimension != 0 && requested.Dimension != config.Dimension {
+		conflicts = append(conflicts, fmt.Sprintf("dimension %d, requested %d", config.Dimension, requested.Dimension))
+	}
+	if requested.DistanceFunc != nil && !sameDistance(requested.DistanceFunc, config.DistanceFunc) {
+		have := metricName(config.DistanceFunc)
+		if have == "" {
+			have = "custom"
+		}
+		conflicts = append(conflicts, fmt.Sprintf("metric %s, requested %s", have, metricName(requested.DistanceFunc)))
+	}
+	if requested.M != 0 && requested.M != config.M {
+		conflicts = append(conflicts, fmt.Sprintf("M %d, requested %d", config.M, requested.M))
+	}
+	if requested.EfConstruction != 0 && requested.EfConstruction != config.EfConstruction {
+		conflicts = append(conflicts, fmt.Sprintf("efConstruction %d, requested %d", config.EfConstruction, requested.EfConstruction))
+	}
+	if requested.TextIndexField != "" && requested.TextIndexField != config.TextIndexField {
+		have := config.TextIndexField
+		if have == "" {
+			have = "none"
+		}
+		conflicts = append(conflicts, fmt.Sprintf("text index %s, requested %s", have, requested.TextIndexField))
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w: collection has %s", ErrValidationFailed, strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+// DropCollection drops collection name and deletes its files, see
+// Collection.Drop. Handles of the collection obtained before fail with
+// ErrCollectionDropped from then on, while Collection(name) creates a new,
+// empty collection. A collection created on disk by another process since
+// Open is dropped as well; a name without a collection returns
+// ErrCollectionNotFound.
 func (db *DB) DropCollection(name string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	coll, exists := db.collections[name]
-	if !exists {
-		return fmt.Errorf("collection %s not found", name)
+	if db.closed {
+		return ErrClosed
 	}
-
-	if err := coll.Drop(); err != nil {
-		return fmt.Errorf("failed to drop collection: %w", err)
+	if db.config.ReadOnly {
+		return wrapError("DropCollection", name, "", ErrReadOnly)
 	}
 
-	delete(db.collections, name)
+	if coll, exists := db.collections[name]; exists {
+		// Dropped even if its files could not all be deleted; a later
+		// DropCollection deletes the rest
+		delete(db.collections, name)
+		return coll.Drop()
+	}
+	if db.config.InMemory || checkCollectionName(name) != nil || !isCollectionDir(filepath.Join(db.path, name)) {
+		return wrapError("DropCollection", name, "", ErrCollectionNotFound)
+	}
+	if err := removeDir(filepath.Join(db.path, name)); err != nil {
+		return wrapError("DropCollection", name, "", err)
+	}
 	return nil
 }
 
+// RenameCollection renames collection oldName to newName, moving its
+// directory in one rename. An open collection is closed first, which saves
+// it and waits for its operations in flight, and reopened under the new
+// name: handles of it obtained before fail with ErrCollectionClosed, get
+// one with Collection(newName). A newName already taken returns
+// ErrCollectionExists and a missing oldName ErrCollectionNotFound.
+// Collections of an in-memory database cannot be renamed.
+func (db *DB) RenameCollection(oldName, newName string) error {
+	if err := checkCollectionName(newName); err != nil {
+		return wrapError("RenameCollection", newName, "", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	switch {
+	case db.config.ReadOnly:
+		return wrapError("RenameCollection", oldName, "", ErrReadOnly)
+	case db.config.InMemory:
+		return wrapError("RenameCollection", oldName, "", errNoFiles("rename"))
+	}
+
+	oldPath, newPath := filepath.Join(db.path, oldName), filepath.Join(db.path, newName)
+	coll, open := db.collections[oldName]
+	if !open && (checkCollectionName(oldName) != nil || !isCollectionDir(oldPath)) {
+		return wrapError("RenameCollection", oldName, "", ErrCollectionNotFound)
+	}
+	if _, exists := db.collections[newName]; exists {
+		return wrapError("RenameCollection", newName, "", ErrCollectionExists)
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return wrapError("RenameCollection", newName, "", ErrCollectionExists)
+	}
+	if !open {
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return wrapError("RenameCollection", oldName, "", err)
+		}
+		return nil
+	}
+
+	if err := coll.Close(); err != nil {
+		return wrapError("RenameCollection", oldName, "", err)
+	}
+	delete(db.collections, oldName)
+	name, path := newName, newPath
+	renameErr := os.Rename(oldPath, newPath)
+	if renameErr != nil {
+		// Reopen it where it is
+		name, path = oldName, oldPath
+	}
+	reopened, err := newCollection(context.Background(), name, path, coll.config, db.sched)
+	if err != nil {
+		return err
+	}
+	db.collections[name] = reopened
+	return wrapError("RenameCollection", oldName, "", renameErr)
+}
+
 // Collections returns list of collection names
 func (db *DB) Collections() []string {
 	db.mu.RLock()
@@ -116,12 +348,89 @@ func (db *DB) Collections() []string {
 	return names
 }
 
-func (db *DB) createCollection(name string) (*Collection, error) {
+// ListCollections returns the sorted names of the collections of the
+// database: those it has open and, on disk, every directory holding a
+// collection's settings or save manifest, including collections created by
+// another process since Open. The staging and trash directories of imports
+// and drops in progress are left out.
+func (db *DB) ListCollections() ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	names := make([]string, 0, len(db.collections))
+	for name := range db.collections {
+		names = append(names, name)
+	}
+	if !db.config.InMemory {
+		entries, err := os.ReadDir(db.path)
+		if err != nil {
+			return nil, wrapError("ListCollections", "", "", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if _, open := db.collections[name]; open || !entry.IsDir() || checkCollectionName(name) != nil {
+				continue
+			}
+			if isCollectionDir(filepath.Join(db.path, name)) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// checkCollectionName rejects names that are not a single path element or
+// that start with a dot, which staging and trash directories do
+func checkCollectionName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: invalid collection name %q", ErrValidationFailed, name)
+	}
+	return nil
+}
+
+// isCollectionDir reports whether dir holds a collection: its settings, or
+// for collections older than them, its save manifest
+func isCollectionDir(dir string) bool {
+	for _, name := range []string{settingsFileName, generationFileName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// trashPrefix starts the names of the directories removeDir moves
+// directories to before deleting them; loadCollections deletes any left
+const trashPrefix = ".trash-"
+
+// removeDir deletes dir after moving it into a new trash directory beside
+// it, so it disappears in one rename even if deleting its files is cut
+// short
+func removeDir(dir string) error {
+	trash, err := os.MkdirTemp(filepath.Dir(dir), trashPrefix+filepath.Base(dir)+"-")
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(dir, filepath.Join(trash, filepath.Base(dir))); err != nil {
+		os.Remove(trash)
+		return err
+	}
+	return os.RemoveAll(trash)
+}
+
+// createCollection opens collection name with the database's options; the
+// settings recorded by an existing collection take precedence
+func (db *DB) createCollection(ctx context.Context, name string) (*Collection, error) {
 	collPath := filepath.Join(db.path, name)
-	return NewCollection(name, collPath, db.config)
+	return newCollection(ctx, name, collPath, db.config, db.sched)
 }
 
-func (db *DB) loadCollections() error {
+func (db *DB) loadCollections(ctx context.Context) error {
 	entries, err := os.ReadDir(db.path)
 	if err != nil {
 		return err
@@ -132,7 +441,15 @@ func (db *DB) loadCollections() error {
 			continue
 		}
 
-		coll, err := db.createCollection(entry.Name())
+		// Left by an import or a drop that did not finish
+		if strings.HasPrefix(entry.Name(), exportStagingPrefix) || strings.HasPrefix(entry.Name(), trashPrefix) {
+			if !db.config.ReadOnly {
+				os.RemoveAll(filepath.Join(db.path, entry.Name()))
+			}
+			continue
+		}
+
+		coll, err := db.createCollection(ctx, entry.Name())
 		if err != nil {
 			return fmt.Errorf("load collection %s: %w", entry.Name(), err)
 		}
@@ -1,8 +1,13 @@
 package vego
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -176,6 +181,25 @@ func TestDBCollection(t *testing.T) {
 			t.Errorf("Expected at least 3 collections, got %d", len(names))
 		}
 	})
+	
+	t.Run("Invalid names", func(t *testing.T) {
+		for _, name := range []string{"", "../escape", "a/b", ".hidden", "."} {
+			if _, err := db.Collection(name); !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("Collection(%q) = %v, want ErrValidationFailed", name, err)
+			}
+			if _, err := db.CollectionWithOptions(name, WithCollectionM(8)); !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("CollectionWithOptions(%q) = %v, want ErrValidationFailed", name, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(db.path), "escape")); !os.IsNotExist(err) {
+			t.Errorf("collection created outside the database: %v", err)
+		}
+		for _, name := range db.Collections() {
+			if checkCollectionName(name) != nil {
+				t.Errorf("invalid collection %q registered", name)
+			}
+		}
+	})
 }
 
 // TestDBDropCollection tests dropping collections
@@ -241,6 +265,215 @@ func TestDBCollections(t *testing.T) {
 	}
 }
 
+func TestDBListCollections(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	for _, name := range []string{"beta", "alpha"} {
+		if _, err := db.Collection(name); err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+	}
+
+	// Created by another process, and directories that are no collection
+	other, err := NewCollection("gamma", filepath.Join(path, "gamma"), db.config)
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	other.Close()
+	for _, dir := range []string{"empty", trashPrefix + "old-1", exportStagingPrefix + "new-1"} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := db.ListCollections()
+	if err != nil {
+		t.Fatalf("ListCollections failed: %v", err)
+	}
+	if fmt.Sprint(names) != "[alpha beta gamma]" {
+		t.Errorf("ListCollections = %v", names)
+	}
+
+	db.Close()
+	if _, err := db.ListCollections(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ListCollections after Close = %v, want ErrClosed", err)
+	}
+
+	// Reopening deletes the trash a crashed drop left
+	db, err = Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := os.Stat(filepath.Join(path, trashPrefix+"old-1")); !os.IsNotExist(err) {
+		t.Errorf("Trash left after Open: %v", err)
+	}
+}
+
+// TestDBDropCollectionWithHandle drops a collection other goroutines are
+// using; their operations fail with ErrCollectionDropped and nothing is
+// written back
+func TestDBDropCollectionWithHandle(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.Insert(&Document{ID: "seed", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				err := coll.Insert(&Document{ID: fmt.Sprintf("doc-%d-%d", w, i), Vector: []float32{float32(i), 1, 2, 3}})
+				if err == nil {
+					_, err = coll.Search([]float32{1, 2, 3, 4}, 5)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := db.DropCollection("docs"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if !IsCollectionDropped(err) {
+			t.Errorf("Operation during drop failed with %v, want ErrCollectionDropped", err)
+		}
+	}
+
+	if err := coll.Save(); !errors.Is(err, ErrCollectionDropped) {
+		t.Errorf("Save after drop = %v, want ErrCollectionDropped", err)
+	}
+	if err := coll.Close(); err != nil {
+		t.Errorf("Close after drop failed: %v", err)
+	}
+	if err := coll.Drop(); !errors.Is(err, ErrCollectionDropped) {
+		t.Errorf("Second Drop = %v, want ErrCollectionDropped", err)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Files left after drop: %v", entries)
+	}
+	if err := db.DropCollection("docs"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Drop of a dropped collection = %v, want ErrCollectionNotFound", err)
+	}
+
+	// The name can be used again, for a new collection
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if n := coll.Count(); n != 0 {
+		t.Errorf("Recreated collection has %d documents", n)
+	}
+}
+
+func TestDBRenameCollection(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.CollectionWithOptions("old", WithCollectionM(8))
+	if err != nil {
+		t.Fatalf("CollectionWithOptions failed: %v", err)
+	}
+	if err := coll.Insert(&Document{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, err := db.Collection("taken"); err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		from, to string
+		want     error
+	}{
+		{"old", "taken", ErrCollectionExists},
+		{"missing", "other", ErrCollectionNotFound},
+		{"old", "../escape", ErrValidationFailed},
+	} {
+		if err := db.RenameCollection(tc.from, tc.to); !errors.Is(err, tc.want) {
+			t.Errorf("RenameCollection(%s, %s) = %v, want %v", tc.from, tc.to, err, tc.want)
+		}
+	}
+
+	// The open collection is saved, moved and reopened
+	if err := db.RenameCollection("old", "new"); err != nil {
+		t.Fatalf("RenameCollection failed: %v", err)
+	}
+	if _, err := coll.Get("a"); !errors.Is(err, ErrCollectionClosed) {
+		t.Errorf("Get through the old handle = %v, want ErrCollectionClosed", err)
+	}
+	names, err := db.ListCollections()
+	if err != nil || fmt.Sprint(names) != "[new taken]" {
+		t.Errorf("ListCollections = %v, %v", names, err)
+	}
+	renamed, err := db.CollectionWithOptions("new", WithCollectionM(8))
+	if err != nil {
+		t.Fatalf("CollectionWithOptions after rename failed: %v", err)
+	}
+	if doc, err := renamed.Get("a"); err != nil || doc.Vector[3] != 4 {
+		t.Errorf("Get after rename = %v, %v", doc, err)
+	}
+
+	// The rename survives a reopen
+	db.Close()
+	db, err = Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	renamed, err = db.Collection("new")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if renamed.Count() != 1 {
+		t.Errorf("Renamed collection has %d documents after reopen", renamed.Count())
+	}
+	if _, err := os.Stat(filepath.Join(path, "old")); !os.IsNotExist(err) {
+		t.Errorf("Old directory left: %v", err)
+	}
+
+	memory, err := OpenInMemory(WithDimension(4))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer memory.Close()
+	memory.Collection("docs")
+	if err := memory.RenameCollection("docs", "other"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("RenameCollection in memory = %v, want ErrNotSupported", err)
+	}
+}
+
 // TestDBPersistence tests database persistence
 func TestDBPersistence(t *testing.T) {
 	tmpDir := filepath.Join(os.TempDir(), "vego_persist_test")
@@ -325,13 +558,16 @@ func TestDBMultipleCollections(t *testing.T) {
 // TestOpenInvalidPath tests opening with invalid path
 func TestOpenInvalidPath(t *testing.T) {
 	t.Run("Invalid path", func(t *testing.T) {
-		// Try to create in a non-existent parent directory that can't be created
-		// This test may be platform-specific
-		_, err := Open("/invalid_path_that_does_not_exist/db")
-		// On Unix systems, this should fail with permission denied
-		// But we can't guarantee this test works on all platforms
-		if err != nil {
-			t.Logf("Got expected error for invalid path: %v", err)
+		// A regular file as parent cannot hold a directory on any platform
+		parent := filepath.Join(t.TempDir(), "not a directory")
+		if err := os.WriteFile(parent, []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		db, err := Open(filepath.Join(parent, "db"))
+		if err == nil {
+			db.Close()
+			t.Error("Expected error opening database under a regular file")
 		}
 	})
 }
@@ -347,3 +583,242 @@ func TestDBCollectionClosed(t *testing.T) {
 	// The behavior depends on implementation, we just ensure it doesn't panic
 	t.Logf("Collection on closed DB returned: %v", err)
 }
+
+// TestDBCloseDrainsInFlight tests that Close waits for or cancels in-flight searches
+func TestDBCloseDrainsInFlight(t *testing.T) {
+	db, cleanup := setupTestDB(t, WithDimension(32), WithCloseTimeout(200*time.Millisecond))
+	defer cleanup()
+
+	coll, err := db.Collection("drain")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	docs := make([]*Document, 500)
+	for i := range docs {
+		vec := make([]float32, 32)
+		for j := range vec {
+			vec[j] = float32((i*31+j*7)%97) / 97
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc%d", i), Vector: vec}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each search keeps hydrating documents until it fails or finishes
+			for n := 0; n < 20; n++ {
+				_, err := coll.SearchContext(ctx, docs[i].Vector, 50)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(i)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := db.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err == nil || IsClosed(err) || errors.Is(err, context.Canceled) {
+			continue
+		}
+		t.Errorf("Unexpected search error: %v", err)
+	}
+
+	// Double close is a no-op
+	if err := db.Close(); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+	if _, err := db.Collection("drain"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	if _, err := coll.Search(docs[0].Vector, 5); !IsClosed(err) {
+		t.Errorf("Expected closed error from search after close, got %v", err)
+	}
+	if err := coll.Close(); err != nil {
+		t.Errorf("Second collection Close failed: %v", err)
+	}
+}
+
+// TestDBPathWithSpacesAndUnicode tests save, load and storage rewrite under a
+// directory whose name contains spaces and non-ASCII characters
+func TestDBPathWithSpacesAndUnicode(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my vectors", "données 向量")
+
+	vector := func(i int) []float32 {
+		v := make([]float32, 16)
+		v[0] = float32(i)
+		return v
+	}
+
+	db, err := Open(dir, WithDimension(16))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("colección")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := coll.Insert(&Document{ID: fmt.Sprintf("doc %d", i), Vector: vector(i)}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Deleting and saving again rewrites the data file without the
+	// deleted documents
+	for i := 0; i < 5; i++ {
+		if err := coll.Delete(fmt.Sprintf("doc %d", i)); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := coll.Insert(&Document{ID: "doc 20", Vector: vector(20)}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(dir, WithDimension(16))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	coll, err = db.Collection("colección")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if coll.Count() != 16 {
+		t.Errorf("Expected 16 documents after reopen, got %d", coll.Count())
+	}
+	if _, err := coll.Get("doc 3"); !IsNotFound(err) {
+		t.Errorf("Expected deleted document to stay deleted, got %v", err)
+	}
+	results, err := coll.Search(vector(20), 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != "doc 20" {
+		t.Errorf("Expected doc 20 as nearest neighbor, got %v", results)
+	}
+
+	// No temporary files are left behind by atomic writes
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.Contains(d.Name(), ".tmp") {
+			t.Errorf("Leftover temporary file: %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+}
+
+func TestDBCollectionWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, WithDimension(128))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	passages, err := db.CollectionWithOptions("passages",
+		WithCollectionDimension(16), WithCollectionDistance(hnsw.CosineDistance))
+	if err != nil {
+		t.Fatalf("CollectionWithOptions failed: %v", err)
+	}
+	openai, err := db.CollectionWithOptions("openai",
+		WithCollectionDimension(32), WithCollectionM(8), WithCollectionEfConstruction(64))
+	if err != nil {
+		t.Fatalf("CollectionWithOptions failed: %v", err)
+	}
+	defaults, _ := db.Collection("defaults")
+
+	for _, c := range []struct {
+		coll *Collection
+		dim  int
+	}{{passages, 16}, {openai, 32}, {defaults, 128}} {
+		for i := 0; i < 20; i++ {
+			doc := createTestDocument(fmt.Sprintf("doc_%d", i), c.dim, nil)
+			doc.Vector[i%c.dim] += 1
+			if err := c.coll.Insert(doc); err != nil {
+				t.Fatalf("Insert of %d-dim vector failed: %v", c.dim, err)
+			}
+		}
+		if err := c.coll.Insert(createTestDocument("wrong", 128+16-c.dim, nil)); !errors.Is(err, ErrDimensionMismatch) {
+			t.Errorf("Insert of wrong dimension = %v", err)
+		}
+		if err := c.coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	db.Close()
+
+	// Reopening with other database options keeps the recorded settings
+	db, err = Open(dir, WithDimension(64), WithM(32))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	passages, _ = db.Collection("passages")
+	if passages.dimension != 16 || !sameDistance(passages.config.DistanceFunc, hnsw.CosineDistance) {
+		t.Errorf("passages reopened with dimension %d, metric %q", passages.dimension, metricName(passages.config.DistanceFunc))
+	}
+	doc, _ := passages.Get("doc_5")
+	query := append([]float32(nil), doc.Vector...)
+	for i := range query {
+		query[i] *= 3 // Cosine distance ignores the scale
+	}
+	results, err := passages.Search(query, 1)
+	if err != nil || len(results) != 1 || results[0].Document.ID != "doc_5" || results[0].Distance > 1e-5 {
+		t.Errorf("cosine search after reopen = %v, %v", results, err)
+	}
+
+	openai, _ = db.Collection("openai")
+	if openai.dimension != 32 || openai.config.M != 8 || openai.config.EfConstruction != 64 {
+		t.Errorf("openai reopened with dimension %d, M %d, efConstruction %d", openai.dimension, openai.config.M, openai.config.EfConstruction)
+	}
+
+	// Matching options are accepted, conflicting ones rejected
+	if _, err := db.CollectionWithOptions("passages", WithCollectionDimension(16), WithCollectionDistance(hnsw.CosineDistance)); err != nil {
+		t.Errorf("CollectionWithOptions with the recorded settings failed: %v", err)
+	}
+	_, err = db.CollectionWithOptions("passages", WithCollectionDimension(32), WithCollectionDistance(hnsw.L2Distance))
+	if !errors.Is(err, ErrValidationFailed) || !strings.Contains(err.Error(), "dimension 16, requested 32") || !strings.Contains(err.Error(), "metric cosine, requested l2") {
+		t.Errorf("conflicting options = %v", err)
+	}
+	if _, err := db.CollectionWithOptions("openai", WithCollectionM(16)); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("conflicting M = %v", err)
+	}
+
+	// Custom distance functions cannot be recorded
+	custom := func(a, b []float32) float32 { return 0 }
+	if _, err := db.CollectionWithOptions("custom", WithCollectionDistance(custom)); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("custom distance = %v", err)
+	}
+	if _, err := db.CollectionWithOptions("invalid", WithCollectionDimension(-1)); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("negative dimension = %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package vego
+
+import "context"
+
+// DistancesTo returns the distance from query to each document of ids, in
+// the same order, using the collection's distance function. The vectors are
+// read from the index, so no document is loaded from storage; use it to
+// re-rank candidates from another source without a Get per document.
+//
+// A document that does not exist, including one deleted since the IDs were
+// collected, gets NaN instead of failing the call; check with math.IsNaN.
+func (c *Collection) DistancesTo(ctx context.Context, query []float32, ids []string) ([]float32, error) {
+	_, done, err := c.begin(ctx, "DistancesTo")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := c.checkQuery("DistancesTo", query); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	nodeIDs := make([]int, len(ids))
+	for i, id := range ids {
+		nodeID, exists := c.docToNode[id]
+		if !exists {
+			nodeID = -1 // not a node; the index reports NaN
+		}
+		nodeIDs[i] = nodeID
+	}
+
+	distances, err := c.index.DistancesTo(query, nodeIDs)
+	if err != nil {
+		return nil, wrapError("DistancesTo", c.name, "", err)
+	}
+	return distances, nil
+}
@@ -0,0 +1,110 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+func TestDistancesTo(t *testing.T) {
+	ctx := context.Background()
+	coll := getTestCollection(t, 20, 5)
+	if err := coll.Delete("doc_007"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	query := []float32{3, 0, 1, 2}
+	ids := []string{"doc_022", "doc_000", "doc_007", "typo", "doc_013", "doc_000"}
+	distances, err := coll.DistancesTo(ctx, query, ids)
+	if err != nil {
+		t.Fatalf("DistancesTo failed: %v", err)
+	}
+	if len(distances) != len(ids) {
+		t.Fatalf("got %d distances, want %d", len(distances), len(ids))
+	}
+	for i, id := range ids {
+		doc, err := coll.Get(id)
+		if IsNotFound(err) {
+			if !math.IsNaN(float64(distances[i])) {
+				t.Errorf("%s: got %f, want NaN for a missing document", id, distances[i])
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", id, err)
+		}
+		if want := hnsw.L2Distance(query, doc.Vector); distances[i] != want {
+			t.Errorf("%s: got %f, want %f", id, distances[i], want)
+		}
+	}
+
+	if _, err := coll.DistancesTo(ctx, []float32{1, 2}, ids); !IsDimensionMismatch(err) {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+	if distances, err := coll.DistancesTo(ctx, query, nil); err != nil || len(distances) != 0 {
+		t.Errorf("empty IDs: got %v, %v", distances, err)
+	}
+}
+
+func TestSearchResultSimilarity(t *testing.T) {
+	ctx := context.Background()
+	for metric, fn := range metrics {
+		t.Run(metric, func(t *testing.T) {
+			coll, err := NewCollection("similarity", t.TempDir(), &Config{Dimension: 4, M: 16, EfConstruction: 200, DistanceFunc: fn})
+			if err != nil {
+				t.Fatalf("NewCollection failed: %v", err)
+			}
+			defer coll.Close()
+			if coll.Metric() != metric {
+				t.Errorf("Metric = %q, want %q", coll.Metric(), metric)
+			}
+
+			// Unit vectors at growing angles from the query are further by
+			// every metric
+			for i := 0; i < 8; i++ {
+				angle := 0.2 * float64(i)
+				vector := []float32{float32(math.Cos(angle)), float32(math.Sin(angle)), 0, 0}
+				if err := coll.Insert(&Document{ID: fmt.Sprintf("doc_%d", i), Vector: vector}); err != nil {
+					t.Fatalf("Insert failed: %v", err)
+				}
+			}
+			results, err := coll.SearchContext(ctx, []float32{1, 0, 0, 0}, 8)
+			if err != nil || len(results) != 8 {
+				t.Fatalf("SearchContext = %d results, %v", len(results), err)
+			}
+			for i, r := range results {
+				if want := fmt.Sprintf("doc_%d", i); r.Document.ID != want {
+					t.Fatalf("result %d is %s, want %s", i, r.Document.ID, want)
+				}
+				if r.Similarity != DistanceToScore(metric, r.Distance) {
+					t.Errorf("%s: Similarity %v, want %v", r.Document.ID, r.Similarity, DistanceToScore(metric, r.Distance))
+				}
+				if i > 0 && r.Similarity >= results[i-1].Similarity {
+					t.Errorf("%s is further than %s but has Similarity %v >= %v", r.Document.ID, results[i-1].Document.ID, r.Similarity, results[i-1].Similarity)
+				}
+			}
+			if math.Abs(float64(results[0].Similarity)-1) > 1e-6 {
+				t.Errorf("identical vector has Similarity %v, want 1", results[0].Similarity)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		metric   string
+		distance float32
+		want     float32
+	}{
+		{MetricCosine, 0.25, 0.75},
+		{MetricInnerProduct, -3, 3},
+		{MetricL2, 3, 0.25},
+		{"", 1, 0.5},
+		{"", -1, 2},
+	} {
+		if got := DistanceToScore(tc.metric, tc.distance); got != tc.want {
+			t.Errorf("DistanceToScore(%q, %v) = %v, want %v", tc.metric, tc.distance, got, tc.want)
+		}
+	}
+}
@@ -29,7 +29,7 @@ func (d *Document) Validate(dimension int) error {
 		return fmt.Errorf("invalid dimension: %d", dimension)
 	}
 	if len(d.Vector) != dimension {
-		return fmt.Errorf("vector dimension mismatch: expected %d, got %d", dimension, len(d.Vector))
+		return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, dimension, len(d.Vector))
 	}
 	return nil
 }
@@ -0,0 +1,204 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// TestEmptyCollection runs every public Collection method against a
+// collection without documents: none may panic or fail just because there
+// is nothing in it, and all report zero values
+func TestEmptyCollection(t *testing.T) {
+	ctx := context.Background()
+	path := t.TempDir()
+	opts := []Option{WithDimension(4), WithCheckpointRetention(2)}
+	db, err := Open(path, opts...)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("empty")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	query := []float32{1, 2, 3, 4}
+	filter := &MetadataFilter{Field: "n", Operator: "eq", Value: 1}
+
+	check := func(name string, n int, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s failed: %v", name, err)
+		} else if n != 0 {
+			t.Errorf("%s returned %d results, want 0", name, n)
+		}
+	}
+
+	runAll := func(coll *Collection) {
+		t.Helper()
+		if n := coll.Count(); n != 0 {
+			t.Errorf("Count = %d", n)
+		}
+		// Deleted documents may leave orphaned index nodes behind
+		stats := coll.Stats()
+		if stats.Count != 0 || stats.IndexNodes != stats.OrphanNodes || stats.Dimension != 4 {
+			t.Errorf("Stats = %+v", stats)
+		}
+
+		results, err := coll.SearchContext(ctx, query, 5)
+		check("SearchContext", len(results), err)
+		results, err = coll.Search(query, 5, WithEnrichment(func(context.Context, []string) (map[string]map[string]interface{}, error) {
+			t.Error("enricher called without results")
+			return nil, nil
+		}))
+		check("Search with enrichment", len(results), err)
+		results, err = coll.SearchWithFilter(query, 5, filter)
+		check("SearchWithFilter", len(results), err)
+		ids, err := coll.SearchIDs(ctx, query, 5, nil)
+		check("SearchIDs", len(ids), err)
+		ids, err = coll.SearchIDs(ctx, query, 5, filter)
+		check("SearchIDs with filter", len(ids), err)
+		batch, err := coll.SearchBatch([][]float32{query, query}, 5)
+		if err != nil || len(batch) != 2 || len(batch[0])+len(batch[1]) != 0 {
+			t.Errorf("SearchBatch = %v, %v", batch, err)
+		}
+		results, err = coll.SearchRadius(ctx, query, StreamOptions{Radius: 100})
+		check("SearchRadius", len(results), err)
+		hits, err := coll.SearchStream(ctx, query, StreamOptions{K: 5})
+		if err != nil {
+			t.Errorf("SearchStream failed: %v", err)
+		} else {
+			for hit := range hits {
+				t.Errorf("SearchStream sent %v", hit)
+			}
+		}
+
+		// Searches still validate their arguments
+		if _, err := coll.SearchContext(ctx, query, 0); !IsInvalidK(err) {
+			t.Errorf("SearchContext k=0: expected ErrInvalidK, got %v", err)
+		}
+		if _, err := coll.SearchContext(ctx, []float32{1}, 5); !IsDimensionMismatch(err) {
+			t.Errorf("SearchContext wrong dimension: expected ErrDimensionMismatch, got %v", err)
+		}
+		if _, err := coll.SearchSimilarTo(ctx, "missing", 5, nil); !IsNotFound(err) {
+			t.Errorf("SearchSimilarTo: expected ErrDocumentNotFound, got %v", err)
+		}
+
+		if _, err := coll.Get("missing"); !IsNotFound(err) {
+			t.Errorf("Get: expected ErrDocumentNotFound, got %v", err)
+		}
+		docs, err := coll.GetBatch([]string{"a", "b"})
+		check("GetBatch", len(docs), err)
+		got, err := coll.GetBatchOpts(ctx, []string{"a", "b", "a"}, GetOptions{})
+		if err != nil || len(got.Found) != 0 || len(got.Missing) != 2 {
+			t.Errorf("GetBatchOpts = %+v, %v", got, err)
+		}
+		distances, err := coll.DistancesTo(ctx, query, []string{"a"})
+		if err != nil || len(distances) != 1 || !math.IsNaN(float64(distances[0])) {
+			t.Errorf("DistancesTo = %v, %v", distances, err)
+		}
+		if _, err := coll.ContentHash(ctx); err != nil {
+			t.Errorf("ContentHash failed: %v", err)
+		}
+		if _, err := coll.Profile(ctx, ProfileOptions{Candidates: []ProfileCandidate{{}}}); !errors.Is(err, hnsw.ErrEmptyIndex) {
+			t.Errorf("Profile: expected ErrEmptyIndex, got %v", err)
+		}
+		if len(coll.Info()) != 0 {
+			t.Errorf("Info = %v", coll.Info())
+		}
+		if _, ok := coll.Model(); ok {
+			t.Error("Model recorded on an empty collection")
+		}
+		if report := coll.LoadReport(); report.IndexRebuilt {
+			t.Errorf("LoadReport = %+v", report)
+		}
+	}
+
+	runAll(coll)
+	if stats := coll.Stats(); stats.IndexNodes != 0 {
+		t.Errorf("new collection has %d index nodes", stats.IndexNodes)
+	}
+
+	// Writes that find nothing to change succeed
+	if err := coll.Delete("missing"); !IsNotFound(err) {
+		t.Errorf("Delete: expected ErrDocumentNotFound, got %v", err)
+	}
+	if err := coll.DeleteBatch([]string{"a", "b"}); err != nil {
+		t.Errorf("DeleteBatch failed: %v", err)
+	}
+	if err := coll.InsertBatch(nil); err != nil {
+		t.Errorf("InsertBatch(nil) failed: %v", err)
+	}
+	report, err := coll.Optimize(ctx, hnsw.OptimizeOptions{})
+	if err != nil || report.Visited != 0 || report.Nodes != 0 {
+		t.Errorf("Optimize = %+v, %v", report, err)
+	}
+	reindexed, err := coll.ReindexWhere(ctx, nil, func(*Document) ([]float32, error) {
+		t.Error("transform called on an empty collection")
+		return nil, nil
+	})
+	if err != nil || reindexed.Matched != 0 {
+		t.Errorf("ReindexWhere = %+v, %v", reindexed, err)
+	}
+	if err := coll.Import(ctx, nil); err != nil {
+		t.Errorf("Import(nil) failed: %v", err)
+	}
+	runAll(coll)
+
+	// An empty collection saves, reopens and checkpoints cleanly
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	db, coll = reopenCollection(t, db, path, "empty", opts...)
+	runAll(coll)
+	checkpoints, err := coll.Checkpoints()
+	if err != nil || len(checkpoints) == 0 {
+		t.Fatalf("Checkpoints = %v, %v", checkpoints, err)
+	}
+	view, err := db.CollectionAtCheckpoint("empty", checkpoints[0].ID)
+	if err != nil {
+		t.Fatalf("CollectionAtCheckpoint failed: %v", err)
+	}
+	runAll(view)
+	view.Close()
+
+	// A read-only reader of it refreshes without changes
+	roDB, err := Open(path, append(opts, WithReadOnly(true))...)
+	if err != nil {
+		t.Fatalf("Open read-only failed: %v", err)
+	}
+	ro, err := roDB.Collection("empty")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if changed, err := ro.Refresh(ctx); err != nil || changed {
+		t.Errorf("Refresh = %v, %v", changed, err)
+	}
+	runAll(ro)
+	roDB.Close()
+
+	// It stays usable
+	if err := coll.Insert(&Document{ID: "a", Vector: query}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	results, err := coll.SearchContext(ctx, query, 5)
+	if err != nil || len(results) != 1 {
+		t.Errorf("SearchContext after insert = %v, %v", results, err)
+	}
+
+	// Deleting every document empties it again
+	if err := coll.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	_, coll = reopenCollection(t, db, path, "empty", opts...)
+	runAll(coll)
+
+	if err := coll.Drop(); err != nil {
+		t.Errorf("Drop failed: %v", err)
+	}
+}
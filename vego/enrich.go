@@ -0,0 +1,110 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Enricher returns extra metadata for the documents with the given IDs,
+// keyed by ID. IDs it has nothing for may be left out of the map.
+type Enricher func(ctx context.Context, ids []string) (map[string]map[string]interface{}, error)
+
+// WithEnrichment calls enricher once per search, after filtering, with the
+// IDs of the results in result order, and merges what it returns into the
+// results' Metadata. Stored keys always win: enrichment never overwrites a
+// key the document already has. Filters only see stored metadata.
+//
+// The enricher runs without the collection's lock held, so it may search or
+// read other collections, or this one. SearchContext and SearchSimilarTo
+// apply it; SearchWithFilter and the streaming searches take no options.
+func WithEnrichment(enricher Enricher) SearchOption {
+	return func(o *SearchOptions) {
+		o.Enricher = enricher
+	}
+}
+
+// WithEnrichmentNamespace stores each result's enrichment as a nested map
+// under Metadata[key] instead of merging it at the top level. A document
+// that already has key is left as it is.
+func WithEnrichmentNamespace(key string) SearchOption {
+	return func(o *SearchOptions) {
+		o.EnrichmentNamespace = key
+	}
+}
+
+// WithEnrichmentOptional makes a failing enricher degrade to un-enriched
+// results, with the error logged, rather than failing the search
+func WithEnrichmentOptional(enabled bool) SearchOption {
+	return func(o *SearchOptions) {
+		o.EnrichmentOptional = enabled
+	}
+}
+
+// EnrichFrom returns an Enricher that looks the result IDs up in other and
+// returns the metadata of the documents found there. Vectors are not read;
+// IDs missing from other are left un-enriched.
+func EnrichFrom(other *Collection) Enricher {
+	return func(ctx context.Context, ids []string) (map[string]map[string]interface{}, error) {
+		batch, err := other.GetBatchOpts(ctx, ids, GetOptions{OmitVectors: true})
+		if err != nil {
+			return nil, err
+		}
+		enrichment := make(map[string]map[string]interface{}, len(batch.Found))
+		for id, doc := range batch.Found {
+			enrichment[id] = doc.Metadata
+		}
+		return enrichment, nil
+	}
+}
+
+// enrich applies options.Enricher to results; it does nothing without an
+// enricher or results
+func (c *Collection) enrich(ctx context.Context, op string, results []SearchResult, options *SearchOptions) ([]SearchResult, error) {
+	if options.Enricher == nil || len(results) == 0 {
+		return results, nil
+	}
+
+	ids := make([]string, 0, len(results))
+	seen := make(map[string]struct{}, len(results))
+	for _, r := range results {
+		if _, dup := seen[r.Document.ID]; !dup {
+			seen[r.Document.ID] = struct{}{}
+			ids = append(ids, r.Document.ID)
+		}
+	}
+
+	enrichment, err := options.Enricher(ctx, ids)
+	if err != nil {
+		if options.EnrichmentOptional && ctx.Err() == nil {
+			log.Printf("Warning: enrichment of %d results of collection %s failed: %v", len(ids), c.name, err)
+			return results, nil
+		}
+		return nil, wrapError(op, c.name, "", fmt.Errorf("enrichment failed: %w", err))
+	}
+
+	for _, r := range results {
+		extra := enrichment[r.Document.ID]
+		if len(extra) == 0 {
+			continue
+		}
+		if r.Document.Metadata == nil {
+			r.Document.Metadata = make(map[string]interface{})
+		}
+		target := r.Document.Metadata
+		if ns := options.EnrichmentNamespace; ns != "" {
+			if _, stored := target[ns]; stored {
+				continue
+			}
+			nested := make(map[string]interface{}, len(extra))
+			target[ns] = nested
+			target = nested
+		}
+		for k, v := range extra {
+			if _, stored := target[k]; !stored {
+				target[k] = v
+			}
+		}
+	}
+	return results, nil
+}
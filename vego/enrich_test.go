@@ -0,0 +1,170 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// enrichTestCollections returns getTestCollection's documents and a sibling
+// collection holding product attributes for the even-numbered documents
+func enrichTestCollections(t *testing.T) (docs, products *Collection) {
+	t.Helper()
+	docs = getTestCollection(t, 10, 10)
+	db, err := Open(t.TempDir(), WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	products, err = db.Collection("products")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	for i := 0; i < 20; i += 2 {
+		err := products.Insert(&Document{
+			ID:       fmt.Sprintf("doc_%03d", i),
+			Vector:   []float32{0, 0, 0, 1},
+			Metadata: map[string]interface{}{"n": "product", "name": fmt.Sprintf("product %d", i)},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	return docs, products
+}
+
+// countingEnricher records the IDs of every call to enricher
+func countingEnricher(enricher Enricher, calls *[][]string) Enricher {
+	return func(ctx context.Context, ids []string) (map[string]map[string]interface{}, error) {
+		*calls = append(*calls, append([]string(nil), ids...))
+		return enricher(ctx, ids)
+	}
+}
+
+func TestSearchEnrichment(t *testing.T) {
+	ctx := context.Background()
+	coll, products := enrichTestCollections(t)
+	query := []float32{4, 1, 2, 3}
+
+	var calls [][]string
+	results, err := coll.SearchContext(ctx, query, 8, WithEnrichment(countingEnricher(EnrichFrom(products), &calls)))
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	if len(calls) != 1 || len(calls[0]) != len(results) {
+		t.Fatalf("enricher called with %v for %d results", calls, len(results))
+	}
+	seen := make(map[string]bool)
+	for i, r := range results {
+		id := r.Document.ID
+		if calls[0][i] != id || seen[id] {
+			t.Errorf("enricher IDs %v do not match results in order, once each", calls[0])
+		}
+		seen[id] = true
+
+		var n int
+		fmt.Sscanf(id, "doc_%d", &n)
+		if got, _ := r.Document.GetInt("n"); got != int64(n) {
+			t.Errorf("%s: stored n overwritten with %v", id, r.Document.Metadata["n"])
+		}
+		name, enriched := r.Document.GetString("name")
+		if n%2 == 0 && name != fmt.Sprintf("product %d", n) {
+			t.Errorf("%s: name = %q, want product %d", id, name, n)
+		}
+		if n%2 == 1 && enriched {
+			t.Errorf("%s: has no product but was enriched with %q", id, name)
+		}
+	}
+
+	// Under a namespace; a stored key of the same name is kept
+	results, err = coll.SearchContext(ctx, query, 4,
+		WithEnrichment(EnrichFrom(products)), WithEnrichmentNamespace("product"))
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	for _, r := range results {
+		var n int
+		fmt.Sscanf(r.Document.ID, "doc_%d", &n)
+		nested, enriched := r.Document.Metadata["product"].(map[string]interface{})
+		if got, _ := r.Document.GetInt("n"); got != int64(n) || enriched != (n%2 == 0) {
+			t.Errorf("%s: unexpected metadata %v", r.Document.ID, r.Document.Metadata)
+		}
+		if enriched && (nested["n"] != "product" || nested["name"] != fmt.Sprintf("product %d", n)) {
+			t.Errorf("%s: unexpected enrichment %v", r.Document.ID, nested)
+		}
+	}
+	results, err = coll.SearchContext(ctx, query, 4,
+		WithEnrichment(EnrichFrom(products)), WithEnrichmentNamespace("n"))
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	for _, r := range results {
+		if _, ok := r.Document.GetInt("n"); !ok {
+			t.Errorf("%s: stored n replaced by %v", r.Document.ID, r.Document.Metadata["n"])
+		}
+	}
+
+	// The enricher may read the collection being searched
+	if _, err := coll.SearchContext(ctx, query, 4, WithEnrichment(EnrichFrom(coll))); err != nil {
+		t.Fatalf("SearchContext enriching from itself failed: %v", err)
+	}
+}
+
+func TestSearchEnrichmentOncePerSearch(t *testing.T) {
+	ctx := context.Background()
+	coll, products := enrichTestCollections(t)
+
+	// A selective filter makes the search widen several times
+	var calls [][]string
+	filter := &MetadataFilter{Field: "n", Operator: "gt", Value: 16}
+	results, err := coll.SearchSimilarTo(ctx, "doc_000", 3, filter,
+		WithEnrichment(countingEnricher(EnrichFrom(products), &calls)))
+	if err != nil {
+		t.Fatalf("SearchSimilarTo failed: %v", err)
+	}
+	if len(results) != 3 || len(calls) != 1 || len(calls[0]) != 3 {
+		t.Fatalf("got %d results and enricher calls %v, want 3 results and one call", len(results), calls)
+	}
+
+	// Filters see stored metadata only, and nothing is enriched when
+	// nothing is found
+	calls = nil
+	filter = &MetadataFilter{Field: "name", Operator: "eq", Value: "product 4"}
+	results, err = coll.SearchSimilarTo(ctx, "doc_000", 3, filter,
+		WithEnrichment(countingEnricher(EnrichFrom(products), &calls)))
+	if err != nil {
+		t.Fatalf("SearchSimilarTo failed: %v", err)
+	}
+	if len(results) != 0 || len(calls) != 0 {
+		t.Errorf("filter matched enrichment: %d results, enricher calls %v", len(results), calls)
+	}
+}
+
+func TestSearchEnrichmentErrors(t *testing.T) {
+	ctx := context.Background()
+	coll, _ := enrichTestCollections(t)
+	query := []float32{4, 1, 2, 3}
+
+	errEnricher := errors.New("attribute service down")
+	failing := func(context.Context, []string) (map[string]map[string]interface{}, error) {
+		return nil, errEnricher
+	}
+
+	if _, err := coll.SearchContext(ctx, query, 4, WithEnrichment(failing)); !errors.Is(err, errEnricher) {
+		t.Errorf("expected the enricher's error, got %v", err)
+	}
+
+	results, err := coll.SearchContext(ctx, query, 4, WithEnrichment(failing), WithEnrichmentOptional(true))
+	if err != nil {
+		t.Fatalf("SearchContext with optional enrichment failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for _, r := range results {
+		if len(r.Document.Metadata) != 1 {
+			t.Errorf("%s: unexpected metadata %v", r.Document.ID, r.Document.Metadata)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package vego
 import (
 	"errors"
 	"fmt"
+
+	hnsw "github.com/wzqhbustb/vego/index"
 )
 
 // Sentinel errors for common cases
@@ -13,6 +15,10 @@ var (
 	// ErrDuplicateID is returned when inserting a document with an existing ID
 	ErrDuplicateID = errors.New("document already exists")
 
+	// ErrDocumentChanged is reported by ReindexWhere for a document that
+	// was written while its new vector was computed; the write is kept
+	ErrDocumentChanged = errors.New("document changed concurrently")
+
 	// ErrDimensionMismatch is returned when vector dimension doesn't match collection
 	ErrDimensionMismatch = errors.New("vector dimension mismatch")
 
@@ -22,6 +28,17 @@ var (
 	// ErrCollectionClosed is returned when operating on a closed collection
 	ErrCollectionClosed = errors.New("collection is closed")
 
+	// ErrCollectionDropped is returned when operating on a collection that
+	// was dropped, through a handle obtained before the drop
+	ErrCollectionDropped = errors.New("collection was dropped")
+
+	// ErrCollectionExists is returned when renaming a collection to the
+	// name of another
+	ErrCollectionExists = errors.New("collection already exists")
+
+	// ErrClosed is returned when operating on a closed database
+	ErrClosed = errors.New("database is closed")
+
 	// ErrInvalidFilter is returned when filter expression is invalid
 	ErrInvalidFilter = errors.New("invalid filter expression")
 
@@ -33,6 +50,35 @@ var (
 
 	// ErrValidationFailed is returned when document validation fails
 	ErrValidationFailed = errors.New("validation failed")
+
+	// ErrCheckpointNotFound is returned when no retained checkpoint matches
+	// the requested time, ID or tag
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+	// ErrTagExists is returned when tagging a checkpoint with a tag another
+	// checkpoint of the collection already has
+	ErrTagExists = errors.New("checkpoint tag already exists")
+
+	// ErrReadOnly is returned when modifying a database opened read-only
+	ErrReadOnly = errors.New("database is read-only")
+
+	// ErrNotSupported is returned for operations a collection does not
+	// support in its configuration, such as Refresh on a sharded collection
+	ErrNotSupported = errors.New("operation not supported")
+
+	// ErrShardUnavailable is returned for documents of a shard that failed
+	// to open
+	ErrShardUnavailable = errors.New("shard unavailable")
+
+	// ErrInvalidK is returned when a search asks for k <= 0 results. It is the
+	// same value as the index's error, so errors.Is works for both layers.
+	ErrInvalidK = hnsw.ErrInvalidK
+
+	// ErrResultsTruncated is returned by SearchRadius, together with the
+	// results, when more hits than StreamOptions.MaxResults were found or the
+	// index was replaced while streaming. It is the same value as the index's
+	// error.
+	ErrResultsTruncated = hnsw.ErrResultsTruncated
 )
 
 // Error provides structured error information
@@ -57,6 +103,32 @@ func (e *Error) Error() string {
 // Unwrap returns the underlying error for errors.Is/As support
 func (e *Error) Unwrap() error { return e.Err }
 
+// BatchError reports per-item failures of a batch operation. Errors is
+// indexed like the batch input, with nil entries for items that succeeded.
+type BatchError struct {
+	Errors []error
+}
+
+// Error summarizes the failed items
+func (e *BatchError) Error() string {
+	failed, first := 0, -1
+	for i, err := range e.Errors {
+		if err != nil {
+			failed++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if failed == 0 {
+		return "no failures"
+	}
+	return fmt.Sprintf("%d of %d failed, first at %d: %v", failed, len(e.Errors), first, e.Errors[first])
+}
+
+// Unwrap returns the per-item errors for errors.Is/As support
+func (e *BatchError) Unwrap() []error { return e.Errors }
+
 // Helper functions for error checking
 
 // IsNotFound checks if an error is ErrDocumentNotFound
@@ -79,6 +151,27 @@ func IsCollectionClosed(err error) bool {
 	return errors.Is(err, ErrCollectionClosed)
 }
 
+// IsCollectionDropped checks if an error is ErrCollectionDropped
+func IsCollectionDropped(err error) bool {
+	return errors.Is(err, ErrCollectionDropped)
+}
+
+// IsClosed checks if an error is ErrClosed, ErrCollectionClosed or
+// ErrCollectionDropped
+func IsClosed(err error) bool {
+	return errors.Is(err, ErrClosed) || errors.Is(err, ErrCollectionClosed) || errors.Is(err, ErrCollectionDropped)
+}
+
+// IsInvalidK checks if an error is ErrInvalidK
+func IsInvalidK(err error) bool {
+	return errors.Is(err, ErrInvalidK)
+}
+
+// IsReadOnly checks if an error is ErrReadOnly
+func IsReadOnly(err error) bool {
+	return errors.Is(err, ErrReadOnly)
+}
+
 // IsValidationFailed checks if an error is ErrValidationFailed
 func IsValidationFailed(err error) bool {
 	return errors.Is(err, ErrValidationFailed)
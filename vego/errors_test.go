@@ -17,6 +17,7 @@ func TestSentinelErrors(t *testing.T) {
 		{"ErrDimensionMismatch", ErrDimensionMismatch, "vector dimension mismatch"},
 		{"ErrCollectionNotFound", ErrCollectionNotFound, "collection not found"},
 		{"ErrCollectionClosed", ErrCollectionClosed, "collection is closed"},
+		{"ErrClosed", ErrClosed, "database is closed"},
 		{"ErrInvalidFilter", ErrInvalidFilter, "invalid filter expression"},
 		{"ErrIndexCorrupted", ErrIndexCorrupted, "index corrupted"},
 		{"ErrStorageCorrupted", ErrStorageCorrupted, "storage corrupted"},
@@ -184,6 +185,27 @@ func TestIsCollectionClosed(t *testing.T) {
 	})
 }
 
+// TestIsClosed tests IsClosed helper
+func TestIsClosed(t *testing.T) {
+	t.Run("Database closed", func(t *testing.T) {
+		if !IsClosed(ErrClosed) {
+			t.Error("IsClosed should return true for ErrClosed")
+		}
+	})
+
+	t.Run("Wrapped collection closed", func(t *testing.T) {
+		if !IsClosed(wrapError("Search", "test", "", ErrCollectionClosed)) {
+			t.Error("IsClosed should return true for wrapped ErrCollectionClosed")
+		}
+	})
+
+	t.Run("Different error", func(t *testing.T) {
+		if IsClosed(ErrDocumentNotFound) {
+			t.Error("IsClosed should return false for other errors")
+		}
+	})
+}
+
 // TestIsValidationFailed tests IsValidationFailed helper
 func TestIsValidationFailed(t *testing.T) {
 	t.Run("Direct error", func(t *testing.T) {
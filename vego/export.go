@@ -0,0 +1,349 @@
+package vego
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// exportMagic starts every export stream
+	exportMagic = "VEGOCOLL"
+
+	// exportVersion is the version of the export format written by Export;
+	// ImportCollection reads versions up to it
+	exportVersion = 1
+
+	// exportStagingPrefix starts the names of the directories
+	// ImportCollection stages an export in under the database directory;
+	// loadCollections skips them
+	exportStagingPrefix = ".import-"
+)
+
+// ImportOptions contains options for ImportCollection
+type ImportOptions struct {
+	Overwrite bool // Replace a collection that already holds documents
+}
+
+// ImportOption is a functional option for ImportCollection
+type ImportOption func(*ImportOptions)
+
+// WithOverwrite lets ImportCollection replace a collection that already
+// holds documents; without it importing into one fails
+func WithOverwrite(enabled bool) ImportOption {
+	return func(o *ImportOptions) {
+		o.Overwrite = enabled
+	}
+}
+
+// exportFile is a file of an export, open for reading
+type exportFile struct {
+	name string // Slash-separated path relative to the collection directory
+	file *os.File
+	size int64
+}
+
+// Export writes the collection to w as a single stream that
+// ImportCollection reads back, on this machine or another: its settings,
+// including dimension, distance function and HNSW parameters, the saved
+// HNSW graph, document storage with vectors and metadata, and the ID
+// mappings. The collection is saved first, unless it is read-only, in which
+// case the save it last loaded is exported.
+//
+// The stream is the saved files of the collection, each preceded by its
+// path and size, after a header with the format version, and followed by a
+// SHA-256 checksum of everything before it. Writes are blocked only while
+// the collection saves; the files are read afterwards.
+func (c *Collection) Export(w io.Writer) error {
+	_, done, err := c.begin(context.Background(), "Export")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if c.config.InMemory {
+		return wrapError("Export", c.name, "", errNoFiles("export"))
+	}
+
+	files, checkpoint, err := c.openExport()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, f := range files {
+			f.file.Close()
+		}
+	}()
+
+	if err := writeExport(w, checkpoint, files); err != nil {
+		return wrapError("Export", c.name, "", err)
+	}
+	return nil
+}
+
+// openExport saves the collection, unless it is read-only, and opens the
+// files of the published save. Every save replaces its files rather than
+// rewriting them, so the open files keep their content through later saves.
+// The save gate is held until the files are open, so no write or other save
+// comes between the save and the files exported.
+func (c *Collection) openExport() ([]exportFile, uint64, error) {
+	if !c.config.ReadOnly {
+		c.saveGate.Lock()
+		defer c.saveGate.Unlock()
+		if err := c.saveGated(); err != nil {
+			return nil, 0, err
+		}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var files []exportFile
+	open := func(dir, name string) error {
+		return filepath.WalkDir(filepath.Join(dir, name), func(path string, entry os.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || strings.Contains(entry.Name(), ".tmp") {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			info, err := file.Stat()
+			if err != nil {
+				file.Close()
+				return err
+			}
+			files = append(files, exportFile{name: filepath.ToSlash(rel), file: file, size: info.Size()})
+			return nil
+		})
+	}
+
+	// Settings live in the collection directory; the rest comes from where
+	// it was loaded, a published set for a replica
+	err := open(c.path, settingsFileName)
+	for _, name := range setEntries {
+		if err == nil {
+			err = open(c.dataDir, name)
+		}
+	}
+	if err != nil {
+		for _, f := range files {
+			f.file.Close()
+		}
+		return nil, 0, wrapError("Export", c.name, "", err)
+	}
+	return files, c.published, nil
+}
+
+// writeExport writes the export stream of files, with a generation file
+// recording checkpoint as the published save
+func writeExport(w io.Writer, checkpoint uint64, files []exportFile) error {
+	sum := sha256.New()
+	out := io.MultiWriter(w, sum)
+
+	if _, err := io.WriteString(out, exportMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(out, binary.LittleEndian, uint32(exportVersion)); err != nil {
+		return err
+	}
+
+	generation, err := json.Marshal(generationInfo{Generation: checkpoint, Checkpoint: checkpoint})
+	if err != nil {
+		return err
+	}
+	if err := writeExportEntry(out, generationFileName, int64(len(generation)), bytes.NewReader(generation)); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := writeExportEntry(out, f.name, f.size, f.file); err != nil {
+			return fmt.Errorf("export %s: %w", f.name, err)
+		}
+	}
+
+	// An empty name ends the entries
+	if err := binary.Write(out, binary.LittleEndian, uint16(0)); err != nil {
+		return err
+	}
+	_, err = w.Write(sum.Sum(nil))
+	return err
+}
+
+// writeExportEntry writes one file of an export: its name and size, then
+// size bytes of r
+func writeExportEntry(w io.Writer, name string, size int64, r io.Reader) error {
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(name))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, name); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(size)); err != nil {
+		return err
+	}
+	_, err := io.CopyN(w, r, size)
+	return err
+}
+
+// ImportCollection creates collection name in db from a stream written by
+// Collection.Export. The collection keeps the settings it was exported
+// with, whatever the options db was opened with, and is saved as it was:
+// its index is loaded, not rebuilt.
+//
+// The stream is written to a staging directory and its checksum verified
+// before the collection is touched, so a truncated or corrupted stream
+// returns ErrStorageCorrupted and leaves db as it was. A newer format
+// version returns ErrNotSupported. Importing over a collection that holds
+// documents fails with ErrValidationFailed unless WithOverwrite is passed;
+// the collection is then closed and replaced.
+func ImportCollection(db *DB, name string, r io.Reader, opts ...ImportOption) (*Collection, error) {
+	options := &ImportOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if err := checkCollectionName(name); err != nil {
+		return nil, wrapError("ImportCollection", name, "", err)
+	}
+	switch {
+	case db.config.ReadOnly:
+		return nil, wrapError("ImportCollection", name, "", ErrReadOnly)
+	case db.config.InMemory:
+		return nil, wrapError("ImportCollection", name, "", errNoFiles("import"))
+	}
+
+	staging, err := os.MkdirTemp(db.path, exportStagingPrefix+name+"-")
+	if err != nil {
+		return nil, wrapError("ImportCollection", name, "", err)
+	}
+	defer os.RemoveAll(staging) // Gone once moved into place
+	if err := readExport(r, staging); err != nil {
+		return nil, wrapError("ImportCollection", name, "", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil, ErrClosed
+	}
+
+	path := filepath.Join(db.path, name)
+	if coll, exists := db.collections[name]; exists {
+		if n := coll.Count(); n > 0 && !options.Overwrite {
+			return nil, wrapError("ImportCollection", name, "", fmt.Errorf("%w: collection holds %d documents, see WithOverwrite", ErrValidationFailed, n))
+		}
+		if err := coll.Close(); err != nil {
+			return nil, wrapError("ImportCollection", name, "", err)
+		}
+		delete(db.collections, name)
+	} else if _, err := os.Stat(path); err == nil && !options.Overwrite {
+		// Created by another process since Open
+		return nil, wrapError("ImportCollection", name, "", fmt.Errorf("%w: collection exists, see WithOverwrite", ErrValidationFailed))
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return nil, wrapError("ImportCollection", name, "", err)
+	}
+	if err := os.Rename(staging, path); err != nil {
+		return nil, wrapError("ImportCollection", name, "", err)
+	}
+	coll, err := newCollection(context.Background(), name, path, db.config, db.sched)
+	if err != nil {
+		return nil, err
+	}
+	db.collections[name] = coll
+	return coll, nil
+}
+
+// readExport writes the files of the export stream r under dir and checks
+// its checksum
+func readExport(r io.Reader, dir string) error {
+	sum := sha256.New()
+	in := io.TeeReader(r, sum)
+	truncated := func(err error) error {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: export is truncated", ErrStorageCorrupted)
+		}
+		return err
+	}
+
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(in, magic); err != nil || string(magic) != exportMagic {
+		return fmt.Errorf("%w: not a collection export", ErrStorageCorrupted)
+	}
+	var version uint32
+	if err := binary.Read(in, binary.LittleEndian, &version); err != nil {
+		return truncated(err)
+	}
+	if version > exportVersion {
+		return fmt.Errorf("%w: export format version %d, newest supported is %d", ErrNotSupported, version, exportVersion)
+	}
+
+	hasSettings := false
+	for {
+		var nameLen uint16
+		if err := binary.Read(in, binary.LittleEndian, &nameLen); err != nil {
+			return truncated(err)
+		}
+		if nameLen == 0 {
+			break
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(in, name); err != nil {
+			return truncated(err)
+		}
+		var size uint64
+		if err := binary.Read(in, binary.LittleEndian, &size); err != nil {
+			return truncated(err)
+		}
+
+		rel := filepath.FromSlash(string(name))
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("%w: export holds file %q outside the collection", ErrStorageCorrupted, name)
+		}
+		hasSettings = hasSettings || rel == settingsFileName
+		if err := readExportFile(in, filepath.Join(dir, rel), int64(size)); err != nil {
+			return truncated(err)
+		}
+	}
+
+	want := sum.Sum(nil)
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(r, got); err != nil {
+		return truncated(err)
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: export checksum mismatch", ErrStorageCorrupted)
+	}
+	if !hasSettings {
+		return fmt.Errorf("%w: export has no %s", ErrStorageCorrupted, settingsFileName)
+	}
+	return nil
+}
+
+// readExportFile copies size bytes of r to a new file path
+func readExportFile(r io.Reader, path string, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(file, r, size); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,235 @@
+package vego
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src, err := Open(t.TempDir(), WithDimension(64))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer src.Close()
+
+	// Settings that differ from the destination database's
+	coll, err := src.CollectionWithOptions("docs", WithCollectionDimension(16),
+		WithCollectionDistance(hnsw.CosineDistance), WithTextIndex("content"))
+	if err != nil {
+		t.Fatalf("CollectionWithOptions failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(7))
+	docs := make([]*Document, 200)
+	for i := range docs {
+		vector := make([]float32, 16)
+		for j := range vector {
+			vector[j] = rng.Float32()
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc_%03d", i), Vector: vector, Metadata: map[string]interface{}{
+			"content": fmt.Sprintf("document number %d", i),
+			"even":    i%2 == 0,
+		}}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	for _, id := range []string{"doc_010", "doc_020"} {
+		if err := coll.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+
+	queries := make([][]float32, 10)
+	for i := range queries {
+		queries[i] = docs[i*17].Vector
+	}
+	searchAll := func(c *Collection) string {
+		t.Helper()
+		var sb strings.Builder
+		for _, q := range queries {
+			results, err := c.SearchContext(ctx, q, 10)
+			if err != nil {
+				t.Fatalf("SearchContext failed: %v", err)
+			}
+			for _, r := range results {
+				fmt.Fprintf(&sb, "%s %v %v %v;", r.Document.ID, r.Distance, r.Document.Metadata["content"], r.Document.Metadata["even"])
+			}
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	}
+	before := searchAll(coll)
+
+	var buf bytes.Buffer
+	if err := coll.Export(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	export := buf.Bytes()
+
+	dstPath := t.TempDir()
+	dst, err := Open(dstPath, WithDimension(64))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	imported, err := ImportCollection(dst, "copy", bytes.NewReader(export))
+	if err != nil {
+		t.Fatalf("ImportCollection failed: %v", err)
+	}
+	if imported.Count() != 198 || imported.Metric() != MetricCosine || imported.dimension != 16 {
+		t.Errorf("imported %d documents, metric %q, dimension %d", imported.Count(), imported.Metric(), imported.dimension)
+	}
+	if after := searchAll(imported); after != before {
+		t.Errorf("search results differ after import:\n%s\nwant\n%s", after, before)
+	}
+	if results, err := imported.HybridSearch(ctx, queries[0], "number 42", 1, 1); err != nil || len(results) != 1 || results[0].Document.ID != "doc_042" {
+		t.Errorf("HybridSearch after import = %v, %v", results, err)
+	}
+	if _, err := imported.Get("doc_010"); !IsNotFound(err) {
+		t.Errorf("deleted document imported: %v", err)
+	}
+
+	t.Run("Reopen", func(t *testing.T) {
+		if err := dst.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		dst, err = Open(dstPath, WithDimension(64))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		reopened, err := dst.Collection("copy")
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+		if after := searchAll(reopened); after != before {
+			t.Errorf("search results differ after reopening")
+		}
+	})
+	defer func() { dst.Close() }()
+
+	t.Run("Overwrite", func(t *testing.T) {
+		_, err := ImportCollection(dst, "copy", bytes.NewReader(export))
+		if !errors.Is(err, ErrValidationFailed) {
+			t.Fatalf("import over a non-empty collection = %v, want ErrValidationFailed", err)
+		}
+
+		// An empty collection is replaced without asking
+		if _, err := dst.Collection("empty"); err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+		if _, err := ImportCollection(dst, "empty", bytes.NewReader(export)); err != nil {
+			t.Errorf("import over an empty collection failed: %v", err)
+		}
+
+		replaced, err := ImportCollection(dst, "copy", bytes.NewReader(export), WithOverwrite(true))
+		if err != nil {
+			t.Fatalf("import with overwrite failed: %v", err)
+		}
+		if got, err := dst.Collection("copy"); err != nil || got != replaced || replaced.Count() != 198 {
+			t.Errorf("overwritten collection not registered: %v", err)
+		}
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		flipped := bytes.Clone(export)
+		flipped[len(flipped)/2] ^= 0xff
+		version := bytes.Clone(export)
+		version[len(exportMagic)] = exportVersion + 1
+		for name, tc := range map[string]struct {
+			data []byte
+			want error
+		}{
+			"flipped":   {flipped, ErrStorageCorrupted},
+			"truncated": {export[:len(export)-40], ErrStorageCorrupted},
+			"garbage":   {[]byte("not an export"), ErrStorageCorrupted},
+			"version":   {version, ErrNotSupported},
+		} {
+			_, err := ImportCollection(dst, "broken_"+name, bytes.NewReader(tc.data))
+			if !errors.Is(err, tc.want) {
+				t.Errorf("%s: %v, want %v", name, err, tc.want)
+			}
+		}
+
+		entries, err := os.ReadDir(dstPath)
+		if err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), "broken_") || strings.HasPrefix(entry.Name(), exportStagingPrefix) {
+				t.Errorf("failed import left %s behind", entry.Name())
+			}
+		}
+	})
+}
+
+func TestExportDuringWrites(t *testing.T) {
+	src, err := Open(t.TempDir(), WithDimension(8))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer src.Close()
+	coll, err := src.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	// Writers keep inserting while the collection saves and is exported
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				doc := &Document{ID: fmt.Sprintf("w%d_%05d", w, i), Vector: []float32{float32(w), float32(i), 1, 2, 3, 4, 5, 6}}
+				if err := coll.Insert(doc); err != nil {
+					t.Errorf("Insert failed: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	dst, err := Open(t.TempDir(), WithDimension(8))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dst.Close()
+	last := 0
+	for round := 0; round < 5; round++ {
+		var buf bytes.Buffer
+		if err := coll.Export(&buf); err != nil {
+			t.Fatalf("Export failed: %v", err)
+		}
+		imported, err := ImportCollection(dst, fmt.Sprintf("copy_%d", round), &buf)
+		if err != nil {
+			t.Fatalf("ImportCollection of round %d failed: %v", round, err)
+		}
+
+		// The files are of one save, so the saved index matches the documents
+		if report := imported.LoadReport(); report.IndexRebuilt {
+			t.Errorf("round %d: saved index unusable after import: %v", round, report.Reason)
+		}
+		if count := imported.Count(); count < last || count != len(imported.docToNode) {
+			t.Errorf("round %d: imported %d documents, %d indexed, %d before", round, count, len(imported.docToNode), last)
+		} else {
+			last = count
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -1,8 +1,11 @@
 package vego
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -422,6 +425,60 @@ func TestNestedFilter(t *testing.T) {
 	}
 }
 
+// TestNotFilter tests negation, alone and nested in And and Or filters
+func TestNotFilter(t *testing.T) {
+	coll, cleanup := setupFilterTest(t)
+	defer cleanup()
+
+	query := make([]float32, 64)
+	author := func(name string) Filter {
+		return &MetadataFilter{Field: "author", Operator: "eq", Value: name}
+	}
+	tags := func(tag string) Filter {
+		return &MetadataFilter{Field: "tags", Operator: "contains", Value: tag}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"not", &NotFilter{Filter: author("Alice")}, []string{"doc2", "doc4"}},
+		{"double negation", &NotFilter{Filter: &NotFilter{Filter: author("Alice")}}, []string{"doc1", "doc3"}},
+		// doc4 has no tags: the MetadataFilter is false, so NOT is true
+		{"missing field", &NotFilter{Filter: tags("tech")}, []string{"doc3", "doc4"}},
+		{"and not", &AndFilter{Filters: []Filter{
+			&MetadataFilter{Field: "type", Operator: "eq", Value: "article"},
+			&NotFilter{Filter: author("Alice")},
+		}}, []string{"doc2"}},
+		{"or not", &OrFilter{Filters: []Filter{
+			author("Charlie"),
+			&NotFilter{Filter: &MetadataFilter{Field: "views", Operator: "gte", Value: 100}},
+		}}, []string{"doc3", "doc4"}},
+		{"not of or", &NotFilter{Filter: &OrFilter{Filters: []Filter{author("Bob"), tags("life")}}}, []string{"doc1", "doc4"}},
+		{"nil", &NotFilter{}, nil},
+	}
+	for _, tt := range tests {
+		results, err := coll.SearchWithFilter(query, 10, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: SearchWithFilter failed: %v", tt.name, err)
+		}
+		got := resultIDs(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("%s: found %v, want %v", tt.name, got, tt.want)
+		}
+
+		filtered, err := coll.SearchFiltered(context.Background(), query, 10, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: SearchFiltered failed: %v", tt.name, err)
+		}
+		if len(filtered.Results) != len(tt.want) {
+			t.Errorf("%s: SearchFiltered found %d results, want %d", tt.name, len(filtered.Results), len(tt.want))
+		}
+	}
+}
+
 // TestFilterMissingField tests filter on missing field
 func TestFilterMissingField(t *testing.T) {
 	coll, cleanup := setupFilterTest(t)
@@ -565,3 +622,100 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// TestMetadataFilterLists tests the in and nin operators and matching
+// against slice fields
+func TestMetadataFilterLists(t *testing.T) {
+	docs := map[string]*Document{
+		"audio":    {Metadata: map[string]interface{}{"tags": []string{"audio", "wireless"}, "scores": []int{1, 2}, "year": 2024}},
+		"decoded":  {Metadata: map[string]interface{}{"tags": []interface{}{"gaming", "audio"}, "scores": []interface{}{2.0, 3.0}, "year": 2025.0}},
+		"office":   {Metadata: map[string]interface{}{"tags": []string{"office"}, "scores": []float64{4.5}, "year": int64(2023)}},
+		"untagged": {Metadata: map[string]interface{}{"year": "2024"}},
+	}
+
+	tests := []struct {
+		filter *MetadataFilter
+		want   []string
+	}{
+		{&MetadataFilter{Field: "year", Operator: "in", Value: []int{2024, 2025}}, []string{"audio", "decoded"}},
+		{&MetadataFilter{Field: "year", Operator: "in", Value: []float64{2023}}, []string{"office"}},
+		{&MetadataFilter{Field: "year", Operator: "in", Value: []string{"2024"}}, []string{"untagged"}},
+		{&MetadataFilter{Field: "year", Operator: "nin", Value: []interface{}{2024, 2025.0}}, []string{"office", "untagged"}},
+		{&MetadataFilter{Field: "year", Operator: "in", Value: 2024}, nil},
+		{&MetadataFilter{Field: "year", Operator: "nin", Value: 2024}, nil},
+		{&MetadataFilter{Field: "tags", Operator: "contains", Value: "audio"}, []string{"audio", "decoded"}},
+		{&MetadataFilter{Field: "tags", Operator: "contains", Value: "aud"}, nil},
+		{&MetadataFilter{Field: "tags", Operator: "in", Value: []string{"office", "gaming"}}, []string{"decoded", "office"}},
+		{&MetadataFilter{Field: "tags", Operator: "nin", Value: []string{"wireless"}}, []string{"decoded", "office"}},
+		{&MetadataFilter{Field: "scores", Operator: "contains", Value: 2}, []string{"audio", "decoded"}},
+		{&MetadataFilter{Field: "scores", Operator: "contains", Value: 4.5}, []string{"office"}},
+		{&MetadataFilter{Field: "scores", Operator: "in", Value: []int{3, 4}}, []string{"decoded"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, name := range []string{"audio", "decoded", "office", "untagged"} {
+			if tt.filter.Match(docs[name]) {
+				got = append(got, name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s %v: matched %v, want %v", tt.filter.Field, tt.filter.Operator, tt.filter.Value, got, tt.want)
+		}
+	}
+}
+
+// TestMetadataFilterListsPersisted tests list filters on metadata read back
+// from disk, where slices come back as []interface{} of float64 and string
+func TestMetadataFilterListsPersisted(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, _ := db.Collection("products")
+	docs := []*Document{
+		{ID: "headphones", Vector: []float32{1, 0, 0, 0}, Metadata: map[string]interface{}{"tags": []string{"audio", "wireless"}, "sizes": []int{1, 2}}},
+		{ID: "mouse", Vector: []float32{0, 1, 0, 0}, Metadata: map[string]interface{}{"tags": []string{"gaming"}, "sizes": []int{3}}},
+		{ID: "speaker", Vector: []float32{0, 0, 1, 0}, Metadata: map[string]interface{}{"tags": []string{"audio"}, "sizes": []int{2, 3}}},
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, _ = db.Collection("products")
+	if doc, err := coll.Get("mouse"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	} else if _, ok := doc.Metadata["sizes"].([]interface{}); !ok {
+		t.Fatalf("sizes reloaded as %T", doc.Metadata["sizes"])
+	}
+
+	tests := []struct {
+		filter *MetadataFilter
+		want   []string
+	}{
+		{&MetadataFilter{Field: "tags", Operator: "contains", Value: "audio"}, []string{"headphones", "speaker"}},
+		{&MetadataFilter{Field: "tags", Operator: "nin", Value: []string{"audio"}}, []string{"mouse"}},
+		{&MetadataFilter{Field: "sizes", Operator: "contains", Value: 2}, []string{"headphones", "speaker"}},
+		{&MetadataFilter{Field: "sizes", Operator: "in", Value: []int{1, 5}}, []string{"headphones"}},
+	}
+	query := []float32{1, 1, 1, 1}
+	for _, tt := range tests {
+		results, err := coll.SearchWithFilter(query, 10, tt.filter)
+		if err != nil {
+			t.Fatalf("SearchWithFilter failed: %v", err)
+		}
+		got := resultIDs(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s %v: found %v, want %v", tt.filter.Field, tt.filter.Operator, tt.filter.Value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package vego
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// defaultMaxFilterCandidates is the candidate count filtered searches
+// escalate to at most when Config.MaxFilterCandidates is 0
+const defaultMaxFilterCandidates = 10000
+
+// FilteredSearchResult is the outcome of SearchFiltered
+type FilteredSearchResult struct {
+	Results []SearchResult
+
+	// Escalations counts the searches run after the first, each over twice
+	// as many candidates as the one before, because too few matched
+	Escalations int
+
+	// Candidates is how many nearest documents the last search examined,
+	// summed over the shards of a sharded collection. Exhausted reports
+	// that the index holds fewer, so every document was examined and no
+	// further matches exist.
+	Candidates int
+	Exhausted  bool
+}
+
+// SearchFiltered finds the k nearest documents matching filter and reports
+// how hard it had to look. It searches the 2k nearest candidates first and
+// doubles their number while fewer than k match: up to 20k candidates, then,
+// unless disabled with WithFilterEscalation(false), up to
+// Config.MaxFilterCandidates. Each search only examines candidates the
+// previous ones did not, and the matches of all of them are merged. Once ctx
+// is done no further escalation starts and the matches found so far are
+// returned. A nil filter matches every document; options apply as in
+// SearchContext, and a boost widens k to the candidates it scores.
+func (c *Collection) SearchFiltered(ctx context.Context, query []float32, k int, filter Filter, opts ...SearchOption) (*FilteredSearchResult, error) {
+	ctx, done, err := c.begin(ctx, "SearchFiltered")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := c.checkQuery("SearchFiltered", query); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, wrapError("SearchFiltered", c.name, "", ErrInvalidK)
+	}
+
+	options := c.searchOptions(opts)
+	if err := c.checkBoost("SearchFiltered", options); err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = matchAll{}
+	}
+	result, err := c.searchFiltered(ctx, "SearchFiltered", query, boostCandidates(k, options), filter, options)
+	if err != nil {
+		return nil, err
+	}
+	result.Results = boost(result.Results, k, options)
+	if result.Results, err = c.enrich(ctx, "SearchFiltered", result.Results, options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// matchAll is the filter of filtered searches given a nil filter
+type matchAll struct{}
+
+func (matchAll) Match(*Document) bool { return true }
+
+// nextCandidates returns the candidate count a filtered search for k hits
+// tries after n, or 0 when there is none: n doubled up to 20k, then, with
+// escalation, doubled on up to the maximum
+func nextCandidates(n, k int, options *SearchOptions) int {
+	next := n * 2
+	if next <= k*20 {
+		return next
+	}
+	if !options.FilterEscalation {
+		return 0
+	}
+	limit := options.MaxFilterCandidates
+	if limit <= 0 {
+		limit = defaultMaxFilterCandidates
+	}
+	if n >= limit {
+		return 0
+	}
+	return min(next, limit)
+}
+
+// searchFiltered runs filtered searches over a growing number of candidates
+// until k of them match filter, see SearchFiltered. Results are not
+// enriched, so filter only sees stored metadata.
+func (c *Collection) searchFiltered(ctx context.Context, op string, query []float32, k int, filter Filter, options *SearchOptions) (*FilteredSearchResult, error) {
+	if c.shards != nil {
+		return c.shardSearchFiltered(ctx, op, query, k, filter, options)
+	}
+
+	result := &FilteredSearchResult{}
+	seen := make(map[string]bool)
+	var matched []SearchResult
+	attempts := 0
+	for n := 2 * k; n > 0; n = nextCandidates(n, k, options) {
+		// Escalations stop at the deadline with what was found so far
+		if attempts > 0 && ctx.Err() != nil {
+			break
+		}
+		exhausted, err := c.filterCandidates(ctx, op, query, n, filter, options, seen, &matched)
+		if err != nil {
+			if attempts > 0 && ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		attempts++
+		result.Candidates = n
+		result.Exhausted = exhausted
+		if len(matched) >= k || exhausted {
+			break
+		}
+	}
+	result.Escalations = attempts - 1
+
+	result.Results = mergeResults(matched, k)
+	return result, nil
+}
+
+// filterCandidates searches the n nearest documents to query and appends
+// those not in seen that match filter to matched, adding them to seen. It
+// reports whether the index held fewer than n nodes.
+func (c *Collection) filterCandidates(ctx context.Context, op string, query []float32, n int, filter Filter, options *SearchOptions, seen map[string]bool, matched *[]SearchResult) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	// An empty collection has no hits rather than an empty index error
+	if c.index.Len() == 0 {
+		return true, nil
+	}
+
+	hits, err := c.index.SearchWithParams(query, n, hnsw.SearchParams{
+		EfUpperLayers: options.EFUpperLayers,
+		EfBase:        options.EF,
+	})
+	if err != nil {
+		return false, wrapError(op, c.name, "", err)
+	}
+
+	// Only new candidates are loaded, all in one pass over storage
+	ids := make([]string, 0, len(hits))
+	fresh := make([]hnsw.SearchResult, 0, len(hits))
+	for _, hr := range hits {
+		docID, exists := c.nodeToDoc[hr.ID]
+		if !exists || seen[docID] {
+			continue // Skip deleted/orphaned nodes and nodes of in-progress batches
+		}
+		seen[docID] = true
+		ids = append(ids, docID)
+		fresh = append(fresh, hr)
+	}
+	// As in search, vectors are read from storage only when requested
+	docs, _, err := c.storage.getBatch(ids, options.Vectors, true)
+	if err != nil {
+		return false, wrapError(op, c.name, "", err)
+	}
+
+	for i, docID := range ids {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		doc, ok := docs[docID]
+		if !ok {
+			log.Printf("Warning: failed to load document %s: %v", docID, ErrDocumentNotFound)
+			continue
+		}
+		if filter.Match(doc) {
+			*matched = append(*matched, c.newResult(doc, fresh[i].Distance))
+		}
+	}
+	return len(hits) < n, nil
+}
+
+// shardSearchFiltered is searchFiltered for sharded collections: every
+// shard escalates on its own and their matches are merged, so
+// Escalations is that of the shard that escalated most
+func (c *Collection) shardSearchFiltered(ctx context.Context, op string, query []float32, k int, filter Filter, options *SearchOptions) (*FilteredSearchResult, error) {
+	var mu sync.Mutex
+	result := &FilteredSearchResult{Exhausted: true}
+	var merged []SearchResult
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		r, err := shard.searchFiltered(ctx, op, query, k, filter, options)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		merged = append(merged, r.Results...)
+		result.Escalations = max(result.Escalations, r.Escalations)
+		result.Candidates += r.Candidates
+		result.Exhausted = result.Exhausted && r.Exhausted
+		return nil
+	})
+	if len(failed) == len(c.shards) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, c.shardError(op, failed)
+	}
+	for _, f := range failed {
+		log.Printf("Warning: %s of collection %s skipped shard %d: %v", op, c.name, f.Shard, f.Err)
+	}
+
+	result.Results = mergeResults(merged, k)
+	return result, nil
+}
+
+// mergeResults sorts results closest first and keeps the k nearest. Ties
+// are broken by document ID so the order does not depend on how the
+// results were gathered.
+func mergeResults(results []SearchResult, k int) []SearchResult {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Document.ID < results[j].Document.ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	return results
+}
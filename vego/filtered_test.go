@@ -0,0 +1,192 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+func TestSearchFilteredEscalates(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a 100k document index")
+	}
+	const dim, n = 8, 100000
+	rng := rand.New(rand.NewSource(7))
+	docs := make([]*Document, n)
+	for i := range docs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		// One document in a hundred is in bucket 0
+		docs[i] = &Document{ID: fmt.Sprintf("doc_%06d", i), Vector: v, Metadata: map[string]interface{}{"bucket": i % 100}}
+	}
+
+	db, err := OpenInMemory(WithDimension(dim), WithM(8), WithEfConstruction(64))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer db.Close()
+	coll, _ := db.Collection("docs")
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	ctx := context.Background()
+	filter := &MetadataFilter{Field: "bucket", Operator: "eq", Value: 0}
+	for q := 0; q < 5; q++ {
+		query := docs[rng.Intn(n)].Vector
+		result, err := coll.SearchFiltered(ctx, query, 10, filter)
+		if err != nil {
+			t.Fatalf("SearchFiltered failed: %v", err)
+		}
+		if len(result.Results) != 10 {
+			t.Fatalf("query %d: %d results, want 10 (escalations %d, candidates %d)",
+				q, len(result.Results), result.Escalations, result.Candidates)
+		}
+		if result.Escalations == 0 {
+			t.Errorf("query %d: no escalations reported", q)
+		}
+		for i, r := range result.Results {
+			if b, _ := r.Document.GetInt("bucket"); b != 0 {
+				t.Errorf("query %d: result %s is in bucket %d", q, r.Document.ID, b)
+			}
+			if i > 0 && r.Distance < result.Results[i-1].Distance {
+				t.Errorf("query %d: results not sorted at %d", q, i)
+			}
+		}
+
+		// SearchWithFilter and SearchIDs filter during the traversal and
+		// find the same matches
+		results, err := coll.SearchWithFilter(query, 10, filter)
+		if err != nil || fmt.Sprint(resultIDs(results)) != fmt.Sprint(resultIDs(result.Results)) {
+			t.Errorf("query %d: SearchWithFilter = %v, %v", q, resultIDs(results), err)
+		}
+		ids, err := coll.SearchIDs(ctx, query, 10, filter)
+		if err != nil || len(ids) != 10 {
+			t.Errorf("query %d: SearchIDs = %d hits, %v", q, len(ids), err)
+		}
+	}
+
+	// Without escalation the search stops at 20k candidates, under-filled
+	noEscalation := func(o *SearchOptions) { o.FilterEscalation = false }
+	result, err := coll.SearchFiltered(ctx, docs[0].Vector, 10, filter, noEscalation)
+	if err != nil {
+		t.Fatalf("SearchFiltered failed: %v", err)
+	}
+	if len(result.Results) >= 10 || result.Candidates != 160 || result.Escalations != 3 {
+		t.Errorf("without escalation: %d results, %d candidates, %d escalations",
+			len(result.Results), result.Candidates, result.Escalations)
+	}
+}
+
+func TestSearchFilteredLimits(t *testing.T) {
+	db, err := OpenInMemory(WithDimension(8), WithMaxFilterCandidates(100))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer db.Close()
+	coll, _ := db.Collection("docs")
+	docs := shardTestDocs(rand.New(rand.NewSource(8)), 500, 8)
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	ctx := context.Background()
+	none := &MetadataFilter{Field: "n", Operator: "lt", Value: 0}
+
+	// Escalation stops at the cap
+	result, err := coll.SearchFiltered(ctx, docs[0].Vector, 2, none)
+	if err != nil {
+		t.Fatalf("SearchFiltered failed: %v", err)
+	}
+	if len(result.Results) != 0 || result.Candidates != 100 || result.Exhausted {
+		t.Errorf("capped search = %+v", result)
+	}
+
+	// Or once every document was a candidate
+	result, err = coll.SearchFiltered(ctx, docs[0].Vector, 200, none)
+	if err != nil {
+		t.Fatalf("SearchFiltered failed: %v", err)
+	}
+	if !result.Exhausted || result.Candidates != 800 || result.Escalations != 1 {
+		t.Errorf("exhaustive search = %+v", result)
+	}
+
+	// A deadline that passed before the first search fails it
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := coll.SearchFiltered(expired, docs[0].Vector, 2, none); err != context.DeadlineExceeded {
+		t.Errorf("SearchFiltered past its deadline = %v", err)
+	}
+
+	// A nil filter matches every document
+	result, err = coll.SearchFiltered(ctx, docs[0].Vector, 5, nil)
+	if err != nil || len(result.Results) != 5 || result.Results[0].Document.ID != docs[0].ID {
+		t.Errorf("SearchFiltered without filter = %+v, %v", result, err)
+	}
+}
+
+func TestSearchWithFilterSelective(t *testing.T) {
+	const dim, n, k = 8, 5000, 10
+	rng := rand.New(rand.NewSource(3))
+	docs := make([]*Document, n)
+	for i := range docs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc_%05d", i), Vector: v, Metadata: map[string]interface{}{"bucket": i % 100}}
+	}
+
+	// Without escalation post-filtering would stop at 20k candidates, which
+	// hold about two matches of a 1% filter
+	db, err := OpenInMemory(WithDimension(dim), WithM(8), WithEfConstruction(64), WithFilterEscalation(false))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer db.Close()
+	coll, _ := db.Collection("docs")
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	filter := &MetadataFilter{Field: "bucket", Operator: "eq", Value: 0}
+	var recall float64
+	for q := 0; q < 20; q++ {
+		query := docs[rng.Intn(n)].Vector
+		results, err := coll.SearchWithFilter(query, k, filter)
+		if err != nil {
+			t.Fatalf("SearchWithFilter failed: %v", err)
+		}
+		if len(results) != k {
+			t.Fatalf("query %d: %d results, want %d", q, len(results), k)
+		}
+
+		// Exact nearest matches
+		var truth []SearchResult
+		for i := 0; i < n; i += 100 {
+			truth = append(truth, SearchResult{Document: docs[i], Distance: hnsw.L2Distance(query, docs[i].Vector)})
+		}
+		sort.Slice(truth, func(i, j int) bool { return truth[i].Distance < truth[j].Distance })
+		want := make(map[string]bool, k)
+		for _, r := range truth[:k] {
+			want[r.Document.ID] = true
+		}
+		for _, r := range results {
+			if b, _ := r.Document.GetInt("bucket"); b != 0 {
+				t.Fatalf("query %d: result %s is in bucket %d", q, r.Document.ID, b)
+			}
+			if want[r.Document.ID] {
+				recall++
+			}
+		}
+	}
+	if recall /= 20 * k; recall < 0.9 {
+		t.Errorf("recall of a 1%% filter = %.3f", recall)
+	}
+}
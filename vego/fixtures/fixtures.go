@@ -0,0 +1,160 @@
+// Package fixtures ships a small embedded dataset for examples and tests:
+// a few hundred short text snippets about eight topics with precomputed
+// 32-dimensional unit-norm embeddings, and queries labeled with the
+// snippets relevant to them.
+//
+// The embeddings are synthetic: each snippet is embedded as the normalized
+// sum of word vectors in which words about the same subject share a
+// component (see gen.go). Snippets about the same subject are therefore
+// nearest neighbors, which makes search results meaningful to read and lets
+// tests assert recall without downloading a model.
+//
+//	db, _ := vego.Open(dir, vego.WithDimension(fixtures.Dimension))
+//	coll, _ := fixtures.LoadCollection(db, "snippets")
+//	for _, q := range fixtures.Queries() {
+//		results, _ := coll.Search(q.Vector, len(q.Relevant))
+//		...
+//	}
+package fixtures
+
+//go:generate go run gen.go
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/wzqhbustb/vego/vego"
+)
+
+// Dimension is the dimension of the fixture embeddings
+const Dimension = 32
+
+//go:embed data.json.gz
+var compressed []byte
+
+// Snippet is a document of the dataset
+type Snippet struct {
+	ID       string    `json:"id"`
+	Text     string    `json:"text"`
+	Topic    string    `json:"topic"`    // e.g. "cooking"
+	Subtopic string    `json:"subtopic"` // e.g. "bread"
+	Vector   []float32 `json:"vector"`
+}
+
+// Query is a query of the dataset with the IDs of its relevant snippets:
+// its exact nearest neighbors by L2 distance, nearest first
+type Query struct {
+	Text     string    `json:"text"`
+	Topic    string    `json:"topic"`
+	Subtopic string    `json:"subtopic"`
+	Vector   []float32 `json:"vector"`
+	Relevant []string  `json:"relevant"`
+}
+
+type dataset struct {
+	Dimension int       `json:"dimension"`
+	Snippets  []Snippet `json:"snippets"`
+	Queries   []Query   `json:"queries"`
+}
+
+var (
+	loadOnce sync.Once
+	data     dataset
+	loadErr  error
+)
+
+// load decodes the embedded dataset once
+func load() (*dataset, error) {
+	loadOnce.Do(func() {
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			loadErr = fmt.Errorf("fixtures: %w", err)
+			return
+		}
+		defer zr.Close()
+		if err := json.NewDecoder(zr).Decode(&data); err != nil {
+			loadErr = fmt.Errorf("fixtures: %w", err)
+			return
+		}
+		if data.Dimension != Dimension {
+			loadErr = fmt.Errorf("fixtures: embedded dimension %d, want %d", data.Dimension, Dimension)
+		}
+	})
+	return &data, loadErr
+}
+
+// mustLoad returns the dataset; the embedded file is generated and tested,
+// so failing to decode it is a build defect
+func mustLoad() *dataset {
+	d, err := load()
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Snippets returns a copy of the snippets of the dataset
+func Snippets() []Snippet {
+	d := mustLoad()
+	out := make([]Snippet, len(d.Snippets))
+	for i, s := range d.Snippets {
+		s.Vector = append([]float32(nil), s.Vector...)
+		out[i] = s
+	}
+	return out
+}
+
+// Queries returns a copy of the queries of the dataset
+func Queries() []Query {
+	d := mustLoad()
+	out := make([]Query, len(d.Queries))
+	for i, q := range d.Queries {
+		q.Vector = append([]float32(nil), q.Vector...)
+		q.Relevant = append([]string(nil), q.Relevant...)
+		out[i] = q
+	}
+	return out
+}
+
+// Documents returns the snippets as documents with "text", "topic" and
+// "subtopic" metadata
+func Documents() []*vego.Document {
+	snippets := Snippets()
+	docs := make([]*vego.Document, len(snippets))
+	for i, s := range snippets {
+		docs[i] = &vego.Document{
+			ID:     s.ID,
+			Vector: s.Vector,
+			Metadata: map[string]interface{}{
+				"text":     s.Text,
+				"topic":    s.Topic,
+				"subtopic": s.Subtopic,
+			},
+		}
+	}
+	return docs
+}
+
+// LoadCollection inserts the snippets into collection name of db, which
+// must be opened with vego.WithDimension(Dimension), and returns the
+// collection. The collection must not already hold snippets of the dataset.
+func LoadCollection(db *vego.DB, name string) (*vego.Collection, error) {
+	if _, err := load(); err != nil {
+		return nil, err
+	}
+	coll, err := db.Collection(name)
+	if err != nil {
+		return nil, err
+	}
+	if dim := coll.Stats().Dimension; dim != Dimension {
+		return nil, fmt.Errorf("fixtures: collection %s has dimension %d, want %d: %w", name, dim, Dimension, vego.ErrDimensionMismatch)
+	}
+	if err := coll.InsertBatch(Documents()); err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
@@ -0,0 +1,118 @@
+package fixtures
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wzqhbustb/vego/vego"
+)
+
+func TestDataset(t *testing.T) {
+	if len(compressed) >= 1<<20 {
+		t.Errorf("embedded dataset is %d bytes, want < 1MB", len(compressed))
+	}
+
+	snippets := Snippets()
+	if len(snippets) < 200 {
+		t.Fatalf("got %d snippets, want a few hundred", len(snippets))
+	}
+	ids := make(map[string]bool, len(snippets))
+	for _, s := range snippets {
+		if ids[s.ID] {
+			t.Fatalf("duplicate snippet ID %s", s.ID)
+		}
+		ids[s.ID] = true
+		if len(s.Vector) != Dimension || s.Text == "" || s.Topic == "" {
+			t.Fatalf("incomplete snippet %+v", s)
+		}
+	}
+
+	queries := Queries()
+	if len(queries) == 0 {
+		t.Fatal("no queries")
+	}
+	for _, q := range queries {
+		if len(q.Vector) != Dimension || len(q.Relevant) == 0 {
+			t.Fatalf("incomplete query %+v", q)
+		}
+		for _, id := range q.Relevant {
+			if !ids[id] {
+				t.Fatalf("query %q labels unknown snippet %s", q.Text, id)
+			}
+		}
+	}
+
+	// Callers get copies
+	snippets[0].Vector[0] = 42
+	queries[0].Relevant[0] = "changed"
+	if Snippets()[0].Vector[0] == 42 || Queries()[0].Relevant[0] == "changed" {
+		t.Error("modifying returned data changed the dataset")
+	}
+}
+
+// TestRecall searches the whole stack with default settings and checks the
+// labeled snippets come back
+func TestRecall(t *testing.T) {
+	ctx := context.Background()
+	for _, reopen := range []bool{false, true} {
+		dir := t.TempDir()
+		db, err := vego.Open(dir, vego.WithDimension(Dimension))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		coll, err := LoadCollection(db, "snippets")
+		if err != nil {
+			t.Fatalf("LoadCollection failed: %v", err)
+		}
+		if reopen {
+			if err := db.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if db, err = vego.Open(dir, vego.WithDimension(Dimension)); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			if coll, err = db.Collection("snippets"); err != nil {
+				t.Fatalf("Collection failed: %v", err)
+			}
+		}
+
+		hits, total := 0, 0
+		for _, q := range Queries() {
+			results, err := coll.SearchContext(ctx, q.Vector, len(q.Relevant))
+			if err != nil {
+				t.Fatalf("SearchContext failed: %v", err)
+			}
+			found := make(map[string]bool, len(results))
+			for _, r := range results {
+				found[r.Document.ID] = true
+				if r.Document.Metadata["topic"] != q.Topic {
+					t.Errorf("query %q (%s) returned %s about %v", q.Text, q.Topic, r.Document.ID, r.Document.Metadata["topic"])
+				}
+			}
+			for _, id := range q.Relevant {
+				if found[id] {
+					hits++
+				}
+			}
+			total += len(q.Relevant)
+		}
+
+		recall := float64(hits) / float64(total)
+		t.Logf("reopen=%v: recall %.3f over %d queries", reopen, recall, len(Queries()))
+		if recall < 0.9 {
+			t.Errorf("reopen=%v: recall %.3f, want >= 0.9", reopen, recall)
+		}
+		db.Close()
+	}
+}
+
+func TestLoadCollectionDimension(t *testing.T) {
+	db, err := vego.Open(t.TempDir(), vego.WithDimension(Dimension+1))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := LoadCollection(db, "snippets"); !vego.IsDimensionMismatch(err) {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
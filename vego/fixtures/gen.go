@@ -0,0 +1,288 @@
+//go:build ignore
+
+// gen writes data.json.gz, the dataset embedded by package fixtures.
+//
+// Snippets are composed from per-subtopic vocabularies and embedded as the
+// normalized sum of word vectors: every word has a random direction, and
+// words of the same subtopic and topic share a common component, so snippets
+// about the same subject end up close together. A query's relevant
+// documents are its exact nearest neighbors under L2 distance.
+//
+// Run: go generate ./vego/fixtures
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	dimension        = 32
+	snippetsPerSub   = 10
+	queriesPerSub    = 2
+	relevantPerQuery = 10
+)
+
+type subtopic struct {
+	name  string
+	words []string
+}
+
+type topic struct {
+	name  string
+	words []string
+	subs  []subtopic
+}
+
+var topics = []topic{
+	{"cooking", []string{"kitchen", "recipe", "cook", "flavor"}, []subtopic{
+		{"bread", []string{"sourdough", "starter", "dough", "crust", "flour", "proofing", "loaf", "yeast"}},
+		{"pasta", []string{"pasta", "noodles", "semolina", "sauce", "tomato", "basil", "parmesan", "boil"}},
+		{"grilling", []string{"grill", "charcoal", "smoke", "brisket", "marinade", "barbecue", "steak", "sear"}},
+		{"coffee", []string{"coffee", "espresso", "beans", "roast", "grinder", "brew", "crema", "pourover"}},
+	}},
+	{"travel", []string{"trip", "travel", "journey", "visit"}, []subtopic{
+		{"flights", []string{"flight", "airport", "layover", "boarding", "airline", "luggage", "jetlag", "seat"}},
+		{"hiking", []string{"trail", "summit", "backpack", "hiking", "campsite", "boots", "ridge", "tent"}},
+		{"cities", []string{"museum", "subway", "cathedral", "downtown", "cafe", "neighborhood", "tram", "plaza"}},
+		{"beaches", []string{"beach", "surf", "snorkel", "reef", "sunscreen", "island", "tide", "lagoon"}},
+	}},
+	{"software", []string{"code", "software", "developer", "program"}, []subtopic{
+		{"databases", []string{"database", "query", "index", "transaction", "schema", "replica", "sql", "table"}},
+		{"concurrency", []string{"goroutine", "mutex", "channel", "deadlock", "thread", "race", "lock", "scheduler"}},
+		{"testing", []string{"test", "assertion", "coverage", "fixture", "mock", "benchmark", "regression", "flaky"}},
+		{"deployment", []string{"container", "kubernetes", "deploy", "rollout", "cluster", "pipeline", "docker", "release"}},
+	}},
+	{"health", []string{"health", "body", "wellness", "habit"}, []subtopic{
+		{"sleep", []string{"sleep", "insomnia", "nap", "bedtime", "dream", "melatonin", "rest", "circadian"}},
+		{"running", []string{"running", "marathon", "pace", "sneakers", "stride", "jog", "interval", "sprint"}},
+		{"nutrition", []string{"protein", "vitamin", "fiber", "diet", "calories", "vegetables", "hydration", "meal"}},
+		{"meditation", []string{"meditation", "breathing", "mindfulness", "calm", "focus", "stress", "anxiety", "yoga"}},
+	}},
+	{"finance", []string{"money", "finance", "budget", "savings"}, []subtopic{
+		{"investing", []string{"stocks", "portfolio", "dividend", "index", "bonds", "fund", "market", "shares"}},
+		{"taxes", []string{"tax", "deduction", "refund", "filing", "receipt", "income", "audit", "return"}},
+		{"mortgages", []string{"mortgage", "loan", "interest", "downpayment", "lender", "refinance", "house", "rate"}},
+		{"crypto", []string{"bitcoin", "wallet", "blockchain", "token", "exchange", "mining", "ledger", "coin"}},
+	}},
+	{"nature", []string{"nature", "wildlife", "outdoors", "earth"}, []subtopic{
+		{"birds", []string{"birds", "feathers", "nest", "migration", "songbird", "owl", "hawk", "binoculars"}},
+		{"oceans", []string{"whale", "coral", "ocean", "dolphin", "plankton", "currents", "shark", "kelp"}},
+		{"forests", []string{"forest", "oak", "moss", "canopy", "fungi", "pine", "undergrowth", "leaves"}},
+		{"weather", []string{"storm", "thunder", "rain", "forecast", "humidity", "clouds", "hurricane", "wind"}},
+	}},
+	{"music", []string{"music", "song", "sound", "listen"}, []subtopic{
+		{"guitar", []string{"guitar", "chords", "strings", "fretboard", "riff", "amplifier", "pick", "strum"}},
+		{"piano", []string{"piano", "keys", "scales", "sonata", "pedal", "chopin", "arpeggio", "metronome"}},
+		{"concerts", []string{"concert", "stage", "festival", "crowd", "tickets", "encore", "setlist", "venue"}},
+		{"production", []string{"mixing", "mastering", "synth", "beat", "sampler", "vocals", "studio", "reverb"}},
+	}},
+	{"home", []string{"home", "house", "room", "diy"}, []subtopic{
+		{"gardening", []string{"garden", "seeds", "compost", "tomatoes", "soil", "watering", "pruning", "weeds"}},
+		{"repairs", []string{"drill", "leak", "faucet", "drywall", "hammer", "screws", "plumbing", "wrench"}},
+		{"cleaning", []string{"vacuum", "laundry", "dust", "mop", "detergent", "stains", "declutter", "sponge"}},
+		{"decor", []string{"paint", "sofa", "lighting", "curtains", "rug", "shelves", "plants", "color"}},
+	}},
+}
+
+// fillers are topic-neutral words that add noise shared across topics
+var fillers = []string{"beginner", "weekend", "cheap", "quick", "practical", "simple", "modern", "classic", "daily", "honest"}
+
+// templates take a filler word, two subtopic words and a topic word
+var templates = []string{
+	"%[1]s tips for %[2]s and %[3]s in the %[4]s",
+	"A %[1]s guide to %[2]s, %[3]s and more %[4]s ideas",
+	"Common %[1]s mistakes with %[2]s and %[3]s",
+	"What every %[4]s fan should know about %[2]s and %[3]s",
+	"%[2]s or %[3]s? A %[1]s %[4]s comparison",
+	"Notes on %[2]s, %[3]s and %[1]s %[4]s routines",
+}
+
+// queryTemplates take three subtopic words
+var queryTemplates = []string{
+	"%s %s %s",
+	"questions about %s, %s and %s",
+}
+
+type snippet struct {
+	ID       string    `json:"id"`
+	Text     string    `json:"text"`
+	Topic    string    `json:"topic"`
+	Subtopic string    `json:"subtopic"`
+	Vector   []float32 `json:"vector"`
+}
+
+type query struct {
+	Text     string    `json:"text"`
+	Topic    string    `json:"topic"`
+	Subtopic string    `json:"subtopic"`
+	Vector   []float32 `json:"vector"`
+	Relevant []string  `json:"relevant"`
+}
+
+type dataset struct {
+	Dimension int       `json:"dimension"`
+	Snippets  []snippet `json:"snippets"`
+	Queries   []query   `json:"queries"`
+}
+
+// direction returns a deterministic random unit vector for key
+func direction(key string) []float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	rng := rand.New(rand.NewSource(int64(h.Sum64())))
+	v := make([]float64, dimension)
+	var norm float64
+	for i := range v {
+		v[i] = rng.NormFloat64()
+		norm += v[i] * v[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// wordVectors maps every vocabulary word to its vector
+func wordVectors() map[string][]float64 {
+	vectors := make(map[string][]float64)
+	type part struct {
+		key    string
+		weight float64
+	}
+	add := func(word string, parts ...part) {
+		v := make([]float64, dimension)
+		for _, p := range parts {
+			for i, x := range direction(p.key) {
+				v[i] += p.weight * x
+			}
+		}
+		vectors[word] = v
+	}
+	for _, t := range topics {
+		for _, w := range t.words {
+			add(w, part{"topic:" + t.name, 1}, part{"word:" + w, 0.5})
+		}
+		for _, s := range t.subs {
+			for _, w := range s.words {
+				add(w, part{"topic:" + t.name, 0.5}, part{"sub:" + s.name, 1}, part{"word:" + w, 0.6})
+			}
+		}
+	}
+	for _, w := range fillers {
+		add(w, part{"word:" + w, 0.4})
+	}
+	return vectors
+}
+
+// embed returns the normalized sum of the vectors of the known words of text
+func embed(vectors map[string][]float64, text string) []float32 {
+	sum := make([]float64, dimension)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	}) {
+		for i, x := range vectors[word] {
+			sum[i] += x
+		}
+	}
+	var norm float64
+	for _, x := range sum {
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	out := make([]float32, dimension)
+	for i, x := range sum {
+		out[i] = float32(x / norm)
+	}
+	return out
+}
+
+func l2(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// pick returns n distinct words of words
+func pick(rng *rand.Rand, words []string, n int) []string {
+	perm := rng.Perm(len(words))
+	out := make([]string, n)
+	for i := range out {
+		out[i] = words[perm[i]]
+	}
+	return out
+}
+
+func main() {
+	rng := rand.New(rand.NewSource(1))
+	vectors := wordVectors()
+	data := dataset{Dimension: dimension}
+
+	for _, t := range topics {
+		for _, s := range t.subs {
+			for i := 0; i < snippetsPerSub; i++ {
+				kw := pick(rng, s.words, 2)
+				tmpl := templates[rng.Intn(len(templates))]
+				text := fmt.Sprintf(tmpl, pick(rng, fillers, 1)[0], kw[0], kw[1], pick(rng, t.words, 1)[0])
+				text = strings.ToUpper(text[:1]) + text[1:]
+				data.Snippets = append(data.Snippets, snippet{
+					ID:       fmt.Sprintf("%s-%s-%02d", t.name, s.name, i),
+					Text:     text,
+					Topic:    t.name,
+					Subtopic: s.name,
+					Vector:   embed(vectors, text),
+				})
+			}
+		}
+	}
+
+	for _, t := range topics {
+		for _, s := range t.subs {
+			for i := 0; i < queriesPerSub; i++ {
+				kw := pick(rng, s.words, 3)
+				text := fmt.Sprintf(queryTemplates[i%len(queryTemplates)], kw[0], kw[1], kw[2])
+				q := query{Text: text, Topic: t.name, Subtopic: s.name, Vector: embed(vectors, text)}
+
+				ranked := make([]snippet, len(data.Snippets))
+				copy(ranked, data.Snippets)
+				sort.SliceStable(ranked, func(a, b int) bool {
+					return l2(q.Vector, ranked[a].Vector) < l2(q.Vector, ranked[b].Vector)
+				})
+				for _, r := range ranked[:relevantPerQuery] {
+					q.Relevant = append(q.Relevant, r.ID)
+				}
+				data.Queries = append(data.Queries, q)
+			}
+		}
+	}
+
+	f, err := os.Create("data.json.gz")
+	if err != nil {
+		log.Fatal(err)
+	}
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.NewEncoder(zw).Encode(data); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,165 @@
+package vego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+// generationFileName records how many times a collection has been saved
+const generationFileName = "generation.json"
+
+// generationInfo is the content of generation.json. A writer marks the next
+// generation as Saving before rewriting any file and clears the flag once
+// everything is on disk, so a replica never adopts a half-written save. It
+// is also the manifest of the save barrier: Checkpoint names the last save
+// whose files were published as a complete set, see barrier.go.
+type generationInfo struct {
+	Generation uint64 `json:"generation"`
+	Saving     bool   `json:"saving"`
+	Checkpoint uint64 `json:"checkpoint,omitempty"`
+}
+
+// readGeneration returns the generation recorded in dir, or the zero value
+// for collections that have never been saved
+func readGeneration(dir string) (generationInfo, error) {
+	data, err := os.ReadFile(filepath.Join(dir, generationFileName))
+	if os.IsNotExist(err) {
+		return generationInfo{}, nil
+	}
+	if err != nil {
+		return generationInfo{}, err
+	}
+
+	var info generationInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return generationInfo{}, fmt.Errorf("%w: parse %s: %v", ErrStorageCorrupted, generationFileName, err)
+	}
+	return info, nil
+}
+
+// writeGeneration atomically replaces the generation file in dir
+func writeGeneration(dir string, info generationInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return lanceio.WriteFileAtomic(filepath.Join(dir, generationFileName), data, 0644)
+}
+
+// Refresh reloads a read-only collection if its writer has saved since the
+// last load and reports whether it did. The new index, mappings, and
+// document storage are loaded alongside the current ones and swapped in
+// together; searches already running finish against the previous snapshot.
+// If a save is in progress, or completes while loading, Refresh keeps the
+// current snapshot and returns false so a later call can retry.
+func (c *Collection) Refresh(ctx context.Context) (bool, error) {
+	ctx, done, err := c.begin(ctx, "Refresh")
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	if !c.config.ReadOnly {
+		return false, wrapError("Refresh", c.name, "", fmt.Errorf("refresh requires a read-only collection"))
+	}
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	before, err := readGeneration(c.path)
+	if err != nil {
+		return false, wrapError("Refresh", c.name, "", err)
+	}
+	c.mu.RLock()
+	current := c.generation
+	c.mu.RUnlock()
+	if before.Saving || before.Generation == current {
+		return false, nil
+	}
+
+	dataDir, err := publishedDir(c.path, before)
+	if err != nil {
+		return false, wrapError("Refresh", c.name, "", err)
+	}
+	index, err := newIndex(c.config)
+	if err != nil {
+		return false, wrapError("Refresh", c.name, "", err)
+	}
+	storage, err := NewDocumentStorageWithFactory(filepath.Join(dataDir, "documents"), c.dimension, c.factory)
+	if err != nil {
+		return false, wrapError("Refresh", c.name, "", err)
+	}
+	fresh := &Collection{
+		name:      c.name,
+		path:      c.path,
+		dimension: c.dimension,
+		index:     index,
+		storage:   storage,
+		docToNode: make(map[string]int),
+		nodeToDoc: make(map[int]string),
+		info:      make(map[string]string),
+		orphans:   make(map[int]struct{}),
+		config:    c.config,
+		settings:  c.settings,
+		factory:   c.factory,
+		dataDir:   dataDir,
+	}
+	if c.text != nil {
+		fresh.text = newTextIndex(c.text.field)
+	}
+	fresh.metaIndexes = c.metaIndexes.fresh()
+	discard := func() {
+		fresh.index.Close()
+		fresh.storage.Close()
+	}
+	if err := fresh.load(ctx); err != nil && !os.IsNotExist(err) {
+		discard()
+		return false, wrapError("Refresh", c.name, "", err)
+	}
+
+	// The files may have changed underneath the load
+	after, err := readGeneration(c.path)
+	if err != nil || after != before {
+		discard()
+		return false, nil
+	}
+
+	c.mu.Lock()
+	oldIndex, oldStorage := c.index, c.storage
+	c.index = fresh.index
+	c.storage = fresh.storage
+	c.docToNode = fresh.docToNode
+	c.nodeToDoc = fresh.nodeToDoc
+	c.orphans = fresh.orphans
+	c.text = fresh.text
+	c.metaIndexes = fresh.metaIndexes
+	c.info = fresh.info
+	c.loadReport = fresh.loadReport
+	c.generation = before.Generation
+	c.published = before.Checkpoint
+	c.dataDir = dataDir
+	c.mu.Unlock()
+
+	// Nothing references the old snapshot once the write lock was acquired
+	if err := oldIndex.Close(); err != nil {
+		log.Printf("Warning: failed to close previous index of collection %s: %v", c.name, err)
+	}
+	if err := oldStorage.Close(); err != nil {
+		log.Printf("Warning: failed to close previous storage of collection %s: %v", c.name, err)
+	}
+	return true, nil
+}
+
+// autoRefresh is the background task calling Refresh
+func (c *Collection) autoRefresh(ctx context.Context) error {
+	if _, err := c.Refresh(ctx); err != nil && !IsClosed(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package vego
+
+import "context"
+
+// defaultGetChunkSize is how many IDs GetBatchOpts looks up per hold of the
+// collection's read lock when GetOptions.ChunkSize is 0
+const defaultGetChunkSize = 1000
+
+// GetOptions selects what GetBatchOpts loads. The zero value loads full
+// documents.
+type GetOptions struct {
+	OmitVectors  bool // Skip vectors; the vector column is then never read
+	OmitMetadata bool // Skip metadata
+
+	// ChunkSize is how many IDs are looked up per hold of the collection's
+	// read lock; writers and cancellation get in between chunks. 0 = 1000.
+	ChunkSize int
+}
+
+// GetBatchResult is the result of GetBatchOpts
+type GetBatchResult struct {
+	Found   map[string]*Document // Documents by ID
+	Missing []string             // Requested IDs that do not exist, once each in request order
+}
+
+// GetBatchOpts retrieves the documents with the given IDs, reporting the IDs
+// that do not exist in Missing rather than dropping them. Each chunk of IDs
+// reads the vector column at most once, and not at all with OmitVectors, so
+// metadata for many IDs is cheap to fetch. Documents flushed to storage have
+// no Timestamp when vectors are omitted.
+//
+// Chunks are consistent on their own, but a write between chunks may be
+// seen by later chunks only. Cancelling ctx stops the lookup between chunks.
+func (c *Collection) GetBatchOpts(ctx context.Context, ids []string, opts GetOptions) (*GetBatchResult, error) {
+	if c.shards != nil {
+		return c.shardGetBatch(ctx, ids, opts)
+	}
+	ctx, done, err := c.begin(ctx, "GetBatchOpts")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultGetChunkSize
+	}
+
+	result := &GetBatchResult{Found: make(map[string]*Document, len(ids))}
+	reported := make(map[string]struct{})
+	for start := 0; start < len(ids); start += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		chunk := ids[start:min(start+chunkSize, len(ids))]
+		c.mu.RLock()
+		found, missing, err := c.storage.getBatch(chunk, !opts.OmitVectors, !opts.OmitMetadata)
+		c.mu.RUnlock()
+		if err != nil {
+			return nil, wrapError("GetBatchOpts", c.name, "", err)
+		}
+
+		for id, doc := range found {
+			result.Found[id] = doc
+		}
+		for _, id := range missing {
+			if _, dup := reported[id]; !dup {
+				reported[id] = struct{}{}
+				result.Missing = append(result.Missing, id)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,153 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// getTestCollection returns a collection holding doc_000..doc_(n-1), the
+// first saved documents flushed to the vector column and the rest buffered
+func getTestCollection(t *testing.T, saved, buffered int) *Collection {
+	t.Helper()
+	db, err := Open(t.TempDir(), WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	insert := func(from, to int) {
+		docs := make([]*Document, 0, to-from)
+		for i := from; i < to; i++ {
+			docs = append(docs, &Document{
+				ID:       fmt.Sprintf("doc_%03d", i),
+				Vector:   []float32{float32(i), 1, 2, 3},
+				Metadata: map[string]interface{}{"n": i},
+			})
+		}
+		if err := coll.InsertBatch(docs); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+	}
+	insert(0, saved)
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	insert(saved, saved+buffered)
+	return coll
+}
+
+// countColumnReads counts reads of the vector column file until the test ends
+func countColumnReads(t *testing.T, onRead func()) *int {
+	reads := new(int)
+	beforeColumnRead = func() {
+		*reads++
+		if onRead != nil {
+			onRead()
+		}
+	}
+	t.Cleanup(func() { beforeColumnRead = nil })
+	return reads
+}
+
+func TestGetBatchOpts(t *testing.T) {
+	ctx := context.Background()
+	coll := getTestCollection(t, 20, 5)
+
+	ids := []string{"doc_003", "typo", "doc_022", "doc_003", "doc_019", "gone", "typo"}
+	if err := coll.Delete("doc_019"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		opts          GetOptions
+		vectors       bool
+		metadata      bool
+		columnReads   int
+		wantTimestamp bool
+	}{
+		// doc_003 is requested again in the second chunk, and each chunk
+		// needing flushed vectors reads the column once
+		{"full", GetOptions{ChunkSize: 2}, true, true, 2, true},
+		{"metadata only", GetOptions{OmitVectors: true}, false, true, 0, false},
+		{"vectors only", GetOptions{OmitMetadata: true}, true, false, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := countColumnReads(t, nil)
+			result, err := coll.GetBatchOpts(ctx, ids, tt.opts)
+			if err != nil {
+				t.Fatalf("GetBatchOpts failed: %v", err)
+			}
+
+			if want := []string{"typo", "doc_019", "gone"}; !reflect.DeepEqual(result.Missing, want) {
+				t.Errorf("Missing = %v, want %v", result.Missing, want)
+			}
+			if len(result.Found) != 2 {
+				t.Fatalf("found %d documents, want 2", len(result.Found))
+			}
+			// doc_003 is in the vector column, doc_022 in the write buffer
+			for _, id := range []string{"doc_003", "doc_022"} {
+				doc := result.Found[id]
+				if doc == nil {
+					t.Fatalf("%s not found", id)
+				}
+				if got := doc.Vector != nil; got != tt.vectors {
+					t.Errorf("%s: vector %v, want loaded %v", id, doc.Vector, tt.vectors)
+				}
+				var n int
+				fmt.Sscanf(id, "doc_%d", &n)
+				if tt.vectors && doc.Vector[0] != float32(n) {
+					t.Errorf("%s: wrong vector %v", id, doc.Vector)
+				}
+				if got := doc.Metadata != nil; got != tt.metadata {
+					t.Errorf("%s: metadata %v, want loaded %v", id, doc.Metadata, tt.metadata)
+				}
+			}
+			if got := !result.Found["doc_003"].Timestamp.IsZero(); got != tt.wantTimestamp {
+				t.Errorf("doc_003: timestamp set %v, want %v", got, tt.wantTimestamp)
+			}
+			if *reads != tt.columnReads {
+				t.Errorf("vector column read %d times, want %d", *reads, tt.columnReads)
+			}
+		})
+	}
+
+	// GetBatch keeps returning only the documents found
+	docs, err := coll.GetBatch(ids)
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if len(docs) != 2 || docs["doc_003"] == nil || len(docs["doc_003"].Vector) != 4 {
+		t.Errorf("GetBatch = %v", docs)
+	}
+}
+
+func TestGetBatchOptsCancelBetweenChunks(t *testing.T) {
+	coll := getTestCollection(t, 100, 0)
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("doc_%03d", i)
+	}
+
+	// Cancel while the first chunk reads the vector column; the chunk
+	// completes and no further chunk starts
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reads := countColumnReads(t, cancel)
+
+	_, err := coll.GetBatchOpts(ctx, ids, GetOptions{ChunkSize: 10})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if *reads != 1 {
+		t.Errorf("vector column read %d times, want 1", *reads)
+	}
+}
@@ -0,0 +1,97 @@
+package vego
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"math"
+	"sort"
+)
+
+// contentHashVersion prefixes the canonical form hashed by ContentHash; bump
+// it whenever that form changes
+const contentHashVersion = "vego-content-v1"
+
+// ContentHash returns the hex SHA-256 of the collection's logical content:
+// the dimension, M and EfConstruction of the index, then every document
+// sorted by ID with its vector and its metadata as JSON. Two collections
+// holding the same documents hash equally regardless of insertion order,
+// compression level, encodings or how the index graph turned out; use
+// hnsw.HNSWIndex.GraphHash to compare graphs.
+//
+// The form is fixed: lengths and float bits are big-endian, metadata is
+// encoded with sorted keys and nil and empty metadata are the same.
+// Timestamps are not part of it.
+func (c *Collection) ContentHash(ctx context.Context) (string, error) {
+	ctx, done, err := c.begin(ctx, "ContentHash")
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	docs, err := c.storage.allDocuments()
+	if err != nil {
+		return "", wrapError("ContentHash", c.name, "", err)
+	}
+	live := docs[:0]
+	for _, doc := range docs {
+		if _, ok := c.docToNode[doc.ID]; ok {
+			live = append(live, doc)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].ID < live[j].ID })
+
+	w := contentWriter{Hash: sha256.New()}
+	w.Write([]byte(contentHashVersion))
+	w.uint(uint64(c.dimension))
+	w.uint(uint64(c.index.M))
+	w.uint(uint64(c.index.EfConstruction()))
+	w.uint(uint64(len(live)))
+
+	for i, doc := range live {
+		if i%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+		}
+
+		w.bytes([]byte(doc.ID))
+		w.uint(uint64(len(doc.Vector)))
+		for _, v := range doc.Vector {
+			w.uint(uint64(math.Float32bits(v)))
+		}
+
+		metadata := []byte("{}")
+		if len(doc.Metadata) > 0 {
+			// encoding/json writes map keys sorted, at every nesting level
+			if metadata, err = json.Marshal(doc.Metadata); err != nil {
+				return "", wrapError("ContentHash", c.name, doc.ID, err)
+			}
+		}
+		w.bytes(metadata)
+	}
+	return hex.EncodeToString(w.Sum(nil)), nil
+}
+
+// contentWriter writes length-prefixed values to a hash in a fixed width and
+// byte order
+type contentWriter struct {
+	hash.Hash
+	buf [8]byte
+}
+
+func (w *contentWriter) uint(v uint64) {
+	binary.BigEndian.PutUint64(w.buf[:], v)
+	w.Write(w.buf[:])
+}
+
+func (w *contentWriter) bytes(b []byte) {
+	w.uint(uint64(len(b)))
+	w.Write(b)
+}
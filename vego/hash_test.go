@@ -0,0 +1,140 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func hashTestDocs() []*Document {
+	docs := make([]*Document, 200)
+	for i := range docs {
+		vector := make([]float32, 16)
+		for j := range vector {
+			vector[j] = float32((i*31+j*7)%97) / 97
+		}
+		docs[i] = &Document{
+			ID:     fmt.Sprintf("doc_%03d", i),
+			Vector: vector,
+			Metadata: map[string]interface{}{
+				"n":    i,
+				"tags": []interface{}{"a", "b"},
+				"nested": map[string]interface{}{
+					"z": true,
+					"a": fmt.Sprintf("v%d", i),
+				},
+			},
+		}
+	}
+	return docs
+}
+
+func TestContentHash(t *testing.T) {
+	ctx := context.Background()
+
+	// Same documents in a different order and with a different compression level
+	hashes := make([]string, 2)
+	var colls []*Collection
+	for i, level := range []int{1, 19} {
+		db, err := Open(t.TempDir(), WithDimension(16), WithCompressionLevel(level))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer db.Close()
+		coll, err := db.Collection("docs")
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+
+		docs := hashTestDocs()
+		if i == 1 {
+			for l, r := 0, len(docs)-1; l < r; l, r = l+1, r-1 {
+				docs[l], docs[r] = docs[r], docs[l]
+			}
+		}
+		if err := coll.InsertBatch(docs); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if err := coll.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if hashes[i], err = coll.ContentHash(ctx); err != nil {
+			t.Fatalf("ContentHash failed: %v", err)
+		}
+		colls = append(colls, coll)
+	}
+	if hashes[0] != hashes[1] {
+		t.Fatalf("equal content hashes differently: %s vs %s", hashes[0], hashes[1])
+	}
+
+	// A single metadata value changes the hash
+	doc, err := colls[1].Get("doc_042")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	doc.Metadata["nested"].(map[string]interface{})["a"] = "changed"
+	if err := colls[1].Update(doc); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	changed, err := colls[1].ContentHash(ctx)
+	if err != nil {
+		t.Fatalf("ContentHash failed: %v", err)
+	}
+	if changed == hashes[0] {
+		t.Errorf("metadata change did not change the hash")
+	}
+
+	// A deleted document is not part of the content
+	if err := colls[0].Delete("doc_042"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	deleted, err := colls[0].ContentHash(ctx)
+	if err != nil {
+		t.Fatalf("ContentHash failed: %v", err)
+	}
+	if deleted == hashes[0] || deleted == changed {
+		t.Errorf("deleting a document did not change the hash")
+	}
+}
+
+func TestContentHashReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := Open(dir, WithDimension(16))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.InsertBatch(hashTestDocs()); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	before, err := coll.ContentHash(ctx)
+	if err != nil {
+		t.Fatalf("ContentHash failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Metadata comes back from JSON with float64 numbers
+	db, err = Open(dir, WithDimension(16))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	after, err := coll.ContentHash(ctx)
+	if err != nil {
+		t.Fatalf("ContentHash failed: %v", err)
+	}
+	if before != after {
+		t.Errorf("content hash changed across reopen: %s vs %s", before, after)
+	}
+}
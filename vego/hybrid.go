@@ -0,0 +1,195 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// hybridRRFK dampens the weight of the top ranks in the reciprocal rank
+	// fusion of HybridSearch; 60 is the constant of the original paper
+	hybridRRFK = 60
+
+	// hybridCandidates is how many candidates per result each ranking of
+	// HybridSearch contributes, and hybridMinCandidates the least it does
+	hybridCandidates    = 4
+	hybridMinCandidates = 50
+)
+
+// hybridHit is a document ranked by HybridSearch
+type hybridHit struct {
+	id       string
+	nodeID   int
+	distance float32
+	score    float64
+}
+
+// HybridSearch ranks documents by vector similarity to query combined with
+// the BM25 keyword relevance of their text-indexed field to text, see
+// WithTextIndex. The collection must have a text index; ErrNotSupported is
+// returned otherwise.
+//
+// The two rankings are merged with weighted reciprocal rank fusion: a
+// document ranked r (from 1) by the vector search and t by keywords scores
+// (1-alpha)/(60+r) + alpha/(60+t), leaving out the term of a ranking it is
+// missing from. alpha must be between 0 and 1: 0 ranks by vector alone and
+// 1 by keywords alone, so a document that only matches the keywords can
+// rank first even if its vector is far from query. Each ranking contributes
+// max(4k, 50) candidates.
+//
+// Results are ordered by fused score, which is set in Score; Distance is
+// the vector distance of every result, including those found by keywords
+// only.
+func (c *Collection) HybridSearch(ctx context.Context, query []float32, text string, k int, alpha float32) ([]SearchResult, error) {
+	ctx, done, err := c.begin(ctx, "HybridSearch")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := c.checkQuery("HybridSearch", query); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, wrapError("HybridSearch", c.name, "", ErrInvalidK)
+	}
+	if !(alpha >= 0 && alpha <= 1) {
+		return nil, wrapError("HybridSearch", c.name, "", fmt.Errorf("%w: alpha %v is not between 0 and 1", ErrValidationFailed, alpha))
+	}
+	if c.text == nil {
+		return nil, wrapError("HybridSearch", c.name, "", fmt.Errorf("%w: collection has no text index, see WithTextIndex", ErrNotSupported))
+	}
+	defer c.observeSearch(time.Now())
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.index.Len() == 0 {
+		return []SearchResult{}, nil
+	}
+
+	hits, err := c.fuseRankings(query, text, max(k*hybridCandidates, hybridMinCandidates), float64(alpha))
+	if err != nil {
+		return nil, wrapError("HybridSearch", c.name, "", err)
+	}
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		doc, err := c.storage.Get(hit.id)
+		if err != nil {
+			log.Printf("Warning: failed to load document %s: %v", hit.id, err)
+			continue // Skip missing documents
+		}
+
+		// Vectors are returned as stored, as in SearchContext
+		if !c.config.SearchVectors {
+			doc.Vector = nil
+		}
+		result := c.newResult(doc, hit.distance)
+		result.Score = float32(hit.score)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// fuseRankings returns the documents among the n nearest to query and the n
+// best keyword matches for text, ordered by fused score; documents whose
+// score is 0 because alpha gives their only ranking no weight are left out.
+// c.mu must be held.
+func (c *Collection) fuseRankings(query []float32, text string, n int, alpha float64) ([]*hybridHit, error) {
+	vectorHits, err := c.index.SearchWithFilter(query, n, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*hybridHit)
+	rank := 0
+	for _, vh := range vectorHits {
+		id, exists := c.nodeToDoc[vh.ID]
+		if !exists {
+			continue // Skip deleted/orphaned nodes and nodes of in-progress batches
+		}
+		rank++
+		byID[id] = &hybridHit{id: id, nodeID: vh.ID, distance: vh.Distance, score: (1 - alpha) / float64(hybridRRFK+rank)}
+	}
+
+	var textOnly []*hybridHit
+	for i, th := range c.text.search(text, n) {
+		hit, ok := byID[th.ID]
+		if !ok {
+			nodeID, exists := c.docToNode[th.ID]
+			if !exists {
+				continue
+			}
+			hit = &hybridHit{id: th.ID, nodeID: nodeID}
+			byID[th.ID] = hit
+			textOnly = append(textOnly, hit)
+		}
+		hit.score += alpha / float64(hybridRRFK+i+1)
+	}
+
+	// Documents found by keywords only get their vector distance too
+	if len(textOnly) > 0 {
+		nodeIDs := make([]int, len(textOnly))
+		for i, hit := range textOnly {
+			nodeIDs[i] = hit.nodeID
+		}
+		distances, err := c.index.DistancesTo(query, nodeIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i, hit := range textOnly {
+			hit.distance = distances[i]
+		}
+	}
+
+	hits := make([]*hybridHit, 0, len(byID))
+	for _, hit := range byID {
+		if hit.score > 0 {
+			hits = append(hits, hit)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.distance != b.distance && !math.IsNaN(float64(a.distance)) && !math.IsNaN(float64(b.distance)) {
+			return a.distance < b.distance
+		}
+		return a.id < b.id
+	})
+	return hits, nil
+}
+
+// rebuildTextIndex re-indexes the text field of every mapped document from
+// its stored metadata. c.mu must be held for writing.
+func (c *Collection) rebuildTextIndex() error {
+	c.text.clear()
+	ids := make([]string, 0, len(c.docToNode))
+	for id := range c.docToNode {
+		ids = append(ids, id)
+	}
+	docs, _, err := c.storage.getBatch(ids, false, true)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		c.text.add(doc)
+	}
+	return nil
+}
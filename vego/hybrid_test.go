@@ -0,0 +1,211 @@
+package vego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// hybridTestCollection returns a collection indexing "content", holding
+// docs near_0..near_9 close to hybridQuery and far, whose vector points the
+// other way but whose content is the only one mentioning "quantum"
+func hybridTestCollection(t *testing.T, path string) *Collection {
+	t.Helper()
+	coll, err := NewCollection("hybrid", path, &Config{Dimension: 8, M: 16, EfConstruction: 200, TextIndexField: "content"})
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+
+	docs := make([]*Document, 0, 11)
+	for i := 0; i < 10; i++ {
+		vector := make([]float32, 8)
+		vector[0] = 1
+		vector[1] = float32(i) / 10
+		docs = append(docs, &Document{
+			ID:       fmt.Sprintf("near_%d", i),
+			Vector:   vector,
+			Metadata: map[string]interface{}{"content": fmt.Sprintf("Cats and dogs, part %d", i)},
+		})
+	}
+	far := make([]float32, 8)
+	far[0] = -1
+	docs = append(docs, &Document{ID: "far", Vector: far, Metadata: map[string]interface{}{"content": "Quantum entanglement of cats"}})
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	return coll
+}
+
+var hybridQuery = []float32{1, 0, 0, 0, 0, 0, 0, 0}
+
+func hybridTop(t *testing.T, coll *Collection, text string, alpha float32) string {
+	t.Helper()
+	results, err := coll.HybridSearch(context.Background(), hybridQuery, text, 1, alpha)
+	if err != nil {
+		t.Fatalf("HybridSearch(%q, %v) failed: %v", text, alpha, err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("HybridSearch(%q, %v) returned %d results", text, alpha, len(results))
+	}
+	return results[0].Document.ID
+}
+
+func TestHybridSearch(t *testing.T) {
+	ctx := context.Background()
+	coll := hybridTestCollection(t, t.TempDir())
+	defer coll.Close()
+
+	// The keyword-only match surfaces once alpha favours text
+	if id := hybridTop(t, coll, "quantum", 0); id != "near_0" {
+		t.Errorf("top result with alpha 0 = %s, want near_0", id)
+	}
+	if id := hybridTop(t, coll, "quantum", 0.8); id != "far" {
+		t.Errorf("top result with alpha 0.8 = %s, want far", id)
+	}
+
+	results, err := coll.HybridSearch(ctx, hybridQuery, "quantum", 11, 1)
+	if err != nil {
+		t.Fatalf("HybridSearch failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != "far" {
+		t.Fatalf("keyword-only search returned %d results", len(results))
+	}
+	if results[0].Distance == 0 || results[0].Score <= 0 || results[0].Document.Metadata["content"] == nil {
+		t.Errorf("result = %+v", results[0])
+	}
+
+	// Updates and deletes keep the text index in step
+	updated := &Document{ID: "near_5", Vector: []float32{1, 0.5, 0, 0, 0, 0, 0, 0}, Metadata: map[string]interface{}{"content": "Quantum quantum quantum"}}
+	if err := coll.Update(updated); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if id := hybridTop(t, coll, "quantum", 0.5); id != "near_5" {
+		t.Errorf("top result after update = %s, want near_5", id)
+	}
+	if err := coll.Delete("near_5"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if id := hybridTop(t, coll, "quantum", 1); id != "far" {
+		t.Errorf("top result after delete = %s, want far", id)
+	}
+	if results, _ := coll.HybridSearch(ctx, hybridQuery, "part 5", 11, 1); len(results) != 9 {
+		t.Errorf("search for deleted content returned %d results", len(results))
+	} else {
+		for _, r := range results {
+			if r.Document.ID == "near_5" {
+				t.Error("deleted document still matches its text")
+			}
+		}
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		if _, err := coll.HybridSearch(ctx, hybridQuery, "cats", 0, 0.5); !errors.Is(err, ErrInvalidK) {
+			t.Errorf("k = 0: %v", err)
+		}
+		if _, err := coll.HybridSearch(ctx, hybridQuery, "cats", 1, 1.5); !errors.Is(err, ErrValidationFailed) {
+			t.Errorf("alpha = 1.5: %v", err)
+		}
+		if _, err := coll.HybridSearch(ctx, hybridQuery[:4], "cats", 1, 0.5); err == nil {
+			t.Error("query of the wrong dimension accepted")
+		}
+	})
+
+	t.Run("NoTextIndex", func(t *testing.T) {
+		plain, cleanup := setupTestCollection(t)
+		defer cleanup()
+		_, err := plain.HybridSearch(ctx, make([]float32, 64), "cats", 1, 0.5)
+		if !errors.Is(err, ErrNotSupported) {
+			t.Errorf("HybridSearch without text index = %v, want ErrNotSupported", err)
+		}
+	})
+}
+
+func TestHybridSearchPersistence(t *testing.T) {
+	path := t.TempDir()
+	coll := hybridTestCollection(t, path)
+	if err := coll.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The text field is recorded in the settings, so the reopened
+	// collection indexes it without asking
+	reopen := func() *Collection {
+		t.Helper()
+		coll, err := NewCollection("hybrid", path, &Config{Dimension: 8, M: 16, EfConstruction: 200})
+		if err != nil {
+			t.Fatalf("NewCollection failed: %v", err)
+		}
+		return coll
+	}
+	coll = reopen()
+	if id := hybridTop(t, coll, "quantum", 1); id != "far" {
+		t.Errorf("top result after reopen = %s, want far", id)
+	}
+	dataDir := coll.dataDir
+	if err := coll.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Mappings saved without the text index have it rebuilt from storage
+	mappingsPath := filepath.Join(dataDir, "mappings.json")
+	data, err := os.ReadFile(mappingsPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	var mappings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := mappings["text"]; !ok {
+		t.Fatal("mappings do not hold the text index")
+	}
+	delete(mappings, "text")
+	if data, err = json.Marshal(mappings); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if err := os.WriteFile(mappingsPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	coll = reopen()
+	defer coll.Close()
+	if id := hybridTop(t, coll, "quantum", 1); id != "far" {
+		t.Errorf("top result after rebuild = %s, want far", id)
+	}
+}
+
+func TestTextIndexBM25(t *testing.T) {
+	idx := newTextIndex("content")
+	for id, content := range map[string]interface{}{
+		"a": "the vector database",
+		"b": "the the the vector",
+		"c": "A database of databases",
+		"d": 42, // Not a string, not indexed
+	} {
+		idx.add(&Document{ID: id, Metadata: map[string]interface{}{"content": content}})
+	}
+
+	hits := idx.search("Database", 10)
+	if len(hits) != 2 || hits[0].ID != "a" || hits[1].ID != "c" {
+		t.Errorf("search(database) = %v", hits)
+	}
+	// The rarer term outweighs the repeated common one
+	if hits := idx.search("the databases", 1); len(hits) != 1 || hits[0].ID != "c" {
+		t.Errorf("search(the databases) = %v", hits)
+	}
+	if hits := idx.search("missing", 10); len(hits) != 0 {
+		t.Errorf("search(missing) = %v", hits)
+	}
+
+	restored := newTextIndex("content")
+	if !restored.restore(idx.snapshot()) || restored.total != idx.total || len(restored.postings) != len(idx.postings) {
+		t.Error("restore did not reproduce the index")
+	}
+	if newTextIndex("title").restore(idx.snapshot()) {
+		t.Error("restore accepted the snapshot of another field")
+	}
+}
@@ -0,0 +1,86 @@
+package vego
+
+import (
+	"context"
+	"log"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// Import loads docs into the collection. Into an empty collection the index
+// is built in one pass with hnsw.BuildBulk, which constructs the graph on all
+// cores and is several times faster than inserting the documents one by one;
+// the collection lock is held for the whole build. If the collection already
+// holds documents, or a batch insert is in progress, Import behaves like
+// InsertBatchContext.
+//
+// Import is all-or-nothing: if validation, the build or the storage write
+// fails, the collection is left as it was.
+func (c *Collection) Import(ctx context.Context, docs []*Document) (err error) {
+	ctx, done, err := c.beginWrite(ctx, "Import")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if len(docs) == 0 {
+		return nil
+	}
+	if err := c.validateBatch("Import", docs); err != nil {
+		return err
+	}
+
+	c.lockWrites()
+	if c.index.Len() > 0 || len(c.pending) > 0 {
+		c.unlockWrites()
+		return c.InsertBatchContext(ctx, docs)
+	}
+	defer c.unlockJournaled("Import", &err)
+
+	seen := make(map[string]struct{}, len(docs))
+	vectors := make([][]float32, len(docs))
+	for i, doc := range docs {
+		if _, dup := seen[doc.ID]; dup {
+			return wrapError("Import", c.name, doc.ID, ErrDuplicateID)
+		}
+		seen[doc.ID] = struct{}{}
+		vectors[i] = doc.Vector
+	}
+
+	start := time.Now()
+	index, err := hnsw.BuildBulk(ctx, vectors, indexConfig(c.config), hnsw.BulkOptions{})
+	if err != nil {
+		return wrapError("Import", c.name, "", err)
+	}
+
+	now := time.Now()
+	for _, doc := range docs {
+		doc.Timestamp = now
+	}
+	if err := c.storage.PutBatch(docs); err != nil {
+		index.Close()
+		return wrapError("Import", c.name, "", err)
+	}
+
+	// Node IDs of a bulk-built index are the positions in vectors
+	old := c.index
+	_, _, defaultEF, _ := old.Params()
+	index.SetDefaultEf(defaultEF)
+	c.index = index
+	for i, doc := range docs {
+		c.docToNode[doc.ID] = i
+		c.nodeToDoc[i] = doc.ID
+		c.text.add(doc)
+		c.metaIndexes.add(doc)
+	}
+	c.markDirty(len(docs))
+	if err := c.wal.put(docs...); err != nil {
+		return wrapError("Import", c.name, "", err)
+	}
+	if err := old.Close(); err != nil {
+		log.Printf("Warning: failed to close replaced index of collection %s: %v", c.name, err)
+	}
+	log.Printf("Imported %d documents into collection %s in %v", len(docs), c.name, time.Since(start))
+	return nil
+}
@@ -0,0 +1,119 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func importDocs(n, dim int, seed int64) []*Document {
+	rng := rand.New(rand.NewSource(seed))
+	docs := make([]*Document, n)
+	for i := range docs {
+		docs[i] = &Document{ID: fmt.Sprintf("doc_%d_%04d", seed, i), Vector: make([]float32, dim)}
+		for j := range docs[i].Vector {
+			docs[i].Vector[j] = rng.Float32()
+		}
+	}
+	return docs
+}
+
+func TestImportBulkBuild(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	docs := importDocs(1000, 32, 1)
+	if err := coll.Import(context.Background(), docs); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if coll.Count() != len(docs) {
+		t.Fatalf("Count = %d, want %d", coll.Count(), len(docs))
+	}
+
+	for _, doc := range docs[:50] {
+		results, err := coll.Search(doc.Vector, 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) == 0 || results[0].Document.ID != doc.ID {
+			t.Errorf("Search for %s did not return it first", doc.ID)
+		}
+	}
+
+	// Imports into a populated collection go through InsertBatchContext
+	more := importDocs(100, 32, 2)
+	if err := coll.Import(context.Background(), more); err != nil {
+		t.Fatalf("second Import failed: %v", err)
+	}
+	if coll.Count() != len(docs)+len(more) {
+		t.Fatalf("Count = %d, want %d", coll.Count(), len(docs)+len(more))
+	}
+	if err := coll.Import(context.Background(), docs[:1]); !IsDuplicate(err) {
+		t.Errorf("re-import: err = %v, want duplicate", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if coll.Count() != len(docs)+len(more) {
+		t.Errorf("Count after reopen = %d, want %d", coll.Count(), len(docs)+len(more))
+	}
+	results, err := coll.Search(more[0].Vector, 1)
+	if err != nil || len(results) == 0 || results[0].Document.ID != more[0].ID {
+		t.Errorf("Search after reopen = %v, %v; want %s", results, err, more[0].ID)
+	}
+}
+
+func TestImportAllOrNothing(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	docs := importDocs(100, 32, 1)
+	docs = append(docs, &Document{ID: docs[0].ID, Vector: docs[1].Vector})
+	if err := coll.Import(context.Background(), docs); !IsDuplicate(err) {
+		t.Errorf("duplicate in batch: err = %v, want duplicate", err)
+	}
+
+	docs = importDocs(100, 32, 1)
+	setStorageFailing(coll.storage, true)
+	err = coll.Import(context.Background(), docs)
+	setStorageFailing(coll.storage, false)
+	if err == nil {
+		t.Errorf("Import with failing storage should have failed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := coll.Import(ctx, docs); err == nil {
+		t.Errorf("Import with cancelled context should have failed")
+	}
+
+	if coll.Count() != 0 || coll.index.Len() != 0 {
+		t.Errorf("failed imports left %d documents and %d nodes", coll.Count(), coll.index.Len())
+	}
+}
@@ -0,0 +1,173 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxInfoSize caps the bytes of keys and values of a collection's info
+	maxInfoSize = 64 << 10
+
+	// reservedInfoPrefix marks info keys maintained by vego itself
+	reservedInfoPrefix = "vego."
+
+	infoModelName      = "vego.model.name"
+	infoModelVersion   = "vego.model.version"
+	infoModelDimension = "vego.model.dimension"
+)
+
+// ErrModelMismatch is returned by CheckModel when the embedding model of a
+// query differs from the one recorded with SetModel; errors.As with
+// *ModelMismatchError tells the two apart
+var ErrModelMismatch = errors.New("embedding model mismatch")
+
+// ModelInfo identifies the embedding model that produced a collection's
+// vectors
+type ModelInfo struct {
+	Name      string
+	Version   string
+	Dimension int
+}
+
+// String returns name@version/dimension
+func (m ModelInfo) String() string {
+	return fmt.Sprintf("%s@%s/%d", m.Name, m.Version, m.Dimension)
+}
+
+// ModelMismatchError describes a model rejected by CheckModel
+type ModelMismatchError struct {
+	Recorded ModelInfo // Model recorded with SetModel
+	Got      ModelInfo // Model passed to CheckModel
+}
+
+// Error describes both models
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("%v: collection embedded with %s, got %s", ErrModelMismatch, e.Recorded, e.Got)
+}
+
+// Unwrap returns ErrModelMismatch for errors.Is support
+func (e *ModelMismatchError) Unwrap() error { return ErrModelMismatch }
+
+// IsModelMismatch checks if an error is ErrModelMismatch
+func IsModelMismatch(err error) bool {
+	return errors.Is(err, ErrModelMismatch)
+}
+
+// Info returns a copy of the collection's info: free-form string pairs such
+// as a description or schema version. Keys starting with "vego." are
+// maintained by vego, e.g. by SetModel.
+func (c *Collection) Info() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info := make(map[string]string, len(c.info))
+	for k, v := range c.info {
+		info[k] = v
+	}
+	return info
+}
+
+// SetInfo sets key of the collection's info to value; an empty value
+// removes the key. Info is written to disk with the mappings by the next
+// Save, so it belongs to the same save as the documents and is kept by
+// checkpoints. Keys and values together are limited to 64KB, and keys
+// starting with "vego." are reserved; both fail with ErrValidationFailed.
+func (c *Collection) SetInfo(key, value string) error {
+	if key == "" || strings.HasPrefix(key, reservedInfoPrefix) {
+		return wrapError("SetInfo", c.name, "", fmt.Errorf("%w: info key %q is empty or reserved", ErrValidationFailed, key))
+	}
+	return c.updateInfo("SetInfo", map[string]string{key: value})
+}
+
+// SetModel records the embedding model that produced the collection's
+// vectors, for CheckModel to compare against later. dim must be the
+// collection's dimension.
+func (c *Collection) SetModel(name, version string, dim int) error {
+	if name == "" {
+		return wrapError("SetModel", c.name, "", fmt.Errorf("%w: model name is required", ErrValidationFailed))
+	}
+	if dim != c.dimension {
+		return wrapError("SetModel", c.name, "", ErrDimensionMismatch)
+	}
+	return c.updateInfo("SetModel", map[string]string{
+		infoModelName:      name,
+		infoModelVersion:   version,
+		infoModelDimension: strconv.Itoa(dim),
+	})
+}
+
+// Model returns the model recorded with SetModel, or false if none was
+func (c *Collection) Model() (ModelInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.model()
+}
+
+// model reads the recorded model from c.info; c.mu must be held
+func (c *Collection) model() (ModelInfo, bool) {
+	name, ok := c.info[infoModelName]
+	if !ok {
+		return ModelInfo{}, false
+	}
+	dim, _ := strconv.Atoi(c.info[infoModelDimension])
+	return ModelInfo{Name: name, Version: c.info[infoModelVersion], Dimension: dim}, true
+}
+
+// CheckModel returns a *ModelMismatchError if a model was recorded with
+// SetModel and model differs from it in name, version or, when set,
+// dimension. Code that embeds queries should call it before searching so
+// that vectors of another model are refused rather than silently compared.
+// A collection without a recorded model accepts any model.
+func (c *Collection) CheckModel(model ModelInfo) error {
+	recorded, ok := c.Model()
+	if !ok {
+		return nil
+	}
+	if model.Name != recorded.Name || model.Version != recorded.Version ||
+		(model.Dimension != 0 && model.Dimension != recorded.Dimension) {
+		return wrapError("CheckModel", c.name, "", &ModelMismatchError{Recorded: recorded, Got: model})
+	}
+	return nil
+}
+
+// updateInfo applies updates to the info as one change, removing keys with
+// empty values, and fails without changing anything if the result would
+// exceed maxInfoSize
+func (c *Collection) updateInfo(op string, updates map[string]string) error {
+	_, done, err := c.beginWrite(context.Background(), op)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	c.lockWrites()
+	defer c.unlockWrites()
+
+	size := 0
+	for k, v := range c.info {
+		if _, updated := updates[k]; !updated {
+			size += len(k) + len(v)
+		}
+	}
+	for k, v := range updates {
+		if v != "" {
+			size += len(k) + len(v)
+		}
+	}
+	if size > maxInfoSize {
+		return wrapError(op, c.name, "", fmt.Errorf("%w: info would take %d bytes, limit is %d", ErrValidationFailed, size, maxInfoSize))
+	}
+
+	for k, v := range updates {
+		if v == "" {
+			delete(c.info, k)
+		} else {
+			c.info[k] = v
+		}
+	}
+	return nil
+}
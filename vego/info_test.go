@@ -0,0 +1,234 @@
+package vego
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// reopenCollection closes db and returns collection name of the database
+// at path reopened with opts
+func reopenCollection(t *testing.T, db *DB, path, name string, opts ...Option) (*DB, *Collection) {
+	t.Helper()
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db, err := Open(path, opts...)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection(name)
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	return db, coll
+}
+
+func TestCollectionInfo(t *testing.T) {
+	path := t.TempDir()
+	opts := []Option{WithDimension(4), WithCheckpointRetention(2)}
+	db, err := Open(path, opts...)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.Insert(&Document{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	for k, v := range map[string]string{"description": "support tickets", "schema": "3", "owner": "search"} {
+		if err := coll.SetInfo(k, v); err != nil {
+			t.Fatalf("SetInfo(%s) failed: %v", k, err)
+		}
+	}
+	if err := coll.SetInfo("owner", ""); err != nil {
+		t.Fatalf("SetInfo removing owner failed: %v", err)
+	}
+	want := map[string]string{"description": "support tickets", "schema": "3"}
+
+	info := coll.Info()
+	if fmt.Sprint(info) != fmt.Sprint(want) {
+		t.Fatalf("Info = %v, want %v", info, want)
+	}
+	info["schema"] = "changed"
+	if coll.Info()["schema"] != "3" {
+		t.Error("modifying the returned map changed the info")
+	}
+
+	for _, key := range []string{"", "vego.model.name"} {
+		if err := coll.SetInfo(key, "x"); !IsValidationFailed(err) {
+			t.Errorf("SetInfo(%q): expected ErrValidationFailed, got %v", key, err)
+		}
+	}
+	big := strings.Repeat("x", maxInfoSize)
+	if err := coll.SetInfo("big", big); !IsValidationFailed(err) {
+		t.Errorf("oversized SetInfo: expected ErrValidationFailed, got %v", err)
+	}
+	if _, exists := coll.Info()["big"]; exists {
+		t.Error("rejected value was stored")
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Round-trip through Save and load
+	db, coll = reopenCollection(t, db, path, "docs", opts...)
+	if got := coll.Info(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("after reopen: Info = %v, want %v", got, want)
+	}
+
+	// Checkpoints keep the info of their save
+	checkpoints, err := coll.Checkpoints()
+	if err != nil || len(checkpoints) == 0 {
+		t.Fatalf("Checkpoints = %v, %v", checkpoints, err)
+	}
+	if err := coll.SetInfo("schema", "4"); err != nil {
+		t.Fatalf("SetInfo failed: %v", err)
+	}
+	view, err := db.CollectionAtCheckpoint("docs", checkpoints[len(checkpoints)-1].ID)
+	if err != nil {
+		t.Fatalf("CollectionAtCheckpoint failed: %v", err)
+	}
+	if got := view.Info(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("checkpoint: Info = %v, want %v", got, want)
+	}
+	if err := view.SetInfo("schema", "5"); !IsReadOnly(err) {
+		t.Errorf("SetInfo on a read-only view: expected ErrReadOnly, got %v", err)
+	}
+	view.Close()
+	want["schema"] = "4"
+
+	// The info is kept when the index has to be rebuilt
+	db.Close()
+	for _, dir := range []string{"index", setsDirName} {
+		if err := os.RemoveAll(filepath.Join(path, "docs", dir)); err != nil {
+			t.Fatalf("Failed to remove %s: %v", dir, err)
+		}
+	}
+	_, coll = reopenCollection(t, db, path, "docs", opts...)
+	if !coll.LoadReport().IndexRebuilt {
+		t.Fatal("expected the index to be rebuilt")
+	}
+	if got := coll.Info(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("after rebuild: Info = %v, want %v", got, want)
+	}
+}
+
+func TestSetInfoConcurrent(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.Insert(&Document{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	const writers, keys = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				if err := coll.SetInfo(fmt.Sprintf("w%d_k%d", w, i), fmt.Sprint(i)); err != nil {
+					t.Errorf("SetInfo failed: %v", err)
+				}
+				if i%10 == 0 {
+					if err := coll.Save(); err != nil {
+						t.Errorf("Save failed: %v", err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	_, coll = reopenCollection(t, db, path, "docs", WithDimension(4))
+	info := coll.Info()
+	if len(info) != writers*keys {
+		t.Fatalf("got %d keys, want %d", len(info), writers*keys)
+	}
+	for w := 0; w < writers; w++ {
+		for i := 0; i < keys; i++ {
+			if got := info[fmt.Sprintf("w%d_k%d", w, i)]; got != fmt.Sprint(i) {
+				t.Fatalf("w%d_k%d = %q, want %d", w, i, got, i)
+			}
+		}
+	}
+}
+
+func TestCollectionModel(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.Insert(&Document{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// Nothing recorded accepts any model
+	if _, ok := coll.Model(); ok {
+		t.Error("new collection has a model")
+	}
+	if err := coll.CheckModel(ModelInfo{Name: "anything"}); err != nil {
+		t.Errorf("CheckModel without a recorded model: %v", err)
+	}
+
+	if err := coll.SetModel("minilm", "v2", 8); !IsDimensionMismatch(err) {
+		t.Errorf("SetModel with the wrong dimension: expected ErrDimensionMismatch, got %v", err)
+	}
+	if err := coll.SetModel("", "v2", 4); !IsValidationFailed(err) {
+		t.Errorf("SetModel without a name: expected ErrValidationFailed, got %v", err)
+	}
+	if err := coll.SetModel("minilm", "v2", 4); err != nil {
+		t.Fatalf("SetModel failed: %v", err)
+	}
+	_, coll = reopenCollection(t, db, path, "docs", WithDimension(4))
+
+	recorded := ModelInfo{Name: "minilm", Version: "v2", Dimension: 4}
+	if got, ok := coll.Model(); !ok || got != recorded {
+		t.Fatalf("Model = %v, %v, want %v", got, ok, recorded)
+	}
+	if got := coll.Info()[infoModelName]; got != "minilm" {
+		t.Errorf("model name is not in Info: %v", coll.Info())
+	}
+
+	for _, model := range []ModelInfo{recorded, {Name: "minilm", Version: "v2"}} {
+		if err := coll.CheckModel(model); err != nil {
+			t.Errorf("CheckModel(%v): %v", model, err)
+		}
+	}
+	for _, model := range []ModelInfo{
+		{Name: "minilm", Version: "v3", Dimension: 4},
+		{Name: "bge", Version: "v2", Dimension: 4},
+		{Name: "minilm", Version: "v2", Dimension: 8},
+	} {
+		err := coll.CheckModel(model)
+		if !IsModelMismatch(err) {
+			t.Errorf("CheckModel(%v): expected ErrModelMismatch, got %v", model, err)
+			continue
+		}
+		var mismatch *ModelMismatchError
+		if !errors.As(err, &mismatch) || mismatch.Recorded != recorded || mismatch.Got != model {
+			t.Errorf("CheckModel(%v): unexpected error %v", model, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/wzqhbustb/vego/storage/column"
+)
+
+// StorageReport describes how the Lance files of a collection's last save
+// store their columns: each file's column.FileReport and the totals over
+// all of them. It marshals to JSON, so it can be printed as is.
+type StorageReport struct {
+	Collection string               `json:"collection"`
+	Files      []*column.FileReport `json:"files"` // Paths relative to the collection
+
+	FileBytes         int64          `json:"file_bytes"`         // Size of the files
+	DataBytes         int64          `json:"data_bytes"`         // Size of their pages, headers included
+	UncompressedBytes int64          `json:"uncompressed_bytes"` // Raw size of the page data
+	Encodings         map[string]int `json:"encodings"`          // Pages of each encoding
+}
+
+// StorageReport inspects the Lance files of the documents and index of the
+// last save, reading their footers and, for files written before footers
+// summarized their columns, their page headers. Changes made since the
+// last save are not in the files; call Save first to include them. An
+// in-memory collection has no files and reports none. Sharded collections
+// are not supported.
+func (c *Collection) StorageReport() (*StorageReport, error) {
+	_, done, err := c.begin(context.Background(), "StorageReport")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	report := &StorageReport{Collection: c.name, Encodings: make(map[string]int)}
+	if c.config.InMemory {
+		return report, nil
+	}
+
+	// Keep saves out so the files all come from one
+	c.saveGate.RLock()
+	defer c.saveGate.RUnlock()
+	c.mu.RLock()
+	dir := c.dataDir
+	c.mu.RUnlock()
+
+	for _, name := range []string{"documents", "index"} {
+		err := filepath.WalkDir(filepath.Join(dir, name), func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lance") {
+				return err
+			}
+			file, err := column.Inspect(path)
+			if err != nil {
+				return err
+			}
+			if file.Path, err = filepath.Rel(dir, path); err != nil {
+				return err
+			}
+			file.Path = filepath.ToSlash(file.Path)
+			report.add(file)
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, wrapError("StorageReport", c.name, "", err)
+		}
+	}
+	return report, nil
+}
+
+// add adds a file to the report and its totals
+func (r *StorageReport) add(file *column.FileReport) {
+	r.Files = append(r.Files, file)
+	r.FileBytes += file.FileSize
+	for _, col := range file.Columns {
+		r.DataBytes += col.CompressedBytes
+		r.UncompressedBytes += col.UncompressedBytes
+		for name, n := range col.Encodings {
+			r.Encodings[name] += n
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package vego
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageReport(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(8))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	// Nothing saved yet
+	report, err := coll.StorageReport()
+	if err != nil {
+		t.Fatalf("StorageReport failed: %v", err)
+	}
+	if len(report.Files) != 0 {
+		t.Errorf("Unsaved collection reports %d files", len(report.Files))
+	}
+
+	docs := make([]*Document, 500)
+	for i := range docs {
+		vector := make([]float32, 8)
+		for d := range vector {
+			vector[d] = float32(i*8+d) * 0.01
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc-%d", i), Vector: vector, Metadata: map[string]interface{}{"group": i % 4}}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	report, err = coll.StorageReport()
+	if err != nil {
+		t.Fatalf("StorageReport failed: %v", err)
+	}
+	files := make(map[string]bool)
+	var fileBytes int64
+	for _, file := range report.Files {
+		files[file.Path] = true
+		info, err := os.Stat(filepath.Join(path, "docs", filepath.FromSlash(file.Path)))
+		if err != nil {
+			t.Fatalf("Stat %s failed: %v", file.Path, err)
+		}
+		if info.Size() != file.FileSize {
+			t.Errorf("%s: reported %d bytes, file has %d", file.Path, file.FileSize, info.Size())
+		}
+		if got := file.HeaderBytes + file.DataBytes() + file.FooterBytes + file.RowIndexBytes; got != file.FileSize {
+			t.Errorf("%s: parts add up to %d bytes, file has %d", file.Path, got, file.FileSize)
+		}
+		fileBytes += file.FileSize
+	}
+	for _, name := range []string{"documents/vectors.lance", "index/nodes.lance", "index/connections.lance"} {
+		if !files[name] {
+			t.Errorf("Report is missing %s: %v", name, files)
+		}
+	}
+	if report.FileBytes != fileBytes || report.DataBytes <= 0 || report.UncompressedBytes <= 0 {
+		t.Errorf("Totals: %d file bytes (want %d), %d data, %d uncompressed",
+			report.FileBytes, fileBytes, report.DataBytes, report.UncompressedBytes)
+	}
+	if len(report.Encodings) < 2 {
+		t.Errorf("Encodings = %v, want several", report.Encodings)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("Marshal failed: %v", err)
+	}
+}
+
+func TestStorageReport_InMemory(t *testing.T) {
+	db, err := OpenInMemory(WithDimension(4))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if err := coll.Insert(&Document{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	report, err := coll.StorageReport()
+	if err != nil || len(report.Files) != 0 {
+		t.Errorf("StorageReport = %v, %v; want no files", report, err)
+	}
+}
@@ -0,0 +1,604 @@
+package vego
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// journalFileName is the write-ahead log of a collection, see WithWAL
+	journalFileName = "journal.wal"
+
+	// journalMagic starts the journal file, followed by the save the
+	// records apply on top of
+	journalMagic      = "VEGOWAL1"
+	journalHeaderSize = len(journalMagic) + 8
+
+	// defaultWALSyncInterval is used when Config.WALSyncInterval is 0
+	defaultWALSyncInterval = time.Second
+)
+
+// Journal record operations
+const (
+	journalPut    byte = 1 // Insert or replace a document
+	journalDelete byte = 2 // Delete a document
+)
+
+// journalCRC is the checksum table of journal records
+var journalCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// syncJournalFile fsyncs the journal file; tests replace it to emulate a
+// slow disk
+var syncJournalFile = (*os.File).Sync
+
+// journal is the write-ahead log of a collection. Every insert, update and
+// delete appends a record, after it is applied and before it returns, so
+// that opening the collection after a crash replays the changes made since
+// the last save onto it. Records are idempotent: a put stores the whole
+// document and a delete of a missing document does nothing.
+//
+// The file starts with journalMagic and the save the records follow; each
+// record is its length and CRC-32C, then the operation, the document ID
+// and, for a put, the timestamp, vector and metadata as JSON. Its methods
+// are no-ops on a nil journal, so collections without one call them
+// unconditionally. Appends happen under the collection's mu; the
+// journal's own mu also orders them against the background sync.
+//
+// With a negative interval a write is durable before it returns. By
+// default that is a group commit: appends only write, and the writer waits
+// in commit, after releasing the collection's mu, while a syncer goroutine
+// fsyncs everything appended so far for all waiting writers at once.
+// Without group commit every append fsyncs itself under the collection's
+// mu, so writers queue behind each other's fsyncs.
+type journal struct {
+	mu       sync.Mutex
+	file     *os.File
+	interval time.Duration // Between fsyncs; negative = before every write returns
+	synced   time.Time     // Last fsync
+	unsynced bool          // Records appended since then
+	size     int64         // Length of the file
+	durable  int64         // Length of the file the last fsync covered
+	epoch    int           // Resets so far; a reset makes earlier lengths stale
+
+	// Group commit. Positions count every byte ever appended; unlike the
+	// file length, a reset does not lower them.
+	group   bool
+	delay   time.Duration // Longest an fsync waits for more writes
+	bytes   int64         // Pending bytes that start an fsync before delay, 0 = none
+	written int64         // Position of the end of the last append
+	flushed int64         // Position the last successful fsync covered
+	syncing int64         // Position the running or last fsync covers
+	failed  int64         // Position the last failed fsync covered
+	syncErr error         // Error of that fsync
+	queued  int           // Writers waiting for the next fsync
+	joined  int           // Writers waiting for the running fsync
+	cond    *sync.Cond    // Broadcast when an fsync finishes
+	kick    chan struct{} // Wakes the syncer after an append
+	full    chan struct{} // Ends the syncer's delay once bytes are pending
+	stop    chan struct{}
+	done    chan struct{}
+
+	stats journalStats
+}
+
+// journalStats accumulates the fsyncs of a journal for WALStats
+type journalStats struct {
+	syncs      int64
+	syncTime   time.Duration
+	commits    int64
+	commitTime time.Duration
+	maxBatch   int
+}
+
+// WALStats describes the fsyncs of a collection's journal since it was
+// opened, see WithWAL. All zero without a journal.
+type WALStats struct {
+	Syncs         int64         // Fsyncs of the journal
+	SyncLatency   time.Duration // Mean duration of an fsync
+	Commits       int64         // Writes that waited for an fsync before returning
+	CommitLatency time.Duration // Mean time those writes waited, the effective sync latency
+	MeanBatch     float64       // Mean writes made durable by one fsync
+	MaxBatch      int           // Most writes made durable by one fsync
+}
+
+// journalRecord is a decoded journal record
+type journalRecord struct {
+	op  byte
+	doc *Document // Only the ID for a delete
+}
+
+// put journals the insertion or replacement of docs
+func (j *journal) put(docs ...*Document) error {
+	if j == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, doc := range docs {
+		meta, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return fmt.Errorf("journal %s: %w", doc.ID, err)
+		}
+		payload := binary.AppendUvarint([]byte{journalPut}, uint64(len(doc.ID)))
+		payload = append(payload, doc.ID...)
+		payload = binary.AppendVarint(payload, doc.Timestamp.UnixNano())
+		payload = binary.AppendUvarint(payload, uint64(len(doc.Vector)))
+		for _, v := range doc.Vector {
+			payload = binary.LittleEndian.AppendUint32(payload, math.Float32bits(v))
+		}
+		payload = binary.AppendUvarint(payload, uint64(len(meta)))
+		payload = append(payload, meta...)
+		appendJournalRecord(&buf, payload)
+	}
+	return j.append(buf.Bytes())
+}
+
+// delete journals the deletion of ids
+func (j *journal) delete(ids ...string) error {
+	if j == nil || len(ids) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, id := range ids {
+		payload := binary.AppendUvarint([]byte{journalDelete}, uint64(len(id)))
+		payload = append(payload, id...)
+		appendJournalRecord(&buf, payload)
+	}
+	return j.append(buf.Bytes())
+}
+
+// appendJournalRecord frames payload with its length and checksum
+func appendJournalRecord(buf *bytes.Buffer, payload []byte) {
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(payload, journalCRC))
+	buf.Write(header[:])
+	buf.Write(payload)
+}
+
+// append writes framed records in one write. With group commit it wakes
+// the syncer, and the writer waits in commit; otherwise it fsyncs if the
+// interval has passed.
+func (j *journal) append(records []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(records); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	j.size += int64(len(records))
+	j.written += int64(len(records))
+	j.unsynced = true
+
+	switch {
+	case j.group:
+		notify(j.kick)
+		if j.bytes > 0 && j.written-j.flushed >= j.bytes {
+			notify(j.full)
+		}
+	case j.interval < 0:
+		return j.syncLocked(1)
+	case time.Since(j.synced) >= j.interval:
+		return j.syncLocked(0)
+	}
+	return nil
+}
+
+// notify wakes the receiver of ch without blocking if it is already woken
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// commit waits until everything appended so far, which includes the
+// caller's records, is fsynced. It returns at once unless the journal does
+// group commit; the collection's mu must not be held, or the writers the
+// fsync would batch could not append.
+func (j *journal) commit() error {
+	if j == nil || !j.group {
+		return nil
+	}
+	start := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	target := j.written
+	if target <= j.flushed {
+		return nil
+	}
+	if target <= j.syncing {
+		j.joined++
+	} else {
+		j.queued++
+	}
+	for j.flushed < target {
+		if j.failed >= target {
+			return j.syncErr
+		}
+		j.cond.Wait()
+	}
+	j.stats.commits++
+	j.stats.commitTime += time.Since(start)
+	return nil
+}
+
+// runSyncer fsyncs the journal for group commit until close stops it. Woken
+// by an append, it waits up to delay, less once bytes are pending, for more
+// writers to join, then fsyncs everything appended by then. Appends made
+// while the fsync runs are covered by the next one.
+func (j *journal) runSyncer() {
+	defer close(j.done)
+	for {
+		select {
+		case <-j.kick:
+		case <-j.stop:
+			return
+		}
+		if j.delay > 0 {
+			timer := time.NewTimer(j.delay)
+			select {
+			case <-timer.C:
+			case <-j.full:
+			case <-j.stop:
+				timer.Stop()
+				return
+			}
+			timer.Stop()
+		}
+		j.groupSync()
+	}
+}
+
+// groupSync fsyncs the records appended so far without holding mu, so
+// writers keep appending meanwhile, and wakes the writers it covers
+func (j *journal) groupSync() {
+	j.mu.Lock()
+	target, size, epoch := j.written, j.size, j.epoch
+	if target <= j.flushed {
+		j.mu.Unlock()
+		return
+	}
+	j.syncing = target
+	batch := j.queued
+	j.queued = 0
+	j.mu.Unlock()
+
+	start := time.Now()
+	err := syncJournalFile(j.file)
+	elapsed := time.Since(start)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	batch += j.joined
+	j.joined = 0
+	if err != nil {
+		j.failed = target
+		j.syncErr = fmt.Errorf("sync journal: %w", err)
+	} else {
+		j.flushed = max(j.flushed, target)
+		if epoch == j.epoch {
+			j.durable = size
+		}
+		j.synced = time.Now()
+		j.unsynced = j.written > j.flushed
+	}
+	j.stats.record(batch, elapsed)
+	j.cond.Broadcast()
+	if j.written > target {
+		notify(j.kick)
+	}
+}
+
+// record counts an fsync that made batch writes durable
+func (s *journalStats) record(batch int, elapsed time.Duration) {
+	s.syncs++
+	s.syncTime += elapsed
+	s.maxBatch = max(s.maxBatch, batch)
+}
+
+// walStats returns the journal's WALStats
+func (j *journal) walStats() WALStats {
+	if j == nil {
+		return WALStats{}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := j.stats
+	stats := WALStats{Syncs: s.syncs, Commits: s.commits, MaxBatch: s.maxBatch}
+	if s.syncs > 0 {
+		stats.SyncLatency = s.syncTime / time.Duration(s.syncs)
+		stats.MeanBatch = float64(s.commits) / float64(s.syncs)
+	}
+	if s.commits > 0 {
+		stats.CommitLatency = s.commitTime / time.Duration(s.commits)
+	}
+	return stats
+}
+
+// durableSize returns the length of the journal file the last fsync
+// covered, what a machine crash now would leave of it
+func (j *journal) durableSize() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.durable
+}
+
+// sync fsyncs records appended since the last fsync
+func (j *journal) sync() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.syncLocked(0)
+}
+
+// syncLocked fsyncs the journal with mu held, making batch writes durable
+func (j *journal) syncLocked(batch int) error {
+	if !j.unsynced {
+		return nil
+	}
+	start := time.Now()
+	if err := syncJournalFile(j.file); err != nil {
+		return fmt.Errorf("sync journal: %w", err)
+	}
+	elapsed := time.Since(start)
+	j.stats.record(batch, elapsed)
+	j.stats.commits += int64(batch)
+	j.stats.commitTime += time.Duration(batch) * elapsed
+	j.synced = time.Now()
+	j.unsynced = false
+	j.durable = j.size
+	j.flushed = j.written
+	if j.group {
+		j.cond.Broadcast()
+	}
+	return nil
+}
+
+// reset empties the journal once save id has written everything in it
+func (j *journal) reset(id uint64) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncate journal: %w", err)
+	}
+	if _, err := j.file.WriteAt(journalHeader(id), 0); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if _, err := j.file.Seek(int64(journalHeaderSize), 0); err != nil {
+		return err
+	}
+	j.size = int64(journalHeaderSize)
+	j.epoch++
+	j.unsynced = true
+	return j.syncLocked(0)
+}
+
+// close stops the syncer, then fsyncs and closes the journal
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+	if j.group {
+		close(j.stop)
+		<-j.done
+	}
+	err := j.sync()
+	if cerr := j.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// journalHeader returns the header of a journal following save id
+func journalHeader(id uint64) []byte {
+	return binary.LittleEndian.AppendUint64([]byte(journalMagic), id)
+}
+
+// readJournal decodes the records of a journal file that follow save id.
+// It stops at the first record that is truncated or fails its checksum, as
+// a crash while appending leaves, and returns the records before it and
+// the length of the file they span. A journal that follows another save,
+// whose records that save already holds, or has no valid header has no
+// records.
+func readJournal(data []byte, id uint64) ([]journalRecord, int, error) {
+	if len(data) < journalHeaderSize || string(data[:len(journalMagic)]) != journalMagic {
+		return nil, 0, errors.New("no journal header")
+	}
+	if base := binary.LittleEndian.Uint64(data[len(journalMagic):]); base != id {
+		return nil, journalHeaderSize, nil
+	}
+
+	var records []journalRecord
+	offset := journalHeaderSize
+	for offset < len(data) {
+		if len(data)-offset < 8 {
+			return records, offset, errors.New("truncated record header")
+		}
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		sum := binary.LittleEndian.Uint32(data[offset+4:])
+		if size > len(data)-offset-8 {
+			return records, offset, errors.New("truncated record")
+		}
+		payload := data[offset+8 : offset+8+size]
+		if crc32.Checksum(payload, journalCRC) != sum {
+			return records, offset, errors.New("checksum mismatch")
+		}
+		record, err := decodeJournalRecord(payload)
+		if err != nil {
+			return records, offset, err
+		}
+		records = append(records, record)
+		offset += 8 + size
+	}
+	return records, offset, nil
+}
+
+// decodeJournalRecord decodes the payload of a journal record
+func decodeJournalRecord(payload []byte) (journalRecord, error) {
+	bad := errors.New("malformed record")
+	if len(payload) == 0 {
+		return journalRecord{}, bad
+	}
+	record := journalRecord{op: payload[0], doc: &Document{}}
+	rest := payload[1:]
+
+	readBytes := func() ([]byte, bool) {
+		n, k := binary.Uvarint(rest)
+		if k <= 0 || n > uint64(len(rest)-k) {
+			return nil, false
+		}
+		b := rest[k : k+int(n)]
+		rest = rest[k+int(n):]
+		return b, true
+	}
+
+	id, ok := readBytes()
+	if !ok {
+		return journalRecord{}, bad
+	}
+	record.doc.ID = string(id)
+
+	switch record.op {
+	case journalDelete:
+		return record, nil
+	case journalPut:
+	default:
+		return journalRecord{}, fmt.Errorf("unknown operation %d", record.op)
+	}
+
+	nanos, k := binary.Varint(rest)
+	if k <= 0 {
+		return journalRecord{}, bad
+	}
+	rest = rest[k:]
+	record.doc.Timestamp = time.Unix(0, nanos)
+
+	dim, k := binary.Uvarint(rest)
+	if k <= 0 || dim > uint64(len(rest)-k)/4 {
+		return journalRecord{}, bad
+	}
+	rest = rest[k:]
+	record.doc.Vector = make([]float32, dim)
+	for i := range record.doc.Vector {
+		record.doc.Vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(rest[4*i:]))
+	}
+	rest = rest[4*dim:]
+
+	meta, ok := readBytes()
+	if !ok {
+		return journalRecord{}, bad
+	}
+	if err := json.Unmarshal(meta, &record.doc.Metadata); err != nil {
+		return journalRecord{}, fmt.Errorf("metadata: %w", err)
+	}
+	return record, nil
+}
+
+// openJournal replays the collection's journal onto what load restored
+// and opens it for appending. A journal that is corrupt part way is
+// replayed up to the corruption and cut there: the open does not fail, and
+// the records after it, written by the write that crashed or after it, are
+// lost. c.mu need not be held; the collection is not yet shared.
+func (c *Collection) openJournal() error {
+	path := filepath.Join(c.path, journalFileName)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	valid := 0
+	if len(data) > 0 {
+		records, n, err := readJournal(data, c.generation)
+		if err != nil {
+			log.Printf("Warning: journal of collection %s is corrupt after %d records, dropping the rest: %v", c.name, len(records), err)
+		}
+		for _, record := range records {
+			if err := c.replay(record); err != nil {
+				log.Printf("Warning: failed to replay journal record for document %s of collection %s: %v", record.doc.ID, c.name, err)
+			}
+		}
+		if len(records) > 0 {
+			log.Printf("Replayed %d journal records of collection %s", len(records), c.name)
+		}
+		valid = n
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	interval := c.config.WALSyncInterval
+	if interval == 0 {
+		interval = defaultWALSyncInterval
+	}
+	j := &journal{file: file, interval: interval, synced: time.Now()}
+
+	// Keep the valid records, which the next save writes out
+	if valid == 0 {
+		err = j.reset(c.generation)
+	} else if err = file.Truncate(int64(valid)); err == nil {
+		_, err = file.Seek(int64(valid), 0)
+		j.size, j.durable = int64(valid), int64(valid)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if interval < 0 && c.config.WALGroupCommitDelay >= 0 {
+		j.group = true
+		j.delay = c.config.WALGroupCommitDelay
+		j.bytes = int64(c.config.WALGroupCommitBytes)
+		j.cond = sync.NewCond(&j.mu)
+		j.kick = make(chan struct{}, 1)
+		j.full = make(chan struct{}, 1)
+		j.stop = make(chan struct{})
+		j.done = make(chan struct{})
+		go j.runSyncer()
+	}
+	c.wal = j
+
+	if interval > 0 {
+		c.schedule("journal sync", interval, func(context.Context) error {
+			return c.wal.sync()
+		})
+	}
+	return nil
+}
+
+// replay applies a journal record. c.wal is not yet set, so nothing is
+// journaled again.
+func (c *Collection) replay(record journalRecord) error {
+	doc := record.doc
+	nodeID, exists := c.docToNode[doc.ID]
+	switch {
+	case record.op == journalDelete && exists:
+		return c.deleteDocument(doc.ID, nodeID)
+	case record.op == journalDelete:
+		return nil
+	case len(doc.Vector) != c.dimension:
+		return ErrDimensionMismatch
+	case exists:
+		return c.replaceDocument(doc, nodeID)
+	default:
+		return c.addDocument(doc)
+	}
+}
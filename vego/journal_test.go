@@ -0,0 +1,328 @@
+package vego
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func journalConfig() *Config {
+	config := barrierConfig(false)
+	config.WAL = true
+	config.WALSyncInterval = -1
+	return config
+}
+
+// crashCopy copies the directory of coll as a process killed now would leave
+// it: whatever the collection holds only in memory is lost
+func crashCopy(t *testing.T, coll *Collection) string {
+	t.Helper()
+	dst := filepath.Join(t.TempDir(), coll.name)
+	err := filepath.WalkDir(coll.path, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(coll.path, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+	if err != nil {
+		t.Fatalf("copy collection: %v", err)
+	}
+	return dst
+}
+
+// journalState returns the document IDs of coll with their "i" metadata
+func journalState(t *testing.T, coll *Collection) map[string]interface{} {
+	t.Helper()
+	state := make(map[string]interface{})
+	for id := range coll.docToNode {
+		doc, err := coll.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", id, err)
+		}
+		state[id] = doc.Metadata["i"]
+	}
+	return state
+}
+
+func TestJournalRecovery(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	coll, err := NewCollection("wal", filepath.Join(t.TempDir(), "wal"), journalConfig())
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	defer coll.Close()
+
+	if err := coll.InsertBatch(barrierDocs(rng, 0, 50)); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	walPath := filepath.Join(coll.path, journalFileName)
+	if info, err := os.Stat(walPath); err != nil || info.Size() != int64(journalHeaderSize) {
+		t.Fatalf("journal after Save: %v, %v", info, err)
+	}
+
+	// Unsaved writes, with the journal size after each
+	var sizes []int64
+	step := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		info, err := os.Stat(walPath)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		sizes = append(sizes, info.Size())
+	}
+	step(coll.InsertBatch(barrierDocs(rng, 50, 60)))
+	for _, doc := range barrierDocs(rng, 60, 63) {
+		step(coll.Insert(doc))
+	}
+	updated := barrierDocs(rng, 5, 6)[0]
+	updated.Metadata["i"] = 500
+	step(coll.Update(updated))
+	step(coll.Delete("doc_007"))
+	step(coll.Delete("doc_055"))
+	want := journalState(t, coll)
+
+	reopen := func(t *testing.T, path string) *Collection {
+		t.Helper()
+		reopened, err := NewCollection("wal", path, journalConfig())
+		if err != nil {
+			t.Fatalf("NewCollection after crash failed: %v", err)
+		}
+		t.Cleanup(func() { reopened.Close() })
+		return reopened
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		recovered := reopen(t, crashCopy(t, coll))
+		if got := journalState(t, recovered); len(got) != len(want) || got["doc_005"] != float64(500) {
+			t.Fatalf("recovered %d documents, doc_005 = %v; want %d, 500", len(got), got["doc_005"], len(want))
+		}
+		for id := range want {
+			if _, err := recovered.Get(id); err != nil {
+				t.Errorf("document %s lost: %v", id, err)
+			}
+		}
+		for _, id := range []string{"doc_007", "doc_055"} {
+			if _, err := recovered.Get(id); !IsNotFound(err) {
+				t.Errorf("deleted document %s recovered: %v", id, err)
+			}
+		}
+		results, err := recovered.Search(updated.Vector, 1)
+		if err != nil || len(results) != 1 || results[0].Document.ID != "doc_005" {
+			t.Errorf("Search for the updated vector = %v, %v", results, err)
+		}
+
+		// Replayed records stay journaled until the next save
+		if err := recovered.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if got := journalState(t, reopen(t, recovered.path)); len(got) != len(want) {
+			t.Errorf("%d documents after save, want %d", len(got), len(want))
+		}
+	})
+
+	t.Run("TornRecord", func(t *testing.T) {
+		// A crash half-way through appending the last delete
+		path := crashCopy(t, coll)
+		cut := (sizes[len(sizes)-2] + sizes[len(sizes)-1]) / 2
+		if err := os.Truncate(filepath.Join(path, journalFileName), cut); err != nil {
+			t.Fatal(err)
+		}
+		got := journalState(t, reopen(t, path))
+		if len(got) != len(want)+1 || got["doc_055"] == nil {
+			t.Errorf("recovered %d documents, want %d with doc_055", len(got), len(want)+1)
+		}
+		if info, err := os.Stat(filepath.Join(path, journalFileName)); err != nil || info.Size() != sizes[len(sizes)-2] {
+			t.Errorf("torn record not cut from the journal: %v, %v", info, err)
+		}
+	})
+
+	t.Run("BadChecksum", func(t *testing.T) {
+		// A flipped byte in the first single insert drops it and what follows
+		path := crashCopy(t, coll)
+		data, err := os.ReadFile(filepath.Join(path, journalFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[(sizes[0]+sizes[1])/2] ^= 0xff
+		if err := os.WriteFile(filepath.Join(path, journalFileName), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got := journalState(t, reopen(t, path))
+		if len(got) != 60 || got["doc_060"] != nil || got["doc_007"] == nil || got["doc_005"] == float64(500) {
+			t.Errorf("recovered %d documents, want the 60 of the first batch", len(got))
+		}
+	})
+
+	t.Run("CrashDuringSave", func(t *testing.T) {
+		// The save restored on open is the one the journal follows
+		crashAfter(t, "index")
+		if err := coll.Save(); err == nil {
+			t.Fatal("Save succeeded despite the injected crash")
+		}
+		got := journalState(t, reopen(t, crashCopy(t, coll)))
+		if len(got) != len(want) || got["doc_005"] != float64(500) {
+			t.Errorf("recovered %d documents, want %d", len(got), len(want))
+		}
+	})
+}
+
+// journalInserters runs n goroutines inserting documents into coll until
+// stop is closed, and returns the IDs of the inserts that returned nil
+func journalInserters(t testing.TB, coll *Collection, n int, stop chan struct{}) func() []string {
+	var (
+		mu    sync.Mutex
+		acked []string
+		wg    sync.WaitGroup
+		next  atomic.Int64
+	)
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				i := next.Add(1)
+				doc := &Document{ID: fmt.Sprintf("w%02d_%06d", w, i), Vector: make([]float32, 16), Metadata: map[string]interface{}{"i": i}}
+				for j := range doc.Vector {
+					doc.Vector[j] = rng.Float32()
+				}
+				if err := coll.Insert(doc); err != nil {
+					t.Errorf("Insert failed: %v", err)
+					return
+				}
+				mu.Lock()
+				acked = append(acked, doc.ID)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	return func() []string {
+		wg.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return acked
+	}
+}
+
+func TestJournalGroupCommit(t *testing.T) {
+	// A slow disk, so writers queue up behind each fsync
+	syncJournalFile = func(f *os.File) error {
+		time.Sleep(2 * time.Millisecond)
+		return f.Sync()
+	}
+	defer func() { syncJournalFile = (*os.File).Sync }()
+
+	coll, err := NewCollection("wal", filepath.Join(t.TempDir(), "wal"), journalConfig())
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	defer coll.Close()
+
+	stop := make(chan struct{})
+	wait := journalInserters(t, coll, 32, stop)
+	time.Sleep(300 * time.Millisecond)
+
+	close(stop)
+	acked := wait()
+	if len(acked) == 0 {
+		t.Fatal("no insert returned")
+	}
+
+	// A machine crash now keeps what the last fsync covered, which must
+	// hold every insert that returned
+	durable := coll.wal.durableSize()
+
+	stats := coll.Stats().WAL
+	t.Logf("%d inserts by 32 writers, %+v", len(acked), stats)
+	if stats.Commits < int64(len(acked)) || stats.Syncs == 0 || stats.MaxBatch < 2 || stats.Syncs >= stats.Commits {
+		t.Errorf("fsyncs were not shared between writers: %+v", stats)
+	}
+
+	path := crashCopy(t, coll)
+	if err := os.Truncate(filepath.Join(path, journalFileName), durable); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := NewCollection("wal", path, journalConfig())
+	if err != nil {
+		t.Fatalf("NewCollection after crash failed: %v", err)
+	}
+	defer recovered.Close()
+	for _, id := range acked {
+		if _, exists := recovered.docToNode[id]; !exists {
+			t.Fatalf("acknowledged insert %s lost in the crash", id)
+		}
+	}
+}
+
+// BenchmarkJournalConcurrentInserts compares 32 writers fsyncing the
+// journal one at a time with the same writers sharing fsyncs, on this disk
+// and on one emulating the fsync latency of a spinning disk
+func BenchmarkJournalConcurrentInserts(b *testing.B) {
+	for _, disk := range []struct {
+		name    string
+		latency time.Duration
+	}{
+		{"Disk", 0},
+		{"Spinning", 8 * time.Millisecond},
+	} {
+		for _, mode := range []struct {
+			name  string
+			delay time.Duration
+		}{
+			{"PerWriteSync", -1},
+			{"GroupCommit", 0},
+		} {
+			b.Run(disk.name+"/"+mode.name, func(b *testing.B) {
+				if disk.latency > 0 {
+					syncJournalFile = func(f *os.File) error {
+						time.Sleep(disk.latency)
+						return f.Sync()
+					}
+					defer func() { syncJournalFile = (*os.File).Sync }()
+				}
+				config := journalConfig()
+				config.WALGroupCommitDelay = mode.delay
+				coll, err := NewCollection("wal", filepath.Join(b.TempDir(), "wal"), config)
+				if err != nil {
+					b.Fatalf("NewCollection failed: %v", err)
+				}
+				defer coll.Close()
+
+				stop := make(chan struct{})
+				b.ResetTimer()
+				start := time.Now()
+				wait := journalInserters(b, coll, 32, stop)
+				time.Sleep(time.Duration(b.N) * time.Millisecond)
+				close(stop)
+				inserts := len(wait())
+				stats := coll.Stats().WAL
+				b.ReportMetric(float64(inserts)/time.Since(start).Seconds(), "inserts/s")
+				b.ReportMetric(stats.MeanBatch, "writes/fsync")
+				b.ReportMetric(float64(stats.CommitLatency.Microseconds()), "commit-µs")
+			})
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package vego
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+const (
+	// defaultMaintenanceChunk is MaintenanceWindow.ChunkBudget when it is 0
+	defaultMaintenanceChunk = 50 * time.Millisecond
+
+	// defaultMaintenanceCheck is MaintenanceWindow.CheckInterval when it is 0
+	defaultMaintenanceCheck = time.Minute
+
+	// searchLatencyIdle is how long after the last search a collection's
+	// search latency stops counting as live
+	searchLatencyIdle = time.Minute
+
+	// maintenanceFileName holds the progress of a collection's maintenance
+	// tasks; it is not part of saves
+	maintenanceFileName = "maintenance.json"
+)
+
+// MaintenanceWindow schedules the maintenance tasks of a DB's collections
+// into a daily low-traffic window, see WithMaintenanceWindow
+type MaintenanceWindow struct {
+	// Daily is the window as "HH:MM-HH:MM" in Location, e.g. "01:00-05:00";
+	// an end at or before the start runs past midnight. Empty = disabled.
+	Daily string
+
+	MaxDuration      time.Duration  // Time spent on maintenance per window, 0 = the whole window
+	ChunkBudget      time.Duration  // Longest a chunk may hold a collection, 0 = 50ms
+	MaxSearchLatency time.Duration  // Back off while recent search latency exceeds this, 0 = never
+	CheckInterval    time.Duration  // How often the window is checked, 0 = 1 minute
+	Location         *time.Location // Time zone of Daily, nil = time.Local
+}
+
+// enabled reports whether w schedules anything
+func (w MaintenanceWindow) enabled() bool {
+	return w.Daily != ""
+}
+
+// validate checks the parameters of w
+func (w MaintenanceWindow) validate() error {
+	if !w.enabled() {
+		return nil
+	}
+	if _, _, err := parseDailyWindow(w.Daily); err != nil {
+		return err
+	}
+	if w.MaxDuration < 0 || w.ChunkBudget < 0 || w.MaxSearchLatency < 0 || w.CheckInterval < 0 {
+		return fmt.Errorf("%w: maintenance window durations must not be negative", ErrValidationFailed)
+	}
+	return nil
+}
+
+// parseDailyWindow returns the start and end of spec, "HH:MM-HH:MM", as
+// offsets from midnight
+func parseDailyWindow(spec string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(spec, "-")
+	if ok {
+		if start, ok = parseClock(from); ok {
+			end, ok = parseClock(to)
+		}
+	}
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: maintenance window %q is not HH:MM-HH:MM", ErrValidationFailed, spec)
+	}
+	return start, end, nil
+}
+
+// parseClock returns "HH:MM" as an offset from midnight
+func parseClock(s string) (time.Duration, bool) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || len(m) != 2 {
+		return 0, false
+	}
+	hours, err := strconv.Atoi(h)
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(m)
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, true
+}
+
+// MaintenanceFunc runs one chunk of a maintenance task, see
+// Collection.RegisterMaintenance. It gets the chunk's time budget and the
+// progress the previous chunk returned, nil for the first, and should stop
+// at a point it can resume from once budget is spent or ctx is done. It
+// returns the progress to resume from and whether the task is done for the
+// current window.
+type MaintenanceFunc func(ctx context.Context, budget time.Duration, progress []byte) (next []byte, done bool, err error)
+
+// MaintenanceStatus is the state of a maintenance window task, as reported
+// in BackgroundTask.Maintenance
+type MaintenanceStatus struct {
+	Window       time.Time     // Start of the window the task last ran in, zero if it never has
+	Done         bool          // Whether it finished in that window
+	Chunks       int           // Chunks run since the collection was opened
+	Backoffs     int           // Times it was held back by search latency
+	LongestChunk time.Duration // Longest chunk run
+}
+
+// maintenanceState is the persisted progress of a maintenance task
+type maintenanceState struct {
+	Window   time.Time `json:"window"`
+	Done     bool      `json:"done"`
+	Progress []byte    `json:"progress,omitempty"`
+}
+
+// maintenanceTask is a task registered with a collection for the
+// maintenance window. Fields below run are guarded by
+// Collection.maintMu.
+type maintenanceTask struct {
+	name string
+	run  MaintenanceFunc
+
+	state    maintenanceState
+	running  bool
+	chunks   int
+	backoffs int
+	longest  time.Duration
+	lastRun  time.Time
+	lastErr  error
+}
+
+// maintenanceRunner works through the maintenance tasks of a DB during its
+// window
+type maintenanceRunner struct {
+	window     MaintenanceWindow
+	start, end time.Duration    // Window as offsets from midnight
+	now        func() time.Time // Clock of the window, time.Now outside tests
+
+	mu      sync.Mutex    // Serializes runs, guards the fields below
+	current time.Time     // Start of the window worked in last
+	spent   time.Duration // Time spent in it
+}
+
+// newMaintenanceRunner returns the runner of w, which must be valid
+func newMaintenanceRunner(w MaintenanceWindow) *maintenanceRunner {
+	start, end, _ := parseDailyWindow(w.Daily)
+	if w.ChunkBudget == 0 {
+		w.ChunkBudget = defaultMaintenanceChunk
+	}
+	if w.CheckInterval == 0 {
+		w.CheckInterval = defaultMaintenanceCheck
+	}
+	if w.Location == nil {
+		w.Location = time.Local
+	}
+	return &maintenanceRunner{window: w, start: start, end: end, now: time.Now}
+}
+
+// occurrence returns the start and end of the window containing now, or
+// ok=false outside the window
+func (m *maintenanceRunner) occurrence(now time.Time) (start, end time.Time, ok bool) {
+	length := m.end - m.start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+	y, mo, d := now.In(m.window.Location).Date()
+	// A window running past midnight may have started the day before
+	for _, day := range []int{d - 1, d} {
+		start = time.Date(y, mo, day, 0, 0, 0, 0, m.window.Location).Add(m.start)
+		end = start.Add(length)
+		if !now.Before(start) && now.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// next returns the start of the first window after now
+func (m *maintenanceRunner) next(now time.Time) time.Time {
+	y, mo, d := now.In(m.window.Location).Date()
+	start := time.Date(y, mo, d, 0, 0, 0, 0, m.window.Location).Add(m.start)
+	if !start.After(now) {
+		start = time.Date(y, mo, d+1, 0, 0, 0, 0, m.window.Location).Add(m.start)
+	}
+	return start
+}
+
+// startMaintenance schedules the maintenance window of a writable database
+// on its background pool
+func (db *DB) startMaintenance() {
+	if !db.config.Maintenance.enabled() || db.config.ReadOnly {
+		return
+	}
+	db.maint = newMaintenanceRunner(db.config.Maintenance)
+	interval := db.maint.window.CheckInterval
+	db.sched.schedule("", "maintenance window", interval, interval/10, db.runMaintenance)
+}
+
+// runMaintenance runs chunks of the collections' maintenance tasks, in
+// order of collection and registration, until the window ends, its
+// MaxDuration is spent, every task is done for the window, search latency
+// exceeds MaxSearchLatency, or ctx is done. Time is measured on the
+// runner's clock.
+func (db *DB) runMaintenance(ctx context.Context) error {
+	m := db.maint
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start, end, ok := m.occurrence(m.now())
+	if !ok {
+		return nil
+	}
+	if !start.Equal(m.current) {
+		m.current, m.spent = start, 0
+	}
+
+	colls := db.maintenanceCollections()
+	for _, coll := range colls {
+		for _, task := range coll.maintenanceTasks() {
+			for !coll.maintenanceDone(task, start) {
+				if ctx.Err() != nil {
+					return nil
+				}
+				now := m.now()
+				budget := min(m.window.ChunkBudget, end.Sub(now))
+				if m.window.MaxDuration > 0 {
+					budget = min(budget, m.window.MaxDuration-m.spent)
+				}
+				if budget <= 0 {
+					return nil
+				}
+				if limit := m.window.MaxSearchLatency; limit > 0 && maxSearchLatency(colls) > limit {
+					coll.backOff(task)
+					return nil
+				}
+
+				_, err := coll.runMaintenanceChunk(ctx, task, start, budget)
+				m.spent += m.now().Sub(now)
+				if err != nil {
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// maintenanceCollections returns the open collections of the database
+// sorted by name, with sharded collections replaced by their shards
+func (db *DB) maintenanceCollections() []*Collection {
+	db.mu.RLock()
+	names := make([]string, 0, len(db.collections))
+	for name := range db.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var colls []*Collection
+	for _, name := range names {
+		coll := db.collections[name]
+		if coll.shards == nil {
+			colls = append(colls, coll)
+			continue
+		}
+		for _, shard := range coll.shards {
+			if shard != nil {
+				colls = append(colls, shard)
+			}
+		}
+	}
+	db.mu.RUnlock()
+	return colls
+}
+
+// maintenanceStatus lists the maintenance tasks of the database's
+// collections for BackgroundTasks
+func (db *DB) maintenanceStatus() []BackgroundTask {
+	if db.maint == nil {
+		return nil
+	}
+	now := db.maint.now()
+	var tasks []BackgroundTask
+	for _, coll := range db.maintenanceCollections() {
+		tasks = append(tasks, coll.maintenanceStatus(db.maint, now)...)
+	}
+	return tasks
+}
+
+// maxSearchLatency returns the highest live search latency of colls
+func maxSearchLatency(colls []*Collection) time.Duration {
+	var latency time.Duration
+	for _, coll := range colls {
+		latency = max(latency, coll.latency.live())
+	}
+	return latency
+}
+
+// searchLatency is a moving average of the latency of a collection's
+// searches
+type searchLatency struct {
+	mu   sync.Mutex
+	avg  time.Duration
+	last time.Time // When the last search finished
+}
+
+// observe records a search that took d
+func (l *searchLatency) observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last.IsZero() {
+		l.avg = d
+	} else {
+		l.avg += (d - l.avg) / 8
+	}
+	l.last = time.Now()
+}
+
+// live returns the average, or 0 if there has been no search for a while
+func (l *searchLatency) live() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.last) > searchLatencyIdle {
+		return 0
+	}
+	return l.avg
+}
+
+// observeSearch records the latency of a search started at start
+func (c *Collection) observeSearch(start time.Time) {
+	c.latency.observe(time.Since(start))
+}
+
+// RegisterMaintenance adds a task that runs in chunks during the DB's
+// maintenance window, see WithMaintenanceWindow; without a window it never
+// runs. Each chunk should hold the collection no longer than its budget.
+// The progress a chunk returns is saved in the collection's directory, so
+// the next chunk resumes from it in the next window or after a restart.
+// Once a chunk reports done, the task rests until the next window.
+//
+// Names are unique per collection; "optimize" is registered by vego and
+// re-prunes the index, see Optimize.
+func (c *Collection) RegisterMaintenance(name string, fn MaintenanceFunc) error {
+	_, done, err := c.beginWrite(context.Background(), "RegisterMaintenance")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if name == "" || fn == nil {
+		return wrapError("RegisterMaintenance", c.name, "", fmt.Errorf("%w: maintenance task needs a name and a function", ErrValidationFailed))
+	}
+	c.maintMu.Lock()
+	defer c.maintMu.Unlock()
+	for _, t := range c.maint {
+		if t.name == name {
+			return wrapError("RegisterMaintenance", c.name, "", fmt.Errorf("%w: maintenance task %q is already registered", ErrValidationFailed, name))
+		}
+	}
+	c.addMaintenance(name, fn)
+	return nil
+}
+
+// addMaintenance registers a maintenance task, resuming its saved progress.
+// c.maintMu must be held.
+func (c *Collection) addMaintenance(name string, fn MaintenanceFunc) {
+	c.maint = append(c.maint, &maintenanceTask{name: name, run: fn, state: c.maintSaved[name]})
+}
+
+// maintenanceTasks returns the registered maintenance tasks
+func (c *Collection) maintenanceTasks() []*maintenanceTask {
+	c.maintMu.Lock()
+	defer c.maintMu.Unlock()
+	return append([]*maintenanceTask(nil), c.maint...)
+}
+
+// maintenanceDone reports whether t finished in the window starting at
+// window
+func (c *Collection) maintenanceDone(t *maintenanceTask, window time.Time) bool {
+	c.maintMu.Lock()
+	defer c.maintMu.Unlock()
+	return t.state.Done && t.state.Window.Equal(window)
+}
+
+// backOff records that t was held back by search latency
+func (c *Collection) backOff(t *maintenanceTask) {
+	c.maintMu.Lock()
+	defer c.maintMu.Unlock()
+	t.backoffs++
+}
+
+// runMaintenanceChunk runs one chunk of t, at most budget long, in the
+// window starting at window, and saves its progress. It reports whether t
+// is done for the window.
+func (c *Collection) runMaintenanceChunk(ctx context.Context, t *maintenanceTask, window time.Time, budget time.Duration) (bool, error) {
+	ctx, done, err := c.beginWrite(ctx, "Maintenance")
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	c.maintMu.Lock()
+	if !t.state.Window.Equal(window) {
+		t.state.Window, t.state.Done = window, false
+	}
+	progress := t.state.Progress
+	t.running = true
+	c.maintMu.Unlock()
+
+	start := time.Now()
+	next, finished, err := t.run(ctx, budget, progress)
+	elapsed := time.Since(start)
+
+	c.maintMu.Lock()
+	defer c.maintMu.Unlock()
+	t.running = false
+	t.chunks++
+	t.longest = max(t.longest, elapsed)
+	t.lastRun = time.Now()
+	t.lastErr = err
+	if err != nil {
+		return false, wrapError("Maintenance", c.name, "", err)
+	}
+	t.state.Progress, t.state.Done = next, finished
+	if err := c.saveMaintenance(); err != nil {
+		return finished, wrapError("Maintenance", c.name, "", err)
+	}
+	return finished, nil
+}
+
+// maintenanceStatus describes the collection's maintenance tasks as
+// background tasks of runner at now
+func (c *Collection) maintenanceStatus(runner *maintenanceRunner, now time.Time) []BackgroundTask {
+	start, _, inWindow := runner.occurrence(now)
+	c.maintMu.Lock()
+	defer c.maintMu.Unlock()
+	tasks := make([]BackgroundTask, 0, len(c.maint))
+	for _, t := range c.maint {
+		next := runner.next(now)
+		if inWindow && !(t.state.Done && t.state.Window.Equal(start)) {
+			next = now
+		}
+		tasks = append(tasks, BackgroundTask{
+			Collection: c.name,
+			Name:       t.name,
+			Interval:   24 * time.Hour,
+			Runs:       t.chunks,
+			Running:    t.running,
+			LastRun:    t.lastRun,
+			LastError:  t.lastErr,
+			NextRun:    next,
+			Maintenance: &MaintenanceStatus{
+				Window:       t.state.Window,
+				Done:         t.state.Done,
+				Chunks:       t.chunks,
+				Backoffs:     t.backoffs,
+				LongestChunk: t.longest,
+			},
+		})
+	}
+	return tasks
+}
+
+// loadMaintenance reads the saved progress of the collection's maintenance
+// tasks; a missing or unreadable file starts them afresh
+func (c *Collection) loadMaintenance() {
+	if c.config.InMemory {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(c.path, maintenanceFileName))
+	if err != nil {
+		return
+	}
+	var saved map[string]maintenanceState
+	if json.Unmarshal(data, &saved) == nil {
+		c.maintSaved = saved
+	}
+}
+
+// saveMaintenance writes the progress of the collection's maintenance
+// tasks, keeping that of tasks not registered in this process.
+// c.maintMu must be held.
+func (c *Collection) saveMaintenance() error {
+	if c.maintSaved == nil {
+		c.maintSaved = make(map[string]maintenanceState)
+	}
+	for _, t := range c.maint {
+		c.maintSaved[t.name] = t.state
+	}
+	if c.config.InMemory {
+		return nil
+	}
+	data, err := json.Marshal(c.maintSaved)
+	if err != nil {
+		return err
+	}
+	return lanceio.WriteFileAtomic(filepath.Join(c.path, maintenanceFileName), data, 0644)
+}
+
+// optimizeProgress is the progress of the "optimize" maintenance task
+type optimizeProgress struct {
+	Next    int `json:"next"`    // Node the next chunk starts from
+	Visited int `json:"visited"` // Nodes visited in the current pass
+}
+
+// optimizeChunk is the "optimize" maintenance task: one Optimize pass over
+// the whole graph per window, a budget at a time. Writes wait for each
+// chunk, searches do not.
+func (c *Collection) optimizeChunk(ctx context.Context, budget time.Duration, progress []byte) ([]byte, bool, error) {
+	var p optimizeProgress
+	if len(progress) > 0 && json.Unmarshal(progress, &p) != nil {
+		p = optimizeProgress{}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Optimize visits the slots of deleted nodes too
+	nodes := c.index.Len() + c.index.Deleted()
+	if p.Visited >= nodes {
+		// The graph is empty, or shrank below the nodes already visited
+		next, _ := json.Marshal(optimizeProgress{Next: p.Next})
+		return next, true, nil
+	}
+	c.index.SetOptimizeCursor(p.Next)
+	report, err := c.index.Optimize(ctx, hnsw.OptimizeOptions{
+		MaxNodes:   nodes - p.Visited,
+		TimeBudget: budget,
+		Workers:    c.config.AutoOptimize.Workers,
+	})
+	p.Next = report.Next
+	p.Visited += report.Visited
+	finished := p.Visited >= nodes
+	if finished {
+		p.Visited = 0
+	}
+	next, _ := json.Marshal(p)
+	return next, finished, err
+}
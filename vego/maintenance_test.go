@@ -0,0 +1,292 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a maintenance clock the test moves by hand
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// openMaintenanceDB opens a DB at path with a 01:00-03:00 UTC window on
+// clock, whose chunks may spend 5 of at most 30 minutes per window
+func openMaintenanceDB(t *testing.T, path string, clock *fakeClock, opts ...Option) *DB {
+	t.Helper()
+	opts = append([]Option{WithDimension(4),
+		WithMaintenanceWindow("01:00-03:00", 30*time.Minute),
+		WithMaintenanceLimits(5*time.Minute, 0),
+		func(c *Config) { c.Maintenance.Location = time.UTC },
+	}, opts...)
+	db, err := Open(path, opts...)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	db.maint.mu.Lock()
+	db.maint.now = clock.Now
+	db.maint.mu.Unlock()
+	return db
+}
+
+// countingTask returns a maintenance task that needs chunks chunks, each
+// spending its budget on clock, and records the progress it resumed from
+func countingTask(clock *fakeClock, chunks int, resumed *[]int) MaintenanceFunc {
+	return func(ctx context.Context, budget time.Duration, progress []byte) ([]byte, bool, error) {
+		n := 0
+		if progress != nil {
+			n, _ = strconv.Atoi(string(progress))
+		}
+		*resumed = append(*resumed, n)
+		clock.Advance(budget)
+		n++
+		return []byte(strconv.Itoa(n)), n >= chunks, nil
+	}
+}
+
+// maintenanceTaskStatus returns the BackgroundTasks entry of a maintenance
+// task
+func maintenanceTaskStatus(t *testing.T, db *DB, collection, name string) BackgroundTask {
+	t.Helper()
+	for _, task := range db.BackgroundTasks() {
+		if task.Collection == collection && task.Name == name && task.Maintenance != nil {
+			return task
+		}
+	}
+	t.Fatalf("no maintenance task %s of %s", name, collection)
+	return BackgroundTask{}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	path := t.TempDir()
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: day.Add(30 * time.Minute)}
+	db := openMaintenanceDB(t, path, clock)
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := coll.Insert(&Document{ID: fmt.Sprint(i), Vector: []float32{float32(i), 1, 2, 3}}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	var resumed []int
+	if err := coll.RegisterMaintenance("count", countingTask(clock, 10, &resumed)); err != nil {
+		t.Fatalf("RegisterMaintenance failed: %v", err)
+	}
+	if err := coll.RegisterMaintenance("count", countingTask(clock, 1, &resumed)); !IsValidationFailed(err) {
+		t.Errorf("duplicate task = %v, want ErrValidationFailed", err)
+	}
+	ctx := context.Background()
+
+	// Nothing runs before the window
+	db.runMaintenance(ctx)
+	if len(resumed) != 0 {
+		t.Fatalf("%d chunks ran outside the window", len(resumed))
+	}
+	if task := maintenanceTaskStatus(t, db, "docs", "count"); !task.NextRun.Equal(day.Add(time.Hour)) {
+		t.Errorf("next run %v before the window", task.NextRun)
+	}
+
+	// In the window, the built-in optimize finishes its pass and the
+	// counting task gets the 30 minutes of MaxDuration: 6 chunks
+	clock.Set(day.Add(time.Hour))
+	db.runMaintenance(ctx)
+	if len(resumed) != 6 {
+		t.Fatalf("%d chunks in the first window, want 6", len(resumed))
+	}
+	optimize := maintenanceTaskStatus(t, db, "docs", "optimize")
+	if !optimize.Maintenance.Done || optimize.Runs == 0 || optimize.LastError != nil {
+		t.Errorf("optimize = %+v %+v", optimize, optimize.Maintenance)
+	}
+	count := maintenanceTaskStatus(t, db, "docs", "count")
+	if count.Maintenance.Done || count.Runs != 6 || !count.Maintenance.Window.Equal(day.Add(time.Hour)) {
+		t.Errorf("count = %+v %+v", count, count.Maintenance)
+	}
+
+	// MaxDuration is spent, so another check in the same window does nothing
+	clock.Advance(10 * time.Minute)
+	db.runMaintenance(ctx)
+	if len(resumed) != 6 {
+		t.Fatalf("%d chunks after MaxDuration was spent", len(resumed))
+	}
+
+	// After the window nothing runs either
+	clock.Set(day.Add(4 * time.Hour))
+	db.runMaintenance(ctx)
+	if len(resumed) != 6 {
+		t.Fatalf("%d chunks after the window", len(resumed))
+	}
+
+	// The next window finishes the task and it rests for the rest of it
+	clock.Set(day.Add(25 * time.Hour))
+	db.runMaintenance(ctx)
+	clock.Advance(time.Hour)
+	db.runMaintenance(ctx)
+	if len(resumed) != 10 || resumed[6] != 6 || resumed[9] != 9 {
+		t.Fatalf("second window resumed from %v", resumed)
+	}
+	if count := maintenanceTaskStatus(t, db, "docs", "count"); !count.Maintenance.Done {
+		t.Errorf("count not done: %+v", count.Maintenance)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A window running past midnight covers the early hours of the next day
+	m := newMaintenanceRunner(MaintenanceWindow{Daily: "23:00-02:00", Location: time.UTC})
+	if start, _, ok := m.occurrence(day.Add(25 * time.Hour)); !ok || !start.Equal(day.Add(23*time.Hour)) {
+		t.Errorf("01:00 on day 2 in window starting %v, %t", start, ok)
+	}
+	if _, _, ok := m.occurrence(day.Add(3 * time.Hour)); ok {
+		t.Error("03:00 in a 23:00-02:00 window")
+	}
+	if _, err := Open(t.TempDir(), WithMaintenanceWindow("1am-3am", 0)); !IsValidationFailed(err) {
+		t.Errorf("bad window spec = %v, want ErrValidationFailed", err)
+	}
+}
+
+func TestMaintenanceLatencyBackoff(t *testing.T) {
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: day.Add(90 * time.Minute)}
+	db := openMaintenanceDB(t, t.TempDir(), clock, WithMaintenanceLimits(5*time.Minute, 10*time.Millisecond))
+	defer db.Close()
+	busy, _ := db.Collection("busy")
+	quiet, _ := db.Collection("quiet")
+	var resumed []int
+	if err := quiet.RegisterMaintenance("count", countingTask(clock, 3, &resumed)); err != nil {
+		t.Fatalf("RegisterMaintenance failed: %v", err)
+	}
+
+	// Slow searches on any collection hold maintenance back
+	busy.latency.observe(50 * time.Millisecond)
+	if got := busy.Stats().SearchLatency; got != 50*time.Millisecond {
+		t.Errorf("SearchLatency = %v", got)
+	}
+	db.runMaintenance(context.Background())
+	if len(resumed) != 0 {
+		t.Fatalf("%d chunks ran while searches were slow", len(resumed))
+	}
+	if task := maintenanceTaskStatus(t, db, "busy", "optimize"); task.Maintenance.Backoffs != 1 {
+		t.Errorf("busy optimize backed off %d times", task.Maintenance.Backoffs)
+	}
+
+	// Once latency recovers, the next check runs everything
+	for i := 0; i < 50; i++ {
+		busy.latency.observe(time.Millisecond)
+	}
+	db.runMaintenance(context.Background())
+	if len(resumed) != 3 {
+		t.Fatalf("%d chunks after latency recovered, want 3", len(resumed))
+	}
+}
+
+func TestMaintenanceResume(t *testing.T) {
+	path := t.TempDir()
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: day.Add(time.Hour)}
+	open := func() (*DB, *Collection, *[]int) {
+		db := openMaintenanceDB(t, path, clock)
+		coll, err := db.Collection("docs")
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+		var resumed []int
+		if err := coll.RegisterMaintenance("count", countingTask(clock, 8, &resumed)); err != nil {
+			t.Fatalf("RegisterMaintenance failed: %v", err)
+		}
+		return db, coll, &resumed
+	}
+
+	db, _, resumed := open()
+	db.runMaintenance(context.Background())
+	if len(*resumed) != 6 {
+		t.Fatalf("%d chunks before the restart, want 6", len(*resumed))
+	}
+	db.Close()
+
+	// After a restart the next window resumes from the saved progress
+	clock.Set(day.Add(25 * time.Hour))
+	db, _, resumed = open()
+	db.runMaintenance(context.Background())
+	if got := fmt.Sprint(*resumed); got != "[6 7]" {
+		t.Fatalf("resumed from %s, want [6 7]", got)
+	}
+	db.Close()
+
+	// A task done for the window stays done across a restart within it
+	clock.Advance(10 * time.Minute)
+	db, _, resumed = open()
+	defer db.Close()
+	db.runMaintenance(context.Background())
+	if len(*resumed) != 0 {
+		t.Fatalf("finished task ran again: %v", *resumed)
+	}
+	if task := maintenanceTaskStatus(t, db, "docs", "count"); !task.Maintenance.Done {
+		t.Errorf("count not done after restart: %+v", task.Maintenance)
+	}
+}
+
+func TestMaintenanceChunkBudget(t *testing.T) {
+	const budget = 2 * time.Millisecond
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: day.Add(time.Hour)}
+	db := openMaintenanceDB(t, t.TempDir(), clock, WithMaintenanceLimits(budget, 0))
+	defer db.Close()
+	coll, _ := db.Collection("docs")
+	docs := make([]*Document, 3000)
+	for i := range docs {
+		docs[i] = &Document{ID: fmt.Sprint(i), Vector: []float32{float32(i % 97), float32(i % 89), float32(i % 83), float32(i % 79)}}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	// The optimize pass is split into chunks, and writers get the lock
+	// between them
+	writes := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 20 && err == nil; i++ {
+			err = coll.Insert(&Document{ID: fmt.Sprint("w", i), Vector: []float32{1, 2, 3, float32(i)}})
+		}
+		writes <- err
+	}()
+	db.runMaintenance(context.Background())
+	if err := <-writes; err != nil {
+		t.Fatalf("Insert during maintenance failed: %v", err)
+	}
+
+	task := maintenanceTaskStatus(t, db, "docs", "optimize")
+	if !task.Maintenance.Done || task.Runs < 2 {
+		t.Fatalf("optimize pass ran as %d chunks, done %t", task.Runs, task.Maintenance.Done)
+	}
+	// A chunk overruns its budget by at most one batch of Optimize
+	if longest := task.Maintenance.LongestChunk; longest > budget+50*time.Millisecond {
+		t.Errorf("longest chunk held the collection for %v, budget %v", longest, budget)
+	}
+}
@@ -0,0 +1,61 @@
+package vego
+
+import (
+	"fmt"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// OpenInMemory opens a database that never touches the filesystem, for
+// tests and ephemeral caches. Documents, indexes and mappings live only in
+// memory and are lost on Close; Save only reaps orphaned index nodes and
+// there is nothing to load. Every other operation behaves as on a database
+// opened with Open, and CollectionStats.InMemory reports the mode.
+//
+// Options that need files are rejected with ErrNotSupported: hnsw.TieredL0
+// graph storage (a memory-mapped file), read-only replicas and auto-refresh,
+// and checkpoint retention. Checkpoints and CollectionAt fail the same way.
+// Profile still measures saved index sizes in a temporary directory.
+func OpenInMemory(opts ...Option) (*DB, error) {
+	config := DefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	config.InMemory = true
+
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		config:      config,
+		collections: make(map[string]*Collection),
+		sched:       newScheduler(config.BackgroundWorkers),
+	}
+	db.startMaintenance()
+	return db, nil
+}
+
+// validateInMemory rejects the options of config that need files
+func validateInMemory(config *Config) error {
+	switch {
+	case config.GraphStorage != hnsw.InMemory:
+		return errNoFiles("memory-mapped graph storage")
+	case config.ReadOnly:
+		return errNoFiles("read-only replicas")
+	case config.AutoRefreshInterval > 0:
+		return errNoFiles("auto-refresh")
+	case config.CheckpointRetention > 0 || config.CheckpointMaxAge > 0:
+		return errNoFiles("checkpoints")
+	case config.WAL:
+		return errNoFiles("write-ahead log")
+	case config.AutoSaveInterval > 0 || config.AutoSaveThreshold > 0:
+		return errNoFiles("auto-save")
+	}
+	return nil
+}
+
+// errNoFiles is the error of a feature that needs files, used in memory
+func errNoFiles(feature string) error {
+	return fmt.Errorf("%w in memory: %s", ErrNotSupported, feature)
+}
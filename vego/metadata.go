@@ -0,0 +1,288 @@
+package vego
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Metadata is persisted as JSON, so a value reads back in the type JSON
+// decodes it to rather than the type it was written with: every number
+// becomes a float64, a []string becomes a []interface{} and a time.Time
+// becomes a string. The accessors below accept both forms, so code reading
+// metadata works the same before and after a document is saved and
+// reloaded. Each returns ok=false when the key is absent or its value cannot
+// be converted, and never panics.
+
+// GetString returns the string stored under key
+func (d *Document) GetString(key string) (string, bool) {
+	s, ok := d.Metadata[key].(string)
+	return s, ok
+}
+
+// GetInt returns the integer stored under key. Any integer type converts,
+// as do floats and json.Numbers without a fractional part that fit in an
+// int64.
+func (d *Document) GetInt(key string) (int64, bool) {
+	n, ok := toNumeric(d.Metadata[key])
+	if !ok || !n.isInt {
+		return 0, false
+	}
+	return n.i, true
+}
+
+// GetFloat returns the number stored under key as a float64. Any integer or
+// float type and json.Number convert.
+func (d *Document) GetFloat(key string) (float64, bool) {
+	n, ok := toNumeric(d.Metadata[key])
+	return n.f, ok
+}
+
+// GetBool returns the bool stored under key
+func (d *Document) GetBool(key string) (bool, bool) {
+	b, ok := d.Metadata[key].(bool)
+	return b, ok
+}
+
+// GetStringSlice returns a copy of the strings stored under key, either as a
+// []string or as a []interface{} holding only strings
+func (d *Document) GetStringSlice(key string) ([]string, bool) {
+	switch v := d.Metadata[key].(type) {
+	case []string:
+		return append([]string(nil), v...), true
+	case []interface{}:
+		out := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// GetTime returns the time stored under key: a time.Time, an RFC 3339
+// string or an integer number of seconds since the Unix epoch, which is
+// returned in UTC
+func (d *Document) GetTime(key string) (time.Time, bool) {
+	switch v := d.Metadata[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	if n, ok := toNumeric(d.Metadata[key]); ok && n.isInt {
+		return time.Unix(n.i, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// SetTyped stores value under key in the form metadata is kept in: integers
+// of any type as int64, floats as float64, json.Number as whichever of the
+// two it holds, time.Time as an RFC 3339 string in UTC and []string as a
+// []interface{}. Nested slices and maps are converted element by element.
+// Values JSON cannot persist, such as NaN, structs or channels, are rejected
+// with ErrValidationFailed and leave the document unchanged.
+func (d *Document) SetTyped(key string, value interface{}) error {
+	v, err := canonicalValue(value)
+	if err != nil {
+		return fmt.Errorf("%w: metadata %q: %v", ErrValidationFailed, key, err)
+	}
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]interface{})
+	}
+	d.Metadata[key] = v
+	return nil
+}
+
+// canonicalValue converts a metadata value for SetTyped
+func canonicalValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string:
+		return v, nil
+	case float32, float64, json.Number:
+		n, ok := toNumeric(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %v", v)
+		}
+		if math.IsNaN(n.f) || math.IsInf(n.f, 0) {
+			return nil, fmt.Errorf("%v cannot be stored", v)
+		}
+		if _, isNumber := v.(json.Number); isNumber && n.isInt {
+			return n.i, nil
+		}
+		return n.f, nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case []string:
+		out := make([]interface{}, len(v))
+		for i, s := range v {
+			out[i] = s
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			c, err := canonicalValue(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c, err := canonicalValue(e)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = c
+		}
+		return out, nil
+	}
+
+	if n, ok := toNumeric(value); ok {
+		if !n.isInt {
+			return nil, fmt.Errorf("%v overflows int64", value)
+		}
+		return n.i, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", value)
+}
+
+// numeric is a metadata number. isInt is set when it has no fractional part
+// and fits in an int64, in which case i holds it exactly; f always holds it,
+// possibly rounded.
+type numeric struct {
+	i     int64
+	f     float64
+	isInt bool
+}
+
+func intNumeric(i int64) numeric {
+	return numeric{i: i, f: float64(i), isInt: true}
+}
+
+func floatNumeric(f float64) numeric {
+	// -2^63 is an int64, 2^63 is not
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < -math.MinInt64 {
+		return numeric{i: int64(f), f: f, isInt: true}
+	}
+	return numeric{f: f}
+}
+
+// toNumeric converts any Go number or json.Number
+func toNumeric(v interface{}) (numeric, bool) {
+	switch n := v.(type) {
+	case int:
+		return intNumeric(int64(n)), true
+	case int8:
+		return intNumeric(int64(n)), true
+	case int16:
+		return intNumeric(int64(n)), true
+	case int32:
+		return intNumeric(int64(n)), true
+	case int64:
+		return intNumeric(n), true
+	case uint:
+		return uintNumeric(uint64(n)), true
+	case uint8:
+		return intNumeric(int64(n)), true
+	case uint16:
+		return intNumeric(int64(n)), true
+	case uint32:
+		return intNumeric(int64(n)), true
+	case uint64:
+		return uintNumeric(n), true
+	case float32:
+		return floatNumeric(float64(n)), true
+	case float64:
+		return floatNumeric(n), true
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return intNumeric(i), true
+		}
+		if f, err := n.Float64(); err == nil {
+			return floatNumeric(f), true
+		}
+	}
+	return numeric{}, false
+}
+
+func uintNumeric(u uint64) numeric {
+	if u > math.MaxInt64 {
+		return numeric{f: float64(u)}
+	}
+	return intNumeric(int64(u))
+}
+
+// compareNumeric returns -1, 0 or 1 as a is less than, equal to or greater
+// than b, comparing exactly when both are integers
+func compareNumeric(a, b numeric) int {
+	if a.isInt && b.isInt {
+		switch {
+		case a.i < b.i:
+			return -1
+		case a.i > b.i:
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case a.f < b.f:
+		return -1
+	case a.f > b.f:
+		return 1
+	}
+	return 0
+}
+
+// compareValues orders two metadata values with the coercion rules of the
+// accessors: numbers of any type compare by value and strings compare
+// lexicographically. ok is false for any other pair, including NaN.
+func compareValues(a, b interface{}) (cmp int, ok bool) {
+	if an, ok := toNumeric(a); ok {
+		bn, ok := toNumeric(b)
+		if !ok || math.IsNaN(an.f) || math.IsNaN(bn.f) {
+			return 0, false
+		}
+		return compareNumeric(an, bn), true
+	}
+	if as, ok := a.(string); ok {
+		bs, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case as < bs:
+			return -1, true
+		case as > bs:
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// valuesEqual reports whether two metadata values are equal, so that 2024
+// stored as an int matches 2024 read back from JSON as a float64. Values
+// that are not numbers or strings fall back to deep equality.
+func valuesEqual(a, b interface{}) bool {
+	if cmp, ok := compareValues(a, b); ok {
+		return cmp == 0
+	}
+	if _, ok := toNumeric(a); ok {
+		return false
+	}
+	if _, ok := a.(string); ok {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
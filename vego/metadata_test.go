@@ -0,0 +1,282 @@
+package vego
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// accessorResults calls every accessor on key and returns the values that
+// converted, keyed by accessor name
+func accessorResults(doc *Document, key string) map[string]interface{} {
+	out := make(map[string]interface{})
+	if v, ok := doc.GetString(key); ok {
+		out["string"] = v
+	}
+	if v, ok := doc.GetInt(key); ok {
+		out["int"] = v
+	}
+	if v, ok := doc.GetFloat(key); ok {
+		out["float"] = v
+	}
+	if v, ok := doc.GetBool(key); ok {
+		out["bool"] = v
+	}
+	if v, ok := doc.GetStringSlice(key); ok {
+		out["strings"] = v
+	}
+	if v, ok := doc.GetTime(key); ok {
+		out["time"] = v
+	}
+	return out
+}
+
+func TestMetadataAccessors(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  map[string]interface{}
+	}{
+		{"absent", nil, map[string]interface{}{}},
+		{"string", "hello", map[string]interface{}{"string": "hello"}},
+		{"RFC3339 string", "2024-03-01T12:30:00Z", map[string]interface{}{"string": "2024-03-01T12:30:00Z", "time": when}},
+		{"int", 2024, map[string]interface{}{"int": int64(2024), "float": 2024.0, "time": time.Unix(2024, 0).UTC()}},
+		{"int8", int8(-3), map[string]interface{}{"int": int64(-3), "float": -3.0, "time": time.Unix(-3, 0).UTC()}},
+		{"uint32", uint32(7), map[string]interface{}{"int": int64(7), "float": 7.0, "time": time.Unix(7, 0).UTC()}},
+		{"int64 epoch", when.Unix(), map[string]interface{}{"int": when.Unix(), "float": float64(when.Unix()), "time": when}},
+		{"uint64 overflow", uint64(math.MaxUint64), map[string]interface{}{"float": float64(math.MaxUint64)}},
+		{"integral float64", 2024.0, map[string]interface{}{"int": int64(2024), "float": 2024.0, "time": time.Unix(2024, 0).UTC()}},
+		{"fractional float64", 4.5, map[string]interface{}{"float": 4.5}},
+		{"float32", float32(0.5), map[string]interface{}{"float": 0.5}},
+		{"huge float64", 1e300, map[string]interface{}{"float": 1e300}},
+		{"NaN", math.NaN(), map[string]interface{}{}},
+		{"json.Number int", json.Number("42"), map[string]interface{}{"int": int64(42), "float": 42.0, "time": time.Unix(42, 0).UTC()}},
+		{"json.Number float", json.Number("1.25"), map[string]interface{}{"float": 1.25}},
+		{"json.Number invalid", json.Number("x"), map[string]interface{}{}},
+		{"bool", true, map[string]interface{}{"bool": true}},
+		{"time.Time", when, map[string]interface{}{"time": when}},
+		{"[]string", []string{"a", "b"}, map[string]interface{}{"strings": []string{"a", "b"}}},
+		{"[]interface{} of strings", []interface{}{"a", "b"}, map[string]interface{}{"strings": []string{"a", "b"}}},
+		{"mixed []interface{}", []interface{}{"a", 1.0}, map[string]interface{}{}},
+		{"map", map[string]interface{}{"k": "v"}, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &Document{Metadata: map[string]interface{}{}}
+			if tt.value != nil {
+				doc.Metadata["k"] = tt.value
+			}
+			got := accessorResults(doc, "k")
+			// NaN is a float but not a comparable one
+			if f, ok := got["float"].(float64); ok && math.IsNaN(f) {
+				delete(got, "float")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Nil metadata behaves like an absent key
+	if got := accessorResults(&Document{}, "k"); len(got) != 0 {
+		t.Errorf("nil metadata: got %v", got)
+	}
+
+	// GetStringSlice returns a copy
+	doc := &Document{Metadata: map[string]interface{}{"tags": []string{"a"}}}
+	tags, _ := doc.GetStringSlice("tags")
+	tags[0] = "changed"
+	if doc.Metadata["tags"].([]string)[0] != "a" {
+		t.Error("modifying the returned slice changed the document")
+	}
+}
+
+func TestSetTyped(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 30, 0, 5, time.FixedZone("X", 3600))
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"int", 7, int64(7)},
+		{"uint16", uint16(7), int64(7)},
+		{"int64", int64(-7), int64(-7)},
+		{"float32", float32(0.5), 0.5},
+		{"float64", 2.0, 2.0},
+		{"json.Number int", json.Number("12"), int64(12)},
+		{"json.Number float", json.Number("1.5"), 1.5},
+		{"string", "s", "s"},
+		{"bool", false, false},
+		{"nil", nil, nil},
+		{"time.Time", when, "2024-03-01T11:30:00.000000005Z"},
+		{"[]string", []string{"a", "b"}, []interface{}{"a", "b"}},
+		{"nested", map[string]interface{}{"n": 1, "l": []interface{}{int8(2), "x"}},
+			map[string]interface{}{"n": int64(1), "l": []interface{}{int64(2), "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &Document{}
+			if err := doc.SetTyped("k", tt.value); err != nil {
+				t.Fatalf("SetTyped failed: %v", err)
+			}
+			if got := doc.Metadata["k"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stored %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	for name, value := range map[string]interface{}{
+		"NaN":             math.NaN(),
+		"Inf":             math.Inf(1),
+		"uint64 overflow": uint64(math.MaxUint64),
+		"struct":          struct{}{},
+		"nested channel":  []interface{}{make(chan int)},
+		"invalid number":  json.Number("x"),
+	} {
+		doc := &Document{Metadata: map[string]interface{}{"k": "old"}}
+		if err := doc.SetTyped("k", value); !IsValidationFailed(err) {
+			t.Errorf("%s: expected ErrValidationFailed, got %v", name, err)
+		}
+		if doc.Metadata["k"] != "old" {
+			t.Errorf("%s: rejected value changed the document", name)
+		}
+	}
+}
+
+// TestMetadataJSONRoundTrip reads metadata back after a save and reopen,
+// where JSON has turned every number into a float64
+func TestMetadataJSONRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	when := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	db, err := Open(dir, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	doc := &Document{ID: "a", Vector: []float32{1, 2, 3, 4}}
+	for k, v := range map[string]interface{}{
+		"year":    2024,
+		"views":   json.Number("9007199254740993"), // 2^53+1
+		"rating":  4.5,
+		"draft":   true,
+		"tags":    []string{"go", "db"},
+		"created": when,
+		"epoch":   when.Unix(),
+	} {
+		if err := doc.SetTyped(k, v); err != nil {
+			t.Fatalf("SetTyped(%s) failed: %v", k, err)
+		}
+	}
+	doc.Metadata["raw"] = 2024 // set without SetTyped
+	if err := coll.Insert(doc); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	check := func(stage string, doc *Document) {
+		t.Helper()
+		if v, ok := doc.GetInt("year"); !ok || v != 2024 {
+			t.Errorf("%s: year = %v, %v", stage, v, ok)
+		}
+		if v, ok := doc.GetInt("raw"); !ok || v != 2024 {
+			t.Errorf("%s: raw = %v, %v", stage, v, ok)
+		}
+		if v, ok := doc.GetFloat("rating"); !ok || v != 4.5 {
+			t.Errorf("%s: rating = %v, %v", stage, v, ok)
+		}
+		if _, ok := doc.GetInt("rating"); ok {
+			t.Errorf("%s: a fractional rating converted to an int", stage)
+		}
+		if v, ok := doc.GetBool("draft"); !ok || !v {
+			t.Errorf("%s: draft = %v, %v", stage, v, ok)
+		}
+		if v, ok := doc.GetStringSlice("tags"); !ok || !reflect.DeepEqual(v, []string{"go", "db"}) {
+			t.Errorf("%s: tags = %v, %v", stage, v, ok)
+		}
+		for _, key := range []string{"created", "epoch"} {
+			if v, ok := doc.GetTime(key); !ok || !v.Equal(when) {
+				t.Errorf("%s: %s = %v, %v", stage, key, v, ok)
+			}
+		}
+		if _, ok := doc.GetFloat("views"); !ok {
+			t.Errorf("%s: views is not a number", stage)
+		}
+	}
+
+	stored, err := coll.Get("a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	check("buffered", stored)
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db, err = Open(dir, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	if coll, err = db.Collection("docs"); err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	reloaded, err := coll.Get("a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, isFloat := reloaded.Metadata["raw"].(float64); !isFloat {
+		t.Fatalf("expected JSON to reload raw as float64, got %T", reloaded.Metadata["raw"])
+	}
+	check("reloaded", reloaded)
+}
+
+func TestFilterNumericCoercion(t *testing.T) {
+	docs := map[string]*Document{
+		"int":     {Metadata: map[string]interface{}{"year": 2024}},
+		"int64":   {Metadata: map[string]interface{}{"year": int64(2024)}},
+		"float64": {Metadata: map[string]interface{}{"year": 2024.0}},
+		"number":  {Metadata: map[string]interface{}{"year": json.Number("2024")}},
+		"later":   {Metadata: map[string]interface{}{"year": 2025.0}},
+		"string":  {Metadata: map[string]interface{}{"year": "2024"}},
+	}
+
+	tests := []struct {
+		filter *MetadataFilter
+		want   []string
+	}{
+		{&MetadataFilter{Field: "year", Operator: "eq", Value: 2024}, []string{"float64", "int", "int64", "number"}},
+		{&MetadataFilter{Field: "year", Operator: "eq", Value: 2024.0}, []string{"float64", "int", "int64", "number"}},
+		{&MetadataFilter{Field: "year", Operator: "eq", Value: "2024"}, []string{"string"}},
+		{&MetadataFilter{Field: "year", Operator: "ne", Value: 2024}, []string{"later", "string"}},
+		{&MetadataFilter{Field: "year", Operator: "gt", Value: 2024}, []string{"later"}},
+		{&MetadataFilter{Field: "year", Operator: "gte", Value: int64(2024)}, []string{"float64", "int", "int64", "later", "number"}},
+		{&MetadataFilter{Field: "year", Operator: "lt", Value: 2024.5}, []string{"float64", "int", "int64", "number"}},
+		{&MetadataFilter{Field: "year", Operator: "lte", Value: float32(2024)}, []string{"float64", "int", "int64", "number"}},
+		{&MetadataFilter{Field: "year", Operator: "in", Value: []interface{}{2025, "2024"}}, []string{"later", "string"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, name := range []string{"float64", "int", "int64", "later", "number", "string"} {
+			if tt.filter.Match(docs[name]) {
+				got = append(got, name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %v: matched %v, want %v", tt.filter.Operator, tt.filter.Value, got, tt.want)
+		}
+	}
+
+	// Values that cannot be compared with == no longer panic
+	tagged := &Document{Metadata: map[string]interface{}{"tags": []string{"a"}}}
+	if !(&MetadataFilter{Field: "tags", Operator: "eq", Value: []string{"a"}}).Match(tagged) {
+		t.Error("eq on equal slices did not match")
+	}
+}
@@ -0,0 +1,713 @@
+package vego
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// IndexType is the kind of a metadata index, see CreateMetadataIndex
+type IndexType int
+
+const (
+	// IndexTypeHash answers eq and in filters
+	IndexTypeHash IndexType = iota + 1
+	// IndexTypeBTree keeps its values in order, so it also answers gt, gte,
+	// lt and lte filters
+	IndexTypeBTree
+)
+
+var indexTypeNames = map[IndexType]string{
+	IndexTypeHash:  "hash",
+	IndexTypeBTree: "btree",
+}
+
+func (t IndexType) String() string {
+	if name, ok := indexTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("IndexType(%d)", int(t))
+}
+
+// parseIndexType returns the IndexType named name, as String returns it
+func parseIndexType(name string) (IndexType, bool) {
+	for t, n := range indexTypeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	// A search only uses the indexes when they narrow it to at most one
+	// document in indexedFilterShare, or to exactFilterLimit documents in a
+	// small collection; collecting a wider set costs more than testing the
+	// filter on the nodes a traversal visits
+	indexedFilterShare = 10
+
+	// Up to this many candidates are ranked exactly rather than traversed:
+	// the traversal of a small allow-set visits most of the graph to find
+	// them
+	exactFilterLimit = 2048
+
+	// Documents indexed at a time by a backfill, with mu held
+	backfillBatch = 1024
+
+	// Keys in a leaf of a B-tree index
+	btreeLeafSize = 512
+)
+
+// MetadataIndexStats describes a metadata index, see
+// CollectionStats.MetadataIndexes
+type MetadataIndexStats struct {
+	Field string
+	Type  IndexType
+
+	// Documents in the collection when the index was created and how many
+	// of them the backfill has indexed; searches use the index once it is
+	// ready, after the last of them
+	Total   int
+	Indexed int
+	Ready   bool
+
+	Values int // Distinct indexed values
+}
+
+// keyKind orders the kinds of indexed values: bools sort before numbers and
+// numbers before strings
+type keyKind uint8
+
+const (
+	keyBool keyKind = iota
+	keyNumber
+	keyString
+)
+
+// indexKey is an indexed metadata value. Numbers are keyed by their float64
+// value, so integers beyond 2^53 that round alike share a key: an index
+// only narrows a search, whose candidates are still matched exactly.
+type indexKey struct {
+	kind keyKind
+	num  float64 // Numbers, and bools as 0 or 1
+	str  string
+}
+
+// toIndexKey returns the key of a metadata value. ok is false for the
+// values that are not indexed: NaN and anything but numbers, strings and
+// bools.
+func toIndexKey(v interface{}) (key indexKey, ok bool) {
+	switch v := v.(type) {
+	case string:
+		return indexKey{kind: keyString, str: v}, true
+	case bool:
+		if v {
+			return indexKey{kind: keyBool, num: 1}, true
+		}
+		return indexKey{kind: keyBool}, true
+	}
+	n, ok := toNumeric(v)
+	if !ok || math.IsNaN(n.f) {
+		return indexKey{}, false
+	}
+	return indexKey{kind: keyNumber, num: n.f}, true
+}
+
+// isNaN reports whether v is a NaN number, which no filter matches
+func isNaN(v interface{}) bool {
+	n, ok := toNumeric(v)
+	return ok && math.IsNaN(n.f)
+}
+
+func compareKeys(a, b indexKey) int {
+	switch {
+	case a.kind != b.kind:
+		return cmp.Compare(a.kind, b.kind)
+	case a.kind == keyString:
+		return strings.Compare(a.str, b.str)
+	}
+	return cmp.Compare(a.num, b.num)
+}
+
+// keyTree holds the keys of a B-tree index in order: a two-level B+tree
+// whose leaves are sorted runs of at most btreeLeafSize keys, found by a
+// binary search over their last keys. A full leaf splits in two and an
+// empty one is dropped. Its methods are no-ops on a nil tree, which hash
+// indexes have.
+type keyTree struct {
+	leaves [][]indexKey
+}
+
+// leaf returns the first leaf whose last key is not less than key,
+// len(t.leaves) if key is greater than every key
+func (t *keyTree) leaf(key indexKey) int {
+	return sort.Search(len(t.leaves), func(i int) bool {
+		leaf := t.leaves[i]
+		return compareKeys(leaf[len(leaf)-1], key) >= 0
+	})
+}
+
+// insert adds key, which must not be in the tree
+func (t *keyTree) insert(key indexKey) {
+	if t == nil {
+		return
+	}
+	i := t.leaf(key)
+	if i == len(t.leaves) {
+		if i == 0 {
+			t.leaves = append(t.leaves, []indexKey{key})
+			return
+		}
+		i-- // Greater than every key: append to the last leaf
+	}
+	leaf := t.leaves[i]
+	j, _ := slices.BinarySearchFunc(leaf, key, compareKeys)
+	leaf = slices.Insert(leaf, j, key)
+	if len(leaf) > btreeLeafSize {
+		half := len(leaf) / 2
+		right := append([]indexKey(nil), leaf[half:]...)
+		t.leaves[i] = leaf[:half:half]
+		t.leaves = slices.Insert(t.leaves, i+1, right)
+		return
+	}
+	t.leaves[i] = leaf
+}
+
+// remove deletes key from the tree
+func (t *keyTree) remove(key indexKey) {
+	if t == nil {
+		return
+	}
+	i := t.leaf(key)
+	if i == len(t.leaves) {
+		return
+	}
+	leaf := t.leaves[i]
+	j, found := slices.BinarySearchFunc(leaf, key, compareKeys)
+	if !found {
+		return
+	}
+	leaf = slices.Delete(leaf, j, j+1)
+	if len(leaf) == 0 {
+		t.leaves = slices.Delete(t.leaves, i, i+1)
+		return
+	}
+	t.leaves[i] = leaf
+}
+
+// ascend calls fn on the keys not less than from, in order, until it
+// returns false
+func (t *keyTree) ascend(from indexKey, fn func(indexKey) bool) {
+	first := t.leaf(from)
+	for i := first; i < len(t.leaves); i++ {
+		leaf := t.leaves[i]
+		if i == first {
+			j, _ := slices.BinarySearchFunc(leaf, from, compareKeys)
+			leaf = leaf[j:]
+		}
+		for _, key := range leaf {
+			if !fn(key) {
+				return
+			}
+		}
+	}
+}
+
+// metadataIndex maps the values of one metadata field to the IDs of the
+// documents having them, see CreateMetadataIndex. It is guarded by the
+// collection's mu.
+type metadataIndex struct {
+	field    string
+	typ      IndexType
+	postings map[indexKey]map[string]struct{} // Key -> IDs of the documents with it
+	docs     map[string][]indexKey            // Document ID -> its keys
+	tree     *keyTree                         // Keys in order, IndexTypeBTree only
+
+	// Backfill progress, see MetadataIndexStats
+	total   int
+	indexed int
+	ready   bool
+}
+
+func newMetadataIndex(field string, typ IndexType) *metadataIndex {
+	x := &metadataIndex{
+		field:    field,
+		typ:      typ,
+		postings: make(map[indexKey]map[string]struct{}),
+		docs:     make(map[string][]indexKey),
+	}
+	if typ == IndexTypeBTree {
+		x.tree = &keyTree{}
+	}
+	return x
+}
+
+// add indexes the field of a document's metadata, replacing what was
+// indexed for its ID. Each element of a slice value is indexed, as in
+// filters on tags; values toIndexKey rejects are not.
+func (x *metadataIndex) add(id string, metadata map[string]interface{}) {
+	x.remove(id)
+
+	val, ok := metadata[x.field]
+	if !ok {
+		return
+	}
+	vals, isSlice := sliceValues(val)
+	if !isSlice {
+		vals = []interface{}{val}
+	}
+	var keys []indexKey
+	for _, v := range vals {
+		key, ok := toIndexKey(v)
+		if !ok || slices.Contains(keys, key) {
+			continue
+		}
+		docs, ok := x.postings[key]
+		if !ok {
+			docs = make(map[string]struct{})
+			x.postings[key] = docs
+			x.tree.insert(key)
+		}
+		docs[id] = struct{}{}
+		keys = append(keys, key)
+	}
+	if len(keys) > 0 {
+		x.docs[id] = keys
+	}
+}
+
+// remove drops a document from the index
+func (x *metadataIndex) remove(id string) {
+	for _, key := range x.docs[id] {
+		docs := x.postings[key]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(x.postings, key)
+			x.tree.remove(key)
+		}
+	}
+	delete(x.docs, id)
+}
+
+// match returns the IDs of the documents a MetadataFilter on the index's
+// field with op and value may match: every document it matches and,
+// where keys are shared or bounds inclusive, possibly others. ok is false
+// if the index cannot answer the filter or it may match more than limit
+// documents.
+func (x *metadataIndex) match(op string, value interface{}, limit int) (ids map[string]struct{}, ok bool) {
+	ids = make(map[string]struct{})
+	collect := func(key indexKey) bool {
+		for id := range x.postings[key] {
+			ids[id] = struct{}{}
+		}
+		return len(ids) <= limit
+	}
+
+	switch op {
+	case "eq":
+		key, ok := toIndexKey(value)
+		if !ok {
+			return ids, isNaN(value)
+		}
+		return ids, collect(key)
+
+	case "in":
+		vals, ok := sliceValues(value)
+		if !ok {
+			return ids, true // Matches nothing
+		}
+		for _, v := range vals {
+			key, ok := toIndexKey(v)
+			if !ok {
+				if isNaN(v) {
+					continue
+				}
+				return nil, false
+			}
+			if !collect(key) {
+				return nil, false
+			}
+		}
+		return ids, true
+
+	case "gt", "gte", "lt", "lte":
+		if x.tree == nil {
+			return nil, false
+		}
+		key, ok := toIndexKey(value)
+		if !ok {
+			return ids, isNaN(value)
+		}
+		if key.kind == keyBool {
+			return ids, true // Bools are not ordered
+		}
+
+		// Bounds are inclusive: numbers sharing a key may compare either way
+		from := indexKey{kind: key.kind, num: math.Inf(-1)}
+		below := op == "lt" || op == "lte"
+		if !below {
+			from = key
+		}
+		ok = true
+		x.tree.ascend(from, func(k indexKey) bool {
+			if k.kind != key.kind || below && compareKeys(k, key) > 0 {
+				return false
+			}
+			ok = collect(k)
+			return ok
+		})
+		if !ok {
+			return nil, false
+		}
+		return ids, true
+	}
+	return nil, false
+}
+
+// stats returns the MetadataIndexStats of the index
+func (x *metadataIndex) stats() MetadataIndexStats {
+	return MetadataIndexStats{
+		Field:   x.field,
+		Type:    x.typ,
+		Total:   x.total,
+		Indexed: x.indexed,
+		Ready:   x.ready,
+		Values:  len(x.postings),
+	}
+}
+
+// metadataIndexes are the metadata indexes of a collection by field. It is
+// guarded by the collection's mu; a nil map has no indexes, so collections
+// without any call its methods unconditionally.
+type metadataIndexes map[string]*metadataIndex
+
+// metadataIndexDef is the persisted form of a metadata index, saved with
+// the mappings. Only the definition is saved: the entries are rebuilt from
+// the stored metadata, which is in memory, when the mappings are loaded.
+type metadataIndexDef struct {
+	Field string `json:"field"`
+	Type  string `json:"type"`
+}
+
+// add indexes doc in every index, replacing what was indexed for its ID
+func (m metadataIndexes) add(doc *Document) {
+	for _, x := range m {
+		x.add(doc.ID, doc.Metadata)
+	}
+}
+
+// remove drops a document from every index
+func (m metadataIndexes) remove(id string) {
+	for _, x := range m {
+		x.remove(id)
+	}
+}
+
+// build replaces the entries of every index with docs and marks it ready
+func (m metadataIndexes) build(docs []*Document) {
+	for field, x := range m {
+		x = newMetadataIndex(field, x.typ)
+		for _, doc := range docs {
+			x.add(doc.ID, doc.Metadata)
+		}
+		x.total, x.indexed, x.ready = len(docs), len(docs), true
+		m[field] = x
+	}
+}
+
+// fresh returns empty, ready indexes of the same definitions, for a copy
+// of the collection whose load builds them
+func (m metadataIndexes) fresh() metadataIndexes {
+	if len(m) == 0 {
+		return nil
+	}
+	fresh := make(metadataIndexes, len(m))
+	for field, x := range m {
+		fresh[field] = newMetadataIndex(field, x.typ)
+		fresh[field].ready = true
+	}
+	return fresh
+}
+
+// defs returns the definitions of the indexes, by field
+func (m metadataIndexes) defs() []metadataIndexDef {
+	defs := make([]metadataIndexDef, 0, len(m))
+	for field, x := range m {
+		defs = append(defs, metadataIndexDef{Field: field, Type: x.typ.String()})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Field < defs[j].Field })
+	return defs
+}
+
+// stats returns the MetadataIndexStats of the indexes, by field
+func (m metadataIndexes) stats() []MetadataIndexStats {
+	if len(m) == 0 {
+		return nil
+	}
+	stats := make([]MetadataIndexStats, 0, len(m))
+	for _, x := range m {
+		stats = append(stats, x.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Field < stats[j].Field })
+	return stats
+}
+
+// candidates returns the IDs of the documents filter may match as the
+// ready indexes find them, every document it matches among them. An
+// AndFilter uses the conditions the indexes answer and intersects them, an
+// OrFilter is answered only if each of its branches is. ok is false if the
+// indexes cannot answer filter or narrow it to limit documents.
+func (m metadataIndexes) candidates(filter Filter, limit int) (ids map[string]struct{}, ok bool) {
+	if len(m) == 0 {
+		return nil, false
+	}
+
+	switch f := filter.(type) {
+	case *MetadataFilter:
+		x, ok := m[f.Field]
+		if !ok || !x.ready {
+			return nil, false
+		}
+		return x.match(f.Operator, f.Value, limit)
+
+	case *AndFilter:
+		for _, child := range f.Filters {
+			found, ok := m.candidates(child, limit)
+			if !ok {
+				continue
+			}
+			if ids == nil {
+				ids = found
+				continue
+			}
+			for id := range ids {
+				if _, ok := found[id]; !ok {
+					delete(ids, id)
+				}
+			}
+		}
+		return ids, ids != nil
+
+	case *OrFilter:
+		if len(f.Filters) == 0 {
+			return nil, false
+		}
+		ids = make(map[string]struct{})
+		for _, child := range f.Filters {
+			found, ok := m.candidates(child, limit)
+			if !ok {
+				return nil, false
+			}
+			for id := range found {
+				ids[id] = struct{}{}
+			}
+			if len(ids) > limit {
+				return nil, false
+			}
+		}
+		return ids, true
+	}
+	return nil, false
+}
+
+// CreateMetadataIndex indexes the values of a metadata field, so that
+// SearchWithFilter searches only the documents a filter on it may match
+// instead of testing the filter on every node the traversal visits, which
+// for a selective filter is most of them. A hash index answers eq and in
+// filters, a B-tree index also gt, gte, lt and lte; an AndFilter is
+// narrowed by any condition an index answers, an OrFilter only when every
+// branch is. Values are indexed as MetadataFilter compares them: numbers by
+// value whatever their type, strings and bools, and each element of a
+// slice.
+//
+// Inserts, updates and deletes keep the index up to date. The documents
+// already in the collection are indexed in the background, and searches
+// use the index once that completes; Stats reports the progress. Creating
+// an index that exists is a no-op, and a field has one index at most. The
+// index is saved with the collection and rebuilt when it is opened.
+// Sharded collections are not supported.
+func (c *Collection) CreateMetadataIndex(field string, typ IndexType) error {
+	const op = "CreateMetadataIndex"
+	if field == "" || indexTypeNames[typ] == "" {
+		return wrapError(op, c.name, "", fmt.Errorf("%w: %v index on field %q", ErrValidationFailed, typ, field))
+	}
+	ctx, done, err := c.beginWrite(context.Background(), op)
+	if err != nil {
+		return err
+	}
+
+	c.lockWrites()
+	if x, ok := c.metaIndexes[field]; ok {
+		c.unlockWrites()
+		done()
+		if x.typ != typ {
+			return wrapError(op, c.name, "", fmt.Errorf("%w: field %q has a %v index", ErrValidationFailed, field, x.typ))
+		}
+		return nil
+	}
+	ids := make([]string, 0, len(c.docToNode))
+	for id := range c.docToNode {
+		ids = append(ids, id)
+	}
+	x := newMetadataIndex(field, typ)
+	x.total = len(ids)
+	x.ready = len(ids) == 0
+	if c.metaIndexes == nil {
+		c.metaIndexes = make(metadataIndexes)
+	}
+	c.metaIndexes[field] = x
+	c.unlockWrites()
+
+	if x.ready {
+		done()
+		return nil
+	}
+	go func() {
+		defer done()
+		c.backfill(ctx, x, ids)
+	}()
+	return nil
+}
+
+// backfill indexes the documents of ids still in the collection in x,
+// backfillBatch at a time, and marks x ready. It stops early, leaving x not
+// ready, if the collection is closing or x was dropped.
+func (c *Collection) backfill(ctx context.Context, x *metadataIndex, ids []string) {
+	for start := 0; start < len(ids); start += backfillBatch {
+		c.lifeMu.Lock()
+		closing := c.closing
+		c.lifeMu.Unlock()
+		if closing || ctx.Err() != nil {
+			return
+		}
+
+		batch := ids[start:min(start+backfillBatch, len(ids))]
+		c.mu.Lock()
+		if c.metaIndexes[x.field] != x {
+			c.mu.Unlock()
+			return
+		}
+		// Documents deleted since are missing, those updated have their
+		// current metadata
+		docs, _, err := c.storage.getBatch(batch, false, true)
+		if err != nil {
+			c.mu.Unlock()
+			log.Printf("Warning: backfill of metadata index %s of collection %s failed: %v", x.field, c.name, err)
+			return
+		}
+		for id, doc := range docs {
+			x.add(id, doc.Metadata)
+		}
+		x.indexed += len(batch)
+		x.ready = x.indexed == x.total
+		c.mu.Unlock()
+	}
+}
+
+// DropMetadataIndex removes the metadata index of field, stopping its
+// backfill if it is still running
+func (c *Collection) DropMetadataIndex(field string) error {
+	const op = "DropMetadataIndex"
+	_, done, err := c.beginWrite(context.Background(), op)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	c.lockWrites()
+	defer c.unlockWrites()
+	if _, ok := c.metaIndexes[field]; !ok {
+		return wrapError(op, c.name, "", fmt.Errorf("%w: no index on field %q", ErrValidationFailed, field))
+	}
+	delete(c.metaIndexes, field)
+	return nil
+}
+
+// rebuildMetadataIndexes rebuilds the metadata indexes from the stored
+// metadata of the mapped documents
+func (c *Collection) rebuildMetadataIndexes() error {
+	if len(c.metaIndexes) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(c.docToNode))
+	for id := range c.docToNode {
+		ids = append(ids, id)
+	}
+	docs, _, err := c.storage.getBatch(ids, false, true)
+	if err != nil {
+		return err
+	}
+	all := make([]*Document, 0, len(docs))
+	for _, doc := range docs {
+		all = append(all, doc)
+	}
+	c.metaIndexes.build(all)
+	return nil
+}
+
+// filteredHits returns the k nearest nodes matching filter. When the ready
+// metadata indexes narrow filter down to exactFilterLimit documents, their
+// nodes are ranked exactly without a traversal; when they narrow it less,
+// the traversal admits only their nodes; without them it tests filter on
+// every node it visits. c.mu must be held for reading.
+func (c *Collection) filteredHits(query []float32, k int, filter Filter, options *SearchOptions) ([]hnsw.SearchResult, error) {
+	allow := c.allowFilter(filter)
+	params := hnsw.SearchParams{
+		EfUpperLayers: options.EFUpperLayers,
+		EfBase:        options.EF,
+		Allow:         allow,
+	}
+	ids, ok := c.metaIndexes.candidates(filter, max(len(c.docToNode)/indexedFilterShare, exactFilterLimit))
+	if !ok {
+		return c.index.SearchWithParams(query, k, params)
+	}
+
+	// The candidates are a superset, so each is still matched
+	nodes := make(map[int]struct{}, len(ids))
+	for id := range ids {
+		if nodeID, ok := c.docToNode[id]; ok {
+			nodes[nodeID] = struct{}{}
+		}
+	}
+	if len(nodes) > exactFilterLimit {
+		params.Allow = func(nodeID int) bool {
+			_, ok := nodes[nodeID]
+			return ok && allow(nodeID)
+		}
+		return c.index.SearchWithParams(query, k, params)
+	}
+
+	matched := make([]int, 0, len(nodes))
+	for nodeID := range nodes {
+		if allow(nodeID) {
+			matched = append(matched, nodeID)
+		}
+	}
+	distances, err := c.index.DistancesTo(query, matched)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]hnsw.SearchResult, 0, len(matched))
+	for i, nodeID := range matched {
+		if !math.IsNaN(float64(distances[i])) {
+			hits = append(hits, hnsw.SearchResult{ID: nodeID, Distance: distances[i]})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Distance != hits[j].Distance {
+			return hits[i].Distance < hits[j].Distance
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, nil
+}
@@ -0,0 +1,399 @@
+package vego
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// metaIndexTestDocs returns n documents with random vectors, one of 200
+// user IDs and a distinct score
+func metaIndexTestDocs(n int, seed int64) []*Document {
+	rng := rand.New(rand.NewSource(seed))
+	docs := make([]*Document, n)
+	for i := range docs {
+		vector := make([]float32, 8)
+		for d := range vector {
+			vector[d] = rng.Float32()
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc-%05d", i), Vector: vector, Metadata: map[string]interface{}{
+			"user_id": fmt.Sprintf("user-%d", i%200),
+			"score":   i,
+		}}
+	}
+	return docs
+}
+
+// openMetaIndexTest returns an in-memory collection of 8-dimensional
+// vectors compared by L2 distance, as nearestMatching ranks them
+func openMetaIndexTest(t *testing.T) *Collection {
+	t.Helper()
+	db, err := OpenInMemory(WithDimension(8), WithDistanceFunc(hnsw.L2Distance), WithM(8), WithEfConstruction(64))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	return coll
+}
+
+// nearestMatching returns the IDs of the k documents of docs matching
+// filter nearest to query, by brute force
+func nearestMatching(docs map[string]*Document, query []float32, k int, filter Filter) []string {
+	var ids []string
+	for id, doc := range docs {
+		if filter.Match(doc) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		di, dj := hnsw.L2Distance(query, docs[ids[i]].Vector), hnsw.L2Distance(query, docs[ids[j]].Vector)
+		if di != dj {
+			return di < dj
+		}
+		return ids[i] < ids[j]
+	})
+	return ids[:min(k, len(ids))]
+}
+
+// waitIndexReady waits for the backfill of the index on field and returns
+// its stats
+func waitIndexReady(t *testing.T, coll *Collection, field string) MetadataIndexStats {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		for _, s := range coll.Stats().MetadataIndexes {
+			if s.Field == field && s.Ready {
+				return s
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Index on %s not ready: %+v", field, coll.Stats().MetadataIndexes)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// checkIndexedSearch checks that filter is answered by the indexes and
+// that SearchWithFilter returns the exact nearest matches
+func checkIndexedSearch(t *testing.T, coll *Collection, docs map[string]*Document, query []float32, k int, filter Filter) {
+	t.Helper()
+	coll.mu.RLock()
+	_, ok := coll.metaIndexes.candidates(filter, exactFilterLimit)
+	coll.mu.RUnlock()
+	if !ok {
+		t.Fatalf("Indexes do not answer %+v", filter)
+	}
+	results, err := coll.SearchWithFilter(query, k, filter)
+	if err != nil {
+		t.Fatalf("SearchWithFilter failed: %v", err)
+	}
+	got := make([]string, len(results))
+	for i, r := range results {
+		got[i] = r.Document.ID
+	}
+	if want := nearestMatching(docs, query, k, filter); !slices.Equal(got, want) {
+		t.Errorf("Filter %+v: got %v, want %v", filter, got, want)
+	}
+}
+
+func TestMetadataIndex_Hash(t *testing.T) {
+	coll := openMetaIndexTest(t)
+	docs := metaIndexTestDocs(5000, 1)
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	byID := make(map[string]*Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+
+	// The documents already inserted are backfilled
+	if err := coll.CreateMetadataIndex("user_id", IndexTypeHash); err != nil {
+		t.Fatalf("CreateMetadataIndex failed: %v", err)
+	}
+	stats := waitIndexReady(t, coll, "user_id")
+	if stats.Type != IndexTypeHash || stats.Total != 5000 || stats.Indexed != 5000 || stats.Values != 200 {
+		t.Errorf("Stats = %+v", stats)
+	}
+	if err := coll.CreateMetadataIndex("user_id", IndexTypeHash); err != nil {
+		t.Errorf("Creating the index again: %v", err)
+	}
+	if err := coll.CreateMetadataIndex("user_id", IndexTypeBTree); err == nil {
+		t.Error("Created a second index on user_id")
+	}
+
+	query := docs[42].Vector
+	checkIndexedSearch(t, coll, byID, query, 10, &MetadataFilter{Field: "user_id", Operator: "eq", Value: "user-7"})
+	checkIndexedSearch(t, coll, byID, query, 10, &MetadataFilter{Field: "user_id", Operator: "in", Value: []string{"user-3", "user-150"}})
+	checkIndexedSearch(t, coll, byID, query, 5, &AndFilter{Filters: []Filter{
+		&MetadataFilter{Field: "user_id", Operator: "eq", Value: "user-7"},
+		&MetadataFilter{Field: "score", Operator: "gte", Value: 2500},
+	}})
+	checkIndexedSearch(t, coll, byID, query, 10, &MetadataFilter{Field: "user_id", Operator: "eq", Value: "nobody"})
+
+	// A hash index does not answer ranges
+	coll.mu.RLock()
+	_, ok := coll.metaIndexes.candidates(&MetadataFilter{Field: "user_id", Operator: "gte", Value: "user-7"}, exactFilterLimit)
+	coll.mu.RUnlock()
+	if ok {
+		t.Error("Hash index answered a range")
+	}
+
+	if err := coll.DropMetadataIndex("user_id"); err != nil {
+		t.Fatalf("DropMetadataIndex failed: %v", err)
+	}
+	if n := len(coll.Stats().MetadataIndexes); n != 0 {
+		t.Errorf("%d indexes after the drop", n)
+	}
+}
+
+func TestMetadataIndex_BTreeRange(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a 30k document index")
+	}
+	coll := openMetaIndexTest(t)
+
+	// Created empty, the index is ready at once and kept by the inserts
+	if err := coll.CreateMetadataIndex("score", IndexTypeBTree); err != nil {
+		t.Fatalf("CreateMetadataIndex failed: %v", err)
+	}
+	docs := metaIndexTestDocs(30000, 2)
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	byID := make(map[string]*Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	stats := coll.Stats().MetadataIndexes
+	if len(stats) != 1 || !stats[0].Ready || stats[0].Values != 30000 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+
+	query := docs[1234].Vector
+	checkIndexedSearch(t, coll, byID, query, 10, &AndFilter{Filters: []Filter{
+		&MetadataFilter{Field: "score", Operator: "gte", Value: 1000},
+		&MetadataFilter{Field: "score", Operator: "lt", Value: 1300},
+	}})
+	checkIndexedSearch(t, coll, byID, query, 10, &MetadataFilter{Field: "score", Operator: "gt", Value: 29000.5})
+	checkIndexedSearch(t, coll, byID, query, 10, &MetadataFilter{Field: "score", Operator: "lte", Value: int64(40)})
+	checkIndexedSearch(t, coll, byID, query, 10, &OrFilter{Filters: []Filter{
+		&MetadataFilter{Field: "score", Operator: "lt", Value: 100},
+		&MetadataFilter{Field: "score", Operator: "in", Value: []interface{}{20000, 25000.0}},
+	}})
+
+	// Wider ranges admit the found nodes to the traversal
+	filter := &MetadataFilter{Field: "score", Operator: "lt", Value: 2500}
+	coll.mu.RLock()
+	ids, ok := coll.metaIndexes.candidates(filter, len(docs)/indexedFilterShare)
+	coll.mu.RUnlock()
+	if !ok || len(ids) <= exactFilterLimit {
+		t.Fatalf("Indexes found %d candidates (%v)", len(ids), ok)
+	}
+	results, err := coll.SearchWithFilter(query, 10, filter)
+	if err != nil {
+		t.Fatalf("SearchWithFilter failed: %v", err)
+	}
+	want := nearestMatching(byID, query, 10, filter)
+	found := 0
+	for _, r := range results {
+		if score, _ := r.Document.GetInt("score"); score >= 2500 {
+			t.Errorf("Result %s has score %d", r.Document.ID, score)
+		}
+		if slices.Contains(want, r.Document.ID) {
+			found++
+		}
+	}
+	if len(results) != 10 || found < 8 {
+		t.Errorf("%d results, %d of the 10 nearest", len(results), found)
+	}
+}
+
+func TestMetadataIndex_DeleteAndUpdate(t *testing.T) {
+	coll := openMetaIndexTest(t)
+	docs := metaIndexTestDocs(2000, 3)
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.CreateMetadataIndex("user_id", IndexTypeHash); err != nil {
+		t.Fatalf("CreateMetadataIndex failed: %v", err)
+	}
+	if err := coll.CreateMetadataIndex("score", IndexTypeBTree); err != nil {
+		t.Fatalf("CreateMetadataIndex failed: %v", err)
+	}
+
+	byID := make(map[string]*Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	// While the backfill runs, delete half the documents of user-7 and
+	// move others to it
+	for i := 7; i < 2000; i += 400 {
+		id := docs[i].ID
+		if err := coll.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		delete(byID, id)
+	}
+	for i := 8; i < 2000; i += 500 {
+		doc := &Document{ID: docs[i].ID, Vector: docs[i+1].Vector, Metadata: map[string]interface{}{
+			"user_id": "user-7",
+			"score":   []interface{}{-5, 5000},
+		}}
+		if err := coll.Update(doc); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		byID[doc.ID] = doc
+	}
+	waitIndexReady(t, coll, "user_id")
+	waitIndexReady(t, coll, "score")
+
+	query := docs[0].Vector
+	eq := &MetadataFilter{Field: "user_id", Operator: "eq", Value: "user-7"}
+	checkIndexedSearch(t, coll, byID, query, 20, eq)
+	checkIndexedSearch(t, coll, byID, query, 20, &MetadataFilter{Field: "score", Operator: "lt", Value: 50})
+	checkIndexedSearch(t, coll, byID, query, 20, &MetadataFilter{Field: "score", Operator: "in", Value: []int{5000}})
+
+	// The index holds exactly the remaining documents of user-7
+	coll.mu.RLock()
+	ids, _ := coll.metaIndexes.candidates(eq, exactFilterLimit)
+	coll.mu.RUnlock()
+	want := 0
+	for id, doc := range byID {
+		if eq.Match(doc) {
+			want++
+			if _, ok := ids[id]; !ok {
+				t.Errorf("%s is not indexed", id)
+			}
+		}
+	}
+	if len(ids) != want {
+		t.Errorf("Index has %d documents of user-7, want %d", len(ids), want)
+	}
+
+	// Deleting every document empties the indexes
+	for id := range byID {
+		if err := coll.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	for _, s := range coll.Stats().MetadataIndexes {
+		if s.Values != 0 {
+			t.Errorf("Index on %s keeps %d values", s.Field, s.Values)
+		}
+	}
+	coll.mu.RLock()
+	leaves := len(coll.metaIndexes["score"].tree.leaves)
+	coll.mu.RUnlock()
+	if leaves != 0 {
+		t.Errorf("Empty B-tree has %d leaves", leaves)
+	}
+}
+
+func TestMetadataIndex_Persisted(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(8), WithDistanceFunc(hnsw.L2Distance))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	docs := metaIndexTestDocs(1000, 4)
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := coll.CreateMetadataIndex("score", IndexTypeBTree); err != nil {
+		t.Fatalf("CreateMetadataIndex failed: %v", err)
+	}
+	waitIndexReady(t, coll, "score")
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(path, WithDimension(8), WithDistanceFunc(hnsw.L2Distance))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	stats := coll.Stats().MetadataIndexes
+	if len(stats) != 1 || stats[0].Type != IndexTypeBTree || !stats[0].Ready || stats[0].Values != 1000 {
+		t.Fatalf("Stats after reopen = %+v", stats)
+	}
+	byID := make(map[string]*Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	checkIndexedSearch(t, coll, byID, docs[5].Vector, 10, &MetadataFilter{Field: "score", Operator: "gte", Value: 900})
+}
+
+func TestKeyTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	tree := &keyTree{}
+	keys := make(map[indexKey]bool)
+	for i := 0; i < 5000; i++ {
+		key := indexKey{kind: keyNumber, num: float64(rng.Intn(20000))}
+		if i%3 == 0 {
+			key = indexKey{kind: keyString, str: fmt.Sprintf("k%d", rng.Intn(20000))}
+		}
+		if !keys[key] {
+			keys[key] = true
+			tree.insert(key)
+		}
+	}
+	for key := range keys {
+		if rng.Intn(2) == 0 {
+			delete(keys, key)
+			tree.remove(key)
+		}
+	}
+
+	want := make([]indexKey, 0, len(keys))
+	for key := range keys {
+		want = append(want, key)
+	}
+	slices.SortFunc(want, compareKeys)
+	var got []indexKey
+	tree.ascend(indexKey{kind: keyBool}, func(key indexKey) bool {
+		got = append(got, key)
+		return true
+	})
+	if !slices.Equal(got, want) {
+		t.Fatalf("Tree holds %d keys, want %d in order", len(got), len(want))
+	}
+	for _, leaf := range tree.leaves {
+		if len(leaf) == 0 || len(leaf) > btreeLeafSize {
+			t.Errorf("Leaf of %d keys", len(leaf))
+		}
+	}
+
+	// Ascending from a key starts at the first not less than it
+	from := want[len(want)/2]
+	from.num += 0.5
+	got = got[:0]
+	tree.ascend(from, func(key indexKey) bool {
+		got = append(got, key)
+		return len(got) < 3
+	})
+	i := sort.Search(len(want), func(i int) bool { return compareKeys(want[i], from) >= 0 })
+	if !slices.Equal(got, want[i:i+3]) {
+		t.Errorf("Ascend from %v: %v, want %v", from, got, want[i:i+3])
+	}
+}
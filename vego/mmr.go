@@ -0,0 +1,140 @@
+package vego
+
+import (
+	"fmt"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// defaultMMRCandidates is how many times k candidates MMR re-ranks when
+// MMR.FetchK is 0
+const defaultMMRCandidates = 4
+
+// MMR re-ranks search results by maximal marginal relevance, see WithMMR
+type MMR struct {
+	Lambda float64 // Weight of relevance against diversity, from 0 to 1
+	FetchK int     // Candidates re-ranked (0 = 4k)
+}
+
+// WithMMR diversifies the results of a search: the fetchK nearest
+// documents are fetched and the k results picked from them one at a time,
+// each maximising
+//
+//	lambda × similarity(query, doc) - (1-lambda) × max similarity(doc, picked)
+//
+// where similarity is 1/(1+distance), or 1-distance for the negative
+// distances of inner product, with distances taken by the collection's
+// distance function. Lambda 1 keeps the nearest-first order of the
+// candidates; lower values trade relevance for results that are less alike.
+// fetchK 0 fetches 4k candidates. Distance keeps the distance to the query.
+//
+// SearchContext applies it; the other searches ignore it. It cannot be
+// combined with WithBoost.
+func WithMMR(lambda float64, fetchK int) SearchOption {
+	return func(o *SearchOptions) {
+		o.MMR = &MMR{Lambda: lambda, FetchK: fetchK}
+	}
+}
+
+// validate checks the parameters of m
+func (m *MMR) validate() error {
+	if !(m.Lambda >= 0 && m.Lambda <= 1) {
+		return fmt.Errorf("%w: MMR lambda %v is not between 0 and 1", ErrValidationFailed, m.Lambda)
+	}
+	if m.FetchK < 0 {
+		return fmt.Errorf("%w: MMR fetchK %d is negative", ErrValidationFailed, m.FetchK)
+	}
+	return nil
+}
+
+// checkMMR validates the MMR of options, if any
+func (c *Collection) checkMMR(op string, options *SearchOptions) error {
+	if options.MMR == nil {
+		return nil
+	}
+	if options.Boost != nil {
+		return wrapError(op, c.name, "", fmt.Errorf("%w: WithMMR cannot be combined with WithBoost", ErrValidationFailed))
+	}
+	if err := options.MMR.validate(); err != nil {
+		return wrapError(op, c.name, "", err)
+	}
+	return nil
+}
+
+// mmrCandidates returns how many hits a search for k fetches when
+// diversifying, never fewer than k, and the options to fetch them with:
+// MMR compares the candidates' vectors, so they are always loaded
+func mmrCandidates(k int, options *SearchOptions) (int, *SearchOptions) {
+	fetchK := options.MMR.FetchK
+	if fetchK == 0 {
+		fetchK = k * defaultMMRCandidates
+	}
+	withVectors := *options
+	withVectors.Vectors = true
+	return max(fetchK, k), &withVectors
+}
+
+// diversify picks k of results by options.MMR, in the order picked, and
+// drops their vectors unless options asks for them. Without MMR it returns
+// results unchanged.
+//
+// Each pair of candidates is compared at most once: when a result is
+// picked, the distance from it to every remaining candidate is folded into
+// that candidate's running maximum similarity to the picked results, so a
+// search costs at most k×fetchK distance computations.
+func diversify(results []SearchResult, k int, options *SearchOptions, distance hnsw.DistanceFunc) []SearchResult {
+	m := options.MMR
+	if m == nil {
+		return results
+	}
+	if distance == nil {
+		distance = hnsw.L2Distance // The index's default
+	}
+
+	picked := results
+	if m.Lambda < 1 && len(results) > 1 {
+		relevance := make([]float64, len(results))
+		redundancy := make([]float64, len(results)) // Max similarity to the picked results
+		taken := make([]bool, len(results))
+		for i, r := range results {
+			relevance[i] = similarity(r.Distance)
+		}
+
+		picked = make([]SearchResult, 0, min(k, len(results)))
+		for len(picked) < k && len(picked) < len(results) {
+			best, bestScore := -1, 0.0
+			for i := range results {
+				if taken[i] {
+					continue
+				}
+				// Ties go to the nearer candidate, which comes first
+				score := m.Lambda*relevance[i] - (1-m.Lambda)*redundancy[i]
+				if best < 0 || score > bestScore {
+					best, bestScore = i, score
+				}
+			}
+			taken[best] = true
+			picked = append(picked, results[best])
+			if len(picked) == k {
+				break
+			}
+
+			for i := range results {
+				if !taken[i] {
+					sim := similarity(distance(results[best].Document.Vector, results[i].Document.Vector))
+					redundancy[i] = max(redundancy[i], sim)
+				}
+			}
+		}
+	}
+	if len(picked) > k {
+		picked = picked[:k]
+	}
+
+	if !options.Vectors {
+		for _, r := range picked {
+			r.Document.Vector = nil
+		}
+	}
+	return picked
+}
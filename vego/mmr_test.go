@@ -0,0 +1,124 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSearchMMR(t *testing.T) {
+	ctx := context.Background()
+	coll, err := NewCollection("mmr", t.TempDir(), &Config{Dimension: 8, M: 16, EfConstruction: 200})
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	defer coll.Close()
+
+	// Five near-duplicates of the query, and three documents further away
+	// in different directions
+	var docs []*Document
+	for i := 0; i < 5; i++ {
+		docs = append(docs, &Document{ID: fmt.Sprintf("dup_%d", i), Vector: []float32{1, 0.01 * float32(i), 0, 0, 0, 0, 0, 0}})
+	}
+	for i := 0; i < 3; i++ {
+		vector := []float32{0.8, 0, 0, 0, 0, 0, 0, 0}
+		vector[i+2] = 0.6
+		docs = append(docs, &Document{ID: fmt.Sprintf("other_%d", i), Vector: vector})
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	query := []float32{1, 0, 0, 0, 0, 0, 0, 0}
+
+	ids := func(results []SearchResult) string {
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.Document.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	plain, err := coll.SearchContext(ctx, query, 3)
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+
+	// Lambda 1 is the plain ordering
+	same, err := coll.SearchContext(ctx, query, 3, WithMMR(1, 0))
+	if err != nil {
+		t.Fatalf("SearchContext with MMR failed: %v", err)
+	}
+	if ids(same) != ids(plain) {
+		t.Errorf("lambda 1 order %s, want %s", ids(same), ids(plain))
+	}
+	for i := range same {
+		if same[i].Distance != plain[i].Distance {
+			t.Errorf("%s has distance %v, want %v", same[i].Document.ID, same[i].Distance, plain[i].Distance)
+		}
+	}
+
+	// A low lambda keeps one near-duplicate and fills up with the others
+	diverse, err := coll.SearchContext(ctx, query, 3, WithMMR(0.3, 8))
+	if err != nil {
+		t.Fatalf("SearchContext with MMR failed: %v", err)
+	}
+	if len(diverse) != 3 || diverse[0].Document.ID != "dup_0" {
+		t.Fatalf("diverse results %s", ids(diverse))
+	}
+	for _, r := range diverse[1:] {
+		if !strings.HasPrefix(r.Document.ID, "other_") {
+			t.Errorf("diverse results %s hold more than one near-duplicate", ids(diverse))
+		}
+		if r.Document.Vector != nil {
+			t.Errorf("%s has a vector that was not requested", r.Document.ID)
+		}
+	}
+
+	withVectors, err := coll.SearchContext(ctx, query, 2, WithMMR(0.3, 0), WithVectors(true))
+	if err != nil || len(withVectors) != 2 || withVectors[1].Document.Vector == nil {
+		t.Errorf("WithVectors results %v, %v", withVectors, err)
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		for name, opts := range map[string][]SearchOption{
+			"lambda":     {WithMMR(1.5, 0)},
+			"fetchK":     {WithMMR(0.5, -1)},
+			"with boost": {WithMMR(0.5, 0), WithBoost(BoostExpr{})},
+		} {
+			if _, err := coll.SearchContext(ctx, query, 3, opts...); !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("%s: %v, want ErrValidationFailed", name, err)
+			}
+		}
+	})
+}
+
+func TestDiversifyDistanceCache(t *testing.T) {
+	results := make([]SearchResult, 20)
+	for i := range results {
+		vector := make([]float32, 4)
+		vector[i%4] = float32(i)
+		results[i] = SearchResult{Document: &Document{ID: fmt.Sprint(i), Vector: vector}, Distance: float32(i)}
+	}
+
+	calls := 0
+	counting := func(a, b []float32) float32 {
+		calls++
+		var sum float32
+		for i := range a {
+			sum += (a[i] - b[i]) * (a[i] - b[i])
+		}
+		return sum
+	}
+	options := &SearchOptions{MMR: &MMR{Lambda: 0.5}, Vectors: true}
+	picked := diversify(results, 5, options, counting)
+	if len(picked) != 5 {
+		t.Fatalf("picked %d results, want 5", len(picked))
+	}
+	// Each picked result but the last is compared once to every candidate
+	// still left
+	if want := 19 + 18 + 17 + 16; calls != want {
+		t.Errorf("%d distance computations, want %d", calls, want)
+	}
+}
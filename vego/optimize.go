@@ -0,0 +1,39 @@
+package vego
+
+import (
+	"context"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// Optimize re-prunes the index's neighbor lists, continuing round-robin
+// where the previous call stopped, see hnsw.HNSWIndex.Optimize. Bound the
+// work with opts.MaxNodes or opts.TimeBudget to run it in quiet periods;
+// writes to the collection wait until it returns, searches do not. The
+// improved graph is persisted by the next Save.
+func (c *Collection) Optimize(ctx context.Context, opts hnsw.OptimizeOptions) (hnsw.OptimizeReport, error) {
+	ctx, done, err := c.beginWrite(ctx, "Optimize")
+	if err != nil {
+		return hnsw.OptimizeReport{}, err
+	}
+	defer done()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report, err := c.index.Optimize(ctx, opts)
+	if err != nil {
+		return report, wrapError("Optimize", c.name, "", err)
+	}
+	return report, nil
+}
+
+// optimizeTask returns the background task calling Optimize with opts
+func (c *Collection) optimizeTask(opts hnsw.OptimizeOptions) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := c.Optimize(ctx, opts); err != nil && !IsClosed(err) {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+package vego
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+func TestCollectionOptimize(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	insertRandomDocs(t, coll, rand.New(rand.NewSource(1)), 0, 500)
+
+	report, err := coll.Optimize(context.Background(), hnsw.OptimizeOptions{MaxNodes: 200})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if report.Visited != 200 || report.Next != 200 || report.Nodes != 500 {
+		t.Errorf("report = %+v, want 200 of 500 nodes visited", report)
+	}
+
+	doc, _ := coll.Get("doc_00042")
+	results, err := coll.Search(doc.Vector, 1)
+	if err != nil || len(results) != 1 || results[0].Document.ID != "doc_00042" {
+		t.Errorf("search after Optimize: %v, %v", results, err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	replica, err := Open(dir, WithDimension(32), WithReadOnly(true))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer replica.Close()
+	coll, err = replica.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if _, err := coll.Optimize(context.Background(), hnsw.OptimizeOptions{}); !IsReadOnly(err) {
+		t.Errorf("read-only: err = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestAutoOptimize(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(32),
+		WithAutoOptimize(10*time.Millisecond, hnsw.OptimizeOptions{MaxNodes: 50}))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	insertRandomDocs(t, coll, rand.New(rand.NewSource(1)), 0, 500)
+
+	// Background passes advance the round-robin cursor
+	time.Sleep(100 * time.Millisecond)
+	report, err := coll.Optimize(context.Background(), hnsw.OptimizeOptions{MaxNodes: 1})
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if report.Next == 1 {
+		t.Errorf("no background optimize pass ran")
+	}
+}
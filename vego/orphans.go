@@ -0,0 +1,39 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// sweepOrphans reaps the nodes recorded in c.orphans by deleting them from
+// the index, which only unlinks their neighbors; SaveToLance leaves the
+// deleted nodes out. c.mu must be held for writing.
+func (c *Collection) sweepOrphans() error {
+	if len(c.orphans) == 0 {
+		return nil
+	}
+	start := time.Now()
+
+	reaped := 0
+	for nodeID := range c.orphans {
+		if err := c.index.Delete(nodeID); err != nil && !errors.Is(err, hnsw.ErrNodeNotFound) {
+			return wrapError("sweepOrphans", c.name, "", err)
+		}
+		delete(c.orphans, nodeID)
+		reaped++
+	}
+
+	log.Printf("Reaped %d orphaned nodes from collection %s in %v", reaped, c.name, time.Since(start))
+	return nil
+}
+
+// sweepOrphansTask is the background task calling sweepOrphans
+func (c *Collection) sweepOrphansTask(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sweepOrphans()
+}
@@ -0,0 +1,186 @@
+package vego
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// setStorageFailing makes every storage write fail until called with false
+func setStorageFailing(s *DocumentStorage, failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = failing
+}
+
+// insertWithFailures inserts n documents, failing the storage write of every
+// tenth one, and returns the IDs that were stored
+func insertWithFailures(t *testing.T, coll *Collection, n int) []string {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+
+	var stored []string
+	for i := 0; i < n; i++ {
+		doc := &Document{ID: fmt.Sprintf("doc_%04d", i), Vector: make([]float32, 32)}
+		for j := range doc.Vector {
+			doc.Vector[j] = rng.Float32()
+		}
+
+		fail := i%10 == 3
+		setStorageFailing(coll.storage, fail)
+		err := coll.Insert(doc)
+		setStorageFailing(coll.storage, false)
+
+		if fail {
+			if err == nil {
+				t.Fatalf("Insert of %s should have failed", doc.ID)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Insert of %s failed: %v", doc.ID, err)
+		}
+		stored = append(stored, doc.ID)
+	}
+	return stored
+}
+
+func TestOrphansReapedAtSave(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	stored := insertWithFailures(t, coll, 500)
+	stats := coll.Stats()
+	if stats.IndexNodes != 500 || stats.OrphanNodes != 50 {
+		t.Fatalf("Before sweep: expected 500 nodes with 50 orphans, got %d with %d", stats.IndexNodes, stats.OrphanNodes)
+	}
+
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	stats = coll.Stats()
+	if stats.IndexNodes != len(stored) || stats.OrphanNodes != 0 || stats.Count != len(stored) {
+		t.Fatalf("After sweep: expected %d nodes and no orphans, got %+v", len(stored), stats)
+	}
+
+	// Every stored document is still found by its own vector
+	for _, id := range stored {
+		doc, err := coll.Get(id)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", id, err)
+		}
+		results, err := coll.Search(doc.Vector, 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Document.ID != id {
+			t.Fatalf("Search by vector of %s did not return it", id)
+		}
+	}
+
+	// A failed ID can be inserted again after the sweep
+	retry := &Document{ID: "doc_0003", Vector: make([]float32, 32)}
+	if err := coll.Insert(retry); err != nil {
+		t.Fatalf("Retrying failed insert: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if stats := coll.Stats(); stats.IndexNodes != len(stored)+1 || stats.OrphanNodes != 0 {
+		t.Errorf("After reopen: expected %d nodes and no orphans, got %+v", len(stored)+1, stats)
+	}
+}
+
+func TestOrphanSweeper(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(32), WithOrphanSweepInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	stored := insertWithFailures(t, coll, 100)
+	deadline := time.Now().Add(5 * time.Second)
+	for coll.Stats().IndexNodes != len(stored) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Sweeper did not reap orphans: %+v", coll.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeleteRemovesIndexNodes(t *testing.T) {
+	path := t.TempDir()
+	db, err := Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := coll.Insert(&Document{ID: fmt.Sprint(i), Vector: []float32{float32(i), 1, 2, 3}}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Deletes and updates leave no nodes behind
+	for i := 0; i < 10; i++ {
+		if err := coll.Delete(fmt.Sprint(i)); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := coll.DeleteBatch([]string{"10", "11", "12", "13", "14"}); err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	for i := 40; i < 50; i++ {
+		if err := coll.Update(&Document{ID: fmt.Sprint(i), Vector: []float32{float32(i), 4, 5, 6}}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	if stats := coll.Stats(); stats.Count != 35 || stats.IndexNodes != 35 || stats.OrphanNodes != 0 {
+		t.Errorf("After deletes: %+v", stats)
+	}
+	results, err := coll.Search([]float32{0, 1, 2, 3}, 5)
+	if err != nil || len(results) != 5 || results[0].Document.ID != "15" {
+		t.Errorf("Search after deletes = %v, %v", results, err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(path, WithDimension(4))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if stats := coll.Stats(); stats.Count != 35 || stats.IndexNodes != 35 || stats.OrphanNodes != 0 {
+		t.Errorf("After reopen: %+v", stats)
+	}
+}
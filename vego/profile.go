@@ -0,0 +1,373 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+	"github.com/wzqhbustb/vego/storage/encoding"
+)
+
+const (
+	defaultProfileSampleDocs   = 2000
+	defaultProfileQueries      = 100
+	defaultProfileK            = 10
+	defaultProfileTargetRecall = 0.9
+	maxProfileEF               = 1024
+	profileCancelCheckInterval = 256
+)
+
+// ProfileCandidate is one index configuration tried by Profile. Zero values
+// select the same defaults as Config.
+type ProfileCandidate struct {
+	Name             string // Label in the report; defaults to the parameters
+	M                int
+	EfConstruction   int
+	EF               int // Search ef; 0 finds the smallest ef reaching TargetRecall
+	CompressionLevel int // 0 = the collection's level
+}
+
+// String returns the name, or the parameters if there is none
+func (p ProfileCandidate) String() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprintf("M=%d efConstruction=%d ef=%d compression=%d", p.M, p.EfConstruction, p.EF, p.CompressionLevel)
+}
+
+// ProfileOptions configures Profile. Memory is bounded by SampleDocs: each
+// candidate index holds that many vectors and is released before the next
+// one is built.
+type ProfileOptions struct {
+	SampleDocs    int                // Documents sampled from the collection (default 2000)
+	SampleQueries [][]float32        // Queries; default 100 vectors of documents left out of the sample
+	K             int                // Neighbors per query (default 10)
+	TargetRecall  float64            // Recall@K a candidate must reach (default 0.9)
+	Candidates    []ProfileCandidate // Configurations to compare, at least one
+	MaxDuration   time.Duration      // Bound on the whole profile, 0 = none
+}
+
+// ProfileResult is the measurement of one candidate
+type ProfileResult struct {
+	Candidate     ProfileCandidate
+	EF            int           // Search ef measured: Candidate.EF or the smallest reaching TargetRecall
+	BuildTime     time.Duration // Time to insert the sample
+	BuildRate     float64       // Documents inserted per second
+	Recall        float64       // Mean recall@K against exact search over the sample
+	MeetsTarget   bool          // Recall >= TargetRecall
+	SearchP50     time.Duration
+	SearchP95     time.Duration
+	IndexBytes    int64 // Saved size of the sample index
+	EstimatedSize int64 // IndexBytes scaled to the collection's document count
+}
+
+// ProfileReport ranks the candidates of a Profile, best first: candidates
+// reaching the target recall by P95 latency then size, followed by the rest
+// by recall
+type ProfileReport struct {
+	SampleDocs   int
+	Queries      int
+	K            int
+	TargetRecall float64
+	Results      []ProfileResult
+	Recommended  ProfileCandidate // Candidate of Results[0]
+}
+
+// Profile compares index configurations on the collection's own vectors.
+// For each candidate it builds a trial index from a sample of the
+// collection, measures the build rate, the recall and search latency
+// percentiles at the smallest ef reaching the target recall, and the saved
+// index size using the candidate's compression level, then ranks the
+// candidates. The collection is not modified; only sampling holds its read
+// lock. Cancelling ctx or exceeding MaxDuration stops the profile with the
+// context's error. An empty collection has nothing to measure and fails
+// with hnsw.ErrEmptyIndex.
+func (c *Collection) Profile(ctx context.Context, opts ProfileOptions) (*ProfileReport, error) {
+	ctx, done, err := c.begin(ctx, "Profile")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if len(opts.Candidates) == 0 {
+		return nil, wrapError("Profile", c.name, "", fmt.Errorf("%w: no candidates to profile", ErrValidationFailed))
+	}
+	if opts.SampleDocs <= 0 {
+		opts.SampleDocs = defaultProfileSampleDocs
+	}
+	if opts.K <= 0 {
+		opts.K = defaultProfileK
+	}
+	if opts.TargetRecall <= 0 {
+		opts.TargetRecall = defaultProfileTargetRecall
+	}
+	for _, cand := range opts.Candidates {
+		if err := c.validateCandidate(cand); err != nil {
+			return nil, wrapError("Profile", c.name, "", fmt.Errorf("candidate %s: %w", cand, err))
+		}
+	}
+	for _, q := range opts.SampleQueries {
+		if err := c.checkQuery("Profile", q); err != nil {
+			return nil, err
+		}
+	}
+	if opts.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxDuration)
+		defer cancel()
+	}
+
+	queries := opts.SampleQueries
+	numQueries := 0
+	if queries == nil {
+		numQueries = defaultProfileQueries
+	}
+	sample, heldOut, total, err := c.profileSample(opts.SampleDocs, numQueries)
+	if err != nil {
+		return nil, wrapError("Profile", c.name, "", err)
+	}
+	if queries == nil {
+		queries = heldOut
+	}
+	if len(sample) == 0 || len(queries) == 0 {
+		return nil, wrapError("Profile", c.name, "", hnsw.ErrEmptyIndex)
+	}
+
+	distFunc := c.config.DistanceFunc
+	if distFunc == nil {
+		distFunc = hnsw.L2Distance
+	}
+	truth := exactNeighbors(sample, queries, opts.K, distFunc)
+
+	report := &ProfileReport{
+		SampleDocs:   len(sample),
+		Queries:      len(queries),
+		K:            opts.K,
+		TargetRecall: opts.TargetRecall,
+	}
+	for _, cand := range opts.Candidates {
+		result, err := c.profileCandidate(ctx, cand, sample, queries, truth, opts, distFunc)
+		if err != nil {
+			return nil, wrapError("Profile", c.name, "", err)
+		}
+		result.EstimatedSize = result.IndexBytes * int64(total) / int64(len(sample))
+		report.Results = append(report.Results, result)
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		a, b := report.Results[i], report.Results[j]
+		if a.MeetsTarget != b.MeetsTarget {
+			return a.MeetsTarget
+		}
+		if !a.MeetsTarget {
+			return a.Recall > b.Recall
+		}
+		if a.SearchP95 != b.SearchP95 {
+			return a.SearchP95 < b.SearchP95
+		}
+		return a.EstimatedSize < b.EstimatedSize
+	})
+	report.Recommended = report.Results[0].Candidate
+	return report, nil
+}
+
+// validateCandidate rejects index parameters and compression levels the
+// collection could not be configured with
+func (c *Collection) validateCandidate(cand ProfileCandidate) error {
+	config := hnsw.Config{Dimension: c.dimension, M: cand.M, EfConstruction: cand.EfConstruction}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidationFailed, err)
+	}
+	if cand.EF < 0 {
+		return fmt.Errorf("%w: ef %d is negative", ErrValidationFailed, cand.EF)
+	}
+	if cand.CompressionLevel != 0 {
+		if err := encoding.ValidateCompressionLevel(cand.CompressionLevel); err != nil {
+			return fmt.Errorf("%w: %v", ErrValidationFailed, err)
+		}
+	}
+	return nil
+}
+
+// profileSample returns the vectors of up to n documents chosen at random,
+// the vectors of up to q other documents to use as queries, and the number
+// of documents in the collection. The choice is seeded, so repeated
+// profiles of the same collection use the same sample.
+func (c *Collection) profileSample(n, q int) (sample, queries [][]float32, total int, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]string, 0, len(c.docToNode))
+	for id := range c.docToNode {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	rng := rand.New(rand.NewSource(1))
+	rng.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+
+	// Leave the queries out of the sample so they are not their own hits
+	if q > len(ids)/2 {
+		q = len(ids) / 2
+	}
+	if n > len(ids)-q {
+		n = len(ids) - q
+	}
+	vectors := make([][]float32, 0, n+q)
+	for _, id := range ids[:n+q] {
+		v, err := c.index.Vector(c.docToNode[id])
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors[:n], vectors[n:], len(ids), nil
+}
+
+// exactNeighbors returns the positions in sample of the k nearest vectors of
+// each query by brute force
+func exactNeighbors(sample, queries [][]float32, k int, distFunc hnsw.DistanceFunc) []map[int]bool {
+	truth := make([]map[int]bool, len(queries))
+	order := make([]int, len(sample))
+	distances := make([]float32, len(sample))
+	for qi, q := range queries {
+		for i, v := range sample {
+			order[i] = i
+			distances[i] = distFunc(q, v)
+		}
+		sort.Slice(order, func(a, b int) bool { return distances[order[a]] < distances[order[b]] })
+		n := min(k, len(order))
+		truth[qi] = make(map[int]bool, n)
+		for _, i := range order[:n] {
+			truth[qi][i] = true
+		}
+	}
+	return truth
+}
+
+// profileCandidate builds, searches and saves the trial index of cand
+func (c *Collection) profileCandidate(ctx context.Context, cand ProfileCandidate, sample, queries [][]float32, truth []map[int]bool, opts ProfileOptions, distFunc hnsw.DistanceFunc) (ProfileResult, error) {
+	result := ProfileResult{Candidate: cand}
+
+	index, err := hnsw.NewHNSWChecked(hnsw.Config{
+		Dimension:      c.dimension,
+		M:              cand.M,
+		EfConstruction: cand.EfConstruction,
+		DistanceFunc:   distFunc,
+	})
+	if err != nil {
+		return result, err
+	}
+	defer index.Close()
+
+	start := time.Now()
+	for i, v := range sample {
+		if i%profileCancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+		}
+		// Node IDs follow insertion order, so node i is sample[i]
+		if _, err := index.Add(v); err != nil {
+			return result, err
+		}
+	}
+	result.BuildTime = time.Since(start)
+	result.BuildRate = float64(len(sample)) / result.BuildTime.Seconds()
+
+	efs := []int{cand.EF}
+	if cand.EF == 0 {
+		efs = nil
+		for ef := opts.K; ef < maxProfileEF; ef *= 2 {
+			efs = append(efs, ef)
+		}
+		efs = append(efs, maxProfileEF)
+	}
+	for _, ef := range efs {
+		recall, latencies, err := profileSearch(ctx, index, queries, truth, opts.K, ef)
+		if err != nil {
+			return result, err
+		}
+		result.EF = ef
+		result.Recall = recall
+		result.MeetsTarget = recall >= opts.TargetRecall
+		result.SearchP50 = latencies[len(latencies)/2]
+		result.SearchP95 = latencies[len(latencies)*95/100]
+		if result.MeetsTarget {
+			break
+		}
+	}
+
+	level := cand.CompressionLevel
+	if level == 0 {
+		level = c.settings.CompressionLevel
+	}
+	size, err := savedIndexSize(index, level, c.settings.Encoder)
+	if err != nil {
+		return result, err
+	}
+	result.IndexBytes = size
+	return result, nil
+}
+
+// profileSearch runs every query at ef and returns the mean recall@k and
+// the sorted search latencies
+func profileSearch(ctx context.Context, index *hnsw.HNSWIndex, queries [][]float32, truth []map[int]bool, k, ef int) (float64, []time.Duration, error) {
+	latencies := make([]time.Duration, len(queries))
+	var recall float64
+	for qi, q := range queries {
+		if err := ctx.Err(); err != nil {
+			return 0, nil, err
+		}
+		start := time.Now()
+		results, err := index.Search(q, k, ef)
+		latencies[qi] = time.Since(start)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(truth[qi]) == 0 {
+			recall++
+			continue
+		}
+		hits := 0
+		for _, r := range results {
+			if truth[qi][r.ID] {
+				hits++
+			}
+		}
+		recall += float64(hits) / float64(len(truth[qi]))
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return recall / float64(len(queries)), latencies, nil
+}
+
+// savedIndexSize saves index to a temporary directory with the given
+// compression and returns the bytes written
+func savedIndexSize(index *hnsw.HNSWIndex, level int, config encoding.EncoderConfig) (int64, error) {
+	dir, err := os.MkdirTemp("", "vego-profile-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := index.SaveToLanceWithFactory(dir, encoding.NewEncoderFactoryWithConfig(level, &config)); err != nil {
+		return 0, err
+	}
+	var size int64
+	err = filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,129 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// profileTestCollection returns a collection of n documents in tight
+// clusters, where a sparse graph easily gets stuck in the wrong cluster
+func profileTestCollection(t *testing.T, n int) *Collection {
+	t.Helper()
+	const dim, clusters = 32, 40
+
+	db, err := Open(t.TempDir(), WithDimension(dim))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(7))
+	centers := make([][]float32, clusters)
+	for i := range centers {
+		centers[i] = make([]float32, dim)
+		for j := range centers[i] {
+			centers[i][j] = rng.Float32() * 10
+		}
+	}
+	docs := make([]*Document, n)
+	for i := range docs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = centers[i%clusters][j] + float32(rng.NormFloat64())*0.3
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc_%05d", i), Vector: v}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	return coll
+}
+
+func TestProfile(t *testing.T) {
+	coll := profileTestCollection(t, 2000)
+	sparse := ProfileCandidate{Name: "sparse", M: 2, EfConstruction: 4, EF: 10}
+	dense := ProfileCandidate{Name: "dense", M: 24, EfConstruction: 200, EF: 10}
+
+	report, err := coll.Profile(context.Background(), ProfileOptions{
+		SampleDocs: 1500,
+		Candidates: []ProfileCandidate{sparse, dense},
+	})
+	if err != nil {
+		t.Fatalf("Profile failed: %v", err)
+	}
+	for _, r := range report.Results {
+		t.Logf("%s: recall %.3f p50 %v p95 %v build %.0f docs/s size %d (est. %d)",
+			r.Candidate, r.Recall, r.SearchP50, r.SearchP95, r.BuildRate, r.IndexBytes, r.EstimatedSize)
+	}
+
+	if report.SampleDocs != 1500 || report.Queries != defaultProfileQueries || report.K != defaultProfileK {
+		t.Errorf("unexpected sample: %d docs, %d queries, k=%d", report.SampleDocs, report.Queries, report.K)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(report.Results))
+	}
+	if report.Recommended != dense || report.Results[0].Candidate != dense {
+		t.Errorf("recommended %s, want dense", report.Recommended)
+	}
+	best, worst := report.Results[0], report.Results[1]
+	if best.Recall <= worst.Recall {
+		t.Errorf("dense recall %.3f not above sparse %.3f", best.Recall, worst.Recall)
+	}
+	for _, r := range report.Results {
+		if r.BuildRate <= 0 || r.SearchP50 <= 0 || r.SearchP95 < r.SearchP50 || r.EF != 10 {
+			t.Errorf("%s: incomplete measurement %+v", r.Candidate, r)
+		}
+		if r.IndexBytes <= 0 || r.EstimatedSize < r.IndexBytes {
+			t.Errorf("%s: size %d, estimated %d", r.Candidate, r.IndexBytes, r.EstimatedSize)
+		}
+	}
+
+	// Without a fixed ef, the smallest ef reaching the target is reported
+	report, err = coll.Profile(context.Background(), ProfileOptions{
+		SampleDocs:   500,
+		TargetRecall: 0.95,
+		Candidates:   []ProfileCandidate{{M: 16}},
+	})
+	if err != nil {
+		t.Fatalf("Profile failed: %v", err)
+	}
+	if r := report.Results[0]; !r.MeetsTarget || r.Recall < 0.95 || r.EF < defaultProfileK {
+		t.Errorf("expected the target recall to be reached, got %+v", r)
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	coll := profileTestCollection(t, 300)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := coll.Profile(ctx, ProfileOptions{Candidates: []ProfileCandidate{{M: 16}}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	for name, opts := range map[string]ProfileOptions{
+		"no candidates": {},
+		"invalid M":     {Candidates: []ProfileCandidate{{M: 1}}},
+		"negative ef":   {Candidates: []ProfileCandidate{{EF: -1}}},
+		"invalid level": {Candidates: []ProfileCandidate{{CompressionLevel: 99}}},
+	} {
+		if _, err := coll.Profile(context.Background(), opts); !IsValidationFailed(err) {
+			t.Errorf("%s: expected ErrValidationFailed, got %v", name, err)
+		}
+	}
+	_, err = coll.Profile(context.Background(), ProfileOptions{
+		SampleQueries: [][]float32{{1, 2}},
+		Candidates:    []ProfileCandidate{{}},
+	})
+	if !IsDimensionMismatch(err) {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
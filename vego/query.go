@@ -1,15 +1,78 @@
 package vego
 
+import "reflect"
+
+// Names of the built-in distance functions, as recorded in a collection's
+// settings and returned by Collection.Metric
+const (
+	MetricL2           = "l2"     // hnsw.L2Distance, the squared Euclidean distance
+	MetricInnerProduct = "ip"     // hnsw.InnerProductDistance, the negated dot product
+	MetricCosine       = "cosine" // hnsw.CosineDistance, 1 - cosine similarity
+)
+
 // SearchResult represents a search result
 type SearchResult struct {
 	Document *Document
 	Distance float32
+
+	// Similarity is Distance converted by the collection's metric to a score
+	// that rises as documents get closer, see DistanceToScore
+	Similarity float32
+
+	// Score is set by boosted searches, see WithBoost, and by HybridSearch;
+	// Explain by boosted searches only
+	Score   float32
+	Explain string
+}
+
+// IDResult is a search hit without its document, as returned by SearchIDs
+type IDResult struct {
+	DocID    string
+	Distance float32
+}
+
+// DistanceToScore converts a distance of the named metric to a score that
+// rises as vectors get closer:
+//
+//	cosine: 1 - distance, the cosine similarity, from -1 to 1
+//	ip:     -distance, the dot product
+//	l2:     1 / (1 + distance), from 1 for identical vectors towards 0
+//
+// Any other metric, such as the "" of a custom distance function, maps as
+// l2, or as 1 - distance for a negative distance.
+func DistanceToScore(metric string, distance float32) float32 {
+	switch metric {
+	case MetricCosine:
+		return 1 - distance
+	case MetricInnerProduct:
+		return -distance
+	default:
+		return float32(similarity(distance))
+	}
+}
+
+// newResult returns the result for doc at distance from the query
+func (c *Collection) newResult(doc *Document, distance float32) SearchResult {
+	return SearchResult{Document: doc, Distance: distance, Similarity: DistanceToScore(c.metric, distance)}
 }
 
 // SearchOptions contains search options
 type SearchOptions struct {
-	EF     int    // Search scope (0 = use default)
-	Filter Filter // Optional metadata filter
+	EF            int    // Search scope (0 = use default)
+	EFUpperLayers int    // Beam width above layer 0 (0 or 1 = greedy descent)
+	Filter        Filter // Optional metadata filter
+	Vectors       bool   // Populate Document.Vector in results (default from Config.SearchVectors)
+	IncludeSource bool   // Keep the source document in SearchSimilarTo results
+
+	FilterEscalation    bool // Search beyond 20k candidates when a filter leaves fewer than k (default from Config)
+	MaxFilterCandidates int  // Candidates a filtered search escalates to at most (default from Config)
+
+	Boost *BoostExpr // Ranks results by metadata as well as distance, see WithBoost
+	MMR   *MMR       // Diversifies results, see WithMMR
+
+	Enricher            Enricher // Adds metadata to results, see WithEnrichment
+	EnrichmentNamespace string   // Metadata key holding enrichment ("" = merge at top level)
+	EnrichmentOptional  bool     // Return results un-enriched if the enricher fails
 }
 
 // SearchOption is a functional option for search
@@ -22,6 +85,34 @@ func WithEF(ef int) SearchOption {
 	}
 }
 
+// WithEFUpperLayers widens the descent through the layers above 0 from a
+// single greedy path to a beam of ef nodes. On clustered data this recovers
+// recall lost to a poor entry region, which raising WithEF alone cannot do.
+// Expert tuning; most searches should leave it unset.
+func WithEFUpperLayers(ef int) SearchOption {
+	return func(o *SearchOptions) {
+		o.EFUpperLayers = ef
+	}
+}
+
+// WithVectors controls whether result documents carry their vectors. By
+// default Document.Vector is nil in search results to keep responses small.
+// When enabled, each vector is copied from the index's in-memory copy, so the
+// caller may modify it freely.
+func WithVectors(enabled bool) SearchOption {
+	return func(o *SearchOptions) {
+		o.Vectors = enabled
+	}
+}
+
+// WithIncludeSource keeps the source document in SearchSimilarTo results,
+// where it is normally left out. Other searches ignore it.
+func WithIncludeSource(enabled bool) SearchOption {
+	return func(o *SearchOptions) {
+		o.IncludeSource = enabled
+	}
+}
+
 // Filter is an interface for document filtering
 type Filter interface {
 	Match(doc *Document) bool
@@ -30,10 +121,22 @@ type Filter interface {
 // MetadataFilter filters by metadata field
 type MetadataFilter struct {
 	Field    string
-	Operator string // eq, ne, gt, lt, contains, etc.
+	Operator string // eq, ne, gt, gte, lt, lte, in, nin, contains
 	Value    interface{}
 }
 
+// Match reports whether the document's field satisfies the operator.
+// Values are compared with the same coercion as the Document accessors
+// such as GetInt: numbers match by value whatever their type, so eq 2024
+// matches a document stored with an int and the same document reloaded
+// from disk, where JSON made it a float64.
+//
+// in and nin take a slice of values and match if the field equals any or
+// none of them; contains matches a substring of a string field. A field
+// holding a slice, such as tags, matches in if any element is in the list,
+// nin if none is, and contains if an element equals the value. Slices of
+// any element type are accepted on both sides, including the []interface{}
+// metadata read back from disk.
 func (f *MetadataFilter) Match(doc *Document) bool {
 	val, exists := doc.Metadata[f.Field]
 	if !exists {
@@ -42,27 +145,35 @@ func (f *MetadataFilter) Match(doc *Document) bool {
 
 	switch f.Operator {
 	case "eq":
-		return val == f.Value
+		return valuesEqual(val, f.Value)
 	case "ne":
-		return val != f.Value
+		return !valuesEqual(val, f.Value)
 	case "gt":
-		return compareGreater(val, f.Value)
+		cmp, ok := compareValues(val, f.Value)
+		return ok && cmp > 0
 	case "gte":
-		return compareGreater(val, f.Value) || val == f.Value
+		cmp, ok := compareValues(val, f.Value)
+		return ok && cmp >= 0
 	case "lt":
-		return compareLess(val, f.Value)
+		cmp, ok := compareValues(val, f.Value)
+		return ok && cmp < 0
 	case "lte":
-		return compareLess(val, f.Value) || val == f.Value
+		cmp, ok := compareValues(val, f.Value)
+		return ok && cmp <= 0
 	case "in":
-		if slice, ok := f.Value.([]interface{}); ok {
-			for _, v := range slice {
-				if val == v {
+		return inList(val, f.Value)
+	case "nin":
+		_, ok := sliceValues(f.Value)
+		return ok && !inList(val, f.Value)
+	case "contains":
+		if elems, ok := sliceValues(val); ok {
+			for _, e := range elems {
+				if valuesEqual(e, f.Value) {
 					return true
 				}
 			}
+			return false
 		}
-		return false
-	case "contains":
 		if str, ok := val.(string); ok {
 			if substr, ok := f.Value.(string); ok {
 				return contains(str, substr)
@@ -74,49 +185,42 @@ func (f *MetadataFilter) Match(doc *Document) bool {
 	}
 }
 
-// Helper functions for type-safe comparison
-func compareGreater(a, b interface{}) bool {
-	switch v := a.(type) {
-	case int:
-		if bv, ok := b.(int); ok {
-			return v > bv
-		}
-	case int64:
-		if bv, ok := b.(int64); ok {
-			return v > bv
-		}
-	case float64:
-		if bv, ok := b.(float64); ok {
-			return v > bv
-		}
-	case string:
-		if bv, ok := b.(string); ok {
-			return v > bv
+// inList reports whether val, or an element of val if it is a slice,
+// equals an element of list. It is false if list is not a slice.
+func inList(val, list interface{}) bool {
+	candidates, ok := sliceValues(list)
+	if !ok {
+		return false
+	}
+	vals, isSlice := sliceValues(val)
+	if !isSlice {
+		vals = []interface{}{val}
+	}
+	for _, v := range vals {
+		for _, c := range candidates {
+			if valuesEqual(v, c) {
+				return true
+			}
 		}
 	}
 	return false
 }
 
-func compareLess(a, b interface{}) bool {
-	switch v := a.(type) {
-	case int:
-		if bv, ok := b.(int); ok {
-			return v < bv
-		}
-	case int64:
-		if bv, ok := b.(int64); ok {
-			return v < bv
-		}
-	case float64:
-		if bv, ok := b.(float64); ok {
-			return v < bv
-		}
-	case string:
-		if bv, ok := b.(string); ok {
-			return v < bv
-		}
+// sliceValues returns the elements of v if it is a slice or array of any
+// element type, such as []string tags or the []interface{} JSON decodes
+func sliceValues(v interface{}) ([]interface{}, bool) {
+	if elems, ok := v.([]interface{}); ok {
+		return elems, true
 	}
-	return false
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elems := make([]interface{}, rv.Len())
+	for i := range elems {
+		elems[i] = rv.Index(i).Interface()
+	}
+	return elems, true
 }
 
 func contains(s, substr string) bool {
@@ -160,3 +264,16 @@ func (f *OrFilter) Match(doc *Document) bool {
 	}
 	return false
 }
+
+// NotFilter matches the documents its Filter does not, and can be nested
+// anywhere in And and Or filters. A MetadataFilter on a field the document
+// lacks does not match, so its negation does: NOT brand = TechCo matches
+// documents without a brand. A nil Filter matches every document, as in
+// SearchFiltered, so its negation matches none.
+type NotFilter struct {
+	Filter Filter
+}
+
+func (f *NotFilter) Match(doc *Document) bool {
+	return f.Filter != nil && !f.Filter.Match(doc)
+}
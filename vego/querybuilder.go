@@ -0,0 +1,161 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultQueryTopK is the number of documents a Query ranks when TopK is
+// not called
+const defaultQueryTopK = 10
+
+// QueryBuilder composes a vector search with a filter, pagination and
+// field selection; see Collection.Query. Its methods return the builder so
+// calls chain, and nothing runs until Execute. A builder may be executed
+// more than once but must not be used from several goroutines at a time.
+type QueryBuilder struct {
+	coll   *Collection
+	vector []float32
+	topK   int
+	filter Filter
+	offset int
+	limit  int
+	fields []string
+	opts   []SearchOption
+}
+
+// Query starts a query for the documents nearest to vector:
+//
+//	results, err := coll.Query(vector).TopK(50).Filter(f).
+//		Offset(20).Limit(10).Fields("title", "price").Execute(ctx)
+//
+// The query ranks the TopK nearest documents matching the filter, then
+// returns the page of them selected by Offset and Limit. Pages are cut
+// after filtering, so for the same vector and filter consecutive offsets
+// page through one ranking without gaps or repeats.
+func (c *Collection) Query(vector []float32) *QueryBuilder {
+	return &QueryBuilder{coll: c, vector: vector, topK: defaultQueryTopK}
+}
+
+// TopK sets how many of the nearest matching documents the query ranks
+// (default 10); pages never reach past them. k must be positive.
+func (q *QueryBuilder) TopK(k int) *QueryBuilder {
+	q.topK = k
+	return q
+}
+
+// Filter restricts the results to documents matching f, applied during
+// the index traversal as in SearchWithFilter. A nil filter matches every
+// document.
+func (q *QueryBuilder) Filter(f Filter) *QueryBuilder {
+	q.filter = f
+	return q
+}
+
+// Offset skips the first n ranked documents (default 0). An offset at or
+// past the ranked documents returns no results.
+func (q *QueryBuilder) Offset(n int) *QueryBuilder {
+	q.offset = n
+	return q
+}
+
+// Limit caps the results at n documents (default 0, no cap beyond TopK)
+func (q *QueryBuilder) Limit(n int) *QueryBuilder {
+	q.limit = n
+	return q
+}
+
+// Fields keeps only the named metadata fields in the result documents;
+// fields a document lacks are left out. With no names, as with a nil or
+// empty slice passed as Fields(names...), results carry all their metadata.
+func (q *QueryBuilder) Fields(names ...string) *QueryBuilder {
+	q.fields = names
+	return q
+}
+
+// Options adds search options, applied as in SearchContext. A boost ranks
+// the TopK documents by score before the page is cut; enrichment runs on
+// the page only, before Fields selects from the enriched metadata.
+func (q *QueryBuilder) Options(opts ...SearchOption) *QueryBuilder {
+	q.opts = append(q.opts, opts...)
+	return q
+}
+
+// Execute runs the query and returns its page of results, closest (or with
+// a boost, highest scoring) first. It returns ErrInvalidK if TopK is not
+// positive, ErrValidationFailed for a negative offset or limit, and the
+// context's error if ctx is done before the query completes.
+func (q *QueryBuilder) Execute(ctx context.Context) ([]SearchResult, error) {
+	c := q.coll
+	ctx, done, err := c.begin(ctx, "Query")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := c.checkQuery("Query", q.vector); err != nil {
+		return nil, err
+	}
+	if q.topK <= 0 {
+		return nil, wrapError("Query", c.name, "", ErrInvalidK)
+	}
+	if q.offset < 0 || q.limit < 0 {
+		return nil, wrapError("Query", c.name, "", fmt.Errorf("%w: offset %d and limit %d must not be negative", ErrValidationFailed, q.offset, q.limit))
+	}
+
+	options := c.searchOptions(q.opts)
+	if err := c.checkBoost("Query", options); err != nil {
+		return nil, err
+	}
+	k := boostCandidates(q.topK, options)
+	var results []SearchResult
+	if q.filter != nil {
+		results, err = c.searchWithFilter(ctx, "Query", q.vector, k, q.filter, options)
+	} else {
+		results, err = c.search(ctx, "Query", q.vector, k, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results = page(boost(results, q.topK, options), q.offset, q.limit)
+	if results, err = c.enrich(ctx, "Query", results, options); err != nil {
+		return nil, err
+	}
+	if len(q.fields) > 0 {
+		for i := range results {
+			results[i].Document = selectFields(results[i].Document, q.fields)
+		}
+	}
+	return results, nil
+}
+
+// page returns the results from offset on, at most limit of them unless
+// limit is 0
+func page(results []SearchResult, offset, limit int) []SearchResult {
+	if offset >= len(results) {
+		return []SearchResult{}
+	}
+	results = results[offset:]
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results
+}
+
+// selectFields returns a copy of doc whose metadata holds only the given
+// fields. doc's metadata map may be shared with storage, so it is never
+// modified.
+func selectFields(doc *Document, fields []string) *Document {
+	selected := *doc
+	selected.Metadata = make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if v, ok := doc.Metadata[field]; ok {
+			selected.Metadata[field] = v
+		}
+	}
+	return &selected
+}
@@ -0,0 +1,145 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// filterFunc adapts a function to Filter
+type filterFunc func(*Document) bool
+
+func (f filterFunc) Match(doc *Document) bool { return f(doc) }
+
+func setupQueryTest(t *testing.T) *Collection {
+	t.Helper()
+	db, err := OpenInMemory(WithDimension(4))
+	if err != nil {
+		t.Fatalf("OpenInMemory failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, _ := db.Collection("docs")
+	for i := 0; i < 40; i++ {
+		doc := &Document{
+			ID:     fmt.Sprintf("doc_%02d", i),
+			Vector: []float32{float32(i), 0, 0, 0},
+			Metadata: map[string]interface{}{
+				"title": fmt.Sprint("title ", i),
+				"price": i * 10,
+				"even":  i%2 == 0,
+			},
+		}
+		if err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	return coll
+}
+
+func TestQueryPagination(t *testing.T) {
+	coll := setupQueryTest(t)
+	ctx := context.Background()
+	query := []float32{0, 0, 0, 0}
+	even := &MetadataFilter{Field: "even", Operator: "eq", Value: true}
+
+	all, err := coll.Query(query).TopK(15).Filter(even).Execute(ctx)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(all) != 15 || all[0].Document.ID != "doc_00" || all[14].Document.ID != "doc_28" {
+		t.Fatalf("TopK 15 of even documents = %v", resultIDs(all))
+	}
+
+	// Pages cut after filtering cover the ranking without gaps or repeats
+	var paged []string
+	for offset := 0; offset < 20; offset += 4 {
+		results, err := coll.Query(query).TopK(15).Filter(even).Offset(offset).Limit(4).Execute(ctx)
+		if err != nil {
+			t.Fatalf("Execute at offset %d failed: %v", offset, err)
+		}
+		if want := min(4, max(15-offset, 0)); len(results) != want {
+			t.Errorf("page at offset %d has %d results, want %d", offset, len(results), want)
+		}
+		paged = append(paged, resultIDs(results)...)
+	}
+	if !reflect.DeepEqual(paged, resultIDs(all)) {
+		t.Errorf("pages = %v, want %v", paged, resultIDs(all))
+	}
+
+	// An offset at or past the ranked documents returns no results
+	for _, offset := range []int{15, 100} {
+		results, err := coll.Query(query).TopK(15).Filter(even).Offset(offset).Execute(ctx)
+		if err != nil || results == nil || len(results) != 0 {
+			t.Errorf("offset %d = %v, %v", offset, results, err)
+		}
+	}
+
+	// A filter matching nothing, and a nil filter
+	none := &MetadataFilter{Field: "price", Operator: "gt", Value: 1000}
+	if results, err := coll.Query(query).Filter(none).Execute(ctx); err != nil || len(results) != 0 {
+		t.Errorf("empty filter = %v, %v", resultIDs(results), err)
+	}
+	results, err := coll.Query(query).Filter(nil).Limit(3).Execute(ctx)
+	if err != nil || fmt.Sprint(resultIDs(results)) != "[doc_00 doc_01 doc_02]" {
+		t.Errorf("nil filter = %v, %v", resultIDs(results), err)
+	}
+
+	if _, err := coll.Query(query).TopK(0).Execute(ctx); !IsInvalidK(err) {
+		t.Errorf("TopK 0 = %v, want ErrInvalidK", err)
+	}
+	if _, err := coll.Query(query).Offset(-1).Execute(ctx); !IsValidationFailed(err) {
+		t.Errorf("negative offset = %v, want ErrValidationFailed", err)
+	}
+	if _, err := coll.Query([]float32{1}).Execute(ctx); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("short vector = %v, want ErrDimensionMismatch", err)
+	}
+}
+
+func TestQueryFields(t *testing.T) {
+	coll := setupQueryTest(t)
+	ctx := context.Background()
+	query := []float32{3, 0, 0, 0}
+
+	results, err := coll.Query(query).Limit(2).Fields("title", "missing").Execute(ctx)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	for _, r := range results {
+		if len(r.Document.Metadata) != 1 || r.Document.Metadata["title"] == nil {
+			t.Errorf("%s has metadata %v, want only title", r.Document.ID, r.Document.Metadata)
+		}
+	}
+
+	// Without fields, results keep all metadata, and selection never
+	// touched the stored documents
+	for _, fields := range [][]string{nil, {}} {
+		results, err := coll.Query(query).Limit(1).Fields(fields...).Execute(ctx)
+		if err != nil || len(results) != 1 || len(results[0].Document.Metadata) != 3 {
+			t.Errorf("Fields(%v) = %v, %v", fields, results, err)
+		}
+	}
+}
+
+func TestQueryCancelled(t *testing.T) {
+	coll := setupQueryTest(t)
+	query := []float32{0, 0, 0, 0}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := coll.Query(query).Execute(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled before = %v, want context.Canceled", err)
+	}
+
+	// Cancelled while the search runs
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	cancelling := filterFunc(func(*Document) bool {
+		cancel()
+		return true
+	})
+	if _, err := coll.Query(query).Filter(cancelling).Execute(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled during = %v, want context.Canceled", err)
+	}
+}
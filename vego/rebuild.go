@@ -0,0 +1,107 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// rebuildProgressSteps is how many progress lines a rebuild logs
+const rebuildProgressSteps = 10
+
+// rebuildChunkSize is how many documents a rebuild reads from storage and
+// adds to the index at a time
+var rebuildChunkSize = 4096
+
+// LoadReport describes how a collection's index was restored when it was
+// opened
+type LoadReport struct {
+	IndexRebuilt       bool          // Index was rebuilt from document storage
+	Reason             error         // Why the saved index could not be used, nil unless rebuilt
+	DocumentsReindexed int           // Documents added to the rebuilt index
+	Duration           time.Duration // Time spent rebuilding
+}
+
+// LoadReport returns how the index was restored when the collection was
+// opened. IndexRebuilt is false when the saved index loaded normally.
+func (c *Collection) LoadReport() LoadReport {
+	return c.loadReport
+}
+
+// rebuildIndex replaces the index with one built from every document in
+// storage, then persists it. reason is why the saved index was unusable.
+// Documents are read and indexed a chunk at a time, so only one chunk of
+// vectors is held besides the index. Cancelling ctx aborts the rebuild
+// between chunks; nothing is persisted in that case.
+func (c *Collection) rebuildIndex(ctx context.Context, reason error) error {
+	start := time.Now()
+
+	total := c.storage.Stats().DocumentCount
+	log.Printf("Rebuilding index of collection %s from %d documents: %v", c.name, total, reason)
+
+	index, err := newIndex(c.config)
+	if err != nil {
+		return wrapError("rebuildIndex", c.name, "", err)
+	}
+	docToNode := make(map[string]int, total)
+	nodeToDoc := make(map[int]string, total)
+	if c.text != nil {
+		c.text.clear()
+	}
+
+	step := max((total+rebuildProgressSteps-1)/rebuildProgressSteps, 1)
+	err = c.storage.forEachChunk(rebuildChunkSize, func(docs []*Document) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		vectors := make([][]float32, len(docs))
+		for i, doc := range docs {
+			vectors[i] = doc.Vector
+		}
+		nodeIDs, err := index.AddBatch(vectors)
+		if err != nil {
+			return err
+		}
+
+		before := len(docToNode)
+		for i, doc := range docs {
+			docToNode[doc.ID] = nodeIDs[i]
+			nodeToDoc[nodeIDs[i]] = doc.ID
+			if c.text != nil {
+				c.text.add(doc)
+			}
+		}
+		if done := len(docToNode); done/step > before/step || done == total {
+			log.Printf("Rebuilding index of collection %s: %d/%d documents", c.name, done, total)
+		}
+		return nil
+	})
+	if err != nil {
+		return wrapError("rebuildIndex", c.name, "", err)
+	}
+
+	c.index = index
+	c.docToNode = docToNode
+	c.nodeToDoc = nodeToDoc
+	if err := c.rebuildMetadataIndexes(); err != nil {
+		return wrapError("rebuildIndex", c.name, "", err)
+	}
+
+	// A read-only collection keeps the rebuilt index in memory only
+	if !c.config.ReadOnly {
+		if err := c.save(); err != nil {
+			return fmt.Errorf("persist rebuilt index: %w", err)
+		}
+	}
+
+	c.loadReport = LoadReport{
+		IndexRebuilt:       true,
+		Reason:             reason,
+		DocumentsReindexed: len(docToNode),
+		Duration:           time.Since(start),
+	}
+	log.Printf("Rebuilt index of collection %s: %d documents in %v", c.name, len(docToNode), c.loadReport.Duration)
+	return nil
+}
@@ -0,0 +1,294 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupRebuildDB creates a database with one collection of n random
+// documents, closes it, and returns its path and the query vectors to use
+func setupRebuildDB(t *testing.T, n int, opts ...Option) (string, [][]float32) {
+	t.Helper()
+	path := t.TempDir()
+
+	db, err := Open(path, opts...)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	randomVector := func() []float32 {
+		v := make([]float32, 32)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		return v
+	}
+
+	const batchSize = 1000
+	for start := 0; start < n; start += batchSize {
+		docs := make([]*Document, 0, batchSize)
+		for i := start; i < start+batchSize && i < n; i++ {
+			docs = append(docs, &Document{
+				ID:       fmt.Sprintf("doc_%05d", i),
+				Vector:   randomVector(),
+				Metadata: map[string]interface{}{"index": i},
+			})
+		}
+		if err := coll.InsertBatch(docs); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	queries := make([][]float32, 50)
+	for i := range queries {
+		queries[i] = randomVector()
+	}
+	return path, queries
+}
+
+func rebuildTestOptions() []Option {
+	return []Option{WithDimension(32), WithM(16), WithEfConstruction(100)}
+}
+
+// searchAll returns the top-10 document IDs for every query
+func searchAll(t *testing.T, coll *Collection, queries [][]float32) [][]string {
+	t.Helper()
+	ids := make([][]string, len(queries))
+	for i, q := range queries {
+		results, err := coll.SearchIDs(context.Background(), q, 10, nil)
+		if err != nil {
+			t.Fatalf("SearchIDs failed: %v", err)
+		}
+		for _, r := range results {
+			ids[i] = append(ids[i], r.DocID)
+		}
+	}
+	return ids
+}
+
+func TestRebuildMissingIndex(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping 20k-document rebuild in short mode")
+	}
+
+	const n = 20000
+	path, queries := setupRebuildDB(t, n, rebuildTestOptions()...)
+
+	db, err := Open(path, rebuildTestOptions()...)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if coll.LoadReport().IndexRebuilt {
+		t.Fatal("Intact index should not be rebuilt")
+	}
+	original := searchAll(t, coll, queries)
+	db.Close()
+
+	// Without the save sets there is no saved index left to restore
+	for _, dir := range []string{"index", setsDirName} {
+		if err := os.RemoveAll(filepath.Join(path, "docs", dir)); err != nil {
+			t.Fatalf("Failed to remove %s: %v", dir, err)
+		}
+	}
+
+	db, err = Open(path, rebuildTestOptions()...)
+	if err != nil {
+		t.Fatalf("Open after deleting index failed: %v", err)
+	}
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	report := coll.LoadReport()
+	if !report.IndexRebuilt || report.DocumentsReindexed != n || report.Reason == nil || report.Duration <= 0 {
+		t.Fatalf("Unexpected load report: %+v", report)
+	}
+	if coll.Count() != n {
+		t.Fatalf("Expected %d documents, got %d", n, coll.Count())
+	}
+
+	rebuilt := searchAll(t, coll, queries)
+	var hits, total int
+	for i := range original {
+		want := make(map[string]bool, len(original[i]))
+		for _, id := range original[i] {
+			want[id] = true
+		}
+		for _, id := range rebuilt[i] {
+			if want[id] {
+				hits++
+			}
+		}
+		total += len(original[i])
+	}
+	recall := float64(hits) / float64(total)
+	t.Logf("Rebuilt %d documents in %v, recall vs original %.3f", n, report.Duration, recall)
+	if recall < 0.95 {
+		t.Errorf("Recall of rebuilt index vs original is %.3f, want >= 0.95", recall)
+	}
+	db.Close()
+
+	// The rebuilt index was persisted and loads normally
+	db, err = Open(path, rebuildTestOptions()...)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, err = db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	if coll.LoadReport().IndexRebuilt {
+		t.Error("Persisted rebuilt index should load without another rebuild")
+	}
+}
+
+func TestRebuildCorruptIndex(t *testing.T) {
+	path, queries := setupRebuildDB(t, 500, rebuildTestOptions()...)
+
+	nodesFile := filepath.Join(path, "docs", "index", "nodes.lance")
+	if err := os.WriteFile(nodesFile, []byte("not a lance file"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt index: %v", err)
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		opts := append(rebuildTestOptions(), WithIndexRebuild(false))
+		_, err := Open(path, opts...)
+		if !errors.Is(err, ErrIndexCorrupted) {
+			t.Fatalf("Expected ErrIndexCorrupted, got %v", err)
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := OpenContext(ctx, path, rebuildTestOptions()...)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("Rebuilt", func(t *testing.T) {
+		db, err := Open(path, rebuildTestOptions()...)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer db.Close()
+		coll, err := db.Collection("docs")
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+
+		report := coll.LoadReport()
+		if !report.IndexRebuilt || !errors.Is(report.Reason, ErrIndexCorrupted) || report.DocumentsReindexed != 500 {
+			t.Fatalf("Unexpected load report: %+v", report)
+		}
+		for _, ids := range searchAll(t, coll, queries) {
+			if len(ids) != 10 {
+				t.Fatalf("Expected 10 results after rebuild, got %d", len(ids))
+			}
+		}
+	})
+}
+
+func TestRebuildInChunks(t *testing.T) {
+	path, _ := setupRebuildDB(t, 500, rebuildTestOptions()...)
+
+	// Rewrite some documents so the data file holds stale rows for them
+	db, err := Open(path, rebuildTestOptions()...)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	updated := make(map[string][]float32)
+	for i := 0; i < 500; i += 50 {
+		id := fmt.Sprintf("doc_%05d", i)
+		v := make([]float32, 32)
+		v[i%32] = 1
+		if err := coll.Upsert(&Document{ID: id, Vector: v, Metadata: map[string]interface{}{"index": -i}}); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+		updated[id] = v
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for _, dir := range []string{"index", setsDirName} {
+		if err := os.RemoveAll(filepath.Join(path, "docs", dir)); err != nil {
+			t.Fatalf("Failed to remove %s: %v", dir, err)
+		}
+	}
+
+	rebuildChunkSize = 64
+	t.Cleanup(func() { rebuildChunkSize = 4096 })
+
+	t.Run("CancelledBetweenChunks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reads := 0
+		beforeColumnRead = func() {
+			if reads++; reads == 2 {
+				cancel()
+			}
+		}
+		defer func() { beforeColumnRead = nil }()
+
+		_, err := OpenContext(ctx, path, rebuildTestOptions()...)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+		if reads != 2 {
+			t.Errorf("Expected the rebuild to stop after 2 chunk reads, got %d", reads)
+		}
+	})
+
+	t.Run("Rebuilt", func(t *testing.T) {
+		db, err := Open(path, rebuildTestOptions()...)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer db.Close()
+		coll, err := db.Collection("docs")
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+
+		report := coll.LoadReport()
+		if !report.IndexRebuilt || report.DocumentsReindexed != 500 {
+			t.Fatalf("Unexpected load report: %+v", report)
+		}
+		if coll.Count() != 500 {
+			t.Fatalf("Expected 500 documents, got %d", coll.Count())
+		}
+		for id, v := range updated {
+			results, err := coll.SearchIDs(context.Background(), v, 1, nil)
+			if err != nil {
+				t.Fatalf("SearchIDs failed: %v", err)
+			}
+			if len(results) != 1 || results[0].DocID != id {
+				t.Errorf("Expected %s to be nearest to its updated vector, got %+v", id, results)
+			}
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// insertRandomDocs inserts docs doc_<from>..doc_<to-1> with random vectors
+func insertRandomDocs(t *testing.T, coll *Collection, rng *rand.Rand, from, to int) {
+	t.Helper()
+	docs := make([]*Document, 0, to-from)
+	for i := from; i < to; i++ {
+		v := make([]float32, 32)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		docs = append(docs, &Document{
+			ID:       fmt.Sprintf("doc_%05d", i),
+			Vector:   v,
+			Metadata: map[string]interface{}{"index": i},
+		})
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+}
+
+// openReplica opens path as a writer and a read-only replica and returns
+// the "docs" collection of each
+func openReplica(t *testing.T, path string, readerOpts ...Option) (*Collection, *Collection) {
+	t.Helper()
+
+	writerDB, err := Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open writer failed: %v", err)
+	}
+	t.Cleanup(func() { writerDB.Close() })
+	writer, err := writerDB.Collection("docs")
+	if err != nil {
+		t.Fatalf("Writer Collection failed: %v", err)
+	}
+
+	readerDB, err := Open(path, append([]Option{WithDimension(32), WithReadOnly(true)}, readerOpts...)...)
+	if err != nil {
+		t.Fatalf("Open reader failed: %v", err)
+	}
+	t.Cleanup(func() { readerDB.Close() })
+	reader, err := readerDB.Collection("docs")
+	if err != nil {
+		t.Fatalf("Reader Collection failed: %v", err)
+	}
+	return writer, reader
+}
+
+func TestRefreshSeesWriterSaves(t *testing.T) {
+	path := t.TempDir()
+	rng := rand.New(rand.NewSource(1))
+
+	// The collection must exist before a read-only handle can open it
+	db, err := Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	insertRandomDocs(t, coll, rng, 0, 200)
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	writer, reader := openReplica(t, path)
+	if _, err := writer.Refresh(context.Background()); err == nil {
+		t.Error("Refresh on a writable collection should fail")
+	}
+	if reader.Count() != 200 {
+		t.Fatalf("Expected reader to start with 200 documents, got %d", reader.Count())
+	}
+
+	// Search continuously while the writer saves and the reader refreshes
+	var searches, failures atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			qrng := rand.New(rand.NewSource(seed))
+			query := make([]float32, 32)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for j := range query {
+					query[j] = qrng.Float32()
+				}
+				results, err := reader.SearchContext(context.Background(), query, 5)
+				searches.Add(1)
+				if err != nil || len(results) != 5 {
+					failures.Add(1)
+				}
+			}
+		}(int64(g))
+	}
+
+	n := 200
+	for round := 0; round < 5; round++ {
+		insertRandomDocs(t, writer, rng, n, n+100)
+		n += 100
+
+		// Unsaved changes are not visible to the replica
+		if refreshed, err := reader.Refresh(context.Background()); err != nil || refreshed {
+			t.Fatalf("Refresh before Save: refreshed=%v err=%v", refreshed, err)
+		}
+
+		if err := writer.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		refreshed, err := reader.Refresh(context.Background())
+		if err != nil || !refreshed {
+			t.Fatalf("Refresh after Save: refreshed=%v err=%v", refreshed, err)
+		}
+		if reader.Count() != n {
+			t.Fatalf("Expected %d documents after refresh, got %d", n, reader.Count())
+		}
+		latest := fmt.Sprintf("doc_%05d", n-1)
+		if _, err := reader.Get(latest); err != nil {
+			t.Fatalf("Get %s from refreshed reader failed: %v", latest, err)
+		}
+
+		// Nothing new until the next save
+		if refreshed, err := reader.Refresh(context.Background()); err != nil || refreshed {
+			t.Fatalf("Second Refresh: refreshed=%v err=%v", refreshed, err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+	t.Logf("%d searches during refreshes", searches.Load())
+	if failures.Load() != 0 {
+		t.Errorf("%d of %d searches failed during refreshes", failures.Load(), searches.Load())
+	}
+}
+
+func TestAutoRefresh(t *testing.T) {
+	path := t.TempDir()
+	rng := rand.New(rand.NewSource(2))
+
+	db, err := Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	insertRandomDocs(t, coll, rng, 0, 50)
+	db.Close()
+
+	writer, reader := openReplica(t, path, WithAutoRefresh(10*time.Millisecond))
+	insertRandomDocs(t, writer, rng, 50, 100)
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for reader.Count() != 100 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Auto-refresh did not pick up the save, count is %d", reader.Count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	path := t.TempDir()
+
+	db, err := Open(path, WithDimension(32))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	insertRandomDocs(t, coll, rand.New(rand.NewSource(3)), 0, 10)
+	db.Close()
+
+	roDB, err := Open(path, WithDimension(32), WithReadOnly(true))
+	if err != nil {
+		t.Fatalf("Open read-only failed: %v", err)
+	}
+	defer roDB.Close()
+
+	if _, err := roDB.Collection("missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound for a new collection, got %v", err)
+	}
+
+	ro, err := roDB.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	doc := &Document{ID: "new", Vector: make([]float32, 32)}
+	if err := ro.Insert(doc); !IsReadOnly(err) {
+		t.Errorf("Insert: expected ErrReadOnly, got %v", err)
+	}
+	if err := ro.Delete("doc_00000"); !IsReadOnly(err) {
+		t.Errorf("Delete: expected ErrReadOnly, got %v", err)
+	}
+	if err := ro.Save(); !IsReadOnly(err) {
+		t.Errorf("Save: expected ErrReadOnly, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// defaultReindexBatchSize is the number of documents scanned per batch
+const defaultReindexBatchSize = 1000
+
+// ReindexProgress is reported after each batch of ReindexWhere
+type ReindexProgress struct {
+	Scanned   int // Documents examined so far
+	Total     int // Documents in the collection when the scan started
+	Matched   int // Documents that matched the filter so far
+	Reindexed int // Documents whose vector was replaced so far
+	Failed    int // Documents that failed so far
+}
+
+// ReindexFailure records why a single document could not be re-embedded
+type ReindexFailure struct {
+	ID  string
+	Err error
+}
+
+// ReindexReport summarizes a ReindexWhere run
+type ReindexReport struct {
+	ReindexProgress
+	Failures []ReindexFailure
+}
+
+// ReindexOptions contains options for ReindexWhere
+type ReindexOptions struct {
+	BatchSize int                   // Documents per batch (0 = default 1000)
+	Progress  func(ReindexProgress) // Called after every batch, may be nil
+}
+
+// ReindexOption is a functional option for ReindexWhere
+type ReindexOption func(*ReindexOptions)
+
+// WithReindexBatchSize sets how many documents are processed per batch
+func WithReindexBatchSize(n int) ReindexOption {
+	return func(o *ReindexOptions) {
+		o.BatchSize = n
+	}
+}
+
+// WithReindexProgress registers a callback invoked after every batch
+func WithReindexProgress(fn func(ReindexProgress)) ReindexOption {
+	return func(o *ReindexOptions) {
+		o.Progress = fn
+	}
+}
+
+// ReindexWhere replaces the vectors of documents matching filter with the
+// vectors returned by transform, leaving every other document untouched.
+// Documents are processed in batches; transform runs without holding the
+// collection lock, and each new vector is written to storage and indexed
+// through the same path as Update. A nil filter matches every document. A
+// document written while its new vector was computed keeps that write and
+// is reported as failed with ErrDocumentChanged.
+//
+// Per-document failures (transform errors, wrong dimensions, write errors)
+// are collected in the report instead of aborting the run. If ctx is
+// cancelled, the report covers the batches completed so far and the
+// context's error is returned with it.
+func (c *Collection) ReindexWhere(ctx context.Context, filter Filter, transform func(*Document) ([]float32, error), opts ...ReindexOption) (*ReindexReport, error) {
+	ctx, done, err := c.beginWrite(ctx, "ReindexWhere")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if transform == nil {
+		return nil, wrapError("ReindexWhere", c.name, "", fmt.Errorf("transform is required"))
+	}
+
+	options := &ReindexOptions{BatchSize: defaultReindexBatchSize}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultReindexBatchSize
+	}
+
+	// Snapshot the IDs up front; documents inserted during the run are not
+	// visited and documents deleted during it are skipped
+	c.mu.RLock()
+	ids := make([]string, 0, len(c.docToNode))
+	for id := range c.docToNode {
+		ids = append(ids, id)
+	}
+	c.mu.RUnlock()
+	sort.Strings(ids)
+
+	report := &ReindexReport{}
+	report.Total = len(ids)
+
+	for start := 0; start < len(ids); start += options.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		end := start + options.BatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := c.reindexBatch(ctx, ids[start:end], filter, transform, report); err != nil {
+			return report, err
+		}
+
+		if options.Progress != nil {
+			options.Progress(report.ReindexProgress)
+		}
+	}
+
+	return report, nil
+}
+
+// reindexBatch re-embeds the matching documents among ids and records the
+// outcome in report. It only returns an error when the run must stop
+// (context cancelled or collection closed).
+func (c *Collection) reindexBatch(ctx context.Context, ids []string, filter Filter, transform func(*Document) ([]float32, error), report *ReindexReport) (err error) {
+	docs, err := c.GetBatchContext(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	fail := func(id string, err error) {
+		report.Failures = append(report.Failures, ReindexFailure{ID: id, Err: err})
+		report.Failed++
+	}
+
+	// Compute new vectors outside the lock; transform may be slow
+	updates := make([]*Document, 0, len(docs))
+	for _, id := range ids {
+		report.Scanned++
+
+		doc, ok := docs[id]
+		if !ok {
+			continue // Deleted since the scan started
+		}
+		if filter != nil && !filter.Match(doc) {
+			continue
+		}
+		report.Matched++
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		vector, err := transform(doc.Clone())
+		if err != nil {
+			fail(id, err)
+			continue
+		}
+		if len(vector) != c.dimension {
+			fail(id, fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, c.dimension, len(vector)))
+			continue
+		}
+		if err := c.settings.Constraints.Check(vector); err != nil {
+			fail(id, err)
+			continue
+		}
+
+		updated := doc.Clone()
+		updated.Vector = append([]float32(nil), vector...)
+		updated.Timestamp = time.Now()
+		updates = append(updates, updated)
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	c.lockWrites()
+	defer c.unlockJournaled("ReindexWhere", &err)
+
+	// Documents written since they were read keep the new write: their
+	// vector was computed from the old one
+	updateIDs := make([]string, len(updates))
+	for i, doc := range updates {
+		updateIDs[i] = doc.ID
+	}
+	current, _, err := c.storage.getBatch(updateIDs, true, true)
+	if err != nil {
+		for _, id := range updateIDs {
+			fail(id, err)
+		}
+		return nil
+	}
+
+	for _, doc := range updates {
+		oldNodeID, exists := c.docToNode[doc.ID]
+		if !exists {
+			fail(doc.ID, ErrDocumentNotFound) // Deleted while transforming
+			continue
+		}
+		if now, seen := current[doc.ID], docs[doc.ID]; now == nil ||
+			!vectorsEqual(now.Vector, seen.Vector) || !reflect.DeepEqual(now.Metadata, seen.Metadata) {
+			fail(doc.ID, ErrDocumentChanged)
+			continue
+		}
+
+		// Stored and re-indexed as with Update
+		if err := c.replaceDocument(doc, oldNodeID); err != nil {
+			fail(doc.ID, err)
+			continue
+		}
+		report.Reindexed++
+	}
+
+	return nil
+}
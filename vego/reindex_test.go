@@ -0,0 +1,228 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// setupReindexCollection inserts n documents, every 20th tagged with
+// tenant "legacy" (5%), and returns the original vectors by ID.
+func setupReindexCollection(t *testing.T, n int) (*Collection, map[string][]float32, func()) {
+	t.Helper()
+	coll, cleanup := setupTestCollection(t)
+
+	rng := rand.New(rand.NewSource(1))
+	original := make(map[string][]float32, n)
+	docs := make([]*Document, n)
+	for i := 0; i < n; i++ {
+		tenant := "current"
+		if i%20 == 0 {
+			tenant = "legacy"
+		}
+		vector := make([]float32, 64)
+		for j := range vector {
+			vector[j] = rng.Float32()
+		}
+		id := fmt.Sprintf("doc_%03d", i)
+		original[id] = vector
+		docs[i] = &Document{
+			ID:       id,
+			Vector:   append([]float32(nil), vector...),
+			Metadata: map[string]interface{}{"tenant": tenant, "index": i},
+		}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		cleanup()
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+	return coll, original, cleanup
+}
+
+// reembed derives a new vector far away from the original data
+func reembed(doc *Document) ([]float32, error) {
+	vector := make([]float32, len(doc.Vector))
+	for j, v := range doc.Vector {
+		vector[j] = 10 + v
+	}
+	return vector, nil
+}
+
+func TestReindexWhere(t *testing.T) {
+	coll, original, cleanup := setupReindexCollection(t, 200)
+	defer cleanup()
+
+	filter := &MetadataFilter{Field: "tenant", Operator: "eq", Value: "legacy"}
+
+	var progress []ReindexProgress
+	report, err := coll.ReindexWhere(context.Background(), filter, reembed,
+		WithReindexBatchSize(64),
+		WithReindexProgress(func(p ReindexProgress) { progress = append(progress, p) }))
+	if err != nil {
+		t.Fatalf("ReindexWhere failed: %v", err)
+	}
+
+	if report.Total != 200 || report.Scanned != 200 {
+		t.Errorf("Expected 200 scanned of 200, got %d of %d", report.Scanned, report.Total)
+	}
+	if report.Matched != 10 || report.Reindexed != 10 || report.Failed != 0 {
+		t.Errorf("Expected 10 matched and reindexed, got %+v", report.ReindexProgress)
+	}
+	if len(progress) != 4 {
+		t.Fatalf("Expected 4 progress reports for 200 docs in batches of 64, got %d", len(progress))
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i].Scanned <= progress[i-1].Scanned {
+			t.Errorf("Progress not increasing: %+v then %+v", progress[i-1], progress[i])
+		}
+	}
+
+	if coll.Count() != 200 {
+		t.Errorf("Expected 200 documents, got %d", coll.Count())
+	}
+
+	for id, vector := range original {
+		doc, err := coll.Get(id)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", id, err)
+		}
+		want := vector
+		if doc.Metadata["tenant"] == "legacy" {
+			want, _ = reembed(&Document{Vector: vector})
+		}
+		for j := range want {
+			if doc.Vector[j] != want[j] {
+				t.Fatalf("%s (%v) Vector[%d]: expected %v, got %v", id, doc.Metadata["tenant"], j, want[j], doc.Vector[j])
+			}
+		}
+	}
+
+	// Re-embedded documents are found by their new vectors only; the
+	// others still answer to their original vectors
+	for id, vector := range original {
+		query := vector
+		var i int
+		fmt.Sscanf(id, "doc_%d", &i)
+		if i%20 == 0 {
+			query, _ = reembed(&Document{Vector: vector})
+		}
+		results, err := coll.Search(query, 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if results[0].Document.ID != id {
+			t.Errorf("Expected %s as nearest neighbor, got %s", id, results[0].Document.ID)
+		}
+	}
+
+	results, err := coll.Search(original["doc_000"], 5)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Document.ID == "doc_000" {
+			t.Error("Re-embedded document still found by its old vector")
+		}
+	}
+}
+
+func TestReindexWhereFailureReport(t *testing.T) {
+	coll, original, cleanup := setupReindexCollection(t, 60)
+	defer cleanup()
+
+	errModel := errors.New("model unavailable")
+	transform := func(doc *Document) ([]float32, error) {
+		switch doc.ID {
+		case "doc_020":
+			return nil, errModel
+		case "doc_040":
+			return []float32{1, 2, 3}, nil
+		}
+		return reembed(doc)
+	}
+
+	filter := &MetadataFilter{Field: "tenant", Operator: "eq", Value: "legacy"}
+	report, err := coll.ReindexWhere(context.Background(), filter, transform)
+	if err != nil {
+		t.Fatalf("ReindexWhere failed: %v", err)
+	}
+
+	if report.Matched != 3 || report.Reindexed != 1 || report.Failed != 2 {
+		t.Fatalf("Expected 3 matched, 1 reindexed, 2 failed, got %+v", report.ReindexProgress)
+	}
+	failed := make(map[string]error)
+	for _, f := range report.Failures {
+		failed[f.ID] = f.Err
+	}
+	if !errors.Is(failed["doc_020"], errModel) {
+		t.Errorf("Expected transform error for doc_020, got %v", failed["doc_020"])
+	}
+	if !errors.Is(failed["doc_040"], ErrDimensionMismatch) {
+		t.Errorf("Expected dimension mismatch for doc_040, got %v", failed["doc_040"])
+	}
+
+	// Failed documents keep their original vectors
+	for _, id := range []string{"doc_020", "doc_040"} {
+		doc, err := coll.Get(id)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", id, err)
+		}
+		if doc.Vector[0] != original[id][0] {
+			t.Errorf("%s vector changed despite failure", id)
+		}
+	}
+}
+
+func TestReindexWhereCancellation(t *testing.T) {
+	coll, _, cleanup := setupReindexCollection(t, 100)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	report, err := coll.ReindexWhere(ctx, nil, reembed,
+		WithReindexBatchSize(10),
+		WithReindexProgress(func(p ReindexProgress) {
+			if p.Scanned >= 30 {
+				cancel()
+			}
+		}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if report == nil || report.Reindexed != 30 {
+		t.Fatalf("Expected 30 documents reindexed before cancellation, got %+v", report)
+	}
+}
+
+// TestReindexWhereConcurrentUpdate checks that a document updated while its
+// new vector is computed keeps the update rather than the stale copy
+func TestReindexWhereConcurrentUpdate(t *testing.T) {
+	coll, original, cleanup := setupReindexCollection(t, 40)
+	defer cleanup()
+
+	filter := &MetadataFilter{Field: "tenant", Operator: "eq", Value: "legacy"}
+	report, err := coll.ReindexWhere(context.Background(), filter, func(doc *Document) ([]float32, error) {
+		if doc.ID == "doc_020" {
+			updated := &Document{ID: doc.ID, Vector: original[doc.ID], Metadata: map[string]interface{}{"tenant": "legacy", "note": "edited"}}
+			if err := coll.Update(updated); err != nil {
+				return nil, err
+			}
+		}
+		return reembed(doc)
+	})
+	if err != nil {
+		t.Fatalf("ReindexWhere failed: %v", err)
+	}
+	if report.Reindexed != 1 || report.Failed != 1 || !errors.Is(report.Failures[0].Err, ErrDocumentChanged) {
+		t.Fatalf("Report = %+v", report)
+	}
+
+	doc, err := coll.Get("doc_020")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if doc.Metadata["note"] != "edited" || doc.Vector[0] != original["doc_020"][0] {
+		t.Errorf("Concurrent update lost: metadata %v, vector[0] %v", doc.Metadata, doc.Vector[0])
+	}
+}
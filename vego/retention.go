@@ -0,0 +1,153 @@
+package vego
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// defaultRetentionInterval is how often a retention policy is enforced when
+// Config.RetentionInterval is not set
+const defaultRetentionInterval = time.Minute
+
+// RetentionPolicy bounds how long and how many documents a collection keeps.
+// Either limit may be zero to disable it.
+type RetentionPolicy struct {
+	// MaxAge deletes documents whose timestamp is older than this
+	MaxAge time.Duration
+
+	// MaxDocuments deletes the oldest timestamped documents while the
+	// collection holds more than this many
+	MaxDocuments int64
+
+	// TimestampField names the metadata key holding each document's time,
+	// read with Document.GetTime. Documents without it, or with a value
+	// GetTime cannot read, are never deleted by retention. Empty uses the
+	// built-in Timestamp, set at every write.
+	TimestampField string
+}
+
+// enabled reports whether p limits anything
+func (p RetentionPolicy) enabled() bool {
+	return p.MaxAge > 0 || p.MaxDocuments > 0
+}
+
+// RetentionReport summarizes one ApplyRetention pass
+type RetentionReport struct {
+	Scanned  int      // Documents examined
+	Skipped  int      // Documents without a usable timestamp, left alone
+	Expired  int      // Documents deleted for exceeding MaxAge
+	Trimmed  int      // Documents deleted to get down to MaxDocuments
+	Deleted  []string // IDs of the deleted documents, oldest first
+	Duration time.Duration
+}
+
+// ApplyRetention enforces the collection's RetentionPolicy once: documents
+// older than MaxAge are deleted, then the oldest remaining ones are deleted
+// until at most MaxDocuments are left. Age is measured by TimestampField or
+// the built-in Timestamp; ties are broken by ID, so the outcome does not
+// depend on insertion order. Documents without a timestamp count toward
+// MaxDocuments but are never deleted, so the cap may not be reached.
+//
+// Deletes go through DeleteBatchContext. A document rewritten with a newer
+// timestamp while the pass runs may still be deleted. ApplyRetention does
+// nothing without a policy; collections with one also run it in the
+// background, see WithRetention.
+func (c *Collection) ApplyRetention(ctx context.Context) (*RetentionReport, error) {
+	ctx, done, err := c.beginWrite(ctx, "ApplyRetention")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	policy := c.config.Retention
+	report := &RetentionReport{}
+	if !policy.enabled() {
+		return report, nil
+	}
+	start := time.Now()
+
+	type aged struct {
+		id string
+		at time.Time
+	}
+
+	c.mu.RLock()
+	docs, err := c.storage.allDocuments()
+	total := int64(len(c.docToNode))
+	now := c.now()
+	var candidates []aged
+	if err == nil {
+		for _, doc := range docs {
+			if _, ok := c.docToNode[doc.ID]; !ok {
+				continue
+			}
+			report.Scanned++
+			at := doc.Timestamp
+			ok := !at.IsZero()
+			if policy.TimestampField != "" {
+				at, ok = doc.GetTime(policy.TimestampField)
+			}
+			if !ok {
+				report.Skipped++
+				continue
+			}
+			candidates = append(candidates, aged{id: doc.ID, at: at})
+		}
+	}
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, wrapError("ApplyRetention", c.name, "", err)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].at.Equal(candidates[j].at) {
+			return candidates[i].at.Before(candidates[j].at)
+		}
+		return candidates[i].id < candidates[j].id
+	})
+
+	var ids []string
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge)
+		for _, doc := range candidates {
+			if !doc.at.Before(cutoff) {
+				break
+			}
+			ids = append(ids, doc.id)
+		}
+		report.Expired = len(ids)
+	}
+	if policy.MaxDocuments > 0 {
+		for _, doc := range candidates[len(ids):] {
+			if total-int64(len(ids)) <= policy.MaxDocuments {
+				break
+			}
+			ids = append(ids, doc.id)
+		}
+		report.Trimmed = len(ids) - report.Expired
+	}
+
+	if len(ids) > 0 {
+		if err := c.DeleteBatchContext(ctx, ids); err != nil {
+			return nil, err
+		}
+	}
+	report.Deleted = ids
+	report.Duration = time.Since(start)
+
+	if len(ids) > 0 {
+		log.Printf("Retention deleted %d documents from collection %s (%d expired, %d over limit) in %v",
+			len(ids), c.name, report.Expired, report.Trimmed, report.Duration)
+	}
+	return report, nil
+}
+
+// retentionTask is the background task calling ApplyRetention
+func (c *Collection) retentionTask(ctx context.Context) error {
+	if _, err := c.ApplyRetention(ctx); err != nil && !IsClosed(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// retentionTestCollection opens a collection with policy and a clock fixed
+// at now
+func retentionTestCollection(t *testing.T, path string, policy RetentionPolicy, now time.Time) (*DB, *Collection) {
+	t.Helper()
+	db, err := Open(path, WithDimension(4), WithRetention(policy), WithRetentionInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("telemetry")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	coll.now = func() time.Time { return now }
+	return db, coll
+}
+
+// insertAged inserts one document per entry of ages, named by its index,
+// whose "ts" field is that long before now; a negative age leaves "ts" out
+func insertAged(t *testing.T, coll *Collection, now time.Time, ages []time.Duration) {
+	t.Helper()
+	for i, age := range ages {
+		doc := &Document{
+			ID:       fmt.Sprintf("doc_%d", i),
+			Vector:   []float32{float32(i), 1, 2, 3},
+			Metadata: map[string]interface{}{"n": i},
+		}
+		if age >= 0 {
+			doc.Metadata["ts"] = now.Add(-age)
+		}
+		if err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+}
+
+func applyRetention(t *testing.T, coll *Collection) *RetentionReport {
+	t.Helper()
+	report, err := coll.ApplyRetention(context.Background())
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	return report
+}
+
+func TestRetentionMaxAge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	path := t.TempDir()
+	policy := RetentionPolicy{MaxAge: 30 * day, TimestampField: "ts"}
+	db, coll := retentionTestCollection(t, path, policy, now)
+	insertAged(t, coll, now, []time.Duration{40 * day, day, -1, 31 * day, 29 * day, 90 * day})
+
+	report := applyRetention(t, coll)
+	if want := []string{"doc_5", "doc_0", "doc_3"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("deleted %v, want %v", report.Deleted, want)
+	}
+	if report.Scanned != 6 || report.Skipped != 1 || report.Expired != 3 || report.Trimmed != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if n := coll.Count(); n != 3 {
+		t.Errorf("Count = %d, want 3", n)
+	}
+	if _, err := coll.Get("doc_2"); err != nil {
+		t.Errorf("document without a timestamp was deleted: %v", err)
+	}
+
+	// Timestamps read back as strings after a reload still count, and time
+	// moving on expires more
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	db.Close()
+	_, coll = retentionTestCollection(t, path, policy, now.Add(2*day))
+	report = applyRetention(t, coll)
+	if want := []string{"doc_4"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("after reload deleted %v, want %v", report.Deleted, want)
+	}
+	results, err := coll.SearchContext(context.Background(), []float32{4, 1, 2, 3}, 10)
+	if err != nil || len(results) != 2 {
+		t.Errorf("SearchContext = %v, %v; want the 2 remaining documents", results, err)
+	}
+}
+
+func TestRetentionBuiltInTimestamp(t *testing.T) {
+	_, coll := retentionTestCollection(t, t.TempDir(), RetentionPolicy{MaxAge: time.Hour}, time.Now())
+	insertAged(t, coll, time.Now(), []time.Duration{-1, -1})
+	old, err := coll.Get("doc_1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := coll.Insert(&Document{ID: "new", Vector: []float32{1, 1, 1, 1}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// An hour after the old writes, the new one is just young enough
+	cutoff := old.Timestamp.Add(time.Nanosecond)
+	coll.now = func() time.Time { return cutoff.Add(time.Hour) }
+	report := applyRetention(t, coll)
+	if want := []string{"doc_0", "doc_1"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("deleted %v, want %v", report.Deleted, want)
+	}
+	if _, err := coll.Get("new"); err != nil {
+		t.Errorf("Get new failed: %v", err)
+	}
+}
+
+func TestRetentionMaxDocuments(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	policy := RetentionPolicy{MaxDocuments: 3, TimestampField: "ts"}
+	_, coll := retentionTestCollection(t, t.TempDir(), policy, now)
+
+	// Inserted newest first, so insertion order is the reverse of age
+	insertAged(t, coll, now, []time.Duration{time.Minute, time.Hour, 2 * time.Hour, 3 * time.Hour, 3 * time.Hour})
+	report := applyRetention(t, coll)
+	if want := []string{"doc_3", "doc_4"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("deleted %v, want %v", report.Deleted, want)
+	}
+	if report.Trimmed != 2 || report.Expired != 0 || coll.Count() != 3 {
+		t.Errorf("report = %+v, Count = %d", report, coll.Count())
+	}
+
+	// Nothing more to do at the cap
+	if report := applyRetention(t, coll); len(report.Deleted) != 0 {
+		t.Errorf("second pass deleted %v", report.Deleted)
+	}
+
+	// Documents without a timestamp count toward the cap but are kept, even
+	// when that leaves the collection over it
+	for i := 0; i < 3; i++ {
+		doc := &Document{ID: fmt.Sprintf("untimed_%d", i), Vector: []float32{1, 2, 3, 4}}
+		if err := coll.Insert(doc); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := coll.Insert(&Document{ID: "bad_ts", Vector: []float32{1, 2, 3, 4}, Metadata: map[string]interface{}{"ts": "yesterday"}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	report = applyRetention(t, coll)
+	if want := []string{"doc_2", "doc_1", "doc_0"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("deleted %v, want %v", report.Deleted, want)
+	}
+	if report.Skipped != 4 || coll.Count() != 4 {
+		t.Errorf("report = %+v, Count = %d; want the 4 documents without a timestamp kept", report, coll.Count())
+	}
+}
+
+func TestRetentionCombined(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	policy := RetentionPolicy{MaxAge: 10 * time.Hour, MaxDocuments: 2, TimestampField: "ts"}
+	_, coll := retentionTestCollection(t, t.TempDir(), policy, now)
+	insertAged(t, coll, now, []time.Duration{time.Hour, 20 * time.Hour, 2 * time.Hour, 3 * time.Hour})
+
+	report := applyRetention(t, coll)
+	if want := []string{"doc_1", "doc_3"}; !reflect.DeepEqual(report.Deleted, want) {
+		t.Errorf("deleted %v, want %v", report.Deleted, want)
+	}
+	if report.Expired != 1 || report.Trimmed != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestRetentionBackground(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(4),
+		WithRetention(RetentionPolicy{MaxDocuments: 2}), WithRetentionInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("telemetry")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	insertAged(t, coll, time.Now(), []time.Duration{-1, -1, -1, -1, -1})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for coll.Count() > 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := coll.Count(); n != 2 {
+		t.Fatalf("Count = %d after background retention, want 2", n)
+	}
+	for _, id := range []string{"doc_3", "doc_4"} {
+		if _, err := coll.Get(id); err != nil {
+			t.Errorf("newest document %s was deleted: %v", id, err)
+		}
+	}
+}
+
+func TestRetentionDisabled(t *testing.T) {
+	coll := getTestCollection(t, 5, 5)
+	report := applyRetention(t, coll)
+	if report.Scanned != 0 || len(report.Deleted) != 0 || coll.Count() != 10 {
+		t.Errorf("report = %+v, Count = %d", report, coll.Count())
+	}
+}
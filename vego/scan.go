@@ -0,0 +1,239 @@
+package vego
+
+import "context"
+
+// defaultScanBatchSize is the number of documents a scan reads per batch
+const defaultScanBatchSize = 1000
+
+// ScanOptions contains options for Scan and ScanWithFilter
+type ScanOptions struct {
+	BatchSize int // Documents read per batch (0 = default 1000)
+}
+
+// ScanOption is a functional option for Scan and ScanWithFilter
+type ScanOption func(*ScanOptions)
+
+// WithScanBatchSize sets how many documents a scan reads from storage at a
+// time. Each batch reads the vector column once and holds the collection's
+// read lock only while it is read.
+func WithScanBatchSize(n int) ScanOption {
+	return func(o *ScanOptions) {
+		o.BatchSize = n
+	}
+}
+
+// scanPart is the rest of a scan over one collection, or one shard
+type scanPart struct {
+	coll *Collection
+	ids  []string
+}
+
+// DocumentIterator yields the documents of a Scan or ScanWithFilter:
+//
+//	it, err := coll.Scan(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	for it.Next() {
+//		doc := it.Document()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//
+// An iterator must not be used from several goroutines at a time. It holds
+// no locks between calls to Next, so it needs no closing and may be
+// abandoned at any point.
+type DocumentIterator struct {
+	ctx       context.Context
+	filter    Filter
+	batchSize int
+	parts     []scanPart
+	batch     []*Document
+	doc       *Document
+	err       error
+}
+
+// Scan returns an iterator over every document in the collection, in
+// storage order: documents in the order they were flushed, then the ones
+// still buffered. A sharded collection is scanned shard by shard; shards
+// that failed to open are skipped.
+//
+// The IDs to visit are snapshotted when Scan is called and the documents
+// are then read in batches (see WithScanBatchSize) without holding the
+// collection lock in between, so writes proceed during the scan. Documents
+// inserted after Scan returns are not visited, documents deleted before
+// their batch is read are skipped, and a document updated before its batch
+// is read is yielded in its updated version. Cancelling ctx or closing the
+// collection ends the iteration with that error in Err.
+func (c *Collection) Scan(ctx context.Context, opts ...ScanOption) (*DocumentIterator, error) {
+	return c.scan(ctx, "Scan", nil, opts)
+}
+
+// ScanWithFilter is Scan yielding only the documents that match filter. The
+// filter is evaluated on metadata alone, as in SearchWithFilter, so the
+// vectors of documents that do not match are never read. A nil filter
+// matches every document.
+func (c *Collection) ScanWithFilter(ctx context.Context, filter Filter, opts ...ScanOption) (*DocumentIterator, error) {
+	return c.scan(ctx, "ScanWithFilter", filter, opts)
+}
+
+func (c *Collection) scan(ctx context.Context, op string, filter Filter, opts []ScanOption) (*DocumentIterator, error) {
+	// The iterator reads with the caller's context: the operation's own
+	// context ends when scan returns
+	opCtx, done, err := c.begin(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	options := &ScanOptions{BatchSize: defaultScanBatchSize}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultScanBatchSize
+	}
+
+	it := &DocumentIterator{ctx: ctx, filter: filter, batchSize: options.BatchSize}
+	colls := []*Collection{c}
+	if c.shards != nil {
+		colls = c.shards
+	}
+	for _, coll := range colls {
+		if coll == nil {
+			continue // Failed to open
+		}
+		if err := opCtx.Err(); err != nil {
+			return nil, err
+		}
+		ids, err := coll.scanIDs()
+		if err != nil {
+			return nil, wrapError(op, c.name, "", err)
+		}
+		if len(ids) > 0 {
+			it.parts = append(it.parts, scanPart{coll: coll, ids: ids})
+		}
+	}
+	return it, nil
+}
+
+// scanIDs snapshots the IDs of the collection's documents in storage order.
+// The read lock is only held while the indexed IDs are copied; storage is
+// read after, dropping documents inserted or deleted meanwhile.
+func (c *Collection) scanIDs() ([]string, error) {
+	c.mu.RLock()
+	indexed := make(map[string]struct{}, len(c.docToNode))
+	for id := range c.docToNode {
+		indexed[id] = struct{}{}
+	}
+	c.mu.RUnlock()
+
+	order, err := c.storage.scanOrder()
+	if err != nil {
+		return nil, err
+	}
+	ids := order[:0]
+	for _, id := range order {
+		if _, ok := indexed[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Next advances to the next document, reading the next batch from storage
+// when the current one is used up. It returns false when the scan is done
+// or failed; Err tells the two apart.
+func (it *DocumentIterator) Next() bool {
+	for len(it.batch) == 0 {
+		if it.err != nil || len(it.parts) == 0 {
+			it.doc = nil
+			return false
+		}
+		it.batch, it.err = it.fetch()
+	}
+	it.doc, it.batch = it.batch[0], it.batch[1:]
+	return true
+}
+
+// Document returns the current document. It is a copy the caller may
+// modify.
+func (it *DocumentIterator) Document() *Document {
+	return it.doc
+}
+
+// Err returns the error that ended the iteration, or nil if every document
+// was visited
+func (it *DocumentIterator) Err() error {
+	return it.err
+}
+
+// fetch reads the next batch of the first remaining part
+func (it *DocumentIterator) fetch() ([]*Document, error) {
+	part := &it.parts[0]
+	n := min(it.batchSize, len(part.ids))
+	ids := part.ids[:n]
+	coll := part.coll
+	if part.ids = part.ids[n:]; len(part.ids) == 0 {
+		it.parts = it.parts[1:]
+	}
+	return coll.scanBatch(it.ctx, ids, it.filter)
+}
+
+// scanBatch reads the documents among ids that still exist and match
+// filter, in the order of ids
+func (c *Collection) scanBatch(ctx context.Context, ids []string, filter Filter) ([]*Document, error) {
+	ctx, done, err := c.begin(ctx, "Scan")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Match on metadata first so only the matching vectors are read
+	if filter != nil {
+		found, _, err := c.storage.getBatch(ids, false, true)
+		if err != nil {
+			return nil, wrapError("Scan", c.name, "", err)
+		}
+		matched := make([]string, 0, len(found))
+		for _, id := range ids {
+			if doc, ok := found[id]; ok && filter.Match(doc) {
+				matched = append(matched, id)
+			}
+		}
+		if ids = matched; len(ids) == 0 {
+			return nil, nil
+		}
+	}
+
+	found, _, err := c.storage.getBatch(ids, true, true)
+	if err != nil {
+		return nil, wrapError("Scan", c.name, "", err)
+	}
+	docs := make([]*Document, 0, len(found))
+	for _, id := range ids {
+		doc, ok := found[id]
+		if !ok {
+			continue // Deleted since the scan started
+		}
+		// Flushed documents share their metadata map with storage
+		if doc.Metadata != nil {
+			metadata := make(map[string]interface{}, len(doc.Metadata))
+			for k, v := range doc.Metadata {
+				metadata[k] = v
+			}
+			doc.Metadata = metadata
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
@@ -0,0 +1,198 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// scanAll drains it, returning the documents in the order yielded
+func scanAll(t *testing.T, it *DocumentIterator) []*Document {
+	t.Helper()
+	var docs []*Document
+	for it.Next() {
+		docs = append(docs, it.Document())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	return docs
+}
+
+func TestScan(t *testing.T) {
+	coll, original, cleanup := setupReindexCollection(t, 200)
+	defer cleanup()
+
+	// Flushed documents come first, then the buffered ones
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("extra_%d", i)
+		vector := make([]float32, 64)
+		vector[i] = 1
+		original[id] = vector
+		if err := coll.Insert(&Document{ID: id, Vector: vector}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	it, err := coll.Scan(context.Background(), WithScanBatchSize(7))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	docs := scanAll(t, it)
+	if len(docs) != 205 {
+		t.Fatalf("scanned %d documents, want 205", len(docs))
+	}
+	for i, doc := range docs {
+		want := fmt.Sprintf("doc_%03d", i)
+		if i >= 200 {
+			want = fmt.Sprintf("extra_%d", i-200)
+		}
+		if doc.ID != want {
+			t.Fatalf("document %d is %s, want %s", i, doc.ID, want)
+		}
+		if !vectorsEqual(doc.Vector, original[doc.ID]) {
+			t.Fatalf("document %s has the wrong vector", doc.ID)
+		}
+	}
+
+	// Scanned documents are copies
+	docs[0].Metadata["tenant"] = "changed"
+	if doc, _ := coll.Get("doc_000"); doc.Metadata["tenant"] != "legacy" {
+		t.Errorf("modifying a scanned document changed the stored one: %v", doc.Metadata)
+	}
+}
+
+func TestScanWithFilter(t *testing.T) {
+	coll, _, cleanup := setupReindexCollection(t, 200)
+	defer cleanup()
+
+	filter := &MetadataFilter{Field: "tenant", Operator: "eq", Value: "legacy"}
+	it, err := coll.ScanWithFilter(context.Background(), filter, WithScanBatchSize(30))
+	if err != nil {
+		t.Fatalf("ScanWithFilter failed: %v", err)
+	}
+	docs := scanAll(t, it)
+	if len(docs) != 10 {
+		t.Fatalf("scanned %d documents, want 10", len(docs))
+	}
+	for _, doc := range docs {
+		if doc.Metadata["tenant"] != "legacy" || len(doc.Vector) != 64 {
+			t.Errorf("unexpected document %s: %v", doc.ID, doc.Metadata)
+		}
+	}
+}
+
+func TestScanConcurrentWrites(t *testing.T) {
+	coll, _, cleanup := setupReindexCollection(t, 100)
+	defer cleanup()
+
+	it, err := coll.Scan(context.Background(), WithScanBatchSize(10))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	// Writes between batches go through: the scan holds no lock. Inserted
+	// documents are not visited, deleted ones are skipped and updated ones
+	// are yielded as updated.
+	seen := make(map[string]*Document)
+	for it.Next() {
+		doc := it.Document()
+		seen[doc.ID] = doc
+		if doc.ID != "doc_000" {
+			continue
+		}
+		if err := coll.Insert(&Document{ID: "late", Vector: make([]float32, 64)}); err != nil {
+			t.Fatalf("Insert during scan failed: %v", err)
+		}
+		if err := coll.Delete("doc_050"); err != nil {
+			t.Fatalf("Delete during scan failed: %v", err)
+		}
+		updated, _ := coll.Get("doc_060")
+		updated.Metadata["tenant"] = "updated"
+		if err := coll.Update(updated); err != nil {
+			t.Fatalf("Update during scan failed: %v", err)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+
+	if len(seen) != 99 {
+		t.Errorf("scanned %d documents, want 99", len(seen))
+	}
+	if _, ok := seen["late"]; ok {
+		t.Error("scan visited a document inserted after it started")
+	}
+	if _, ok := seen["doc_050"]; ok {
+		t.Error("scan visited a deleted document")
+	}
+	if doc := seen["doc_060"]; doc == nil || doc.Metadata["tenant"] != "updated" {
+		t.Errorf("updated document scanned as %v", doc)
+	}
+}
+
+func TestScanCancelled(t *testing.T) {
+	coll, _, cleanup := setupReindexCollection(t, 100)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it, err := coll.Scan(ctx, WithScanBatchSize(10))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	n := 0
+	for it.Next() {
+		if n++; n == 15 {
+			cancel()
+		}
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("Err = %v, want context.Canceled", it.Err())
+	}
+	if n != 20 {
+		t.Errorf("scanned %d documents after cancelling mid-batch, want 20", n)
+	}
+
+	// A closed collection ends the scan too
+	it, _ = coll.Scan(context.Background())
+	coll.Close()
+	if it.Next() || !errors.Is(it.Err(), ErrCollectionClosed) {
+		t.Errorf("scan of closed collection: Err = %v", it.Err())
+	}
+}
+
+func TestScanSharded(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(8), WithShards(3, nil))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("sharded")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+	docs := shardTestDocs(rand.New(rand.NewSource(1)), 50, 8)
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	it, err := coll.Scan(context.Background(), WithScanBatchSize(4))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, doc := range scanAll(t, it) {
+		seen[doc.ID] = true
+	}
+	for _, doc := range docs {
+		if !seen[doc.ID] {
+			t.Errorf("document %s not scanned", doc.ID)
+		}
+	}
+}
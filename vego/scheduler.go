@@ -0,0 +1,334 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultBackgroundWorkers is the size of a DB's background pool when
+// Config.BackgroundWorkers is not set
+const defaultBackgroundWorkers = 4
+
+// BackgroundTask describes a periodic task of a collection, as reported by
+// DB.BackgroundTasks
+type BackgroundTask struct {
+	Collection string        // Collection the task belongs to
+	Name       string        // Task name, e.g. "orphan sweep"
+	Interval   time.Duration // Time between runs, before jitter
+	Runs       int           // Completed runs
+	Running    bool          // Whether a worker is running it now
+	LastRun    time.Time     // When the last run finished, zero if none has
+	LastError  error         // Error or panic of the last run, nil if it succeeded
+	NextRun    time.Time     // When it is due next
+
+	// Tasks of the maintenance window only, nil for periodic tasks; Runs
+	// counts their chunks. See WithMaintenanceWindow.
+	Maintenance *MaintenanceStatus
+}
+
+// scheduledTask is a periodic task registered with a scheduler. Fields
+// below run are guarded by scheduler.mu.
+type scheduledTask struct {
+	collection string
+	name       string
+	interval   time.Duration
+	jitter     time.Duration
+	run        func(ctx context.Context) error
+	ctx        context.Context // Passed to run, cancelled when the task is removed
+	cancel     context.CancelFunc
+
+	nextRun time.Time
+	lastRun time.Time
+	lastErr error
+	runs    int
+	running bool
+	removed bool
+	again   bool // Triggered while running, see trigger
+}
+
+// scheduler runs the periodic tasks of many collections on a bounded pool
+// of workers, so background features cost no goroutines per collection.
+// Its goroutines start with the first task and stop at close.
+type scheduler struct {
+	workers int
+
+	mu      sync.Mutex
+	idle    *sync.Cond // Signalled whenever a run finishes
+	tasks   map[*scheduledTask]struct{}
+	started bool
+	closed  bool
+
+	wake   chan struct{}       // Nudges the dispatcher after tasks change
+	work   chan *scheduledTask // Due tasks handed to workers
+	ctx    context.Context     // Parent of the tasks' contexts, cancelled by close
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newScheduler returns a scheduler running at most workers tasks at once
+func newScheduler(workers int) *scheduler {
+	if workers <= 0 {
+		workers = defaultBackgroundWorkers
+	}
+	s := &scheduler{
+		workers: workers,
+		tasks:   make(map[*scheduledTask]struct{}),
+		wake:    make(chan struct{}, 1),
+		work:    make(chan *scheduledTask),
+	}
+	s.idle = sync.NewCond(&s.mu)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// schedule registers run to be called every interval, delayed by up to
+// jitter so tasks registered together spread out. It returns nil if the
+// scheduler is closed.
+func (s *scheduler) schedule(collection, name string, interval, jitter time.Duration, run func(ctx context.Context) error) *scheduledTask {
+	t := &scheduledTask{
+		collection: collection,
+		name:       name,
+		interval:   interval,
+		jitter:     jitter,
+		run:        run,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	t.ctx, t.cancel = context.WithCancel(s.ctx)
+	t.nextRun = t.next(time.Now())
+	s.tasks[t] = struct{}{}
+	if !s.started {
+		s.started = true
+		s.wg.Add(1 + s.workers)
+		go s.dispatch()
+		for i := 0; i < s.workers; i++ {
+			go s.worker()
+		}
+	}
+	s.nudge()
+	return t
+}
+
+// next returns when t is due after a run at now
+func (t *scheduledTask) next(now time.Time) time.Time {
+	delay := t.interval
+	if t.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(t.jitter)))
+	}
+	return now.Add(delay)
+}
+
+// remove unregisters tasks, cancels the contexts of those running and waits
+// for them to finish
+func (s *scheduler) remove(tasks []*scheduledTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tasks {
+		t.removed = true
+		t.cancel()
+		delete(s.tasks, t)
+		for t.running {
+			s.idle.Wait()
+		}
+	}
+	s.nudge()
+}
+
+// trigger makes t due now rather than at its next run. A task triggered
+// while running runs once more as soon as it finishes, however often it was
+// triggered meanwhile.
+func (s *scheduler) trigger(t *scheduledTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case t.removed:
+	case t.running:
+		t.again = true
+	default:
+		t.nextRun = time.Now()
+		s.nudge()
+	}
+}
+
+// close cancels running tasks' contexts, waits for them and stops the
+// scheduler's goroutines. Tasks cannot be registered afterwards.
+func (s *scheduler) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for t := range s.tasks {
+		t.removed = true
+	}
+	s.tasks = make(map[*scheduledTask]struct{})
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+}
+
+// nudge wakes the dispatcher. s.mu must be held.
+func (s *scheduler) nudge() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands due tasks to workers in order of their next run. A task
+// is not handed out again while it is running, so runs of one task never
+// overlap.
+func (s *scheduler) dispatch() {
+	defer s.wg.Done()
+	defer close(s.work)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		var due *scheduledTask
+		for t := range s.tasks {
+			if !t.running && (due == nil || t.nextRun.Before(due.nextRun)) {
+				due = t
+			}
+		}
+		wait := time.Hour
+		if due != nil {
+			wait = time.Until(due.nextRun)
+		}
+		if due != nil && wait <= 0 {
+			due.running = true
+		}
+		s.mu.Unlock()
+
+		if due != nil && wait <= 0 {
+			select {
+			case s.work <- due:
+				continue
+			case <-s.ctx.Done():
+				s.finish(due, nil, false)
+				return
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// worker runs the tasks handed out by dispatch until it stops
+func (s *scheduler) worker() {
+	defer s.wg.Done()
+	for t := range s.work {
+		// A task removed while waiting for a worker is dropped
+		s.mu.Lock()
+		removed := t.removed
+		s.mu.Unlock()
+		if removed {
+			s.finish(t, nil, false)
+			continue
+		}
+		s.finish(t, s.runTask(t), true)
+	}
+}
+
+// finish marks t as no longer running, recording the outcome of a run if
+// ran is set, and wakes those waiting for it
+func (s *scheduler) finish(t *scheduledTask, err error, ran bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.running = false
+	if ran {
+		now := time.Now()
+		t.lastRun = now
+		t.lastErr = err
+		t.runs++
+		t.nextRun = t.next(now)
+		if t.again {
+			t.nextRun = now
+			t.again = false
+		}
+	}
+	s.idle.Broadcast()
+	s.nudge()
+}
+
+// runTask runs t once and logs its error. A panic is logged and returned as
+// an error, so it only costs this run of this task.
+func (s *scheduler) runTask(t *scheduledTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			log.Printf("Warning: %s of collection %s panicked: %v", t.name, t.collection, r)
+		}
+	}()
+
+	// Runs cut short by removal or close are not worth a warning
+	if err := t.run(t.ctx); err != nil {
+		if t.ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Warning: %s of collection %s failed: %v", t.name, t.collection, err)
+		return err
+	}
+	return nil
+}
+
+// list returns the registered tasks sorted by collection and name
+func (s *scheduler) list() []BackgroundTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := make([]BackgroundTask, 0, len(s.tasks))
+	for t := range s.tasks {
+		tasks = append(tasks, BackgroundTask{
+			Collection: t.collection,
+			Name:       t.name,
+			Interval:   t.interval,
+			Runs:       t.runs,
+			Running:    t.running,
+			LastRun:    t.lastRun,
+			LastError:  t.lastErr,
+			NextRun:    t.nextRun,
+		})
+	}
+	sortTasks(tasks)
+	return tasks
+}
+
+// sortTasks sorts tasks by collection and name, periodic tasks before
+// maintenance tasks of the same name
+func sortTasks(tasks []BackgroundTask) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Collection != tasks[j].Collection {
+			return tasks[i].Collection < tasks[j].Collection
+		}
+		if tasks[i].Name != tasks[j].Name {
+			return tasks[i].Name < tasks[j].Name
+		}
+		return tasks[i].Maintenance == nil && tasks[j].Maintenance != nil
+	})
+}
@@ -0,0 +1,243 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// waitFor polls cond until it holds or timeout passes
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+func TestBackgroundPoolBound(t *testing.T) {
+	const workers = 3
+	const collections = 200
+	db, err := Open(t.TempDir(), WithDimension(4), WithBackgroundWorkers(workers))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	var active, maxActive atomic.Int32
+	var ran sync.Map
+	for i := 0; i < collections; i++ {
+		coll, err := db.Collection(fmt.Sprintf("coll_%03d", i))
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+		name := coll.name
+		coll.schedule("probe", 10*time.Millisecond, func(context.Context) error {
+			n := active.Add(1)
+			for {
+				m := maxActive.Load()
+				if n <= m || maxActive.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			active.Add(-1)
+			ran.Store(name, true)
+			return nil
+		})
+	}
+
+	allRan := waitFor(20*time.Second, func() bool {
+		n := 0
+		ran.Range(func(any, any) bool { n++; return true })
+		return n == collections
+	})
+	if !allRan {
+		t.Fatal("not every collection's task ran")
+	}
+	if m := maxActive.Load(); m > workers {
+		t.Errorf("%d tasks ran at once, want at most %d", m, workers)
+	}
+
+	tasks := db.BackgroundTasks()
+	if len(tasks) != collections {
+		t.Fatalf("BackgroundTasks listed %d tasks, want %d", len(tasks), collections)
+	}
+	for _, task := range tasks {
+		if task.Name != "probe" || task.Interval != 10*time.Millisecond || task.NextRun.IsZero() {
+			t.Errorf("unexpected task %+v", task)
+		}
+	}
+	if tasks[0].Collection != "coll_000" || tasks[collections-1].Collection != "coll_199" {
+		t.Errorf("tasks not sorted by collection: first %s, last %s", tasks[0].Collection, tasks[collections-1].Collection)
+	}
+}
+
+func TestBackgroundTaskPanic(t *testing.T) {
+	db, err := Open(t.TempDir(), WithDimension(4), WithBackgroundWorkers(1))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	var panics, runs atomic.Int32
+	coll.schedule("panicking", 5*time.Millisecond, func(context.Context) error {
+		panics.Add(1)
+		panic("task bug")
+	})
+	coll.schedule("healthy", 5*time.Millisecond, func(context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+
+	// The panicking task is rescheduled and the other keeps running on the
+	// same single worker
+	if !waitFor(10*time.Second, func() bool { return panics.Load() >= 3 && runs.Load() >= 3 }) {
+		t.Fatalf("%d panics and %d healthy runs", panics.Load(), runs.Load())
+	}
+	for _, task := range db.BackgroundTasks() {
+		switch task.Name {
+		case "panicking":
+			if task.LastError == nil || !strings.Contains(task.LastError.Error(), "task bug") || task.Runs < 3 {
+				t.Errorf("panicking task = %+v", task)
+			}
+		case "healthy":
+			if task.LastError != nil || task.LastRun.IsZero() {
+				t.Errorf("healthy task = %+v", task)
+			}
+		}
+	}
+
+	// The collection still works
+	if err := coll.Insert(&Document{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Errorf("Insert failed: %v", err)
+	}
+}
+
+func TestBackgroundClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	path := t.TempDir()
+
+	db, err := Open(path, WithDimension(4), WithBackgroundWorkers(2),
+		WithOrphanSweepInterval(time.Millisecond),
+		WithAutoOptimize(time.Millisecond, hnsw.OptimizeOptions{MaxNodes: 10}),
+		WithRetention(RetentionPolicy{MaxDocuments: 100}), WithRetentionInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	var names []string
+	for i := 0; i < 5; i++ {
+		coll, err := db.Collection(fmt.Sprintf("coll_%d", i))
+		if err != nil {
+			t.Fatalf("Collection failed: %v", err)
+		}
+		if err := coll.Insert(&Document{ID: "a", Vector: []float32{1, 2, 3, 4}}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		names = append(names, coll.name)
+	}
+
+	// A task still running at Close has its context cancelled
+	blocking, _ := db.Collection("coll_0")
+	started := make(chan struct{})
+	var once sync.Once
+	blocking.schedule("blocking", time.Millisecond, func(ctx context.Context) error {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+
+	tasks := db.BackgroundTasks()
+	if len(tasks) != 3*len(names)+1 {
+		t.Errorf("BackgroundTasks listed %d tasks, want %d", len(tasks), 3*len(names)+1)
+	}
+	for _, task := range tasks[:4] {
+		if task.Collection != "coll_0" {
+			t.Errorf("unexpected task %+v", task)
+		}
+	}
+	if got := []string{tasks[0].Name, tasks[1].Name, tasks[2].Name, tasks[3].Name}; strings.Join(got, ",") != "blocking,optimize,orphan sweep,retention" {
+		t.Errorf("coll_0 tasks = %v", got)
+	}
+
+	// Dropping a collection stops its tasks
+	if err := db.DropCollection("coll_4"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if n := len(db.BackgroundTasks()); n != 3*(len(names)-1)+1 {
+		t.Errorf("%d tasks after DropCollection, want %d", n, 3*(len(names)-1)+1)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if tasks := db.BackgroundTasks(); len(tasks) != 0 {
+		t.Errorf("%d tasks left after Close", len(tasks))
+	}
+
+	// A collection opened on its own runs its tasks on a pool it stops at
+	// Close
+	config := DefaultConfig()
+	config.Dimension = 4
+	config.OrphanSweepInterval = time.Millisecond
+	coll, err := NewCollection("standalone", filepath.Join(path, "standalone"), config)
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	if err := coll.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if !waitFor(5*time.Second, func() bool { return runtime.NumGoroutine() <= before }) {
+		buf := make([]byte, 1<<16)
+		t.Errorf("%d goroutines after Close, %d before:\n%s", runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestBackgroundTrigger(t *testing.T) {
+	s := newScheduler(2)
+	defer s.close()
+
+	var runs atomic.Int32
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	task := s.schedule("coll", "probe", time.Hour, 0, func(context.Context) error {
+		runs.Add(1)
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	// Triggered long before it is due, it runs now
+	s.trigger(task)
+	<-started
+
+	// Triggers while it runs coalesce into one more run
+	for i := 0; i < 5; i++ {
+		s.trigger(task)
+	}
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+
+	time.Sleep(50 * time.Millisecond)
+	if n := runs.Load(); n != 2 {
+		t.Errorf("task ran %d times, want 2", n)
+	}
+	close(release)
+}
@@ -0,0 +1,81 @@
+package vego
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultBatchConcurrency is how many queries of a batch search run at once
+const defaultBatchConcurrency = 4
+
+// BatchOptions contains options for SearchBatchContext
+type BatchOptions struct {
+	MaxConcurrency int            // Queries searched at once (0 = default 4)
+	Search         []SearchOption // Applied to every query
+}
+
+// BatchOption is a functional option for SearchBatchContext
+type BatchOption func(*BatchOptions)
+
+// WithMaxConcurrency caps how many queries of a batch are searched at once,
+// and so the goroutines the batch starts
+func WithMaxConcurrency(n int) BatchOption {
+	return func(o *BatchOptions) {
+		o.MaxConcurrency = n
+	}
+}
+
+// WithBatchSearchOptions applies search options, such as WithEF or
+// WithVectors, to every query of a batch
+func WithBatchSearchOptions(opts ...SearchOption) BatchOption {
+	return func(o *BatchOptions) {
+		o.Search = append(o.Search, opts...)
+	}
+}
+
+// SearchBatchContext runs SearchContext for each of queries in parallel and
+// returns the results and error of query i in slot i of each slice; one
+// query failing, for example on its dimension, does not affect the others.
+//
+// Once ctx ends, queries not yet started are abandoned with ctx.Err() in
+// their error slots, and those being searched stop as SearchContext does.
+func (c *Collection) SearchBatchContext(ctx context.Context, queries [][]float32, k int, opts ...BatchOption) ([][]SearchResult, []error) {
+	options := &BatchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	workers := options.MaxConcurrency
+	if workers <= 0 {
+		workers = defaultBatchConcurrency
+	}
+	return c.searchBatch(ctx, queries, k, workers, options.Search)
+}
+
+// searchBatch searches queries with the given number of workers
+func (c *Collection) searchBatch(ctx context.Context, queries [][]float32, k, workers int, opts []SearchOption) ([][]SearchResult, []error) {
+	results := make([][]SearchResult, len(queries))
+	errs := make([]error, len(queries))
+
+	jobs := make(chan int, len(queries))
+	for i := range queries {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(queries)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				results[i], errs[i] = c.SearchContext(ctx, queries[i], k, opts...)
+			}
+		}()
+	}
+	wg.Wait()
+	return results, errs
+}
@@ -0,0 +1,194 @@
+package vego
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+	"github.com/wzqhbustb/vego/storage/encoding"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
+)
+
+const (
+	// settingsFileName stores the storage settings a collection was created with
+	settingsFileName = "settings.json"
+
+	// defaultCompressionLevel is used when Config.CompressionLevel is 0
+	defaultCompressionLevel = 3
+)
+
+// collectionSettings are the storage and index settings fixed when a
+// collection is created. They are persisted so a reopened collection keeps
+// encoding its files and building its graph the same way regardless of the
+// options passed to Open.
+type collectionSettings struct {
+	CompressionLevel int                    `json:"compression_level"`
+	Encoder          encoding.EncoderConfig `json:"encoder"`
+	Constraints      *Constraints           `json:"vector_constraints,omitempty"`
+	Shards           int                    `json:"shards,omitempty"` // 0 = unsharded
+
+	// Index settings; files written before they were recorded lack them, and
+	// the collection then uses the options it is opened with
+	Dimension      int    `json:"dimension,omitempty"`
+	Metric         string `json:"metric,omitempty"` // "l2", "ip" or "cosine"; "" = custom distance function
+	M              int    `json:"m,omitempty"`
+	EfConstruction int    `json:"ef_construction,omitempty"`
+
+	TextField string `json:"text_field,omitempty"` // Metadata field of the text index, "" = none
+}
+
+// metrics are the distance functions recorded by name in the settings file
+var metrics = map[string]hnsw.DistanceFunc{
+	MetricL2:           hnsw.L2Distance,
+	MetricInnerProduct: hnsw.InnerProductDistance,
+	MetricCosine:       hnsw.CosineDistance,
+}
+
+// metricName returns the name of a built-in distance function, or "" for a
+// custom one. nil is the default L2Distance.
+func metricName(fn hnsw.DistanceFunc) string {
+	if fn == nil {
+		return MetricL2
+	}
+	for name, metric := range metrics {
+		if sameDistance(fn, metric) {
+			return name
+		}
+	}
+	return ""
+}
+
+// sameDistance reports whether a and b are the same function, nil being
+// L2Distance
+func sameDistance(a, b hnsw.DistanceFunc) bool {
+	if a == nil {
+		a = hnsw.L2Distance
+	}
+	if b == nil {
+		b = hnsw.L2Distance
+	}
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// configure returns a copy of config using the recorded index settings
+func (s collectionSettings) configure(config *Config) *Config {
+	configured := *config
+	if s.Dimension > 0 {
+		configured.Dimension = s.Dimension
+	}
+	if fn, ok := metrics[s.Metric]; ok {
+		configured.DistanceFunc = fn
+	}
+	if s.M > 0 {
+		configured.M = s.M
+	}
+	if s.EfConstruction > 0 {
+		configured.EfConstruction = s.EfConstruction
+	}
+	configured.TextIndexField = s.TextField
+	return &configured
+}
+
+// settingsFromConfig derives storage settings from config, applying defaults
+func settingsFromConfig(config *Config) collectionSettings {
+	settings := collectionSettings{
+		CompressionLevel: config.CompressionLevel,
+		Encoder:          *encoding.DefaultEncoderConfig(),
+	}
+	if settings.CompressionLevel == 0 {
+		settings.CompressionLevel = defaultCompressionLevel
+	}
+	if config.EncoderConfig != nil {
+		settings.Encoder = *config.EncoderConfig
+	}
+	if config.VectorConstraints != nil {
+		constraints := *config.VectorConstraints
+		settings.Constraints = &constraints
+	}
+	if config.Shards != 1 {
+		settings.Shards = config.Shards
+	}
+	settings.Dimension = config.Dimension
+	settings.Metric = metricName(config.DistanceFunc)
+	settings.M = config.M
+	settings.EfConstruction = config.EfConstruction
+	settings.TextField = config.TextIndexField
+	return settings
+}
+
+// validate rejects compression levels outside zstd's range, invalid
+// encoder thresholds, negative shard counts and unsatisfiable vector
+// constraints
+func (s collectionSettings) validate() error {
+	if err := encoding.ValidateCompressionLevel(s.CompressionLevel); err != nil {
+		return fmt.Errorf("%w: %v", ErrValidationFailed, err)
+	}
+	if err := s.Encoder.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrValidationFailed, err)
+	}
+	if s.Shards < 0 {
+		return fmt.Errorf("%w: shard count %d is negative", ErrValidationFailed, s.Shards)
+	}
+	if _, ok := metrics[s.Metric]; s.Metric != "" && !ok {
+		return fmt.Errorf("%w: unknown metric %q", ErrValidationFailed, s.Metric)
+	}
+	if s.Constraints != nil {
+		return s.Constraints.validate()
+	}
+	return nil
+}
+
+// factory returns an encoder factory using these settings
+func (s collectionSettings) factory() *encoding.EncoderFactory {
+	encoder := s.Encoder
+	return encoding.NewEncoderFactoryWithConfig(s.CompressionLevel, &encoder)
+}
+
+// loadOrCreateSettings returns the settings persisted in dir, or persists and
+// returns the settings derived from config for a new collection. Read-only
+// configurations never write the file, in-memory ones never read it either.
+func loadOrCreateSettings(dir string, config *Config) (collectionSettings, error) {
+	if config.InMemory {
+		settings := settingsFromConfig(config)
+		if err := settings.validate(); err != nil {
+			return collectionSettings{}, err
+		}
+		return settings, nil
+	}
+	path := filepath.Join(dir, settingsFileName)
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var settings collectionSettings
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return collectionSettings{}, fmt.Errorf("%w: parse %s: %v", ErrStorageCorrupted, settingsFileName, err)
+		}
+		if err := settings.validate(); err != nil {
+			return collectionSettings{}, fmt.Errorf("%w: %s: %v", ErrStorageCorrupted, settingsFileName, err)
+		}
+		return settings, nil
+	}
+	if !os.IsNotExist(err) {
+		return collectionSettings{}, err
+	}
+
+	settings := settingsFromConfig(config)
+	if err := settings.validate(); err != nil {
+		return collectionSettings{}, err
+	}
+	if config.ReadOnly {
+		return settings, nil
+	}
+
+	data, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return collectionSettings{}, err
+	}
+	if err := lanceio.WriteFileAtomic(path, data, 0644); err != nil {
+		return collectionSettings{}, err
+	}
+	return settings, nil
+}
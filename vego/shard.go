@@ -0,0 +1,601 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"path/filepath"
+	"sync"
+)
+
+// A sharded collection spreads its documents over Config.Shards
+// sub-collections, each with its own index, document storage and save
+// files under shards/<n>. The shard of a document is picked by the
+// collection's ShardFunc from its ID, so writes and Gets touch one shard
+// while searches run on every shard in parallel and merge the hits.
+//
+// The shard count is persisted at creation and cannot change. The shard
+// function is not persisted: a collection must be reopened with the same
+// function it was created with, or documents are looked up in the wrong
+// shard.
+
+// ShardFunc returns the shard, in [0, n), of the document with the given ID
+type ShardFunc func(id string, n int) int
+
+// HashShard is the default ShardFunc; it spreads IDs by their FNV-1a hash
+func HashShard(id string, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return int(h.Sum64() % uint64(n))
+}
+
+// shardedOps are the operations a sharded collection supports; begin
+// rejects the others with ErrNotSupported
+var shardedOps = map[string]bool{
+	"InsertContext":      true,
+	"InsertBatchContext": true,
+	"InsertBatchOpts":    true,
+	"UpsertBatchContext": true,
+	"GetContext":         true,
+	"GetBatchOpts":       true,
+	"DeleteContext":      true,
+	"DeleteBatchContext": true,
+	"UpdateContext":      true,
+	"UpsertContext":      true,
+	"SearchContext":      true,
+	"SearchWithFilter":   true,
+	"SearchFiltered":     true,
+	"SearchShards":       true,
+	"Scan":               true,
+	"ScanWithFilter":     true,
+	"Save":               true,
+	"SetDefaultEF":       true,
+}
+
+// ShardFailure reports a shard that could not serve an operation
+type ShardFailure struct {
+	Shard int
+	Err   error
+}
+
+// ShardSearchResult is the result of SearchShards
+type ShardSearchResult struct {
+	Results []SearchResult // Merged hits of the shards that answered
+	Failed  []ShardFailure // Shards that could not be searched, by shard number
+}
+
+// ShardStats describes one shard of a sharded collection
+type ShardStats struct {
+	Shard      int
+	Count      int   // Documents in the shard
+	IndexNodes int   // HNSW nodes in the shard, including orphaned ones
+	Err        error // Why the shard failed to open, nil if it is available
+}
+
+// checkShards rejects opening a collection with a shard count other than
+// the one it was created with
+func checkShards(settings collectionSettings, config *Config) error {
+	if config.Shards == 0 {
+		return nil
+	}
+	want := config.Shards
+	if want == 1 {
+		want = 0
+	}
+	if want != settings.Shards {
+		return fmt.Errorf("%w: collection has %d shards, opened with %d; resharding is not supported",
+			ErrValidationFailed, max(settings.Shards, 1), config.Shards)
+	}
+	return nil
+}
+
+// shardPath returns the directory of shard i of the collection at path
+func shardPath(path string, i int) string {
+	return filepath.Join(path, "shards", fmt.Sprintf("%03d", i))
+}
+
+// openShards opens the sub-collections of a sharded collection. A shard
+// that fails to open is recorded in shardErrs and left out of searches, so
+// one corrupt shard degrades the collection instead of making it unusable;
+// only if every shard fails does opening fail.
+func (c *Collection) openShards(ctx context.Context) error {
+	n := c.settings.Shards
+	c.shardFn = c.config.ShardFunc
+	if c.shardFn == nil {
+		c.shardFn = HashShard
+	}
+
+	// Shards run their background tasks on the collection's scheduler
+	if c.sched == nil {
+		c.sched = newScheduler(c.config.BackgroundWorkers)
+		c.ownSched = true
+	}
+
+	config := *c.config
+	config.Shards = 0
+	config.ShardFunc = nil
+
+	c.shards = make([]*Collection, n)
+	c.shardErrs = make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("%s/shard-%d", c.name, i)
+			c.shards[i], c.shardErrs[i] = newCollection(ctx, name, shardPath(c.path, i), &config, c.sched)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range c.shardErrs {
+		if err != nil {
+			failed++
+			log.Printf("Warning: shard %d of collection %s is unavailable: %v", i, c.name, err)
+		}
+	}
+	if failed == n {
+		c.stopTasks()
+		return c.shardErrs[0]
+	}
+	return nil
+}
+
+// shardOf returns the shard holding the document with the given ID, or an
+// error if the shard function is out of range or the shard failed to open
+func (c *Collection) shardOf(op, id string) (*Collection, error) {
+	i := c.shardFn(id, len(c.shards))
+	if i < 0 || i >= len(c.shards) {
+		return nil, wrapError(op, c.name, id, fmt.Errorf("%w: shard function returned %d for %d shards", ErrValidationFailed, i, len(c.shards)))
+	}
+	if c.shards[i] == nil {
+		return nil, wrapError(op, c.name, id, fmt.Errorf("%w: shard %d: %v", ErrShardUnavailable, i, c.shardErrs[i]))
+	}
+	return c.shards[i], nil
+}
+
+// eachShard runs fn on every available shard in parallel and returns the
+// failures, including the shards that failed to open, by shard number
+func (c *Collection) eachShard(fn func(i int, shard *Collection) error) []ShardFailure {
+	errs := make([]error, len(c.shards))
+	var wg sync.WaitGroup
+	for i, shard := range c.shards {
+		if shard == nil {
+			errs[i] = fmt.Errorf("%w: %v", ErrShardUnavailable, c.shardErrs[i])
+			continue
+		}
+		wg.Add(1)
+		go func(i int, shard *Collection) {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var failed []ShardFailure
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, ShardFailure{Shard: i, Err: err})
+		}
+	}
+	return failed
+}
+
+// shardError combines shard failures into one error for op, nil if there
+// are none
+func (c *Collection) shardError(op string, failed []ShardFailure) error {
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return wrapError(op, c.name, "", fmt.Errorf("shard %d: %w", failed[0].Shard, failed[0].Err))
+	}
+	errs := make([]error, len(failed))
+	for i, f := range failed {
+		errs[i] = fmt.Errorf("shard %d: %w", f.Shard, f.Err)
+	}
+	return wrapError(op, c.name, "", &BatchError{Errors: errs})
+}
+
+// groupByShard splits ids by shard, keeping request order within each
+func (c *Collection) groupByShard(op string, ids []string) (map[*Collection][]string, error) {
+	groups := make(map[*Collection][]string)
+	for _, id := range ids {
+		shard, err := c.shardOf(op, id)
+		if err != nil {
+			return nil, err
+		}
+		groups[shard] = append(groups[shard], id)
+	}
+	return groups, nil
+}
+
+func (c *Collection) shardInsert(ctx context.Context, doc *Document) error {
+	ctx, done, err := c.beginWrite(ctx, "InsertContext")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := doc.ValidateWith(c.dimension, c.settings.Constraints); err != nil {
+		return err
+	}
+	shard, err := c.shardOf("InsertContext", doc.ID)
+	if err != nil {
+		return err
+	}
+	return shard.InsertContext(ctx, doc)
+}
+
+// shardInsertBatch validates the whole batch, then inserts each shard's
+// documents in parallel. Each shard's part is inserted or rejected as a
+// whole, but a failure in one shard does not undo the others.
+func (c *Collection) shardInsertBatch(ctx context.Context, docs []*Document) error {
+	ctx, done, err := c.beginWrite(ctx, "InsertBatchContext")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := c.validateBatch("InsertBatchContext", docs); err != nil {
+		return err
+	}
+	groups := make(map[*Collection][]*Document)
+	for _, doc := range docs {
+		shard, err := c.shardOf("InsertBatchContext", doc.ID)
+		if err != nil {
+			return err
+		}
+		groups[shard] = append(groups[shard], doc)
+	}
+
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		if group := groups[shard]; len(group) > 0 {
+			return shard.InsertBatchContext(ctx, group)
+		}
+		return nil
+	})
+	return c.shardError("InsertBatchContext", c.openFailures(failed))
+}
+
+// openFailures drops the failures of shards that failed to open from
+// failed. Operations that route by ID have already rejected documents of
+// those shards, and the others skip them.
+func (c *Collection) openFailures(failed []ShardFailure) []ShardFailure {
+	kept := failed[:0]
+	for _, f := range failed {
+		if c.shards[f.Shard] != nil {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func (c *Collection) shardGet(ctx context.Context, id string) (*Document, error) {
+	ctx, done, err := c.begin(ctx, "GetContext")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	shard, err := c.shardOf("GetContext", id)
+	if err != nil {
+		return nil, err
+	}
+	return shard.GetContext(ctx, id)
+}
+
+// shardGetBatch looks up each shard's IDs in parallel and reports Missing
+// in request order
+func (c *Collection) shardGetBatch(ctx context.Context, ids []string, opts GetOptions) (*GetBatchResult, error) {
+	ctx, done, err := c.begin(ctx, "GetBatchOpts")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	groups, err := c.groupByShard("GetBatchOpts", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	result := &GetBatchResult{Found: make(map[string]*Document, len(ids))}
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		group := groups[shard]
+		if len(group) == 0 {
+			return nil
+		}
+		part, err := shard.GetBatchOpts(ctx, group, opts)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for id, doc := range part.Found {
+			result.Found[id] = doc
+		}
+		return nil
+	})
+	if err := c.shardError("GetBatchOpts", c.openFailures(failed)); err != nil {
+		return nil, err
+	}
+
+	reported := make(map[string]struct{})
+	for _, id := range ids {
+		if _, found := result.Found[id]; found {
+			continue
+		}
+		if _, dup := reported[id]; !dup {
+			reported[id] = struct{}{}
+			result.Missing = append(result.Missing, id)
+		}
+	}
+	return result, nil
+}
+
+func (c *Collection) shardDelete(ctx context.Context, id string) error {
+	ctx, done, err := c.beginWrite(ctx, "DeleteContext")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	shard, err := c.shardOf("DeleteContext", id)
+	if err != nil {
+		return err
+	}
+	return shard.DeleteContext(ctx, id)
+}
+
+func (c *Collection) shardDeleteBatch(ctx context.Context, ids []string) error {
+	ctx, done, err := c.beginWrite(ctx, "DeleteBatchContext")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	groups, err := c.groupByShard("DeleteBatchContext", ids)
+	if err != nil {
+		return err
+	}
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		if group := groups[shard]; len(group) > 0 {
+			return shard.DeleteBatchContext(ctx, group)
+		}
+		return nil
+	})
+	return c.shardError("DeleteBatchContext", c.openFailures(failed))
+}
+
+func (c *Collection) shardUpdate(ctx context.Context, op string, doc *Document) error {
+	ctx, done, err := c.beginWrite(ctx, op)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := doc.ValidateWith(c.dimension, c.settings.Constraints); err != nil {
+		return err
+	}
+	shard, err := c.shardOf(op, doc.ID)
+	if err != nil {
+		return err
+	}
+	if op == "UpsertContext" {
+		return shard.UpsertContext(ctx, doc)
+	}
+	return shard.UpdateContext(ctx, doc)
+}
+
+// SearchShards searches a sharded collection like SearchContext and also
+// reports the shards that could not be searched. Their hits are missing
+// from Results, which holds the k best hits of the other shards; plain
+// searches return the same partial results and log the failures. On an
+// unsharded collection it is SearchContext with no failures.
+func (c *Collection) SearchShards(ctx context.Context, query []float32, k int, opts ...SearchOption) (*ShardSearchResult, error) {
+	if c.shards == nil {
+		results, err := c.SearchContext(ctx, query, k, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &ShardSearchResult{Results: results}, nil
+	}
+
+	ctx, done, err := c.begin(ctx, "SearchShards")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := c.checkQuery("SearchShards", query); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, wrapError("SearchShards", c.name, "", ErrInvalidK)
+	}
+
+	options := c.searchOptions(opts)
+	if err := c.checkBoost("SearchShards", options); err != nil {
+		return nil, err
+	}
+	result, err := c.scatterSearch(ctx, "SearchShards", query, boostCandidates(k, options), options)
+	if err != nil {
+		return nil, err
+	}
+	result.Results = boost(result.Results, k, options)
+	if result.Results, err = c.enrich(ctx, "SearchShards", result.Results, options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// shardSearch is search for sharded collections: it returns the merged
+// hits of the shards that answered, logging those that did not, and fails
+// only if no shard could be searched
+func (c *Collection) shardSearch(ctx context.Context, op string, query []float32, k int, options *SearchOptions) ([]SearchResult, error) {
+	result, err := c.scatterSearch(ctx, op, query, k, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range result.Failed {
+		log.Printf("Warning: %s of collection %s skipped shard %d: %v", op, c.name, f.Shard, f.Err)
+	}
+	return result.Results, nil
+}
+
+// shardSearchWithFilter is searchWithFilter for sharded collections: each
+// shard filters its own traversal and their hits are merged as in
+// shardSearch
+func (c *Collection) shardSearchWithFilter(ctx context.Context, op string, query []float32, k int, filter Filter, options *SearchOptions) ([]SearchResult, error) {
+	var mu sync.Mutex
+	var merged []SearchResult
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		results, err := shard.searchWithFilter(ctx, op, query, k, filter, options)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		merged = append(merged, results...)
+		mu.Unlock()
+		return nil
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(failed) == len(c.shards) {
+		return nil, c.shardError(op, failed)
+	}
+	for _, f := range failed {
+		log.Printf("Warning: %s of collection %s skipped shard %d: %v", op, c.name, f.Shard, f.Err)
+	}
+	return mergeResults(merged, k), nil
+}
+
+// scatterSearch searches every shard for its k nearest documents in
+// parallel and merges them into the overall k nearest. Ties are broken by
+// document ID so the merge does not depend on which shard answers first.
+func (c *Collection) scatterSearch(ctx context.Context, op string, query []float32, k int, options *SearchOptions) (*ShardSearchResult, error) {
+	var mu sync.Mutex
+	var merged []SearchResult
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		results, err := shard.search(ctx, op, query, k, options)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		merged = append(merged, results...)
+		mu.Unlock()
+		return nil
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(failed) == len(c.shards) {
+		return nil, c.shardError(op, failed)
+	}
+
+	return &ShardSearchResult{Results: mergeResults(merged, k), Failed: failed}, nil
+}
+
+func (c *Collection) shardCount() int {
+	n := 0
+	for _, shard := range c.shards {
+		if shard != nil {
+			n += shard.Count()
+		}
+	}
+	return n
+}
+
+// shardStats sums the shards' statistics and reports each shard and the
+// skew of their sizes
+func (c *Collection) shardStats() CollectionStats {
+	stats := CollectionStats{
+		Name:             c.name,
+		Dimension:        c.dimension,
+		LastUpdate:       c.now(),
+		CompressionLevel: c.settings.CompressionLevel,
+		EncoderConfig:    c.settings.Encoder,
+		InMemory:         c.config.InMemory,
+		Shards:           make([]ShardStats, len(c.shards)),
+	}
+	largest, available := 0, 0
+	for i, shard := range c.shards {
+		stats.Shards[i] = ShardStats{Shard: i, Err: c.shardErrs[i]}
+		if shard == nil {
+			continue
+		}
+		s := shard.Stats()
+		stats.Shards[i].Count = s.Count
+		stats.Shards[i].IndexNodes = s.IndexNodes
+		stats.Count += s.Count
+		stats.IndexNodes += s.IndexNodes
+		stats.OrphanNodes += s.OrphanNodes
+		stats.PendingMutations += s.PendingMutations
+		stats.M, stats.EfConstruction, stats.DefaultEF, stats.Metric = s.M, s.EfConstruction, s.DefaultEF, s.Metric
+		if available == 0 || s.LastSaveTime.Before(stats.LastSaveTime) {
+			stats.LastSaveTime = s.LastSaveTime // The stalest shard's
+		}
+		largest = max(largest, s.Count)
+		available++
+	}
+	if stats.Count > 0 {
+		stats.ShardSkew = float64(largest) / (float64(stats.Count) / float64(available))
+	}
+	return stats
+}
+
+// shardSetDefaultEF sets the default search scope of every shard
+func (c *Collection) shardSetDefaultEF(ef int) error {
+	_, done, err := c.begin(context.Background(), "SetDefaultEF")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		return shard.SetDefaultEF(ef)
+	})
+	return c.shardError("SetDefaultEF", c.openFailures(failed))
+}
+
+// shardSave saves every shard in parallel; a shard that fails to save does
+// not stop the others
+func (c *Collection) shardSave() error {
+	_, done, err := c.beginWrite(context.Background(), "Save")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		return shard.Save()
+	})
+	return c.shardError("Save", c.openFailures(failed))
+}
+
+// shardClose drains the collection's operations, then closes the shards in
+// parallel and stops the scheduler if the collection owns it
+func (c *Collection) shardClose() error {
+	c.drain()
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		return shard.Close()
+	})
+	c.stopTasks()
+	return c.shardError("Close", c.openFailures(failed))
+}
+
+// shardDropHandle is dropHandle of a sharded collection: it drains the
+// collection and drops the handle of every shard, leaving Drop to remove
+// the collection's directory, including those of shards that failed to open
+func (c *Collection) shardDropHandle() error {
+	c.drain()
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		return shard.dropHandle()
+	})
+	c.stopTasks()
+	return c.shardError("Drop", c.openFailures(failed))
+}
@@ -0,0 +1,305 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// shardTestDocs returns n documents with random vectors
+func shardTestDocs(rng *rand.Rand, n, dim int) []*Document {
+	docs := make([]*Document, n)
+	for i := range docs {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		docs[i] = &Document{ID: fmt.Sprintf("doc_%05d", i), Vector: v, Metadata: map[string]interface{}{"n": i}}
+	}
+	return docs
+}
+
+// recallAt10 is the fraction of the exact 10 nearest documents coll finds
+func recallAt10(t *testing.T, coll *Collection, docs []*Document, queries [][]float32) float64 {
+	t.Helper()
+	hits := 0
+	for _, q := range queries {
+		exact := append([]*Document(nil), docs...)
+		sort.Slice(exact, func(i, j int) bool {
+			return hnsw.L2Distance(q, exact[i].Vector) < hnsw.L2Distance(q, exact[j].Vector)
+		})
+		want := make(map[string]bool)
+		for _, d := range exact[:10] {
+			want[d.ID] = true
+		}
+
+		results, err := coll.Search(q, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, r := range results {
+			if want[r.Document.ID] {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(10*len(queries))
+}
+
+func TestShardedMatchesUnsharded(t *testing.T) {
+	const dim = 16
+	rng := rand.New(rand.NewSource(1))
+	docs := shardTestDocs(rng, 2000, dim)
+	queries := make([][]float32, 50)
+	for i := range queries {
+		queries[i] = shardTestDocs(rng, 1, dim)[0].Vector
+	}
+
+	db, err := Open(t.TempDir(), WithDimension(dim))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	plain, _ := db.Collection("plain")
+	sharded, err := Open(t.TempDir(), WithDimension(dim), WithShards(4, nil))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer sharded.Close()
+	coll, err := sharded.Collection("sharded")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	for _, c := range []*Collection{plain, coll} {
+		if err := c.InsertBatch(docs[:1000]); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		for _, doc := range docs[1000:] {
+			if err := c.Insert(doc); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+	}
+
+	plainRecall, shardedRecall := recallAt10(t, plain, docs, queries), recallAt10(t, coll, docs, queries)
+	t.Logf("recall@10: unsharded %.3f, 4 shards %.3f", plainRecall, shardedRecall)
+	if shardedRecall < 0.9 || shardedRecall < plainRecall-0.02 {
+		t.Errorf("sharded recall %.3f, unsharded %.3f", shardedRecall, plainRecall)
+	}
+
+	// Results come back merged closest first
+	results, err := coll.Search(queries[0], 10)
+	if err != nil || len(results) != 10 {
+		t.Fatalf("Search = %d results, %v", len(results), err)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Distance < results[i-1].Distance {
+			t.Fatalf("results not sorted at %d", i)
+		}
+	}
+	filtered, err := coll.SearchWithFilter(queries[0], 5, &MetadataFilter{Field: "n", Operator: "lt", Value: 100})
+	if err != nil {
+		t.Fatalf("SearchWithFilter failed: %v", err)
+	}
+	for _, r := range filtered {
+		if n, _ := r.Document.GetInt("n"); n >= 100 {
+			t.Errorf("filtered search returned %s", r.Document.ID)
+		}
+	}
+
+	// Point operations route to the document's shard
+	if coll.Count() != len(docs) {
+		t.Errorf("Count = %d, want %d", coll.Count(), len(docs))
+	}
+	if err := coll.Insert(docs[0]); !IsDuplicate(err) {
+		t.Errorf("duplicate Insert = %v", err)
+	}
+	moved := &Document{ID: docs[1].ID, Vector: queries[1]}
+	if err := coll.Update(moved); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if results, _ := coll.Search(queries[1], 1); len(results) != 1 || results[0].Document.ID != moved.ID {
+		t.Errorf("updated document not found at its new vector: %v", results)
+	}
+	if err := coll.Delete(docs[2].ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := coll.Get(docs[2].ID); !IsNotFound(err) {
+		t.Errorf("Get of deleted document = %v", err)
+	}
+	batch, err := coll.GetBatchOpts(context.Background(), []string{docs[3].ID, "missing", docs[4].ID, docs[2].ID}, GetOptions{})
+	if err != nil {
+		t.Fatalf("GetBatchOpts failed: %v", err)
+	}
+	if len(batch.Found) != 2 || fmt.Sprint(batch.Missing) != fmt.Sprint([]string{"missing", docs[2].ID}) {
+		t.Errorf("GetBatchOpts found %d, missing %v", len(batch.Found), batch.Missing)
+	}
+
+	stats := coll.Stats()
+	if len(stats.Shards) != 4 || stats.Count != len(docs)-1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	sum := 0
+	for _, s := range stats.Shards {
+		if s.Err != nil || s.Count == 0 {
+			t.Errorf("shard stats %+v", s)
+		}
+		sum += s.Count
+	}
+	if sum != stats.Count || stats.ShardSkew < 1 || stats.ShardSkew > 1.2 {
+		t.Errorf("shards hold %d documents, skew %.3f", sum, stats.ShardSkew)
+	}
+
+	// Operations that need a single index are refused
+	if _, err := coll.SearchIDs(context.Background(), queries[0], 10, nil); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("SearchIDs = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestShardedReopen(t *testing.T) {
+	const dim = 8
+	path := t.TempDir()
+	docs := shardTestDocs(rand.New(rand.NewSource(2)), 300, dim)
+	byLength := func(id string, n int) int { return len(id) % n }
+
+	db, err := Open(path, WithDimension(dim), WithShards(3, nil))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, _ := db.Collection("docs")
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	before, _ := coll.Search(docs[7].Vector, 10)
+	if err := coll.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := os.Stat(filepath.Join(shardPath(filepath.Join(path, "docs"), i), "index")); err != nil {
+			t.Errorf("shard %d has no saved index: %v", i, err)
+		}
+	}
+
+	// A different shard count is refused
+	if _, err := Open(path, WithDimension(dim), WithShards(2, byLength)); !IsValidationFailed(err) {
+		t.Errorf("reopen with 2 shards = %v, want ErrValidationFailed", err)
+	}
+
+	// Without WithShards the persisted count is used
+	db, err = Open(path, WithDimension(dim))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	coll, _ = db.Collection("docs")
+	if coll.Count() != len(docs) || len(coll.Stats().Shards) != 3 {
+		t.Fatalf("reopened with %d documents, %d shards", coll.Count(), len(coll.Stats().Shards))
+	}
+	after, _ := coll.Search(docs[7].Vector, 10)
+	if fmt.Sprint(resultIDs(after)) != fmt.Sprint(resultIDs(before)) {
+		t.Errorf("results after reopen %v, before %v", resultIDs(after), resultIDs(before))
+	}
+	if doc, err := coll.Get(docs[42].ID); err != nil || doc.ID != docs[42].ID {
+		t.Errorf("Get after reopen = %v, %v", doc, err)
+	}
+
+	if err := db.DropCollection("docs"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "docs")); !os.IsNotExist(err) {
+		t.Errorf("collection directory left after drop: %v", err)
+	}
+}
+
+// resultIDs returns the document IDs of results in order
+func resultIDs(results []SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Document.ID
+	}
+	return ids
+}
+
+func TestShardedCorruptShard(t *testing.T) {
+	const dim = 8
+	path := t.TempDir()
+	docs := shardTestDocs(rand.New(rand.NewSource(3)), 400, dim)
+
+	db, err := Open(path, WithDimension(dim), WithShards(4, nil))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	coll, _ := db.Collection("docs")
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Shard 1 can no longer be opened
+	settings := filepath.Join(shardPath(filepath.Join(path, "docs"), 1), settingsFileName)
+	if err := os.WriteFile(settings, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(path, WithDimension(dim))
+	if err != nil {
+		t.Fatalf("reopen with a corrupt shard failed: %v", err)
+	}
+	defer db.Close()
+	coll, _ = db.Collection("docs")
+
+	result, err := coll.SearchShards(context.Background(), docs[0].Vector, 10)
+	if err != nil {
+		t.Fatalf("SearchShards failed: %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Shard != 1 || !errors.Is(result.Failed[0].Err, ErrShardUnavailable) {
+		t.Fatalf("Failed = %+v, want shard 1", result.Failed)
+	}
+	if len(result.Results) != 10 {
+		t.Fatalf("%d partial results, want 10", len(result.Results))
+	}
+	for _, r := range result.Results {
+		if HashShard(r.Document.ID, 4) == 1 {
+			t.Errorf("result %s belongs to the failed shard", r.Document.ID)
+		}
+	}
+	if results, err := coll.Search(docs[0].Vector, 10); err != nil || len(results) != 10 {
+		t.Errorf("Search = %d results, %v", len(results), err)
+	}
+
+	// Documents of the failed shard are reported, the others still work
+	var lost, kept *Document
+	for _, doc := range docs {
+		if HashShard(doc.ID, 4) == 1 {
+			lost = doc
+		} else {
+			kept = doc
+		}
+	}
+	if _, err := coll.Get(lost.ID); !errors.Is(err, ErrShardUnavailable) {
+		t.Errorf("Get from the failed shard = %v, want ErrShardUnavailable", err)
+	}
+	if err := coll.Delete(kept.ID); err != nil {
+		t.Errorf("Delete from a healthy shard failed: %v", err)
+	}
+	stats := coll.Stats()
+	if stats.Shards[1].Err == nil || !IsNotFound(func() error { _, err := coll.Get(kept.ID); return err }()) {
+		t.Errorf("Stats = %+v", stats.Shards)
+	}
+	if err := coll.Save(); err != nil {
+		t.Errorf("Save with a failed shard = %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// setupSimilarTest creates a collection of 200 documents alternating between
+// the "even" and "odd" groups
+func setupSimilarTest(t *testing.T) *Collection {
+	t.Helper()
+	db, err := Open(t.TempDir(), WithDimension(16))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	docs := make([]*Document, 200)
+	for i := range docs {
+		group := "even"
+		if i%2 == 1 {
+			group = "odd"
+		}
+		docs[i] = &Document{
+			ID:       fmt.Sprintf("doc_%03d", i),
+			Vector:   make([]float32, 16),
+			Metadata: map[string]interface{}{"group": group},
+		}
+		for j := range docs[i].Vector {
+			docs[i].Vector[j] = rng.Float32()
+		}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	return coll
+}
+
+func TestSearchSimilarToExcludesSource(t *testing.T) {
+	coll := setupSimilarTest(t)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("doc_%03d", i)
+		results, err := coll.SearchSimilarTo(ctx, id, 5, nil)
+		if err != nil {
+			t.Fatalf("SearchSimilarTo(%s) failed: %v", id, err)
+		}
+		if len(results) != 5 {
+			t.Fatalf("SearchSimilarTo(%s) returned %d results, want 5", id, len(results))
+		}
+		for _, r := range results {
+			if r.Document.ID == id {
+				t.Errorf("SearchSimilarTo(%s) returned the source document", id)
+			}
+		}
+
+		results, err = coll.SearchSimilarTo(ctx, id, 5, nil, WithIncludeSource(true))
+		if err != nil {
+			t.Fatalf("SearchSimilarTo(%s) failed: %v", id, err)
+		}
+		if len(results) != 5 || results[0].Document.ID != id || results[0].Distance != 0 {
+			t.Errorf("SearchSimilarTo(%s, WithIncludeSource) = %v, want the source first", id, results)
+		}
+	}
+}
+
+func TestSearchSimilarToFilter(t *testing.T) {
+	coll := setupSimilarTest(t)
+	filter := &MetadataFilter{Field: "group", Operator: "eq", Value: "odd"}
+
+	// doc_000 is even, so none of its results may share its group
+	results, err := coll.SearchSimilarTo(context.Background(), "doc_000", 10, filter, WithVectors(true))
+	if err != nil {
+		t.Fatalf("SearchSimilarTo failed: %v", err)
+	}
+	if len(results) != 10 {
+		t.Fatalf("got %d results, want 10", len(results))
+	}
+	for _, r := range results {
+		if r.Document.Metadata["group"] != "odd" {
+			t.Errorf("result %s does not match the filter", r.Document.ID)
+		}
+		if len(r.Document.Vector) != 16 {
+			t.Errorf("result %s has no vector despite WithVectors", r.Document.ID)
+		}
+	}
+
+	// A source that matches the filter is still excluded
+	results, err = coll.SearchSimilarTo(context.Background(), "doc_001", 10, filter)
+	if err != nil {
+		t.Fatalf("SearchSimilarTo failed: %v", err)
+	}
+	if len(results) != 10 {
+		t.Fatalf("got %d results, want 10", len(results))
+	}
+	for _, r := range results {
+		if r.Document.ID == "doc_001" {
+			t.Errorf("filtered SearchSimilarTo returned the source document")
+		}
+	}
+}
+
+func TestSearchSimilarToNotFound(t *testing.T) {
+	coll := setupSimilarTest(t)
+	ctx := context.Background()
+
+	if _, err := coll.SearchSimilarTo(ctx, "missing", 5, nil); !IsNotFound(err) {
+		t.Errorf("unknown ID: err = %v, want not found", err)
+	}
+
+	// The deleted document's node is still in the index, but must not be
+	// used as a query
+	if err := coll.Delete("doc_010"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := coll.SearchSimilarTo(ctx, "doc_010", 5, nil); !IsNotFound(err) {
+		t.Errorf("deleted ID: err = %v, want not found", err)
+	}
+
+	if _, err := coll.SearchSimilarTo(ctx, "doc_011", 0, nil); !IsInvalidK(err) {
+		t.Errorf("k = 0: err = %v, want ErrInvalidK", err)
+	}
+}
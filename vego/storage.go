@@ -12,6 +12,7 @@ import (
 	"github.com/wzqhbustb/vego/storage/arrow"
 	"github.com/wzqhbustb/vego/storage/column"
 	"github.com/wzqhbustb/vego/storage/encoding"
+	lanceio "github.com/wzqhbustb/vego/storage/io"
 )
 
 const (
@@ -57,6 +58,15 @@ type DocumentStorage struct {
 	// Metadata storage (separate from column storage)
 	metaStore *metadataStore
 
+	// Save the files on disk belong to, as recorded in their stamp file;
+	// 0 once they are written outside a save, see barrier.go
+	stamp uint64
+
+	// In-memory storage keeps the flushed documents in rows instead of the
+	// data file and writes no files at all; nil rows = nothing flushed yet
+	memory bool
+	rows   []*Document
+
 	// State tracking
 	dirty  bool
 	mu     sync.RWMutex
@@ -73,6 +83,17 @@ type StorageStats struct {
 
 // NewDocumentStorage creates a new document storage instance.
 func NewDocumentStorage(path string, dimension int) (*DocumentStorage, error) {
+	return NewDocumentStorageWithFactory(path, dimension, nil)
+}
+
+// NewDocumentStorageWithFactory creates a document storage instance that
+// encodes its data file with factory. A nil factory uses zstd level 3 and the
+// default encoder configuration.
+func NewDocumentStorageWithFactory(path string, dimension int, factory *encoding.EncoderFactory) (*DocumentStorage, error) {
+	if factory == nil {
+		factory = encoding.NewEncoderFactory(defaultCompressionLevel)
+	}
+
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, fmt.Errorf("create storage directory: %w", err)
 	}
@@ -86,7 +107,7 @@ func NewDocumentStorage(path string, dimension int) (*DocumentStorage, error) {
 	s := &DocumentStorage{
 		path:      path,
 		dimension: dimension,
-		factory:   encoding.NewEncoderFactory(3),
+		factory:   factory,
 		metaStore: metaStore,
 		maxBuffer: maxBufferSize,
 	}
@@ -99,6 +120,20 @@ func NewDocumentStorage(path string, dimension int) (*DocumentStorage, error) {
 	return s, nil
 }
 
+// newMemoryStorage creates a document storage instance that never touches
+// the filesystem, see OpenInMemory
+func newMemoryStorage(dimension int) *DocumentStorage {
+	return &DocumentStorage{
+		dimension: dimension,
+		memory:    true,
+		metaStore: &metadataStore{
+			entries:  make(map[int64]docMeta),
+			idToHash: make(map[string]int64),
+		},
+		maxBuffer: maxBufferSize,
+	}
+}
+
 // hashID converts a string ID to int64 hash for column storage
 func hashID(id string) int64 {
 	h := fnv.New64a()
@@ -106,12 +141,15 @@ func hashID(id string) int64 {
 	return int64(h.Sum64())
 }
 
-// createSchema creates the Arrow schema for vector storage
+// createSchema creates the Arrow schema for vector storage. Data files are
+// read back by field ID, so existing IDs must not change; a column added
+// later takes a new ID and must be nullable for older files to stay
+// readable.
 func (s *DocumentStorage) createSchema() *arrow.Schema {
 	return arrow.NewSchema([]arrow.Field{
-		{Name: "id_hash", Type: arrow.PrimInt64(), Nullable: false},
-		{Name: "vector", Type: arrow.VectorType(s.dimension), Nullable: false},
-		{Name: "timestamp", Type: arrow.PrimInt64(), Nullable: false},
+		{ID: 1, Name: "id_hash", Type: arrow.PrimInt64(), Nullable: false},
+		{ID: 2, Name: "vector", Type: arrow.VectorType(s.dimension), Nullable: false},
+		{ID: 3, Name: "timestamp", Type: arrow.PrimInt64(), Nullable: false},
 	}, nil)
 }
 
@@ -232,6 +270,32 @@ func (s *DocumentStorage) Get(id string) (*Document, error) {
 	}, nil
 }
 
+// getMetadata returns a document's metadata without reading its vector from
+// column storage. The returned map is shared and must not be modified.
+func (s *DocumentStorage) getMetadata(id string) (map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("storage is closed")
+	}
+
+	for _, doc := range s.writeBuffer {
+		if doc.ID == id {
+			return doc.Metadata, nil
+		}
+	}
+
+	s.metaStore.mu.RLock()
+	defer s.metaStore.mu.RUnlock()
+
+	idHash, exists := s.metaStore.idToHash[id]
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+	return s.metaStore.entries[idHash].Metadata, nil
+}
+
 // GetBatch retrieves multiple documents by IDs.
 func (s *DocumentStorage) GetBatch(ids []string) (map[string]*Document, error) {
 	results := make(map[string]*Document, len(ids))
@@ -247,6 +311,87 @@ func (s *DocumentStorage) GetBatch(ids []string) (map[string]*Document, error) {
 	return results, nil
 }
 
+// getBatch looks up ids in one pass over the buffer and metadata store and
+// reads the vector column at most once, only if vectors are wanted. Without
+// vectors, flushed documents have no Timestamp. IDs that are not stored are
+// returned in missing, in the order given.
+func (s *DocumentStorage) getBatch(ids []string, vectors, metadata bool) (map[string]*Document, []string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, nil, fmt.Errorf("storage is closed")
+	}
+
+	// The first buffered copy wins, as in Get
+	buffered := make(map[string]*Document, len(s.writeBuffer))
+	for _, doc := range s.writeBuffer {
+		if _, ok := buffered[doc.ID]; !ok {
+			buffered[doc.ID] = doc
+		}
+	}
+
+	found := make(map[string]*Document, len(ids))
+	var missing []string
+	unread := make(map[int64]*Document)
+
+	s.metaStore.mu.RLock()
+	for _, id := range ids {
+		if _, ok := found[id]; ok {
+			continue
+		}
+		if doc, ok := buffered[id]; ok {
+			out := &Document{ID: doc.ID, Timestamp: doc.Timestamp}
+			if vectors {
+				out.Vector = append([]float32(nil), doc.Vector...)
+			}
+			if metadata && doc.Metadata != nil {
+				out.Metadata = make(map[string]interface{}, len(doc.Metadata))
+				for k, v := range doc.Metadata {
+					out.Metadata[k] = v
+				}
+			}
+			found[id] = out
+			continue
+		}
+
+		idHash, exists := s.metaStore.idToHash[id]
+		if !exists {
+			missing = append(missing, id)
+			continue
+		}
+		out := &Document{ID: id}
+		if metadata {
+			out.Metadata = s.metaStore.entries[idHash].Metadata
+		}
+		if vectors {
+			unread[idHash] = out
+		}
+		found[id] = out
+	}
+	s.metaStore.mu.RUnlock()
+
+	if len(unread) == 0 {
+		return found, missing, nil
+	}
+
+	docs, err := s.readAllDocuments()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, doc := range docs {
+		if out, ok := unread[hashID(doc.ID)]; ok && out.ID == doc.ID {
+			out.Vector = doc.Vector
+			out.Timestamp = doc.Timestamp
+			delete(unread, hashID(doc.ID))
+		}
+	}
+	for idHash := range unread {
+		return nil, nil, fmt.Errorf("vector not found for hash: %d", idHash)
+	}
+	return found, missing, nil
+}
+
 // Delete removes a document by ID.
 func (s *DocumentStorage) Delete(id string) error {
 	s.mu.Lock()
@@ -278,6 +423,9 @@ func (s *DocumentStorage) deleteFromStorage(id string) error {
 	s.metaStore.mu.Unlock()
 
 	s.dirty = true
+	if err := s.setStamp(0); err != nil {
+		return err
+	}
 
 	// Note: We don't immediately rewrite the column storage file.
 	// The deleted document will be filtered out on next read.
@@ -294,16 +442,59 @@ func (s *DocumentStorage) Flush() error {
 	return s.flush()
 }
 
+// flushCheckpoint writes all buffered documents as part of save id: the
+// directory is stamped with id first, so a save that stops partway leaves
+// files whose stamp does not match the published save.
+func (s *DocumentStorage) flushCheckpoint(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("storage is closed")
+	}
+	if err := s.setStamp(id); err != nil {
+		return err
+	}
+	if s.bufferSize == 0 {
+		return nil
+	}
+	return s.writeBuffered()
+}
+
 // flush is the internal flush implementation (must hold lock)
 func (s *DocumentStorage) flush() error {
 	if s.bufferSize == 0 {
 		return nil
 	}
+	if err := s.setStamp(0); err != nil {
+		return err
+	}
+	return s.writeBuffered()
+}
+
+// setStamp records that the files are about to be written for save id, 0
+// outside a save (must hold lock)
+func (s *DocumentStorage) setStamp(id uint64) error {
+	if s.stamp == id {
+		return nil
+	}
+	if s.memory {
+		s.stamp = id
+		return nil
+	}
+	if err := writeStamp(s.path, id); err != nil {
+		return fmt.Errorf("write stamp: %w", err)
+	}
+	s.stamp = id
+	return nil
+}
 
+// writeBuffered rewrites the data file with the buffered documents added
+// (must hold lock)
+func (s *DocumentStorage) writeBuffered() error {
 	// Read existing vectors if file exists
 	var existingDocs []*Document
-	dataFile := filepath.Join(s.path, dataFileName)
-	if _, err := os.Stat(dataFile); err == nil {
+	if s.hasData() {
 		docs, err := s.readAllDocuments()
 		if err != nil {
 			return fmt.Errorf("read existing documents: %w", err)
@@ -359,15 +550,31 @@ func (s *DocumentStorage) writeColumnStorage(docs []*Document) error {
 	if len(docs) == 0 {
 		return nil
 	}
+	if s.memory {
+		rows := make([]*Document, len(docs))
+		for i, doc := range docs {
+			rows[i] = &Document{ID: doc.ID, Vector: append([]float32(nil), doc.Vector...), Timestamp: doc.Timestamp}
+		}
+		s.rows = rows
+		return nil
+	}
 
+	// Write to a temporary file and replace the data file once complete
 	dataFile := filepath.Join(s.path, dataFileName)
+	tmpFile := dataFile + ".tmp"
 	schema := s.createSchema()
 
-	writer, err := column.NewWriter(dataFile, schema, s.factory)
+	writer, err := column.NewWriter(tmpFile, schema, s.factory)
 	if err != nil {
 		return fmt.Errorf("create writer: %w", err)
 	}
-	defer writer.Close()
+	closed := false
+	defer func() {
+		if !closed {
+			writer.Close()
+			os.Remove(tmpFile)
+		}
+	}()
 
 	// Build arrays
 	idBuilder := arrow.NewInt64Builder()
@@ -401,14 +608,32 @@ func (s *DocumentStorage) writeColumnStorage(docs []*Document) error {
 		return fmt.Errorf("write record batch: %w", err)
 	}
 
+	closed = true
+	if err := writer.Close(); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("close writer: %w", err)
+	}
+	if err := lanceio.ReplaceFile(tmpFile, dataFile); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("replace data file: %w", err)
+	}
+
 	return nil
 }
 
+// beforeColumnRead, when set by tests, runs before every read of the vector
+// column file
+var beforeColumnRead func()
+
 // readAllDocuments reads all documents from storage.
 func (s *DocumentStorage) readAllDocuments() ([]*Document, error) {
-	dataFile := filepath.Join(s.path, dataFileName)
-	
-	reader, err := column.NewReader(dataFile)
+	if beforeColumnRead != nil {
+		beforeColumnRead()
+	}
+	if s.memory {
+		return s.readRows(), nil
+	}
+	reader, err := s.openDataFile()
 	if err != nil {
 		return nil, fmt.Errorf("open reader: %w", err)
 	}
@@ -423,6 +648,13 @@ func (s *DocumentStorage) readAllDocuments() ([]*Document, error) {
 		return []*Document{}, nil
 	}
 
+	return s.batchDocuments(batch, nil), nil
+}
+
+// batchDocuments converts rows of the data file to documents. Rows whose
+// document was deleted are skipped, and so are rows keep rejects when it is
+// set; keep gets the row's index in batch and its ID hash.
+func (s *DocumentStorage) batchDocuments(batch *arrow.RecordBatch, keep func(i int, idHash int64) bool) []*Document {
 	// Extract columns
 	idHashArray := batch.Column(0).(*arrow.Int64Array)
 	vectorArray := batch.Column(1).(*arrow.FixedSizeListArray)
@@ -437,7 +669,10 @@ func (s *DocumentStorage) readAllDocuments() ([]*Document, error) {
 
 	for i := 0; i < batch.NumRows(); i++ {
 		idHash := idHashArray.Value(i)
-		
+		if keep != nil && !keep(i, idHash) {
+			continue
+		}
+
 		// Skip if not in metadata (deleted)
 		meta, exists := s.metaStore.entries[idHash]
 		if !exists {
@@ -458,7 +693,257 @@ func (s *DocumentStorage) readAllDocuments() ([]*Document, error) {
 		})
 	}
 
-	return docs, nil
+	return docs
+}
+
+// readRows is readAllDocuments of in-memory storage
+func (s *DocumentStorage) readRows() []*Document {
+	return s.rowDocuments(s.rows, nil)
+}
+
+// rowDocuments is batchDocuments of in-memory rows
+func (s *DocumentStorage) rowDocuments(rows []*Document, keep func(i int, idHash int64) bool) []*Document {
+	s.metaStore.mu.RLock()
+	defer s.metaStore.mu.RUnlock()
+
+	docs := make([]*Document, 0, len(rows))
+	for i, row := range rows {
+		idHash := hashID(row.ID)
+		if keep != nil && !keep(i, idHash) {
+			continue
+		}
+		meta, exists := s.metaStore.entries[idHash]
+		if !exists {
+			continue
+		}
+		docs = append(docs, &Document{
+			ID:        meta.ID,
+			Vector:    append([]float32(nil), row.Vector...),
+			Metadata:  meta.Metadata,
+			Timestamp: row.Timestamp,
+		})
+	}
+	return docs
+}
+
+// hasData reports whether documents were ever flushed to the data file
+// (must hold lock)
+func (s *DocumentStorage) hasData() bool {
+	if s.memory {
+		return s.rows != nil
+	}
+	_, err := os.Stat(filepath.Join(s.path, dataFileName))
+	return err == nil
+}
+
+// allDocuments returns every stored document, flushed or buffered. When a
+// document was written more than once, its latest version wins.
+func (s *DocumentStorage) allDocuments() ([]*Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("storage is closed")
+	}
+
+	var docs []*Document
+	if s.hasData() {
+		var err error
+		if docs, err = s.readAllDocuments(); err != nil {
+			return nil, err
+		}
+	}
+	for _, doc := range s.writeBuffer {
+		docs = append(docs, doc.Clone())
+	}
+
+	latest := make(map[string]int, len(docs))
+	unique := docs[:0]
+	for _, doc := range docs {
+		if i, ok := latest[doc.ID]; ok {
+			unique[i] = doc
+			continue
+		}
+		latest[doc.ID] = len(unique)
+		unique = append(unique, doc)
+	}
+	return unique, nil
+}
+
+// forEachChunk calls fn with every stored document, flushed or buffered,
+// at most size at a time. The data file is read one row range per call, so
+// only a chunk of vectors is held at once. As in allDocuments, the latest
+// version of a document written more than once wins. fn must not call back
+// into the storage.
+func (s *DocumentStorage) forEachChunk(size int, fn func(docs []*Document) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("storage is closed")
+	}
+
+	// Buffered documents are newer than any flushed row
+	buffered := make(map[string]*Document, len(s.writeBuffer))
+	var bufferOrder []string
+	for _, doc := range s.writeBuffer {
+		if _, ok := buffered[doc.ID]; !ok {
+			bufferOrder = append(bufferOrder, doc.ID)
+		}
+		buffered[doc.ID] = doc
+	}
+
+	// Flushed rows: a document rewritten since the file was last compacted
+	// has several rows, and only the last is current. Rows of a document
+	// rewritten into the buffer are all stale.
+	var hashes []int64
+	if s.memory {
+		for _, row := range s.rows {
+			hashes = append(hashes, hashID(row.ID))
+		}
+	} else if s.hasData() {
+		var err error
+		if hashes, err = s.readIDHashes(); err != nil {
+			return err
+		}
+	}
+	last := make(map[int64]int, len(hashes))
+	for row, idHash := range hashes {
+		last[idHash] = row
+	}
+
+	var reader *column.Reader
+	if !s.memory && len(hashes) > 0 {
+		var err error
+		if reader, err = s.openDataFile(); err != nil {
+			return fmt.Errorf("open reader: %w", err)
+		}
+		defer reader.Close()
+	}
+
+	for start := 0; start < len(hashes); start += size {
+		count := min(size, len(hashes)-start)
+		keep := func(i int, idHash int64) bool {
+			return last[idHash] == start+i
+		}
+
+		var docs []*Document
+		if s.memory {
+			docs = s.rowDocuments(s.rows[start:start+count], keep)
+		} else {
+			if beforeColumnRead != nil {
+				beforeColumnRead()
+			}
+			batch, err := reader.ReadRowRange(int64(start), int64(count))
+			if err != nil {
+				return fmt.Errorf("read rows %d-%d: %w", start, start+count, err)
+			}
+			docs = s.batchDocuments(batch, keep)
+		}
+
+		current := docs[:0]
+		for _, doc := range docs {
+			if _, ok := buffered[doc.ID]; !ok {
+				current = append(current, doc)
+			}
+		}
+		if len(current) == 0 {
+			continue
+		}
+		if err := fn(current); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(bufferOrder); start += size {
+		ids := bufferOrder[start:min(start+size, len(bufferOrder))]
+		docs := make([]*Document, len(ids))
+		for i, id := range ids {
+			docs[i] = buffered[id].Clone()
+		}
+		if err := fn(docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanOrder returns the IDs of the stored documents in storage order:
+// flushed documents in data file order, then buffered documents in write
+// order. Only the ID hash column of the data file is read.
+func (s *DocumentStorage) scanOrder() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("storage is closed")
+	}
+
+	var hashes []int64
+	if s.memory {
+		for _, row := range s.rows {
+			hashes = append(hashes, hashID(row.ID))
+		}
+	} else if s.hasData() {
+		var err error
+		if hashes, err = s.readIDHashes(); err != nil {
+			return nil, err
+		}
+	}
+
+	// A document rewritten since the file was last compacted has several
+	// rows; it keeps the position of the first
+	seen := make(map[string]struct{}, len(hashes)+len(s.writeBuffer))
+	ids := make([]string, 0, len(hashes)+len(s.writeBuffer))
+	s.metaStore.mu.RLock()
+	for _, idHash := range hashes {
+		meta, exists := s.metaStore.entries[idHash]
+		if !exists {
+			continue // Deleted, or rewritten into the buffer
+		}
+		if _, dup := seen[meta.ID]; !dup {
+			seen[meta.ID] = struct{}{}
+			ids = append(ids, meta.ID)
+		}
+	}
+	s.metaStore.mu.RUnlock()
+
+	for _, doc := range s.writeBuffer {
+		if _, dup := seen[doc.ID]; !dup {
+			seen[doc.ID] = struct{}{}
+			ids = append(ids, doc.ID)
+		}
+	}
+	return ids, nil
+}
+
+// readIDHashes reads the ID hash column of the data file, in row order
+func (s *DocumentStorage) readIDHashes() ([]int64, error) {
+	reader, err := s.openDataFile()
+	if err != nil {
+		return nil, fmt.Errorf("open reader: %w", err)
+	}
+	defer reader.Close()
+
+	batch, err := reader.ReadColumns([]string{"id_hash"})
+	if err != nil {
+		return nil, fmt.Errorf("read record batch: %w", err)
+	}
+
+	idHashArray := batch.Column(0).(*arrow.Int64Array)
+	hashes := make([]int64, batch.NumRows())
+	for i := range hashes {
+		hashes[i] = idHashArray.Value(i)
+	}
+	return hashes, nil
+}
+
+// openDataFile opens the data file to read it through createSchema, so
+// that files written before a column was added read it as null and files
+// with columns added since leave them out
+func (s *DocumentStorage) openDataFile() (*column.Reader, error) {
+	return column.NewReaderWithOptions(filepath.Join(s.path, dataFileName), nil,
+		column.ReaderOption{Schema: s.createSchema()})
 }
 
 // readVectorByHash reads a vector by its ID hash.
@@ -479,6 +964,10 @@ func (s *DocumentStorage) readVectorByHash(idHash int64) ([]float32, int64, erro
 
 // saveMetadata saves the metadata store to disk.
 func (s *DocumentStorage) saveMetadata() error {
+	if s.memory {
+		return nil
+	}
+
 	s.metaStore.mu.RLock()
 	data := struct {
 		Entries  map[int64]docMeta `json:"entries"`
@@ -487,18 +976,15 @@ func (s *DocumentStorage) saveMetadata() error {
 		Entries:  s.metaStore.entries,
 		IDToHash: s.metaStore.idToHash,
 	}
+	bytes, err := json.MarshalIndent(data, "", "  ")
 	s.metaStore.mu.RUnlock()
-
-	file, err := os.Create(s.metaStore.path)
 	if err != nil {
-		return fmt.Errorf("create metadata file: %w", err)
+		return fmt.Errorf("encode metadata: %w", err)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("encode metadata: %w", err)
+	// Replace atomically so a crash never leaves a truncated metadata file
+	if err := lanceio.WriteFileAtomic(s.metaStore.path, bytes, 0644); err != nil {
+		return fmt.Errorf("write metadata file: %w", err)
 	}
 
 	return nil
@@ -545,6 +1031,11 @@ func (s *DocumentStorage) loadMetadata() error {
 
 // load loads existing data.
 func (s *DocumentStorage) load() error {
+	stamp, err := readStamp(s.path)
+	if err != nil {
+		return err
+	}
+	s.stamp = stamp
 	return s.loadMetadata()
 }
 
@@ -558,7 +1049,10 @@ func (s *DocumentStorage) Stats() StorageStats {
 	s.metaStore.mu.RUnlock()
 
 	var dataSize, metaSize int64
-	
+	if s.memory {
+		return StorageStats{DocumentCount: docCount, BufferSize: s.bufferSize}
+	}
+
 	dataFile := filepath.Join(s.path, dataFileName)
 	if info, err := os.Stat(dataFile); err == nil {
 		dataSize = info.Size()
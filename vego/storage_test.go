@@ -0,0 +1,97 @@
+package vego
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzqhbustb/vego/storage/arrow"
+	"github.com/wzqhbustb/vego/storage/column"
+)
+
+// TestDocumentStorage_SchemaEvolution checks that the data file is read by
+// field ID: a file with a column added by a later version still reads, and
+// one whose vector column changed type fails naming it
+func TestDocumentStorage_SchemaEvolution(t *testing.T) {
+	path := t.TempDir()
+	storage, err := NewDocumentStorage(path, 4)
+	if err != nil {
+		t.Fatalf("NewDocumentStorage failed: %v", err)
+	}
+	docs := make([]*Document, 20)
+	for i := range docs {
+		docs[i] = &Document{ID: fmt.Sprintf("doc-%d", i), Vector: []float32{float32(i), 1, 2, 3}}
+	}
+	if err := storage.PutBatch(docs); err != nil {
+		t.Fatalf("PutBatch failed: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Rewrite the data file as a newer version would, with one more column
+	dataFile := filepath.Join(path, dataFileName)
+	reader, err := column.NewReader(dataFile)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	batch, err := reader.ReadRecordBatch()
+	reader.Close()
+	if err != nil {
+		t.Fatalf("ReadRecordBatch failed: %v", err)
+	}
+	fields := append(append([]arrow.Field(nil), batch.Schema().Fields()...),
+		arrow.Field{ID: 4, Name: "source", Type: arrow.PrimInt64(), Nullable: true})
+	columns := append(append([]arrow.Array(nil), batch.Columns()...),
+		arrow.NewInt64Array(make([]int64, batch.NumRows()), nil))
+	writeTestDataFile(t, dataFile, arrow.NewSchema(fields, nil), batch.NumRows(), columns)
+
+	storage, err = NewDocumentStorage(path, 4)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	doc, err := storage.Get("doc-7")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if doc.Vector[0] != 7 {
+		t.Errorf("doc-7 vector %v", doc.Vector)
+	}
+	storage.Close()
+
+	// A vector of another dimension under the same field ID is rejected
+	fields = append([]arrow.Field(nil), batch.Schema().Fields()...)
+	fields[1].Type = arrow.VectorType(8)
+	values := make([]float32, batch.NumRows()*8)
+	vectors := arrow.NewFixedSizeListArray(fields[1].Type.(*arrow.FixedSizeListType), arrow.NewFloat32Array(values, nil), nil)
+	writeTestDataFile(t, dataFile, arrow.NewSchema(fields, nil), batch.NumRows(),
+		[]arrow.Array{batch.Column(0), vectors, batch.Column(2)})
+	storage, err = NewDocumentStorage(path, 4)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer storage.Close()
+	if _, err := storage.Get("doc-7"); err == nil || !strings.Contains(err.Error(), "vector (id 2)") {
+		t.Errorf("Get of an incompatible file: %v", err)
+	}
+}
+
+// writeTestDataFile writes a single batch of columns to filename
+func writeTestDataFile(t *testing.T, filename string, schema *arrow.Schema, numRows int, columns []arrow.Array) {
+	t.Helper()
+	batch, err := arrow.NewRecordBatch(schema, numRows, columns)
+	if err != nil {
+		t.Fatalf("NewRecordBatch failed: %v", err)
+	}
+	writer, err := column.NewWriter(filename, schema, nil)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if err := writer.WriteRecordBatch(batch); err != nil {
+		t.Fatalf("WriteRecordBatch failed: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
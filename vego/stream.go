@@ -0,0 +1,217 @@
+package vego
+
+import (
+	"context"
+
+	hnsw "github.com/wzqhbustb/vego/index"
+)
+
+// defaultStreamBuffer is the channel capacity of SearchStream when
+// StreamOptions.Buffer is 0
+const defaultStreamBuffer = 64
+
+// StreamOptions contains options for SearchStream and SearchRadius. At least
+// one of K and Radius must be set.
+type StreamOptions struct {
+	K             int     // Stop after K hits, 0 = no limit
+	Radius        float32 // Only hits with Distance <= Radius when > 0
+	Filter        Filter  // Optional metadata filter, applied before hits count toward K
+	EF            int     // Traversal scope (0 = default max(200, 2K))
+	EFUpperLayers int     // Beam width above layer 0 (0 or 1 = greedy descent)
+	Vectors       bool    // Populate Document.Vector in hits
+	Buffer        int     // Channel capacity (0 = default 64)
+	MaxResults    int     // Safety cap of SearchRadius, signaled by ErrResultsTruncated (0 = none); SearchStream ignores it
+}
+
+// StreamHit is a search hit delivered by SearchStream. A hit with Err set
+// carries no document and is the last one sent before the channel closes.
+type StreamHit struct {
+	Document   *Document
+	Distance   float32
+	Similarity float32 // Distance as a score, see SearchResult
+	Err        error   // Why the stream ended early, see SearchStream
+}
+
+// SearchStream searches like SearchContext but delivers hits on a channel as
+// the traversal confirms them, for result sets too large to wait for.
+//
+// Hits arrive in non-decreasing distance order: a hit is sent once the
+// traversal has converged on the query and no candidate left to expand is
+// closer, see hnsw.HNSWIndex.SearchStream. Filters are applied before a hit
+// is sent. The channel is closed when K hits were sent, the traversal ends,
+// or ctx is done; ctx.Err() tells the last case apart. Sending blocks while
+// the consumer is behind, but the collection is not locked meanwhile, so
+// other searches and writes proceed.
+//
+// A stream that fails ends with a hit whose Err is set: when a document
+// cannot be loaded, when the traversal fails, or with ErrResultsTruncated
+// when the index is replaced while streaming (by an orphan sweep, Import or
+// Refresh), since the hits sent so far may not be all there are.
+//
+// Validation errors are returned directly; the channel is nil then.
+func (c *Collection) SearchStream(ctx context.Context, query []float32, opts StreamOptions) (<-chan StreamHit, error) {
+	ctx, done, err := c.begin(ctx, "SearchStream")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkQuery("SearchStream", query); err != nil {
+		done()
+		return nil, err
+	}
+	if opts.K < 0 {
+		done()
+		return nil, wrapError("SearchStream", c.name, "", ErrInvalidK)
+	}
+	if opts.K == 0 && opts.Radius <= 0 {
+		done()
+		return nil, wrapError("SearchStream", c.name, "", ErrValidationFailed)
+	}
+
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = defaultStreamBuffer
+	}
+	hits := make(chan StreamHit, buffer)
+
+	// An empty collection streams no hits
+	c.mu.RLock()
+	empty := c.index.Len() == 0
+	c.mu.RUnlock()
+	if empty {
+		done()
+		close(hits)
+		return hits, nil
+	}
+
+	go func() {
+		defer done()
+		defer close(hits)
+		c.stream(ctx, query, opts, hits)
+	}()
+	return hits, nil
+}
+
+// stream runs the traversal of SearchStream and sends its hits, then the
+// error that ended it, if any. c.mu is held for reading while the traversal
+// runs and released while a send blocks.
+func (c *Collection) stream(ctx context.Context, query []float32, opts StreamOptions, hits chan<- StreamHit) {
+	err := c.traverse(ctx, query, opts, hits)
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	select {
+	case hits <- StreamHit{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// traverse sends the hits of stream and returns the error ending it early
+func (c *Collection) traverse(ctx context.Context, query []float32, opts StreamOptions, hits chan<- StreamHit) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	index := c.index
+
+	// Without a filter every hit counts, so the index can stop by itself
+	params := hnsw.StreamParams{
+		Radius:        opts.Radius,
+		EfUpperLayers: opts.EFUpperLayers,
+		EfBase:        opts.EF,
+	}
+	if opts.Filter == nil {
+		params.K = opts.K
+	} else if opts.EF <= 0 {
+		params.EfBase = max(200, opts.K*2)
+	}
+
+	sent := 0
+	var failed error
+	err := index.SearchStream(ctx, query, params, func(hr hnsw.SearchResult) bool {
+		docID, exists := c.nodeToDoc[hr.ID]
+		if !exists {
+			return true // Skip deleted/orphaned nodes and nodes of in-progress batches
+		}
+
+		doc, err := c.storage.Get(docID)
+		if err != nil {
+			failed = wrapError("SearchStream", c.name, docID, err)
+			return false
+		}
+		if opts.Filter != nil && !opts.Filter.Match(doc) {
+			return true
+		}
+
+		// Vectors are returned as stored, as in SearchContext
+		if !opts.Vectors {
+			doc.Vector = nil
+		}
+
+		c.mu.RUnlock()
+		select {
+		case hits <- StreamHit{Document: doc, Distance: hr.Distance, Similarity: DistanceToScore(c.metric, hr.Distance)}:
+		case <-ctx.Done():
+			c.mu.RLock()
+			return false
+		}
+		c.mu.RLock()
+
+		sent++
+		if opts.K > 0 && sent == opts.K {
+			return false
+		}
+		if c.index != index {
+			failed = wrapError("SearchStream", c.name, "", ErrResultsTruncated)
+			return false
+		}
+		return true
+	})
+	if failed != nil {
+		return failed
+	}
+	return wrapError("SearchStream", c.name, "", err)
+}
+
+// SearchRadius returns the hits SearchStream would send for the same query
+// and options, in the same order. With Radius set, that is every matching
+// document found within it, closest first; a radius closer than the nearest
+// document gives no results and no error. If more than MaxResults hits were
+// found, the first MaxResults are returned with ErrResultsTruncated. If the
+// stream ended early, the hits sent so far are returned with its error.
+func (c *Collection) SearchRadius(ctx context.Context, query []float32, opts StreamOptions) ([]SearchResult, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	hits, err := c.SearchStream(streamCtx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	var streamErr error
+	truncated := false
+	for hit := range hits {
+		if hit.Err != nil {
+			streamErr = hit.Err
+			continue
+		}
+		if opts.MaxResults > 0 && len(results) == opts.MaxResults {
+			// Stop the stream and let it close the channel
+			truncated = true
+			cancel()
+			for range hits {
+			}
+			break
+		}
+		results = append(results, SearchResult{Document: hit.Document, Distance: hit.Distance, Similarity: hit.Similarity})
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if streamErr != nil {
+		return results, streamErr
+	}
+	if truncated {
+		return results, wrapError("SearchRadius", c.name, "", ErrResultsTruncated)
+	}
+	return results, nil
+}
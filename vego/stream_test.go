@@ -0,0 +1,313 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// setupStreamTest creates a collection of 2000 documents alternating between
+// the "even" and "odd" groups and returns it with a query vector
+func setupStreamTest(t *testing.T) (*Collection, []float32) {
+	t.Helper()
+	db, err := Open(t.TempDir(), WithDimension(16))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	coll, err := db.Collection("docs")
+	if err != nil {
+		t.Fatalf("Collection failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	docs := make([]*Document, 2000)
+	for i := range docs {
+		group := "even"
+		if i%2 == 1 {
+			group = "odd"
+		}
+		docs[i] = &Document{
+			ID:       fmt.Sprintf("doc_%04d", i),
+			Vector:   make([]float32, 16),
+			Metadata: map[string]interface{}{"group": group},
+		}
+		for j := range docs[i].Vector {
+			docs[i].Vector[j] = rng.Float32()
+		}
+	}
+	if err := coll.InsertBatch(docs); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	query := make([]float32, 16)
+	for j := range query {
+		query[j] = rng.Float32()
+	}
+	return coll, query
+}
+
+func collectStream(t *testing.T, hits <-chan StreamHit) []StreamHit {
+	t.Helper()
+	var out []StreamHit
+	for hit := range hits {
+		out = append(out, hit)
+	}
+	return out
+}
+
+func TestSearchStreamMatchesSearchRadius(t *testing.T) {
+	coll, query := setupStreamTest(t)
+	ctx := context.Background()
+
+	// A radius holding a few hundred documents
+	wide, err := coll.SearchContext(ctx, query, 300)
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	radius := wide[len(wide)-1].Distance
+
+	tests := []struct {
+		name string
+		opts StreamOptions
+	}{
+		{"k", StreamOptions{K: 1000}},
+		{"radius", StreamOptions{Radius: radius}},
+		{"radius and k", StreamOptions{K: 50, Radius: radius}},
+		{"filtered radius", StreamOptions{Radius: radius, Filter: &MetadataFilter{Field: "group", Operator: "eq", Value: "odd"}}},
+		{"filtered k", StreamOptions{K: 100, Filter: &MetadataFilter{Field: "group", Operator: "eq", Value: "even"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := coll.SearchStream(ctx, query, tt.opts)
+			if err != nil {
+				t.Fatalf("SearchStream failed: %v", err)
+			}
+			streamed := collectStream(t, hits)
+
+			batch, err := coll.SearchRadius(ctx, query, tt.opts)
+			if err != nil {
+				t.Fatalf("SearchRadius failed: %v", err)
+			}
+			if len(streamed) != len(batch) {
+				t.Fatalf("stream sent %d hits, SearchRadius returned %d", len(streamed), len(batch))
+			}
+			if len(streamed) == 0 {
+				t.Fatal("no hits")
+			}
+			for i := range batch {
+				if streamed[i].Document.ID != batch[i].Document.ID || streamed[i].Distance != batch[i].Distance {
+					t.Fatalf("hit %d differs: stream %s, SearchRadius %s", i, streamed[i].Document.ID, batch[i].Document.ID)
+				}
+			}
+
+			if !sort.SliceIsSorted(streamed, func(a, b int) bool { return streamed[a].Distance < streamed[b].Distance }) {
+				t.Errorf("hits are not in ascending distance order")
+			}
+			if tt.opts.K > 0 && len(streamed) > tt.opts.K {
+				t.Errorf("got %d hits, limit %d", len(streamed), tt.opts.K)
+			}
+			for _, hit := range streamed {
+				if tt.opts.Radius > 0 && hit.Distance > tt.opts.Radius {
+					t.Errorf("hit %s at %f outside radius %f", hit.Document.ID, hit.Distance, tt.opts.Radius)
+				}
+				if tt.opts.Filter != nil && !tt.opts.Filter.Match(hit.Document) {
+					t.Errorf("hit %s does not match the filter", hit.Document.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchRadiusMaxResults(t *testing.T) {
+	coll, query := setupStreamTest(t)
+	ctx := context.Background()
+	wide, err := coll.SearchContext(ctx, query, 300)
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	opts := StreamOptions{
+		Radius: wide[len(wide)-1].Distance,
+		Filter: &MetadataFilter{Field: "group", Operator: "eq", Value: "odd"},
+	}
+	all, err := coll.SearchRadius(ctx, query, opts)
+	if err != nil || len(all) < 100 {
+		t.Fatalf("SearchRadius = %d hits, %v", len(all), err)
+	}
+
+	opts.MaxResults = 20
+	capped, err := coll.SearchRadius(ctx, query, opts)
+	if !errors.Is(err, ErrResultsTruncated) || len(capped) != 20 {
+		t.Fatalf("SearchRadius(MaxResults: 20) = %d hits, %v; want 20, ErrResultsTruncated", len(capped), err)
+	}
+	for i := range capped {
+		if capped[i].Document.ID != all[i].Document.ID {
+			t.Fatalf("truncated hit %d is %s, want %s", i, capped[i].Document.ID, all[i].Document.ID)
+		}
+	}
+
+	opts.MaxResults = len(all)
+	if got, err := coll.SearchRadius(ctx, query, opts); err != nil || len(got) != len(all) {
+		t.Errorf("SearchRadius(MaxResults: %d) = %d hits, %v; want all, nil", len(all), len(got), err)
+	}
+
+	opts.Radius = wide[0].Distance / 2
+	if got, err := coll.SearchRadius(ctx, query, opts); err != nil || len(got) != 0 {
+		t.Errorf("SearchRadius(tiny radius) = %d hits, %v; want none, nil", len(got), err)
+	}
+}
+
+func TestSearchStreamCancel(t *testing.T) {
+	coll, query := setupStreamTest(t)
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hits, err := coll.SearchStream(ctx, query, StreamOptions{K: 2000, Buffer: 1})
+	if err != nil {
+		t.Fatalf("SearchStream failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		<-hits
+	}
+	cancel()
+
+	// The channel closes promptly once the context is cancelled
+	deadline := time.After(5 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-hits:
+		case <-deadline:
+			t.Fatal("stream did not close after cancel")
+		}
+	}
+
+	for start := time.Now(); runtime.NumGoroutine() > baseline; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("goroutines leaked: %d running, %d before the stream", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSearchStreamSlowConsumer(t *testing.T) {
+	coll, query := setupStreamTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Nobody reads the stream, so its sender blocks on the full channel
+	if _, err := coll.SearchStream(ctx, query, StreamOptions{K: 1000, Buffer: 1}); err != nil {
+		t.Fatalf("SearchStream failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		if _, err := coll.Search(query, 10); err != nil {
+			done <- err
+			return
+		}
+		done <- coll.Insert(&Document{ID: "late", Vector: query})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("concurrent operation failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked stream held up concurrent search and insert")
+	}
+}
+
+func TestSearchStreamValidation(t *testing.T) {
+	coll, query := setupStreamTest(t)
+	ctx := context.Background()
+
+	if _, err := coll.SearchStream(ctx, query[:8], StreamOptions{K: 10}); !IsDimensionMismatch(err) {
+		t.Errorf("short query: err = %v, want dimension mismatch", err)
+	}
+	if _, err := coll.SearchStream(ctx, query, StreamOptions{K: -1}); !IsInvalidK(err) {
+		t.Errorf("negative K: err = %v, want ErrInvalidK", err)
+	}
+	if _, err := coll.SearchStream(ctx, query, StreamOptions{}); !IsValidationFailed(err) {
+		t.Errorf("no limit: err = %v, want ErrValidationFailed", err)
+	}
+}
+
+// drainStream reads hits until the channel closes and returns the number of
+// documents and the error of the terminal hit, if any
+func drainStream(hits <-chan StreamHit) (int, error) {
+	n := 0
+	var err error
+	for hit := range hits {
+		if hit.Err != nil {
+			err = hit.Err
+			continue
+		}
+		n++
+	}
+	return n, err
+}
+
+func TestSearchStreamIndexReplaced(t *testing.T) {
+	coll, query := setupStreamTest(t)
+	ctx := context.Background()
+
+	hits, err := coll.SearchStream(ctx, query, StreamOptions{K: 2000, Buffer: 1})
+	if err != nil {
+		t.Fatalf("SearchStream failed: %v", err)
+	}
+	<-hits
+
+	// Swap the index as Refresh or Import would
+	fresh, err := newIndex(coll.config)
+	if err != nil {
+		t.Fatalf("newIndex failed: %v", err)
+	}
+	coll.mu.Lock()
+	coll.index = fresh
+	coll.mu.Unlock()
+
+	n, err := drainStream(hits)
+	if !errors.Is(err, ErrResultsTruncated) {
+		t.Fatalf("stream over a replaced index ended with %v after %d more hits, want ErrResultsTruncated", err, n)
+	}
+	if n+1 >= 2000 {
+		t.Errorf("stream sent %d hits, want it to stop early", n+1)
+	}
+}
+
+func TestSearchRadiusLoadFailure(t *testing.T) {
+	coll, query := setupStreamTest(t)
+	ctx := context.Background()
+
+	all, err := coll.SearchRadius(ctx, query, StreamOptions{K: 50})
+	if err != nil || len(all) != 50 {
+		t.Fatalf("SearchRadius = %d hits, %v", len(all), err)
+	}
+
+	// Leave the nearest document's node mapped while storage loses it
+	lost := all[10].Document.ID
+	coll.mu.Lock()
+	err = coll.storage.Delete(lost)
+	coll.mu.Unlock()
+	if err != nil {
+		t.Fatalf("storage Delete failed: %v", err)
+	}
+
+	got, err := coll.SearchRadius(ctx, query, StreamOptions{K: 50})
+	if !IsNotFound(err) {
+		t.Fatalf("SearchRadius over a lost document: err = %v, want not found", err)
+	}
+	var vegoErr *Error
+	if !errors.As(err, &vegoErr) || vegoErr.DocID != lost {
+		t.Errorf("err = %v, want it to name %s", err, lost)
+	}
+	if len(got) != 10 {
+		t.Errorf("SearchRadius returned %d hits, want the 10 before the lost document", len(got))
+	}
+}
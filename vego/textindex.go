@@ -0,0 +1,185 @@
+package vego
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: term frequency saturation and length normalization
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// textIndex is an inverted index over one metadata string field of a
+// collection's documents, scored with BM25; see WithTextIndex. It is
+// guarded by the collection's mu. Its methods are no-ops on a nil index, so
+// collections without a text index call them unconditionally.
+type textIndex struct {
+	field    string
+	docs     map[string]map[string]int      // Document ID -> term -> frequency
+	lengths  map[string]int                 // Document ID -> number of terms
+	postings map[string]map[string]struct{} // Term -> IDs of the documents containing it
+	total    int                            // Sum of lengths
+}
+
+// textSnapshot is the persisted form of a textIndex, saved with the
+// mappings; postings and lengths are derived from docs on load
+type textSnapshot struct {
+	Field string                    `json:"field"`
+	Docs  map[string]map[string]int `json:"docs"`
+}
+
+// textHit is a document matching a text query
+type textHit struct {
+	ID    string
+	Score float64
+}
+
+func newTextIndex(field string) *textIndex {
+	return &textIndex{
+		field:    field,
+		docs:     make(map[string]map[string]int),
+		lengths:  make(map[string]int),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+// tokenize splits text into lowercase terms at every character that is not
+// a letter or digit
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes doc's text field, replacing what was indexed for its ID. A
+// document whose field is missing or not a string is not indexed.
+func (t *textIndex) add(doc *Document) {
+	if t == nil {
+		return
+	}
+	t.remove(doc.ID)
+
+	text, _ := doc.Metadata[t.field].(string)
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return
+	}
+	freqs := make(map[string]int)
+	for _, term := range terms {
+		freqs[term]++
+	}
+	t.addTerms(doc.ID, freqs)
+}
+
+// addTerms indexes a document with the given term frequencies
+func (t *textIndex) addTerms(id string, freqs map[string]int) {
+	length := 0
+	for term, n := range freqs {
+		length += n
+		docs, ok := t.postings[term]
+		if !ok {
+			docs = make(map[string]struct{})
+			t.postings[term] = docs
+		}
+		docs[id] = struct{}{}
+	}
+	t.docs[id] = freqs
+	t.lengths[id] = length
+	t.total += length
+}
+
+// remove drops a document from the index
+func (t *textIndex) remove(id string) {
+	if t == nil {
+		return
+	}
+	freqs, ok := t.docs[id]
+	if !ok {
+		return
+	}
+	for term := range freqs {
+		delete(t.postings[term], id)
+		if len(t.postings[term]) == 0 {
+			delete(t.postings, term)
+		}
+	}
+	t.total -= t.lengths[id]
+	delete(t.docs, id)
+	delete(t.lengths, id)
+}
+
+// search returns the n documents scoring highest for query with BM25,
+// best first; documents sharing no term with query are not returned
+func (t *textIndex) search(query string, n int) []textHit {
+	if t == nil || len(t.docs) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	scores := make(map[string]float64)
+	numDocs := float64(len(t.docs))
+	avgLength := float64(t.total) / numDocs
+	for _, term := range tokenize(query) {
+		if _, dup := seen[term]; dup {
+			continue
+		}
+		seen[term] = struct{}{}
+
+		docs := t.postings[term]
+		df := float64(len(docs))
+		idf := math.Log(1 + (numDocs-df+0.5)/(df+0.5))
+		for id := range docs {
+			tf := float64(t.docs[id][term])
+			norm := 1 - bm25B + bm25B*float64(t.lengths[id])/avgLength
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	hits := make([]textHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, textHit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > n {
+		hits = hits[:n]
+	}
+	return hits
+}
+
+// snapshot returns the index in its persisted form, or nil for a nil index
+func (t *textIndex) snapshot() *textSnapshot {
+	if t == nil {
+		return nil
+	}
+	return &textSnapshot{Field: t.field, Docs: t.docs}
+}
+
+// restore replaces the index's contents with snap. It reports false, leaving
+// the index empty, if snap is nil or was taken of another field.
+func (t *textIndex) restore(snap *textSnapshot) bool {
+	t.clear()
+	if snap == nil || snap.Field != t.field {
+		return false
+	}
+	for id, freqs := range snap.Docs {
+		t.addTerms(id, freqs)
+	}
+	return true
+}
+
+// clear empties the index
+func (t *textIndex) clear() {
+	t.docs = make(map[string]map[string]int)
+	t.lengths = make(map[string]int)
+	t.postings = make(map[string]map[string]struct{})
+	t.total = 0
+}
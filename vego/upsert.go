@@ -0,0 +1,144 @@
+package vego
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ConflictPolicy is how InsertBatchOpts handles a document whose ID is
+// already in use
+type ConflictPolicy int
+
+const (
+	// ConflictError fails the whole batch with ErrDuplicateID, as
+	// InsertBatchContext does
+	ConflictError ConflictPolicy = iota
+	// ConflictSkip keeps the stored document and drops the new one
+	ConflictSkip
+	// ConflictReplace replaces the stored document's vector and metadata, as
+	// UpsertContext does
+	ConflictReplace
+)
+
+// InsertBatchOptions contains options for InsertBatchOpts
+type InsertBatchOptions struct {
+	OnConflict ConflictPolicy // Default ConflictError
+}
+
+// InsertBatchResult reports what InsertBatchOpts did with each document
+type InsertBatchResult struct {
+	Inserted int // Documents with new IDs
+	Replaced int // Documents that replaced a stored document or an earlier copy
+	Skipped  int // Documents dropped by ConflictSkip
+}
+
+// InsertBatchOpts inserts docs like InsertBatchContext, handling IDs that
+// are already stored by opts.OnConflict. An ID repeated within docs
+// conflicts with its earlier copy: ConflictSkip keeps the first copy and
+// ConflictReplace the last, counting the others as skipped or replaced.
+// With either policy, re-running a batch after a crash completes it instead
+// of failing on the documents already written.
+//
+// Replacements are written first, each atomically as with UpdateContext,
+// then the new documents are inserted as one batch. If the batch fails
+// part way, the result counts the documents written before the error; it
+// is nil if the batch was rejected up front. An ID being inserted by another
+// batch at the same time is skipped with ConflictSkip and fails the batch
+// with ErrDuplicateID otherwise.
+func (c *Collection) InsertBatchOpts(ctx context.Context, docs []*Document, opts InsertBatchOptions) (*InsertBatchResult, error) {
+	return c.insertBatchOpts(ctx, "InsertBatchOpts", docs, opts.OnConflict)
+}
+
+// UpsertBatchContext inserts the documents of docs with new IDs and
+// replaces the stored documents with the IDs of the others; it is
+// InsertBatchOpts with ConflictReplace
+func (c *Collection) UpsertBatchContext(ctx context.Context, docs []*Document) (*InsertBatchResult, error) {
+	return c.insertBatchOpts(ctx, "UpsertBatchContext", docs, ConflictReplace)
+}
+
+func (c *Collection) insertBatchOpts(ctx context.Context, op string, docs []*Document, policy ConflictPolicy) (*InsertBatchResult, error) {
+	if policy < ConflictError || policy > ConflictReplace {
+		return nil, wrapError(op, c.name, "", fmt.Errorf("%w: unknown conflict policy %d", ErrValidationFailed, policy))
+	}
+	if c.shards != nil {
+		return c.shardInsertBatchOpts(ctx, op, docs, policy)
+	}
+	return c.insertBatch(ctx, op, docs, policy)
+}
+
+// resolveConflicts splits docs into the documents to insert and the ones
+// replacing a stored document, by policy. Each ID is inserted or replaced
+// once, with its first copy under ConflictSkip and its last otherwise.
+// c.mu must be held for writing.
+func (c *Collection) resolveConflicts(op string, docs []*Document, policy ConflictPolicy) (inserts, replaces []*Document, skipped int, err error) {
+	insertAt := make(map[string]int, len(docs))
+	replaceAt := make(map[string]int)
+	for _, doc := range docs {
+		_, exists := c.docToNode[doc.ID]
+		_, reserved := c.pending[doc.ID]
+		i, repeated := insertAt[doc.ID]
+		j, replacing := replaceAt[doc.ID]
+
+		switch {
+		case !exists && !reserved && !repeated:
+			insertAt[doc.ID] = len(inserts)
+			inserts = append(inserts, doc)
+		case policy == ConflictSkip:
+			skipped++
+		case policy == ConflictReplace && repeated:
+			inserts[i] = doc
+		case policy == ConflictReplace && replacing:
+			replaces[j] = doc
+		case policy == ConflictReplace && exists:
+			replaceAt[doc.ID] = len(replaces)
+			replaces = append(replaces, doc)
+		default:
+			// ConflictError, or another batch is inserting the ID
+			return nil, nil, 0, wrapError(op, c.name, doc.ID, ErrDuplicateID)
+		}
+	}
+	return inserts, replaces, skipped, nil
+}
+
+// shardInsertBatchOpts is insertBatchOpts of a sharded collection: each
+// shard resolves the conflicts of its own documents, and the results are
+// summed over the shards that succeeded
+func (c *Collection) shardInsertBatchOpts(ctx context.Context, op string, docs []*Document, policy ConflictPolicy) (*InsertBatchResult, error) {
+	ctx, done, err := c.beginWrite(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := c.validateBatch(op, docs); err != nil {
+		return nil, err
+	}
+	groups := make(map[*Collection][]*Document)
+	for _, doc := range docs {
+		shard, err := c.shardOf(op, doc.ID)
+		if err != nil {
+			return nil, err
+		}
+		groups[shard] = append(groups[shard], doc)
+	}
+
+	var mu sync.Mutex
+	result := &InsertBatchResult{}
+	failed := c.eachShard(func(_ int, shard *Collection) error {
+		group := groups[shard]
+		if len(group) == 0 {
+			return nil
+		}
+		part, err := shard.insertBatch(ctx, op, group, policy)
+		if part != nil {
+			mu.Lock()
+			result.Inserted += part.Inserted
+			result.Replaced += part.Replaced
+			result.Skipped += part.Skipped
+			mu.Unlock()
+		}
+		return err
+	})
+	return result, c.shardError(op, c.openFailures(failed))
+}
@@ -0,0 +1,207 @@
+package vego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// upsertTestDocs returns n documents doc_000.. whose vectors and metadata
+// depend on version
+func upsertTestDocs(n, version int) []*Document {
+	docs := make([]*Document, n)
+	for i := range docs {
+		doc := createTestDocument(fmt.Sprintf("doc_%03d", i), 64, map[string]interface{}{"version": version})
+		doc.Vector[0] = float32(version)
+		doc.Vector[1] = float32(i)
+		docs[i] = doc
+	}
+	return docs
+}
+
+// checkNoLeakedNodes fails if the index holds live nodes not mapped to a
+// document
+func checkNoLeakedNodes(t *testing.T, coll *Collection) {
+	t.Helper()
+	coll.mu.RLock()
+	defer coll.mu.RUnlock()
+	if coll.index.Len() != len(coll.docToNode) || len(coll.orphans) != 0 {
+		t.Errorf("index has %d live nodes and %d orphans for %d documents", coll.index.Len(), len(coll.orphans), len(coll.docToNode))
+	}
+}
+
+func TestInsertBatchOpts(t *testing.T) {
+	ctx := context.Background()
+
+	// The second batch is half documents already stored, half new ones
+	setup := func(t *testing.T) (*Collection, []*Document, func()) {
+		coll, cleanup := setupTestCollection(t)
+		if err := coll.InsertBatch(upsertTestDocs(50, 1)); err != nil {
+			cleanup()
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		return coll, upsertTestDocs(100, 2), cleanup
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		coll, docs, cleanup := setup(t)
+		defer cleanup()
+
+		result, err := coll.InsertBatchOpts(ctx, docs, InsertBatchOptions{})
+		if !errors.Is(err, ErrDuplicateID) || result != nil {
+			t.Fatalf("InsertBatchOpts = %v, %v, want ErrDuplicateID", result, err)
+		}
+		if coll.Count() != 50 {
+			t.Errorf("Count = %d after rejected batch, want 50", coll.Count())
+		}
+		checkNoLeakedNodes(t, coll)
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		coll, docs, cleanup := setup(t)
+		defer cleanup()
+
+		result, err := coll.InsertBatchOpts(ctx, docs, InsertBatchOptions{OnConflict: ConflictSkip})
+		if err != nil {
+			t.Fatalf("InsertBatchOpts failed: %v", err)
+		}
+		if *result != (InsertBatchResult{Inserted: 50, Skipped: 50}) {
+			t.Errorf("result = %+v", *result)
+		}
+		for i, want := range map[int]float64{0: 1, 49: 1, 50: 2, 99: 2} {
+			doc, _ := coll.Get(fmt.Sprintf("doc_%03d", i))
+			if version, _ := doc.GetFloat("version"); version != want {
+				t.Errorf("doc_%03d has version %v, want %v", i, version, want)
+			}
+		}
+		checkNoLeakedNodes(t, coll)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		coll, docs, cleanup := setup(t)
+		defer cleanup()
+
+		result, err := coll.UpsertBatchContext(ctx, docs)
+		if err != nil {
+			t.Fatalf("UpsertBatchContext failed: %v", err)
+		}
+		if *result != (InsertBatchResult{Inserted: 50, Replaced: 50}) {
+			t.Errorf("result = %+v", *result)
+		}
+		if coll.Count() != 100 {
+			t.Errorf("Count = %d, want 100", coll.Count())
+		}
+		for _, doc := range docs {
+			got, err := coll.Get(doc.ID)
+			if err != nil {
+				t.Fatalf("Get(%s) failed: %v", doc.ID, err)
+			}
+			if version, _ := got.GetFloat("version"); version != 2 || !vectorsEqual(got.Vector, doc.Vector) {
+				t.Fatalf("%s not replaced: version %v", doc.ID, version)
+			}
+		}
+		checkNoLeakedNodes(t, coll)
+
+		// Replaced vectors are found under their new values only
+		results, err := coll.Search(docs[10].Vector, 1)
+		if err != nil || len(results) != 1 || results[0].Document.ID != docs[10].ID {
+			t.Errorf("Search for replaced vector = %v, %v", results, err)
+		}
+
+		// Re-running the batch replaces everything and leaks nothing
+		result, err = coll.UpsertBatchContext(ctx, docs)
+		if err != nil || *result != (InsertBatchResult{Replaced: 100}) {
+			t.Errorf("re-run = %+v, %v", result, err)
+		}
+		checkNoLeakedNodes(t, coll)
+	})
+
+	t.Run("RepeatedIDs", func(t *testing.T) {
+		coll, cleanup := setupTestCollection(t)
+		defer cleanup()
+
+		first, last := upsertTestDocs(1, 1)[0], upsertTestDocs(1, 2)[0]
+		batch := []*Document{first, last}
+
+		result, err := coll.InsertBatchOpts(ctx, batch, InsertBatchOptions{OnConflict: ConflictSkip})
+		if err != nil || *result != (InsertBatchResult{Inserted: 1, Skipped: 1}) {
+			t.Fatalf("Skip = %+v, %v", result, err)
+		}
+		if doc, _ := coll.Get(first.ID); doc.Metadata["version"] != 1 {
+			t.Errorf("Skip kept version %v, want the first copy", doc.Metadata["version"])
+		}
+
+		coll.Delete(first.ID)
+		result, err = coll.UpsertBatchContext(ctx, batch)
+		if err != nil || *result != (InsertBatchResult{Inserted: 1, Replaced: 1}) {
+			t.Fatalf("Replace = %+v, %v", result, err)
+		}
+		if doc, _ := coll.Get(first.ID); doc.Metadata["version"] != 2 {
+			t.Errorf("Replace kept version %v, want the last copy", doc.Metadata["version"])
+		}
+		checkNoLeakedNodes(t, coll)
+	})
+
+	t.Run("Sharded", func(t *testing.T) {
+		db, err := Open(t.TempDir(), WithDimension(64), WithShards(3, nil))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer db.Close()
+		coll, _ := db.Collection("sharded")
+		if err := coll.InsertBatch(upsertTestDocs(50, 1)); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+
+		result, err := coll.UpsertBatchContext(ctx, upsertTestDocs(100, 2))
+		if err != nil || *result != (InsertBatchResult{Inserted: 50, Replaced: 50}) {
+			t.Fatalf("UpsertBatchContext = %+v, %v", result, err)
+		}
+		if doc, _ := coll.Get("doc_000"); doc.Metadata["version"] != 2 {
+			t.Errorf("doc_000 has version %v after upsert", doc.Metadata["version"])
+		}
+		for _, shard := range coll.shards {
+			checkNoLeakedNodes(t, shard)
+		}
+	})
+
+	t.Run("UnknownPolicy", func(t *testing.T) {
+		coll, cleanup := setupTestCollection(t)
+		defer cleanup()
+
+		_, err := coll.InsertBatchOpts(ctx, upsertTestDocs(1, 1), InsertBatchOptions{OnConflict: 7})
+		if !errors.Is(err, ErrValidationFailed) {
+			t.Errorf("InsertBatchOpts with unknown policy = %v", err)
+		}
+	})
+}
+
+func TestUpsertConcurrent(t *testing.T) {
+	coll, cleanup := setupTestCollection(t)
+	defer cleanup()
+
+	// Upserts of the same new ID race on the existence check; none fails
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc := createTestDocument("shared", 64, map[string]interface{}{"writer": i})
+			errs <- coll.Upsert(doc)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent Upsert failed: %v", err)
+		}
+	}
+	if coll.Count() != 1 {
+		t.Errorf("Count = %d, want 1", coll.Count())
+	}
+	checkNoLeakedNodes(t, coll)
+}